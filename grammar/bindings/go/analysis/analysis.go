@@ -0,0 +1,369 @@
+// Package analysis runs the checks of a project as a pipeline of passes,
+// in the manner of golang.org/x/tools/go/analysis, so that the linter, the
+// metrics, type inference and dead-code detection share one parse of each
+// file and each other's results instead of each walking the tree on its
+// own.
+//
+// A Pass declares the passes whose results it needs and whether it
+// exports facts, values about globals that its runs on the files using
+// those globals import: the types pass exports the type of each global
+// it infers, so that a call of a function defined in another file is
+// checked against it. Run schedules the passes of every file after the
+// passes they require, and the files after those assigning the globals
+// they use, so that facts are exported before they are imported.
+//
+// Which passes report diagnostics is configured in wabznasm.yaml files;
+// see ParseConfig. A pass that is disabled still runs when an enabled one
+// requires it, but its diagnostics are dropped.
+//
+// The passes shipped with the package register themselves, and further
+// passes can be added with Register.
+package analysis
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/depgraph"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+// Pass is one analysis of a file.
+type Pass interface {
+	// Name identifies the pass in diagnostics and configuration, e.g.
+	// "types".
+	Name() string
+	// Doc is a one-line description of what the pass computes or reports.
+	Doc() string
+	// Requires lists the passes whose results Run reads with
+	// Context.Result. They run on the file first.
+	Requires() []Pass
+	// Facts reports whether Run exports facts with Context.ExportFact.
+	Facts() bool
+	// Run analyses the file of c and returns a result for the passes
+	// requiring this one, which may be nil. A pass failing with an error
+	// fails the passes requiring it too. Trees may contain syntax errors;
+	// passes should skip what they cannot interpret.
+	Run(c *Context) (any, error)
+}
+
+// Diagnostic is a problem reported by a pass.
+type Diagnostic struct {
+	Path string
+	// Pass is the name of the pass reporting the problem.
+	Pass string
+	// Code classifies the problem within the pass, such as the code of a
+	// syntax error or the rule of a lint finding.
+	Code     string
+	Range    tree_sitter.Range
+	Severity tree_sitter_wabznasm.Severity
+	Message  string
+	// Fixes are machine-applicable corrections, if the pass offers any.
+	Fixes []tree_sitter_wabznasm.Fix
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s (%s)", d.Path, d.Range.StartPoint.Row+1, d.Range.StartPoint.Column+1, d.Message, d.Pass)
+}
+
+// Error reports a pass that failed on a file.
+type Error struct {
+	Path string
+	Pass string
+	Err  error
+}
+
+func (e *Error) Error() string { return fmt.Sprintf("%s: %s: %v", e.Path, e.Pass, e.Err) }
+func (e *Error) Unwrap() error { return e.Err }
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Pass{}
+)
+
+// Register adds p to the registry. It panics if a pass with the same name
+// is already registered.
+func Register(p Pass) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := registry[p.Name()]; dup {
+		panic("analysis: pass registered twice: " + p.Name())
+	}
+	registry[p.Name()] = p
+}
+
+// Lookup returns the registered pass called name.
+func Lookup(name string) (Pass, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := registry[name]
+	return p, ok
+}
+
+// Passes returns the registered passes sorted by name.
+func Passes() []Pass {
+	mu.RLock()
+	defer mu.RUnlock()
+	passes := make([]Pass, 0, len(registry))
+	for _, p := range registry {
+		passes = append(passes, p)
+	}
+	sort.Slice(passes, func(i, j int) bool { return passes[i].Name() < passes[j].Name() })
+	return passes
+}
+
+// Context is what a pass sees of the file it analyses.
+type Context struct {
+	Project *project.Project
+	File    *project.File
+	// Script is the file parsed a statement at a time, shared by every
+	// pass.
+	Script *tree_sitter_wabznasm.Script
+
+	pass    Pass
+	run     *run
+	results map[string]any
+	enabled map[string]bool
+}
+
+// Result returns the result of the pass p on the file. p must be one of
+// the passes the running pass requires.
+func (c *Context) Result(p Pass) any {
+	v, ok := c.results[p.Name()]
+	if !ok || !requires(c.pass, p.Name()) {
+		panic(fmt.Sprintf("analysis: pass %s reads the result of %s without requiring it", c.pass.Name(), p.Name()))
+	}
+	return v
+}
+
+// Report reports a problem in the file. Its Path and Pass are filled in.
+func (c *Context) Report(d Diagnostic) {
+	if !c.enabled[c.pass.Name()] {
+		return
+	}
+	d.Path, d.Pass = c.File.Path, c.pass.Name()
+	c.run.diagnostics = append(c.run.diagnostics, d)
+}
+
+// Enabled reports whether the configuration of the file enables name, a
+// pass or a setting a pass looks up, such as a lint rule.
+func (c *Context) Enabled(name string) bool { return c.enabled[name] }
+
+// ExportFact records fact about the global name, for the runs of the pass
+// on the files using it. A pass must declare that it exports facts.
+func (c *Context) ExportFact(name string, fact any) {
+	if !c.pass.Facts() {
+		panic("analysis: pass exports facts without declaring them: " + c.pass.Name())
+	}
+	facts := c.run.facts[c.pass.Name()]
+	if facts == nil {
+		facts = map[string]any{}
+		c.run.facts[c.pass.Name()] = facts
+	}
+	facts[name] = fact
+}
+
+// Fact returns the fact the pass exported about the global name, if the
+// file assigning it was analysed first. Files depending on each other in
+// a cycle see only the facts of those before them in path order.
+func (c *Context) Fact(name string) (any, bool) {
+	fact, ok := c.run.facts[c.pass.Name()][name]
+	return fact, ok
+}
+
+// Shared returns the value fn computes, calling it only once in a run for
+// each key, so that the passes of every file can share work about the
+// whole project, such as finding which globals are used.
+func (c *Context) Shared(key any, fn func() any) any {
+	if v, ok := c.run.shared[key]; ok {
+		return v
+	}
+	v := fn()
+	c.run.shared[key] = v
+	return v
+}
+
+// Result is the outcome of Run.
+type Result struct {
+	// Diagnostics are the problems the enabled passes reported, ordered
+	// by path, then position, then pass.
+	Diagnostics []Diagnostic
+	// Errors are the passes that failed, as *Error values, in the order
+	// they ran.
+	Errors []error
+
+	results map[string]map[string]any
+}
+
+// Of returns the result of the pass named pass on the file at path, and
+// false if it did not run there.
+func (r *Result) Of(path, pass string) (any, bool) {
+	v, ok := r.results[path][pass]
+	return v, ok
+}
+
+// run is the state of one call of Run.
+type run struct {
+	diagnostics []Diagnostic
+	facts       map[string]map[string]any
+	shared      map[any]any
+}
+
+// Run runs passes, and the passes they require, on every file of p, as
+// the wabznasm.yaml files of the project configure them. It fails if a
+// configuration file is invalid or the passes require each other in a
+// cycle, and stops early, returning ctx.Err(), if ctx is done first.
+func Run(ctx context.Context, p *project.Project, passes ...Pass) (*Result, error) {
+	order, err := schedule(passes)
+	if err != nil {
+		return nil, err
+	}
+	configs := newConfigs(p.Root, knownNames(order))
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+
+	r := &run{facts: map[string]map[string]any{}, shared: map[any]any{}}
+	res := &Result{results: map[string]map[string]any{}}
+	for _, path := range fileOrder(p) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		f := p.File(path)
+		enabled, err := configs.enabled(path, passes, order)
+		if err != nil {
+			return nil, err
+		}
+		script, err := parser.ParseScriptContext(ctx, f.Source)
+		if err != nil {
+			return nil, err
+		}
+		c := &Context{Project: p, File: f, Script: script, run: r, results: map[string]any{}, enabled: enabled}
+		failed := map[string]bool{}
+		for _, pass := range order {
+			if dep := failedDep(pass, failed); dep != "" {
+				failed[pass.Name()] = true
+				res.Errors = append(res.Errors, &Error{Path: path, Pass: pass.Name(), Err: fmt.Errorf("required pass %s failed", dep)})
+				continue
+			}
+			c.pass = pass
+			v, err := pass.Run(c)
+			if err != nil {
+				failed[pass.Name()] = true
+				res.Errors = append(res.Errors, &Error{Path: path, Pass: pass.Name(), Err: err})
+				continue
+			}
+			c.results[pass.Name()] = v
+		}
+		script.Close()
+		res.results[path] = c.results
+	}
+	sort.SliceStable(r.diagnostics, func(i, j int) bool {
+		a, b := r.diagnostics[i], r.diagnostics[j]
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		if a.Range.StartByte != b.Range.StartByte {
+			return a.Range.StartByte < b.Range.StartByte
+		}
+		return a.Pass < b.Pass
+	})
+	res.Diagnostics = r.diagnostics
+	return res, nil
+}
+
+// schedule returns passes and the passes they require, each after those
+// it requires, or an error if passes require each other in a cycle.
+func schedule(passes []Pass) ([]Pass, error) {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+	var order []Pass
+	var visit func(p Pass, path []string) error
+	visit = func(p Pass, path []string) error {
+		switch state[p.Name()] {
+		case visiting:
+			return fmt.Errorf("analysis: passes require each other: %s -> %s", joinNames(path), p.Name())
+		case done:
+			return nil
+		}
+		state[p.Name()] = visiting
+		for _, dep := range p.Requires() {
+			if err := visit(dep, append(path, p.Name())); err != nil {
+				return err
+			}
+		}
+		state[p.Name()] = done
+		order = append(order, p)
+		return nil
+	}
+	for _, p := range passes {
+		if err := visit(p, nil); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// fileOrder returns the files of p, those assigning a global before those
+// using it where the globals do not depend on each other in a cycle, and
+// otherwise in path order.
+func fileOrder(p *project.Project) []string {
+	paths := p.Files()
+	g := depgraph.FromProject(p)
+	// Order returns the assignments it could sort even if there is a
+	// cycle; the others follow in path order.
+	names, _ := g.Order()
+	seen := map[int]bool{}
+	var out []string
+	for _, name := range names {
+		// A file assigning several globals comes where the first does.
+		if i := g.Node(name).Index; !seen[i] {
+			seen[i] = true
+			out = append(out, paths[i])
+		}
+	}
+	for i, path := range paths {
+		if !seen[i] {
+			out = append(out, path)
+		}
+	}
+	return out
+}
+
+func failedDep(p Pass, failed map[string]bool) string {
+	for _, dep := range p.Requires() {
+		if failed[dep.Name()] {
+			return dep.Name()
+		}
+	}
+	return ""
+}
+
+func requires(p Pass, name string) bool {
+	for _, dep := range p.Requires() {
+		if dep.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+func joinNames(names []string) string {
+	out := ""
+	for i, name := range names {
+		if i > 0 {
+			out += " -> "
+		}
+		out += name
+	}
+	return out
+}
@@ -0,0 +1,212 @@
+package analysis_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/analysis"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/infer"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/metrics"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func open(t *testing.T, files map[string]string) *project.Project {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := project.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+// summary lists diagnostics as file:pass:code, relative to the root of p.
+func summary(p *project.Project, ds []analysis.Diagnostic) []string {
+	var out []string
+	for _, d := range ds {
+		rel, _ := filepath.Rel(p.Root, d.Path)
+		out = append(out, filepath.ToSlash(rel)+":"+d.Pass+":"+d.Code)
+	}
+	return out
+}
+
+func TestRun(t *testing.T) {
+	p := open(t, map[string]string{
+		// The call is checked knowing sq, which a file after it defines.
+		"a.wz": "main: sq[1;2]",
+		"z.wz": "sq: {x*x}",
+		"b.wz": "sq[2] + (1",
+	})
+	res, err := analysis.Run(context.Background(), p, analysis.Passes()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := summary(p, res.Diagnostics)
+	want := []string{"a.wz:deadcode:unused", "a.wz:types:ARITY_MISMATCH", "b.wz:syntax:missing"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Diagnostics = %v, want %v", got, want)
+	}
+	if len(res.Errors) != 0 {
+		t.Errorf("Errors = %v", res.Errors)
+	}
+	if v, ok := res.Of(filepath.Join(p.Root, "z.wz"), "metrics"); !ok || len(v.([]metrics.Metrics)) != 1 {
+		t.Errorf("metrics of z.wz = %v, %v", v, ok)
+	}
+	if v, _ := res.Of(filepath.Join(p.Root, "z.wz"), "types"); v.(*infer.Info) == nil {
+		t.Errorf("types of z.wz = %v", v)
+	}
+
+	// Without the types pass listed, lint reports the errors it found as
+	// findings of its type-error rule.
+	res, err = analysis.Run(context.Background(), p, analysis.Lint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = summary(p, res.Diagnostics)
+	if want := []string{"a.wz:lint:type-error"}; !slices.Equal(got, want) {
+		t.Errorf("lint alone: Diagnostics = %v, want %v", got, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := analysis.Run(ctx, p, analysis.Syntax); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run with a canceled context = %v", err)
+	}
+}
+
+// pass is a pass made of functions, for tests.
+type pass struct {
+	name     string
+	requires []analysis.Pass
+	facts    bool
+	run      func(c *analysis.Context) (any, error)
+}
+
+func (p *pass) Name() string                         { return p.name }
+func (p *pass) Doc() string                          { return "a test pass" }
+func (p *pass) Requires() []analysis.Pass            { return p.requires }
+func (p *pass) Facts() bool                          { return p.facts }
+func (p *pass) Run(c *analysis.Context) (any, error) { return p.run(c) }
+
+func TestSchedule(t *testing.T) {
+	p := open(t, map[string]string{"a.wz": "a: 1", "b.wz": "b: a+1"})
+	var ran []string
+	size := &pass{name: "size", run: func(c *analysis.Context) (any, error) {
+		ran = append(ran, "size")
+		return len(c.File.Source), nil
+	}}
+	report := &pass{name: "report", requires: []analysis.Pass{size}, run: func(c *analysis.Context) (any, error) {
+		ran = append(ran, "report")
+		if n := c.Result(size).(int); n > 4 {
+			c.Report(analysis.Diagnostic{Code: "long", Message: "long file"})
+		}
+		return nil, nil
+	}}
+	res, err := analysis.Run(context.Background(), p, report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"size", "report", "size", "report"}; !slices.Equal(ran, want) {
+		t.Errorf("ran %v, want %v", ran, want)
+	}
+	if got := summary(p, res.Diagnostics); !slices.Equal(got, []string{"b.wz:report:long"}) {
+		t.Errorf("Diagnostics = %v", got)
+	}
+
+	// A pass failing fails the passes requiring it.
+	broken := &pass{name: "broken", run: func(*analysis.Context) (any, error) { return nil, errors.New("broken") }}
+	after := &pass{name: "after", requires: []analysis.Pass{broken}, run: func(*analysis.Context) (any, error) {
+		t.Error("after ran though broken failed")
+		return nil, nil
+	}}
+	res, err = analysis.Run(context.Background(), p, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var perr *analysis.Error
+	if len(res.Errors) != 4 || !errors.As(res.Errors[1], &perr) || perr.Pass != "after" {
+		t.Errorf("Errors = %v", res.Errors)
+	}
+
+	x := &pass{name: "x"}
+	y := &pass{name: "y", requires: []analysis.Pass{x}}
+	x.requires = []analysis.Pass{y}
+	if _, err := analysis.Run(context.Background(), p, x); err == nil || !strings.Contains(err.Error(), "x -> y -> x") {
+		t.Errorf("Run of passes requiring each other = %v", err)
+	}
+}
+
+func TestFacts(t *testing.T) {
+	// b uses a, so a is analysed first whatever the paths.
+	p := open(t, map[string]string{"b.wz": "b: a+1", "c.wz": "a: 41"})
+	var seen []any
+	facts := &pass{name: "value", facts: true, run: func(c *analysis.Context) (any, error) {
+		if v, ok := c.Fact("a"); ok {
+			seen = append(seen, v)
+		}
+		name, _, _ := strings.Cut(string(c.File.Source), ":")
+		c.ExportFact(name, len(c.File.Source))
+		return nil, nil
+	}}
+	if _, err := analysis.Run(context.Background(), p, facts); err != nil {
+		t.Fatal(err)
+	}
+	if len(seen) != 1 || seen[0] != 5 {
+		t.Errorf("facts seen = %v", seen)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	c, err := analysis.ParseConfig(strings.NewReader("# passes\ndisable:\n  - deadcode  # noisy\n  - complexity\nenable: [metrics, lint]\n"), "wabznasm.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(c.Disable, []string{"deadcode", "complexity"}) || !slices.Equal(c.Enable, []string{"metrics", "lint"}) {
+		t.Errorf("ParseConfig = %+v", c)
+	}
+	for _, bad := range []string{"- lint\n", "passes: [lint]\n", "enable: lint\n", "enable:\n  - a b\n"} {
+		_, err := analysis.ParseConfig(strings.NewReader(bad), "wabznasm.yaml")
+		if err == nil || !strings.HasPrefix(err.Error(), "wabznasm.yaml:1:") && !strings.HasPrefix(err.Error(), "wabznasm.yaml:2:") {
+			t.Errorf("ParseConfig(%q) = %v", bad, err)
+		}
+	}
+
+	p := open(t, map[string]string{
+		"wabznasm.yaml":     "disable: [deadcode]\n",
+		"f.wz":              "f: {[x;y] x}",
+		"lib/g.wz":          "g: {[x;y] x}",
+		"lib/wabznasm.yaml": "disable: [unused-parameter]\nenable: [deadcode]\n",
+	})
+	res, err := analysis.Run(context.Background(), p, analysis.Passes()...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := summary(p, res.Diagnostics)
+	if want := []string{"f.wz:lint:unused-parameter", "lib/g.wz:deadcode:unused"}; !slices.Equal(got, want) {
+		t.Errorf("Diagnostics = %v, want %v", got, want)
+	}
+
+	p = open(t, map[string]string{"wabznasm.yaml": "disable: [no-such-pass]\n", "f.wz": "1"})
+	if _, err := analysis.Run(context.Background(), p, analysis.Syntax); err == nil || !strings.Contains(err.Error(), "no-such-pass") {
+		t.Errorf("Run with an unknown name configured = %v", err)
+	}
+}
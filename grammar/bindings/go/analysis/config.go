@@ -0,0 +1,219 @@
+package analysis
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConfigFile is the name of the file configuring the passes run on the
+// sources in its directory and the directories beneath it.
+const ConfigFile = "wabznasm.yaml"
+
+// Config enables and disables passes, and the settings passes look up
+// with Context.Enabled, such as lint rules.
+type Config struct {
+	Enable  []string
+	Disable []string
+}
+
+// ParseConfig reads a Config from r, named name in errors. It accepts the
+// subset of YAML needed to list names under the keys enable and disable,
+// one item to a line or in brackets; # starts a comment:
+//
+//	# wabznasm.yaml
+//	disable:
+//	  - deadcode
+//	  - complexity
+//	enable: [metrics]
+//
+// A name both enabled and disabled is disabled.
+func ParseConfig(r io.Reader, name string) (Config, error) {
+	var c Config
+	var list *[]string
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := sc.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		text = strings.TrimRight(text, " \t")
+		if strings.TrimSpace(text) == "" || text == "---" {
+			continue
+		}
+		if item, ok := strings.CutPrefix(strings.TrimSpace(text), "-"); ok {
+			if list == nil {
+				return Config{}, fmt.Errorf("%s:%d: list item outside enable or disable", name, line)
+			}
+			if item = strings.TrimSpace(item); !validName(item) {
+				return Config{}, fmt.Errorf("%s:%d: invalid name %q", name, line, item)
+			}
+			*list = append(*list, item)
+			continue
+		}
+		key, value, ok := strings.Cut(text, ":")
+		if !ok || text[0] == ' ' {
+			return Config{}, fmt.Errorf("%s:%d: want key: value, got %q", name, line, strings.TrimSpace(text))
+		}
+		switch key = strings.TrimSpace(key); key {
+		case "enable":
+			list = &c.Enable
+		case "disable":
+			list = &c.Disable
+		default:
+			return Config{}, fmt.Errorf("%s:%d: unknown setting %q", name, line, key)
+		}
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+			return Config{}, fmt.Errorf("%s:%d: %s: want a list, got %q", name, line, key, value)
+		}
+		inner := value[1 : len(value)-1]
+		if strings.TrimSpace(inner) == "" {
+			continue
+		}
+		for _, item := range strings.Split(inner, ",") {
+			item = strings.TrimSpace(item)
+			if !validName(item) {
+				return Config{}, fmt.Errorf("%s:%d: %s: invalid name %q", name, line, key, item)
+			}
+			*list = append(*list, item)
+		}
+	}
+	return c, sc.Err()
+}
+
+// validName reports whether s can name a pass or setting: letters,
+// digits, - and _.
+func validName(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// LoadConfig reads the Config file at path.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(bytes.NewReader(data), path)
+}
+
+// configs finds and caches the Config files of a project.
+type configs struct {
+	root    string
+	known   map[string]bool
+	byDir   map[string]*Config
+	loadErr map[string]error
+}
+
+func newConfigs(root string, known map[string]bool) *configs {
+	return &configs{root: root, known: known, byDir: map[string]*Config{}, loadErr: map[string]error{}}
+}
+
+// load returns the Config file of dir, or nil if it has none.
+func (cs *configs) load(dir string) (*Config, error) {
+	if c, ok := cs.byDir[dir]; ok {
+		return c, cs.loadErr[dir]
+	}
+	path := filepath.Join(dir, ConfigFile)
+	c, err := LoadConfig(path)
+	var out *Config
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		err = nil
+	case err == nil:
+		out = &c
+		for _, name := range append(append([]string(nil), c.Enable...), c.Disable...) {
+			if !cs.known[name] {
+				err = fmt.Errorf("%s: unknown pass or setting %q", path, name)
+				break
+			}
+		}
+	}
+	cs.byDir[dir], cs.loadErr[dir] = out, err
+	return out, err
+}
+
+// enabled returns the names enabled for the file at path: the passes
+// listed and the settings of every pass scheduled, as the Config files
+// from the root down to the directory of path change them, the deeper
+// files overriding the shallower.
+func (cs *configs) enabled(path string, listed, scheduled []Pass) (map[string]bool, error) {
+	on := map[string]bool{}
+	for _, p := range listed {
+		on[p.Name()] = true
+	}
+	for _, p := range scheduled {
+		for _, s := range settings(p) {
+			on[s] = true
+		}
+	}
+	dirs := []string{cs.root}
+	if rel, err := filepath.Rel(cs.root, filepath.Dir(path)); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+		dir := cs.root
+		for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+			dir = filepath.Join(dir, elem)
+			dirs = append(dirs, dir)
+		}
+	}
+	for _, dir := range dirs {
+		c, err := cs.load(dir)
+		if err != nil {
+			return nil, err
+		}
+		if c == nil {
+			continue
+		}
+		for _, name := range c.Enable {
+			on[name] = true
+		}
+		for _, name := range c.Disable {
+			on[name] = false
+		}
+	}
+	return on, nil
+}
+
+// Settings is implemented by passes having settings of their own that a
+// Config can enable and disable, such as the rules of the lint pass.
+type Settings interface {
+	// Settings lists the names of the settings, which are enabled by
+	// default.
+	Settings() []string
+}
+
+func settings(p Pass) []string {
+	if s, ok := p.(Settings); ok {
+		return s.Settings()
+	}
+	return nil
+}
+
+// knownNames returns the names a Config may use with passes: those of
+// the registered passes, of the passes scheduled, and of their settings.
+func knownNames(scheduled []Pass) map[string]bool {
+	known := map[string]bool{}
+	for _, p := range append(Passes(), scheduled...) {
+		known[p.Name()] = true
+		for _, s := range settings(p) {
+			known[s] = true
+		}
+	}
+	return known
+}
@@ -0,0 +1,237 @@
+package analysis
+
+import (
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/deadcode"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/infer"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/metrics"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// The passes shipped with the package.
+var (
+	// Syntax reports the syntax errors of a file. Its result is the
+	// []tree_sitter_wabznasm.Diagnostic of the file.
+	Syntax Pass = syntaxPass{}
+	// Types infers the types of a file, knowing the types Types inferred
+	// for the globals of the files the file uses, and reports its type
+	// errors. Its result is an *infer.Info; its facts are the infer.Type
+	// of each global.
+	Types Pass = typesPass{}
+	// Lint runs the lint rules its configuration enables, each a setting
+	// of the pass. Its result is the []lint.Finding of the file.
+	Lint Pass = lintPass{}
+	// Metrics measures the statements of a file and reports nothing. Its
+	// result is the []metrics.Metrics of the file.
+	Metrics Pass = metricsPass{}
+	// Deadcode reports the globals a file defines that nothing in the
+	// project uses. Its result is the []deadcode.Dead of the file.
+	Deadcode Pass = deadcodePass{}
+)
+
+func init() {
+	Register(Syntax)
+	Register(Types)
+	Register(Lint)
+	Register(Metrics)
+	Register(Deadcode)
+}
+
+type syntaxPass struct{}
+
+func (syntaxPass) Name() string     { return "syntax" }
+func (syntaxPass) Doc() string      { return "reports syntax errors" }
+func (syntaxPass) Requires() []Pass { return nil }
+func (syntaxPass) Facts() bool      { return false }
+
+// Run reports the diagnostics the project computed when it parsed the
+// file, rather than walking the tree again.
+func (syntaxPass) Run(c *Context) (any, error) {
+	for _, d := range c.File.Diagnostics {
+		c.Report(Diagnostic{Code: d.Code, Range: d.Range, Severity: d.Severity, Message: d.Message, Fixes: d.Fixes})
+	}
+	return c.File.Diagnostics, nil
+}
+
+type typesPass struct{}
+
+func (typesPass) Name() string     { return "types" }
+func (typesPass) Doc() string      { return "reports calls and operations that certainly fail" }
+func (typesPass) Requires() []Pass { return nil }
+func (typesPass) Facts() bool      { return true }
+
+// Run checks the statements of the file's symbol table in turn, in one
+// environment of the builtins and the facts about the free names of the
+// file.
+func (typesPass) Run(c *Context) (any, error) {
+	tab := c.File.Symbols
+	env := infer.NewEnv(infer.BuiltinEnv())
+	for _, sym := range tab.Globals() {
+		if sym.Kind != scopes.Free {
+			continue
+		}
+		if t, ok := c.Fact(sym.Name); ok {
+			env.Define(sym.Name, t.(infer.Type))
+		}
+	}
+	info := &infer.Info{Types: map[ast.Expr]infer.Type{}}
+	for _, f := range tab.Files {
+		got := infer.Check(f, env)
+		for e, t := range got.Types {
+			info.Types[e] = t
+		}
+		info.Errors = append(info.Errors, got.Errors...)
+		if a, ok := f.Stmt.(*ast.Assignment); ok && a.Name != nil {
+			t, _ := env.Lookup(a.Name.Name)
+			c.ExportFact(a.Name.Name, t)
+		}
+	}
+	for _, e := range info.Errors {
+		c.Report(Diagnostic{Code: e.Code, Range: spanRange(e.Span), Severity: tree_sitter_wabznasm.SeverityError, Message: e.Message})
+	}
+	return info, nil
+}
+
+type lintPass struct{}
+
+// typeErrorRule is the name of the lint rule that the types pass stands
+// in for.
+const typeErrorRule = "type-error"
+
+func (lintPass) Name() string     { return "lint" }
+func (lintPass) Doc() string      { return "reports the findings of the enabled lint rules" }
+func (lintPass) Requires() []Pass { return []Pass{Types} }
+func (lintPass) Facts() bool      { return false }
+
+func (lintPass) Settings() []string {
+	var names []string
+	for _, r := range lint.Rules() {
+		names = append(names, r.Name())
+	}
+	return names
+}
+
+// Run runs the enabled rules with lint.Run, so that suppression comments
+// apply. The type-error rule reports the errors the types pass found,
+// with the types of other files known, instead of checking the file
+// again; it is left out when the types pass reports them itself.
+func (lintPass) Run(c *Context) (any, error) {
+	var rules []lint.Rule
+	for _, r := range lint.Rules() {
+		if !c.Enabled(r.Name()) {
+			continue
+		}
+		if r.Name() == typeErrorRule {
+			if c.Enabled(Types.Name()) {
+				continue
+			}
+			r = inferred{c.Result(Types).(*infer.Info)}
+		}
+		rules = append(rules, r)
+	}
+	if len(rules) == 0 {
+		// lint.Run would run every rule.
+		return []lint.Finding(nil), nil
+	}
+	findings := lint.RunScript(c.Script, rules...)
+	for _, f := range findings {
+		c.Report(Diagnostic{Code: f.Rule, Range: f.Range, Severity: f.Severity, Message: f.Message, Fixes: f.Fixes})
+	}
+	return findings, nil
+}
+
+// inferred is the type-error rule finding the errors of a result of the
+// types pass.
+type inferred struct{ info *infer.Info }
+
+func (inferred) Name() string { return typeErrorRule }
+func (inferred) Doc() string  { return "reports the type errors the types pass found" }
+
+// Check reports the errors within the statement of tree, the result
+// holding those of every statement of the file.
+func (r inferred) Check(tree *tree_sitter.Tree, _ []byte) []lint.Finding {
+	root := tree.RootNode()
+	var out []lint.Finding
+	for _, e := range r.info.Errors {
+		if e.Span.Start.Offset < root.StartByte() || e.Span.Start.Offset >= root.EndByte() {
+			continue
+		}
+		out = append(out, lint.Finding{Rule: typeErrorRule, Range: spanRange(e.Span), Severity: tree_sitter_wabznasm.SeverityError, Message: e.Message})
+	}
+	return out
+}
+
+type metricsPass struct{}
+
+func (metricsPass) Name() string     { return "metrics" }
+func (metricsPass) Doc() string      { return "measures the complexity of each statement" }
+func (metricsPass) Requires() []Pass { return nil }
+func (metricsPass) Facts() bool      { return false }
+
+func (metricsPass) Run(c *Context) (any, error) {
+	var out []metrics.Metrics
+	for _, st := range c.Script.Statements {
+		out = append(out, metrics.Analyze(st.Tree.Tree, st.Source)...)
+	}
+	return out, nil
+}
+
+type deadcodePass struct{}
+
+func (deadcodePass) Name() string     { return "deadcode" }
+func (deadcodePass) Doc() string      { return "reports globals nothing uses" }
+func (deadcodePass) Requires() []Pass { return nil }
+func (deadcodePass) Facts() bool      { return false }
+
+// deadKey is the key of the dead globals of the project in Context.Shared.
+type deadKey struct{}
+
+// Run finds the dead globals of the whole project once, for the first
+// file, and reports those of each file in turn.
+func (deadcodePass) Run(c *Context) (any, error) {
+	all := c.Shared(deadKey{}, func() any { return deadcode.Find(c.Project, deadcode.Options{}) }).([]deadcode.Dead)
+	var out []deadcode.Dead
+	for _, d := range all {
+		if d.Path != c.File.Path {
+			continue
+		}
+		out = append(out, d)
+		what := "value"
+		if d.Func {
+			what = "function"
+		}
+		msg := "is never used"
+		if len(d.Peers) > 0 {
+			msg = "is used only by " + strings.Join(d.Peers, ", ")
+		}
+		c.Report(Diagnostic{
+			Code:     "unused",
+			Range:    spanRange(d.Span),
+			Severity: tree_sitter_wabznasm.SeverityWarning,
+			Message:  what + " " + d.Name + " " + msg,
+			Fixes:    []tree_sitter_wabznasm.Fix{{Title: "delete " + d.Name, Edits: []tree_sitter_wabznasm.TextEdit{editOf(d.Delete)}}},
+		})
+	}
+	return out, nil
+}
+
+func spanRange(s ast.Span) tree_sitter.Range {
+	return tree_sitter.Range{
+		StartByte:  s.Start.Offset,
+		EndByte:    s.End.Offset,
+		StartPoint: tree_sitter.Point{Row: s.Start.Row, Column: s.Start.Column},
+		EndPoint:   tree_sitter.Point{Row: s.End.Row, Column: s.End.Column},
+	}
+}
+
+func editOf(e ast.Edit) tree_sitter_wabznasm.TextEdit {
+	return tree_sitter_wabznasm.TextEdit{
+		Range:   spanRange(ast.Span{Start: e.Start, End: e.End}),
+		NewText: e.NewText,
+	}
+}
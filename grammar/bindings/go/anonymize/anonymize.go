@@ -0,0 +1,214 @@
+// Package anonymize rewrites wabznasm source so that it can be shared
+// without revealing what it computes: every user identifier is renamed
+// and every comment is dropped, while builtins, the implicit parameters
+// x, y and z of functions without a parameter list, numbers, operators and
+// layout are kept. A statement that
+// parses still parses to the same tree up to the names, so a program that
+// trips a bug in the parser or the interpreter still trips it.
+//
+// Names are renamed consistently: the same name always becomes the same
+// new name, also across the sources given to one Anonymizer, so that the
+// files of a project still refer to each other. New names say what the
+// name was where it first appeared: g1, g2, ... for globals, p1, ... for
+// parameters and f1, ... for names defined elsewhere. Only the uses of
+// implicit parameters keep their names; a global or parameter called x is
+// renamed like any other. A name met only in
+// text that does not parse becomes v1, and so on.
+//
+// Sources are taken as scripts, one statement per line, as the REPL and
+// the formatter take them. Text the parser cannot make sense of is
+// anonymized too, word by word, so that a syntax error does not leak the
+// names around it.
+package anonymize
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/asthash"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/script"
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// ErrNotPreserved is returned if an anonymized statement would parse to a
+// different tree. It indicates a bug in the anonymizer.
+var ErrNotPreserved = errors.New("anonymize: anonymized source parses differently")
+
+var word = regexp.MustCompile(`[a-zA-Z_][a-zA-Z0-9_]*`)
+
+// Options configure an Anonymizer.
+type Options struct {
+	// Keep lists names to leave as they are besides the builtins, such as
+	// the builtins a host registers that the program calls.
+	Keep []string
+}
+
+// Anonymizer renames the identifiers of sources. Its methods are not safe
+// for concurrent use.
+type Anonymizer struct {
+	keep  map[string]bool
+	names map[string]string
+	next  map[string]int
+}
+
+// New returns an Anonymizer that keeps the names of the builtins of a REPL
+// session, those registered with the interpreter and opts.Keep.
+func New(opts Options) *Anonymizer {
+	a := &Anonymizer{keep: map[string]bool{}, names: map[string]string{}, next: map[string]int{}}
+	for _, b := range append(repl.Builtins(), eval.Builtins()...) {
+		a.keep[b.Name] = true
+	}
+	for _, name := range opts.Keep {
+		a.keep[name] = true
+	}
+	return a
+}
+
+// Source anonymizes src with a new Anonymizer.
+func Source(src []byte) ([]byte, error) {
+	return New(Options{}).Source(src)
+}
+
+// Names returns the new name of each name renamed so far. It is the key to
+// reading answers about the anonymized source, and must be kept as
+// private as the source itself.
+func (a *Anonymizer) Names() map[string]string {
+	out := make(map[string]string, len(a.names))
+	for k, v := range a.names {
+		out[k] = v
+	}
+	return out
+}
+
+// rename returns the new name of name, choosing one with prefix if it has
+// none yet.
+func (a *Anonymizer) rename(name, prefix string) string {
+	if a.keep[name] {
+		return name
+	}
+	if n, ok := a.names[name]; ok {
+		return n
+	}
+	for {
+		a.next[prefix]++
+		n := prefix + strconv.Itoa(a.next[prefix])
+		if !a.keep[n] {
+			a.names[name] = n
+			return n
+		}
+	}
+}
+
+// words renames every word of text.
+func (a *Anonymizer) words(text string) string {
+	return word.ReplaceAllStringFunc(text, func(w string) string { return a.rename(w, "v") })
+}
+
+var prefixes = map[scopes.Kind]string{
+	scopes.Global: "g",
+	scopes.Param:  "p",
+	scopes.Free:   "f",
+}
+
+// Source anonymizes src, which may continue a script given to an earlier
+// call.
+func (a *Anonymizer) Source(src []byte) ([]byte, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+
+	var out strings.Builder
+	code := string(src)
+	off := 0
+	for _, e := range script.Split(code) {
+		out.WriteString(dropComments(code[off:e.Offset]))
+		stmt, err := a.statement(parser, e.Text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", strings.Count(code[:e.Offset], "\n")+1, err)
+		}
+		out.WriteString(stmt)
+		off = e.Offset + len(e.Text)
+	}
+	out.WriteString(dropComments(code[off:]))
+	return []byte(out.String()), nil
+}
+
+// statement anonymizes one statement and checks that it still parses to
+// the same tree. A statement with syntax errors is not checked: dropping
+// its comments can change how the parser recovers from them.
+func (a *Anonymizer) statement(parser *tree_sitter_wabznasm.Parser, text string) (string, error) {
+	tree, err := parser.ParseString(text)
+	if err != nil {
+		return "", err
+	}
+	defer tree.Close()
+	src := []byte(text)
+	// The uses of implicit parameters, by offset, which keep their names
+	// where the same name elsewhere is renamed.
+	implicit := map[uint]bool{}
+	for _, sym := range scopes.FromTree(tree.Tree, src).Symbols {
+		if prefix, ok := prefixes[sym.Kind]; ok {
+			a.rename(sym.Name, prefix)
+		}
+		if sym.Kind == scopes.Implicit {
+			for _, ref := range sym.Refs {
+				implicit[ref.Start.Offset] = true
+			}
+		}
+	}
+	leaf := func(start uint, text string) string {
+		if implicit[start] {
+			return text
+		}
+		return a.words(text)
+	}
+
+	var out strings.Builder
+	for _, tok := range tree_sitter_wabznasm.Tokens(tree.Tree, src) {
+		switch {
+		case tok.IsComment():
+			s := strings.TrimRight(out.String(), " \t")
+			out.Reset()
+			out.WriteString(s)
+		case tok.IsWhitespace():
+			out.WriteString(tok.Text)
+		default:
+			out.WriteString(leaf(tok.Range.StartByte, tok.Text))
+		}
+	}
+
+	if tree.RootNode().HasError() {
+		return out.String(), nil
+	}
+	again, err := parser.ParseString(out.String())
+	if err != nil {
+		return "", err
+	}
+	defer again.Close()
+	want := asthash.HashFunc(tree.RootNode(), src, func(n *tree_sitter.Node, text string) string { return leaf(n.StartByte(), text) })
+	if asthash.Hash(again.RootNode(), []byte(out.String())) != want {
+		return "", fmt.Errorf("%w: %q", ErrNotPreserved, out.String())
+	}
+	return out.String(), nil
+}
+
+// dropComments removes the comment lines of text between statements,
+// which holds only those and blank lines.
+func dropComments(text string) string {
+	var out strings.Builder
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if !strings.HasPrefix(strings.TrimSpace(line), `\`) {
+			out.WriteString(line)
+		}
+	}
+	return out.String()
+}
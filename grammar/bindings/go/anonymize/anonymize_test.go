@@ -0,0 +1,59 @@
+package anonymize_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/anonymize"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func TestSource(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"revenue: {[price;qty] price*qty} \\ secret formula\n", "g1: {[p1;p2] p1*p2}\n"},
+		{"\\ header\nrate: 3\n\ntotal: rate*amount+sum count 4\n", "g1: 3\n\ng2: g1*f1+sum count 4\n"},
+		{"f: {x+y} \\ implicit\nf[1;2]", "g1: {x+y}\ng1[1;2]"},
+		// Names in text that does not parse are renamed too.
+		{"bad: {[secret] secret+}\n", "g1: {[p1] p1+}\n"},
+		{"1 + hidden )\n", "1 + f1 )\n"},
+		// Dropping the comment changes how the parser recovers.
+		{"[\\\nf\n;z]", "[\nf1\n;v1]"},
+		// Only implicit parameters keep the names x, y and z.
+		{"y:   1+  2*3\n", "g1:   1+  2*3\n"},
+		{"x: 2\nf: {x*y}\ng: {[a] a+x}\ng[x]\n", "g1: 2\ng2: {x*y}\ng3: {[p1] p1+g1}\ng3[g1]\n"},
+		{"f: {[y] y+z}\n", "g1: {[p1] p1+f1}\n"},
+	}
+	for _, tt := range tests {
+		got, err := anonymize.Source([]byte(tt.src))
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Source(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestConsistentAcrossSources(t *testing.T) {
+	a := anonymize.New(anonymize.Options{Keep: []string{"hostfn"}})
+	first, err := a.Source([]byte("helper: {[v] v*2}\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := a.Source([]byte("main: helper hostfn 3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(first) != "g1: {[p1] p1*2}\n" || string(second) != "g2: g1 hostfn 3\n" {
+		t.Errorf("got %q and %q", first, second)
+	}
+	names := a.Names()
+	if len(names) != 3 || names["helper"] != "g1" || names["v"] != "p1" || names["main"] != "g2" {
+		t.Errorf("Names() = %v", names)
+	}
+}
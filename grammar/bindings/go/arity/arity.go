@@ -0,0 +1,139 @@
+// Package arity checks every call in a project against the function it
+// calls, so that a call with the wrong number of arguments is found before
+// it fails at run time.
+//
+// What a function takes is easy to misjudge when its parameters are
+// implicit: {x+y} takes two arguments because its body uses y, and {x*z}
+// takes three. A mismatch is reported with both the call site and the
+// definition, and, for an implicit function, the parameters its body uses.
+//
+// Only calls of globals assigned a function literal are checked. A global
+// assigned functions of different arities in different files is skipped,
+// since which one a call reaches depends on the order the files run in.
+package arity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/definition"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// Mismatch is a call with the wrong number of arguments.
+type Mismatch struct {
+	Name string
+	// Call spans the call expression, and Def the name of the function's
+	// definition.
+	Call definition.Location
+	Def  definition.Location
+	// Params are the parameters the function takes. Implicit is set when
+	// they are implicit parameters its body uses.
+	Params   []string
+	Implicit bool
+	// Args is the number of arguments of the call.
+	Args int
+}
+
+// Message describes the mismatch, as in "f is called with 1 argument but
+// takes 2: its body uses x and y".
+func (m Mismatch) Message() string {
+	msg := fmt.Sprintf("%s is called with %s but takes %d", m.Name, count(m.Args), len(m.Params))
+	switch {
+	case m.Implicit && len(m.Params) == 1:
+		return msg + ": its body uses only x"
+	case m.Implicit:
+		return msg + ": its body uses " + list(m.Params)
+	case len(m.Params) > 0:
+		return msg + ": [" + strings.Join(m.Params, ";") + "]"
+	}
+	return msg
+}
+
+func count(n int) string {
+	if n == 1 {
+		return "1 argument"
+	}
+	return fmt.Sprintf("%d arguments", n)
+}
+
+func list(names []string) string {
+	if len(names) < 2 {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
+
+// Check returns the mismatched calls of p, ordered by path and position.
+func Check(p *project.Project) []Mismatch {
+	type def struct {
+		loc    definition.Location
+		fn     *ast.FunctionDef
+		usable bool
+	}
+	defs := map[string]*def{}
+	lookup := func(name string) *def {
+		if d, ok := defs[name]; ok {
+			return d
+		}
+		d := &def{}
+		defs[name] = d
+		for _, loc := range p.Definitions(name) {
+			f := p.File(loc.File)
+			if f == nil {
+				continue
+			}
+			fn := f.Symbols.Global.Symbols[name].Func
+			switch {
+			case fn == nil:
+				// Assigned something other than a function literal.
+				d.usable = false
+				return d
+			case d.fn == nil:
+				*d = def{loc: loc, fn: fn, usable: true}
+			case len(fn.Signature()) != len(d.fn.Signature()):
+				d.usable = false
+				return d
+			}
+		}
+		return d
+	}
+
+	var out []Mismatch
+	for _, path := range p.Files() {
+		f := p.File(path)
+		if f == nil {
+			continue
+		}
+		for _, stmt := range f.Symbols.Stmts() {
+			ast.Inspect(stmt, func(n ast.Node) bool {
+				call, ok := n.(*ast.Call)
+				if !ok || call.Func == nil {
+					return true
+				}
+				sym := f.Symbols.Resolve(call.Func)
+				if sym == nil || sym.Kind != scopes.Global && sym.Kind != scopes.Free {
+					return true
+				}
+				d := lookup(call.Func.Name)
+				if !d.usable {
+					return true
+				}
+				if params := d.fn.Signature(); len(params) != len(call.Args) {
+					out = append(out, Mismatch{
+						Name:     call.Func.Name,
+						Call:     definition.Location{File: path, Span: ast.Span{Start: call.Pos(), End: call.EndPos()}},
+						Def:      d.loc,
+						Params:   params,
+						Implicit: d.fn.Implicit(),
+						Args:     len(call.Args),
+					})
+				}
+				return true
+			})
+		}
+	}
+	return out
+}
@@ -0,0 +1,52 @@
+package arity_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/arity"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"add.wz":   "add: {x+y}",
+		"cube.wz":  "cube: {x*x*x}",
+		"vol.wz":   "vol: {x*z}",
+		"norm.wz":  "norm: {[a;b] a*a+b*b}",
+		"twice.wz": "twice: {[g;v] g[g[v]]}",
+		"calls.wz": "add[1] + cube[2;3] + vol[1;2] + norm[3] + twice[cube;2] + add[1;2] + max[1]",
+		"loop.wz":  "loop: {[n] loop[n-1;0]}",
+		"a.wz":     "dup: {x}",
+		"b.wz":     "dup: {x+y}",
+		"dup.wz":   "dup[1]",
+	}
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := project.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range arity.Check(p) {
+		def, call := filepath.Base(m.Def.File), filepath.Base(m.Call.File)
+		got = append(got, call+":"+strconv.Itoa(int(m.Call.Span.Start.Column))+" "+def+" "+m.Message())
+	}
+	want := []string{
+		"calls.wz:0 add.wz add is called with 1 argument but takes 2: its body uses x and y",
+		"calls.wz:9 cube.wz cube is called with 2 arguments but takes 1: its body uses only x",
+		"calls.wz:21 vol.wz vol is called with 2 arguments but takes 3: its body uses x, y and z",
+		"calls.wz:32 norm.wz norm is called with 1 argument but takes 2: [a;b]",
+		"loop.wz:11 loop.wz loop is called with 2 arguments but takes 1: [n]",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Check:\n%q\nwant:\n%q", got, want)
+	}
+}
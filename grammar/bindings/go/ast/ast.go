@@ -0,0 +1,215 @@
+// Package ast provides a typed view of wabznasm parse trees.
+//
+// The concrete syntax tree produced by tree-sitter encodes operator
+// precedence as a ladder of wrapper nodes (expression, additive,
+// multiplicative, unary, power, postfix, primary). This package collapses
+// that ladder into a small set of Go structs so tools can switch on node
+// types instead of comparing kind strings.
+//
+// Walk and Inspect traverse a typed tree. Rewrite and Apply turn
+// replacements of typed nodes into edits of the original source, leaving
+// every other byte as it was.
+package ast
+
+// Pos is a location in the source text. Row and Column are zero-based and
+// Column counts bytes, matching tree-sitter points.
+type Pos struct {
+	Offset uint
+	Row    uint
+	Column uint
+}
+
+// Span is the half-open source range [Start, End) covered by a node.
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+// Pos returns the start of the span.
+func (s Span) Pos() Pos { return s.Start }
+
+// EndPos returns the end of the span.
+func (s Span) EndPos() Pos { return s.End }
+
+// Node is implemented by every node in the typed tree.
+type Node interface {
+	Pos() Pos
+	EndPos() Pos
+}
+
+// Stmt is a top-level statement.
+type Stmt interface {
+	Node
+	stmtNode()
+}
+
+// Expr is an expression.
+type Expr interface {
+	Node
+	exprNode()
+}
+
+// File is the root of a parsed source.
+type File struct {
+	Span
+	// Stmt is the single statement of the source, or nil if the source is
+	// empty or contains only comments.
+	Stmt     Stmt
+	Comments []*Comment
+}
+
+// Comment is a backslash comment running to the end of the line.
+type Comment struct {
+	Span
+	Text string
+}
+
+// Assignment binds Name to Value, as in `x: 42` or `f: {x+1}`.
+type Assignment struct {
+	Span
+	Name  *Ident
+	Value Expr
+}
+
+// ExprStmt is an expression used as a statement.
+type ExprStmt struct {
+	Span
+	X Expr
+}
+
+// BadStmt stands in for a statement that could not be parsed.
+type BadStmt struct {
+	Span
+}
+
+// FunctionDef is a function literal, `{body}` or `{[params] body}`.
+type FunctionDef struct {
+	Span
+	// Params is nil when the function declares no parameter list.
+	Params *ParamList
+	Body   Expr
+}
+
+// ParamList is an explicit parameter list such as `[x;y]`.
+type ParamList struct {
+	Span
+	Names []*Ident
+}
+
+// BinaryExpr is an infix operation. Op is one of + - * / % ^.
+type BinaryExpr struct {
+	Span
+	Op    string
+	OpPos Pos
+	Left  Expr
+	Right Expr
+}
+
+// UnaryExpr is a prefix operation. Op is always "-".
+type UnaryExpr struct {
+	Span
+	Op      string
+	Operand Expr
+}
+
+// PostfixExpr is a suffix operation. Op is always "!".
+type PostfixExpr struct {
+	Span
+	Op      string
+	Operand Expr
+}
+
+// ParenExpr is a parenthesized expression.
+type ParenExpr struct {
+	Span
+	X Expr
+}
+
+// Call applies a named function to arguments, as in `f[1;2]`.
+type Call struct {
+	Span
+	Func *Ident
+	Args []Expr
+}
+
+// Ident is a variable or function name.
+type Ident struct {
+	Span
+	Name string
+}
+
+// NumberLiteral is an integer literal. Value is zero and Err is set when
+// Text does not fit in an int64.
+type NumberLiteral struct {
+	Span
+	Text  string
+	Value int64
+	Err   error
+}
+
+// BadExpr stands in for an expression that could not be parsed, including
+// tokens the parser reported as missing.
+type BadExpr struct {
+	Span
+	Missing bool
+}
+
+func (*Assignment) stmtNode() {}
+func (*ExprStmt) stmtNode()   {}
+func (*BadStmt) stmtNode()    {}
+
+func (*FunctionDef) exprNode()   {}
+func (*BinaryExpr) exprNode()    {}
+func (*UnaryExpr) exprNode()     {}
+func (*PostfixExpr) exprNode()   {}
+func (*ParenExpr) exprNode()     {}
+func (*Call) exprNode()          {}
+func (*Ident) exprNode()         {}
+func (*NumberLiteral) exprNode() {}
+func (*BadExpr) exprNode()       {}
+
+// Implicit reports whether the function relies on the implicit x, y and z
+// parameters rather than an explicit parameter list.
+func (f *FunctionDef) Implicit() bool { return f.Params == nil }
+
+// ParamNames returns the names of the explicit parameters.
+func (f *FunctionDef) ParamNames() []string {
+	if f.Params == nil {
+		return nil
+	}
+	names := make([]string, len(f.Params.Names))
+	for i, id := range f.Params.Names {
+		names[i] = id.Name
+	}
+	return names
+}
+
+// Signature returns the parameters the function takes: the explicit
+// parameter names, or for an implicit function x, x;y or x;y;z depending on
+// the highest of them its body mentions.
+func (f *FunctionDef) Signature() []string {
+	if f.Params != nil {
+		return f.ParamNames()
+	}
+	arity := 0
+	Inspect(f.Body, func(n Node) bool {
+		if id, ok := n.(*Ident); ok {
+			switch id.Name {
+			case "x":
+				arity = max(arity, 1)
+			case "y":
+				arity = max(arity, 2)
+			case "z":
+				arity = max(arity, 3)
+			}
+		}
+		return true
+	})
+	return []string{"x", "y", "z"}[:arity]
+}
+
+// IsFunction reports whether the assignment defines a function.
+func (a *Assignment) IsFunction() bool {
+	_, ok := a.Value.(*FunctionDef)
+	return ok
+}
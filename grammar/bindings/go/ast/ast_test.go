@@ -0,0 +1,206 @@
+package ast_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func parse(t *testing.T, src string) *ast.File {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	return ast.FromTree(tree.Tree, []byte(src))
+}
+
+func TestFunctionAssignment(t *testing.T) {
+	f := parse(t, "add: {[x;y] x+y}")
+	a, ok := f.Stmt.(*ast.Assignment)
+	if !ok {
+		t.Fatalf("got %T, want *ast.Assignment", f.Stmt)
+	}
+	if a.Name.Name != "add" || !a.IsFunction() {
+		t.Fatalf("unexpected assignment %+v", a)
+	}
+	fn := a.Value.(*ast.FunctionDef)
+	if got := fn.ParamNames(); len(got) != 2 || got[0] != "x" || got[1] != "y" {
+		t.Errorf("params = %v", got)
+	}
+	body, ok := fn.Body.(*ast.BinaryExpr)
+	if !ok || body.Op != "+" {
+		t.Fatalf("body = %#v", fn.Body)
+	}
+	if body.OpPos.Offset != 13 {
+		t.Errorf("operator offset = %d, want 13", body.OpPos.Offset)
+	}
+}
+
+func TestImplicitParams(t *testing.T) {
+	f := parse(t, "f: {x*2}")
+	fn := f.Stmt.(*ast.Assignment).Value.(*ast.FunctionDef)
+	if !fn.Implicit() || fn.ParamNames() != nil {
+		t.Errorf("expected implicit parameters, got %v", fn.ParamNames())
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	f := parse(t, "1+2*-3^2!")
+	add := f.Stmt.(*ast.ExprStmt).X.(*ast.BinaryExpr)
+	if add.Op != "+" {
+		t.Fatalf("root op = %q", add.Op)
+	}
+	mul := add.Right.(*ast.BinaryExpr)
+	if mul.Op != "*" {
+		t.Fatalf("right op = %q", mul.Op)
+	}
+	neg := mul.Right.(*ast.UnaryExpr)
+	pow := neg.Operand.(*ast.BinaryExpr)
+	if pow.Op != "^" {
+		t.Fatalf("power op = %q", pow.Op)
+	}
+	if _, ok := pow.Right.(*ast.PostfixExpr); !ok {
+		t.Errorf("exponent = %T, want *ast.PostfixExpr", pow.Right)
+	}
+}
+
+func TestCallAndParens(t *testing.T) {
+	f := parse(t, "f[(1+2);y] \\ trailing")
+	call := f.Stmt.(*ast.ExprStmt).X.(*ast.Call)
+	if call.Func.Name != "f" || len(call.Args) != 2 {
+		t.Fatalf("call = %+v", call)
+	}
+	if _, ok := call.Args[0].(*ast.ParenExpr); !ok {
+		t.Errorf("arg 0 = %T", call.Args[0])
+	}
+	if id := call.Args[1].(*ast.Ident); id.Name != "y" || id.Pos().Column != 8 {
+		t.Errorf("arg 1 = %+v", id)
+	}
+	if len(f.Comments) != 1 || f.Comments[0].Text != `\ trailing` {
+		t.Errorf("comments = %+v", f.Comments)
+	}
+}
+
+func TestNumberLiteral(t *testing.T) {
+	lit := parse(t, "42").Stmt.(*ast.ExprStmt).X.(*ast.NumberLiteral)
+	if lit.Value != 42 || lit.Err != nil {
+		t.Errorf("lit = %+v", lit)
+	}
+	big := parse(t, "99999999999999999999").Stmt.(*ast.ExprStmt).X.(*ast.NumberLiteral)
+	if big.Err == nil {
+		t.Errorf("expected overflow error for %s", big.Text)
+	}
+}
+
+func TestErrorsBecomeBadNodes(t *testing.T) {
+	f := parse(t, "1+")
+	stmt, ok := f.Stmt.(*ast.ExprStmt)
+	if !ok {
+		t.Fatalf("got %T", f.Stmt)
+	}
+	bin := stmt.X.(*ast.BinaryExpr)
+	if bad, ok := bin.Right.(*ast.BadExpr); !ok || !bad.Missing {
+		t.Errorf("right = %#v, want missing BadExpr", bin.Right)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	f := parse(t, "f: {[a;b] g[a;-b]!}")
+	var kinds []string
+	depth, maxDepth := 0, 0
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			depth--
+			return false
+		}
+		depth++
+		maxDepth = max(maxDepth, depth)
+		switch n := n.(type) {
+		case *ast.Ident:
+			kinds = append(kinds, n.Name)
+		case *ast.Call:
+			kinds = append(kinds, "call")
+		}
+		return true
+	})
+	if got := strings.Join(kinds, " "); got != "f a b call g a b" {
+		t.Errorf("visit order = %q", got)
+	}
+	// File, Assignment, FunctionDef, PostfixExpr, Call, UnaryExpr, Ident.
+	if depth != 0 || maxDepth != 7 {
+		t.Errorf("depth = %d, max depth = %d", depth, maxDepth)
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	src := "\\ keep me\ntotal: old[1;  2]+old[old[3]] \\ and me"
+	// Rename calls to old, keeping the argument text of nested calls as is.
+	out, err := ast.Rewrite([]byte(src), func(n ast.Node, source []byte) (string, bool) {
+		if c, ok := n.(*ast.Call); ok && c.Func.Name == "old" {
+			return "new" + ast.Text(c, source)[len("old"):], true
+		}
+		return "", false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\\ keep me\ntotal: new[1;  2]+new[old[3]] \\ and me"; string(out) != want {
+		t.Errorf("Rewrite = %q, want %q", out, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	at := func(off uint) ast.Pos { return ast.Pos{Offset: off} }
+	src := []byte("a+b")
+	out, err := ast.Apply(src, []ast.Edit{
+		{Start: at(2), End: at(3), NewText: "c"},
+		{Start: at(0), End: at(0), NewText: "("},
+		{Start: at(0), End: at(0), NewText: "("},
+		{Start: at(3), End: at(3), NewText: "))"},
+	})
+	if err != nil || string(out) != "((a+c))" {
+		t.Errorf("Apply = %q, %v", out, err)
+	}
+	if _, err := ast.Apply(src, []ast.Edit{{Start: at(0), End: at(2)}, {Start: at(1), End: at(3)}}); !errors.Is(err, ast.ErrOverlap) {
+		t.Errorf("overlapping Apply = %v, want ErrOverlap", err)
+	}
+	if _, err := ast.Apply(src, []ast.Edit{{Start: at(2), End: at(9)}}); err == nil {
+		t.Error("out of range edit accepted")
+	}
+}
+
+func TestRewriteWithMap(t *testing.T) {
+	src := "x: old[1]+y"
+	out, m, err := ast.RewriteWithMap([]byte(src), func(n ast.Node, source []byte) (string, bool) {
+		if c, ok := n.(*ast.Call); ok && c.Func.Name == "old" {
+			return "renamed[1]", true
+		}
+		return "", false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	y := uint(len(src) - 1)
+	if got := m.Offset(y); out[got] != 'y' {
+		t.Errorf("y maps to %d in %q", got, out)
+	}
+	if start, end := m.Range(3, 9); string(out[start:end]) != "renamed[1]" {
+		t.Errorf("call maps to %q", out[start:end])
+	}
+}
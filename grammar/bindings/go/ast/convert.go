@@ -0,0 +1,213 @@
+package ast
+
+import (
+	"strconv"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+// FromTree converts a parse tree into a typed File. Source must be the text
+// the tree was parsed from. Syntax errors do not stop the conversion; they
+// surface as BadStmt and BadExpr nodes.
+func FromTree(tree *tree_sitter.Tree, source []byte) *File {
+	return FromNode(tree.RootNode(), source)
+}
+
+// FromNode converts a source_file node into a typed File.
+func FromNode(root *tree_sitter.Node, source []byte) *File {
+	c := &converter{source: source}
+	f := &File{Span: span(root)}
+	c.collectComments(root, f)
+	if root.IsError() {
+		f.Stmt = &BadStmt{Span: span(root)}
+		return f
+	}
+	for _, child := range c.namedChildren(root) {
+		if child.Kind() == tree_sitter_wabznasm.KindStatement || child.IsError() {
+			f.Stmt = c.stmt(&child)
+			break
+		}
+	}
+	return f
+}
+
+// ConvertExpr converts a single expression-level node (any rung of the
+// precedence ladder) into a typed Expr.
+func ConvertExpr(n *tree_sitter.Node, source []byte) Expr {
+	c := &converter{source: source}
+	return c.expr(n)
+}
+
+type converter struct {
+	source []byte
+}
+
+func (c *converter) text(n *tree_sitter.Node) string {
+	return n.Utf8Text(c.source)
+}
+
+// namedChildren returns the named children of n, skipping comments and
+// other extras.
+func (c *converter) namedChildren(n *tree_sitter.Node) []tree_sitter.Node {
+	cursor := memtrack.Walk(n)
+	defer cursor.Close()
+	var out []tree_sitter.Node
+	for _, child := range n.NamedChildren(cursor.TreeCursor) {
+		if child.IsExtra() {
+			continue
+		}
+		out = append(out, child)
+	}
+	return out
+}
+
+func (c *converter) firstNamed(n *tree_sitter.Node) *tree_sitter.Node {
+	children := c.namedChildren(n)
+	if len(children) == 0 {
+		return nil
+	}
+	return &children[0]
+}
+
+func (c *converter) collectComments(n *tree_sitter.Node, f *File) {
+	if n.Kind() == tree_sitter_wabznasm.KindComment {
+		f.Comments = append(f.Comments, &Comment{Span: span(n), Text: c.text(n)})
+		return
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		c.collectComments(n.Child(i), f)
+	}
+}
+
+func (c *converter) stmt(n *tree_sitter.Node) Stmt {
+	if n.IsError() {
+		return &BadStmt{Span: span(n)}
+	}
+	inner := c.firstNamed(n)
+	if inner == nil {
+		return &BadStmt{Span: span(n)}
+	}
+	switch inner.Kind() {
+	case tree_sitter_wabznasm.KindAssignment:
+		a := &Assignment{Span: span(inner)}
+		if name := inner.ChildByFieldName(tree_sitter_wabznasm.FieldName); name != nil {
+			a.Name = c.ident(name)
+		}
+		a.Value = c.expr(inner.ChildByFieldName(tree_sitter_wabznasm.FieldValue))
+		return a
+	case tree_sitter_wabznasm.KindExpression:
+		return &ExprStmt{Span: span(inner), X: c.expr(inner)}
+	}
+	return &BadStmt{Span: span(inner)}
+}
+
+func (c *converter) expr(n *tree_sitter.Node) Expr {
+	if n == nil {
+		return nil
+	}
+	if n.IsMissing() {
+		return &BadExpr{Span: span(n), Missing: true}
+	}
+	if n.IsError() {
+		return &BadExpr{Span: span(n)}
+	}
+	switch n.Kind() {
+	case tree_sitter_wabznasm.KindExpression:
+		return c.expr(c.firstNamed(n))
+	case tree_sitter_wabznasm.KindAdditive, tree_sitter_wabznasm.KindMultiplicative:
+		if op := n.ChildByFieldName(tree_sitter_wabznasm.FieldOperator); op != nil {
+			return c.binary(n, op, n.ChildByFieldName(tree_sitter_wabznasm.FieldLeft), n.ChildByFieldName(tree_sitter_wabznasm.FieldRight))
+		}
+		return c.expr(c.firstNamed(n))
+	case tree_sitter_wabznasm.KindPower:
+		if op := n.ChildByFieldName(tree_sitter_wabznasm.FieldOperator); op != nil {
+			return c.binary(n, op, n.ChildByFieldName(tree_sitter_wabznasm.FieldBase), n.ChildByFieldName(tree_sitter_wabznasm.FieldExponent))
+		}
+		return c.expr(c.firstNamed(n))
+	case tree_sitter_wabznasm.KindUnary:
+		if op := n.ChildByFieldName(tree_sitter_wabznasm.FieldOperator); op != nil {
+			return &UnaryExpr{
+				Span:    span(n),
+				Op:      op.Kind(),
+				Operand: c.expr(n.ChildByFieldName(tree_sitter_wabznasm.FieldOperand)),
+			}
+		}
+		return c.expr(c.firstNamed(n))
+	case tree_sitter_wabznasm.KindPostfix:
+		if op := n.ChildByFieldName(tree_sitter_wabznasm.FieldOperator); op != nil {
+			return &PostfixExpr{
+				Span:    span(n),
+				Op:      op.Kind(),
+				Operand: c.expr(n.ChildByFieldName(tree_sitter_wabznasm.FieldOperand)),
+			}
+		}
+		return c.expr(c.firstNamed(n))
+	case tree_sitter_wabznasm.KindPrimary:
+		if n.ChildByFieldName(tree_sitter_wabznasm.FieldLeftParen) != nil {
+			return &ParenExpr{Span: span(n), X: c.expr(n.ChildByFieldName(tree_sitter_wabznasm.FieldExpression))}
+		}
+		return c.expr(c.firstNamed(n))
+	case tree_sitter_wabznasm.KindFunctionCall:
+		call := &Call{Span: span(n)}
+		if fn := n.ChildByFieldName(tree_sitter_wabznasm.FieldFunction); fn != nil {
+			call.Func = c.ident(fn)
+		}
+		if args := n.ChildByFieldName(tree_sitter_wabznasm.FieldArgs); args != nil {
+			cursor := memtrack.Walk(args)
+			for _, arg := range args.ChildrenByFieldName(tree_sitter_wabznasm.FieldArg, cursor.TreeCursor) {
+				call.Args = append(call.Args, c.expr(&arg))
+			}
+			cursor.Close()
+		}
+		return call
+	case tree_sitter_wabznasm.KindFunctionBody:
+		fn := &FunctionDef{Span: span(n), Body: c.expr(n.ChildByFieldName(tree_sitter_wabznasm.FieldBody))}
+		if params := n.ChildByFieldName(tree_sitter_wabznasm.FieldParams); params != nil {
+			fn.Params = &ParamList{Span: span(params)}
+			cursor := memtrack.Walk(params)
+			for _, p := range params.ChildrenByFieldName(tree_sitter_wabznasm.FieldParam, cursor.TreeCursor) {
+				fn.Params.Names = append(fn.Params.Names, c.ident(&p))
+			}
+			cursor.Close()
+		}
+		return fn
+	case tree_sitter_wabznasm.KindIdentifier:
+		return c.ident(n)
+	case tree_sitter_wabznasm.KindNumber:
+		text := c.text(n)
+		lit := &NumberLiteral{Span: span(n), Text: text}
+		lit.Value, lit.Err = strconv.ParseInt(text, 10, 64)
+		if lit.Err != nil {
+			lit.Value = 0
+		}
+		return lit
+	}
+	return &BadExpr{Span: span(n)}
+}
+
+func (c *converter) binary(n, op, left, right *tree_sitter.Node) Expr {
+	return &BinaryExpr{
+		Span:  span(n),
+		Op:    op.Kind(),
+		OpPos: pos(op.StartByte(), op.StartPosition()),
+		Left:  c.expr(left),
+		Right: c.expr(right),
+	}
+}
+
+func (c *converter) ident(n *tree_sitter.Node) *Ident {
+	return &Ident{Span: span(n), Name: c.text(n)}
+}
+
+func span(n *tree_sitter.Node) Span {
+	return Span{
+		Start: pos(n.StartByte(), n.StartPosition()),
+		End:   pos(n.EndByte(), n.EndPosition()),
+	}
+}
+
+func pos(offset uint, p tree_sitter.Point) Pos {
+	return Pos{Offset: offset, Row: p.Row, Column: p.Column}
+}
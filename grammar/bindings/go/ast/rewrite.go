@@ -0,0 +1,108 @@
+package ast
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/sourcemap"
+)
+
+// ErrOverlap is returned by Apply when two edits replace overlapping
+// ranges.
+var ErrOverlap = errors.New("ast: overlapping edits")
+
+// Edit replaces the source bytes [Start, End) with NewText. An edit with
+// Start equal to End inserts.
+type Edit struct {
+	Start   Pos
+	End     Pos
+	NewText string
+}
+
+// Apply applies edits to src and returns the result, leaving every byte
+// outside the edited ranges untouched. Edits may be given in any order;
+// insertions at the same offset are applied in the order given.
+func Apply(src []byte, edits []Edit) ([]byte, error) {
+	out, _, err := ApplyWithMap(src, edits)
+	return out, err
+}
+
+// ApplyWithMap is like Apply, but also returns a map from offsets of src to
+// offsets of the result, in which each edited range corresponds to its new
+// text.
+func ApplyWithMap(src []byte, edits []Edit) ([]byte, *sourcemap.Map, error) {
+	sorted := append([]Edit(nil), edits...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Offset < sorted[j].Start.Offset })
+	out := make([]byte, 0, len(src))
+	last := uint(0)
+	var segments sourcemap.Builder
+	for _, e := range sorted {
+		if e.End.Offset < e.Start.Offset || e.End.Offset > uint(len(src)) {
+			return nil, nil, fmt.Errorf("ast: edit [%d, %d) out of range of %d bytes", e.Start.Offset, e.End.Offset, len(src))
+		}
+		if e.Start.Offset < last {
+			return nil, nil, fmt.Errorf("%w: edit at %d starts before %d", ErrOverlap, e.Start.Offset, last)
+		}
+		out = append(out, src[last:e.Start.Offset]...)
+		start := uint(len(out))
+		out = append(out, e.NewText...)
+		segments.Add(e.Start.Offset, e.End.Offset, start, uint(len(out)))
+		last = e.End.Offset
+	}
+	out = append(out, src[last:]...)
+	return out, segments.Map(uint(len(src)), uint(len(out))), nil
+}
+
+// A Rewriter returns the replacement text for node and true, or false to
+// leave node alone and consider its children.
+type Rewriter func(node Node, source []byte) (string, bool)
+
+// Rewrite parses source and replaces each node for which rw returns true
+// with the text it returns. Nodes are offered outermost first, and the
+// children of a replaced node are not offered, so replacements never
+// overlap. Comments and spacing outside replaced nodes are preserved.
+func Rewrite(source []byte, rw Rewriter) ([]byte, error) {
+	out, _, err := RewriteWithMap(source, rw)
+	return out, err
+}
+
+// RewriteWithMap is like Rewrite, but also returns a map from offsets of
+// source to offsets of the result, as ApplyWithMap does.
+func RewriteWithMap(source []byte, rw Rewriter) ([]byte, *sourcemap.Map, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer parser.Close()
+	tree, err := parser.ParseBytes(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tree.Close()
+	return ApplyWithMap(source, RewriteEdits(FromTree(tree.Tree, source), source, rw))
+}
+
+// RewriteEdits returns the edits Rewrite would make to f, the typed tree of
+// source, in source order.
+func RewriteEdits(f *File, source []byte, rw Rewriter) []Edit {
+	var edits []Edit
+	Inspect(f, func(n Node) bool {
+		if n == nil {
+			return false
+		}
+		text, ok := rw(n, source)
+		if !ok {
+			return true
+		}
+		edits = append(edits, Edit{Start: n.Pos(), End: n.EndPos(), NewText: text})
+		return false
+	})
+	return edits
+}
+
+// Text returns the source text of n.
+func Text(n Node, source []byte) string {
+	return string(source[n.Pos().Offset:n.EndPos().Offset])
+}
@@ -0,0 +1,88 @@
+package ast
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of
+// node with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree rooted at node in depth-first order, starting
+// with node itself. Comments are not visited; they are listed in
+// File.Comments.
+func Walk(node Node, v Visitor) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+	switch n := node.(type) {
+	case *File:
+		if n.Stmt != nil {
+			Walk(n.Stmt, v)
+		}
+	case *Assignment:
+		if n.Name != nil {
+			Walk(n.Name, v)
+		}
+		if n.Value != nil {
+			Walk(n.Value, v)
+		}
+	case *ExprStmt:
+		if n.X != nil {
+			Walk(n.X, v)
+		}
+	case *FunctionDef:
+		if n.Params != nil {
+			Walk(n.Params, v)
+		}
+		if n.Body != nil {
+			Walk(n.Body, v)
+		}
+	case *ParamList:
+		for _, id := range n.Names {
+			Walk(id, v)
+		}
+	case *BinaryExpr:
+		if n.Left != nil {
+			Walk(n.Left, v)
+		}
+		if n.Right != nil {
+			Walk(n.Right, v)
+		}
+	case *UnaryExpr:
+		if n.Operand != nil {
+			Walk(n.Operand, v)
+		}
+	case *PostfixExpr:
+		if n.Operand != nil {
+			Walk(n.Operand, v)
+		}
+	case *ParenExpr:
+		if n.X != nil {
+			Walk(n.X, v)
+		}
+	case *Call:
+		if n.Func != nil {
+			Walk(n.Func, v)
+		}
+		for _, arg := range n.Args {
+			Walk(arg, v)
+		}
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree rooted at node in depth-first order, calling
+// f(node) for each node and descending into its children only while f
+// returns true. After the children, f(nil) is called.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
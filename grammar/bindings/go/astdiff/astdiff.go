@@ -0,0 +1,487 @@
+// Package astdiff compares two wabznasm sources structurally.
+//
+// Diff matches the typed syntax trees of the two sources and reports the
+// nodes that were inserted, deleted, updated or moved, instead of the lines
+// that changed. Layout and comments are not part of the typed tree, so a
+// reformatted source has no changes at all.
+//
+// Matching follows the GumTree approach: identical subtrees are paired
+// first, largest first; then inner nodes are paired when most of their
+// descendants already are; finally the unmatched children of paired nodes
+// are paired with children of the same kind, in order.
+package astdiff
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+// Op is the kind of a change.
+type Op int
+
+const (
+	// Insert is a node present only in the new source.
+	Insert Op = iota
+	// Delete is a node present only in the old source.
+	Delete
+	// Update is a node whose value changed: a renamed identifier, a new
+	// number or a different operator.
+	Update
+	// Move is a node that now has a different parent or position among its
+	// siblings.
+	Move
+)
+
+func (op Op) String() string {
+	switch op {
+	case Insert:
+		return "insert"
+	case Delete:
+		return "delete"
+	case Update:
+		return "update"
+	case Move:
+		return "move"
+	}
+	return fmt.Sprintf("Op(%d)", int(op))
+}
+
+// Change is one structural difference. Only the outermost node of an
+// inserted or deleted subtree is reported.
+type Change struct {
+	Op Op
+	// Kind is the type of the changed node, such as "BinaryExpr" or "Ident".
+	Kind string
+	// Old is the node's span in the old source; nil for Insert.
+	Old *ast.Span
+	// New is the node's span in the new source; nil for Delete.
+	New *ast.Span
+	// OldText and NewText are the source text of the node on each side.
+	OldText string
+	NewText string
+}
+
+func (c Change) String() string {
+	switch c.Op {
+	case Insert:
+		return fmt.Sprintf("%d:%d: insert %s %q", c.New.Start.Row+1, c.New.Start.Column+1, c.Kind, c.NewText)
+	case Delete:
+		return fmt.Sprintf("%d:%d: delete %s %q", c.Old.Start.Row+1, c.Old.Start.Column+1, c.Kind, c.OldText)
+	}
+	return fmt.Sprintf("%d:%d: %s %s %q to %q", c.New.Start.Row+1, c.New.Start.Column+1, c.Op, c.Kind, c.OldText, c.NewText)
+}
+
+// Diff parses both sources and returns their structural differences,
+// ordered by position: deletions by their place in the old source and
+// everything else by its place in the new source.
+func Diff(oldSrc, newSrc []byte) ([]Change, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	oldTree, err := parser.ParseBytes(oldSrc)
+	if err != nil {
+		return nil, err
+	}
+	defer oldTree.Close()
+	newTree, err := parser.ParseBytes(newSrc)
+	if err != nil {
+		return nil, err
+	}
+	defer newTree.Close()
+	return DiffFiles(ast.FromTree(oldTree.Tree, oldSrc), oldSrc, ast.FromTree(newTree.Tree, newSrc), newSrc), nil
+}
+
+// DiffFiles compares two typed trees and the sources they were built from.
+func DiffFiles(oldFile *ast.File, oldSrc []byte, newFile *ast.File, newSrc []byte) []Change {
+	d := &differ{
+		old:   build(oldFile, oldSrc),
+		new:   build(newFile, newSrc),
+		match: map[*node]*node{},
+	}
+	d.topDown()
+	d.bottomUp()
+	d.recover()
+	return d.changes()
+}
+
+// node is a typed tree node reduced to what matching needs.
+type node struct {
+	kind     string
+	label    string
+	span     ast.Span
+	text     string
+	parent   *node
+	children []*node
+	hash     uint64
+	height   int
+	size     int
+	post     int // postorder index
+}
+
+// build flattens f into nodes, returned in postorder.
+func build(f *ast.File, src []byte) []*node {
+	var nodes []*node
+	var stack []*node
+	ast.Inspect(f, func(n ast.Node) bool {
+		if n == nil {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			top.finish()
+			top.post = len(nodes)
+			nodes = append(nodes, top)
+			return false
+		}
+		kind, label := describe(n)
+		nd := &node{kind: kind, label: label, span: ast.Span{Start: n.Pos(), End: n.EndPos()}, text: ast.Text(n, src)}
+		if len(stack) > 0 {
+			nd.parent = stack[len(stack)-1]
+			nd.parent.children = append(nd.parent.children, nd)
+		}
+		stack = append(stack, nd)
+		return true
+	})
+	return nodes
+}
+
+func (n *node) finish() {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00", n.kind, n.label)
+	n.height, n.size = 1, 1
+	for _, c := range n.children {
+		fmt.Fprintf(h, "%x\x00", c.hash)
+		n.height = max(n.height, c.height+1)
+		n.size += c.size
+	}
+	n.hash = h.Sum64()
+}
+
+// describe returns the kind of n and the value it carries beyond its
+// children.
+func describe(n ast.Node) (kind, label string) {
+	kind = strings.TrimPrefix(fmt.Sprintf("%T", n), "*ast.")
+	switch n := n.(type) {
+	case *ast.Ident:
+		return kind, n.Name
+	case *ast.NumberLiteral:
+		return kind, n.Text
+	case *ast.BinaryExpr:
+		return kind, n.Op
+	case *ast.UnaryExpr:
+		return kind, n.Op
+	case *ast.PostfixExpr:
+		return kind, n.Op
+	case *ast.FunctionDef:
+		if n.Params != nil {
+			return kind, "params"
+		}
+	}
+	return kind, ""
+}
+
+type differ struct {
+	old, new []*node
+	// match maps old nodes to new nodes and new nodes to old nodes.
+	match map[*node]*node
+}
+
+func (d *differ) pair(a, b *node) {
+	d.match[a] = b
+	d.match[b] = a
+}
+
+// minHeight keeps lone leaves, which recur everywhere, out of the
+// top-down phase; they are paired through their parents instead.
+const minHeight = 2
+
+// topDown pairs identical subtrees, tallest first. When a subtree occurs
+// several times on both sides, copies whose parents look alike are paired
+// first and the rest in source order.
+func (d *differ) topDown() {
+	for h := d.maxHeight(); h >= minHeight; h-- {
+		olds, news := d.unmatchedAtHeight(d.old, h), d.unmatchedAtHeight(d.new, h)
+		byHash := map[uint64][]*node{}
+		for _, n := range news {
+			byHash[n.hash] = append(byHash[n.hash], n)
+		}
+		for _, pass := range []func(a, b *node) bool{sameParent, func(a, b *node) bool { return true }} {
+			for _, a := range olds {
+				if d.match[a] != nil {
+					continue
+				}
+				for _, b := range byHash[a.hash] {
+					if d.match[b] == nil && pass(a, b) {
+						d.pairSubtrees(a, b)
+						break
+					}
+				}
+			}
+		}
+	}
+}
+
+func sameParent(a, b *node) bool {
+	if a.parent == nil || b.parent == nil {
+		return a.parent == b.parent
+	}
+	return a.parent.kind == b.parent.kind && a.parent.label == b.parent.label
+}
+
+func (d *differ) maxHeight() int {
+	h := 0
+	for _, n := range d.old {
+		h = max(h, n.height)
+	}
+	return h
+}
+
+// unmatchedAtHeight returns the nodes of height h, in preorder, that are
+// not yet paired.
+func (d *differ) unmatchedAtHeight(nodes []*node, h int) []*node {
+	var out []*node
+	for _, n := range nodes {
+		if n.height == h && d.match[n] == nil {
+			out = append(out, n)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].span.Start.Offset < out[j].span.Start.Offset })
+	return out
+}
+
+func (d *differ) pairSubtrees(a, b *node) {
+	d.pair(a, b)
+	for i := range a.children {
+		d.pairSubtrees(a.children[i], b.children[i])
+	}
+}
+
+// simThreshold is the share of paired descendants above which two inner
+// nodes of the same kind are taken to be the same node.
+const simThreshold = 0.5
+
+// bottomUp pairs inner nodes whose descendants are mostly paired with the
+// descendants of a new node of the same kind. The roots always pair.
+func (d *differ) bottomUp() {
+	for _, a := range d.old {
+		if d.match[a] != nil || len(a.children) == 0 {
+			continue
+		}
+		var best *node
+		bestSim := 0.0
+		for _, b := range d.candidates(a) {
+			if sim := d.similarity(a, b); sim > bestSim {
+				best, bestSim = b, sim
+			}
+		}
+		if best != nil && bestSim >= simThreshold {
+			d.pair(a, best)
+		}
+	}
+	oldRoot, newRoot := d.old[len(d.old)-1], d.new[len(d.new)-1]
+	if d.match[oldRoot] == nil && d.match[newRoot] == nil {
+		d.pair(oldRoot, newRoot)
+	}
+}
+
+// candidates returns the unpaired new nodes of a's kind that are ancestors
+// of partners of a's descendants.
+func (d *differ) candidates(a *node) []*node {
+	seen := map[*node]bool{}
+	var out []*node
+	walk(a, func(n *node) {
+		if n == a || d.match[n] == nil {
+			return
+		}
+		for p := d.match[n].parent; p != nil; p = p.parent {
+			if seen[p] {
+				break
+			}
+			seen[p] = true
+			if p.kind == a.kind && d.match[p] == nil {
+				out = append(out, p)
+			}
+		}
+	})
+	return out
+}
+
+// similarity is the dice coefficient of the descendants of a and b that
+// are paired with each other.
+func (d *differ) similarity(a, b *node) float64 {
+	inB := map[*node]bool{}
+	walk(b, func(n *node) { inB[n] = true })
+	common := 0
+	walk(a, func(n *node) {
+		if n != a && inB[d.match[n]] {
+			common++
+		}
+	})
+	return 2 * float64(common) / float64(a.size-1+b.size-1)
+}
+
+// recover pairs the remaining children of paired nodes: first children
+// with identical subtrees, then children with the same kind and value,
+// then children of the same kind, each time in order.
+func (d *differ) recover() {
+	queue := []*node{d.old[len(d.old)-1]}
+	for len(queue) > 0 {
+		a := queue[0]
+		queue = queue[1:]
+		if b := d.match[a]; b != nil {
+			for _, same := range []func(x, y *node) bool{
+				func(x, y *node) bool { return x.hash == y.hash },
+				func(x, y *node) bool { return x.kind == y.kind && x.label == y.label },
+				func(x, y *node) bool { return x.kind == y.kind },
+			} {
+				d.pairInOrder(a.children, b.children, same)
+			}
+		}
+		queue = append(queue, a.children...)
+	}
+}
+
+// pairInOrder pairs unpaired nodes of xs and ys that satisfy same along
+// their longest common subsequence.
+func (d *differ) pairInOrder(xs, ys []*node, same func(x, y *node) bool) {
+	eq := func(x, y *node) bool { return d.match[x] == nil && d.match[y] == nil && same(x, y) }
+	for _, p := range lcs(xs, ys, eq) {
+		if p[0].hash == p[1].hash {
+			d.pairSubtrees(p[0], p[1])
+		} else {
+			d.pair(p[0], p[1])
+		}
+	}
+}
+
+// lcs returns the pairs of a longest common subsequence of xs and ys.
+func lcs(xs, ys []*node, eq func(x, y *node) bool) [][2]*node {
+	m, n := len(xs), len(ys)
+	t := make([][]int, m+1)
+	for i := range t {
+		t[i] = make([]int, n+1)
+	}
+	for i := m - 1; i >= 0; i-- {
+		for j := n - 1; j >= 0; j-- {
+			if eq(xs[i], ys[j]) {
+				t[i][j] = t[i+1][j+1] + 1
+			} else {
+				t[i][j] = max(t[i+1][j], t[i][j+1])
+			}
+		}
+	}
+	var out [][2]*node
+	for i, j := 0, 0; i < m && j < n; {
+		switch {
+		case eq(xs[i], ys[j]):
+			out = append(out, [2]*node{xs[i], ys[j]})
+			i++
+			j++
+		case t[i+1][j] >= t[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return out
+}
+
+func walk(n *node, f func(*node)) {
+	f(n)
+	for _, c := range n.children {
+		walk(c, f)
+	}
+}
+
+// changes turns the pairing into a list of changes.
+func (d *differ) changes() []Change {
+	var out []Change
+	for _, a := range d.old {
+		b := d.match[a]
+		switch {
+		case b == nil:
+			if a.parent == nil || d.match[a.parent] != nil {
+				out = append(out, d.change(Delete, a, nil))
+			}
+		case a.label != b.label:
+			out = append(out, d.change(Update, a, b))
+		}
+	}
+	for _, b := range d.new {
+		if d.match[b] == nil && (b.parent == nil || d.match[b.parent] != nil) {
+			out = append(out, d.change(Insert, nil, b))
+		}
+	}
+	out = append(out, d.moves()...)
+	sort.SliceStable(out, func(i, j int) bool { return changePos(out[i]) < changePos(out[j]) })
+	return out
+}
+
+// moves reports paired nodes whose parents are not paired with each other,
+// and paired siblings that fall outside the longest run kept in order.
+func (d *differ) moves() []Change {
+	var out []Change
+	for _, b := range d.new {
+		a := d.match[b]
+		if a == nil || b.parent == nil || a.parent == nil {
+			continue
+		}
+		if d.match[a.parent] != b.parent {
+			out = append(out, d.change(Move, a, b))
+		}
+	}
+	for _, bp := range d.new {
+		ap := d.match[bp]
+		if ap == nil {
+			continue
+		}
+		var xs, ys []*node
+		for _, c := range ap.children {
+			if m := d.match[c]; m != nil && m.parent == bp {
+				xs = append(xs, c)
+			}
+		}
+		for _, c := range bp.children {
+			if m := d.match[c]; m != nil && m.parent == ap {
+				ys = append(ys, c)
+			}
+		}
+		kept := map[*node]bool{}
+		for _, p := range lcs(xs, ys, func(x, y *node) bool { return d.match[x] == y }) {
+			kept[p[1]] = true
+		}
+		for _, c := range ys {
+			if !kept[c] {
+				out = append(out, d.change(Move, d.match[c], c))
+			}
+		}
+	}
+	return out
+}
+
+func (d *differ) change(op Op, a, b *node) Change {
+	c := Change{Op: op}
+	if a != nil {
+		c.Kind = a.kind
+		c.Old = &a.span
+		c.OldText = a.text
+	}
+	if b != nil {
+		c.Kind = b.kind
+		c.New = &b.span
+		c.NewText = b.text
+	}
+	return c
+}
+
+func changePos(c Change) uint {
+	if c.New != nil {
+		return c.New.Start.Offset
+	}
+	return c.Old.Start.Offset
+}
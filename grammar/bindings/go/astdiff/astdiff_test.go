@@ -0,0 +1,61 @@
+package astdiff_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/astdiff"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func diff(t *testing.T, oldSrc, newSrc string) []string {
+	t.Helper()
+	changes, err := astdiff.Diff([]byte(oldSrc), []byte(newSrc))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, c := range changes {
+		out = append(out, c.String())
+	}
+	return out
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+	}{
+		{"reformatted", "add: {[x;y] x+y}", "\\ adds\nadd: {[x; y]\n  x + y}", nil},
+		{"renamed", "add: {[x;y] x+y}", "add: {[x;z] x+z}", []string{
+			`1:10: update Ident "y" to "z"`,
+			`1:15: update Ident "y" to "z"`,
+		}},
+		{"operator", "f: {x*y+z}", "f: {x*y-z}", []string{`1:5: update BinaryExpr "x*y+z" to "x*y-z"`}},
+		{"inserted", "f[1;2]", "f[1;g[2;3];2]", []string{`1:5: insert Call "g[2;3]"`}},
+		{"deleted", "f[1;g[2;3];2]", "f[1;2]", []string{`1:5: delete Call "g[2;3]"`}},
+		{"swapped", "f[g[a;b];h[c;d]]", "f[h[c;d];g[a;b]]", []string{`1:10: move Call "g[a;b]" to "g[a;b]"`}},
+		{"wrapped", "t: f[a;b]*2", "t: (f[a;b]*2)+1", []string{
+			`1:4: insert BinaryExpr "(f[a;b]*2)+1"`,
+			`1:5: move BinaryExpr "f[a;b]*2" to "f[a;b]*2"`,
+		}},
+	}
+	for _, tt := range tests {
+		got := diff(t, tt.old, tt.new)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Diff = %q, want %q", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: Diff = %q, want %q", tt.name, got, tt.want)
+				break
+			}
+		}
+	}
+}
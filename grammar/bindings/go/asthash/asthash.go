@@ -0,0 +1,110 @@
+// Package asthash fingerprints wabznasm syntax trees by structure alone,
+// so that tools can tell whether a formula has really changed. Two
+// sources that differ only in whitespace and comments have the same hash.
+//
+// A hash covers the kind and field of every node, whether it is missing,
+// and the text of every leaf, encoded without ambiguity and digested with
+// SHA-256. It does not depend on byte offsets, the platform or the
+// version of Go, so it is safe to store.
+package asthash
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// Sum is a structural hash.
+type Sum [sha256.Size]byte
+
+// String returns the hash in hexadecimal.
+func (s Sum) String() string { return hex.EncodeToString(s[:]) }
+
+// Hash returns the structural hash of the subtree at node, whose text is
+// in source.
+func Hash(node *tree_sitter.Node, source []byte) Sum {
+	return HashFunc(node, source, nil)
+}
+
+// HashFunc is like Hash, but hashes the text of each leaf as rewritten by
+// leaf. Tools use it to ignore differences that do not matter to them, such
+// as the names of parameters. A nil leaf hashes the text unchanged.
+func HashFunc(node *tree_sitter.Node, source []byte, leaf func(n *tree_sitter.Node, text string) string) Sum {
+	h := sha256.New()
+	write(h, node, "", source, leaf)
+	var s Sum
+	h.Sum(s[:0])
+	return s
+}
+
+func write(h hash.Hash, n *tree_sitter.Node, field string, src []byte, leaf func(*tree_sitter.Node, string) string) {
+	var flags byte
+	if n.IsMissing() {
+		flags = 1
+	}
+	h.Write([]byte{'(', flags})
+	writeString(h, n.Kind())
+	writeString(h, field)
+	if n.ChildCount() == 0 {
+		text := n.Utf8Text(src)
+		if leaf != nil {
+			text = leaf(n, text)
+		}
+		writeString(h, text)
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		if c := n.Child(i); !c.IsExtra() {
+			write(h, c, n.FieldNameForChild(uint32(i)), src, leaf)
+		}
+	}
+	h.Write([]byte{')'})
+}
+
+// writeString writes s with its length, so that adjacent strings cannot
+// run together.
+func writeString(h hash.Hash, s string) {
+	h.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	h.Write([]byte(s))
+}
+
+// Equal reports whether the subtrees at a and b, whose texts are in srcA
+// and srcB, have the same structure: the same nodes in the same fields,
+// and the same leaf text, ignoring whitespace and comments. It compares
+// the trees directly rather than their hashes.
+func Equal(a *tree_sitter.Node, srcA []byte, b *tree_sitter.Node, srcB []byte) bool {
+	if a.Kind() != b.Kind() || a.IsMissing() != b.IsMissing() {
+		return false
+	}
+	if a.ChildCount() == 0 || b.ChildCount() == 0 {
+		return a.ChildCount() == b.ChildCount() && a.Utf8Text(srcA) == b.Utf8Text(srcB)
+	}
+	as, bs := children(a), children(b)
+	if len(as) != len(bs) {
+		return false
+	}
+	for i := range as {
+		if as[i].field != bs[i].field || !Equal(as[i].node, srcA, bs[i].node, srcB) {
+			return false
+		}
+	}
+	return true
+}
+
+type child struct {
+	node  *tree_sitter.Node
+	field string
+}
+
+// children returns the children of n that are not extras.
+func children(n *tree_sitter.Node) []child {
+	var out []child
+	for i := uint(0); i < n.ChildCount(); i++ {
+		if c := n.Child(i); !c.IsExtra() {
+			out = append(out, child{c, n.FieldNameForChild(uint32(i))})
+		}
+	}
+	return out
+}
@@ -0,0 +1,97 @@
+package asthash_test
+
+import (
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/asthash"
+)
+
+func TestHash(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	for _, tt := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{"x: 1 + 2", "x:1+2", true},
+		{"f: {[x; y] x + y}", "f: {[x;y]\n  \\ sum\n  x+y\n}", true},
+		{"x: 1 + 2", "x: 1 + 3", false},
+		{"x: 1 + 2", "y: 1 + 2", false},
+		{"1 - 2", "1 * 2", false},
+		{"(1 + 2) * 3", "1 + 2 * 3", false},
+		{"f[1; 2]", "f[1 2]", false},
+		{"ab", "a b", false},
+	} {
+		ta, err := parser.ParseBytes([]byte(tt.a))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tb, err := parser.ParseBytes([]byte(tt.b))
+		if err != nil {
+			t.Fatal(err)
+		}
+		ha := asthash.Hash(ta.RootNode(), []byte(tt.a))
+		hb := asthash.Hash(tb.RootNode(), []byte(tt.b))
+		if (ha == hb) != tt.equal {
+			t.Errorf("Hash(%q) == Hash(%q) is %v, want %v", tt.a, tt.b, ha == hb, tt.equal)
+		}
+		if got := asthash.Equal(ta.RootNode(), []byte(tt.a), tb.RootNode(), []byte(tt.b)); got != tt.equal {
+			t.Errorf("Equal(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.equal)
+		}
+		ta.Close()
+		tb.Close()
+	}
+}
+
+func TestHashStable(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	src := []byte("x: 1 + 2")
+	tree, err := parser.ParseBytes(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	// The hash is stored by build tools, so it must not change between
+	// releases without good reason.
+	const want = "056964a65c50228a3db60ca6d3207c766cf3ddb0f28c4df62f31abab8c761c14"
+	if got := asthash.Hash(tree.RootNode(), src).String(); got != want {
+		t.Errorf("Hash(%q) = %s, want %s", src, got, want)
+	}
+}
+
+func TestHashFunc(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	hash := func(src string) asthash.Sum {
+		tree, err := parser.ParseBytes([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tree.Close()
+		// Hash every identifier alike.
+		return asthash.HashFunc(tree.RootNode(), []byte(src), func(n *tree_sitter.Node, text string) string {
+			if n.Kind() == "identifier" {
+				return "_"
+			}
+			return text
+		})
+	}
+	if hash("a + b") != hash("c + d") {
+		t.Error(`HashFunc("a + b") != HashFunc("c + d") with identifiers ignored`)
+	}
+	if hash("a + 1") == hash("a + 2") {
+		t.Error(`HashFunc("a + 1") == HashFunc("a + 2")`)
+	}
+}
@@ -0,0 +1,170 @@
+// Package baseline records the lint findings a codebase already has, so
+// that a linter adopted on it reports only the findings introduced since.
+//
+// A finding is identified by a fingerprint rather than its position, so
+// that it stays suppressed when lines are added or removed around it. The
+// fingerprint covers the rule, the structural hash of the syntax node the
+// finding covers (see package asthash), the kinds of the nodes enclosing
+// it and the name of the global it appears in. Reformatting or
+// commenting code keeps its fingerprints; changing the code a finding is
+// about makes the finding new.
+//
+// Findings with the same fingerprint in a file, as two unused parameters
+// of the same name in copies of a function, are counted: a baseline
+// recording two suppresses two, and a third is reported.
+package baseline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/asthash"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+)
+
+// Version is the version of the baseline format.
+const Version = 1
+
+// Baseline is a set of recorded findings.
+type Baseline struct {
+	Version  int     `json:"version"`
+	Findings []Entry `json:"findings"`
+}
+
+// Entry is a recorded finding. Message and Line are for the reader; only
+// the path, rule and fingerprint are matched.
+type Entry struct {
+	// Path is the file of the finding, cleaned and with slashes.
+	Path        string `json:"path"`
+	Rule        string `json:"rule"`
+	Fingerprint string `json:"fingerprint"`
+	Message     string `json:"message"`
+	Line        int    `json:"line"`
+}
+
+// ReadFile reads the baseline at path.
+func ReadFile(path string) (*Baseline, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("baseline: %s: %w", path, err)
+	}
+	if b.Version != Version {
+		return nil, fmt.Errorf("baseline: %s: unsupported version %d", path, b.Version)
+	}
+	return &b, nil
+}
+
+// WriteFile writes b to path, with its findings sorted so that the file
+// diffs well between runs.
+func (b *Baseline) WriteFile(path string) error {
+	b.Version = Version
+	if b.Findings == nil {
+		b.Findings = []Entry{}
+	}
+	sort.SliceStable(b.Findings, func(i, j int) bool {
+		x, y := b.Findings[i], b.Findings[j]
+		if x.Path != y.Path {
+			return x.Path < y.Path
+		}
+		if x.Line != y.Line {
+			return x.Line < y.Line
+		}
+		return x.Rule < y.Rule
+	})
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Add records findings of tree, parsed from src, the contents of the file
+// at path.
+func (b *Baseline) Add(path string, tree *tree_sitter.Tree, src []byte, findings []lint.Finding) {
+	for _, f := range findings {
+		b.Findings = append(b.Findings, Entry{
+			Path:        filepath.ToSlash(filepath.Clean(path)),
+			Rule:        f.Rule,
+			Fingerprint: Fingerprint(tree, src, f),
+			Message:     f.Message,
+			Line:        int(f.Range.StartPoint.Row) + 1,
+		})
+	}
+}
+
+// Matcher suppresses the findings of a baseline.
+type Matcher struct {
+	// left counts the recorded findings of each path and fingerprint not
+	// yet matched.
+	left map[[2]string]int
+}
+
+// Matcher returns a matcher of the findings of b.
+func (b *Baseline) Matcher() *Matcher {
+	m := &Matcher{left: map[[2]string]int{}}
+	for _, e := range b.Findings {
+		m.left[[2]string{e.Path, e.Fingerprint}]++
+	}
+	return m
+}
+
+// New returns the findings of tree, parsed from src, the contents of the
+// file at path, that the baseline does not record. Each recorded finding
+// suppresses one finding.
+func (m *Matcher) New(path string, tree *tree_sitter.Tree, src []byte, findings []lint.Finding) []lint.Finding {
+	var out []lint.Finding
+	for _, f := range findings {
+		key := [2]string{filepath.ToSlash(filepath.Clean(path)), Fingerprint(tree, src, f)}
+		if m.left[key] > 0 {
+			m.left[key]--
+			continue
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// Fixed returns the number of recorded findings not matched so far: those
+// fixed since the baseline was recorded, once every file has been matched.
+func (m *Matcher) Fixed() int {
+	n := 0
+	for _, c := range m.left {
+		n += c
+	}
+	return n
+}
+
+// Fingerprint returns the fingerprint of f, a finding of tree, parsed from
+// src.
+func Fingerprint(tree *tree_sitter.Tree, src []byte, f lint.Finding) string {
+	h := sha256.New()
+	write := func(s string) {
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	write(f.Rule)
+	anchor := tree.RootNode().NamedDescendantForByteRange(f.Range.StartByte, f.Range.EndByte)
+	if anchor != nil {
+		sum := asthash.Hash(anchor, src)
+		h.Write(sum[:])
+		for n := anchor.Parent(); n != nil; n = n.Parent() {
+			write(n.Kind())
+			if n.Kind() == tree_sitter_wabznasm.KindAssignment {
+				if name := n.ChildByFieldName(tree_sitter_wabznasm.FieldName); name != nil {
+					write(name.Utf8Text(src))
+				}
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
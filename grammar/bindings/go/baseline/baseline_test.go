@@ -0,0 +1,82 @@
+package baseline_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/baseline"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func lintSource(t *testing.T, src string) (*memtrack.TrackedTree, []lint.Finding) {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree, lint.Run(tree.Tree, []byte(src))
+}
+
+func TestBaseline(t *testing.T) {
+	old := "f: {[x;y;z] x*2}"
+	tree, findings := lintSource(t, old)
+	if len(findings) != 2 {
+		t.Fatalf("findings = %v", findings)
+	}
+	var b baseline.Baseline
+	b.Add("lib/f.wabznasm", tree.Tree, []byte(old), findings)
+	path := filepath.Join(t.TempDir(), "baseline.json")
+	if err := b.WriteFile(path); err != nil {
+		t.Fatal(err)
+	}
+	read, err := baseline.ReadFile(path)
+	if err != nil || len(read.Findings) != 2 {
+		t.Fatalf("ReadFile = %+v, %v", read, err)
+	}
+
+	tests := []struct {
+		src  string
+		want int
+	}{
+		// Moved down and reformatted, the findings are the same.
+		{"\\ doubles x\n\nf: {[x; y; z]\n  x*2}", 0},
+		// A new unused parameter is reported.
+		{"f: {[x;y;z;w] x*2}", 1},
+		// Using y leaves z, still recorded.
+		{"f: {[x;y;z] x*y}", 0},
+		// The same parameter in another function is new.
+		{"g: {[x;y;z] x*2}", 2},
+	}
+	for _, tt := range tests {
+		tree, findings := lintSource(t, tt.src)
+		m := read.Matcher()
+		if got := m.New("lib/f.wabznasm", tree.Tree, []byte(tt.src), findings); len(got) != tt.want {
+			t.Errorf("%q: new findings %v, want %d", tt.src, got, tt.want)
+		}
+	}
+
+	// Another file's findings are not suppressed.
+	m := read.Matcher()
+	if got := m.New("lib/g.wabznasm", tree.Tree, []byte(old), findings); len(got) != 2 || m.Fixed() != 2 {
+		t.Errorf("other file: new findings %v, %d fixed", got, m.Fixed())
+	}
+}
+
+func TestBaselineCounts(t *testing.T) {
+	src := "f: {[x;y] x}"
+	tree, findings := lintSource(t, src)
+	var b baseline.Baseline
+	b.Add("f.wabznasm", tree.Tree, []byte(src), findings)
+	twice := append(append([]lint.Finding(nil), findings...), findings...)
+	if got := b.Matcher().New("f.wabznasm", tree.Tree, []byte(src), twice); len(got) != len(findings) {
+		t.Errorf("new findings = %v, want the second copy only", got)
+	}
+}
@@ -0,0 +1,88 @@
+// Package bench holds the inputs of the wabznasm benchmarks, and compares
+// benchmark results, so that a change that slows parsing, editing,
+// querying, formatting or evaluation down fails a check instead of
+// shipping unnoticed.
+//
+// The benchmarks are those of this package's tests. Every input is built
+// by code, not read from disk or generated at random, so that a run on one
+// checkout measures the same work as a run on another. To compare a change
+// with the code before it, run the benchmarks on each and pass the outputs
+// to cmd/bench-compare:
+//
+//	go test -run '^$' -bench . -count 6 ./bindings/go/bench > old.txt
+//	git stash; ...; go test -run '^$' -bench . -count 6 ./bindings/go/bench > new.txt
+//	go run ./bindings/go/cmd/bench-compare old.txt new.txt
+//
+// ParseResults reads the output of go test -bench, and Compare reports the
+// benchmarks that got slower, or allocate more, by more than a threshold.
+package bench
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Input is a named source benchmarked.
+type Input struct {
+	Name   string
+	Source []byte
+}
+
+// Small is a typical one-line function.
+var Small = []byte("add: {[x;y] x+y*2}")
+
+// ChainGlobals defines the function g that Chain calls.
+const ChainGlobals = "g: {[a;b] a*b+1}"
+
+// Chain returns an assignment of an expression of n terms, mixing the
+// operators, parentheses, calls and postfix operators of the grammar. It
+// evaluates without error once ChainGlobals has been.
+func Chain(n int) []byte {
+	var b strings.Builder
+	b.WriteString("total: ")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteString([]string{"+", "-", "*", "+"}[i%4])
+		}
+		switch i % 5 {
+		case 0:
+			fmt.Fprintf(&b, "%d", i%97)
+		case 1:
+			fmt.Fprintf(&b, "(%d+%d)", i%13, i%7)
+		case 2:
+			fmt.Fprintf(&b, "g[%d;2]", i%11)
+		case 3:
+			fmt.Fprintf(&b, "%d!", i%5)
+		case 4:
+			fmt.Fprintf(&b, "-%d", i%19)
+		}
+	}
+	return []byte(b.String())
+}
+
+// Nested returns an expression nested depth parentheses deep.
+func Nested(depth int) []byte {
+	return []byte(strings.Repeat("(", depth) + "1" + strings.Repeat(")", depth))
+}
+
+// Broken returns a source of n fragments that do not parse, which makes
+// the parser recover from an error at every one of them.
+func Broken(n int) []byte {
+	var b strings.Builder
+	b.WriteString("f: {[a;b] ")
+	for i := 0; i < n; i++ {
+		b.WriteString([]string{"a+", ")(", "*]", "b[;", "{+}"}[i%5])
+	}
+	return []byte(b.String())
+}
+
+// Inputs returns the inputs of the parse benchmarks: small, large and
+// pathological.
+func Inputs() []Input {
+	return []Input{
+		{"small", Small},
+		{"large", Chain(5000)},
+		{"nested", Nested(1000)},
+		{"broken", Broken(1000)},
+	}
+}
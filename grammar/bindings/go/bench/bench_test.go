@@ -0,0 +1,181 @@
+package bench_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/bench"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/highlight"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func parse(tb testing.TB, src []byte) *memtrack.TrackedTree {
+	tb.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseBytes(src)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return tree
+}
+
+func TestInputs(t *testing.T) {
+	for _, in := range bench.Inputs() {
+		tree := parse(t, in.Source)
+		if broken := in.Name == "broken"; tree.RootNode().HasError() != broken {
+			t.Errorf("%s: has error %v, want %v", in.Name, !broken, broken)
+		}
+		tree.Close()
+	}
+	in := eval.New()
+	if _, err := in.EvalString(bench.ChainGlobals); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := in.EvalString(string(bench.Chain(100))); err != nil {
+		t.Errorf("Chain does not evaluate: %v", err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	old, err := bench.ParseResults(strings.NewReader(`goos: linux
+BenchmarkParse/small-8   	  100000	      1000 ns/op	     64 B/op	       2 allocs/op
+BenchmarkParse/small-8   	  100000	      1200 ns/op	     64 B/op	       2 allocs/op
+BenchmarkParse/small-8   	  100000	      1100 ns/op	     64 B/op	       2 allocs/op
+BenchmarkFormat-8        	   10000	     50000 ns/op
+BenchmarkGone-8          	   10000	     50000 ns/op
+PASS
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := old["BenchmarkParse/small"]["ns/op"]; len(got) != 3 {
+		t.Fatalf("samples %v", got)
+	}
+	new, err := bench.ParseResults(strings.NewReader(`BenchmarkParse/small-16 100000 1150 ns/op 64 B/op 3 allocs/op
+BenchmarkFormat-16 10000 60000 ns/op
+BenchmarkNew-16 10000 1 ns/op
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deltas := bench.Compare(old, new, bench.Options{})
+	if len(deltas) != 3 {
+		t.Fatalf("deltas %+v", deltas)
+	}
+	want := []struct {
+		name, unit string
+		regressed  bool
+	}{
+		{"BenchmarkFormat", "ns/op", true},
+		{"BenchmarkParse/small", "ns/op", false},
+		{"BenchmarkParse/small", "allocs/op", true},
+	}
+	for i, w := range want {
+		if d := deltas[i]; d.Name != w.name || d.Unit != w.unit || d.Regressed != w.regressed {
+			t.Errorf("delta %d = %+v, want %+v", i, d, w)
+		}
+	}
+	if d := deltas[1]; d.Old != 1100 || d.New != 1150 {
+		t.Errorf("medians %+v", d)
+	}
+	if n := len(bench.Regressions(bench.Compare(old, new, bench.Options{Threshold: 0.6}))); n != 0 {
+		t.Errorf("%d regressions at 60%%", n)
+	}
+	var buf bytes.Buffer
+	if err := bench.WriteTable(&buf, deltas); err != nil || !strings.Contains(buf.String(), "+20.0% !") {
+		t.Errorf("table:\n%s", buf.String())
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer parser.Close()
+	for _, in := range bench.Inputs() {
+		b.Run(in.Name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(in.Source)))
+			for i := 0; i < b.N; i++ {
+				tree, err := parser.ParseBytes(in.Source)
+				if err != nil {
+					b.Fatal(err)
+				}
+				tree.Close()
+			}
+		})
+	}
+}
+
+// BenchmarkIncrementalEdit types a digit into the middle of a large
+// source and deletes it again, reparsing after each edit.
+func BenchmarkIncrementalEdit(b *testing.B) {
+	src := bench.Chain(5000)
+	doc, err := tree_sitter_wabznasm.NewIncrementalDocument(src)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer doc.Close()
+	// Just after the first digit past the middle.
+	at := uint(bytes.IndexAny(src[len(src)/2:], "0123456789") + len(src)/2 + 1)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := doc.ApplyEdit(at, at, at+1, []byte("7")); err != nil {
+			b.Fatal(err)
+		}
+		if err := doc.ApplyEdit(at, at+1, at, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkHighlight(b *testing.B) {
+	src := bench.Chain(5000)
+	tree := parse(b, src)
+	defer tree.Close()
+	h, err := highlight.New(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer h.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		h.Tokens(tree.Tree, src)
+	}
+}
+
+func BenchmarkFormat(b *testing.B) {
+	src := bench.Chain(5000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	for i := 0; i < b.N; i++ {
+		if _, err := format.Source(src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkEval(b *testing.B) {
+	src := string(bench.Chain(1000))
+	in := eval.New()
+	if _, err := in.EvalString(bench.ChainGlobals); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := in.EvalString(src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package bench
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Units are the measurements Compare checks by default.
+var Units = []string{"ns/op", "allocs/op"}
+
+// DefaultThreshold is the relative change beyond which Compare counts a
+// change as a regression: 10%.
+const DefaultThreshold = 0.10
+
+// Results are the measurements of benchmarks, by the name of the benchmark
+// without its GOMAXPROCS suffix, then by unit. A benchmark run several
+// times, as with -count, has a sample for each run.
+type Results map[string]map[string][]float64
+
+// ParseResults reads the output of go test -bench. Lines other than
+// benchmark results are skipped.
+func ParseResults(r io.Reader) (Results, error) {
+	out := Results{}
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		// Name, iterations, then value and unit pairs.
+		if len(fields) < 4 || len(fields)%2 != 0 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			continue
+		}
+		name := trimProcs(fields[0])
+		for i := 2; i+1 < len(fields); i += 2 {
+			v, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%s: bad value %q", fields[0], fields[i])
+			}
+			if out[name] == nil {
+				out[name] = map[string][]float64{}
+			}
+			out[name][fields[i+1]] = append(out[name][fields[i+1]], v)
+		}
+	}
+	return out, sc.Err()
+}
+
+// trimProcs drops the -N suffix go test adds for GOMAXPROCS.
+func trimProcs(name string) string {
+	if i := strings.LastIndexByte(name, '-'); i > 0 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// Options configure Compare.
+type Options struct {
+	// Threshold is the relative increase that counts as a regression;
+	// zero means DefaultThreshold.
+	Threshold float64
+	// Units are the units compared, each measuring a cost, so that more
+	// is worse; nil means Units.
+	Units []string
+}
+
+// Delta is the change of one measurement of a benchmark between two
+// results, compared by the median of the samples of each.
+type Delta struct {
+	Name, Unit string
+	Old, New   float64
+	// Change is the relative change, positive for an increase.
+	Change float64
+	// Regressed reports a Change above the threshold.
+	Regressed bool
+}
+
+// Compare compares the benchmarks measured in both old and new, in name
+// order.
+func Compare(old, new Results, opts Options) []Delta {
+	if opts.Threshold == 0 {
+		opts.Threshold = DefaultThreshold
+	}
+	if opts.Units == nil {
+		opts.Units = Units
+	}
+	names := make([]string, 0, len(new))
+	for name := range new {
+		if _, ok := old[name]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	var out []Delta
+	for _, name := range names {
+		for _, unit := range opts.Units {
+			o, n := old[name][unit], new[name][unit]
+			if len(o) == 0 || len(n) == 0 {
+				continue
+			}
+			d := Delta{Name: name, Unit: unit, Old: median(o), New: median(n)}
+			switch {
+			case d.Old != 0:
+				d.Change = (d.New - d.Old) / d.Old
+			case d.New != 0:
+				// From nothing to something, as from no allocations.
+				d.Change = 1
+			}
+			d.Regressed = d.Change > opts.Threshold
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+func median(samples []float64) float64 {
+	s := append([]float64(nil), samples...)
+	sort.Float64s(s)
+	if len(s)%2 == 1 {
+		return s[len(s)/2]
+	}
+	return (s[len(s)/2-1] + s[len(s)/2]) / 2
+}
+
+// Regressions returns the deltas that regressed.
+func Regressions(deltas []Delta) []Delta {
+	var out []Delta
+	for _, d := range deltas {
+		if d.Regressed {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// WriteTable writes deltas as a table, marking regressions.
+func WriteTable(w io.Writer, deltas []Delta) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "benchmark\tunit\told\tnew\tchange\n")
+	for _, d := range deltas {
+		mark := ""
+		if d.Regressed {
+			mark = " !"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.4g\t%.4g\t%+.1f%%%s\n", d.Name, d.Unit, d.Old, d.New, 100*d.Change, mark)
+	}
+	return tw.Flush()
+}
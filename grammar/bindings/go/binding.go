@@ -1,3 +1,5 @@
+//go:build !(js && wasm)
+
 package tree_sitter_wabznasm
 
 // #cgo CFLAGS: -std=c11 -fPIC
@@ -9,7 +11,17 @@ import "C"
 
 import "unsafe"
 
-// Get the tree-sitter Language for this grammar.
+// Get the tree-sitter Language for this grammar: the one compiled in,
+// unless UseLanguage replaced it.
 func Language() unsafe.Pointer {
-	return unsafe.Pointer(C.tree_sitter_wabznasm())
+	if lang := used.Load(); lang != nil {
+		return unsafe.Pointer(lang.Inner)
+	}
+	return BuiltinLanguage()
+}
+
+// BuiltinLanguage returns the grammar compiled into these bindings,
+// whatever UseLanguage set.
+func BuiltinLanguage() unsafe.Pointer {
+	return unsafe.Pointer(C.tree_sitter_calc())
 }
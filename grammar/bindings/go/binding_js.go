@@ -0,0 +1,17 @@
+//go:build js && wasm
+
+package tree_sitter_wabznasm
+
+import "unsafe"
+
+// Language returns nil on js/wasm, where the grammar is the wasm build
+// loaded by web-tree-sitter rather than compiled in; see package
+// playground for how it is registered.
+func Language() unsafe.Pointer {
+	return nil
+}
+
+// BuiltinLanguage returns nil, as Language does.
+func BuiltinLanguage() unsafe.Pointer {
+	return nil
+}
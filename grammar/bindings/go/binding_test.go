@@ -1,12 +1,18 @@
 package tree_sitter_wabznasm_test
 
 import (
+	"os"
 	"testing"
 
 	tree_sitter "github.com/tree-sitter/go-tree-sitter"
 	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
 )
 
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
 func TestCanLoadGrammar(t *testing.T) {
 	language := tree_sitter.NewLanguage(tree_sitter_wabznasm.Language())
 	if language == nil {
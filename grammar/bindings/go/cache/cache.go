@@ -0,0 +1,240 @@
+// Package cache keeps analysis results on disk between runs, keyed by a
+// hash of the content they were computed from, so that tools can skip
+// re-analysing files that have not changed.
+//
+// Entries are what tools derive from syntax trees, such as diagnostics
+// and lint findings, encoded as JSON, or raw bytes, such as trees encoded
+// by package treebin. Each entry lives in its own file beneath the cache
+// directory, grouped by kind, and is written atomically, so concurrent
+// processes can share a cache. A key also
+// covers the build of the program, since a different version may compute
+// different results from the same content.
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// EnvVar names the environment variable that overrides the location of
+// the default cache. Setting it to "off" disables caching.
+const EnvVar = "WABZNASM_CACHE"
+
+// Key identifies an entry.
+type Key [sha256.Size]byte
+
+// String returns the key in hexadecimal.
+func (k Key) String() string { return hex.EncodeToString(k[:]) }
+
+// NewKey returns the key of parts, such as the contents of a file and a
+// description of the options it was analysed with. Parts are hashed
+// unambiguously together with the build of the program.
+func NewKey(parts ...[]byte) Key {
+	h := sha256.New()
+	write := func(b []byte) {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+		h.Write(n[:])
+		h.Write(b)
+	}
+	write([]byte(buildID()))
+	for _, p := range parts {
+		write(p)
+	}
+	var k Key
+	h.Sum(k[:0])
+	return k
+}
+
+var (
+	buildOnce sync.Once
+	build     string
+)
+
+// buildID identifies the running program. A build from a clean checkout
+// is named by its module version and revision; any other build, such as
+// one with local changes, by a hash of its executable.
+func buildID() string {
+	buildOnce.Do(func() {
+		if info, ok := debug.ReadBuildInfo(); ok {
+			settings := map[string]string{}
+			for _, s := range info.Settings {
+				settings[s.Key] = s.Value
+			}
+			if rev := settings["vcs.revision"]; rev != "" && settings["vcs.modified"] != "true" {
+				build = info.Main.Path + "@" + info.Main.Version + "+" + rev
+				return
+			}
+		}
+		build = "unknown"
+		if exe, err := os.Executable(); err == nil {
+			if f, err := os.Open(exe); err == nil {
+				h := sha256.New()
+				if _, err := io.Copy(h, f); err == nil {
+					build = "exe:" + hex.EncodeToString(h.Sum(nil))
+				}
+				f.Close()
+			}
+		}
+	})
+	return build
+}
+
+// Cache is a directory of entries. A nil *Cache is a valid cache that
+// stores nothing, for callers that have caching turned off.
+type Cache struct {
+	dir string
+}
+
+// Open returns the cache in dir, creating the directory if needed.
+func Open(dir string) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &Cache{dir: dir}, nil
+}
+
+// Default opens the cache named by EnvVar, or else the wabznasm directory
+// of the user's cache directory. It returns nil and no error if EnvVar is
+// "off".
+func Default() (*Cache, error) {
+	dir := os.Getenv(EnvVar)
+	switch dir {
+	case "off":
+		return nil, nil
+	case "":
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		dir = filepath.Join(base, "wabznasm")
+	}
+	return Open(dir)
+}
+
+// Dir returns the directory of c.
+func (c *Cache) Dir() string {
+	if c == nil {
+		return ""
+	}
+	return c.dir
+}
+
+func (c *Cache) path(kind string, key Key, ext string) string {
+	s := key.String()
+	return filepath.Join(c.dir, kind, s[:2], s[2:]+ext)
+}
+
+// Get decodes the entry of kind stored under key into v and reports
+// whether there was one. An entry that cannot be read or decoded counts
+// as missing.
+func (c *Cache) Get(kind string, key Key, v any) bool {
+	data, ok := c.read(kind, key, ".json")
+	return ok && json.Unmarshal(data, v) == nil
+}
+
+// GetBytes returns the bytes of the entry of kind stored under key, as
+// PutBytes stored them, and reports whether there was one.
+func (c *Cache) GetBytes(kind string, key Key) ([]byte, bool) {
+	return c.read(kind, key, ".bin")
+}
+
+func (c *Cache) read(kind string, key Key, ext string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	path := c.path(kind, key, ext)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	// Mark the entry as used, for Trim.
+	now := time.Now()
+	os.Chtimes(path, now, now)
+	return data, true
+}
+
+// Put stores v as the entry of kind under key, replacing any entry there.
+func (c *Cache) Put(kind string, key Key, v any) error {
+	if c == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.write(c.path(kind, key, ".json"), data)
+}
+
+// PutBytes stores data as the entry of kind under key, replacing any
+// entry there. Entries stored with Put and PutBytes are kept apart.
+func (c *Cache) PutBytes(kind string, key Key, data []byte) error {
+	if c == nil {
+		return nil
+	}
+	return c.write(c.path(kind, key, ".bin"), data)
+}
+
+func (c *Cache) write(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// Trim deletes the entries not used for maxAge and returns how many it
+// deleted.
+func (c *Cache) Trim(maxAge time.Duration) (int, error) {
+	if c == nil {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-maxAge)
+	n := 0
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			n++
+		}
+		return nil
+	})
+	return n, err
+}
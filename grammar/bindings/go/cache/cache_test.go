@@ -0,0 +1,136 @@
+package cache_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/cache"
+)
+
+type entry struct {
+	Names []string
+	Count int
+}
+
+func TestPutGet(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := cache.NewKey([]byte("f: {x+1}"), []byte("all"))
+	var got entry
+	if c.Get("test", key, &got) {
+		t.Fatal("Get on an empty cache succeeded")
+	}
+	want := entry{Names: []string{"f"}, Count: 2}
+	if err := c.Put("test", key, want); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Get("test", key, &got) || got.Count != want.Count || len(got.Names) != 1 || got.Names[0] != "f" {
+		t.Errorf("Get = %+v, want %+v", got, want)
+	}
+	if c.Get("other", key, &got) {
+		t.Error("entry visible under another kind")
+	}
+}
+
+func TestPutGetBytes(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := cache.NewKey([]byte("f: {x+1}"))
+	if _, ok := c.GetBytes("tree", key); ok {
+		t.Fatal("GetBytes on an empty cache succeeded")
+	}
+	if err := c.PutBytes("tree", key, []byte("\x00tree")); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := c.GetBytes("tree", key); !ok || string(got) != "\x00tree" {
+		t.Errorf("GetBytes = %q, %v", got, ok)
+	}
+	var e entry
+	if c.Get("tree", key, &e) {
+		t.Error("bytes entry visible to Get")
+	}
+}
+
+func TestNewKey(t *testing.T) {
+	a := cache.NewKey([]byte("ab"), []byte("c"))
+	if a != cache.NewKey([]byte("ab"), []byte("c")) {
+		t.Error("NewKey is not deterministic")
+	}
+	if a == cache.NewKey([]byte("a"), []byte("bc")) {
+		t.Error("NewKey does not separate its parts")
+	}
+}
+
+func TestCorruptEntryIsMissing(t *testing.T) {
+	dir := t.TempDir()
+	c, err := cache.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := cache.NewKey([]byte("x"))
+	if err := c.Put("test", key, entry{Count: 1}); err != nil {
+		t.Fatal(err)
+	}
+	s := key.String()
+	if err := os.WriteFile(filepath.Join(dir, "test", s[:2], s[2:]+".json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var got entry
+	if c.Get("test", key, &got) {
+		t.Error("corrupt entry decoded")
+	}
+}
+
+func TestNilCache(t *testing.T) {
+	var c *cache.Cache
+	key := cache.NewKey([]byte("x"))
+	if err := c.Put("test", key, entry{}); err != nil {
+		t.Fatal(err)
+	}
+	var got entry
+	if c.Get("test", key, &got) {
+		t.Error("nil cache returned an entry")
+	}
+}
+
+func TestDefaultOff(t *testing.T) {
+	t.Setenv(cache.EnvVar, "off")
+	c, err := cache.Default()
+	if c != nil || err != nil {
+		t.Errorf("Default() = %v, %v, want nil, nil", c, err)
+	}
+	dir := t.TempDir()
+	t.Setenv(cache.EnvVar, dir)
+	if c, err := cache.Default(); err != nil || c.Dir() != dir {
+		t.Errorf("Default() = %v, %v, want cache in %s", c, err, dir)
+	}
+}
+
+func TestTrim(t *testing.T) {
+	c, err := cache.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, fresh := cache.NewKey([]byte("old")), cache.NewKey([]byte("fresh"))
+	c.Put("test", old, entry{})
+	c.Put("test", fresh, entry{})
+	s := old.String()
+	past := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(c.Dir(), "test", s[:2], s[2:]+".json"), past, past); err != nil {
+		t.Fatal(err)
+	}
+	n, err := c.Trim(24 * time.Hour)
+	if err != nil || n != 1 {
+		t.Fatalf("Trim = %d, %v, want 1", n, err)
+	}
+	var got entry
+	if c.Get("test", old, &got) || !c.Get("test", fresh, &got) {
+		t.Error("Trim removed the wrong entries")
+	}
+}
@@ -0,0 +1,339 @@
+// Package callgraph builds the graph of which functions of a script call
+// which, so that reviewers can see what a change to a shared function
+// reaches.
+//
+// Like depgraph, it takes a script as a sequence of sources, each holding
+// one or more statements. Every assignment is a node, calling the globals
+// its value applies to arguments, as in f[x]; the statements of a source
+// that assign nothing are one node named after the source. Calls to parameters, as in
+// {[g;x] g[x]}, are not edges, since the callee is not known until the
+// function runs. Functions called but defined nowhere, such as builtins,
+// are external nodes.
+//
+// A graph can be written as Graphviz DOT or as JSON.
+package callgraph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// Kind classifies a node.
+type Kind int
+
+const (
+	// Func is an assignment of a function literal.
+	Func Kind = iota
+	// Value is any other assignment.
+	Value
+	// Script is a statement that assigns nothing.
+	Script
+	// External is a function called but not assigned by the script.
+	External
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Func:
+		return "function"
+	case Value:
+		return "value"
+	case Script:
+		return "script"
+	case External:
+		return "external"
+	}
+	return "unknown"
+}
+
+// Node is a function or other statement of the script.
+type Node struct {
+	Name string
+	Kind Kind
+	// Path is the source the node is defined in, and Pos where its
+	// statement starts. Both are zero for External nodes.
+	Path string
+	Pos  ast.Pos
+	// Calls are the call sites of the statement, in source order.
+	Calls []Call
+}
+
+// Call is a call site.
+type Call struct {
+	Callee string
+	Pos    ast.Pos
+}
+
+// Source is an analysed source of a script.
+type Source struct {
+	Path  string
+	Table *scopes.Table
+}
+
+// Graph is the call graph of a script.
+type Graph struct {
+	nodes   map[string]*Node
+	order   []string
+	callers map[string][]string
+}
+
+// Build returns the call graph of sources, in script order. When a name is
+// assigned more than once, the last assignment counts.
+func Build(sources []Source) *Graph {
+	g := &Graph{nodes: map[string]*Node{}, callers: map[string][]string{}}
+	for _, src := range sources {
+		for _, stmt := range src.Table.Stmts() {
+			g.add(src, stmt)
+		}
+	}
+
+	var external []string
+	for _, name := range g.order {
+		for _, callee := range g.Callees(name) {
+			if g.nodes[callee] == nil {
+				g.nodes[callee] = &Node{Name: callee, Kind: External}
+				external = append(external, callee)
+			}
+			g.callers[callee] = append(g.callers[callee], name)
+		}
+	}
+	sort.Strings(external)
+	g.order = append(g.order, external...)
+	for _, callers := range g.callers {
+		sort.Strings(callers)
+	}
+	return g
+}
+
+// add adds the node of a statement of src.
+func (g *Graph) add(src Source, stmt ast.Stmt) {
+	var n *Node
+	switch s := stmt.(type) {
+	case *ast.Assignment:
+		if s.Name == nil {
+			return
+		}
+		n = &Node{Name: s.Name.Name, Kind: Value}
+		if _, ok := s.Value.(*ast.FunctionDef); ok {
+			n.Kind = Func
+		}
+	case *ast.ExprStmt:
+		n = &Node{Name: src.Path, Kind: Script}
+		if prev := g.nodes[src.Path]; prev != nil && prev.Kind == Script {
+			// The expressions of a source are one node.
+			n = prev
+		}
+	default:
+		return
+	}
+	if n.Path == "" {
+		n.Path, n.Pos = src.Path, stmt.Pos()
+	}
+	ast.Inspect(stmt, func(node ast.Node) bool {
+		call, ok := node.(*ast.Call)
+		if !ok || call.Func == nil {
+			return true
+		}
+		if sym := src.Table.Resolve(call.Func); sym != nil && (sym.Kind == scopes.Global || sym.Kind == scopes.Free) {
+			n.Calls = append(n.Calls, Call{Callee: call.Func.Name, Pos: call.Func.Pos()})
+		}
+		return true
+	})
+	if g.nodes[n.Name] == nil {
+		g.order = append(g.order, n.Name)
+	}
+	g.nodes[n.Name] = n
+}
+
+// FromProject returns the call graph of the files of p in path order.
+func FromProject(p *project.Project) *Graph {
+	var sources []Source
+	for _, path := range p.Files() {
+		if f := p.File(path); f != nil {
+			sources = append(sources, Source{Path: path, Table: f.Symbols})
+		}
+	}
+	return Build(sources)
+}
+
+// Nodes returns the nodes in script order, followed by the external ones
+// sorted by name.
+func (g *Graph) Nodes() []*Node {
+	out := make([]*Node, len(g.order))
+	for i, name := range g.order {
+		out[i] = g.nodes[name]
+	}
+	return out
+}
+
+// Node returns the node named name, or nil.
+func (g *Graph) Node(name string) *Node { return g.nodes[name] }
+
+// Callees returns the names name calls directly, sorted.
+func (g *Graph) Callees(name string) []string {
+	n := g.nodes[name]
+	if n == nil {
+		return nil
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, c := range n.Calls {
+		if !seen[c.Callee] {
+			seen[c.Callee] = true
+			out = append(out, c.Callee)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Callers returns the names calling name directly, sorted.
+func (g *Graph) Callers(name string) []string {
+	return append([]string(nil), g.callers[name]...)
+}
+
+// Affected returns the names calling name directly or through other
+// functions, sorted: everything a change to name may reach. Name itself is
+// included only if it calls itself.
+func (g *Graph) Affected(name string) []string {
+	seen := map[string]bool{}
+	var out []string
+	queue := []string{name}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		for _, caller := range g.callers[next] {
+			if !seen[caller] {
+				seen[caller] = true
+				out = append(out, caller)
+				queue = append(queue, caller)
+			}
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Restrict returns the part of g made of the named nodes and the calls
+// between them.
+func (g *Graph) Restrict(names []string) *Graph {
+	keep := map[string]bool{}
+	for _, name := range names {
+		keep[name] = g.nodes[name] != nil
+	}
+	r := &Graph{nodes: map[string]*Node{}, callers: map[string][]string{}}
+	for _, name := range g.order {
+		if !keep[name] {
+			continue
+		}
+		n := *g.nodes[name]
+		n.Calls = nil
+		for _, c := range g.nodes[name].Calls {
+			if keep[c.Callee] {
+				n.Calls = append(n.Calls, c)
+			}
+		}
+		r.nodes[name] = &n
+		r.order = append(r.order, name)
+		for _, caller := range g.callers[name] {
+			if keep[caller] {
+				r.callers[name] = append(r.callers[name], caller)
+			}
+		}
+	}
+	return r
+}
+
+// WriteDOT writes g in the Graphviz DOT language. Functions are boxes,
+// other assignments ellipses, statements notes and external functions
+// dashed boxes; an edge is labelled with its number of call sites when
+// there is more than one.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph calls {\n\tnode [fontname=\"monospace\"];\n")
+	for _, n := range g.Nodes() {
+		attrs := map[Kind]string{
+			Func:     "shape=box",
+			Value:    "shape=ellipse",
+			Script:   "shape=note",
+			External: "shape=box, style=dashed",
+		}[n.Kind]
+		if n.Path != "" {
+			attrs += fmt.Sprintf(", tooltip=%s", dotQuote(fmt.Sprintf("%s:%d", n.Path, n.Pos.Row+1)))
+		}
+		fmt.Fprintf(&b, "\t%s [%s];\n", dotQuote(n.Name), attrs)
+	}
+	for _, e := range g.edges() {
+		fmt.Fprintf(&b, "\t%s -> %s", dotQuote(e.Caller), dotQuote(e.Callee))
+		if e.Calls > 1 {
+			fmt.Fprintf(&b, " [label=\"%d\"]", e.Calls)
+		}
+		b.WriteString(";\n")
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// nodeJSON and edgeJSON are the records of WriteJSON.
+type nodeJSON struct {
+	Name  string                          `json:"name"`
+	Kind  string                          `json:"kind"`
+	Path  string                          `json:"path,omitempty"`
+	Start *tree_sitter_wabznasm.PointJSON `json:"start,omitempty"`
+}
+
+type edgeJSON struct {
+	Caller string `json:"caller"`
+	Callee string `json:"callee"`
+	// Calls is the number of call sites.
+	Calls int `json:"calls"`
+}
+
+// WriteJSON writes g as a JSON object with the lists "nodes", each with
+// its name, kind and, unless external, path and zero-based start, and
+// "edges", each with its caller, callee and number of call sites.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	out := struct {
+		Nodes []nodeJSON `json:"nodes"`
+		Edges []edgeJSON `json:"edges"`
+	}{Nodes: []nodeJSON{}, Edges: g.edges()}
+	for _, n := range g.Nodes() {
+		rec := nodeJSON{Name: n.Name, Kind: n.Kind.String(), Path: n.Path}
+		if n.Kind != External {
+			rec.Start = &tree_sitter_wabznasm.PointJSON{Row: n.Pos.Row, Column: n.Pos.Column}
+		}
+		out.Nodes = append(out.Nodes, rec)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// edges returns the edges of g, ordered by caller in script order and
+// then by callee.
+func (g *Graph) edges() []edgeJSON {
+	out := []edgeJSON{}
+	for _, n := range g.Nodes() {
+		counts := map[string]int{}
+		for _, c := range n.Calls {
+			counts[c.Callee]++
+		}
+		for _, callee := range g.Callees(n.Name) {
+			out = append(out, edgeJSON{Caller: n.Name, Callee: callee, Calls: counts[callee]})
+		}
+	}
+	return out
+}
@@ -0,0 +1,135 @@
+package callgraph_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/callgraph"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+func build(t *testing.T, script ...string) *callgraph.Graph {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	var sources []callgraph.Source
+	for i, src := range script {
+		tree, err := parser.ParseString(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := string(rune('a'+i)) + ".wabznasm"
+		sources = append(sources, callgraph.Source{Path: path, Table: scopes.FromTree(tree.Tree, []byte(src))})
+		tree.Close()
+	}
+	return callgraph.Build(sources)
+}
+
+func TestGraph(t *testing.T) {
+	g := build(t,
+		"sq: {x*x}",
+		"norm: {[a;b] sqrt[sq[a]+sq[b]]}",
+		"apply: {[f;v] f[v]}",
+		"fact: {[n] n*fact[n-1]}",
+		"total: norm[3;4]+fact[5]",
+		"apply[sq;total]",
+	)
+	var names []string
+	for _, n := range g.Nodes() {
+		names = append(names, n.Name+":"+n.Kind.String())
+	}
+	want := []string{"sq:function", "norm:function", "apply:function", "fact:function", "total:value", "f.wabznasm:script", "sqrt:external"}
+	if !slices.Equal(names, want) {
+		t.Errorf("Nodes() = %v, want %v", names, want)
+	}
+	for _, tt := range []struct {
+		name             string
+		callees, callers []string
+	}{
+		{"sq", nil, []string{"norm"}},
+		{"norm", []string{"sq", "sqrt"}, []string{"total"}},
+		// f is a parameter, not a callee.
+		{"apply", nil, []string{"f.wabznasm"}},
+		{"fact", []string{"fact"}, []string{"fact", "total"}},
+		{"sqrt", nil, []string{"norm"}},
+	} {
+		if got := g.Callees(tt.name); !slices.Equal(got, tt.callees) {
+			t.Errorf("Callees(%s) = %v, want %v", tt.name, got, tt.callees)
+		}
+		if got := g.Callers(tt.name); !slices.Equal(got, tt.callers) {
+			t.Errorf("Callers(%s) = %v, want %v", tt.name, got, tt.callers)
+		}
+	}
+	if got, want := g.Affected("sq"), []string{"norm", "total"}; !slices.Equal(got, want) {
+		t.Errorf("Affected(sq) = %v, want %v", got, want)
+	}
+	if got, want := g.Affected("fact"), []string{"fact", "total"}; !slices.Equal(got, want) {
+		t.Errorf("Affected(fact) = %v, want %v", got, want)
+	}
+	if calls := g.Node("norm").Calls; len(calls) != 3 || calls[1].Callee != "sq" || calls[1].Pos.Column != 18 {
+		t.Errorf("norm calls = %+v", calls)
+	}
+}
+
+func TestRestrict(t *testing.T) {
+	g := build(t, "sq: {x*x}", "norm: {[a;b] sqrt[sq[a]+sq[b]]}", "total: norm[3;4]")
+	r := g.Restrict(append(g.Affected("sq"), "sq"))
+	if got := len(r.Nodes()); got != 3 {
+		t.Errorf("restricted graph has %d nodes, want 3", got)
+	}
+	if got, want := r.Callees("norm"), []string{"sq"}; !slices.Equal(got, want) {
+		t.Errorf("Callees(norm) = %v, want %v", got, want)
+	}
+	if r.Node("sqrt") != nil {
+		t.Error("restricted graph keeps sqrt")
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	g := build(t, "sq: {x*x}", "quad: {[a] sq[a]+sq[a+1]}")
+	var buf bytes.Buffer
+	if err := g.WriteDOT(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `digraph calls {
+	node [fontname="monospace"];
+	"sq" [shape=box, tooltip="a.wabznasm:1"];
+	"quad" [shape=box, tooltip="b.wabznasm:1"];
+	"quad" -> "sq" [label="2"];
+}
+`
+	if buf.String() != want {
+		t.Errorf("WriteDOT:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	g := build(t, "quad: {[a] sq[a]+sq[a+1]}")
+	var buf bytes.Buffer
+	if err := g.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Nodes []map[string]any `json:"nodes"`
+		Edges []map[string]any `json:"edges"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Nodes) != 2 || got.Nodes[1]["kind"] != "external" || got.Nodes[1]["start"] != nil {
+		t.Errorf("nodes = %v", got.Nodes)
+	}
+	if len(got.Edges) != 1 || got.Edges[0]["caller"] != "quad" || got.Edges[0]["calls"] != 2.0 {
+		t.Errorf("edges = %v", got.Edges)
+	}
+	if !strings.Contains(buf.String(), `"path": "a.wabznasm"`) {
+		t.Errorf("WriteJSON lacks the path:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,68 @@
+// Command bench-compare compares two outputs of go test -bench, such as
+// those of the benchmarks of package bench before and after a change, and
+// fails if a benchmark got slower, or allocates more, by more than the
+// threshold:
+//
+//	bench-compare [-threshold percent] [-units list] old.txt new.txt
+//
+// It prints the change of every benchmark measured in both, by the median
+// of its runs, and exits with status 1 if any regressed. Run the
+// benchmarks with -count 6 or more, so that one noisy run cannot fail the
+// check.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/bench"
+)
+
+func main() {
+	threshold := flag.Float64("threshold", 100*bench.DefaultThreshold, "fail on an increase of more than `percent`")
+	units := flag.String("units", strings.Join(bench.Units, ","), "compare the measurements in the comma-separated `list` of units")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: bench-compare [-threshold percent] [-units list] old.txt new.txt")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 || *threshold <= 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	old, err := read(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bench-compare:", err)
+		os.Exit(2)
+	}
+	new, err := read(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "bench-compare:", err)
+		os.Exit(2)
+	}
+	deltas := bench.Compare(old, new, bench.Options{Threshold: *threshold / 100, Units: strings.Split(*units, ",")})
+	if len(deltas) == 0 {
+		fmt.Fprintln(os.Stderr, "bench-compare: no benchmark is measured in both")
+		os.Exit(2)
+	}
+	bench.WriteTable(os.Stdout, deltas)
+	if r := bench.Regressions(deltas); len(r) > 0 {
+		fmt.Fprintf(os.Stderr, "bench-compare: %d regressions beyond %g%%\n", len(r), *threshold)
+		os.Exit(1)
+	}
+}
+
+func read(path string) (bench.Results, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r, err := bench.ParseResults(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return r, nil
+}
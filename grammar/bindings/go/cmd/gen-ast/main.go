@@ -0,0 +1,231 @@
+// Command gen-ast generates the typed node wrappers of package cst from the
+// grammar's node-types.json. It is run by go generate in the bindings
+// package:
+//
+//	gen-ast [-o file] [-pkg name] node-types.json
+//
+// Every named kind that is not an extra becomes a struct embedding
+// *tree_sitter.Node, with one method per field:
+//
+//   - a field holding one named kind returns that kind's type,
+//   - a field holding several named kinds returns cst.Node,
+//   - a field holding only literal tokens returns *tree_sitter.Node,
+//
+// and a slice of the same for fields that may repeat. Children outside any
+// field get a method named after their kind, or Content when there are
+// several kinds.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+type nodeType struct {
+	Type     string           `json:"type"`
+	Named    bool             `json:"named"`
+	Extra    bool             `json:"extra"`
+	Fields   map[string]child `json:"fields"`
+	Children *child           `json:"children"`
+	Subtypes []typeRef        `json:"subtypes"`
+}
+
+type child struct {
+	Multiple bool      `json:"multiple"`
+	Required bool      `json:"required"`
+	Types    []typeRef `json:"types"`
+}
+
+type typeRef struct {
+	Type  string `json:"type"`
+	Named bool   `json:"named"`
+}
+
+func main() {
+	out := flag.String("o", "", "output file; standard output if empty")
+	pkg := flag.String("pkg", "cst", "package name of the generated file")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gen-ast [-o file] [-pkg name] node-types.json")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	var types []nodeType
+	if err := json.Unmarshal(data, &types); err != nil {
+		fatal(fmt.Errorf("%s: %v", flag.Arg(0), err))
+	}
+	src, err := generate(*pkg, types)
+	if err != nil {
+		fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "gen-ast:", err)
+	os.Exit(1)
+}
+
+// reserved holds the method names of the embedded *tree_sitter.Node, which
+// generated methods must not shadow.
+var reserved = func() map[string]bool {
+	m := map[string]bool{"Raw": true}
+	t := reflect.TypeOf(&tree_sitter.Node{})
+	for i := 0; i < t.NumMethod(); i++ {
+		m[t.Method(i).Name] = true
+	}
+	return m
+}()
+
+func generate(pkg string, types []nodeType) ([]byte, error) {
+	var kinds []nodeType
+	for _, t := range types {
+		if t.Named && !t.Extra {
+			kinds = append(kinds, t)
+		}
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i].Type < kinds[j].Type })
+	typeNames := map[string]string{}
+	for _, t := range kinds {
+		name := camel(t.Type)
+		if reserved[name] || name == "Node" {
+			name += "Node"
+		}
+		typeNames[t.Type] = name
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen-ast from node-types.json; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	b.WriteString("import tree_sitter \"github.com/tree-sitter/go-tree-sitter\"\n\n")
+
+	b.WriteString("func wrap(n *tree_sitter.Node) Node {\n\tswitch n.Kind() {\n")
+	for _, t := range kinds {
+		fmt.Fprintf(&b, "\tcase %q:\n\t\treturn &%s{n}\n", t.Type, typeNames[t.Type])
+	}
+	b.WriteString("\t}\n\treturn nil\n}\n")
+
+	for _, t := range kinds {
+		name := typeNames[t.Type]
+		fmt.Fprintf(&b, "\n// %s is a node of kind %q.\ntype %s struct{ *tree_sitter.Node }\n\n", name, t.Type, name)
+		fmt.Fprintf(&b, "// Raw returns the wrapped node.\nfunc (n *%s) Raw() *tree_sitter.Node { return n.Node }\n\n", name)
+		fmt.Fprintf(&b, "func as%s(n *tree_sitter.Node) *%s {\n\tif n == nil || n.Kind() != %q || n.IsMissing() {\n\t\treturn nil\n\t}\n\treturn &%s{n}\n}\n", name, name, t.Type, name)
+
+		fields := make([]string, 0, len(t.Fields))
+		for f := range t.Fields {
+			fields = append(fields, f)
+		}
+		sort.Strings(fields)
+		methods := map[string]bool{}
+		for _, f := range fields {
+			method := camel(f)
+			if reserved[method] {
+				method += "Field"
+			}
+			methods[method] = true
+			if err := accessor(&b, typeNames, name, method, fmt.Sprintf("the %s field", f), t.Fields[f], fmt.Sprintf("%q", f)); err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", t.Type, f, err)
+			}
+		}
+		if c := t.Children; c != nil {
+			method := "Content"
+			if named := namedTypes(c.Types); len(named) == 1 {
+				method = typeNames[named[0]]
+			}
+			if reserved[method] || methods[method] {
+				method = "Content"
+			}
+			if err := accessor(&b, typeNames, name, method, "the child outside any field", *c, ""); err != nil {
+				return nil, fmt.Errorf("%s children: %v", t.Type, err)
+			}
+		}
+	}
+	return format.Source(b.Bytes())
+}
+
+// accessor writes a method returning the children described by c. An
+// empty field selects the children outside any field.
+func accessor(b *bytes.Buffer, typeNames map[string]string, recv, method, what string, c child, field string) error {
+	named := namedTypes(c.Types)
+	for _, k := range named {
+		if _, ok := typeNames[k]; !ok {
+			return fmt.Errorf("unknown kind %q", k)
+		}
+	}
+	var elem, conv string
+	switch len(named) {
+	case 0:
+		elem, conv = "*tree_sitter.Node", "%s"
+	case 1:
+		elem, conv = "*"+typeNames[named[0]], "as"+typeNames[named[0]]+"(%s)"
+	default:
+		elem, conv = "Node", "Wrap(%s)"
+	}
+	lookup := fmt.Sprintf("childrenByField(n.Node, %s)", field)
+	if field == "" {
+		lookup = "unfielded(n.Node)"
+	}
+	if c.Multiple {
+		what = strings.Replace(what, "the child", "the children", 1)
+		fmt.Fprintf(b, "\n// %s returns %s.\nfunc (n *%s) %s() []%s {\n", method, what, recv, method, elem)
+		if len(named) == 0 {
+			fmt.Fprintf(b, "\treturn %s\n}\n", lookup)
+			return nil
+		}
+		fmt.Fprintf(b, "\tvar out []%s\n\tfor _, c := range %s {\n", elem, lookup)
+		fmt.Fprintf(b, "\t\tif v := %s; v != nil {\n\t\t\tout = append(out, v)\n\t\t}\n\t}\n\treturn out\n}\n", fmt.Sprintf(conv, "c"))
+		return nil
+	}
+	single := fmt.Sprintf("n.ChildByFieldName(%s)", field)
+	if field == "" {
+		single = "first(unfielded(n.Node))"
+	}
+	fmt.Fprintf(b, "\n// %s returns %s, or nil.\nfunc (n *%s) %s() %s {\n", method, what, recv, method, elem)
+	if len(named) == 0 {
+		fmt.Fprintf(b, "\treturn %s\n}\n", single)
+		return nil
+	}
+	fmt.Fprintf(b, "\treturn %s\n}\n", fmt.Sprintf(conv, single))
+	return nil
+}
+
+func namedTypes(refs []typeRef) []string {
+	var out []string
+	for _, r := range refs {
+		if r.Named {
+			out = append(out, r.Type)
+		}
+	}
+	return out
+}
+
+func camel(s string) string {
+	var b strings.Builder
+	for _, part := range strings.Split(s, "_") {
+		if part != "" {
+			b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,173 @@
+// Command gen-grammar compiles the rules of the grammar's grammar.json
+// into a Go table of productions, for the gen package to walk. It is run
+// by go generate in that package:
+//
+//	gen-grammar [-o file] [-pkg name] grammar.json
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"strings"
+)
+
+// rule is a rule of grammar.json. Value is the text of a STRING or the
+// expression of a PATTERN; the precedences of PREC rules are not needed
+// to generate programs and are dropped.
+type rule struct {
+	Type    string          `json:"type"`
+	Name    string          `json:"name"`
+	Value   json.RawMessage `json:"value"`
+	Content *rule           `json:"content"`
+	Members []*rule         `json:"members"`
+}
+
+type grammar struct {
+	Name   string          `json:"name"`
+	Rules  json.RawMessage `json:"rules"`
+	Extras []*rule         `json:"extras"`
+}
+
+func main() {
+	out := flag.String("o", "", "output file; standard output if empty")
+	pkg := flag.String("pkg", "gen", "package name of the generated file")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gen-grammar [-o file] [-pkg name] grammar.json")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	var g grammar
+	if err := json.Unmarshal(data, &g); err != nil {
+		fatal(fmt.Errorf("%s: %v", flag.Arg(0), err))
+	}
+	names, rules, err := orderedRules(g.Rules)
+	if err != nil {
+		fatal(fmt.Errorf("%s: %v", flag.Arg(0), err))
+	}
+	src, err := generate(*pkg, names, rules, g.Extras)
+	if err != nil {
+		fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "gen-grammar:", err)
+	os.Exit(1)
+}
+
+// orderedRules decodes the rules object keeping the order of its keys,
+// since the first rule is the start symbol.
+func orderedRules(data json.RawMessage) ([]string, map[string]*rule, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("rules is not an object")
+	}
+	var names []string
+	rules := map[string]*rule{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		name := tok.(string)
+		var r rule
+		if err := dec.Decode(&r); err != nil {
+			return nil, nil, fmt.Errorf("rule %s: %v", name, err)
+		}
+		names = append(names, name)
+		rules[name] = &r
+	}
+	if len(names) == 0 {
+		return nil, nil, fmt.Errorf("no rules")
+	}
+	return names, rules, nil
+}
+
+func generate(pkg string, names []string, rules map[string]*rule, extras []*rule) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen-grammar from grammar.json; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	fmt.Fprintf(&b, "// start is the rule of the root node.\nconst start = %q\n\n", names[0])
+	b.WriteString("// rules are the productions of the grammar, by name.\nvar rules = map[string]*rule{\n")
+	for _, name := range names {
+		lit, err := literal(rules[name])
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %v", name, err)
+		}
+		fmt.Fprintf(&b, "%q: %s,\n", name, lit)
+	}
+	b.WriteString("}\n\n// extras may appear between any two tokens.\nvar extras = []*rule{\n")
+	for _, r := range extras {
+		lit, err := literal(r)
+		if err != nil {
+			return nil, fmt.Errorf("extras: %v", err)
+		}
+		fmt.Fprintf(&b, "%s,\n", lit)
+	}
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
+
+// known are the rule types of grammar.json.
+var known = map[string]bool{
+	"ALIAS": true, "BLANK": true, "CHOICE": true, "FIELD": true, "IMMEDIATE_TOKEN": true,
+	"PATTERN": true, "PREC": true, "PREC_DYNAMIC": true, "PREC_LEFT": true, "PREC_RIGHT": true,
+	"REPEAT": true, "REPEAT1": true, "RESERVED": true, "SEQ": true, "STRING": true,
+	"SYMBOL": true, "TOKEN": true,
+}
+
+// literal returns the Go composite literal of r, without its type, as
+// an element of a map or slice of *rule.
+func literal(r *rule) (string, error) {
+	if !known[r.Type] {
+		return "", fmt.Errorf("unknown rule type %q", r.Type)
+	}
+	parts := []string{fmt.Sprintf("typ: %q", r.Type)}
+	if r.Name != "" {
+		parts = append(parts, fmt.Sprintf("name: %q", r.Name))
+	}
+	if r.Type == "STRING" || r.Type == "PATTERN" {
+		var v string
+		if err := json.Unmarshal(r.Value, &v); err != nil {
+			return "", fmt.Errorf("%s value: %v", r.Type, err)
+		}
+		parts = append(parts, fmt.Sprintf("value: %q", v))
+	}
+	if r.Content != nil {
+		lit, err := literal(r.Content)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, "content: &rule"+lit)
+	}
+	if len(r.Members) > 0 {
+		members := make([]string, len(r.Members))
+		for i, m := range r.Members {
+			lit, err := literal(m)
+			if err != nil {
+				return "", err
+			}
+			members[i] = lit
+		}
+		parts = append(parts, "members: []*rule{\n"+strings.Join(members, ",\n")+",\n}")
+	}
+	return "{" + strings.Join(parts, ", ") + "}", nil
+}
@@ -0,0 +1,237 @@
+// Command gen-nodetypes compiles the grammar's node-types.json into Go
+// constants for every node kind and field, and a table describing each
+// kind. It is run by go generate in the bindings package:
+//
+//	gen-nodetypes [-o file] [-pkg name] node-types.json
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+type nodeType struct {
+	Type     string           `json:"type"`
+	Named    bool             `json:"named"`
+	Extra    bool             `json:"extra"`
+	Root     bool             `json:"root"`
+	Fields   map[string]child `json:"fields"`
+	Children *child           `json:"children"`
+	Subtypes []typeRef        `json:"subtypes"`
+}
+
+type child struct {
+	Multiple bool      `json:"multiple"`
+	Required bool      `json:"required"`
+	Types    []typeRef `json:"types"`
+}
+
+type typeRef struct {
+	Type  string `json:"type"`
+	Named bool   `json:"named"`
+}
+
+// punctuation names the anonymous tokens that are not words.
+var punctuation = map[string]string{
+	"!": "Bang", "%": "Percent", "(": "LParen", ")": "RParen", "*": "Star",
+	"+": "Plus", "-": "Minus", "/": "Slash", ":": "Colon", ";": "Semicolon",
+	"[": "LBracket", "]": "RBracket", "^": "Caret", "{": "LBrace", "}": "RBrace",
+	",": "Comma", ".": "Dot", "=": "Equal", "<": "Less", ">": "Greater",
+	"&": "Amp", "|": "Pipe", "~": "Tilde", "@": "At", "#": "Hash", "$": "Dollar",
+	"?": "Question", "'": "Quote", "\"": "DoubleQuote", "`": "Backquote", "\\": "Backslash",
+}
+
+func main() {
+	out := flag.String("o", "", "output file; standard output if empty")
+	pkg := flag.String("pkg", "tree_sitter_wabznasm", "package name of the generated file")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: gen-nodetypes [-o file] [-pkg name] node-types.json")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	var types []nodeType
+	if err := json.Unmarshal(data, &types); err != nil {
+		fatal(fmt.Errorf("%s: %v", flag.Arg(0), err))
+	}
+	src, err := generate(*pkg, types)
+	if err != nil {
+		fatal(err)
+	}
+	if *out == "" {
+		os.Stdout.Write(src)
+		return
+	}
+	if err := os.WriteFile(*out, src, 0o644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "gen-nodetypes:", err)
+	os.Exit(1)
+}
+
+func generate(pkg string, types []nodeType) ([]byte, error) {
+	sort.SliceStable(types, func(i, j int) bool {
+		if types[i].Named != types[j].Named {
+			return types[i].Named
+		}
+		return types[i].Type < types[j].Type
+	})
+	kindNames := map[typeRef]string{}
+	used := map[string]string{}
+	for _, t := range types {
+		name, err := constName(t.Type, t.Named)
+		if err != nil {
+			return nil, err
+		}
+		if prev, ok := used[name]; ok {
+			return nil, fmt.Errorf("kinds %q and %q both map to %s", prev, t.Type, name)
+		}
+		used[name] = t.Type
+		kindNames[typeRef{t.Type, t.Named}] = "Kind" + name
+	}
+	fieldSet := map[string]bool{}
+	for _, t := range types {
+		for f := range t.Fields {
+			fieldSet[f] = true
+		}
+	}
+	fields := make([]string, 0, len(fieldSet))
+	for f := range fieldSet {
+		fields = append(fields, f)
+	}
+	sort.Strings(fields)
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by gen-nodetypes from node-types.json; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	b.WriteString("// Node kinds, as returned by Node.Kind.\nconst (\n")
+	for _, t := range types {
+		fmt.Fprintf(&b, "\t%s = %q\n", kindNames[typeRef{t.Type, t.Named}], t.Type)
+	}
+	b.WriteString(")\n\n// Field names, as returned by Node.FieldNameForChild.\nconst (\n")
+	for _, f := range fields {
+		name, err := constName(f, true)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "\tField%s = %q\n", name, f)
+	}
+	b.WriteString(")\n\n// Field ids, as used by Node.ChildByFieldId. tree-sitter numbers fields\n// from one in name order.\nconst (\n")
+	for i, f := range fields {
+		name, _ := constName(f, true)
+		fmt.Fprintf(&b, "\tFieldID%s uint16 = %d\n", name, i+1)
+	}
+	b.WriteString(")\n\nvar nodeKinds = []KindInfo{\n")
+	ref := func(r typeRef) string {
+		if name, ok := kindNames[r]; ok {
+			return name
+		}
+		return fmt.Sprintf("%q", r.Type)
+	}
+	refs := func(rs []typeRef) string {
+		names := make([]string, len(rs))
+		for i, r := range rs {
+			names[i] = ref(r)
+		}
+		return "[]string{" + strings.Join(names, ", ") + "}"
+	}
+	childInfo := func(name string, c child) string {
+		s := "{"
+		if name != "" {
+			fn, _ := constName(name, true)
+			s += "Name: Field" + fn + ", "
+		}
+		if c.Multiple {
+			s += "Multiple: true, "
+		}
+		if c.Required {
+			s += "Required: true, "
+		}
+		return s + "Types: " + refs(c.Types) + "}"
+	}
+	for _, t := range types {
+		fmt.Fprintf(&b, "\t{Kind: %s", ref(typeRef{t.Type, t.Named}))
+		for _, flag := range []struct {
+			name string
+			set  bool
+		}{{"Named", t.Named}, {"Extra", t.Extra}, {"Root", t.Root}, {"Supertype", len(t.Subtypes) > 0}} {
+			if flag.set {
+				fmt.Fprintf(&b, ", %s: true", flag.name)
+			}
+		}
+		if len(t.Subtypes) > 0 {
+			fmt.Fprintf(&b, ", Subtypes: %s", refs(t.Subtypes))
+		}
+		if len(t.Fields) > 0 {
+			names := make([]string, 0, len(t.Fields))
+			for f := range t.Fields {
+				names = append(names, f)
+			}
+			sort.Strings(names)
+			b.WriteString(", Fields: []FieldInfo{\n")
+			for _, f := range names {
+				fmt.Fprintf(&b, "\t\t%s,\n", childInfo(f, t.Fields[f]))
+			}
+			b.WriteString("\t}")
+		}
+		if t.Children != nil {
+			fmt.Fprintf(&b, ", Children: &FieldInfo%s", childInfo("", *t.Children))
+		}
+		b.WriteString("},\n")
+	}
+	b.WriteString("}\n")
+	return format.Source(b.Bytes())
+}
+
+// constName turns a kind or field into the suffix of its Go constant:
+// snake_case words are joined in CamelCase and punctuation is spelled out.
+func constName(s string, named bool) (string, error) {
+	if isWord(s) {
+		var b strings.Builder
+		for _, part := range strings.Split(s, "_") {
+			if part == "" {
+				continue
+			}
+			r := []rune(part)
+			b.WriteString(string(unicode.ToUpper(r[0])) + string(r[1:]))
+		}
+		if !named {
+			b.WriteString("Token")
+		}
+		return b.String(), nil
+	}
+	var b strings.Builder
+	for _, r := range s {
+		name, ok := punctuation[string(r)]
+		if !ok {
+			return "", fmt.Errorf("no Go name for %q in kind %q", r, s)
+		}
+		b.WriteString(name)
+	}
+	return b.String(), nil
+}
+
+func isWord(s string) bool {
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return s != "" && strings.Trim(s, "_") != ""
+}
@@ -0,0 +1,18 @@
+// Command wabznasm-dap is a debug adapter for wabznasm scripts. It speaks
+// the Debug Adapter Protocol over stdin and stdout, running the script
+// named by the client's launch request under the evaluator.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/dap"
+)
+
+func main() {
+	if err := dap.NewServer(os.Stdin, os.Stdout).Run(); err != nil {
+		fmt.Fprintln(os.Stderr, "wabznasm-dap:", err)
+		os.Exit(1)
+	}
+}
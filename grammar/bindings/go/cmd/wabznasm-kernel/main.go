@@ -0,0 +1,80 @@
+// Command wabznasm-kernel is a Jupyter kernel for wabznasm.
+//
+// Jupyter starts it with the path of a connection file:
+//
+//	wabznasm-kernel -f /path/to/connection.json
+//
+// To make the kernel available to Jupyter, install its kernel spec into a
+// kernels directory, such as ~/.local/share/jupyter/kernels/wabznasm:
+//
+//	wabznasm-kernel -install ~/.local/share/jupyter/kernels/wabznasm
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/jupyter"
+)
+
+func main() {
+	connFile := flag.String("f", "", "read the connection `file` Jupyter wrote")
+	install := flag.String("install", "", "write the kernel spec into `dir` and exit")
+	flag.Parse()
+	var err error
+	switch {
+	case *install != "":
+		err = installSpec(*install)
+	case *connFile != "":
+		err = run(*connFile)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "wabznasm-kernel:", err)
+		os.Exit(1)
+	}
+}
+
+func run(path string) error {
+	info, err := jupyter.ReadConnectionFile(path)
+	if err != nil {
+		return err
+	}
+	k, err := jupyter.NewKernel(info)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+	// Jupyter interrupts kernels with SIGINT by default; interrupts arrive
+	// on the control socket too, so the signal is only kept from killing
+	// the kernel.
+	signal.Ignore(os.Interrupt)
+	return k.Run(context.Background())
+}
+
+func installSpec(dir string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	spec, err := json.MarshalIndent(map[string]any{
+		"argv":           []string{exe, "-f", "{connection_file}"},
+		"display_name":   "wabznasm",
+		"language":       "wabznasm",
+		"interrupt_mode": "message",
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "kernel.json"), append(spec, '\n'), 0o644)
+}
@@ -0,0 +1,73 @@
+// Command wabznasm-lsp is a language server for wabznasm. It speaks the
+// Language Server Protocol over stdin and stdout.
+//
+// Usage:
+//
+//	wabznasm-lsp [-log-level level] [-log-json] [-otlp url] [-crash-dir dir] [-crash-source] [-grammar file]
+//
+// Failed requests are logged to standard error at warn level; -log-level
+// debug adds the time spent handling each message. With -otlp, or the
+// OTEL_EXPORTER_OTLP_ENDPOINT variable of OpenTelemetry, the same spans
+// are exported to a collector.
+//
+// A message that panics is answered with an internal error and leaves a
+// diagnostic bundle in -crash-dir, with the source of the document only if
+// -crash-source is set.
+//
+// With -grammar, or the WABZNASM_GRAMMAR variable, the server parses with
+// the grammar compiled into a shared object instead of its own, and loads
+// it again, reparsing the open documents, whenever the file changes.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/crash"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lsp"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry/otlp"
+)
+
+func main() {
+	level := flag.String("log-level", "warn", "log records at `level` and above: debug, info, warn or error")
+	logJSON := flag.Bool("log-json", false, "log JSON lines instead of text")
+	otlpURL := flag.String("otlp", "", "export spans to the OTLP/HTTP traces endpoint at `url`")
+	crashDir := flag.String("crash-dir", crash.DefaultDir(), "write the diagnostic bundles of requests that panic to `dir`")
+	crashSource := flag.Bool("crash-source", false, "include the source of a request that panics in its bundle")
+	grammar := flag.String("grammar", os.Getenv("WABZNASM_GRAMMAR"), "parse with the grammar in the shared object `file`, reloading it when it changes")
+	flag.Parse()
+
+	logger, err := telemetry.NewLogger(os.Stderr, *level, *logJSON)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "wabznasm-lsp:", err)
+		os.Exit(2)
+	}
+	telemetry.SetDefault(logger)
+	var exp *otlp.Exporter
+	if *otlpURL != "" {
+		exp = otlp.New(*otlpURL, "wabznasm-lsp")
+	} else {
+		exp = otlp.FromEnv("wabznasm-lsp")
+	}
+	if exp != nil {
+		telemetry.SetExporter(exp)
+	}
+	srv := lsp.NewServer(os.Stdin, os.Stdout)
+	srv.SetCrashReporter(&crash.Reporter{Dir: *crashDir, IncludeSource: *crashSource})
+	if *grammar != "" {
+		if err := srv.SetGrammar(*grammar); err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm-lsp:", err)
+			os.Exit(1)
+		}
+	}
+	err = srv.Run()
+	if exp != nil {
+		exp.Close()
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "wabznasm-lsp:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,134 @@
+// Command wabznasm-server serves the wabznasm parser, formatter, linter and
+// highlighter over HTTP. See package server for the endpoints.
+//
+// Usage:
+//
+//	wabznasm-server [-addr :8080] [-grpc address] [-max-bytes n] [-timeout d]
+//	                [-allow-origin o] [-log-level level] [-log-json] [-otlp url]
+//	                [-crash-dir dir] [-crash-source]
+//
+// With -grpc it also serves the gRPC service of package grpc, parsing,
+// analyzing, formatting and evaluating source, at address, over HTTP/2 in
+// clear text.
+//
+// Requests are logged to standard error at info level; -log-level debug
+// adds the time spent in each phase of a request. With -otlp, or the
+// OTEL_EXPORTER_OTLP_ENDPOINT variable of OpenTelemetry, the same spans
+// are exported to a collector.
+//
+// A request that panics is answered with a 500 and leaves a diagnostic
+// bundle in -crash-dir, with the source of the request only if
+// -crash-source is set.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/crash"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/grpc"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/server"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry/otlp"
+)
+
+func main() {
+	addr := flag.String("addr", ":8080", "listen on `address`")
+	grpcAddr := flag.String("grpc", "", "also serve gRPC on `address`")
+	maxBytes := flag.Int64("max-bytes", server.DefaultMaxRequestBytes, "reject request bodies over `n` bytes")
+	timeout := flag.Duration("timeout", server.DefaultTimeout, "abandon requests that take longer than `d`")
+	origin := flag.String("allow-origin", "", "allow cross-origin requests from `origin`, or * for any")
+	level := flag.String("log-level", "info", "log records at `level` and above: debug, info, warn or error")
+	logJSON := flag.Bool("log-json", false, "log JSON lines instead of text")
+	otlpURL := flag.String("otlp", "", "export spans to the OTLP/HTTP traces endpoint at `url`")
+	crashDir := flag.String("crash-dir", crash.DefaultDir(), "write the diagnostic bundles of requests that panic to `dir`")
+	crashSource := flag.Bool("crash-source", false, "include the source of a request that panics in its bundle")
+	flag.Parse()
+
+	logger, err := telemetry.NewLogger(os.Stderr, *level, *logJSON)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "wabznasm-server:", err)
+		os.Exit(2)
+	}
+	telemetry.SetDefault(logger)
+	var exp *otlp.Exporter
+	if *otlpURL != "" {
+		exp = otlp.New(*otlpURL, "wabznasm-server")
+	} else {
+		exp = otlp.FromEnv("wabznasm-server")
+	}
+	if exp != nil {
+		telemetry.SetExporter(exp)
+		defer exp.Close()
+	}
+
+	h := server.New(server.Options{
+		MaxRequestBytes: *maxBytes,
+		Timeout:         *timeout,
+		AllowOrigin:     *origin,
+		Crash:           &crash.Reporter{Dir: *crashDir, IncludeSource: *crashSource},
+	})
+	defer h.Close()
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           h,
+		ReadHeaderTimeout: 5 * time.Second,
+		// Leave room for reading the body and writing the response around
+		// the work itself.
+		ReadTimeout:  *timeout + 5*time.Second,
+		WriteTimeout: *timeout + 10*time.Second,
+		IdleTimeout:  time.Minute,
+	}
+
+	servers := []*http.Server{srv}
+	if *grpcAddr != "" {
+		g := grpc.New(grpc.Options{
+			MaxMessageBytes: int(*maxBytes),
+			Timeout:         *timeout,
+			Crash:           &crash.Reporter{Dir: *crashDir, IncludeSource: *crashSource},
+		})
+		defer g.Close()
+		var protocols http.Protocols
+		protocols.SetHTTP1(true)
+		protocols.SetUnencryptedHTTP2(true)
+		// Streams of one connection share it, so there are no read and
+		// write timeouts; the calls time out themselves.
+		servers = append(servers, &http.Server{
+			Addr:              *grpcAddr,
+			Handler:           g,
+			Protocols:         &protocols,
+			ReadHeaderTimeout: 5 * time.Second,
+			IdleTimeout:       time.Minute,
+		})
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), *timeout)
+		defer cancel()
+		for _, srv := range servers {
+			srv.Shutdown(shutdown)
+		}
+	}()
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		logger.Info("listening", slog.String("addr", srv.Addr))
+		go func() { errs <- srv.ListenAndServe() }()
+	}
+	for range servers {
+		if err := <-errs; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("serving", slog.String("error", err.Error()))
+			os.Exit(1)
+		}
+	}
+}
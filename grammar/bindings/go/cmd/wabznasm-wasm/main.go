@@ -0,0 +1,29 @@
+//go:build js && wasm
+
+// Command wabznasm-wasm is the wabznasm tooling built for the browser. It
+// publishes the API of package playground as the global wabznasm and
+// keeps running to serve it.
+//
+// Build it, and the wasm grammar it parses with, with:
+//
+//	GOOS=js GOARCH=wasm go build -o wabznasm.wasm ./bindings/go/cmd/wabznasm-wasm
+//	tree-sitter build --wasm
+//
+// and load it beside web-tree-sitter and Go's wasm_exec.js:
+//
+//	import * as TreeSitter from 'web-tree-sitter';
+//	await TreeSitter.Parser.init();
+//	const language = await TreeSitter.Language.load('tree-sitter-wabznasm.wasm');
+//	const go = new Go();
+//	const { instance } = await WebAssembly.instantiateStreaming(fetch('wabznasm.wasm'), go.importObject);
+//	go.run(instance);
+//	wabznasm.init(TreeSitter, language);
+//	wabznasm.format('x : 1 + 2').source; // "x: 1+2\n"
+package main
+
+import "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/playground"
+
+func main() {
+	playground.Export("wabznasm")
+	select {}
+}
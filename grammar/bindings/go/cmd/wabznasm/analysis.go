@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/cache"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+)
+
+// analysis is what lint and check compute for a source, as cached between
+// runs.
+type analysis struct {
+	Diagnostics []tree_sitter_wabznasm.Diagnostic `json:"diagnostics"`
+	Findings    []lint.Finding                    `json:"findings"`
+	// Formatted is a source without syntax errors as formatted in the
+	// style it was analysed with.
+	Formatted []byte `json:"formatted"`
+}
+
+// openCache opens the default cache. Commands run without one if it
+// cannot be opened: a cache only saves time.
+func openCache() *cache.Cache {
+	c, err := cache.Default()
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// analyze parses src a statement at a time, runs rules on it and formats
+// it in the style of cfg, or returns the results of an earlier run on the
+// same source with the same rules and style.
+func analyze(c *cache.Cache, parser *tree_sitter_wabznasm.Parser, cfg format.Config, src []byte, rules []lint.Rule) (a analysis, err error) {
+	ctx, span := telemetry.Start(context.Background(), "analyze", slog.Int("bytes", len(src)))
+	defer func() { span.End(err) }()
+	if rules == nil {
+		rules = lint.Rules()
+	}
+	names := make([]string, len(rules))
+	for i, r := range rules {
+		names[i] = r.Name()
+	}
+	key := cache.NewKey(src, []byte(strings.Join(names, ",")), fmt.Appendf(nil, "%+v", cfg))
+	if c.Get("analysis", key, &a) {
+		span.SetAttrs(slog.Bool("cached", true))
+		return a, nil
+	}
+	script, err := parser.ParseScriptContext(ctx, src)
+	if err != nil {
+		return a, err
+	}
+	defer script.Close()
+	a.Diagnostics = script.Diagnostics()
+	_, lintSpan := telemetry.Start(ctx, "lint")
+	a.Findings = lint.RunScript(script, rules...)
+	lintSpan.End(nil)
+	if len(a.Diagnostics) == 0 {
+		_, formatSpan := telemetry.Start(ctx, "format")
+		a.Formatted, err = cfg.Script(src)
+		formatSpan.End(err)
+		if err != nil {
+			return a, err
+		}
+	}
+	c.Put("analysis", key, a)
+	return a, nil
+}
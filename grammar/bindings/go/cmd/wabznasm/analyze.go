@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	passes "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/analysis"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/report"
+)
+
+var analyzeCommand = &command{
+	summary:  "run the analysis passes over a project",
+	synopsis: "[-passes a,b] [dir]",
+	help: "Runs the analysis passes, syntax, types, lint, metrics and deadcode, over the\n" +
+		"project in dir, each parsing a file once, and exits with status 1 if they report\n" +
+		"anything. The wabznasm.yaml files of the project enable and disable passes\n" +
+		"and lint rules for their directories.",
+	setup: setupAnalyze,
+	dirs:  true,
+}
+
+func setupAnalyze(fset *flag.FlagSet) func() int {
+	list := fset.String("passes", "", "comma-separated passes to run instead of all")
+	return func() int {
+		root := "."
+		switch fset.NArg() {
+		case 0:
+		case 1:
+			root = fset.Arg(0)
+		default:
+			fset.Usage()
+			return 2
+		}
+
+		run := passes.Passes()
+		if *list != "" {
+			run = nil
+			for _, name := range strings.Split(*list, ",") {
+				p, ok := passes.Lookup(strings.TrimSpace(name))
+				if !ok {
+					fmt.Fprintf(os.Stderr, "wabznasm analyze: unknown pass %q\n", name)
+					return 2
+				}
+				run = append(run, p)
+			}
+		}
+		p, err := project.Open(root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm analyze:", err)
+			return 1
+		}
+		res, err := passes.Run(context.Background(), p, run...)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm analyze:", err)
+			return 1
+		}
+		status := 0
+		for _, err := range res.Errors {
+			fmt.Fprintln(os.Stderr, "wabznasm analyze:", err)
+			status = 1
+		}
+		printer := report.New(os.Stdout, report.ColorEnabled(os.Stdout))
+		for _, d := range res.Diagnostics {
+			status = 1
+			printer.Print(d.Path, p.File(d.Path).Source, report.Message{
+				Range:    d.Range,
+				Severity: d.Severity,
+				Code:     d.Pass + "/" + d.Code,
+				Text:     d.Message,
+			})
+		}
+		return status
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/anonymize"
+)
+
+var anonymizeCommand = &command{
+	summary:  "rename identifiers and drop comments, for sharing source",
+	synopsis: "[-keep names] [-map file] [path ...]",
+	help: "Writes the sources, anonymized, to standard output, so that a program that fails can be\n" +
+		"shared in a bug report without its formulas. Builtins, implicit parameters, numbers and\n" +
+		"the shape of the code are kept; names are renamed the same way in every file given.",
+	setup: setupAnonymize,
+}
+
+func setupAnonymize(fset *flag.FlagSet) func() int {
+	keep := fset.String("keep", "", "comma-separated `names` to keep as well as the builtins")
+	mapFile := fset.String("map", "", "write the new name of each renamed name to `file`, as JSON")
+	return func() int {
+		var opts anonymize.Options
+		if *keep != "" {
+			opts.Keep = strings.Split(*keep, ",")
+		}
+		a := anonymize.New(opts)
+		paths := fset.Args()
+		if len(paths) == 0 {
+			paths = []string{"-"}
+		}
+		for _, path := range paths {
+			var src []byte
+			var err error
+			if path == "-" {
+				src, err = io.ReadAll(os.Stdin)
+			} else {
+				src, err = os.ReadFile(path)
+			}
+			if err == nil {
+				src, err = a.Source(src)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "wabznasm anonymize: %s: %v\n", path, err)
+				return 1
+			}
+			os.Stdout.Write(src)
+		}
+		if *mapFile != "" {
+			data, _ := json.MarshalIndent(a.Names(), "", "  ")
+			if err := os.WriteFile(*mapFile, append(data, '\n'), 0o600); err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm anonymize:", err)
+				return 1
+			}
+		}
+		return 0
+	}
+}
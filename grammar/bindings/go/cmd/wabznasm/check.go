@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/diff"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/report"
+)
+
+// hookName is the name under which the binary runs as a git hook: linked
+// or copied to .git/hooks/pre-commit it checks what is being committed.
+const hookName = "pre-commit"
+
+// checkFile is a source to check and where its contents came from.
+type checkFile struct {
+	path string
+	src  []byte
+}
+
+var checkCommand = &command{
+	summary:  "check that sources parse, pass lint and are formatted",
+	synopsis: "[-staged] [-rules a,b] [path ...]",
+	help: "Checks that sources parse, pass lint and are formatted as fmt formats\n" +
+		"them, in the style of the nearest .wabznasmfmt, printing a diff for each\n" +
+		"file that is not. With -staged, paths limit the staged files\n" +
+		"checked, which default to all. To run it as a pre-commit hook, link the\n" +
+		"wabznasm binary to .git/hooks/pre-commit.",
+	setup: setupCheck,
+}
+
+func setupCheck(fset *flag.FlagSet) func() int {
+	staged := fset.Bool("staged", false, "check the contents staged in git instead of the working tree")
+	only := fset.String("rules", "", "comma-separated lint rules to run; default all")
+	return func() int {
+		rules, err := selectRules(*only)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm check:", err)
+			return 2
+		}
+
+		status := 0
+		var files []checkFile
+		if *staged {
+			if files, err = stagedFiles(fset.Args()); err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm check:", err)
+				return 1
+			}
+		} else {
+			if fset.NArg() == 0 {
+				fset.Usage()
+				return 2
+			}
+			for _, path := range sourceFiles(fset.Args(), &status) {
+				src, err := os.ReadFile(path)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					status = 1
+					continue
+				}
+				files = append(files, checkFile{path, src})
+			}
+		}
+
+		parser, err := tree_sitter_wabznasm.NewParser()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm check:", err)
+			return 1
+		}
+		defer parser.Close()
+		printer := report.New(os.Stdout, report.ColorEnabled(os.Stdout))
+		c := openCache()
+		for _, f := range files {
+			cfg, _, err := format.ConfigFor(f.path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+				continue
+			}
+			a, err := analyze(c, parser, cfg, f.src, rules)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", f.path, err)
+				status = 1
+				continue
+			}
+			if len(a.Diagnostics) > 0 {
+				// Lint and format results on a broken tree would only repeat
+				// the syntax errors.
+				for _, d := range a.Diagnostics {
+					printer.Print(f.path, f.src, report.FromDiagnostic(d))
+				}
+				status = 1
+				continue
+			}
+			for _, finding := range a.Findings {
+				printer.Print(f.path, f.src, report.FromFinding(finding))
+			}
+			if len(a.Findings) > 0 {
+				status = 1
+			}
+			if d := diff.Unified("a/"+f.path, "b/"+f.path, string(f.src), string(a.Formatted)); d != "" {
+				fmt.Print(d)
+				status = 1
+			}
+		}
+		if status != 0 && *staged {
+			fmt.Fprintln(os.Stderr, "wabznasm check: fix the problems above, or format with wabznasm fmt -w, and stage the result")
+		}
+		return status
+	}
+}
+
+// stagedFiles returns the wabznasm sources added, copied, modified or
+// renamed in the git index, limited to pathspecs if any, with the contents
+// staged for them. Paths are relative to the current directory.
+func stagedFiles(pathspecs []string) ([]checkFile, error) {
+	top, err := git("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(top))
+	names, err := git("", append([]string{"diff", "--cached", "--name-only", "--diff-filter=ACMR", "-z", "--"}, pathspecs...)...)
+	if err != nil {
+		return nil, err
+	}
+	wd, _ := os.Getwd()
+	var files []checkFile
+	for _, name := range strings.Split(string(names), "\x00") {
+		if name == "" || !project.IsSource(name) {
+			continue
+		}
+		// Ask for the index entry by its path from the top of the tree,
+		// which is how diff names it.
+		src, err := git(root, "show", ":"+name)
+		if err != nil {
+			return nil, err
+		}
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if rel, err := filepath.Rel(wd, path); err == nil {
+			path = rel
+		}
+		files = append(files, checkFile{path, src})
+	}
+	return files, nil
+}
+
+// git runs git with args in dir, or the current directory if dir is
+// empty, and returns its output.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
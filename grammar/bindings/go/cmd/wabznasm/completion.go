@@ -0,0 +1,230 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var completionCommand = &command{
+	summary:  "write a shell completion script for bash, zsh or fish",
+	synopsis: "bash|zsh|fish",
+	help: "Writes a script completing the commands, flags and arguments of wabznasm\n" +
+		"to standard output. For bash or zsh, add to ~/.bashrc or ~/.zshrc\n" +
+		"  source <(wabznasm completion bash)\n" +
+		"naming the shell; for fish, run\n" +
+		"  wabznasm completion fish > ~/.config/fish/completions/wabznasm.fish",
+	setup: setupCompletion,
+	words: func() []string { return []string{"bash", "fish", "zsh"} },
+}
+
+func setupCompletion(fset *flag.FlagSet) func() int {
+	return func() int {
+		if fset.NArg() != 1 {
+			fset.Usage()
+			return 2
+		}
+		switch fset.Arg(0) {
+		case "bash":
+			writeBash(os.Stdout)
+		case "zsh":
+			writeZsh(os.Stdout)
+		case "fish":
+			writeFish(os.Stdout)
+		default:
+			fmt.Fprintf(os.Stderr, "wabznasm completion: unknown shell %q\n", fset.Arg(0))
+			return 2
+		}
+		return 0
+	}
+}
+
+// quote quotes s for bash and zsh.
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes s for fish.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(s) + "'"
+}
+
+// valueFlags returns the flags among fs taking a value named value, or any
+// value if value is empty, as -name words.
+func valueFlags(fs []flagInfo, value string) []string {
+	var out []string
+	for _, f := range fs {
+		if f.value != "" && (value == "" || f.value == value) {
+			out = append(out, "-"+f.name)
+		}
+	}
+	return out
+}
+
+func writeBash(w io.Writer) {
+	fmt.Fprintln(w, "# bash completion for wabznasm, generated by wabznasm completion bash.")
+	fmt.Fprintln(w, "_wabznasm() {")
+	fmt.Fprintln(w, "\tlocal cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]}")
+	fmt.Fprintln(w, "\tif ((COMP_CWORD == 1)); then")
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n", quote(strings.Join(commandNames(), " ")))
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "\tlocal flags= values= words= args=-f")
+	fmt.Fprintln(w, "\tcase ${COMP_WORDS[1]} in")
+	for _, name := range commandNames() {
+		c := commands[name]
+		fs := c.flags(name)
+		fmt.Fprintf(w, "\t%s)\n", name)
+		if values := valueFlags(fs, ""); len(values) > 0 {
+			fmt.Fprintln(w, "\t\tcase $prev in")
+			if files := valueFlags(fs, "file"); len(files) > 0 {
+				fmt.Fprintf(w, "\t\t%s) COMPREPLY=($(compgen -f -- \"$cur\")); return ;;\n", strings.Join(files, "|"))
+			}
+			if dirs := valueFlags(fs, "dir"); len(dirs) > 0 {
+				fmt.Fprintf(w, "\t\t%s) COMPREPLY=($(compgen -d -- \"$cur\")); return ;;\n", strings.Join(dirs, "|"))
+			}
+			fmt.Fprintf(w, "\t\t%s) return ;;\n", strings.Join(values, "|"))
+			fmt.Fprintln(w, "\t\tesac")
+			fmt.Fprintf(w, "\t\tvalues=%s\n", quote(strings.Join(values, " ")))
+		}
+		var names []string
+		for _, f := range fs {
+			names = append(names, "-"+f.name)
+		}
+		if len(names) > 0 {
+			fmt.Fprintf(w, "\t\tflags=%s\n", quote(strings.Join(names, " ")))
+		}
+		if c.words != nil {
+			fmt.Fprintf(w, "\t\twords=%s\n", quote(strings.Join(c.words(), " ")))
+		}
+		if c.dirs {
+			fmt.Fprintln(w, "\t\targs=-d")
+		}
+		fmt.Fprintln(w, "\t\t;;")
+	}
+	fmt.Fprintln(w, "\tesac")
+	// The words are offered only for the first argument, after the flags
+	// and their values.
+	fmt.Fprintln(w, "\tlocal i")
+	fmt.Fprintln(w, "\tfor ((i = 2; i < COMP_CWORD; i++)); do")
+	fmt.Fprintln(w, "\t\tif [[ \" $values \" == *\" ${COMP_WORDS[i]} \"* ]]; then")
+	fmt.Fprintln(w, "\t\t\t((i++))")
+	fmt.Fprintln(w, "\t\telif [[ ${COMP_WORDS[i]} != -* ]]; then")
+	fmt.Fprintln(w, "\t\t\twords=")
+	fmt.Fprintln(w, "\t\t\tbreak")
+	fmt.Fprintln(w, "\t\tfi")
+	fmt.Fprintln(w, "\tdone")
+	fmt.Fprintln(w, "\tif [[ $cur == -* ]]; then")
+	fmt.Fprintln(w, "\t\tCOMPREPLY=($(compgen -W \"$flags\" -- \"$cur\"))")
+	fmt.Fprintln(w, "\telif [[ -n $words ]]; then")
+	fmt.Fprintln(w, "\t\tCOMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))")
+	fmt.Fprintln(w, "\telse")
+	fmt.Fprintln(w, "\t\tCOMPREPLY=($(compgen $args -- \"$cur\"))")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w, "complete -o filenames -F _wabznasm wabznasm")
+}
+
+// zshDescription escapes s for the brackets of an _arguments spec.
+func zshDescription(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "[", `\[`, "]", `\]`, ":", `\:`).Replace(s)
+}
+
+// zshAction returns the _arguments action completing a value named value.
+func zshAction(value string) string {
+	switch value {
+	case "file":
+		return "_files"
+	case "dir":
+		return "_files -/"
+	}
+	return " "
+}
+
+func writeZsh(w io.Writer) {
+	fmt.Fprintln(w, "#compdef wabznasm")
+	fmt.Fprintln(w, "# zsh completion for wabznasm, generated by wabznasm completion zsh.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "_wabznasm() {")
+	fmt.Fprintln(w, "\tlocal -a commands")
+	fmt.Fprintln(w, "\tcommands=(")
+	for _, name := range commandNames() {
+		fmt.Fprintf(w, "\t\t%s\n", quote(name+":"+commands[name].summary))
+	}
+	fmt.Fprintln(w, "\t)")
+	fmt.Fprintln(w, "\tif ((CURRENT == 2)); then")
+	fmt.Fprintln(w, "\t\t_describe command commands")
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "\tlocal cmd=$words[2]")
+	fmt.Fprintln(w, "\tshift words")
+	fmt.Fprintln(w, "\t((CURRENT--))")
+	fmt.Fprintln(w, "\tcase $cmd in")
+	for _, name := range commandNames() {
+		c := commands[name]
+		var specs []string
+		for _, f := range c.flags(name) {
+			spec := "-" + f.name + "[" + zshDescription(f.usage) + "]"
+			if f.value != "" {
+				spec += ":" + f.value + ":" + zshAction(f.value)
+			}
+			specs = append(specs, quote(spec))
+		}
+		arg := "file"
+		if c.dirs {
+			arg = "dir"
+		}
+		if c.words != nil {
+			specs = append(specs, quote("1:argument:("+strings.Join(c.words(), " ")+")"))
+		}
+		specs = append(specs, quote("*:"+arg+":"+zshAction(arg)))
+		fmt.Fprintf(w, "\t%s)\n", name)
+		fmt.Fprintf(w, "\t\t_arguments %s\n", strings.Join(specs, " \\\n\t\t\t"))
+		fmt.Fprintln(w, "\t\t;;")
+	}
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintln(w)
+	// Autoloaded from a directory of fpath, the file is the body of
+	// _wabznasm; sourced, it registers the function.
+	fmt.Fprintln(w, "if [[ $funcstack[1] == _wabznasm ]]; then")
+	fmt.Fprintln(w, "\t_wabznasm \"$@\"")
+	fmt.Fprintln(w, "else")
+	fmt.Fprintln(w, "\tcompdef _wabznasm wabznasm")
+	fmt.Fprintln(w, "fi")
+}
+
+// fishDirs is the fish completion of directories.
+const fishDirs = "'(__fish_complete_directories (commandline -ct))'"
+
+func writeFish(w io.Writer) {
+	fmt.Fprintln(w, "# fish completion for wabznasm, generated by wabznasm completion fish.")
+	for _, name := range commandNames() {
+		fmt.Fprintf(w, "complete -c wabznasm -n __fish_use_subcommand -f -a %s -d %s\n", name, fishQuote(commands[name].summary))
+	}
+	for _, name := range commandNames() {
+		c := commands[name]
+		cond := "-n " + fishQuote("__fish_seen_subcommand_from "+name)
+		for _, f := range c.flags(name) {
+			line := "complete -c wabznasm " + cond + " -o " + f.name
+			switch f.value {
+			case "":
+			case "file":
+				line += " -r"
+			case "dir":
+				line += " -r -f -a " + fishDirs
+			default:
+				line += " -r -f"
+			}
+			fmt.Fprintln(w, line+" -d "+fishQuote(f.usage))
+		}
+		switch {
+		case c.words != nil:
+			fmt.Fprintf(w, "complete -c wabznasm %s -f -a %s\n", cond, fishQuote(strings.Join(c.words(), " ")))
+		case c.dirs:
+			fmt.Fprintf(w, "complete -c wabznasm %s -f -a %s\n", cond, fishDirs)
+		}
+	}
+}
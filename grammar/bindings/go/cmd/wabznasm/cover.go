@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/cover"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+)
+
+var coverCommand = &command{
+	summary:  "measure which expressions of scripts are evaluated",
+	synopsis: "[-html file] [-lcov file] [-min percent] script...",
+	help: "Evaluates the scripts in order in one interpreter, so that libraries\n" +
+		"can precede the scripts exercising them, and reports the statements\n" +
+		"and expressions of each that were evaluated.",
+	setup: setupCover,
+}
+
+func setupCover(fset *flag.FlagSet) func() int {
+	htmlOut := fset.String("html", "", "write an HTML coverage report to `file`")
+	lcovOut := fset.String("lcov", "", "write an lcov trace file to `file`")
+	minimum := fset.Float64("min", 0, "fail unless at least `percent` of the expressions are covered")
+	return func() int {
+		if fset.NArg() == 0 {
+			fset.Usage()
+			return 2
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		p := cover.New()
+		in := eval.New()
+		p.Attach(in)
+		status := 0
+		for _, path := range fset.Args() {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm cover:", err)
+				return 1
+			}
+			err = p.RunScript(ctx, in, path, src, func(st *repl.Statement) bool {
+				if st.Err != nil {
+					fmt.Fprintf(os.Stderr, "%s:%v\n", path, st.Err)
+					status = 1
+				}
+				return true
+			})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm cover:", err)
+				return 1
+			}
+		}
+
+		for _, f := range p.Files {
+			s := f.Summary()
+			fmt.Printf("%s: %.1f%% of statements, %.1f%% of expressions\n", f.Path, s.StatementPercent(), s.ExpressionPercent())
+		}
+		total := p.Summary()
+		if len(p.Files) > 1 {
+			fmt.Printf("total: %.1f%% of statements, %.1f%% of expressions\n", total.StatementPercent(), total.ExpressionPercent())
+		}
+		for _, out := range []struct {
+			path  string
+			write func(*os.File) error
+		}{
+			{*htmlOut, func(f *os.File) error { return p.WriteHTML(f) }},
+			{*lcovOut, func(f *os.File) error { return p.WriteLcov(f) }},
+		} {
+			if out.path == "" {
+				continue
+			}
+			f, err := os.Create(out.path)
+			if err == nil {
+				err = out.write(f)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm cover:", err)
+				return 1
+			}
+		}
+		if total.ExpressionPercent() < *minimum {
+			fmt.Fprintf(os.Stderr, "wabznasm cover: coverage %.1f%% is below the minimum of %.1f%%\n", total.ExpressionPercent(), *minimum)
+			return 1
+		}
+		return status
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/deadcode"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/diff"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+var deadcodeCommand = &command{
+	summary:  "report, or delete, globals nothing uses",
+	synopsis: "[-keep a,b] [-diff | -w] [dir]",
+	help: "Reports the globals of the project in dir that nothing refers to,\n" +
+		"and exits with status 1 if there are any.",
+	setup: setupDeadcode,
+	dirs:  true,
+}
+
+func setupDeadcode(fset *flag.FlagSet) func() int {
+	keep := fset.String("keep", "", "comma-separated entry points to treat as used")
+	showDiff := fset.Bool("diff", false, "print the deletions as a unified diff")
+	write := fset.Bool("w", false, "delete the dead definitions, removing files left empty")
+	return func() int {
+		root := "."
+		switch fset.NArg() {
+		case 0:
+		case 1:
+			root = fset.Arg(0)
+		default:
+			fset.Usage()
+			return 2
+		}
+
+		p, err := project.Open(root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm deadcode:", err)
+			return 1
+		}
+		var opts deadcode.Options
+		for _, name := range strings.Split(*keep, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				opts.Keep = append(opts.Keep, name)
+			}
+		}
+		dead := deadcode.Find(p, opts)
+		status := 0
+		for _, d := range dead {
+			status = 1
+			src := p.File(d.Path).Source
+			if !*showDiff && !*write {
+				what := "value"
+				if d.Func {
+					what = "function"
+				}
+				msg := "is never used"
+				if len(d.Peers) > 0 {
+					msg = "is used only by " + strings.Join(d.Peers, ", ")
+				}
+				fmt.Printf("%s:%d:%d: %s %s %s\n", d.Path, d.Span.Start.Row+1, d.Span.Start.Column+1, what, d.Name, msg)
+				continue
+			}
+			out, err := ast.Apply(src, []ast.Edit{d.Delete})
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", d.Path, err)
+				continue
+			}
+			if *showDiff {
+				fmt.Print(diff.Unified("a/"+d.Path, "b/"+d.Path, string(src), string(out)))
+				continue
+			}
+			if len(bytes.TrimSpace(out)) == 0 {
+				err = os.Remove(d.Path)
+			} else {
+				var info os.FileInfo
+				if info, err = os.Stat(d.Path); err == nil {
+					err = os.WriteFile(d.Path, out, info.Mode().Perm())
+				}
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		return status
+	}
+}
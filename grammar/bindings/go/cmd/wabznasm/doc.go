@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/docs"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+var docCommand = &command{
+	summary:  "generate reference documentation for a project",
+	synopsis: "[-o dir] [-format markdown|html] [dir]",
+	setup:    setupDoc,
+	dirs:     true,
+}
+
+func setupDoc(fset *flag.FlagSet) func() int {
+	out := fset.String("o", "doc", "output `dir`ectory")
+	formatName := fset.String("format", "markdown", "output format: markdown or html")
+	return func() int {
+		var f docs.Format
+		switch *formatName {
+		case "markdown", "md":
+			f = docs.Markdown
+		case "html":
+			f = docs.HTML
+		default:
+			fmt.Fprintf(os.Stderr, "wabznasm doc: unknown format %q\n", *formatName)
+			return 2
+		}
+		root := "."
+		switch fset.NArg() {
+		case 0:
+		case 1:
+			root = fset.Arg(0)
+		default:
+			fset.Usage()
+			return 2
+		}
+
+		p, err := project.Open(root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm doc:", err)
+			return 1
+		}
+		if err := docs.NewSite(p).Write(*out, f); err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm doc:", err)
+			return 1
+		}
+		return 0
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/dupes"
+)
+
+var dupesCommand = &command{
+	summary:  "find copy-pasted functions",
+	synopsis: "[-threshold s] [-min n] path ...",
+	help: "Reports functions whose bodies are copies of each other, most similar first,\n" +
+		"and exits with status 1 if there are any.",
+	setup: setupDupes,
+}
+
+func setupDupes(fset *flag.FlagSet) func() int {
+	threshold := fset.Float64("threshold", 0.8, "least `similarity`, from 0 to 1, of near clones to report")
+	minNodes := fset.Int("min", 30, "ignore functions of fewer than `n` syntax nodes")
+	return func() int {
+		if fset.NArg() == 0 || *threshold <= 0 || *threshold > 1 || *minNodes < 1 {
+			fset.Usage()
+			return 2
+		}
+
+		parser, err := tree_sitter_wabznasm.NewParser()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm dupes:", err)
+			return 1
+		}
+		defer parser.Close()
+
+		status := 0
+		d := dupes.New(dupes.Options{Threshold: *threshold, MinNodes: *minNodes})
+		for _, path := range sourceFiles(fset.Args(), &status) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+				continue
+			}
+			script, err := parser.ParseScript(src)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				status = 1
+				continue
+			}
+			for _, st := range script.Statements {
+				d.Add(path, st.Tree.Tree, st.Source)
+			}
+			script.Close()
+		}
+
+		for i, c := range d.Clones() {
+			if i > 0 {
+				fmt.Println()
+			}
+			kind := "near clones"
+			if c.Similarity == 1 {
+				kind = "exact clones"
+			}
+			fmt.Printf("%d %s, similarity %.2f:\n", len(c.Functions), kind, c.Similarity)
+			for _, f := range c.Functions {
+				pos := f.Range.StartPoint
+				fmt.Printf("\t%s:%d:%d: %s\n", f.Path, pos.Row+1, pos.Column+1, f.Name)
+			}
+			status = 1
+		}
+		return status
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/diff"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+)
+
+// maxFixPasses bounds the rounds of fixing a file: a fix can expose
+// another problem, or conflict with a fix applied in the same round.
+const maxFixPasses = 10
+
+var fixCommand = &command{
+	summary:  "apply the fixes offered for errors and lint findings",
+	synopsis: "[-rules a,b] [-diff | -apply] path ...",
+	help: "Applies the fixes offered for syntax errors and lint findings. Without\n" +
+		"-apply it only reports them, exiting with status 1 if there are any.",
+	setup: setupFix,
+}
+
+func setupFix(fset *flag.FlagSet) func() int {
+	only := fset.String("rules", "", "comma-separated lint rules whose fixes to use; default all")
+	apply := fset.Bool("apply", false, "write the fixed files back")
+	showDiff := fset.Bool("diff", false, "print the changes as a unified diff instead of listing the fixes")
+	return func() int {
+		rules, err := selectRules(*only)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm fix:", err)
+			return 2
+		}
+		if fset.NArg() == 0 || *apply && *showDiff {
+			fset.Usage()
+			return 2
+		}
+
+		parser, err := tree_sitter_wabznasm.NewParser()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm fix:", err)
+			return 1
+		}
+		defer parser.Close()
+
+		status := 0
+		for _, path := range sourceFiles(fset.Args(), &status) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+				continue
+			}
+			out, first, err := fixSource(parser, src, rules)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				status = 1
+				continue
+			}
+			if len(first) == 0 {
+				continue
+			}
+			switch {
+			case *apply:
+				info, err := os.Stat(path)
+				if err == nil {
+					err = os.WriteFile(path, out, info.Mode().Perm())
+				}
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					status = 1
+				}
+			case *showDiff:
+				fmt.Print(diff.Unified("a/"+path, "b/"+path, string(src), string(out)))
+				status = 1
+			default:
+				for _, f := range first {
+					p := f.Edits[0].Range.StartPoint
+					fmt.Printf("%s:%d:%d: %s\n", path, p.Row+1, p.Column+1, f.Title)
+				}
+				status = 1
+			}
+		}
+		return status
+	}
+}
+
+// fixSource applies fixes to src until none remain or none apply, and
+// returns the result and the fixes offered for src itself.
+func fixSource(parser *tree_sitter_wabznasm.Parser, src []byte, rules []lint.Rule) ([]byte, []tree_sitter_wabznasm.Fix, error) {
+	var first []tree_sitter_wabznasm.Fix
+	for pass := 0; pass < maxFixPasses; pass++ {
+		fixes, err := fixesOf(parser, src, rules)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pass == 0 {
+			first = fixes
+		}
+		out, applied := tree_sitter_wabznasm.ApplyFixes(src, fixes)
+		if applied == 0 {
+			break
+		}
+		src = out
+	}
+	return src, first, nil
+}
+
+// fixesOf collects the fixes of the syntax diagnostics and lint findings
+// of src, in that order.
+func fixesOf(parser *tree_sitter_wabznasm.Parser, src []byte, rules []lint.Rule) ([]tree_sitter_wabznasm.Fix, error) {
+	script, err := parser.ParseScript(src)
+	if err != nil {
+		return nil, err
+	}
+	defer script.Close()
+	var fixes []tree_sitter_wabznasm.Fix
+	for _, d := range script.Diagnostics() {
+		fixes = append(fixes, d.Fixes...)
+	}
+	for _, f := range lint.RunScript(script, rules...) {
+		fixes = append(fixes, f.Fixes...)
+	}
+	return fixes, nil
+}
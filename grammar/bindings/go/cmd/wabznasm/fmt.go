@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+)
+
+var fmtCommand = &command{
+	summary:  "format wabznasm source",
+	synopsis: "[-l] [-w] [-check] [-watch] [path ...]",
+	help: "Each file is formatted in the style of the nearest " + format.ConfigFile + " file in its\n" +
+		"directory or above, and standard input in that of the current directory.",
+	setup: setupFmt,
+}
+
+func setupFmt(fset *flag.FlagSet) func() int {
+	list := fset.Bool("l", false, "list files whose formatting differs")
+	write := fset.Bool("w", false, "write result to the source file instead of stdout")
+	check := fset.Bool("check", false, "exit with status 1 if any file is not formatted; implies -l")
+	watchMode := fset.Bool("watch", false, "format again whenever a file is saved, until interrupted")
+	return func() int {
+		if *check {
+			*list = true
+		}
+
+		if fset.NArg() == 0 {
+			if *watchMode {
+				fmt.Fprintln(os.Stderr, "wabznasm fmt: cannot use -watch with standard input")
+				return 2
+			}
+			if *write {
+				fmt.Fprintln(os.Stderr, "wabznasm fmt: cannot use -w with standard input")
+				return 2
+			}
+			var cfg format.Config
+			file, err := format.FindConfig(".")
+			if err == nil && file != "" {
+				cfg, err = format.LoadConfig(file)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm fmt:", err)
+				return 1
+			}
+			src, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm fmt:", err)
+				return 1
+			}
+			out, err := cfg.Script(src)
+			if errors.Is(err, format.ErrSyntax) {
+				reportSyntax("<stdin>", src)
+				return 1
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "<stdin>:", err)
+				return 1
+			}
+			switch {
+			case !*list:
+				os.Stdout.Write(out)
+			case !bytes.Equal(src, out):
+				fmt.Println("<stdin>")
+				if *check {
+					return 1
+				}
+			}
+			return 0
+		}
+
+		if *watchMode {
+			return watchSources(fset.Args(), func(path string, src []byte, _ *tree_sitter_wabznasm.Script) bool {
+				var out []byte
+				cfg, _, err := format.ConfigFor(path)
+				if err == nil {
+					out, err = cfg.Script(src)
+				}
+				changed, err := fmtResult(path, src, out, err, *list, *write)
+				if err != nil && err != errReported {
+					fmt.Fprintln(os.Stderr, err)
+				}
+				return err == nil && !(*check && changed)
+			})
+		}
+
+		status, unformatted := 0, false
+		for _, path := range sourceFiles(fset.Args(), &status) {
+			changed, err := fmtFile(path, *list, *write)
+			if err != nil {
+				if err != errReported {
+					fmt.Fprintln(os.Stderr, err)
+				}
+				status = 1
+			}
+			unformatted = unformatted || changed
+		}
+		if *check && unformatted {
+			status = 1
+		}
+		return status
+	}
+}
+
+// fmtFile formats one file, reporting whether its formatting differed.
+func fmtFile(path string, list, write bool) (bool, error) {
+	cfg, _, err := format.ConfigFor(path)
+	if err != nil {
+		return false, err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+	_, span := telemetry.Start(context.Background(), "format", slog.String("path", path), slog.Int("bytes", len(src)))
+	out, err := cfg.Script(src)
+	span.End(err)
+	return fmtResult(path, src, out, err, list, write)
+}
+
+// fmtResult lists, writes or prints the formatted form out of a file, as
+// fmtFile does, given the error of formatting it.
+func fmtResult(path string, src, out []byte, err error, list, write bool) (bool, error) {
+	if errors.Is(err, format.ErrSyntax) {
+		reportSyntax(path, src)
+		return false, errReported
+	}
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+	changed := !bytes.Equal(src, out)
+	if list && changed {
+		fmt.Println(path)
+	}
+	if write && changed {
+		info, err := os.Stat(path)
+		if err != nil {
+			return changed, err
+		}
+		return changed, os.WriteFile(path, out, info.Mode().Perm())
+	}
+	if !list && !write {
+		os.Stdout.Write(out)
+	}
+	return changed, nil
+}
+
+// sourceFiles expands directory arguments to the wabznasm sources beneath
+// them. Files named explicitly are kept whatever their extension. A
+// trailing /... is accepted, as in Go package patterns; directories are
+// walked recursively either way. Errors are reported and set status to 1.
+func sourceFiles(args []string, status *int) []string {
+	var files []string
+	for _, arg := range args {
+		arg = patternRoot(arg)
+		info, err := os.Stat(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			*status = 1
+			continue
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+		err = filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && project.IsSource(path) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			*status = 1
+		}
+	}
+	return files
+}
+
+// patternRoot strips the /... of a Go-style pattern argument.
+func patternRoot(arg string) string {
+	if arg == "..." {
+		return "."
+	}
+	if dir, ok := strings.CutSuffix(arg, "/..."); ok {
+		return dir
+	}
+	return arg
+}
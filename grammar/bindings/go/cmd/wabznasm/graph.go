@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/callgraph"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+var graphCommand = &command{
+	summary:  "write the call graph of a project",
+	synopsis: "[-format dot|json] [-affected name] [dir]",
+	help: "Writes the call graph of the project in dir, for example to render with\n" +
+		"  wabznasm graph | dot -Tsvg > calls.svg",
+	setup: setupGraph,
+	dirs:  true,
+}
+
+func setupGraph(fset *flag.FlagSet) func() int {
+	formatName := fset.String("format", "dot", "output format: dot or json")
+	focus := fset.String("affected", "", "show only `name` and what calls it, directly or not")
+	return func() int {
+		if *formatName != "dot" && *formatName != "json" {
+			fmt.Fprintf(os.Stderr, "wabznasm graph: unknown format %q\n", *formatName)
+			return 2
+		}
+		root := "."
+		switch fset.NArg() {
+		case 0:
+		case 1:
+			root = fset.Arg(0)
+		default:
+			fset.Usage()
+			return 2
+		}
+
+		p, err := project.Open(root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm graph:", err)
+			return 1
+		}
+		g := callgraph.FromProject(p)
+		if *focus != "" {
+			if g.Node(*focus) == nil {
+				fmt.Fprintf(os.Stderr, "wabznasm graph: %s is not called or defined\n", *focus)
+				return 1
+			}
+			g = g.Restrict(append(g.Affected(*focus), *focus))
+		}
+		if *formatName == "json" {
+			err = g.WriteJSON(os.Stdout)
+		} else {
+			err = g.WriteDOT(os.Stdout)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm graph:", err)
+			return 1
+		}
+		return 0
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/pattern"
+)
+
+var grepCommand = &command{
+	summary:  "search for, or rewrite, code matching a structural pattern",
+	synopsis: "[-l] [-rewrite template [-w]] [-query] pattern path ...",
+	setup:    setupGrep,
+}
+
+func setupGrep(fset *flag.FlagSet) func() int {
+	rewrite := fset.String("rewrite", "", "replace matches with this template, printing the result")
+	write := fset.Bool("w", false, "with -rewrite, write the result to the source file")
+	list := fset.Bool("l", false, "list files with matches instead of the matches")
+	showQuery := fset.Bool("query", false, "print the tree-sitter query the pattern compiles to and exit")
+	return func() int {
+		if fset.NArg() < 1 || *write && *rewrite == "" {
+			fset.Usage()
+			return 2
+		}
+		p, err := pattern.Compile(fset.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm grep:", err)
+			return 2
+		}
+		if *showQuery {
+			fmt.Println(p.Query())
+			return 0
+		}
+		if fset.NArg() < 2 {
+			fset.Usage()
+			return 2
+		}
+
+		parser, err := tree_sitter_wabznasm.NewParser()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm grep:", err)
+			return 2
+		}
+		defer parser.Close()
+
+		status, found := 0, false
+		files := sourceFiles(fset.Args()[1:], &status)
+		if status != 0 {
+			status = 2 // grep reserves 1 for "no matches"
+		}
+		for _, path := range files {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 2
+				continue
+			}
+			script, err := parser.ParseScript(src)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				status = 2
+				continue
+			}
+			if *rewrite != "" {
+				// Rewriting the last statements first leaves the positions
+				// of the trees of those before them as they were.
+				out, replaced := src, 0
+				for i := len(script.Statements) - 1; i >= 0 && err == nil; i-- {
+					var matches []pattern.Match
+					out, matches, err = p.Replace(script.Statements[i].Tree.Tree, out, *rewrite)
+					replaced += len(matches)
+				}
+				script.Close()
+				if err != nil {
+					fmt.Fprintln(os.Stderr, "wabznasm grep:", err)
+					return 2
+				}
+				found = found || replaced > 0
+				switch {
+				case *write && !bytes.Equal(out, src):
+					info, err := os.Stat(path)
+					if err == nil {
+						err = os.WriteFile(path, out, info.Mode().Perm())
+					}
+					if err != nil {
+						fmt.Fprintln(os.Stderr, err)
+						status = 2
+					}
+				case !*write:
+					os.Stdout.Write(out)
+				}
+				continue
+			}
+			var matches []pattern.Match
+			for _, st := range script.Statements {
+				ms, err := p.Find(st.Tree.Tree, src)
+				if err != nil {
+					script.Close()
+					fmt.Fprintln(os.Stderr, "wabznasm grep:", err)
+					return 2
+				}
+				matches = append(matches, ms...)
+			}
+			if len(matches) > 0 && *list {
+				fmt.Println(path)
+			}
+			for _, m := range matches {
+				found = true
+				if *list {
+					break
+				}
+				pos := m.Node.StartPosition()
+				text, _, _ := strings.Cut(m.Node.Utf8Text(src), "\n")
+				fmt.Printf("%s:%d:%d: %s\n", path, pos.Row+1, pos.Column+1, text)
+			}
+			script.Close()
+		}
+		if status == 0 && !found {
+			status = 1
+		}
+		return status
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/baseline"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/report"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/sarif"
+)
+
+var lintCommand = &command{
+	summary:  "check wabznasm source against lint rules",
+	synopsis: "[-rules a,b] [-list] [-watch] [-format text|sarif] [-baseline file [-update-baseline]] path ...",
+	help: "A comment \\ wabznasm:ignore rule reason suppresses the findings of rule in\n" +
+		"the next statement, or in the function body it is in.\n" +
+		"With -format sarif, it writes the syntax errors and findings of every file as\n" +
+		"one SARIF 2.1.0 log to standard output, for upload to code scanning.\n" +
+		"With -baseline, it reports only the findings the baseline file does not\n" +
+		"record; if the file does not exist, or with -update-baseline, it records the\n" +
+		"current findings in it instead.",
+	setup: setupLint,
+}
+
+func setupLint(fset *flag.FlagSet) func() int {
+	only := fset.String("rules", "", "comma-separated rules to run; default all")
+	listRules := fset.Bool("list", false, "list the available rules and exit")
+	watchMode := fset.Bool("watch", false, "lint again whenever a file is saved, until interrupted")
+	formatName := fset.String("format", "text", "output format: text or sarif")
+	basePath := fset.String("baseline", "", "report only findings not recorded in the baseline `file`")
+	updateBase := fset.Bool("update-baseline", false, "record the current findings in the -baseline file")
+	return func() int {
+		if *listRules {
+			for _, r := range lint.Rules() {
+				fmt.Printf("%-20s %s\n", r.Name(), r.Doc())
+			}
+			return 0
+		}
+		rules, err := selectRules(*only)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm lint:", err)
+			return 2
+		}
+		if fset.NArg() == 0 {
+			fset.Usage()
+			return 2
+		}
+
+		var log *sarif.Builder
+		switch *formatName {
+		case "text":
+		case "sarif":
+			if *watchMode {
+				fmt.Fprintln(os.Stderr, "wabznasm lint: cannot use -watch with -format sarif")
+				return 2
+			}
+			log = sarif.New(rules)
+		default:
+			fmt.Fprintf(os.Stderr, "wabznasm lint: unknown format %q\n", *formatName)
+			return 2
+		}
+
+		var base *baseline.Baseline
+		var matcher *baseline.Matcher
+		switch {
+		case *basePath == "":
+			if *updateBase {
+				fmt.Fprintln(os.Stderr, "wabznasm lint: -update-baseline requires -baseline")
+				return 2
+			}
+		case *watchMode:
+			fmt.Fprintln(os.Stderr, "wabznasm lint: cannot use -watch with -baseline")
+			return 2
+		default:
+			b, err := baseline.ReadFile(*basePath)
+			switch {
+			case *updateBase || errors.Is(err, fs.ErrNotExist):
+				base = &baseline.Baseline{}
+			case err != nil:
+				fmt.Fprintln(os.Stderr, "wabznasm lint:", err)
+				return 1
+			default:
+				matcher = b.Matcher()
+			}
+		}
+
+		printer := report.New(os.Stdout, report.ColorEnabled(os.Stdout))
+		if *watchMode {
+			return watchSources(fset.Args(), func(path string, src []byte, script *tree_sitter_wabznasm.Script) bool {
+				return lintScript(printer, path, src, script, rules)
+			})
+		}
+
+		parser, err := tree_sitter_wabznasm.NewParser()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm lint:", err)
+			return 1
+		}
+		defer parser.Close()
+
+		c := openCache()
+		status := 0
+		for _, path := range sourceFiles(fset.Args(), &status) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+				continue
+			}
+			a, err := analyze(c, parser, format.Config{}, src, rules)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				status = 1
+				continue
+			}
+			findings := a.Findings
+			if (base != nil || matcher != nil) && len(findings) > 0 {
+				// Fingerprints need the tree of the statement of each
+				// finding, which the cache does not keep.
+				script, err := parser.ParseScript(src)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+					status = 1
+					continue
+				}
+				var fresh []lint.Finding
+				for _, f := range findings {
+					st := script.At(f.Range.StartByte)
+					if base != nil {
+						base.Add(path, st.Tree.Tree, st.Source, []lint.Finding{f})
+					} else {
+						fresh = append(fresh, matcher.New(path, st.Tree.Tree, st.Source, []lint.Finding{f})...)
+					}
+				}
+				findings = fresh
+				script.Close()
+			}
+			if log != nil {
+				log.AddDiagnostics(path, src, a.Diagnostics)
+				log.AddFindings(path, src, findings)
+			} else {
+				for _, f := range findings {
+					printer.Print(path, src, report.FromFinding(f))
+				}
+			}
+			if len(findings) > 0 || log != nil && len(a.Diagnostics) > 0 {
+				status = 1
+			}
+		}
+		if base != nil {
+			if err := base.WriteFile(*basePath); err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm lint:", err)
+				return 1
+			}
+			fmt.Fprintf(os.Stderr, "wabznasm lint: recorded %d findings in %s\n", len(base.Findings), *basePath)
+		}
+		if log != nil {
+			if err := log.Log().Write(os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm lint:", err)
+				return 1
+			}
+		}
+		return status
+	}
+}
+
+// lintScript prints the findings of rules on a file, reporting whether
+// there were none.
+func lintScript(printer *report.Printer, path string, src []byte, script *tree_sitter_wabznasm.Script, rules []lint.Rule) bool {
+	findings := lint.RunScript(script, rules...)
+	for _, f := range findings {
+		printer.Print(path, src, report.FromFinding(f))
+	}
+	return len(findings) == 0
+}
+
+// selectRules looks up a comma-separated list of rules; an empty list
+// selects every rule.
+func selectRules(names string) ([]lint.Rule, error) {
+	if names == "" {
+		return nil, nil
+	}
+	var rules []lint.Rule
+	for _, name := range strings.Split(names, ",") {
+		r, ok := lint.Lookup(strings.TrimSpace(name))
+		if !ok {
+			return nil, fmt.Errorf("unknown rule %q", name)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
@@ -0,0 +1,343 @@
+// Command wabznasm is the wabznasm command-line tool.
+//
+// Usage:
+//
+//	wabznasm <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	repl       start an interactive session
+//	fmt        format wabznasm source
+//	check      check that sources parse, pass lint and are formatted
+//	lint       check wabznasm source against lint rules
+//	fix        apply the fixes offered for errors and lint findings
+//	metrics    report the complexity of each statement
+//	parse      check the syntax of many files, writing JSON records
+//	deadcode   report, or delete, globals nothing uses
+//	doc        generate reference documentation for a project
+//	graph      write the call graph of a project
+//	grep       search for, or rewrite, code matching a structural pattern
+//	dupes      find copy-pasted functions
+//	transpile  translate q source to wabznasm
+//	anonymize  rename identifiers and drop comments, for sharing source
+//	vet        check a project for mistakes that span files
+//	analyze    run the analysis passes over a project
+//	profile    time the evaluation of a script, expression by expression
+//	cover      measure which expressions of scripts are evaluated
+//	test       run the assertions of *_test.wz files
+//	regress    compare how two versions of the grammar parse a corpus
+//	mod        manage the modules a project requires
+//	replay     replay a session recorded by repl -record
+//	new        write starter files for a library, script or test
+//	completion write a shell completion script for bash, zsh or fish
+//	man        write manual pages for the commands
+//	version    print the versions of the tool, the grammar and tree-sitter
+//	help       list commands, or describe one
+//
+// The lint and check commands cache their results for each file content in
+// the user's cache directory; set WABZNASM_CACHE to another directory to
+// move the cache, or to off to disable it.
+//
+// Set WABZNASM_LOG to a level, such as debug, to log to standard error,
+// debug logging the time spent parsing, analyzing and formatting; append
+// ",json" for JSON lines. The OTEL_EXPORTER_OTLP_ENDPOINT and
+// OTEL_SERVICE_NAME variables of OpenTelemetry export the same spans to a
+// collector.
+//
+// Set WABZNASM_GRAMMAR to the path of a shared object built from a
+// checkout of the grammar, as by tree-sitter build, to parse with it
+// instead of the grammar compiled in.
+//
+// Help with a command describes it. The usage messages, the shell
+// completions of the completion command and the manual pages of the man
+// command are all generated from the same descriptions, so they cannot
+// drift apart.
+//
+// With no command it starts an interactive REPL. Run as pre-commit, as when
+// linked into .git/hooks, it runs check -staged.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/replay"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/trace"
+)
+
+// command is a subcommand. It describes itself, so that its usage message,
+// shell completions and manual page are all generated from one place.
+type command struct {
+	summary string
+	// synopsis follows the command name in the usage line. A command
+	// with several forms puts each on a line of its own.
+	synopsis string
+	// help describes the command in lines of text, after the synopsis.
+	help string
+	// setup declares the flags of the command on fset and returns the
+	// function running it, which takes its arguments from fset once it is
+	// parsed and returns the process exit status.
+	setup func(fset *flag.FlagSet) func() int
+	// words, if set, lists the values of the first argument, such as the
+	// subcommands of mod, for completion.
+	words func() []string
+	// dirs is set if the arguments are directories rather than files.
+	dirs bool
+}
+
+// flagSet returns the flags of the command name, with a usage message
+// generated from its description, and the function running it.
+func (c *command) flagSet(name string) (*flag.FlagSet, func() int) {
+	fset := flag.NewFlagSet(name, flag.ExitOnError)
+	run := c.setup(fset)
+	fset.Usage = func() { c.usage(os.Stderr, name, fset) }
+	return fset, run
+}
+
+// run runs the command name with args, the arguments after its name.
+func (c *command) run(name string, args []string) int {
+	fset, run := c.flagSet(name)
+	fset.Parse(args)
+	return run()
+}
+
+// usage writes the usage message of the command name, with the flags
+// fset, to w.
+func (c *command) usage(w io.Writer, name string, fset *flag.FlagSet) {
+	for i, line := range strings.Split(c.synopsis, "\n") {
+		prefix := "usage:"
+		if i > 0 {
+			prefix = "      "
+		}
+		fmt.Fprintln(w, prefix, "wabznasm", name, line)
+	}
+	if c.help != "" {
+		fmt.Fprintln(w, c.help)
+	}
+	fset.SetOutput(w)
+	fset.PrintDefaults()
+}
+
+// flagInfo describes a flag, for completions and manual pages.
+type flagInfo struct {
+	name string
+	// value names the value of the flag, as in the usage message; it is
+	// empty for a boolean flag.
+	value string
+	usage string
+	// def is the default value, or empty if it is the zero value.
+	def string
+}
+
+// flags returns the flags of the command name, in name order.
+func (c *command) flags(name string) []flagInfo {
+	fset, _ := c.flagSet(name)
+	var out []flagInfo
+	fset.VisitAll(func(f *flag.Flag) {
+		value, usage := flag.UnquoteUsage(f)
+		def := f.DefValue
+		switch def {
+		case "0", "false", "0s":
+			def = ""
+		}
+		out = append(out, flagInfo{name: f.Name, value: value, usage: usage, def: def})
+	})
+	return out
+}
+
+var commands map[string]*command
+
+func init() {
+	commands = map[string]*command{
+		"repl":       replCommand,
+		"fmt":        fmtCommand,
+		"check":      checkCommand,
+		"lint":       lintCommand,
+		"fix":        fixCommand,
+		"metrics":    metricsCommand,
+		"parse":      parseCommand,
+		"deadcode":   deadcodeCommand,
+		"doc":        docCommand,
+		"graph":      graphCommand,
+		"grep":       grepCommand,
+		"dupes":      dupesCommand,
+		"transpile":  transpileCommand,
+		"anonymize":  anonymizeCommand,
+		"vet":        vetCommand,
+		"analyze":    analyzeCommand,
+		"profile":    profileCommand,
+		"cover":      coverCommand,
+		"test":       testCommand,
+		"regress":    regressCommand,
+		"mod":        modCommand,
+		"replay":     replayCommand,
+		"new":        newCommand,
+		"completion": completionCommand,
+		"man":        manCommand,
+		"version":    versionCommand,
+		"help":       helpCommand,
+	}
+}
+
+func main() {
+	flush := setupTelemetry()
+	if err := setupGrammar(); err != nil {
+		fmt.Fprintln(os.Stderr, "wabznasm:", err)
+		flush()
+		os.Exit(1)
+	}
+	args := os.Args[1:]
+	if filepath.Base(os.Args[0]) == hookName {
+		status := commands["check"].run("check", append([]string{"-staged"}, args...))
+		flush()
+		os.Exit(status)
+	}
+	name := "repl"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "wabznasm: unknown command %q\n", name)
+		listCommands()
+		os.Exit(2)
+	}
+	status := cmd.run(name, args)
+	flush()
+	os.Exit(status)
+}
+
+// setupGrammar loads the grammar WABZNASM_GRAMMAR names, if any, for every
+// parser to use.
+func setupGrammar() error {
+	path := os.Getenv("WABZNASM_GRAMMAR")
+	if path == "" {
+		return nil
+	}
+	lang, err := tree_sitter_wabznasm.LoadLanguageFrom(path)
+	if err != nil {
+		return err
+	}
+	tree_sitter_wabznasm.UseLanguage(lang)
+	return nil
+}
+
+// commandNames returns the names of the commands, sorted.
+func commandNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func listCommands() {
+	fmt.Fprintln(os.Stderr, "usage: wabznasm <command> [flags]")
+	for _, name := range commandNames() {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].summary)
+	}
+}
+
+var helpCommand = &command{
+	summary:  "list commands, or describe one",
+	synopsis: "[command]",
+	setup:    setupHelp,
+	words:    commandNames,
+}
+
+func setupHelp(fset *flag.FlagSet) func() int {
+	return func() int {
+		switch fset.NArg() {
+		case 0:
+			listCommands()
+			return 0
+		case 1:
+			name := fset.Arg(0)
+			cmd, ok := commands[name]
+			if !ok {
+				fmt.Fprintf(os.Stderr, "wabznasm help: unknown command %q\n", name)
+				return 2
+			}
+			flags, _ := cmd.flagSet(name)
+			cmd.usage(os.Stderr, name, flags)
+			return 0
+		}
+		fset.Usage()
+		return 2
+	}
+}
+
+var replCommand = &command{
+	summary:  "start an interactive session (default)",
+	synopsis: "[-history file] [-sexp] [-parse-timeout d] [-trace] [-record file]",
+	help: "Evaluates each entry read from standard input and prints its value. It is\n" +
+		"the command run when none is given.",
+	setup: setupREPL,
+}
+
+func setupREPL(fs *flag.FlagSet) func() int {
+	history := fs.String("history", defaultHistoryFile(), "history `file`; empty disables history")
+	sexpr := fs.Bool("sexp", false, "print parse trees instead of evaluating")
+	timeout := fs.Duration("parse-timeout", 0, "give up parsing an entry after this long; 0 means no limit")
+	traced := fs.Bool("trace", false, "log the calls, operators and assignments of each evaluation to standard error")
+	record := fs.String("record", "", "record the session to `file`, for wabznasm replay")
+	return func() int {
+		var recorder *replay.Recorder
+		if *record != "" {
+			recorder = replay.NewRecorder(time.Now().UnixNano())
+		}
+
+		s, err := repl.NewSession(repl.Config{
+			In:                 os.Stdin,
+			Out:                os.Stdout,
+			HistoryFile:        *history,
+			SExpr:              *sexpr,
+			Quiet:              !isTerminal(os.Stdin),
+			ParseTimeoutMicros: uint64(timeout.Microseconds()),
+			Recorder:           recorder,
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm:", err)
+			return 1
+		}
+		defer s.Close()
+		if *traced {
+			log := trace.NewLog(os.Stderr)
+			log.Operators = true
+			s.Interpreter().Tracer = log
+		}
+		err = s.Run()
+		if recorder != nil {
+			if werr := writeRecording(*record, recorder.Recording()); err == nil {
+				err = werr
+			}
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm:", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".wabznasm_history")
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var manCommand = &command{
+	summary:  "write manual pages for the commands",
+	synopsis: "[-dir dir] [command]",
+	help: "Writes the manual page of a command, or of wabznasm itself, to standard\n" +
+		"output in roff. With -dir, it writes the pages of wabznasm and of every\n" +
+		"command to dir instead, as wabznasm.1 and wabznasm-command.1.",
+	setup: setupMan,
+	words: commandNames,
+}
+
+func setupMan(fset *flag.FlagSet) func() int {
+	dir := fset.String("dir", "", "write every page to `dir`")
+	return func() int {
+		if *dir != "" {
+			if fset.NArg() != 0 {
+				fset.Usage()
+				return 2
+			}
+			if err := writeManPages(*dir); err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm man:", err)
+				return 1
+			}
+			return 0
+		}
+		switch fset.NArg() {
+		case 0:
+			writeManIndex(os.Stdout)
+		case 1:
+			name := fset.Arg(0)
+			if commands[name] == nil {
+				fmt.Fprintf(os.Stderr, "wabznasm man: unknown command %q\n", name)
+				return 2
+			}
+			writeManPage(os.Stdout, name)
+		default:
+			fset.Usage()
+			return 2
+		}
+		return 0
+	}
+}
+
+// writeManPages writes the pages of wabznasm and its commands to dir.
+func writeManPages(dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	writeManIndex(&buf)
+	if err := os.WriteFile(filepath.Join(dir, "wabznasm.1"), buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	for _, name := range commandNames() {
+		buf.Reset()
+		writeManPage(&buf, name)
+		if err := os.WriteFile(filepath.Join(dir, "wabznasm-"+name+".1"), buf.Bytes(), 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roff escapes text for roff, a line at a time.
+func roff(text string) string {
+	lines := strings.Split(strings.NewReplacer(`\`, `\e`, "-", `\-`).Replace(text), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			lines[i] = `\&` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// manHeader writes the title and name sections of the page title.
+func manHeader(w io.Writer, title, summary string) {
+	fmt.Fprintf(w, ".TH %s 1 \"\" wabznasm \"wabznasm Manual\"\n", strings.ToUpper(title))
+	fmt.Fprintln(w, ".SH NAME")
+	fmt.Fprintf(w, "%s \\- %s\n", title, roff(summary))
+}
+
+func writeManIndex(w io.Writer) {
+	manHeader(w, "wabznasm", "the wabznasm command-line tool")
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	fmt.Fprintln(w, ".B wabznasm")
+	fmt.Fprintln(w, ".I command")
+	fmt.Fprintln(w, "[flags] [arguments]")
+	fmt.Fprintln(w, ".SH DESCRIPTION")
+	fmt.Fprintln(w, "With no command, wabznasm starts an interactive session, as")
+	fmt.Fprintln(w, ".B wabznasm repl")
+	fmt.Fprintln(w, "does. Run as pre\\-commit, as when linked into .git/hooks, it runs")
+	fmt.Fprintln(w, ".BR "+roff("\"check -staged\"")+" .")
+	fmt.Fprintln(w, ".SH COMMANDS")
+	for _, name := range commandNames() {
+		fmt.Fprintln(w, ".TP")
+		fmt.Fprintln(w, ".B "+name)
+		fmt.Fprintln(w, roff(commands[name].summary))
+	}
+	fmt.Fprintln(w, ".SH ENVIRONMENT")
+	fmt.Fprintln(w, ".TP")
+	fmt.Fprintln(w, ".B WABZNASM_CACHE")
+	fmt.Fprintln(w, "The directory lint and check cache their results in, the user's cache")
+	fmt.Fprintln(w, "directory by default; off disables the cache.")
+	fmt.Fprintln(w, ".SH SEE ALSO")
+	names := commandNames()
+	for i, name := range names {
+		sep := ","
+		if i == len(names)-1 {
+			sep = ""
+		}
+		fmt.Fprintf(w, ".BR wabznasm\\-%s (1)%s\n", name, sep)
+	}
+}
+
+func writeManPage(w io.Writer, name string) {
+	c := commands[name]
+	manHeader(w, "wabznasm-"+name, c.summary)
+	fmt.Fprintln(w, ".SH SYNOPSIS")
+	for i, line := range strings.Split(c.synopsis, "\n") {
+		if i > 0 {
+			fmt.Fprintln(w, ".br")
+		}
+		fmt.Fprintln(w, ".B wabznasm "+name)
+		fmt.Fprintln(w, roff(line))
+	}
+	if c.help != "" {
+		fmt.Fprintln(w, ".SH DESCRIPTION")
+		fmt.Fprintln(w, roff(c.help))
+	}
+	if fs := c.flags(name); len(fs) > 0 {
+		fmt.Fprintln(w, ".SH OPTIONS")
+		for _, f := range fs {
+			fmt.Fprintln(w, ".TP")
+			if f.value == "" {
+				fmt.Fprintln(w, ".B "+roff("-"+f.name))
+			} else {
+				fmt.Fprintf(w, ".BI %s \" %s\"\n", roff("-"+f.name), roff(f.value))
+			}
+			usage := f.usage
+			if f.def != "" {
+				usage += fmt.Sprintf(" (default %q)", f.def)
+			}
+			fmt.Fprintln(w, roff(usage))
+		}
+	}
+	fmt.Fprintln(w, ".SH SEE ALSO")
+	fmt.Fprintln(w, ".BR wabznasm (1)")
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/metrics"
+)
+
+// metricsRecord is the JSON record of one statement. Line and column are
+// one-based.
+type metricsRecord struct {
+	Path       string `json:"path"`
+	Line       uint   `json:"line"`
+	Column     uint   `json:"column"`
+	Name       string `json:"name"`
+	Func       bool   `json:"func"`
+	Params     int    `json:"params"`
+	Complexity int    `json:"complexity"`
+	Depth      int    `json:"depth"`
+	Length     int    `json:"length"`
+}
+
+var metricsColumns = []string{"path", "line", "column", "name", "func", "params", "complexity", "depth", "length"}
+
+var metricsCommand = &command{
+	summary:  "report the complexity of each statement",
+	synopsis: "[-format csv|json] path ...",
+	help: "Writes the parameters, complexity, nesting depth and length of every statement.\n" +
+		"The complexity lint rule reports those over its thresholds.",
+	setup: setupMetrics,
+}
+
+func setupMetrics(fset *flag.FlagSet) func() int {
+	formatName := fset.String("format", "csv", "output format: csv or json")
+	return func() int {
+		if *formatName != "csv" && *formatName != "json" {
+			fmt.Fprintf(os.Stderr, "wabznasm metrics: unknown format %q\n", *formatName)
+			return 2
+		}
+		if fset.NArg() == 0 {
+			fset.Usage()
+			return 2
+		}
+
+		parser, err := tree_sitter_wabznasm.NewParser()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm metrics:", err)
+			return 1
+		}
+		defer parser.Close()
+
+		status := 0
+		records := []metricsRecord{}
+		for _, path := range sourceFiles(fset.Args(), &status) {
+			src, err := os.ReadFile(path)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				status = 1
+				continue
+			}
+			script, err := parser.ParseScript(src)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+				status = 1
+				continue
+			}
+			if script.HasError() {
+				fmt.Fprintf(os.Stderr, "%s: skipped: syntax errors\n", path)
+				status = 1
+			}
+			var ms []metrics.Metrics
+			for _, st := range script.Statements {
+				ms = append(ms, metrics.Analyze(st.Tree.Tree, st.Source)...)
+			}
+			for _, m := range ms {
+				records = append(records, metricsRecord{
+					Path:       path,
+					Line:       m.Range.StartPoint.Row + 1,
+					Column:     m.Range.StartPoint.Column + 1,
+					Name:       m.Name,
+					Func:       m.Func,
+					Params:     m.Params,
+					Complexity: m.Complexity,
+					Depth:      m.Depth,
+					Length:     m.Length,
+				})
+			}
+			script.Close()
+		}
+
+		if *formatName == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(records)
+		} else {
+			w := csv.NewWriter(os.Stdout)
+			w.Write(metricsColumns)
+			for _, r := range records {
+				w.Write([]string{
+					r.Path,
+					strconv.FormatUint(uint64(r.Line), 10),
+					strconv.FormatUint(uint64(r.Column), 10),
+					r.Name,
+					strconv.FormatBool(r.Func),
+					strconv.Itoa(r.Params),
+					strconv.Itoa(r.Complexity),
+					strconv.Itoa(r.Depth),
+					strconv.Itoa(r.Length),
+				})
+			}
+			w.Flush()
+			err = w.Error()
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm metrics:", err)
+			return 1
+		}
+		return status
+	}
+}
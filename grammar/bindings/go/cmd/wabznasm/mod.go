@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/mod"
+)
+
+var modCommand = &command{
+	summary:  "manage the modules a project requires",
+	synopsis: "init [name]\nvendor [-update]\nverify",
+	help: "init writes a " + mod.ManifestFile + " naming the module in the current directory;\n" +
+		"vendor fetches the modules it requires into vendor and pins them in " + mod.LockFile + ";\n" +
+		"verify checks the vendored modules against the lock file.",
+	setup: setupMod,
+	words: func() []string { return []string{"init", "vendor", "verify"} },
+}
+
+func setupMod(fset *flag.FlagSet) func() int {
+	return func() int {
+		args := fset.Args()
+		if len(args) == 0 {
+			fset.Usage()
+			return 2
+		}
+		switch args[0] {
+		case "init":
+			return runModInit(args[1:], fset.Usage)
+		case "vendor":
+			return runModVendor(args[1:])
+		case "verify":
+			if len(args) != 1 {
+				fset.Usage()
+				return 2
+			}
+			if err := mod.Verify("."); err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm mod verify:", err)
+				return 1
+			}
+			fmt.Println("all modules verified")
+			return 0
+		default:
+			fset.Usage()
+			return 2
+		}
+	}
+}
+
+func runModInit(args []string, usage func()) int {
+	if len(args) > 1 {
+		usage()
+		return 2
+	}
+	if _, err := os.Stat(mod.ManifestFile); err == nil {
+		fmt.Fprintln(os.Stderr, "wabznasm mod init:", mod.ManifestFile, "already exists")
+		return 1
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintln(os.Stderr, "wabznasm mod init:", err)
+		return 1
+	}
+	var name string
+	if len(args) == 1 {
+		name = args[0]
+	} else {
+		dir, err := filepath.Abs(".")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm mod init:", err)
+			return 1
+		}
+		name = filepath.Base(dir)
+	}
+	m := &mod.Manifest{Name: name}
+	// Check the name as a manifest read back would.
+	if _, err := mod.ParseManifest(bytes.NewReader(m.Bytes()), mod.ManifestFile); err != nil {
+		fmt.Fprintln(os.Stderr, "wabznasm mod init:", err)
+		return 1
+	}
+	if err := os.WriteFile(mod.ManifestFile, m.Bytes(), 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "wabznasm mod init:", err)
+		return 1
+	}
+	return 0
+}
+
+func runModVendor(args []string) int {
+	fset := flag.NewFlagSet("mod vendor", flag.ExitOnError)
+	update := fset.Bool("update", false, "resolve the refs of the manifests again instead of using the locked commits")
+	fset.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: wabznasm mod vendor [-update]")
+		fset.PrintDefaults()
+	}
+	fset.Parse(args)
+	if fset.NArg() != 0 {
+		fset.Usage()
+		return 2
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	lock, err := mod.Vendor(ctx, ".", mod.Options{Update: *update})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "wabznasm mod vendor:", err)
+		return 1
+	}
+	for _, l := range lock.Modules {
+		fmt.Printf("%s %s %s\n", l.Name, l.Ref, l.Commit)
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scaffold"
+)
+
+var newCommand = &command{
+	summary:  "write starter files for a library, script or test",
+	synopsis: "[-dir dir] template name",
+	help: "Writes starter files from a template, refusing to overwrite any. The\n" +
+		"templates are:\n" + templateList(),
+	setup: setupNew,
+	words: templateNames,
+}
+
+// templateList lists the templates of new, a line each.
+func templateList() string {
+	var b strings.Builder
+	for i, t := range scaffold.Templates() {
+		if i > 0 {
+			b.WriteByte('\n')
+		}
+		fmt.Fprintf(&b, "  %-8s %s", t.Name, t.Summary)
+	}
+	return b.String()
+}
+
+func templateNames() []string {
+	var names []string
+	for _, t := range scaffold.Templates() {
+		names = append(names, t.Name)
+	}
+	return names
+}
+
+func setupNew(fset *flag.FlagSet) func() int {
+	dir := fset.String("dir", "", "write the files to `dir`; default the name for a library, else the current directory")
+	return func() int {
+		if fset.NArg() != 2 {
+			fset.Usage()
+			return 2
+		}
+		t := scaffold.Lookup(fset.Arg(0))
+		if t == nil {
+			fmt.Fprintf(os.Stderr, "wabznasm new: unknown template %q\n", fset.Arg(0))
+			fset.Usage()
+			return 2
+		}
+		files, err := t.Files(fset.Arg(1))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm new:", err)
+			return 2
+		}
+		out := *dir
+		if out == "" && t.Name == "library" {
+			out = fset.Arg(1)
+		}
+		if err := scaffold.Write(out, files); err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm new:", err)
+			return 1
+		}
+		for _, f := range files {
+			fmt.Println(filepath.Join(out, filepath.FromSlash(f.Path)))
+		}
+		return 0
+	}
+}
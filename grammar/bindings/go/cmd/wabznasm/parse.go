@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"os"
+	"runtime"
+	"sync"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+// parseRecord is the NDJSON record written for each file.
+type parseRecord struct {
+	Path        string             `json:"path"`
+	Bytes       int                `json:"bytes"`
+	Errors      int                `json:"errors"`
+	Diagnostics []diagnosticRecord `json:"diagnostics"`
+	// Trees are the syntax trees of the statements of the file, in order.
+	Trees []tree_sitter_wabznasm.NodeJSON `json:"trees,omitempty"`
+	// Error is set when the file could not be read or parsed at all.
+	Error string `json:"error,omitempty"`
+}
+
+// diagnosticRecord is a diagnostic of a parseRecord. Rows and columns are
+// zero-based; columns count bytes.
+type diagnosticRecord struct {
+	StartByte uint                           `json:"start_byte"`
+	EndByte   uint                           `json:"end_byte"`
+	Start     tree_sitter_wabznasm.PointJSON `json:"start"`
+	End       tree_sitter_wabznasm.PointJSON `json:"end"`
+	Severity  string                         `json:"severity"`
+	Code      string                         `json:"code"`
+	Message   string                         `json:"message"`
+	Expected  []string                       `json:"expected,omitempty"`
+}
+
+var parseCommand = &command{
+	summary:  "check the syntax of many files, writing JSON records",
+	synopsis: "[-tree] [-j n] [-watch] path ...",
+	help: "Writes one JSON record per file to standard output, in argument order,\n" +
+		"and exits with status 1 if any file has syntax errors.",
+	setup: setupParse,
+}
+
+func setupParse(fset *flag.FlagSet) func() int {
+	trees := fset.Bool("tree", false, "include the full syntax trees of the statements of each file")
+	jobs := fset.Int("j", runtime.GOMAXPROCS(0), "parse `n` files at a time")
+	watchMode := fset.Bool("watch", false, "write a file's record again whenever it is saved, until interrupted")
+	return func() int {
+		if fset.NArg() == 0 {
+			fset.Usage()
+			return 2
+		}
+		if *watchMode {
+			enc := json.NewEncoder(os.Stdout)
+			return watchSources(fset.Args(), func(path string, src []byte, script *tree_sitter_wabznasm.Script) bool {
+				rec := treeRecord(path, src, script, *trees)
+				enc.Encode(rec)
+				return rec.Errors == 0
+			})
+		}
+		status := 0
+		files := sourceFiles(fset.Args(), &status)
+
+		// Workers fill in records by index; the writer emits them in order as
+		// soon as each is ready, so output is deterministic and streams.
+		records := make([]chan parseRecord, len(files))
+		for i := range records {
+			records[i] = make(chan parseRecord, 1)
+		}
+		next := make(chan int)
+		var wg sync.WaitGroup
+		for range max(*jobs, 1) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				parser, err := tree_sitter_wabznasm.NewParser()
+				if parser != nil {
+					defer parser.Close()
+				}
+				for i := range next {
+					records[i] <- parseFile(parser, err, files[i], *trees)
+				}
+			}()
+		}
+		go func() {
+			for i := range files {
+				next <- i
+			}
+			close(next)
+		}()
+
+		w := bufio.NewWriter(os.Stdout)
+		enc := json.NewEncoder(w)
+		for _, ch := range records {
+			rec := <-ch
+			if rec.Errors > 0 || rec.Error != "" {
+				status = 1
+			}
+			enc.Encode(rec)
+			w.Flush()
+		}
+		wg.Wait()
+		return status
+	}
+}
+
+func parseFile(parser *tree_sitter_wabznasm.Parser, parserErr error, path string, withTree bool) parseRecord {
+	rec := parseRecord{Path: path, Diagnostics: []diagnosticRecord{}}
+	if parserErr != nil {
+		rec.Error = parserErr.Error()
+		return rec
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		rec.Error = err.Error()
+		return rec
+	}
+	script, err := parser.ParseScript(src)
+	if err != nil {
+		rec.Error = err.Error()
+		rec.Bytes = len(src)
+		return rec
+	}
+	defer script.Close()
+	return treeRecord(path, src, script, withTree)
+}
+
+// treeRecord is the record of a parsed file.
+func treeRecord(path string, src []byte, script *tree_sitter_wabznasm.Script, withTree bool) parseRecord {
+	rec := parseRecord{Path: path, Bytes: len(src), Diagnostics: []diagnosticRecord{}}
+	for _, d := range script.Diagnostics() {
+		if d.Severity == tree_sitter_wabznasm.SeverityError {
+			rec.Errors++
+		}
+		rec.Diagnostics = append(rec.Diagnostics, diagnosticRecord{
+			StartByte: d.Range.StartByte,
+			EndByte:   d.Range.EndByte,
+			Start:     tree_sitter_wabznasm.PointJSON{Row: d.Range.StartPoint.Row, Column: d.Range.StartPoint.Column},
+			End:       tree_sitter_wabznasm.PointJSON{Row: d.Range.EndPoint.Row, Column: d.Range.EndPoint.Column},
+			Severity:  d.Severity.String(),
+			Code:      d.Code,
+			Message:   d.Message,
+			Expected:  d.Expected,
+		})
+	}
+	if withTree {
+		for _, st := range script.Statements {
+			rec.Trees = append(rec.Trees, tree_sitter_wabznasm.ToJSON(st.Tree.RootNode(), st.Source))
+		}
+	}
+	return rec
+}
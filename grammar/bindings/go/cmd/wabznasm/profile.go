@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/profile"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+)
+
+var profileCommand = &command{
+	summary:  "time the evaluation of a script, expression by expression",
+	synopsis: "[-n runs] [-top n] [-o file] script",
+	help: "Evaluates a script and lists it annotated with the time spent on each\n" +
+		"line, followed by the hottest expressions and functions.",
+	setup: setupProfile,
+}
+
+func setupProfile(fset *flag.FlagSet) func() int {
+	out := fset.String("o", "", "also write a pprof profile to `file`")
+	top := fset.Int("top", 10, "list the `n` expressions taking the most time")
+	runs := fset.Int("n", 1, "evaluate the script `n` times, each in a fresh interpreter")
+	return func() int {
+		if fset.NArg() != 1 || *runs < 1 {
+			fset.Usage()
+			return 2
+		}
+		path := fset.Arg(0)
+		src, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm profile:", err)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		p := profile.New()
+		status := 0
+		for range *runs {
+			in := eval.New()
+			p.Attach(in)
+			err := repl.RunScript(ctx, in, src, func(st *repl.Statement) bool {
+				if st.Err != nil {
+					fmt.Fprintf(os.Stderr, "%s:%v\n", path, st.Err)
+					status = 1
+				}
+				return st.Err == nil
+			})
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm profile:", err)
+				return 1
+			}
+			if status != 0 {
+				break
+			}
+		}
+		p.Stop()
+
+		if err := p.WriteListing(os.Stdout, path, src, *top); err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm profile:", err)
+			return 1
+		}
+		if *out != "" {
+			f, err := os.Create(*out)
+			if err == nil {
+				err = p.WritePprof(f, path)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm profile:", err)
+				return 1
+			}
+		}
+		return status
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/regress"
+)
+
+var regressCommand = &command{
+	summary:  "compare how two versions of the grammar parse a corpus",
+	synopsis: "[-old lib.so] [-new lib.so] [-symbol name] [-diff] path...",
+	help: "Parses the sources and corpus files among the paths with two versions\n" +
+		"of the grammar and reports the inputs whose trees differ.",
+	setup: setupRegress,
+}
+
+func setupRegress(fset *flag.FlagSet) func() int {
+	oldPath := fset.String("old", "", "shared object `file` of the old grammar; the built-in grammar if empty")
+	newPath := fset.String("new", "", "shared object `file` of the new grammar; the built-in grammar if empty")
+	symbol := fset.String("symbol", regress.Symbol, "`name` of the language function in the shared objects")
+	showDiff := fset.Bool("diff", false, "print a diff of the trees of each changed input")
+	return func() int {
+		if fset.NArg() == 0 || *oldPath == *newPath {
+			fset.Usage()
+			return 2
+		}
+		load := func(path string) (*tree_sitter.Language, error) {
+			if path == "" {
+				return regress.Builtin(), nil
+			}
+			return regress.Load(path, *symbol)
+		}
+		oldLang, err := load(*oldPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm regress:", err)
+			return 1
+		}
+		newLang, err := load(*newPath)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm regress:", err)
+			return 1
+		}
+		inputs, err := regress.Corpus(fset.Args())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm regress:", err)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		results, err := regress.Compare(ctx, oldLang, newLang, inputs)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm regress:", err)
+			return 1
+		}
+		for _, r := range results {
+			if !r.Changed() {
+				continue
+			}
+			note := ""
+			switch {
+			case !r.OldError && r.NewError:
+				note = " (now has errors)"
+			case r.OldError && !r.NewError:
+				note = " (no longer has errors)"
+			}
+			fmt.Printf("%s: %v%s\n", r.Name, r.Divergence, note)
+			if *showDiff {
+				for _, line := range strings.Split(strings.TrimSuffix(r.Diff(), "\n"), "\n") {
+					fmt.Printf("\t%s\n", line)
+				}
+			}
+		}
+		s := regress.Summarize(results)
+		fmt.Printf("%d inputs, %d changed, %d broken, %d fixed\n", s.Inputs, s.Changed, s.Broken, s.Fixed)
+		if s.Changed > 0 {
+			return 1
+		}
+		return 0
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/replay"
+)
+
+var replayCommand = &command{
+	summary:  "replay a session recorded by repl -record",
+	synopsis: "[-q] file",
+	help: "Evaluates the entries of a session recorded by wabznasm repl -record again,\n" +
+		"answering the registered builtins with their recorded results, and\n" +
+		"fails if an outcome differs from the recorded one.",
+	setup: setupReplay,
+}
+
+func setupReplay(fset *flag.FlagSet) func() int {
+	quiet := fset.Bool("q", false, "print only where the replay departs from the recording")
+	return func() int {
+		if fset.NArg() != 1 {
+			fset.Usage()
+			return 2
+		}
+		f, err := os.Open(fset.Arg(0))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm replay:", err)
+			return 1
+		}
+		rec, err := replay.Read(f)
+		f.Close()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm replay:", err)
+			return 1
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		rp := replay.NewReplayer(rec)
+		in := eval.NewWith(append(repl.Builtins(), rp.Builtins()...)...)
+		steps, err := rp.Run(ctx, in)
+		if !*quiet {
+			for _, st := range steps {
+				fmt.Printf("%s%s\n", repl.Prompt, st.Source)
+				switch {
+				case st.Err != nil:
+					fmt.Printf("Error: %v\n", st.Err)
+				case st.Value != nil:
+					fmt.Printf("= %s\n", st.Value)
+				}
+			}
+		}
+		var de *replay.DivergenceError
+		if errors.As(err, &de) {
+			fmt.Fprintf(os.Stderr, "wabznasm replay: %s: recorded %s, replayed %s\n", de.Source, de.Want, de.Got)
+			return 1
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm replay:", err)
+			return 1
+		}
+		return 0
+	}
+}
+
+// writeRecording writes rec to the file at path.
+func writeRecording(path string, rec *replay.Recording) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = rec.Write(f)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
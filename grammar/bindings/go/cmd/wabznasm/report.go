@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/report"
+)
+
+// errReported is returned by helpers that have already printed their
+// error, so callers only set the exit status.
+var errReported = errors.New("error already reported")
+
+// reportSyntax prints the syntax errors of src to standard error.
+func reportSyntax(path string, src []byte) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer parser.Close()
+	script, err := parser.ParseScript(src)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		return
+	}
+	defer script.Close()
+	printer := report.New(os.Stderr, report.ColorEnabled(os.Stderr))
+	for _, d := range script.Diagnostics() {
+		printer.Print(path, src, report.FromDiagnostic(d))
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry/otlp"
+)
+
+// setupTelemetry configures package telemetry from the environment and
+// returns the function flushing the spans still to be exported.
+//
+// WABZNASM_LOG sets the level of the log written to standard error, such as
+// debug, with ",json" appended for JSON lines. The OpenTelemetry variables
+// read by otlp.FromEnv turn on exporting spans.
+func setupTelemetry() func() {
+	if spec := os.Getenv("WABZNASM_LOG"); spec != "" {
+		level, format, _ := strings.Cut(spec, ",")
+		l, err := telemetry.NewLogger(os.Stderr, level, format == "json")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm: WABZNASM_LOG:", err)
+		} else {
+			telemetry.SetDefault(l)
+		}
+	}
+	exp := otlp.FromEnv("wabznasm")
+	if exp == nil {
+		return func() {}
+	}
+	telemetry.SetExporter(exp)
+	return func() {
+		if err := exp.Close(); err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm: exporting spans:", err)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/cover"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/suite"
+)
+
+var testCommand = &command{
+	summary:  "run the assertions of *_test.wz files",
+	synopsis: "[flags] [path...]",
+	help: "Runs the *_test.wz files among the paths, searching directories\n" +
+		"recursively; the default is the current directory. Each file is\n" +
+		"evaluated after the library it tests, if one is beside it, and each\n" +
+		"statement making assertions, such as expect[f[2]; 4], is a test case.",
+	setup: setupTest,
+}
+
+func setupTest(fset *flag.FlagSet) func() int {
+	verbose := fset.Bool("v", false, "list the cases that pass too")
+	junit := fset.String("junit", "", "write a JUnit XML report to `file`")
+	asserts := fset.String("assert", "assert", "comma-separated `names` of the one-argument assertions")
+	expects := fset.String("expect", "expect", "comma-separated `names` of the assertions comparing two values")
+	tolerance := fset.Float64("tolerance", 0, "largest `difference` between floats taken as equal")
+	coverage := fset.Bool("cover", false, "report the coverage of the test files and their libraries")
+	lcovOut := fset.String("coverprofile", "", "write the coverage as an lcov trace file to `file`; implies -cover")
+	return func() int {
+		paths := fset.Args()
+		if len(paths) == 0 {
+			paths = []string{"."}
+		}
+		files, err := suite.Discover(paths)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm test:", err)
+			return 1
+		}
+		if len(files) == 0 {
+			fmt.Fprintln(os.Stderr, "wabznasm test: no test files")
+			return 1
+		}
+
+		cfg := suite.Config{Assert: names(*asserts), Expect: names(*expects), Tolerance: *tolerance}
+		var profile *cover.Profile
+		if *coverage || *lcovOut != "" {
+			profile = cover.New()
+			cfg.Instrument = profile.Attach
+			cfg.RunScript = profile.RunScript
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		status := 0
+		var results []*suite.Result
+		for _, path := range files {
+			r := suite.Run(ctx, cfg, path)
+			results = append(results, r)
+			printResult(r, *verbose)
+			if !r.Passed() {
+				status = 1
+			}
+			if ctx.Err() != nil {
+				break
+			}
+		}
+
+		if profile != nil {
+			s := profile.Summary()
+			fmt.Printf("coverage: %.1f%% of statements, %.1f%% of expressions\n", s.StatementPercent(), s.ExpressionPercent())
+		}
+		for _, out := range []struct {
+			path  string
+			write func(*os.File) error
+		}{
+			{*junit, func(f *os.File) error { return suite.WriteJUnit(f, results) }},
+			{*lcovOut, func(f *os.File) error { return profile.WriteLcov(f) }},
+		} {
+			if out.path == "" {
+				continue
+			}
+			f, err := os.Create(out.path)
+			if err == nil {
+				err = out.write(f)
+				if cerr := f.Close(); err == nil {
+					err = cerr
+				}
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm test:", err)
+				return 1
+			}
+		}
+		return status
+	}
+}
+
+// names splits a comma-separated list of names.
+func names(list string) []string {
+	out := []string{}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// printResult prints the failed cases of r, and with verbose the passed ones,
+// followed by a line summing up the file.
+func printResult(r *suite.Result, verbose bool) {
+	for _, c := range r.Cases {
+		where := fmt.Sprintf("%s:%d", r.Path, c.Pos.Row+1)
+		if c.Passed() {
+			if verbose {
+				fmt.Printf("--- PASS: %s: %s (%s)\n", where, c.Name, elapsed(c.Duration))
+			}
+			continue
+		}
+		fmt.Printf("--- FAIL: %s: %s (%s)\n", where, c.Name, elapsed(c.Duration))
+		if c.Err != nil {
+			fmt.Printf("    %s:%v\n", r.Path, c.Err)
+		}
+		for _, f := range c.Failures {
+			fmt.Printf("    %s: %s\n", f.Assertion, f.Message)
+			for _, line := range strings.Split(strings.TrimSuffix(f.Diff, "\n"), "\n") {
+				if line != "" {
+					fmt.Printf("        %s\n", line)
+				}
+			}
+		}
+	}
+	if r.Err != nil {
+		fmt.Printf("FAIL\t%s\t%v\n", r.Path, r.Err)
+		return
+	}
+	if failed := r.Failed(); failed > 0 {
+		fmt.Printf("FAIL\t%s\t%d of %d cases failed (%s)\n", r.Path, failed, len(r.Cases), elapsed(r.Duration))
+		return
+	}
+	fmt.Printf("ok\t%s\t%d cases (%s)\n", r.Path, len(r.Cases), elapsed(r.Duration))
+}
+
+func elapsed(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/transpile"
+)
+
+var transpileCommand = &command{
+	summary:  "translate q source to wabznasm",
+	synopsis: "[file.q ...]",
+	help:     "Translates q source to wabznasm, one statement per line.",
+	setup:    setupTranspile,
+}
+
+func setupTranspile(fset *flag.FlagSet) func() int {
+	return func() int {
+		paths := fset.Args()
+		if len(paths) == 0 {
+			paths = []string{"-"}
+		}
+		status := 0
+		for _, path := range paths {
+			var src []byte
+			var err error
+			name := path
+			if path == "-" {
+				name = "<stdin>"
+				src, err = io.ReadAll(os.Stdin)
+			} else {
+				src, err = os.ReadFile(path)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "wabznasm transpile:", err)
+				status = 1
+				continue
+			}
+			r, err := transpile.Transpile(src)
+			for _, issue := range r.Issues {
+				fmt.Fprintf(os.Stderr, "%s:%s\n", name, issue)
+			}
+			if err != nil {
+				status = 1
+			}
+			for _, s := range r.Statements {
+				fmt.Println(s.Source)
+			}
+		}
+		return status
+	}
+}
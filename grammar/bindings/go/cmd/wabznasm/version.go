@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"runtime/debug"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+var versionCommand = &command{
+	summary:  "print the versions of the tool, the grammar and tree-sitter",
+	synopsis: "[-json]",
+	help: "Prints the version of the tool, the version, tree-sitter ABI and content\n" +
+		"hash of the grammar in use, and the ABI versions the linked tree-sitter\n" +
+		"runtime supports. Include it in bug reports.",
+	setup: setupVersion,
+}
+
+func setupVersion(fset *flag.FlagSet) func() int {
+	asJSON := fset.Bool("json", false, "write a JSON object")
+	return func() int {
+		if fset.NArg() != 0 {
+			fset.Usage()
+			return 2
+		}
+		v := struct {
+			Version string                            `json:"version"`
+			Grammar tree_sitter_wabznasm.LanguageInfo `json:"grammar"`
+			Runtime struct {
+				MinABIVersion uint32 `json:"min_abi_version"`
+				ABIVersion    uint32 `json:"abi_version"`
+			} `json:"runtime"`
+		}{Version: "(devel)", Grammar: tree_sitter_wabznasm.Info()}
+		if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+			v.Version = info.Main.Version
+		}
+		v.Runtime.MinABIVersion = tree_sitter.MIN_COMPATIBLE_LANGUAGE_VERSION
+		v.Runtime.ABIVersion = tree_sitter.LANGUAGE_VERSION
+
+		if *asJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			enc.Encode(v)
+		} else {
+			fmt.Printf("wabznasm %s\n", v.Version)
+			fmt.Printf("grammar %s, ABI version %d, hash %s\n", v.Grammar.Version, v.Grammar.ABIVersion, v.Grammar.Hash)
+			fmt.Printf("tree-sitter runtime ABI versions %d to %d\n", v.Runtime.MinABIVersion, v.Runtime.ABIVersion)
+		}
+		if err := tree_sitter_wabznasm.CheckABI(tree_sitter.NewLanguage(tree_sitter_wabznasm.Language())); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/arity"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/report"
+)
+
+var vetCommand = &command{
+	summary:  "check a project for mistakes that span files",
+	synopsis: "[dir]",
+	help: "Checks the project in dir for mistakes that span files, such as calls with\n" +
+		"the wrong number of arguments, and exits with status 1 if there are any.",
+	setup: setupVet,
+	dirs:  true,
+}
+
+func setupVet(fset *flag.FlagSet) func() int {
+	return func() int {
+		root := "."
+		switch fset.NArg() {
+		case 0:
+		case 1:
+			root = fset.Arg(0)
+		default:
+			fset.Usage()
+			return 2
+		}
+
+		p, err := project.Open(root)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "wabznasm vet:", err)
+			return 1
+		}
+		printer := report.New(os.Stdout, report.ColorEnabled(os.Stdout))
+		status := 0
+		for _, m := range arity.Check(p) {
+			status = 1
+			printer.Print(m.Call.File, p.File(m.Call.File).Source, report.Message{
+				Range:    spanRange(m.Call.Span),
+				Severity: tree_sitter_wabznasm.SeverityError,
+				Code:     "arity",
+				Text:     m.Message(),
+			})
+			printer.Print(m.Def.File, p.File(m.Def.File).Source, report.Message{
+				Range:    spanRange(m.Def.Span),
+				Severity: tree_sitter_wabznasm.SeverityInformation,
+				Text:     m.Name + " is defined here",
+			})
+		}
+		return status
+	}
+}
+
+func spanRange(s ast.Span) tree_sitter.Range {
+	return tree_sitter.Range{
+		StartByte:  s.Start.Offset,
+		EndByte:    s.End.Offset,
+		StartPoint: tree_sitter.Point{Row: s.Start.Row, Column: s.Start.Column},
+		EndPoint:   tree_sitter.Point{Row: s.End.Row, Column: s.End.Column},
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/watch"
+)
+
+// watchSources calls check on each source named by args, as sourceFiles
+// finds them, and again whenever one is saved, until interrupted. Each
+// save parses the file again a statement at a time. check reports whether
+// the file is clean; a status line after each round says how many were
+// not.
+func watchSources(args []string, check func(path string, src []byte, script *tree_sitter_wabznasm.Script) bool) int {
+	roots := make([]string, len(args))
+	for i, arg := range args {
+		roots[i] = patternRoot(arg)
+	}
+	w := watch.New(roots...)
+	w.Match = project.IsSource
+
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer parser.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err = w.Run(ctx, func(events []watch.Event) {
+		checked, failed := 0, 0
+		for _, e := range events {
+			if e.Op == watch.Remove {
+				continue
+			}
+			checked++
+			src, err := os.ReadFile(e.Path)
+			var script *tree_sitter_wabznasm.Script
+			if err == nil {
+				script, err = parser.ParseScript(src)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed++
+				continue
+			}
+			if !check(e.Path, src, script) {
+				failed++
+			}
+			script.Close()
+		}
+		if checked > 0 {
+			fmt.Fprintf(os.Stderr, "[%s] checked %d file(s), %d with problems; watching for changes\n", time.Now().Format("15:04:05"), checked, failed)
+		}
+	})
+	if err != nil && !errors.Is(err, context.Canceled) {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
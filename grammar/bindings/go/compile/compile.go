@@ -0,0 +1,275 @@
+// Package compile lowers wabznasm statements to bytecode for a stack
+// machine, so that a formula compiled once can be evaluated many times
+// without walking its tree.
+//
+// Compiled programs evaluate exactly like package eval: the same values,
+// the same errors with the same codes and spans, and the same global
+// environment. Parameters are resolved to frame slots at compile time;
+// globals are looked up by name when they are used, so a program sees
+// assignments made after it was compiled. Function literals evaluate to
+// *Func values, which the interpreter can call, and programs can call
+// functions created by the interpreter.
+package compile
+
+import (
+	"fmt"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// Op is a bytecode operation.
+type Op uint8
+
+// Operations. Each pops its operands from the stack and pushes its result.
+const (
+	OpConst   Op = iota // push constant Arg
+	OpLocal             // push slot Arg&0xffff of the frame Arg>>16 levels out
+	OpGlobal            // push the global named by name Arg
+	OpNeg               // negate
+	OpFact              // factorial
+	OpAdd               // add
+	OpSub               // subtract
+	OpMul               // multiply
+	OpDiv               // divide
+	OpMod               // remainder
+	OpPow               // power
+	OpClosure           // push a function of the nested code Arg
+	OpCall              // call the function below Arg arguments
+	OpFail              // raise error Arg
+	OpReturn            // return the top of the stack
+)
+
+var opNames = [...]string{"const", "local", "global", "neg", "fact", "add", "sub", "mul", "div", "mod", "pow", "closure", "call", "fail", "return"}
+
+func (op Op) String() string {
+	if int(op) < len(opNames) {
+		return opNames[op]
+	}
+	return fmt.Sprintf("op(%d)", op)
+}
+
+// Instr is an operation and its argument.
+type Instr struct {
+	Op  Op
+	Arg int32
+}
+
+var binaryOps = map[string]Op{"+": OpAdd, "-": OpSub, "*": OpMul, "/": OpDiv, "%": OpMod, "^": OpPow}
+
+var opSymbols = map[Op]string{OpAdd: "+", OpSub: "-", OpMul: "*", OpDiv: "/", OpMod: "%", OpPow: "^"}
+
+// code is the compiled form of a statement or function body.
+type code struct {
+	instrs []Instr
+	// spans locates each instruction, for the errors it raises.
+	spans  []ast.Span
+	consts []eval.Value
+	names  []string
+	funcs  []*code
+	errors []*eval.Error
+
+	// Params are the slots of the frame, for function bodies.
+	params []string
+	source string
+}
+
+// Program is a compiled statement.
+type Program struct {
+	main *code
+	// assign is the name an assignment binds, or empty.
+	assign string
+}
+
+// CompileString parses and compiles src.
+func CompileString(src string) (*Program, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	return CompileTree(tree.Tree, []byte(src))
+}
+
+// CompileTree compiles a parse tree of source. Like eval's EvalTree, it
+// rejects trees containing syntax errors.
+func CompileTree(tree *tree_sitter.Tree, source []byte) (*Program, error) {
+	if tree.RootNode().HasError() {
+		return nil, eval.SyntaxError(tree, source)
+	}
+	return Compile(ast.FromTree(tree, source), source), nil
+}
+
+// Compile compiles a converted file. Errors the interpreter would raise,
+// such as for an invalid number, are raised when the program runs and
+// reaches them. Source is used to record the text of function literals and
+// may be nil.
+func Compile(f *ast.File, source []byte) *Program {
+	c := &compiler{code: &code{}, source: source}
+	p := &Program{main: c.code}
+	switch s := f.Stmt.(type) {
+	case nil:
+		return &Program{}
+	case *ast.Assignment:
+		p.assign = s.Name.Name
+		c.expr(s.Value, s)
+	case *ast.ExprStmt:
+		c.expr(s.X, s)
+	default:
+		c.fail(s, eval.CodeSyntax, "syntax error")
+	}
+	c.emit(OpReturn, 0, f)
+	return p
+}
+
+type compiler struct {
+	code   *code
+	parent *compiler
+	source []byte
+}
+
+func (c *compiler) emit(op Op, arg int, n ast.Node) {
+	c.code.instrs = append(c.code.instrs, Instr{Op: op, Arg: int32(arg)})
+	var span ast.Span
+	if n != nil {
+		span = ast.Span{Start: n.Pos(), End: n.EndPos()}
+	}
+	c.code.spans = append(c.code.spans, span)
+}
+
+func (c *compiler) fail(n ast.Node, errCode, format string, args ...any) {
+	e := &eval.Error{Code: errCode, Message: fmt.Sprintf(format, args...)}
+	if n != nil {
+		e.Span = ast.Span{Start: n.Pos(), End: n.EndPos()}
+	}
+	c.code.errors = append(c.code.errors, e)
+	c.emit(OpFail, len(c.code.errors)-1, nil)
+}
+
+// expr compiles e, which parent contains. A missing operand is reported at
+// the parent, as the interpreter does.
+func (c *compiler) expr(e ast.Expr, parent ast.Node) {
+	switch e := e.(type) {
+	case nil:
+		c.fail(parent, eval.CodeMissingOperand, "missing operand")
+	case *ast.NumberLiteral:
+		if e.Err != nil {
+			c.fail(e, eval.CodeInvalidNumber, "invalid number %s", e.Text)
+			return
+		}
+		c.code.consts = append(c.code.consts, eval.Long(e.Value))
+		c.emit(OpConst, len(c.code.consts)-1, e)
+	case *ast.Ident:
+		c.ident(e)
+	case *ast.ParenExpr:
+		c.expr(e.X, e)
+	case *ast.UnaryExpr:
+		c.expr(e.Operand, e)
+		c.emit(OpNeg, 0, e)
+	case *ast.PostfixExpr:
+		c.expr(e.Operand, e)
+		c.emit(OpFact, 0, e)
+	case *ast.BinaryExpr:
+		c.expr(e.Left, e)
+		c.expr(e.Right, e)
+		op, ok := binaryOps[e.Op]
+		if !ok {
+			c.fail(e, eval.CodeUnknownOperator, "unknown operator %s", e.Op)
+			return
+		}
+		c.emit(op, 0, e)
+	case *ast.FunctionDef:
+		c.function(e)
+	case *ast.Call:
+		if e.Func == nil {
+			c.fail(e, eval.CodeSyntax, "syntax error")
+			return
+		}
+		c.ident(e.Func)
+		for _, arg := range e.Args {
+			c.expr(arg, e)
+		}
+		c.emit(OpCall, len(e.Args), e)
+	case *ast.BadExpr:
+		c.fail(e, eval.CodeSyntax, "syntax error")
+	default:
+		c.fail(e, eval.CodeSyntax, "unsupported expression %T", e)
+	}
+}
+
+// ident loads a parameter of an enclosing function, or else a global.
+func (c *compiler) ident(id *ast.Ident) {
+	for depth, fc := 0, c; fc != nil; depth, fc = depth+1, fc.parent {
+		// A repeated parameter is bound to its last argument.
+		for slot := len(fc.code.params) - 1; slot >= 0; slot-- {
+			if fc.code.params[slot] == id.Name {
+				c.emit(OpLocal, depth<<16|slot, id)
+				return
+			}
+		}
+	}
+	for i, name := range c.code.names {
+		if name == id.Name {
+			c.emit(OpGlobal, i, id)
+			return
+		}
+	}
+	c.code.names = append(c.code.names, id.Name)
+	c.emit(OpGlobal, len(c.code.names)-1, id)
+}
+
+func (c *compiler) function(fn *ast.FunctionDef) {
+	body := &code{params: fn.Signature()}
+	if end := fn.EndPos().Offset; c.source != nil && end <= uint(len(c.source)) {
+		body.source = string(c.source[fn.Pos().Offset:end])
+	}
+	fc := &compiler{code: body, parent: c, source: c.source}
+	fc.expr(fn.Body, fn)
+	fc.emit(OpReturn, 0, fn)
+	c.code.funcs = append(c.code.funcs, body)
+	c.emit(OpClosure, len(c.code.funcs)-1, fn)
+}
+
+// Disassemble lists the instructions of the program and of the function
+// literals it contains.
+func (p *Program) Disassemble() string {
+	var b strings.Builder
+	if p.main == nil {
+		return ""
+	}
+	var list func(name string, c *code)
+	list = func(name string, c *code) {
+		fmt.Fprintf(&b, "%s:\n", name)
+		for pc, in := range c.instrs {
+			arg := ""
+			switch in.Op {
+			case OpConst:
+				arg = c.consts[in.Arg].String()
+			case OpLocal:
+				arg = fmt.Sprintf("%d %d", in.Arg>>16, in.Arg&0xffff)
+			case OpGlobal:
+				arg = c.names[in.Arg]
+			case OpClosure:
+				arg = fmt.Sprintf("%s.%d", name, in.Arg)
+			case OpCall:
+				arg = fmt.Sprint(in.Arg)
+			case OpFail:
+				arg = c.errors[in.Arg].Code
+			}
+			fmt.Fprintf(&b, "%s\n", strings.TrimRight(fmt.Sprintf("%4d  %-8s%s", pc, in.Op, arg), " "))
+		}
+		for i, fn := range c.funcs {
+			list(fmt.Sprintf("%s.%d", name, i), fn)
+		}
+	}
+	list("main", p.main)
+	return strings.TrimSuffix(b.String(), "\n")
+}
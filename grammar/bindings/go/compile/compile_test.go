@@ -0,0 +1,42 @@
+package compile_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/compile"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func TestDisassemble(t *testing.T) {
+	p, err := compile.CompileString("f: {[a] a*k+g[a;2]}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `main:
+   0  closure main.0
+   1  return
+main.0:
+   0  local   0 0
+   1  global  k
+   2  mul
+   3  global  g
+   4  local   0 0
+   5  const   2
+   6  call    2
+   7  add
+   8  return`
+	if got := p.Disassemble(); got != want {
+		t.Errorf("Disassemble() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCompileSyntaxError(t *testing.T) {
+	if _, err := compile.CompileString("1+"); err == nil {
+		t.Error("CompileString(1+) succeeded")
+	}
+}
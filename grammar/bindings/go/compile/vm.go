@@ -0,0 +1,136 @@
+package compile
+
+import (
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// frame holds the arguments of a call, and the frame of the call that
+// created the function, for parameters of enclosing functions.
+type frame struct {
+	locals []eval.Value
+	parent *frame
+}
+
+// Func is a function created by a compiled program. It implements
+// eval.Callable.
+type Func struct {
+	code    *code
+	env     *frame
+	globals *eval.Env
+}
+
+// Kind returns eval.KindFunction.
+func (f *Func) Kind() eval.Kind { return eval.KindFunction }
+
+// String returns the text of the function literal.
+func (f *Func) String() string {
+	if f.code.source != "" {
+		return f.code.source
+	}
+	return "{[" + strings.Join(f.code.params, ";") + "] ...}"
+}
+
+// Params returns the names of the function's parameters.
+func (f *Func) Params() []string { return append([]string(nil), f.code.params...) }
+
+// Arity returns the number of arguments the function expects.
+func (f *Func) Arity() int { return len(f.code.params) }
+
+// Call applies the function to args.
+func (f *Func) Call(args []eval.Value) (eval.Value, error) {
+	return run(f.code, &frame{locals: args, parent: f.env}, f.globals)
+}
+
+// Run evaluates the program against globals, binding the name of an
+// assignment there, and returns the value of the statement. An empty
+// program evaluates to a nil Value. A program may be run any number of
+// times, and concurrently if globals is not modified meanwhile.
+func (p *Program) Run(globals *eval.Env) (eval.Value, error) {
+	if p.main == nil {
+		return nil, nil
+	}
+	v, err := run(p.main, &frame{}, globals)
+	if err != nil {
+		return nil, err
+	}
+	if p.assign != "" {
+		globals.Define(p.assign, v)
+	}
+	return v, nil
+}
+
+func run(c *code, fr *frame, globals *eval.Env) (eval.Value, error) {
+	var buf [16]eval.Value
+	stack := buf[:0]
+	for pc, in := range c.instrs {
+		var v eval.Value
+		var err error
+		switch in.Op {
+		case OpConst:
+			v = c.consts[in.Arg]
+		case OpLocal:
+			f := fr
+			for depth := in.Arg >> 16; depth > 0; depth-- {
+				f = f.parent
+			}
+			v = f.locals[in.Arg&0xffff]
+		case OpGlobal:
+			var ok bool
+			if v, ok = globals.Lookup(c.names[in.Arg]); !ok {
+				err = &eval.Error{Code: eval.CodeUndefined, Message: "undefined variable: " + c.names[in.Arg]}
+			}
+		case OpNeg:
+			stack, v = stack[:len(stack)-1], stack[len(stack)-1]
+			v, err = eval.Negate(v)
+		case OpFact:
+			stack, v = stack[:len(stack)-1], stack[len(stack)-1]
+			v, err = eval.Factorial(v)
+		case OpAdd, OpSub, OpMul, OpDiv, OpMod, OpPow:
+			l, r := stack[len(stack)-2], stack[len(stack)-1]
+			stack = stack[:len(stack)-2]
+			v, err = eval.Binary(opSymbols[in.Op], l, r)
+		case OpClosure:
+			v = &Func{code: c.funcs[in.Arg], env: fr, globals: globals}
+		case OpCall:
+			n := len(stack) - int(in.Arg)
+			args := make([]eval.Value, in.Arg)
+			copy(args, stack[n:])
+			callee := stack[n-1]
+			stack = stack[:n-1]
+			v, err = call(callee, args)
+		case OpFail:
+			e := *c.errors[in.Arg]
+			err = &e
+		case OpReturn:
+			return stack[len(stack)-1], nil
+		}
+		if err != nil {
+			return nil, at(c.spans[pc], err)
+		}
+		stack = append(stack, v)
+	}
+	panic("compile: code does not return")
+}
+
+func call(callee eval.Value, args []eval.Value) (eval.Value, error) {
+	if f, ok := callee.(*Func); ok {
+		if len(args) != f.Arity() {
+			return nil, &eval.Error{Code: eval.CodeArity, Message: eval.ArityMessage(f.Arity(), len(args))}
+		}
+		return f.Call(args)
+	}
+	// Functions of the interpreter, and values that cannot be called.
+	return new(eval.Interpreter).Apply(callee, args)
+}
+
+// at fills in the span of err if it has none, as the interpreter attributes
+// errors raised by value helpers to the expression that triggered them.
+func at(span ast.Span, err error) error {
+	if e, ok := err.(*eval.Error); ok && e.Span == (ast.Span{}) {
+		e.Span = span
+	}
+	return err
+}
@@ -0,0 +1,172 @@
+package compile_test
+
+import (
+	"errors"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/compile"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// script is run statement by statement, by the interpreter and by compiled
+// programs, each against its own globals.
+var scripts = [][]string{
+	{"1+2*3", "2^3^2", "-7%3", "2*-3^2", "10/3", "5!", "-(2+3)"},
+	{"add: {[x;y] x+y}", "add[2;3]", "twice: {[f;v] f[f[v]]}", "triple: {x*3}", "twice[triple;2]"},
+	{"k: 10", "scale: {x*k}", "scale[4]", "k: 2", "scale[4]"},
+	{"dup: {[a;a] a}", "dup[1;2]", "xyz: {z}", "xyz[1;2;3]"},
+	{"1/0", "9223372036854775807+1", "2^-1", "(-1)!", "nope+1", "1+(2/0)"},
+	{"f: {[x] x+q}", "f[1]", "f[1;2]", "g: 3", "g[1]", "99999999999999999999"},
+	{"h: {1/x}", "h[0]", "{2+}"},
+}
+
+type outcome struct {
+	value string
+	code  string
+	err   string
+}
+
+func outcomeOf(v eval.Value, err error) outcome {
+	if err != nil {
+		var e *eval.Error
+		if errors.As(err, &e) {
+			return outcome{code: e.Code, err: e.Error()}
+		}
+		return outcome{err: err.Error()}
+	}
+	if v == nil {
+		return outcome{}
+	}
+	return outcome{value: v.String()}
+}
+
+func TestMatchesInterpreter(t *testing.T) {
+	for _, script := range scripts {
+		in := eval.New()
+		globals := eval.NewEnv(nil)
+		for _, src := range script {
+			want := outcomeOf(in.EvalString(src))
+			var got outcome
+			p, err := compile.CompileString(src)
+			if err != nil {
+				got = outcomeOf(nil, err)
+			} else {
+				got = outcomeOf(p.Run(globals))
+			}
+			if got != want {
+				t.Errorf("%s: compiled = %+v, interpreted = %+v", src, got, want)
+			}
+		}
+	}
+}
+
+func TestInterop(t *testing.T) {
+	in := eval.New()
+	if _, err := in.EvalString("inc: {x+1}"); err != nil {
+		t.Fatal(err)
+	}
+	p, err := compile.CompileString("sq: {[n] inc[n]*inc[n]}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sq, err := p.Run(in.Globals)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f, ok := sq.(*compile.Func); !ok || f.Arity() != 1 || f.String() != "{[n] inc[n]*inc[n]}" {
+		t.Fatalf("sq = %#v", sq)
+	}
+	v, err := in.EvalString("sq[3]+1")
+	if err != nil || v.String() != "17" {
+		t.Errorf("sq[3]+1 = %v, %v", v, err)
+	}
+	if _, err := in.EvalString("sq[1;2]"); err == nil {
+		t.Error("interpreter called compiled function with two arguments")
+	}
+}
+
+func TestRunRepeatedly(t *testing.T) {
+	p, err := compile.CompileString("a*b+c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	globals := eval.NewEnv(nil)
+	for i := int64(0); i < 3; i++ {
+		globals.Define("a", eval.Long(i))
+		globals.Define("b", eval.Long(2))
+		globals.Define("c", eval.Long(1))
+		v, err := p.Run(globals)
+		if err != nil || v != eval.Long(2*i+1) {
+			t.Errorf("run %d = %v, %v", i, v, err)
+		}
+	}
+}
+
+const (
+	benchDefs    = "f: {[x;y] (x*y)+(x-y)%7}"
+	benchFormula = "f[a;b]+f[b;c]*2-a^2"
+)
+
+func benchGlobals(define func(src string)) {
+	define(benchDefs)
+	for _, src := range []string{"a: 12", "b: 34", "c: 56"} {
+		define(src)
+	}
+}
+
+func parseFile(b *testing.B, src string) *ast.File {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer tree.Close()
+	return ast.FromTree(tree.Tree, []byte(src))
+}
+
+func BenchmarkInterpreter(b *testing.B) {
+	in := eval.New()
+	benchGlobals(func(src string) {
+		if _, err := in.EvalString(src); err != nil {
+			b.Fatal(err)
+		}
+	})
+	// The interpreter walks a tree converted once, as the program is
+	// compiled once.
+	f := parseFile(b, benchFormula)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := in.EvalFile(f, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCompiled(b *testing.B) {
+	globals := eval.NewEnv(nil)
+	benchGlobals(func(src string) {
+		p, err := compile.CompileString(src)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := p.Run(globals); err != nil {
+			b.Fatal(err)
+		}
+	})
+	p, err := compile.CompileString(benchFormula)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Run(globals); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
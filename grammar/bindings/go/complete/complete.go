@@ -0,0 +1,346 @@
+// Package complete proposes completions at a cursor in wabznasm source.
+//
+// What is proposed depends on where the cursor is. Where an expression may
+// start, the names in scope are offered: parameters of the enclosing
+// functions, globals of the source and globals defined elsewhere. After an
+// operand, the operators are offered instead. At the start of a statement
+// and after the colon of an assignment, the Snippets are added.
+// Nothing is offered inside comments, numbers or parameter lists, where a
+// new name is being written.
+package complete
+
+import (
+	"sort"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// Kind classifies a completion.
+type Kind int
+
+const (
+	Variable Kind = iota
+	Function
+	Parameter
+	Operator
+	Snippet
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Variable:
+		return "variable"
+	case Function:
+		return "function"
+	case Parameter:
+		return "parameter"
+	case Operator:
+		return "operator"
+	case Snippet:
+		return "snippet"
+	}
+	return "unknown"
+}
+
+// Item is a proposed completion.
+type Item struct {
+	Label  string
+	Kind   Kind
+	Detail string
+	// InsertText replaces the prefix. For snippets it uses the LSP snippet
+	// syntax, with $1, ${2:default} and $0 marking tab stops.
+	InsertText string
+	// Score ranks the item; items are returned highest first.
+	Score int
+}
+
+// Result is the completions at a cursor.
+type Result struct {
+	// Prefix is the part of a name before the cursor that the items
+	// complete, and Start its byte offset.
+	Prefix string
+	Start  uint
+	Items  []Item
+}
+
+// Globals lists names defined by other sources. *project.Project
+// implements it.
+type Globals interface {
+	Globals() []string
+}
+
+// Options configures Complete.
+type Options struct {
+	// Globals, if set, adds names defined by other sources.
+	Globals Globals
+}
+
+// Template is a snippet of a construct of the grammar, offered as a
+// completion.
+type Template struct {
+	Label  string
+	Detail string
+	// Body is the text inserted, in the LSP snippet syntax.
+	Body string
+	// Construct is the kind of the node Body parses to, as named by the
+	// Kind constants of the root package.
+	Construct string
+	// Statement marks a whole statement, offered at the start of a
+	// source; other snippets are values, offered after the colon of an
+	// assignment.
+	Statement bool
+}
+
+// Snippets are the snippets Complete offers. The grammar has no
+// conditionals or loops, so there are none for them; peach stands in for
+// an each loop.
+var Snippets = []Template{
+	{Label: "fn", Detail: "function definition", Body: "${1:name}: {[${2:x}] $0}", Construct: tree_sitter_wabznasm.KindFunctionBody, Statement: true},
+	{Label: "let", Detail: "assignment", Body: "${1:name}: $0", Construct: tree_sitter_wabznasm.KindAssignment, Statement: true},
+	{Label: "each", Detail: "apply to each item, in parallel", Body: "peach[${1:f}; ${2:list}]", Construct: tree_sitter_wabznasm.KindFunctionCall, Statement: true},
+	{Label: "fn", Detail: "function literal", Body: "{[${1:x}] $0}", Construct: tree_sitter_wabznasm.KindFunctionBody},
+	{Label: "lambda", Detail: "function of x, y and z", Body: "{${1:x}}", Construct: tree_sitter_wabznasm.KindFunctionBody},
+	{Label: "each", Detail: "apply to each item, in parallel", Body: "peach[${1:f}; ${2:list}]", Construct: tree_sitter_wabznasm.KindFunctionCall},
+}
+
+// Scores of the item kinds, before bonuses for matching the prefix's case.
+const (
+	scoreParameter = 400
+	scoreLocal     = 300
+	scoreExternal  = 200
+	scoreSnippet   = 100
+	scoreOperator  = 50
+	caseBonus      = 10
+)
+
+var operators = []Item{
+	{Label: "+", Detail: "add"},
+	{Label: "-", Detail: "subtract"},
+	{Label: "*", Detail: "multiply"},
+	{Label: "/", Detail: "divide"},
+	{Label: "%", Detail: "remainder"},
+	{Label: "^", Detail: "power"},
+	{Label: "!", Detail: "factorial"},
+}
+
+// context is the syntactic position of the cursor.
+type context int
+
+const (
+	none      context = iota // comment, number, parameter list
+	statement                // start of the source
+	value                    // just after the colon of an assignment
+	operand                  // where an expression may start
+	operator                 // after a complete operand
+)
+
+// Complete returns the completions at the byte offset in source, ranked
+// and filtered by the name prefix before it.
+func Complete(tree *tree_sitter.Tree, source []byte, offset uint, opts Options) Result {
+	offset = min(offset, uint(len(source)))
+	start := offset
+	for start > 0 && isNameByte(source[start-1]) {
+		start--
+	}
+	res := Result{Prefix: string(source[start:offset]), Start: start}
+	if res.Prefix != "" && isDigit(res.Prefix[0]) {
+		return res
+	}
+
+	toks := tree_sitter_wabznasm.Tokens(tree, source)
+	ctx := contextAt(toks, start, offset)
+	if ctx == operator && res.Prefix == "" {
+		for _, op := range operators {
+			op.Kind, op.InsertText, op.Score = Operator, op.Label, scoreOperator
+			res.Items = append(res.Items, op)
+		}
+		return res
+	}
+	if ctx == none || ctx == operator {
+		return res
+	}
+
+	c := &collector{prefix: res.Prefix, names: map[string]bool{}}
+	tab := scopes.FromTree(tree, source)
+	for s := scopeAt(tab, source, start); s != nil; s = s.Parent {
+		c.scope(s, start)
+	}
+	if opts.Globals != nil {
+		names := opts.Globals.Globals()
+		sort.Strings(names)
+		for _, name := range names {
+			c.add(Item{Label: name, Kind: Variable, Detail: "global", InsertText: name, Score: scoreExternal})
+		}
+	}
+	if ctx == statement || ctx == value {
+		for _, sn := range Snippets {
+			if sn.Statement == (ctx == statement) {
+				c.add(Item{Label: sn.Label, Kind: Snippet, Detail: sn.Detail, InsertText: sn.Body, Score: scoreSnippet})
+			}
+		}
+	}
+	res.Items = c.items
+	sort.SliceStable(res.Items, func(i, j int) bool {
+		if res.Items[i].Score != res.Items[j].Score {
+			return res.Items[i].Score > res.Items[j].Score
+		}
+		return res.Items[i].Label < res.Items[j].Label
+	})
+	return res
+}
+
+type collector struct {
+	prefix string
+	names  map[string]bool
+	items  []Item
+}
+
+// add keeps item if its label starts with the prefix, ignoring case. Names
+// are added innermost scope first, so a name already added shadows item.
+func (c *collector) add(item Item) {
+	if item.Kind != Snippet {
+		if c.names[item.Label] {
+			return
+		}
+		c.names[item.Label] = true
+	}
+	if !strings.HasPrefix(strings.ToLower(item.Label), strings.ToLower(c.prefix)) {
+		return
+	}
+	if strings.HasPrefix(item.Label, c.prefix) {
+		item.Score += caseBonus
+	}
+	c.items = append(c.items, item)
+}
+
+// scope adds the names s defines, leaving out the one being typed at start.
+func (c *collector) scope(s *scopes.Scope, start uint) {
+	names := make([]string, 0, len(s.Symbols))
+	for name := range s.Symbols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if s.Func != nil && s.Func.Implicit() {
+		for _, name := range []string{"x", "y", "z"} {
+			if s.Symbols[name] == nil {
+				names = append(names, name)
+			}
+		}
+	}
+	for _, name := range names {
+		sym := s.Symbols[name]
+		switch {
+		case sym == nil:
+			c.add(Item{Label: name, Kind: Parameter, Detail: "implicit parameter", InsertText: name, Score: scoreParameter})
+		case sym.Kind == scopes.Free:
+		case sym.Decl != nil && sym.Decl.Pos().Offset == start:
+		case sym.Kind == scopes.Param || sym.Kind == scopes.Implicit:
+			c.add(Item{Label: name, Kind: Parameter, Detail: sym.Kind.String(), InsertText: name, Score: scoreParameter})
+		case sym.Func != nil:
+			c.add(Item{Label: name, Kind: Function, Detail: "{[" + strings.Join(sym.Func.Signature(), ";") + "]}", InsertText: name, Score: scoreLocal})
+		default:
+			c.add(Item{Label: name, Kind: Variable, Detail: sym.Kind.String(), InsertText: name, Score: scoreLocal})
+		}
+	}
+}
+
+// scopeAt is Table.ScopeAt, except that a function whose closing brace is
+// still missing extends to the end of the source.
+func scopeAt(tab *scopes.Table, source []byte, offset uint) *scopes.Scope {
+	s := tab.Global
+	for {
+		var next *scopes.Scope
+		for _, c := range s.Children {
+			span := c.Span
+			inside := span.Start.Offset < offset && offset < span.End.Offset
+			open := offset == span.End.Offset && (span.End.Offset == 0 || source[span.End.Offset-1] != '}')
+			if inside || open {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return s
+		}
+		s = next
+	}
+}
+
+// contextAt classifies the position of a name being typed from start to
+// offset by the tokens before it.
+func contextAt(toks []tree_sitter_wabznasm.Token, start, offset uint) context {
+	var prev []tree_sitter_wabznasm.Token // significant tokens before start
+	for _, t := range toks {
+		if t.Range.StartByte >= start {
+			break
+		}
+		if t.IsComment() && offset <= t.Range.EndByte {
+			return none
+		}
+		if !t.Extra {
+			prev = append(prev, t)
+		}
+	}
+	if len(prev) == 0 {
+		return statement
+	}
+	// Inside an unclosed parameter list, a new name is being written.
+	depth := 0
+	for i := len(prev) - 1; i >= 0; i-- {
+		switch prev[i].Kind {
+		case "]", ")", "}":
+			depth++
+		case "(", "{":
+			depth--
+		case "[":
+			if depth == 0 && i > 0 && prev[i-1].Kind == "{" {
+				return none
+			}
+			depth--
+		}
+		if depth < 0 {
+			break
+		}
+	}
+	switch last := prev[len(prev)-1]; last.Kind {
+	case "]":
+		if closesParams(prev) {
+			return operand
+		}
+		return operator
+	case ":":
+		return value
+	case "identifier", "number", ")", "}", "!":
+		return operator
+	case tree_sitter_wabznasm.KindError:
+		return none
+	}
+	return operand
+}
+
+// closesParams reports whether the last of toks, a "]", closes the
+// parameter list of a function body.
+func closesParams(toks []tree_sitter_wabznasm.Token) bool {
+	depth := 0
+	for i := len(toks) - 1; i >= 0; i-- {
+		switch toks[i].Kind {
+		case "]", ")", "}":
+			depth++
+		case "[", "(", "{":
+			depth--
+		}
+		if depth == 0 {
+			return toks[i].Kind == "[" && i > 0 && toks[i-1].Kind == "{"
+		}
+	}
+	return false
+}
+
+func isNameByte(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || isDigit(b)
+}
+
+func isDigit(b byte) bool { return '0' <= b && b <= '9' }
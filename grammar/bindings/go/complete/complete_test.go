@@ -0,0 +1,112 @@
+package complete_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/complete"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func parse(t *testing.T, src string) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(parser.Close)
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+type globals []string
+
+func (g globals) Globals() []string { return append([]string(nil), g...) }
+
+// labels completes at the | in src.
+func labels(t *testing.T, src string, opts complete.Options) (string, []string) {
+	t.Helper()
+	offset := strings.Index(src, "|")
+	src = src[:offset] + src[offset+1:]
+	res := complete.Complete(parse(t, src).Tree, []byte(src), uint(offset), opts)
+	var out []string
+	for _, item := range res.Items {
+		out = append(out, item.Label)
+	}
+	return res.Prefix, out
+}
+
+func TestComplete(t *testing.T) {
+	opts := complete.Options{Globals: globals{"total", "add"}}
+	tests := []struct {
+		src    string
+		prefix string
+		want   string
+	}{
+		{"add: {[a;b] a+|}", "", "a b add total"},
+		{"add: {[alpha;b] al|}", "al", "alpha"},
+		{"sq: {x*|}", "", "x y z sq add total"},
+		{"f: {[n] n|", "n", "n"},
+		{"t: 1 |", "", "+ - * / % ^ !"},
+		{"t: f[1;|]", "", "t add total"},
+		{"t|", "t", "total"},
+		{"|", "", "add total each fn let"},
+		{"g: |", "", "add total each fn lambda"},
+		{"f: {[a;|", "", ""},
+		{"t: 1 \\ to|", "to", ""},
+		{"t: 12|", "12", ""},
+	}
+	for _, tt := range tests {
+		prefix, got := labels(t, tt.src, opts)
+		if prefix != tt.prefix || strings.Join(got, " ") != tt.want {
+			t.Errorf("Complete(%q) = %q %q, want %q %q", tt.src, prefix, strings.Join(got, " "), tt.prefix, tt.want)
+		}
+	}
+}
+
+func TestCompleteCase(t *testing.T) {
+	_, got := labels(t, "t: {[Abc;abd] ab|}", complete.Options{})
+	if strings.Join(got, " ") != "abd Abc" {
+		t.Errorf("items = %q, want the exact-case match first", got)
+	}
+}
+
+// placeholder matches the tab stops of a snippet, with their defaults.
+var placeholder = regexp.MustCompile(`\$\{\d+:([^}]*)\}|\$\d+`)
+
+func TestSnippets(t *testing.T) {
+	for _, sn := range complete.Snippets {
+		// The final tab stop is where the body of a function, or the value
+		// of an assignment, goes.
+		src := placeholder.ReplaceAllStringFunc(sn.Body, func(m string) string {
+			if m == "$0" {
+				return "1"
+			}
+			return placeholder.FindStringSubmatch(m)[1]
+		})
+		if !sn.Statement {
+			src = "v: " + src
+		}
+		tree := parse(t, src)
+		root := tree.RootNode()
+		found := false
+		var walk func(n *tree_sitter.Node)
+		walk = func(n *tree_sitter.Node) {
+			found = found || n.Kind() == sn.Construct
+			for i := uint(0); i < n.ChildCount(); i++ {
+				walk(n.Child(i))
+			}
+		}
+		walk(root)
+		if root.HasError() || !found {
+			t.Errorf("snippet %s (%s) expands to %q, which does not parse to a %s", sn.Label, sn.Detail, src, sn.Construct)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package tree_sitter_wabznasm
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+)
+
+// ErrParseTimeout is returned when a parse runs past the budget set with
+// SetTimeoutMicros.
+var ErrParseTimeout = errors.New("wabznasm: parse timed out")
+
+// SetTimeoutMicros limits every later parse by p to the given number of
+// microseconds; zero removes the limit. A parse that runs out of time
+// fails with ErrParseTimeout. The budget suits interactive callers, such as
+// a REPL, that would rather report a slow entry than wait for it.
+func (p *Parser) SetTimeoutMicros(micros uint64) {
+	p.timeout = time.Duration(micros) * time.Microsecond
+}
+
+// TimeoutMicros returns the budget set with SetTimeoutMicros.
+func (p *Parser) TimeoutMicros() uint64 {
+	return uint64(p.timeout / time.Microsecond)
+}
+
+// ParseContext parses src, abandoning the parse once ctx is done, in which
+// case it returns ctx.Err(). The returned tree must be closed by the caller.
+func (p *Parser) ParseContext(ctx context.Context, src []byte) (*memtrack.TrackedTree, error) {
+	return p.ReparseContext(ctx, src, nil)
+}
+
+// ReparseContext is Reparse with the cancellation of ParseContext. Both
+// are timed as a "parse" span of ctx; see package telemetry.
+func (p *Parser) ReparseContext(ctx context.Context, src []byte, old *tree_sitter.Tree) (*memtrack.TrackedTree, error) {
+	ctx, span := telemetry.Start(ctx, "parse", slog.Int("bytes", len(src)), slog.Bool("incremental", old != nil))
+	tree, err := p.parse(ctx, func(i int, _ tree_sitter.Point) []byte {
+		if i < len(src) {
+			return src[i:]
+		}
+		return nil
+	}, old)
+	span.End(err)
+	return tree, err
+}
+
+// parse runs a parse that stops early when ctx is done or the timeout
+// passes. tree-sitter polls the progress callback as it works, so neither
+// needs a goroutine. A stopped parser would resume the abandoned parse on
+// its next call, so it is reset first.
+func (p *Parser) parse(ctx context.Context, read func(int, tree_sitter.Point) []byte, old *tree_sitter.Tree) (*memtrack.TrackedTree, error) {
+	if p.inner == nil {
+		return nil, ErrParserClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var opts *tree_sitter.ParseOptions
+	var deadline time.Time
+	done := ctx.Done()
+	if p.timeout > 0 {
+		deadline = time.Now().Add(p.timeout)
+	}
+	if done != nil || p.timeout > 0 {
+		opts = &tree_sitter.ParseOptions{ProgressCallback: func(tree_sitter.ParseState) bool {
+			select {
+			case <-done:
+				return true
+			default:
+				return p.timeout > 0 && time.Now().After(deadline)
+			}
+		}}
+	}
+	if tree := p.inner.ParseWithOptions(read, old, opts); tree != nil {
+		return memtrack.NewTree(tree), nil
+	}
+	p.inner.Reset()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if p.timeout > 0 && time.Now().After(deadline) {
+		return nil, ErrParseTimeout
+	}
+	return nil, ErrParseFailed
+}
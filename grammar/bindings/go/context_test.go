@@ -0,0 +1,64 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+func TestParseContext(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := parser.ParseContext(ctx, []byte("1+2")); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: err = %v, want context.Canceled", err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Microsecond)
+	defer cancel()
+	time.Sleep(time.Millisecond)
+	if _, err := parser.ParseContext(ctx, largeSource()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expired: err = %v, want context.DeadlineExceeded", err)
+	}
+
+	// An abandoned parse must not leak into the next one.
+	tree, err := parser.ParseContext(context.Background(), []byte("f[1;2]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	if got := tree.RootNode().ToSexp(); tree.RootNode().HasError() || tree.RootNode().EndByte() != 6 {
+		t.Errorf("after cancellation: %s", got)
+	}
+}
+
+func TestParserTimeout(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+
+	parser.SetTimeoutMicros(1)
+	if got := parser.TimeoutMicros(); got != 1 {
+		t.Errorf("TimeoutMicros = %d, want 1", got)
+	}
+	if _, err := parser.ParseBytes(largeSource()); !errors.Is(err, tree_sitter_wabznasm.ErrParseTimeout) {
+		t.Errorf("err = %v, want ErrParseTimeout", err)
+	}
+
+	parser.SetTimeoutMicros(0)
+	tree, err := parser.ParseBytes(largeSource())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Close()
+}
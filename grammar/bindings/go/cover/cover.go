@@ -0,0 +1,251 @@
+// Package cover measures which expressions of wabznasm scripts are
+// evaluated, for coverage reports of the test suites of formula
+// libraries.
+//
+// A Profile holds the files being measured. Each expression of a file is
+// a block; blocks that begin a statement or the body of a function are
+// also statements. A Profile attached to an interpreter counts the
+// evaluations of each block, attributing the body of a function to the
+// file the function was defined in, wherever it is called from. The
+// result can be written as an lcov trace file, for CI services and
+// editors, or as an HTML page showing the covered and uncovered code.
+package cover
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+)
+
+// Block is an expression of a file and the number of times it was
+// evaluated.
+type Block struct {
+	Span ast.Span
+	// Statement reports whether the expression is the value of a statement
+	// or the body of a function.
+	Statement bool
+	Count     int64
+}
+
+// Func is a function assigned to a name by a statement of a file.
+type Func struct {
+	Name string
+	// Pos is where the statement assigning the function begins.
+	Pos ast.Pos
+	// Body is the block of the function's body, whose count is the number
+	// of calls.
+	Body *Block
+}
+
+// File is a measured script.
+type File struct {
+	Path   string
+	Source []byte
+	// Blocks are the expressions of the file, in order of their starts.
+	Blocks []*Block
+	Funcs  []Func
+	index  map[[2]uint]*Block
+}
+
+// Summary counts the statements and expressions of some files and how
+// many of them were evaluated.
+type Summary struct {
+	Statements, StatementsCovered   int
+	Expressions, ExpressionsCovered int
+}
+
+// StatementPercent returns the percentage of statements covered, or 100
+// if there are none.
+func (s Summary) StatementPercent() float64 {
+	return percent(s.StatementsCovered, s.Statements)
+}
+
+// ExpressionPercent returns the percentage of expressions covered, or 100
+// if there are none.
+func (s Summary) ExpressionPercent() float64 {
+	return percent(s.ExpressionsCovered, s.Expressions)
+}
+
+func percent(n, of int) float64 {
+	if of == 0 {
+		return 100
+	}
+	return 100 * float64(n) / float64(of)
+}
+
+func (s *Summary) add(o Summary) {
+	s.Statements += o.Statements
+	s.StatementsCovered += o.StatementsCovered
+	s.Expressions += o.Expressions
+	s.ExpressionsCovered += o.ExpressionsCovered
+}
+
+// Summary counts the blocks of f.
+func (f *File) Summary() Summary {
+	var s Summary
+	for _, b := range f.Blocks {
+		covered := b.Count > 0
+		s.Expressions++
+		if covered {
+			s.ExpressionsCovered++
+		}
+		if b.Statement {
+			s.Statements++
+			if covered {
+				s.StatementsCovered++
+			}
+		}
+	}
+	return s
+}
+
+// Profile is the coverage of a set of files. It is not safe for
+// concurrent use.
+type Profile struct {
+	Files   []*File
+	byPath  map[string]*File
+	current *File
+	// owner is the file of each expression of the bodies of the
+	// functions defined so far.
+	owner map[ast.Expr]*File
+}
+
+// New returns an empty profile.
+func New() *Profile {
+	return &Profile{byPath: map[string]*File{}, owner: map[ast.Expr]*File{}}
+}
+
+// Summary counts the blocks of all the files of p.
+func (p *Profile) Summary() Summary {
+	var s Summary
+	for _, f := range p.Files {
+		s.add(f.Summary())
+	}
+	return s
+}
+
+// Add parses src, the script at path, and adds it to the files measured.
+// Adding a path again returns the file already added.
+func (p *Profile) Add(path string, src []byte) (*File, error) {
+	if f := p.byPath[path]; f != nil {
+		return f, nil
+	}
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	f := &File{Path: path, Source: src, index: map[[2]uint]*Block{}}
+	for _, e := range repl.Split(string(src)) {
+		text := e.Isolate(src)
+		tree, err := parser.ParseBytes(text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		file := ast.FromTree(tree.Tree, text)
+		tree.Close()
+		var root ast.Expr
+		switch s := file.Stmt.(type) {
+		case *ast.Assignment:
+			root = s.Value
+			if def, ok := s.Value.(*ast.FunctionDef); ok && def.Body != nil {
+				f.Funcs = append(f.Funcs, Func{Name: s.Name.Name, Pos: s.Pos(), Body: f.block(def.Body, true)})
+			}
+		case *ast.ExprStmt:
+			root = s.X
+		}
+		if root == nil {
+			continue
+		}
+		f.block(root, true)
+		ast.Inspect(root, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.ParamList:
+				// Parameters are bound, never evaluated.
+				return false
+			case *ast.FunctionDef:
+				if n.Body != nil {
+					f.block(n.Body, true)
+				}
+			}
+			if x, ok := n.(ast.Expr); ok && !isBad(x) {
+				f.block(x, false)
+			}
+			return true
+		})
+	}
+	sort.SliceStable(f.Blocks, func(i, j int) bool {
+		a, b := f.Blocks[i].Span, f.Blocks[j].Span
+		if a.Start.Offset != b.Start.Offset {
+			return a.Start.Offset < b.Start.Offset
+		}
+		return a.End.Offset > b.End.Offset
+	})
+	p.Files = append(p.Files, f)
+	p.byPath[path] = f
+	return f, nil
+}
+
+func isBad(e ast.Expr) bool {
+	_, ok := e.(*ast.BadExpr)
+	return ok
+}
+
+// block returns the block of e, adding it if it is new.
+func (f *File) block(e ast.Expr, statement bool) *Block {
+	key := [2]uint{e.Pos().Offset, e.EndPos().Offset}
+	b := f.index[key]
+	if b == nil {
+		b = &Block{Span: ast.Span{Start: e.Pos(), End: e.EndPos()}}
+		f.index[key] = b
+		f.Blocks = append(f.Blocks, b)
+	}
+	b.Statement = b.Statement || statement
+	return b
+}
+
+// Attach installs p as the Hook of in, replacing any set there.
+func (p *Profile) Attach(in *eval.Interpreter) {
+	in.Hook = p.hook
+}
+
+func (p *Profile) hook(e ast.Expr, env *eval.Env) error {
+	f := p.owner[e]
+	if f == nil {
+		f = p.current
+	}
+	if f == nil {
+		return nil
+	}
+	if def, ok := e.(*ast.FunctionDef); ok && def.Body != nil {
+		ast.Inspect(def.Body, func(n ast.Node) bool {
+			if x, ok := n.(ast.Expr); ok {
+				p.owner[x] = f
+			}
+			return true
+		})
+	}
+	if b := f.index[[2]uint{e.Pos().Offset, e.EndPos().Offset}]; b != nil {
+		b.Count++
+	}
+	return nil
+}
+
+// RunScript adds the script at path to p and evaluates it with in, as
+// repl.RunScript does, counting its evaluations. In must be attached to
+// p.
+func (p *Profile) RunScript(ctx context.Context, in *eval.Interpreter, path string, src []byte, report func(*repl.Statement) bool) error {
+	f, err := p.Add(path, src)
+	if err != nil {
+		return err
+	}
+	prev := p.current
+	p.current = f
+	defer func() { p.current = prev }()
+	return repl.RunScript(ctx, in, src, report)
+}
@@ -0,0 +1,151 @@
+package cover_test
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/cover"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+const lib = `sq: {[x] x*x}
+cube: {[x]
+  x*sq[x]
+}
+`
+
+const test = `sq[3]+1
+`
+
+func run(t *testing.T) *cover.Profile {
+	t.Helper()
+	p := cover.New()
+	in := eval.New()
+	p.Attach(in)
+	for _, f := range []struct{ path, src string }{{"lib.wz", lib}, {"lib_test.wz", test}} {
+		err := p.RunScript(context.Background(), in, f.path, []byte(f.src), func(st *repl.Statement) bool {
+			if st.Err != nil {
+				t.Fatal(st.Err)
+			}
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return p
+}
+
+func TestProfile(t *testing.T) {
+	p := run(t)
+	if len(p.Files) != 2 {
+		t.Fatalf("%d files, want 2", len(p.Files))
+	}
+	counts := map[string]int64{}
+	for _, b := range p.Files[0].Blocks {
+		counts[lib[b.Span.Start.Offset:b.Span.End.Offset]] += b.Count
+	}
+	for text, want := range map[string]int64{
+		"{[x] x*x}":          1,
+		"x*x":                1,
+		"x":                  2, // the operands of x*x; cube's are never evaluated
+		"x*sq[x]":            0,
+		"sq[x]":              0,
+		"{[x]\n  x*sq[x]\n}": 1,
+	} {
+		if got := counts[text]; got != want {
+			t.Errorf("count of %q = %d, want %d", text, got, want)
+		}
+	}
+	if _, ok := counts["[x]"]; ok {
+		t.Error("parameter list is a block")
+	}
+
+	// The function definitions and sq's body, but not cube's.
+	s := p.Files[0].Summary()
+	if s.Statements != 4 || s.StatementsCovered != 3 {
+		t.Errorf("lib statements %d/%d, want 3/4", s.StatementsCovered, s.Statements)
+	}
+	if s := p.Files[1].Summary(); s.StatementPercent() != 100 || s.ExpressionPercent() != 100 {
+		t.Errorf("test summary %+v, want all covered", s)
+	}
+	if got, want := p.Summary().Expressions, s.Expressions+p.Files[1].Summary().Expressions; got != want {
+		t.Errorf("total expressions %d, want %d", got, want)
+	}
+}
+
+func TestAddParsesOnce(t *testing.T) {
+	p := cover.New()
+	a, err := p.Add("a.wz", []byte("1+2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := p.Add("a.wz", []byte("3\n"))
+	if a != b || len(p.Files) != 1 {
+		t.Errorf("added %q twice", "a.wz")
+	}
+	if s := a.Summary(); s.Expressions != 3 || s.ExpressionsCovered != 0 || s.ExpressionPercent() != 0 {
+		t.Errorf("summary %+v, want 3 uncovered expressions", s)
+	}
+}
+
+func TestWriteLcov(t *testing.T) {
+	var buf bytes.Buffer
+	if err := run(t).WriteLcov(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `TN:
+SF:lib.wz
+FN:1,sq
+FN:2,cube
+FNDA:1,sq
+FNDA:0,cube
+FNF:2
+FNH:1
+DA:1,1
+DA:2,1
+DA:3,0
+LF:3
+LH:2
+end_of_record
+TN:
+SF:lib_test.wz
+FNF:0
+FNH:0
+DA:1,1
+LF:1
+LH:1
+end_of_record
+`
+	if got := buf.String(); got != want {
+		t.Errorf("lcov:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var buf bytes.Buffer
+	if err := run(t).WriteHTML(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<option value="file0">lib.wz (`,
+		`<option value="file1">lib_test.wz (100.0%)</option>`,
+		`<span class="unc" title="0">sq</span>`,
+		`<span class="cov" title="1">x</span><span class="cov" title="1">*</span>`,
+		`<span class="cov" title="1">+</span>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("HTML lacks %q", want)
+		}
+	}
+}
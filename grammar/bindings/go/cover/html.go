@@ -0,0 +1,98 @@
+package cover
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+)
+
+// WriteHTML writes the profile as a standalone HTML page showing the
+// source of each file, chosen from a menu, with the code of evaluated
+// expressions in green and of unevaluated ones in red. Each character
+// takes the colour of the innermost expression containing it, and
+// hovering over it shows that expression's number of evaluations.
+func (p *Profile) WriteHTML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(htmlHead)
+	bw.WriteString("<select id=\"files\" onchange=\"show(this.value)\">\n")
+	for i, f := range p.Files {
+		s := f.Summary()
+		fmt.Fprintf(bw, "<option value=\"file%d\">%s (%.1f%%)</option>\n", i, html.EscapeString(f.Path), s.ExpressionPercent())
+	}
+	bw.WriteString("</select>\n")
+	s := p.Summary()
+	fmt.Fprintf(bw, "<span class=\"summary\">%.1f%% of statements, %.1f%% of expressions</span>\n", s.StatementPercent(), s.ExpressionPercent())
+	for i, f := range p.Files {
+		display := "none"
+		if i == 0 {
+			display = "block"
+		}
+		fmt.Fprintf(bw, "<pre class=\"file\" id=\"file%d\" style=\"display: %s\">", i, display)
+		f.writeSource(bw)
+		bw.WriteString("</pre>\n")
+	}
+	bw.WriteString(htmlTail)
+	return bw.Flush()
+}
+
+// writeSource writes the source of f, with each run of characters in the
+// same innermost expression in a span of its class.
+func (f *File) writeSource(bw *bufio.Writer) {
+	// Blocks are ordered by start and then by length, longest first, so
+	// each block in turn marks the characters it innermostly contains.
+	inner := make([]int, len(f.Source))
+	for i := range inner {
+		inner[i] = -1
+	}
+	for i, b := range f.Blocks {
+		for o := b.Span.Start.Offset; o < b.Span.End.Offset && o < uint(len(inner)); o++ {
+			inner[o] = i
+		}
+	}
+	for start := 0; start < len(f.Source); {
+		end := start + 1
+		for end < len(f.Source) && inner[end] == inner[start] {
+			end++
+		}
+		text := html.EscapeString(string(f.Source[start:end]))
+		if i := inner[start]; i < 0 {
+			bw.WriteString(text)
+		} else {
+			b := f.Blocks[i]
+			class := "cov"
+			if b.Count == 0 {
+				class = "unc"
+			}
+			fmt.Fprintf(bw, "<span class=\"%s\" title=\"%d\">%s</span>", class, b.Count, text)
+		}
+		start = end
+	}
+}
+
+const htmlHead = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>wabznasm coverage</title>
+<style>
+body { background: #111; color: #888; font-family: monospace; }
+select, .summary { margin: 0.5em; }
+.cov { color: #2c2; }
+.unc { color: #e33; }
+</style>
+</head>
+<body>
+`
+
+const htmlTail = `<script>
+var current = "file0";
+function show(id) {
+	document.getElementById(current).style.display = "none";
+	document.getElementById(id).style.display = "block";
+	current = id;
+}
+</script>
+</body>
+</html>
+`
@@ -0,0 +1,62 @@
+package cover
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteLcov writes the profile as an lcov trace file: for each file, its
+// functions with the number of calls, and each line beginning an
+// expression with the number of evaluations of its least evaluated
+// expression, so that a line counts as covered only if all of it was.
+func (p *Profile) WriteLcov(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	for _, f := range p.Files {
+		fmt.Fprintf(bw, "TN:\nSF:%s\n", f.Path)
+		hit := 0
+		for _, fn := range f.Funcs {
+			fmt.Fprintf(bw, "FN:%d,%s\n", fn.Pos.Row+1, fn.Name)
+		}
+		for _, fn := range f.Funcs {
+			fmt.Fprintf(bw, "FNDA:%d,%s\n", fn.Body.Count, fn.Name)
+			if fn.Body.Count > 0 {
+				hit++
+			}
+		}
+		fmt.Fprintf(bw, "FNF:%d\nFNH:%d\n", len(f.Funcs), hit)
+
+		lines, found, covered := f.lines()
+		for _, l := range lines {
+			fmt.Fprintf(bw, "DA:%d,%d\n", l.row+1, l.count)
+		}
+		fmt.Fprintf(bw, "LF:%d\nLH:%d\nend_of_record\n", found, covered)
+	}
+	return bw.Flush()
+}
+
+// line is a line of a file beginning at least one expression.
+type line struct {
+	row   uint
+	count int64
+}
+
+// lines returns the lines of f beginning expressions, in order, with the
+// least count of the expressions beginning on each, and how many there
+// are and how many of them were evaluated.
+func (f *File) lines() (lines []line, found, covered int) {
+	for _, b := range f.Blocks {
+		row := b.Span.Start.Row
+		if n := len(lines); n > 0 && lines[n-1].row == row {
+			lines[n-1].count = min(lines[n-1].count, b.Count)
+			continue
+		}
+		lines = append(lines, line{row: row, count: b.Count})
+	}
+	for _, l := range lines {
+		if l.count > 0 {
+			covered++
+		}
+	}
+	return lines, len(lines), covered
+}
@@ -0,0 +1,187 @@
+// Package crash turns a panic in a long-running server into a diagnostic
+// bundle, a JSON file a user can attach to a bug report, so that the
+// server can answer the failed request with an error and go on serving.
+//
+//	defer func() {
+//		if v := recover(); v != nil {
+//			b, path := rep.Report(v, &crash.Scope{Operation: "textDocument/hover", Source: src, Tree: tree})
+//			// answer the request with an internal error naming b.ID
+//		}
+//	}()
+//
+// A bundle records the panic, the stack of the goroutine that panicked,
+// the program and its build, and what the request was working on: the
+// shape of its syntax tree and the size and hash of its source. The text
+// of the source is only recorded if the Reporter opts in with
+// IncludeSource, since it may be confidential; without it, the tree
+// records node kinds but no text.
+package crash
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"time"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+// Scope describes the work a panic interrupted. Its fields are all
+// optional.
+type Scope struct {
+	// Operation names the request, such as an LSP method or an HTTP path.
+	Operation string
+	// RequestID is the request ID of package telemetry.
+	RequestID string
+	// URI names the document being worked on.
+	URI    string
+	Source []byte
+	Tree   *tree_sitter.Tree
+}
+
+// Bundle is the diagnostic bundle of a panic.
+type Bundle struct {
+	ID        string    `json:"id"`
+	Time      time.Time `json:"time"`
+	Program   string    `json:"program"`
+	Version   string    `json:"version,omitempty"`
+	GoVersion string    `json:"go_version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	// Grammar identifies the grammar in use, which tells a crash of
+	// mismatched grammar and runtime versions from others.
+	Grammar tree_sitter_wabznasm.LanguageInfo `json:"grammar"`
+
+	Operation string `json:"operation,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	URI       string `json:"uri,omitempty"`
+
+	Panic string `json:"panic"`
+	Stack string `json:"stack"`
+
+	Source *Source `json:"source,omitempty"`
+	// Tree is the S-expression of the syntax tree, which names node kinds
+	// and fields but holds no source text.
+	Tree string `json:"tree,omitempty"`
+	// TreeHasError reports whether the tree contains syntax errors.
+	TreeHasError bool `json:"tree_has_error,omitempty"`
+}
+
+// Source describes the source of a Scope.
+type Source struct {
+	Bytes  int    `json:"bytes"`
+	SHA256 string `json:"sha256"`
+	// Text is only recorded with Reporter.IncludeSource.
+	Text *string `json:"text,omitempty"`
+}
+
+// Reporter writes bundles. The zero Reporter writes them to DefaultDir
+// without the source text.
+type Reporter struct {
+	// Dir is the directory bundles are written to.
+	Dir string
+	// IncludeSource records the text of the source in bundles.
+	IncludeSource bool
+}
+
+// DefaultDir returns the crashes directory of the wabznasm directory of
+// the user's cache directory, or of the temporary directory if there is
+// no cache directory.
+func DefaultDir() string {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		base = os.TempDir()
+	}
+	return filepath.Join(base, "wabznasm", "crashes")
+}
+
+// Report captures the bundle of v, the value of a panic just recovered
+// while working on scope, which may be nil, and writes it. It returns the
+// bundle and the path it was written to, or "" if it could not be
+// written. Report must be called by the function that recovered, so that
+// the stack it records is the panic's. A nil r is a zero Reporter.
+func (r *Reporter) Report(v any, scope *Scope) (*Bundle, string) {
+	b := r.Capture(v, debug.Stack(), scope)
+	path, _ := r.Write(b)
+	return b, path
+}
+
+// Capture returns the bundle of the panic value v, raised with the stack
+// trace stack while working on scope, which may be nil.
+func (r *Reporter) Capture(v any, stack []byte, scope *Scope) *Bundle {
+	var id [8]byte
+	rand.Read(id[:])
+	b := &Bundle{
+		ID:        hex.EncodeToString(id[:]),
+		Time:      time.Now().UTC(),
+		Program:   filepath.Base(os.Args[0]),
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Grammar:   tree_sitter_wabznasm.Info(),
+		Panic:     fmt.Sprint(v),
+		Stack:     string(stack),
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		b.Version = info.Main.Version
+	}
+	if scope == nil {
+		return b
+	}
+	b.Operation, b.RequestID, b.URI = scope.Operation, scope.RequestID, scope.URI
+	if scope.Source != nil {
+		sum := sha256.Sum256(scope.Source)
+		b.Source = &Source{Bytes: len(scope.Source), SHA256: hex.EncodeToString(sum[:])}
+		if r != nil && r.IncludeSource {
+			text := string(scope.Source)
+			b.Source.Text = &text
+		}
+	}
+	if scope.Tree != nil {
+		b.Tree, b.TreeHasError = describeTree(scope.Tree)
+	}
+	return b
+}
+
+// describeTree returns the S-expression of tree, and whether it has
+// errors, or a note if the tree cannot be read: the panic may have left it
+// in a bad state.
+func describeTree(tree *tree_sitter.Tree) (sexp string, hasError bool) {
+	defer func() {
+		if v := recover(); v != nil {
+			sexp = fmt.Sprintf("unavailable: %v", v)
+		}
+	}()
+	root := tree.RootNode()
+	return root.ToSexp(), root.HasError()
+}
+
+// Write writes b to a file of its own in the directory of r, returning its
+// path.
+func (r *Reporter) Write(b *Bundle) (string, error) {
+	dir := DefaultDir()
+	if r != nil && r.Dir != "" {
+		dir = r.Dir
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := fmt.Sprintf("%s-%s-%s.json", b.Program, b.Time.Format("20060102T150405Z"), b.ID)
+	path := filepath.Join(dir, name)
+	// The bundle may hold source, so only the user may read it.
+	if err := os.WriteFile(path, append(data, '\n'), 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
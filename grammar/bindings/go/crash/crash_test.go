@@ -0,0 +1,80 @@
+package crash_test
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/crash"
+)
+
+// crashWith panics while working on src and returns the bundle reported.
+func crashWith(t *testing.T, rep *crash.Reporter, src string) (b *crash.Bundle, path string) {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	func() {
+		defer func() {
+			if v := recover(); v != nil {
+				b, path = rep.Report(v, &crash.Scope{Operation: "lint", RequestID: "abc", URI: "file:///s.wabznasm", Source: []byte(src), Tree: tree.Tree})
+			}
+		}()
+		explode()
+	}()
+	return b, path
+}
+
+func explode() { panic("kaboom") }
+
+func TestReport(t *testing.T) {
+	rep := &crash.Reporter{Dir: t.TempDir()}
+	b, path := crashWith(t, rep, "secret: 1+")
+	if path == "" {
+		t.Fatal("bundle not written")
+	}
+	if b.Panic != "kaboom" || b.Operation != "lint" || b.RequestID != "abc" || b.URI != "file:///s.wabznasm" {
+		t.Errorf("bundle = %+v", b)
+	}
+	if b.Grammar != tree_sitter_wabznasm.Info() {
+		t.Errorf("grammar = %+v", b.Grammar)
+	}
+	if !strings.Contains(b.Stack, "crash_test.explode") {
+		t.Errorf("stack lacks the panicking function:\n%s", b.Stack)
+	}
+	if !strings.HasPrefix(b.Tree, "(source_file") || !b.TreeHasError || strings.Contains(b.Tree, "secret") {
+		t.Errorf("tree = %q, has error %v", b.Tree, b.TreeHasError)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("bundle mode = %v", info.Mode())
+	}
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "secret") {
+		t.Errorf("bundle holds the source without IncludeSource:\n%s", data)
+	}
+	var read crash.Bundle
+	if err := json.Unmarshal(data, &read); err != nil || read.ID != b.ID || read.Source.Bytes != 10 || len(read.Source.SHA256) != 64 {
+		t.Errorf("read %+v, %v", read, err)
+	}
+}
+
+func TestIncludeSource(t *testing.T) {
+	b, _ := crashWith(t, &crash.Reporter{Dir: t.TempDir(), IncludeSource: true}, "secret: 1")
+	if b.Source.Text == nil || *b.Source.Text != "secret: 1" {
+		t.Errorf("source = %+v", b.Source)
+	}
+}
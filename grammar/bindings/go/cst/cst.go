@@ -0,0 +1,66 @@
+// Package cst provides typed wrappers for the nodes of wabznasm parse trees.
+//
+// Unlike package ast, which collapses the precedence ladder into a few
+// expression types, cst mirrors the grammar one to one: there is a type for
+// every named node kind and a method for every field, generated from the
+// grammar's node-types.json by cmd/gen-ast. A change to the grammar that
+// renames or removes a kind or field therefore breaks the build of the code
+// that depends on it, instead of silently matching nothing.
+//
+// Each type embeds the *tree_sitter.Node it wraps. Field methods return nil
+// when the field is absent or holds an error node.
+package cst
+
+import (
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Node is implemented by every typed node.
+type Node interface {
+	Raw() *tree_sitter.Node
+}
+
+// Wrap returns the typed wrapper for n, or nil if n is nil, an error node or
+// a literal token.
+func Wrap(n *tree_sitter.Node) Node {
+	if n == nil || n.IsError() || n.IsMissing() || !n.IsNamed() {
+		return nil
+	}
+	return wrap(n)
+}
+
+// Root returns the typed root of tree.
+func Root(tree *tree_sitter.Tree) *SourceFile {
+	return asSourceFile(tree.RootNode())
+}
+
+// childrenByField returns the children of n in the named field.
+func childrenByField(n *tree_sitter.Node, field string) []*tree_sitter.Node {
+	var out []*tree_sitter.Node
+	for i := uint(0); i < n.ChildCount(); i++ {
+		if n.FieldNameForChild(uint32(i)) == field {
+			out = append(out, n.Child(i))
+		}
+	}
+	return out
+}
+
+// unfielded returns the named children of n that are in no field, leaving
+// out comments.
+func unfielded(n *tree_sitter.Node) []*tree_sitter.Node {
+	var out []*tree_sitter.Node
+	for i := uint(0); i < n.ChildCount(); i++ {
+		c := n.Child(i)
+		if c.IsNamed() && !c.IsExtra() && n.FieldNameForChild(uint32(i)) == "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func first(nodes []*tree_sitter.Node) *tree_sitter.Node {
+	if len(nodes) == 0 {
+		return nil
+	}
+	return nodes[0]
+}
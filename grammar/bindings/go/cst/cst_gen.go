@@ -0,0 +1,473 @@
+// Code generated by gen-ast from node-types.json; DO NOT EDIT.
+
+package cst
+
+import tree_sitter "github.com/tree-sitter/go-tree-sitter"
+
+func wrap(n *tree_sitter.Node) Node {
+	switch n.Kind() {
+	case "additive":
+		return &Additive{n}
+	case "argument_list":
+		return &ArgumentList{n}
+	case "assignment":
+		return &Assignment{n}
+	case "expression":
+		return &Expression{n}
+	case "function_body":
+		return &FunctionBody{n}
+	case "function_call":
+		return &FunctionCall{n}
+	case "identifier":
+		return &Identifier{n}
+	case "multiplicative":
+		return &Multiplicative{n}
+	case "number":
+		return &Number{n}
+	case "parameter_list":
+		return &ParameterList{n}
+	case "postfix":
+		return &Postfix{n}
+	case "power":
+		return &Power{n}
+	case "primary":
+		return &Primary{n}
+	case "source_file":
+		return &SourceFile{n}
+	case "statement":
+		return &Statement{n}
+	case "unary":
+		return &Unary{n}
+	}
+	return nil
+}
+
+// Additive is a node of kind "additive".
+type Additive struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *Additive) Raw() *tree_sitter.Node { return n.Node }
+
+func asAdditive(n *tree_sitter.Node) *Additive {
+	if n == nil || n.Kind() != "additive" || n.IsMissing() {
+		return nil
+	}
+	return &Additive{n}
+}
+
+// Left returns the left field, or nil.
+func (n *Additive) Left() *Additive {
+	return asAdditive(n.ChildByFieldName("left"))
+}
+
+// Operator returns the operator field, or nil.
+func (n *Additive) Operator() *tree_sitter.Node {
+	return n.ChildByFieldName("operator")
+}
+
+// Right returns the right field, or nil.
+func (n *Additive) Right() *Multiplicative {
+	return asMultiplicative(n.ChildByFieldName("right"))
+}
+
+// Multiplicative returns the child outside any field, or nil.
+func (n *Additive) Multiplicative() *Multiplicative {
+	return asMultiplicative(first(unfielded(n.Node)))
+}
+
+// ArgumentList is a node of kind "argument_list".
+type ArgumentList struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *ArgumentList) Raw() *tree_sitter.Node { return n.Node }
+
+func asArgumentList(n *tree_sitter.Node) *ArgumentList {
+	if n == nil || n.Kind() != "argument_list" || n.IsMissing() {
+		return nil
+	}
+	return &ArgumentList{n}
+}
+
+// Arg returns the arg field.
+func (n *ArgumentList) Arg() []*Expression {
+	var out []*Expression
+	for _, c := range childrenByField(n.Node, "arg") {
+		if v := asExpression(c); v != nil {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// Separator returns the separator field.
+func (n *ArgumentList) Separator() []*tree_sitter.Node {
+	return childrenByField(n.Node, "separator")
+}
+
+// Assignment is a node of kind "assignment".
+type Assignment struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *Assignment) Raw() *tree_sitter.Node { return n.Node }
+
+func asAssignment(n *tree_sitter.Node) *Assignment {
+	if n == nil || n.Kind() != "assignment" || n.IsMissing() {
+		return nil
+	}
+	return &Assignment{n}
+}
+
+// Name returns the name field, or nil.
+func (n *Assignment) Name() *Identifier {
+	return asIdentifier(n.ChildByFieldName("name"))
+}
+
+// Operator returns the operator field, or nil.
+func (n *Assignment) Operator() *tree_sitter.Node {
+	return n.ChildByFieldName("operator")
+}
+
+// Value returns the value field, or nil.
+func (n *Assignment) Value() Node {
+	return Wrap(n.ChildByFieldName("value"))
+}
+
+// Expression is a node of kind "expression".
+type Expression struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *Expression) Raw() *tree_sitter.Node { return n.Node }
+
+func asExpression(n *tree_sitter.Node) *Expression {
+	if n == nil || n.Kind() != "expression" || n.IsMissing() {
+		return nil
+	}
+	return &Expression{n}
+}
+
+// Additive returns the child outside any field, or nil.
+func (n *Expression) Additive() *Additive {
+	return asAdditive(first(unfielded(n.Node)))
+}
+
+// FunctionBody is a node of kind "function_body".
+type FunctionBody struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *FunctionBody) Raw() *tree_sitter.Node { return n.Node }
+
+func asFunctionBody(n *tree_sitter.Node) *FunctionBody {
+	if n == nil || n.Kind() != "function_body" || n.IsMissing() {
+		return nil
+	}
+	return &FunctionBody{n}
+}
+
+// Body returns the body field, or nil.
+func (n *FunctionBody) Body() *Expression {
+	return asExpression(n.ChildByFieldName("body"))
+}
+
+// LeftBrace returns the left_brace field, or nil.
+func (n *FunctionBody) LeftBrace() *tree_sitter.Node {
+	return n.ChildByFieldName("left_brace")
+}
+
+// Params returns the params field, or nil.
+func (n *FunctionBody) Params() *ParameterList {
+	return asParameterList(n.ChildByFieldName("params"))
+}
+
+// RightBrace returns the right_brace field, or nil.
+func (n *FunctionBody) RightBrace() *tree_sitter.Node {
+	return n.ChildByFieldName("right_brace")
+}
+
+// FunctionCall is a node of kind "function_call".
+type FunctionCall struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *FunctionCall) Raw() *tree_sitter.Node { return n.Node }
+
+func asFunctionCall(n *tree_sitter.Node) *FunctionCall {
+	if n == nil || n.Kind() != "function_call" || n.IsMissing() {
+		return nil
+	}
+	return &FunctionCall{n}
+}
+
+// Args returns the args field, or nil.
+func (n *FunctionCall) Args() *ArgumentList {
+	return asArgumentList(n.ChildByFieldName("args"))
+}
+
+// Function returns the function field, or nil.
+func (n *FunctionCall) Function() *Identifier {
+	return asIdentifier(n.ChildByFieldName("function"))
+}
+
+// LeftBracket returns the left_bracket field, or nil.
+func (n *FunctionCall) LeftBracket() *tree_sitter.Node {
+	return n.ChildByFieldName("left_bracket")
+}
+
+// RightBracket returns the right_bracket field, or nil.
+func (n *FunctionCall) RightBracket() *tree_sitter.Node {
+	return n.ChildByFieldName("right_bracket")
+}
+
+// Identifier is a node of kind "identifier".
+type Identifier struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *Identifier) Raw() *tree_sitter.Node { return n.Node }
+
+func asIdentifier(n *tree_sitter.Node) *Identifier {
+	if n == nil || n.Kind() != "identifier" || n.IsMissing() {
+		return nil
+	}
+	return &Identifier{n}
+}
+
+// Multiplicative is a node of kind "multiplicative".
+type Multiplicative struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *Multiplicative) Raw() *tree_sitter.Node { return n.Node }
+
+func asMultiplicative(n *tree_sitter.Node) *Multiplicative {
+	if n == nil || n.Kind() != "multiplicative" || n.IsMissing() {
+		return nil
+	}
+	return &Multiplicative{n}
+}
+
+// Left returns the left field, or nil.
+func (n *Multiplicative) Left() *Multiplicative {
+	return asMultiplicative(n.ChildByFieldName("left"))
+}
+
+// Operator returns the operator field, or nil.
+func (n *Multiplicative) Operator() *tree_sitter.Node {
+	return n.ChildByFieldName("operator")
+}
+
+// Right returns the right field, or nil.
+func (n *Multiplicative) Right() *Unary {
+	return asUnary(n.ChildByFieldName("right"))
+}
+
+// Unary returns the child outside any field, or nil.
+func (n *Multiplicative) Unary() *Unary {
+	return asUnary(first(unfielded(n.Node)))
+}
+
+// Number is a node of kind "number".
+type Number struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *Number) Raw() *tree_sitter.Node { return n.Node }
+
+func asNumber(n *tree_sitter.Node) *Number {
+	if n == nil || n.Kind() != "number" || n.IsMissing() {
+		return nil
+	}
+	return &Number{n}
+}
+
+// ParameterList is a node of kind "parameter_list".
+type ParameterList struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *ParameterList) Raw() *tree_sitter.Node { return n.Node }
+
+func asParameterList(n *tree_sitter.Node) *ParameterList {
+	if n == nil || n.Kind() != "parameter_list" || n.IsMissing() {
+		return nil
+	}
+	return &ParameterList{n}
+}
+
+// LeftBracket returns the left_bracket field, or nil.
+func (n *ParameterList) LeftBracket() *tree_sitter.Node {
+	return n.ChildByFieldName("left_bracket")
+}
+
+// Param returns the param field.
+func (n *ParameterList) Param() []*Identifier {
+	var out []*Identifier
+	for _, c := range childrenByField(n.Node, "param") {
+		if v := asIdentifier(c); v != nil {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// RightBracket returns the right_bracket field, or nil.
+func (n *ParameterList) RightBracket() *tree_sitter.Node {
+	return n.ChildByFieldName("right_bracket")
+}
+
+// Separator returns the separator field.
+func (n *ParameterList) Separator() []*tree_sitter.Node {
+	return childrenByField(n.Node, "separator")
+}
+
+// Postfix is a node of kind "postfix".
+type Postfix struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *Postfix) Raw() *tree_sitter.Node { return n.Node }
+
+func asPostfix(n *tree_sitter.Node) *Postfix {
+	if n == nil || n.Kind() != "postfix" || n.IsMissing() {
+		return nil
+	}
+	return &Postfix{n}
+}
+
+// Operand returns the operand field, or nil.
+func (n *Postfix) Operand() *Postfix {
+	return asPostfix(n.ChildByFieldName("operand"))
+}
+
+// Operator returns the operator field, or nil.
+func (n *Postfix) Operator() *tree_sitter.Node {
+	return n.ChildByFieldName("operator")
+}
+
+// Primary returns the child outside any field, or nil.
+func (n *Postfix) Primary() *Primary {
+	return asPrimary(first(unfielded(n.Node)))
+}
+
+// Power is a node of kind "power".
+type Power struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *Power) Raw() *tree_sitter.Node { return n.Node }
+
+func asPower(n *tree_sitter.Node) *Power {
+	if n == nil || n.Kind() != "power" || n.IsMissing() {
+		return nil
+	}
+	return &Power{n}
+}
+
+// Base returns the base field, or nil.
+func (n *Power) Base() *Postfix {
+	return asPostfix(n.ChildByFieldName("base"))
+}
+
+// Exponent returns the exponent field, or nil.
+func (n *Power) Exponent() *Unary {
+	return asUnary(n.ChildByFieldName("exponent"))
+}
+
+// Operator returns the operator field, or nil.
+func (n *Power) Operator() *tree_sitter.Node {
+	return n.ChildByFieldName("operator")
+}
+
+// Postfix returns the child outside any field, or nil.
+func (n *Power) Postfix() *Postfix {
+	return asPostfix(first(unfielded(n.Node)))
+}
+
+// Primary is a node of kind "primary".
+type Primary struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *Primary) Raw() *tree_sitter.Node { return n.Node }
+
+func asPrimary(n *tree_sitter.Node) *Primary {
+	if n == nil || n.Kind() != "primary" || n.IsMissing() {
+		return nil
+	}
+	return &Primary{n}
+}
+
+// Expression returns the expression field, or nil.
+func (n *Primary) Expression() *Expression {
+	return asExpression(n.ChildByFieldName("expression"))
+}
+
+// LeftParen returns the left_paren field, or nil.
+func (n *Primary) LeftParen() *tree_sitter.Node {
+	return n.ChildByFieldName("left_paren")
+}
+
+// RightParen returns the right_paren field, or nil.
+func (n *Primary) RightParen() *tree_sitter.Node {
+	return n.ChildByFieldName("right_paren")
+}
+
+// Content returns the child outside any field, or nil.
+func (n *Primary) Content() Node {
+	return Wrap(first(unfielded(n.Node)))
+}
+
+// SourceFile is a node of kind "source_file".
+type SourceFile struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *SourceFile) Raw() *tree_sitter.Node { return n.Node }
+
+func asSourceFile(n *tree_sitter.Node) *SourceFile {
+	if n == nil || n.Kind() != "source_file" || n.IsMissing() {
+		return nil
+	}
+	return &SourceFile{n}
+}
+
+// Statement returns the child outside any field, or nil.
+func (n *SourceFile) Statement() *Statement {
+	return asStatement(first(unfielded(n.Node)))
+}
+
+// Statement is a node of kind "statement".
+type Statement struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *Statement) Raw() *tree_sitter.Node { return n.Node }
+
+func asStatement(n *tree_sitter.Node) *Statement {
+	if n == nil || n.Kind() != "statement" || n.IsMissing() {
+		return nil
+	}
+	return &Statement{n}
+}
+
+// Content returns the child outside any field, or nil.
+func (n *Statement) Content() Node {
+	return Wrap(first(unfielded(n.Node)))
+}
+
+// Unary is a node of kind "unary".
+type Unary struct{ *tree_sitter.Node }
+
+// Raw returns the wrapped node.
+func (n *Unary) Raw() *tree_sitter.Node { return n.Node }
+
+func asUnary(n *tree_sitter.Node) *Unary {
+	if n == nil || n.Kind() != "unary" || n.IsMissing() {
+		return nil
+	}
+	return &Unary{n}
+}
+
+// Operand returns the operand field, or nil.
+func (n *Unary) Operand() *Unary {
+	return asUnary(n.ChildByFieldName("operand"))
+}
+
+// Operator returns the operator field, or nil.
+func (n *Unary) Operator() *tree_sitter.Node {
+	return n.ChildByFieldName("operator")
+}
+
+// Power returns the child outside any field, or nil.
+func (n *Unary) Power() *Power {
+	return asPower(first(unfielded(n.Node)))
+}
@@ -0,0 +1,73 @@
+package cst_test
+
+import (
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/cst"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func parse(t *testing.T, src string) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(parser.Close)
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+func TestTypedFields(t *testing.T) {
+	src := "add: {[x;y] f[x;y]}"
+	root := cst.Root(parse(t, src).Tree)
+	asg, ok := root.Statement().Content().(*cst.Assignment)
+	if !ok {
+		t.Fatalf("statement holds %T, want *cst.Assignment", root.Statement().Content())
+	}
+	if got := asg.Name().Utf8Text([]byte(src)); got != "add" {
+		t.Errorf("name = %q", got)
+	}
+	fn, ok := asg.Value().(*cst.FunctionBody)
+	if !ok {
+		t.Fatalf("value is %T, want *cst.FunctionBody", asg.Value())
+	}
+	if n := len(fn.Params().Param()); n != 2 {
+		t.Errorf("%d params, want 2", n)
+	}
+	var call *cst.FunctionCall
+	e := fn.Body().Additive().Multiplicative().Unary().Power().Postfix().Primary()
+	if call, ok = e.Content().(*cst.FunctionCall); !ok {
+		t.Fatalf("primary holds %T, want *cst.FunctionCall", e.Content())
+	}
+	if args := call.Args().Arg(); len(args) != 2 || len(call.Args().Separator()) != 1 {
+		t.Errorf("call has %d args", len(args))
+	}
+	if asg.Raw().Kind() != tree_sitter_wabznasm.KindAssignment {
+		t.Errorf("raw kind = %q", asg.Raw().Kind())
+	}
+}
+
+func TestMissingFields(t *testing.T) {
+	root := cst.Root(parse(t, "f: {}").Tree)
+	asg, _ := root.Statement().Content().(*cst.Assignment)
+	if asg == nil {
+		t.Fatalf("no assignment in %s", root.ToSexp())
+	}
+	fn := asg.Value().(*cst.FunctionBody)
+	if fn.Params() != nil {
+		t.Errorf("params = %v, want nil", fn.Params())
+	}
+	// The parser inserts a missing identifier as the body.
+	if c := fn.Body().Additive().Multiplicative().Unary().Power().Postfix().Primary().Content(); c != nil {
+		t.Errorf("missing body = %v, want nil", c)
+	}
+	if cst.Wrap(nil) != nil {
+		t.Error("Wrap(nil) != nil")
+	}
+}
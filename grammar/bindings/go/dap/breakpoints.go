@@ -0,0 +1,196 @@
+package dap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+)
+
+// setBreakpoints replaces the line breakpoints of a script. A breakpoint
+// on a line without code moves to the next line with some.
+func (s *Server) setBreakpoints(args *SetBreakpointsArguments) (any, error) {
+	path := filepath.Clean(args.Source.Path)
+	if args.Source.Path == "" {
+		return nil, errors.New("setBreakpoints: no source path given")
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := codeRows(src)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	set := map[int]*breakpoint{}
+	out := []Breakpoint{}
+	for _, req := range args.Breakpoints {
+		row := req.Line - 1
+		if s.lines0 {
+			row = req.Line
+		}
+		i := sort.SearchInts(rows, row)
+		if i == len(rows) {
+			out = append(out, Breakpoint{Verified: false, Message: "no code at or after this line", Line: req.Line})
+			continue
+		}
+		row = rows[i]
+		bp, err := s.newBreakpoint(req.Condition)
+		if err != nil {
+			out = append(out, Breakpoint{Verified: false, Message: err.Error(), Line: req.Line})
+			continue
+		}
+		line := row + 1
+		if s.lines0 {
+			line = row
+		}
+		set[row] = bp
+		out = append(out, Breakpoint{ID: bp.id, Verified: true, Source: &args.Source, Line: line})
+	}
+	s.breakpoints[path] = set
+	return map[string]any{"breakpoints": out}, nil
+}
+
+// setFunctionBreakpoints replaces the function breakpoints.
+func (s *Server) setFunctionBreakpoints(args *SetFunctionBreakpointsArguments) any {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.functions = map[string]*breakpoint{}
+	out := []Breakpoint{}
+	for _, req := range args.Breakpoints {
+		bp, err := s.newBreakpoint(req.Condition)
+		if err != nil {
+			out = append(out, Breakpoint{Verified: false, Message: err.Error()})
+			continue
+		}
+		s.functions[req.Name] = bp
+		out = append(out, Breakpoint{ID: bp.id, Verified: true})
+	}
+	return map[string]any{"breakpoints": out}
+}
+
+// newBreakpoint returns a breakpoint stopping when condition, if not
+// empty, holds.
+func (s *Server) newBreakpoint(condition string) (*breakpoint, error) {
+	bp := &breakpoint{}
+	if condition != "" {
+		stmt, err := parseStmt(condition)
+		if err != nil {
+			return nil, fmt.Errorf("condition: %v", err)
+		}
+		x, ok := stmt.(*ast.ExprStmt)
+		if !ok {
+			return nil, errors.New("condition: not an expression")
+		}
+		bp.condition = x.X
+	}
+	s.nextID++
+	bp.id = s.nextID
+	return bp, nil
+}
+
+// codeRows returns the rows of src on which an expression begins, in
+// order.
+func codeRows(src []byte) ([]int, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	seen := map[int]bool{}
+	for _, e := range repl.Split(string(src)) {
+		text := e.Isolate(src)
+		tree, err := parser.ParseBytes(text)
+		if err != nil {
+			return nil, err
+		}
+		ast.Inspect(ast.FromTree(tree.Tree, text), func(n ast.Node) bool {
+			if _, ok := n.(ast.Expr); ok {
+				seen[int(n.Pos().Row)] = true
+			}
+			return true
+		})
+		tree.Close()
+	}
+	rows := make([]int, 0, len(seen))
+	for row := range seen {
+		rows = append(rows, row)
+	}
+	sort.Ints(rows)
+	return rows, nil
+}
+
+// parseStmt parses a statement typed by the client.
+func parseStmt(text string) (ast.Stmt, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(text)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	if tree.RootNode().HasError() {
+		return nil, eval.SyntaxError(tree.Tree, []byte(text))
+	}
+	f := ast.FromTree(tree.Tree, []byte(text))
+	if f.Stmt == nil {
+		return nil, errors.New("nothing to evaluate")
+	}
+	return f.Stmt, nil
+}
+
+// evaluate evaluates an expression in the environment of a frame of the
+// stopped script. An assignment binds the name in that environment.
+func (s *Server) evaluate(args *EvaluateArguments) (any, error) {
+	st := s.paused()
+	if st == nil {
+		return nil, errNotStopped
+	}
+	env := s.interp.Globals
+	if args.FrameID != 0 {
+		f, err := st.frame(args.FrameID)
+		if err != nil {
+			return nil, err
+		}
+		env = f.env
+	}
+	stmt, err := parseStmt(args.Expression)
+	if err != nil {
+		return nil, err
+	}
+	hook := s.interp.Hook
+	s.interp.Hook = nil
+	defer func() { s.interp.Hook = hook }()
+	var v eval.Value
+	switch stmt := stmt.(type) {
+	case *ast.Assignment:
+		if v, err = s.interp.Eval(stmt.Value, env); err == nil {
+			env.Define(stmt.Name.Name, v)
+		}
+	case *ast.ExprStmt:
+		v, err = s.interp.Eval(stmt.X, env)
+	default:
+		err = errors.New("not an expression")
+	}
+	if err != nil {
+		return nil, err
+	}
+	out := st.variable("", v)
+	return map[string]any{
+		"result":             out.Value,
+		"type":               out.Type,
+		"variablesReference": out.VariablesReference,
+		"indexedVariables":   out.IndexedVariables,
+	}, nil
+}
@@ -0,0 +1,72 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// conn frames protocol messages with Content-Length headers, as the
+// debug adapter protocol's base protocol requires, and numbers the
+// messages it writes.
+type conn struct {
+	r   *bufio.Reader
+	mu  sync.Mutex
+	w   io.Writer
+	seq int
+}
+
+func newConn(r io.Reader, w io.Writer) *conn {
+	return &conn{r: bufio.NewReader(r), w: w}
+}
+
+func (c *conn) read() ([]byte, error) {
+	header, err := textproto.NewReader(c.r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("dap: invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+func (c *conn) write(m *message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	m.Seq = c.seq
+	body, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+// reply answers req with body, or with the failure err.
+func (c *conn) reply(req *message, body any, err error) error {
+	ok := err == nil
+	m := &message{Type: "response", RequestSeq: req.Seq, Command: req.Command, Success: &ok, Body: body}
+	if err != nil {
+		m.Message = err.Error()
+	}
+	return c.write(m)
+}
+
+func (c *conn) event(name string, body any) error {
+	return c.write(&message{Type: "event", Event: name, Body: body})
+}
@@ -0,0 +1,377 @@
+package dap_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/dap"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+const script = `double: {[a] a*2}
+quad: {[a]
+  double[a]+double[a]
+}
+v: quad[3]
+v+1
+`
+
+// client drives a Server over in-memory pipes.
+type client struct {
+	t    *testing.T
+	w    io.WriteCloser
+	msgs chan map[string]json.RawMessage
+	seq  int
+	done chan error
+	// events received while waiting for responses.
+	events []map[string]json.RawMessage
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{t: t, w: inW, msgs: make(chan map[string]json.RawMessage, 64), done: make(chan error, 1)}
+	go func() {
+		r := bufio.NewReader(outR)
+		defer close(c.msgs)
+		for {
+			header, err := textproto.NewReader(r).ReadMIMEHeader()
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(header.Get("Content-Length"))
+			body := make([]byte, n)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			var msg map[string]json.RawMessage
+			if err := json.Unmarshal(body, &msg); err != nil {
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+	go func() {
+		c.done <- dap.NewServer(inR, outW).Run()
+		outW.Close()
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		select {
+		case <-c.done:
+		case <-time.After(5 * time.Second):
+			t.Error("server did not stop")
+		}
+	})
+	return c
+}
+
+func (c *client) next() map[string]json.RawMessage {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.msgs:
+		if !ok {
+			c.t.Fatal("server closed the connection")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for the server")
+	}
+	return nil
+}
+
+// call sends a request and returns the body of its response, failing the
+// test if it did not succeed.
+func (c *client) call(command string, args any) json.RawMessage {
+	c.t.Helper()
+	body, msg := c.try(command, args)
+	if msg != "" {
+		c.t.Fatalf("%s: %s", command, msg)
+	}
+	return body
+}
+
+// try sends a request and returns the body of its response, or the
+// message of a failure.
+func (c *client) try(command string, args any) (json.RawMessage, string) {
+	c.t.Helper()
+	c.seq++
+	req, _ := json.Marshal(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(req), req)
+	for {
+		msg := c.next()
+		if string(msg["type"]) == `"event"` {
+			c.events = append(c.events, msg)
+			continue
+		}
+		if string(msg["request_seq"]) != strconv.Itoa(c.seq) {
+			c.t.Fatalf("unexpected response %v", msg)
+		}
+		if string(msg["success"]) != "true" {
+			var text string
+			json.Unmarshal(msg["message"], &text)
+			return nil, text
+		}
+		return msg["body"], ""
+	}
+}
+
+// event waits for the next event called name, skipping output events,
+// and returns its body.
+func (c *client) event(name string) json.RawMessage {
+	c.t.Helper()
+	for {
+		var msg map[string]json.RawMessage
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.next()
+		}
+		var got string
+		json.Unmarshal(msg["event"], &got)
+		if got == name {
+			return msg["body"]
+		}
+		if got != "output" {
+			c.t.Fatalf("got event %s, want %s", got, name)
+		}
+	}
+}
+
+// outputs waits for the script to end and returns what it printed.
+func (c *client) outputs() (stdout string, exitCode int) {
+	c.t.Helper()
+	for {
+		var msg map[string]json.RawMessage
+		if len(c.events) > 0 {
+			msg, c.events = c.events[0], c.events[1:]
+		} else {
+			msg = c.next()
+		}
+		var ev string
+		json.Unmarshal(msg["event"], &ev)
+		switch ev {
+		case "output":
+			var body struct{ Category, Output string }
+			json.Unmarshal(msg["body"], &body)
+			if body.Category == "stdout" {
+				stdout += body.Output
+			}
+		case "exited":
+			var body struct{ ExitCode int }
+			json.Unmarshal(msg["body"], &body)
+			exitCode = body.ExitCode
+		case "terminated":
+			return stdout, exitCode
+		}
+	}
+}
+
+type stopped struct {
+	Reason           string
+	HitBreakpointIDs []int
+}
+
+func (c *client) stopped() stopped {
+	c.t.Helper()
+	var s stopped
+	json.Unmarshal(c.event("stopped"), &s)
+	return s
+}
+
+type frame struct {
+	ID, Line, Column int
+	Name             string
+}
+
+func (c *client) stack() []frame {
+	c.t.Helper()
+	var body struct{ StackFrames []frame }
+	json.Unmarshal(c.call("stackTrace", map[string]int{"threadId": 1}), &body)
+	return body.StackFrames
+}
+
+func names(frames []frame) []string {
+	var out []string
+	for _, f := range frames {
+		out = append(out, fmt.Sprintf("%s:%d", f.Name, f.Line))
+	}
+	return out
+}
+
+// start initializes a session debugging script, configured by setup.
+func start(t *testing.T, launch map[string]any, setup func(c *client, path string)) (*client, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.wabznasm")
+	if err := os.WriteFile(path, []byte(script), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := newClient(t)
+	c.call("initialize", map[string]any{"clientID": "test"})
+	c.event("initialized")
+	launch["program"] = path
+	c.call("launch", launch)
+	if setup != nil {
+		setup(c, path)
+	}
+	c.call("configurationDone", nil)
+	return c, path
+}
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	c, _ := start(t, map[string]any{}, func(c *client, path string) {
+		var body struct{ Breakpoints []struct{ Verified bool } }
+		json.Unmarshal(c.call("setBreakpoints", map[string]any{
+			"source":      map[string]string{"path": path},
+			"breakpoints": []map[string]any{{"line": 3}, {"line": 7}},
+		}), &body)
+		if len(body.Breakpoints) != 2 || !body.Breakpoints[0].Verified || body.Breakpoints[1].Verified {
+			t.Errorf("breakpoints = %+v", body.Breakpoints)
+		}
+	})
+
+	if s := c.stopped(); s.Reason != dap.StopBreakpoint || len(s.HitBreakpointIDs) != 1 {
+		t.Fatalf("stopped = %+v", s)
+	}
+	frames := c.stack()
+	if got := fmt.Sprint(names(frames)); got != "[quad:3 main.wabznasm:5]" {
+		t.Errorf("stack = %s", got)
+	}
+
+	var scopes struct {
+		Scopes []struct {
+			Name               string
+			VariablesReference int
+		}
+	}
+	json.Unmarshal(c.call("scopes", map[string]int{"frameId": frames[0].ID}), &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[0].Name != "Locals" || scopes.Scopes[1].Name != "Globals" {
+		t.Fatalf("scopes = %+v", scopes.Scopes)
+	}
+	var vars struct {
+		Variables []struct{ Name, Value string }
+	}
+	json.Unmarshal(c.call("variables", map[string]int{"variablesReference": scopes.Scopes[0].VariablesReference}), &vars)
+	if fmt.Sprint(vars.Variables) != "[{a 3}]" {
+		t.Errorf("locals = %v", vars.Variables)
+	}
+
+	var result struct{ Result string }
+	json.Unmarshal(c.call("evaluate", map[string]any{"expression": "a*10", "frameId": frames[0].ID}), &result)
+	if result.Result != "30" {
+		t.Errorf("evaluate = %q", result.Result)
+	}
+	if _, msg := c.try("evaluate", map[string]any{"expression": "b", "frameId": frames[0].ID}); msg == "" {
+		t.Errorf("evaluating an undefined name succeeded")
+	}
+
+	// The first step is the call of double, the second its body.
+	c.call("stepIn", map[string]int{"threadId": 1})
+	if s := c.stopped(); s.Reason != dap.StopStep {
+		t.Errorf("stopped = %+v", s)
+	}
+	if got := c.stack(); len(got) != 2 || got[0].Column != 3 {
+		t.Errorf("stack after stepIn = %+v", got)
+	}
+	c.call("stepIn", map[string]int{"threadId": 1})
+	c.stopped()
+	if got := fmt.Sprint(names(c.stack())); got != "[double:1 quad:3 main.wabznasm:5]" {
+		t.Errorf("stack after stepIn = %s", got)
+	}
+	c.call("stepOut", map[string]int{"threadId": 1})
+	c.stopped()
+	if got := c.stack(); len(got) != 2 || got[0].Name != "quad" || got[0].Column != 13 {
+		t.Errorf("stack after stepOut = %+v", got)
+	}
+	c.call("next", map[string]int{"threadId": 1})
+	c.stopped()
+	if got := fmt.Sprint(names(c.stack())); got != "[main.wabznasm:6]" {
+		t.Errorf("stack after next = %s", got)
+	}
+
+	c.call("continue", map[string]int{"threadId": 1})
+	if out, code := c.outputs(); out != "13\n" || code != 0 {
+		t.Errorf("output = %q, exit code %d", out, code)
+	}
+	if _, msg := c.try("stackTrace", map[string]int{"threadId": 1}); msg == "" {
+		t.Errorf("stackTrace succeeded after the script ended")
+	}
+	c.call("disconnect", nil)
+}
+
+func TestEntryAndFunctionBreakpoints(t *testing.T) {
+	c, _ := start(t, map[string]any{"stopOnEntry": true}, func(c *client, _ string) {
+		c.call("setFunctionBreakpoints", map[string]any{
+			"breakpoints": []map[string]string{{"name": "double", "condition": "a-3"}, {"name": "quad"}},
+		})
+	})
+	if s := c.stopped(); s.Reason != dap.StopEntry {
+		t.Fatalf("stopped = %+v", s)
+	}
+	c.call("next", map[string]int{"threadId": 1})
+	c.stopped()
+	if got := fmt.Sprint(names(c.stack())); got != "[main.wabznasm:2]" {
+		t.Errorf("stack after next = %s", got)
+	}
+	c.call("continue", map[string]int{"threadId": 1})
+	if s := c.stopped(); s.Reason != dap.StopFunctionBreakpoint {
+		t.Fatalf("stopped = %+v", s)
+	}
+	if got := fmt.Sprint(names(c.stack())); got != "[quad:3 main.wabznasm:5]" {
+		t.Errorf("stack = %s", got)
+	}
+
+	// An assignment in a paused frame binds the name there, so that the
+	// condition of double's breakpoint holds.
+	frames := c.stack()
+	c.call("evaluate", map[string]any{"expression": "a: 4", "frameId": frames[0].ID})
+	c.call("continue", map[string]int{"threadId": 1})
+	if s := c.stopped(); s.Reason != dap.StopFunctionBreakpoint {
+		t.Fatalf("stopped = %+v", s)
+	}
+	if got := fmt.Sprint(names(c.stack())); got != "[double:1 quad:3 main.wabznasm:5]" {
+		t.Errorf("stack = %s", got)
+	}
+	c.call("disconnect", nil)
+}
+
+func TestScriptErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.wabznasm")
+	os.WriteFile(path, []byte("x: 1\n  x/0\nx\n"), 0o644)
+	c := newClient(t)
+	c.call("initialize", nil)
+	c.call("launch", map[string]any{"program": path, "noDebug": true})
+	c.call("configurationDone", nil)
+	var stderr string
+	for ev := ""; ev != "terminated"; {
+		msg := c.next()
+		json.Unmarshal(msg["event"], &ev)
+		if ev == "output" {
+			var body struct{ Category, Output string }
+			json.Unmarshal(msg["body"], &body)
+			if body.Category == "stderr" {
+				stderr += body.Output
+			}
+		}
+	}
+	if stderr != "bad.wabznasm:2:3: division by zero\n" {
+		t.Errorf("stderr = %q", stderr)
+	}
+	if _, msg := c.try("launch", map[string]any{"program": path}); msg == "" {
+		t.Errorf("a second launch succeeded")
+	}
+	c.call("disconnect", nil)
+}
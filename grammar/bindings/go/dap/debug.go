@@ -0,0 +1,358 @@
+package dap
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/position"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+)
+
+// errKilled unwinds the evaluation of a script stopped by the client.
+var errKilled = errors.New("dap: script stopped")
+
+// stepMode is how far a resumed script runs before it stops again.
+type stepMode int
+
+const (
+	// stepNone runs to the next breakpoint.
+	stepNone stepMode = iota
+	// stepInto stops at the next step.
+	stepInto
+	// stepOver stops at the next step outside the calls made from the
+	// stopped frame.
+	stepOver
+	// stepOut stops at the next step in a caller of the stopped frame.
+	stepOut
+	// stepAbort stops the script.
+	stepAbort
+)
+
+// action resumes a stopped script.
+type action struct {
+	mode stepMode
+	// depth is the number of calls in progress where the script stopped.
+	depth int
+}
+
+// location is a line of the script and the depth of the call evaluating
+// it, to tell when evaluation reaches a line anew.
+type location struct {
+	row   uint
+	depth int
+}
+
+// positioned is the expression a frame is evaluating.
+type positioned struct {
+	expr ast.Expr
+	env  *eval.Env
+}
+
+// breakpoint is a line or function breakpoint as set by the client.
+type breakpoint struct {
+	id        int
+	condition ast.Expr
+}
+
+// runScript evaluates the statements of the script in turn, reporting the
+// value of each expression statement and stopping at the first error.
+func (s *Server) runScript() {
+	s.mu.Lock()
+	src, program, launch := s.source, s.program, s.launch
+	s.mu.Unlock()
+	s.interp = eval.New()
+	s.interp.Hook = s.hook
+	s.step = action{mode: stepNone}
+	s.onEntry = launch.StopOnEntry && !launch.NoDebug
+
+	status := 0
+	killed := false
+	err := repl.RunScript(context.Background(), s.interp, src, func(st *repl.Statement) bool {
+		s.root = nil
+		switch {
+		case errors.Is(st.Err, errKilled):
+			killed = true
+		case st.Err != nil:
+			s.output("stderr", fmt.Sprintf("%s:%v\n", filepath.Base(program), st.Err))
+			status = 1
+		default:
+			if _, ok := st.Stmt.(*ast.ExprStmt); ok && st.Value != nil {
+				s.output("stdout", st.Value.String()+"\n")
+			}
+			return true
+		}
+		return false
+	})
+	if killed {
+		return
+	}
+	if err != nil {
+		s.output("stderr", err.Error()+"\n")
+		status = 1
+	}
+	s.finish(status)
+}
+
+func (s *Server) output(category, text string) {
+	s.conn.event("output", outputEvent{Category: category, Output: text})
+}
+
+func (s *Server) finish(status int) {
+	s.conn.event("exited", map[string]int{"exitCode": status})
+	s.conn.event("terminated", nil)
+}
+
+// hook runs before each expression the script evaluates and stops it if
+// it reached a step it should stop at.
+func (s *Server) hook(e ast.Expr, env *eval.Env) error {
+	if s.abort.Load() {
+		return errKilled
+	}
+	frames := s.interp.Frames()
+	depth := len(frames)
+	for len(s.current) < depth {
+		s.current = append(s.current, positioned{})
+	}
+	s.current = append(s.current[:depth], positioned{e, env})
+	here := location{e.Pos().Row, depth}
+	arrived := here != s.last
+	s.last = here
+
+	step := s.isStep(e, env, frames)
+	reason, description := "", ""
+	var hit []int
+	switch {
+	case s.pause.Swap(false):
+		reason = StopPause
+	case step && s.onEntry:
+		reason, s.onEntry = StopEntry, false
+	case step && s.stepping(depth):
+		reason = StopStep
+	}
+	s.mu.Lock()
+	noDebug := s.launch.NoDebug
+	bp := s.breakpoints[s.program][int(here.row)]
+	var fn *breakpoint
+	if n := len(frames); n > 0 && frames[n-1].Call != nil && e == frames[n-1].Function.Body && env == frames[n-1].Env {
+		fn = s.functions[frames[n-1].Call.Func.Name]
+	}
+	s.mu.Unlock()
+	if reason == "" && !noDebug {
+		switch {
+		case arrived && bp != nil && s.holds(bp, env):
+			reason, hit = StopBreakpoint, []int{bp.id}
+		case fn != nil && s.holds(fn, env):
+			reason, hit = StopFunctionBreakpoint, []int{fn.id}
+			description = "Paused on entry to " + frames[len(frames)-1].Call.Func.Name
+		}
+	}
+	if reason == "" {
+		return nil
+	}
+	return s.wait(reason, description, hit, depth)
+}
+
+// isStep reports whether e begins a step: it is the root of a statement,
+// the body of the function just called, or a call.
+func (s *Server) isStep(e ast.Expr, env *eval.Env, frames []eval.Frame) bool {
+	if n := len(frames); n == 0 {
+		// The first expression of a statement is its root.
+		if s.root == nil {
+			s.root = e
+		}
+		if e == s.root {
+			return true
+		}
+	} else if e == frames[n-1].Function.Body && env == frames[n-1].Env {
+		return true
+	}
+	_, ok := e.(*ast.Call)
+	return ok
+}
+
+// stepping reports whether a step at depth ends the current step action.
+func (s *Server) stepping(depth int) bool {
+	switch s.step.mode {
+	case stepInto:
+		return true
+	case stepOver:
+		return depth <= s.step.depth
+	case stepOut:
+		return depth < s.step.depth
+	}
+	return false
+}
+
+// holds reports whether the condition of bp, if any, holds in env. A
+// condition that fails to evaluate stops the script, with a note.
+func (s *Server) holds(bp *breakpoint, env *eval.Env) bool {
+	if bp.condition == nil {
+		return true
+	}
+	hook := s.interp.Hook
+	s.interp.Hook = nil
+	v, err := s.interp.Eval(bp.condition, env)
+	s.interp.Hook = hook
+	if err != nil {
+		s.output("console", fmt.Sprintf("breakpoint condition: %v\n", err))
+		return true
+	}
+	return truthy(v)
+}
+
+// truthy reports whether v counts as true in a condition: anything but a
+// numeric zero.
+func truthy(v eval.Value) bool {
+	switch v := v.(type) {
+	case eval.Long:
+		return v != 0
+	case eval.Float:
+		return v != 0
+	}
+	return true
+}
+
+// wait reports that the script stopped and blocks until the client
+// resumes it.
+func (s *Server) wait(reason, description string, hit []int, depth int) error {
+	st := &stopState{depth: depth, refs: map[int]any{}}
+	frames := s.interp.Frames()
+	for level := depth; level >= 0; level-- {
+		p := s.current[level]
+		name := filepath.Base(s.program)
+		if level > 0 {
+			f := frames[level-1]
+			name = "<function>"
+			if f.Call != nil {
+				name = f.Call.Func.Name
+			}
+		}
+		if level < depth && frames[level].Call != nil {
+			// The frame is evaluating the call of the one within.
+			p.expr = frames[level].Call
+		}
+		st.frames = append(st.frames, p)
+		st.stackFrames = append(st.stackFrames, s.stackFrame(len(st.frames), name, p.expr))
+	}
+	s.mu.Lock()
+	s.stopped = st
+	s.mu.Unlock()
+	s.conn.event("stopped", stoppedEvent{
+		Reason:            reason,
+		Description:       description,
+		ThreadID:          threadID,
+		AllThreadsStopped: true,
+		HitBreakpointIDs:  hit,
+	})
+	a := <-s.resume
+	s.mu.Lock()
+	s.stopped = nil
+	s.mu.Unlock()
+	if a.mode == stepAbort || s.abort.Load() {
+		return errKilled
+	}
+	s.step = a
+	return nil
+}
+
+func (s *Server) stackFrame(id int, name string, e ast.Expr) StackFrame {
+	line, col := s.clientPos(e.Pos())
+	endLine, endCol := s.clientPos(e.EndPos())
+	return StackFrame{
+		ID:        id,
+		Name:      name,
+		Source:    &Source{Name: filepath.Base(s.program), Path: s.program},
+		Line:      line,
+		Column:    col,
+		EndLine:   endLine,
+		EndColumn: endCol,
+	}
+}
+
+// clientPos returns the line and column of p as the client counts them,
+// columns in UTF-16 code units.
+func (s *Server) clientPos(p ast.Pos) (line, col int) {
+	line, col = int(p.Row)+1, int(position.Column(s.source, p.Offset, position.UTF16))+1
+	if s.lines0 {
+		line--
+	}
+	if s.columns0 {
+		col--
+	}
+	return line, col
+}
+
+// stopState is what the client can inspect of a stopped script. The
+// script's goroutine is blocked while it is set, so the environments in
+// it may be read and evaluated in.
+type stopState struct {
+	depth       int
+	frames      []positioned
+	stackFrames []StackFrame
+	// refs are the containers of variables the client was given
+	// references to: environments and lists.
+	refs map[int]any
+}
+
+func (st *stopState) frame(id int) (positioned, error) {
+	if id < 1 || id > len(st.frames) {
+		return positioned{}, fmt.Errorf("no frame %d", id)
+	}
+	return st.frames[id-1], nil
+}
+
+func (st *stopState) ref(v any) int {
+	id := len(st.refs) + 1
+	st.refs[id] = v
+	return id
+}
+
+// scopes returns the scopes of a frame: its local environment, those of
+// the functions it is nested in, and the globals.
+func (st *stopState) scopes(id int, globals *eval.Env) ([]Scope, error) {
+	f, err := st.frame(id)
+	if err != nil {
+		return nil, err
+	}
+	var scopes []Scope
+	name := "Locals"
+	for env := f.env; env != nil && env != globals; env = env.Parent() {
+		scopes = append(scopes, Scope{Name: name, PresentationHint: "locals", VariablesReference: st.ref(env)})
+		name = "Closure"
+	}
+	scopes = append(scopes, Scope{Name: "Globals", VariablesReference: st.ref(globals)})
+	return scopes, nil
+}
+
+// variables lists the variables of the container ref names.
+func (st *stopState) variables(ref int) ([]Variable, error) {
+	vars := []Variable{}
+	switch c := st.refs[ref].(type) {
+	case *eval.Env:
+		for _, name := range c.Names() {
+			v, _ := c.Lookup(name)
+			vars = append(vars, st.variable(name, v))
+		}
+	case eval.List:
+		for i, v := range c {
+			vars = append(vars, st.variable(strconv.Itoa(i), v))
+		}
+	default:
+		return nil, fmt.Errorf("no variables %d", ref)
+	}
+	return vars, nil
+}
+
+func (st *stopState) variable(name string, v eval.Value) Variable {
+	out := Variable{Name: name, Value: v.String(), Type: v.Kind().String()}
+	if l, ok := v.(eval.List); ok && len(l) > 0 {
+		out.VariablesReference = st.ref(l)
+		out.IndexedVariables = len(l)
+	}
+	return out
+}
@@ -0,0 +1,169 @@
+package dap
+
+import "encoding/json"
+
+// message is any protocol message: a request from the client, or a
+// response or event from the adapter.
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command,omitempty"`
+	Arguments  json.RawMessage `json:"arguments,omitempty"`
+	RequestSeq int             `json:"request_seq,omitempty"`
+	Success    *bool           `json:"success,omitempty"`
+	Message    string          `json:"message,omitempty"`
+	Event      string          `json:"event,omitempty"`
+	Body       any             `json:"body,omitempty"`
+}
+
+// Capabilities are the optional requests the adapter supports.
+type Capabilities struct {
+	SupportsConfigurationDoneRequest bool `json:"supportsConfigurationDoneRequest"`
+	SupportsFunctionBreakpoints      bool `json:"supportsFunctionBreakpoints"`
+	SupportsConditionalBreakpoints   bool `json:"supportsConditionalBreakpoints"`
+	SupportsEvaluateForHovers        bool `json:"supportsEvaluateForHovers"`
+	SupportsTerminateRequest         bool `json:"supportsTerminateRequest"`
+}
+
+// InitializeArguments are the arguments of initialize.
+type InitializeArguments struct {
+	ClientID        string `json:"clientID"`
+	LinesStartAt1   *bool  `json:"linesStartAt1"`
+	ColumnsStartAt1 *bool  `json:"columnsStartAt1"`
+}
+
+// LaunchArguments are the arguments of launch.
+type LaunchArguments struct {
+	// Program is the path of the script to run.
+	Program string `json:"program"`
+	// StopOnEntry pauses before the first statement.
+	StopOnEntry bool `json:"stopOnEntry"`
+	// NoDebug runs the script without stopping at breakpoints.
+	NoDebug bool `json:"noDebug"`
+}
+
+// Source is a script.
+type Source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+// SourceBreakpoint is a breakpoint on a line, which only stops if its
+// Condition, if any, evaluates to something other than zero.
+type SourceBreakpoint struct {
+	Line      int    `json:"line"`
+	Condition string `json:"condition,omitempty"`
+}
+
+// SetBreakpointsArguments are the arguments of setBreakpoints, which
+// replaces the breakpoints of a source.
+type SetBreakpointsArguments struct {
+	Source      Source             `json:"source"`
+	Breakpoints []SourceBreakpoint `json:"breakpoints"`
+}
+
+// FunctionBreakpoint stops on entry to the functions called by Name.
+type FunctionBreakpoint struct {
+	Name      string `json:"name"`
+	Condition string `json:"condition,omitempty"`
+}
+
+// SetFunctionBreakpointsArguments are the arguments of
+// setFunctionBreakpoints, which replaces all function breakpoints.
+type SetFunctionBreakpointsArguments struct {
+	Breakpoints []FunctionBreakpoint `json:"breakpoints"`
+}
+
+// Breakpoint reports whether a requested breakpoint could be set.
+type Breakpoint struct {
+	ID       int     `json:"id"`
+	Verified bool    `json:"verified"`
+	Message  string  `json:"message,omitempty"`
+	Source   *Source `json:"source,omitempty"`
+	Line     int     `json:"line,omitempty"`
+}
+
+// Thread is a thread of the debuggee. Scripts run on a single thread.
+type Thread struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// StackFrame is a frame of a stack trace, located at the expression it
+// is evaluating.
+type StackFrame struct {
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	Source    *Source `json:"source,omitempty"`
+	Line      int     `json:"line"`
+	Column    int     `json:"column"`
+	EndLine   int     `json:"endLine,omitempty"`
+	EndColumn int     `json:"endColumn,omitempty"`
+}
+
+// Scope is a group of variables of a frame.
+type Scope struct {
+	Name               string `json:"name"`
+	PresentationHint   string `json:"presentationHint,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+// Variable is a name and its value. A VariablesReference other than zero
+// names the children of a list.
+type Variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	Type               string `json:"type,omitempty"`
+	VariablesReference int    `json:"variablesReference"`
+	IndexedVariables   int    `json:"indexedVariables,omitempty"`
+}
+
+// Arguments of the requests that only name a thread, frame or variable
+// container.
+type (
+	threadArguments struct {
+		ThreadID int `json:"threadId"`
+	}
+	scopesArguments struct {
+		FrameID int `json:"frameId"`
+	}
+	variablesArguments struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+)
+
+// EvaluateArguments are the arguments of evaluate. FrameID selects the
+// paused frame whose environment the expression is evaluated in.
+type EvaluateArguments struct {
+	Expression string `json:"expression"`
+	FrameID    int    `json:"frameId"`
+	Context    string `json:"context,omitempty"`
+}
+
+// Reasons given in stopped events.
+const (
+	StopEntry              = "entry"
+	StopStep               = "step"
+	StopBreakpoint         = "breakpoint"
+	StopFunctionBreakpoint = "function breakpoint"
+	StopPause              = "pause"
+)
+
+// stoppedEvent is the body of a stopped event.
+type stoppedEvent struct {
+	Reason            string `json:"reason"`
+	Description       string `json:"description,omitempty"`
+	ThreadID          int    `json:"threadId"`
+	AllThreadsStopped bool   `json:"allThreadsStopped"`
+	HitBreakpointIDs  []int  `json:"hitBreakpointIds,omitempty"`
+}
+
+// outputEvent is the body of an output event.
+type outputEvent struct {
+	Category string  `json:"category"`
+	Output   string  `json:"output"`
+	Source   *Source `json:"source,omitempty"`
+	Line     int     `json:"line,omitempty"`
+	Column   int     `json:"column,omitempty"`
+}
@@ -0,0 +1,294 @@
+// Package dap implements a Debug Adapter Protocol server that runs
+// wabznasm scripts under the evaluator, so that an editor can set
+// breakpoints, step through a script and inspect and evaluate in the
+// environment of a paused call.
+//
+// A script is split into statements as the REPL splits its input, and the
+// statements are evaluated in turn by one interpreter. Stepping stops
+// before each step: a statement, the body of a called function, or a
+// call. A line breakpoint stops at the first expression evaluated on its
+// line, and a function breakpoint on entry to the body of a function
+// called by its name.
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// threadID is the one thread of a script.
+const threadID = 1
+
+// errNotStopped fails requests that need a paused script.
+var errNotStopped = errors.New("the script is not stopped")
+
+// Server is a debug adapter speaking the protocol over a reader and
+// writer, typically stdin and stdout. It debugs one script per session.
+// Requests are handled one at a time in the order they arrive, while the
+// script runs on a goroutine of its own.
+type Server struct {
+	conn *conn
+	// lines0 and columns0 report that the client counts from zero.
+	lines0, columns0 bool
+	// after runs once the response to the current request is written.
+	after   func()
+	running bool
+	ended   chan struct{}
+
+	// mu guards the fields below, which the script's goroutine reads.
+	mu          sync.Mutex
+	launch      *LaunchArguments
+	configured  bool
+	program     string
+	source      []byte
+	breakpoints map[string]map[int]*breakpoint
+	functions   map[string]*breakpoint
+	nextID      int
+	stopped     *stopState
+
+	interp *eval.Interpreter
+	resume chan action
+	pause  atomic.Bool
+	abort  atomic.Bool
+	// State of the script's goroutine.
+	step    action
+	onEntry bool
+	root    ast.Expr
+	current []positioned
+	last    location
+}
+
+// NewServer returns an adapter reading requests from r and writing
+// responses and events to w.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		conn:        newConn(r, w),
+		breakpoints: map[string]map[int]*breakpoint{},
+		functions:   map[string]*breakpoint{},
+		resume:      make(chan action, 1),
+	}
+}
+
+// Run serves requests until the client sends disconnect or closes the
+// input, then stops the script. It returns nil after a disconnect.
+func (s *Server) Run() error {
+	for {
+		body, err := s.conn.read()
+		if err != nil {
+			s.kill()
+			return err
+		}
+		var req message
+		if err := json.Unmarshal(body, &req); err != nil {
+			return fmt.Errorf("dap: %v", err)
+		}
+		if req.Type != "request" {
+			continue
+		}
+		result, err := s.handle(&req)
+		if err := s.conn.reply(&req, result, err); err != nil {
+			s.kill()
+			return err
+		}
+		if f := s.after; f != nil {
+			s.after = nil
+			f()
+		}
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+func (s *Server) handle(req *message) (any, error) {
+	switch req.Command {
+	case "initialize":
+		var args InitializeArguments
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+		s.lines0 = args.LinesStartAt1 != nil && !*args.LinesStartAt1
+		s.columns0 = args.ColumnsStartAt1 != nil && !*args.ColumnsStartAt1
+		s.after = func() { s.conn.event("initialized", nil) }
+		return Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsFunctionBreakpoints:      true,
+			SupportsConditionalBreakpoints:   true,
+			SupportsEvaluateForHovers:        true,
+			SupportsTerminateRequest:         true,
+		}, nil
+	case "launch":
+		var args LaunchArguments
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+		return nil, s.doLaunch(&args)
+	case "configurationDone":
+		s.mu.Lock()
+		s.configured = true
+		s.mu.Unlock()
+		s.after = s.start
+		return nil, nil
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(&args)
+	case "setFunctionBreakpoints":
+		var args SetFunctionBreakpointsArguments
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+		return s.setFunctionBreakpoints(&args), nil
+	case "setExceptionBreakpoints":
+		return map[string]any{}, nil
+	case "threads":
+		return map[string]any{"threads": []Thread{{ID: threadID, Name: "main"}}}, nil
+	case "stackTrace":
+		st := s.paused()
+		if st == nil {
+			return nil, errNotStopped
+		}
+		return map[string]any{"stackFrames": st.stackFrames, "totalFrames": len(st.stackFrames)}, nil
+	case "scopes":
+		var args scopesArguments
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+		st := s.paused()
+		if st == nil {
+			return nil, errNotStopped
+		}
+		scopes, err := st.scopes(args.FrameID, s.interp.Globals)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"scopes": scopes}, nil
+	case "variables":
+		var args variablesArguments
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+		st := s.paused()
+		if st == nil {
+			return nil, errNotStopped
+		}
+		vars, err := st.variables(args.VariablesReference)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]any{"variables": vars}, nil
+	case "evaluate":
+		var args EvaluateArguments
+		if err := decode(req, &args); err != nil {
+			return nil, err
+		}
+		return s.evaluate(&args)
+	case "continue":
+		return map[string]any{"allThreadsContinued": true}, s.resumeWith(stepNone)
+	case "next":
+		return nil, s.resumeWith(stepOver)
+	case "stepIn":
+		return nil, s.resumeWith(stepInto)
+	case "stepOut":
+		return nil, s.resumeWith(stepOut)
+	case "pause":
+		s.pause.Store(true)
+		return nil, nil
+	case "terminate":
+		s.after = s.kill
+		return nil, nil
+	case "disconnect":
+		s.after = s.kill
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unsupported request %q", req.Command)
+}
+
+func decode(req *message, v any) error {
+	if len(req.Arguments) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(req.Arguments, v); err != nil {
+		return fmt.Errorf("invalid arguments to %s: %v", req.Command, err)
+	}
+	return nil
+}
+
+func (s *Server) doLaunch(args *LaunchArguments) error {
+	if args.Program == "" {
+		return errors.New("launch: no program given")
+	}
+	src, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.launch != nil {
+		return errors.New("launch: a script is already launched")
+	}
+	s.launch = args
+	s.program = filepath.Clean(args.Program)
+	s.source = src
+	s.after = s.start
+	return nil
+}
+
+// start runs the script once it is launched and configured.
+func (s *Server) start() {
+	s.mu.Lock()
+	ready := s.launch != nil && s.configured && !s.running
+	s.running = s.running || ready
+	s.mu.Unlock()
+	if !ready {
+		return
+	}
+	s.ended = make(chan struct{})
+	go func() {
+		defer close(s.ended)
+		s.runScript()
+	}()
+}
+
+// kill stops the script if it is running and waits for it to end.
+func (s *Server) kill() {
+	if !s.running {
+		return
+	}
+	s.abort.Store(true)
+	select {
+	case s.resume <- action{mode: stepAbort}:
+	default:
+	}
+	<-s.ended
+}
+
+// paused returns the state of the stopped script, or nil if it is not
+// stopped.
+func (s *Server) paused() *stopState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.stopped
+}
+
+// resumeWith lets the stopped script continue in mode once the response
+// has been written, so that it precedes the next stopped event.
+func (s *Server) resumeWith(mode stepMode) error {
+	st := s.paused()
+	if st == nil {
+		return errNotStopped
+	}
+	s.after = func() { s.resume <- action{mode: mode, depth: st.depth} }
+	return nil
+}
@@ -0,0 +1,146 @@
+// Package deadcode finds the globals of a project that nothing uses.
+//
+// A global is dead when no statement of the project refers to it, other
+// than its own definition, as in a function calling itself, or the
+// definitions of other globals that refer only to each other, as in two
+// functions calling one another and nothing else calling either. Bare
+// expression statements, which assign nothing, are always live, and so
+// are the globals named as entry points, which an embedder calls from
+// outside the project.
+//
+// Only directly dead globals are reported: a helper used only by a dead
+// function becomes dead once the function is deleted, and is reported then.
+package deadcode
+
+import (
+	"sort"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/depgraph"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+// Options adjust what is reported.
+type Options struct {
+	// Keep names globals that are used from outside the project and are
+	// never dead.
+	Keep []string
+}
+
+// Dead is an unused global.
+type Dead struct {
+	Name string
+	// Func is set when the global is a function.
+	Func bool
+	// Path is the file defining the global, and Span its name there. A
+	// global assigned in several files is reported for each.
+	Path string
+	Span ast.Span
+	// Peers are the other globals of a group that refer only to each
+	// other, sorted; empty for a global nothing refers to.
+	Peers []string
+	// Delete removes the definition from its file, together with its doc
+	// comment and the end of its line.
+	Delete ast.Edit
+}
+
+// Find returns the dead globals of p, ordered by path.
+func Find(p *project.Project, opts Options) []Dead {
+	keep := map[string]bool{}
+	for _, name := range opts.Keep {
+		keep[name] = true
+	}
+	g := depgraph.FromProject(p)
+	group := map[string][]string{}
+	for _, cycle := range g.Cycles() {
+		for _, name := range cycle {
+			group[name] = cycle
+		}
+	}
+
+	var out []Dead
+	for _, name := range p.Globals() {
+		if keep[name] || used(p, name, group[name]) {
+			continue
+		}
+		var peers []string
+		for _, peer := range group[name] {
+			if peer != name {
+				peers = append(peers, peer)
+			}
+		}
+		sort.Strings(peers)
+		for _, loc := range p.Definitions(name) {
+			f := p.File(loc.File)
+			if f == nil {
+				continue
+			}
+			sym := f.Symbols.Global.Symbols[name]
+			out = append(out, Dead{
+				Name:   name,
+				Func:   sym.Func != nil,
+				Path:   loc.File,
+				Span:   loc.Span,
+				Peers:  peers,
+				Delete: deletion(f, f.Symbols.FileAt(loc.Span.Start.Offset)),
+			})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Path < out[j].Path })
+	return out
+}
+
+// used reports whether some statement other than the definitions of name
+// and of its group refers to name.
+func used(p *project.Project, name string, group []string) bool {
+	inside := map[string]bool{name: true}
+	for _, peer := range group {
+		inside[peer] = true
+	}
+	for _, ref := range p.References(name) {
+		f := p.File(ref.File)
+		if f == nil || !inside[assigned(f.Symbols.FileAt(ref.Span.Start.Offset))] {
+			return true
+		}
+	}
+	return false
+}
+
+// assigned returns the name a statement assigns, or "".
+func assigned(stmt *ast.File) string {
+	if stmt == nil {
+		return ""
+	}
+	if a, ok := stmt.Stmt.(*ast.Assignment); ok && a.Name != nil {
+		return a.Name.Name
+	}
+	return ""
+}
+
+// deletion returns the edit removing a statement of f, the comments on
+// the lines just above it and the rest of its last line.
+func deletion(f *project.File, file *ast.File) ast.Edit {
+	stmt := file.Stmt
+	start, end := stmt.Pos(), stmt.EndPos()
+	comments := file.Comments
+	for i := len(comments) - 1; i >= 0; i-- {
+		c := comments[i]
+		if c.Start.Offset < start.Offset && c.Start.Row+1 == start.Row {
+			start = c.Start
+		}
+	}
+	// Take a comment trailing the statement on its line, and the newline.
+	for _, c := range comments {
+		if c.Start.Offset >= end.Offset && c.Start.Row == end.Row {
+			end = c.End
+		}
+	}
+	rest := end.Offset
+	for rest < uint(len(f.Source)) && (f.Source[rest] == ' ' || f.Source[rest] == '\t' || f.Source[rest] == '\r') {
+		rest++
+	}
+	if rest < uint(len(f.Source)) && f.Source[rest] == '\n' {
+		end = ast.Pos{Offset: rest + 1, Row: end.Row + 1}
+	}
+	return ast.Edit{Start: start, End: end}
+}
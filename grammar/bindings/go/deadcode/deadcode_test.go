@@ -0,0 +1,86 @@
+package deadcode_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/deadcode"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+func open(t *testing.T, files map[string]string) *project.Project {
+	t.Helper()
+	dir := t.TempDir()
+	for name, src := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := project.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestFind(t *testing.T) {
+	p := open(t, map[string]string{
+		"sq.wz":   "sq: {x*x}",
+		"norm.wz": "norm: {[a;b] sq[a]+sq[b]}",
+		"run.wz":  "norm[3;4]",
+		"old.wz":  "\\ increments\nold: {x+1} \\ unused\n\\ end\n",
+		"ping.wz": "ping: {[n] pong[n-1]}",
+		"pong.wz": "pong: {[n] ping[n-1]}",
+		"fact.wz": "fact: {[n] n*fact[n-1]}",
+		"main.wz": "main: {norm[1;2]}",
+		"rate.wz": "rate: 5",
+		"tax.wz":  "tax: {x*rate}",
+	})
+	var got []string
+	for _, d := range deadcode.Find(p, deadcode.Options{Keep: []string{"main"}}) {
+		entry := d.Name
+		if len(d.Peers) > 0 {
+			entry += "<->" + strings.Join(d.Peers, ",")
+		}
+		if filepath.Base(d.Path) != d.Name+".wz" {
+			t.Errorf("%s reported in %s", d.Name, d.Path)
+		}
+		got = append(got, entry)
+	}
+	want := []string{"fact", "old", "ping<->pong", "pong<->ping", "tax"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Find = %v, want %v", got, want)
+	}
+}
+
+func TestFindScript(t *testing.T) {
+	// The statements of a file use the globals the ones before them assign.
+	p := open(t, map[string]string{"lib.wz": "a: 1\nb: a+1\nc: 2\n"})
+	var got []string
+	for _, d := range deadcode.Find(p, deadcode.Options{Keep: []string{"b"}}) {
+		got = append(got, d.Name)
+	}
+	if want := []string{"c"}; !slices.Equal(got, want) {
+		t.Errorf("Find = %v, want %v", got, want)
+	}
+}
+
+func TestDelete(t *testing.T) {
+	src := "\\ increments\nold: {x+1} \\ unused\n\\ end\n"
+	p := open(t, map[string]string{"old.wz": src})
+	dead := deadcode.Find(p, deadcode.Options{})
+	if len(dead) != 1 {
+		t.Fatalf("Find = %v, want old", dead)
+	}
+	out, err := ast.Apply([]byte(src), []ast.Edit{dead[0].Delete})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "\\ end\n" {
+		t.Errorf("after Delete: %q, want %q", out, "\\ end\n")
+	}
+}
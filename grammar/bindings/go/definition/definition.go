@@ -0,0 +1,88 @@
+// Package definition finds where the name under a cursor is defined.
+//
+// At works on a single buffer. AtIn additionally consults an Index for
+// globals defined in other files, such as the symbol index of package
+// project or the open documents of a language server.
+package definition
+
+import (
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// Location is a span of a file. File is empty for the buffer passed to At.
+type Location struct {
+	File string
+	Span ast.Span
+}
+
+// Index resolves global names across files.
+type Index interface {
+	// Definitions returns the assignments to name in every indexed file.
+	Definitions(name string) []Location
+	// References returns the uses of the global name in every indexed file,
+	// excluding uses that resolve to a parameter.
+	References(name string) []Location
+}
+
+// At returns the definition of the name at point in a single buffer. A
+// parameter or global resolves to its declaration; an implicit parameter to
+// the function literal that takes it. Names defined elsewhere resolve to
+// nothing.
+func At(tree *tree_sitter.Tree, source []byte, point tree_sitter.Point) []Location {
+	return AtIn(nil, "", tree, source, point)
+}
+
+// AtIn is like At for a buffer called file, looking up globals in idx when
+// it is non-nil. Every definition of a global is returned, this file's
+// first.
+func AtIn(idx Index, file string, tree *tree_sitter.Tree, source []byte, point tree_sitter.Point) []Location {
+	tab := scopes.FromTree(tree, source)
+	sym := tab.ResolveAt(tree_sitter_wabznasm.OffsetForPoint(source, point))
+	if sym == nil {
+		return nil
+	}
+	return Of(idx, file, sym)
+}
+
+// Of returns the definitions of a resolved symbol of file.
+func Of(idx Index, file string, sym *scopes.Symbol) []Location {
+	var out []Location
+	switch sym.Kind {
+	case scopes.Param:
+		return []Location{{File: file, Span: span(sym.Decl)}}
+	case scopes.Implicit:
+		fn := sym.Scope.Func
+		return []Location{{File: file, Span: ast.Span{Start: fn.Pos(), End: fn.EndPos()}}}
+	case scopes.Global:
+		out = append(out, Location{File: file, Span: span(sym.Decl)})
+		for _, id := range sym.Redefs {
+			out = append(out, Location{File: file, Span: span(id)})
+		}
+	}
+	if idx != nil {
+		out = Merge(out, idx.Definitions(sym.Name))
+	}
+	return out
+}
+
+// Merge appends the locations of more not already in locs.
+func Merge(locs, more []Location) []Location {
+	seen := map[Location]bool{}
+	for _, l := range locs {
+		seen[l] = true
+	}
+	for _, l := range more {
+		if !seen[l] {
+			seen[l] = true
+			locs = append(locs, l)
+		}
+	}
+	return locs
+}
+
+func span(id *ast.Ident) ast.Span {
+	return ast.Span{Start: id.Pos(), End: id.EndPos()}
+}
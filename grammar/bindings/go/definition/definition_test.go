@@ -0,0 +1,72 @@
+package definition_test
+
+import (
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/definition"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func parse(t *testing.T, src string) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+// fakeIndex defines lib in lib.wz.
+type fakeIndex struct{}
+
+func (fakeIndex) Definitions(name string) []definition.Location {
+	if name != "lib" {
+		return nil
+	}
+	return []definition.Location{{File: "lib.wz", Span: ast.Span{End: ast.Pos{Offset: 3, Column: 3}}}}
+}
+
+func (fakeIndex) References(string) []definition.Location { return nil }
+
+func TestAt(t *testing.T) {
+	src := "f: {[a] a+lib[a]}"
+	tree := parse(t, src)
+	at := func(col uint) []definition.Location {
+		return definition.At(tree.Tree, []byte(src), tree_sitter.Point{Column: col})
+	}
+	if locs := at(8); len(locs) != 1 || locs[0].Span.Start.Offset != 5 {
+		t.Errorf("definition of a = %+v", locs)
+	}
+	if locs := at(0); len(locs) != 1 || locs[0].Span.Start.Offset != 0 || locs[0].Span.End.Offset != 1 {
+		t.Errorf("definition of f = %+v", locs)
+	}
+	if locs := at(11); locs != nil {
+		t.Errorf("definition of free lib without index = %+v", locs)
+	}
+	if locs := at(2); locs != nil {
+		t.Errorf("definition at ':' = %+v", locs)
+	}
+
+	locs := definition.AtIn(fakeIndex{}, "f.wz", tree.Tree, []byte(src), tree_sitter.Point{Column: 11})
+	if len(locs) != 1 || locs[0].File != "lib.wz" {
+		t.Errorf("definition of lib with index = %+v", locs)
+	}
+}
+
+func TestAtImplicit(t *testing.T) {
+	src := "g: {x*2}"
+	tree := parse(t, src)
+	locs := definition.At(tree.Tree, []byte(src), tree_sitter.Point{Column: 4})
+	if len(locs) != 1 || locs[0].Span.Start.Offset != 3 || locs[0].Span.End.Offset != 8 {
+		t.Errorf("definition of implicit x = %+v", locs)
+	}
+}
@@ -0,0 +1,277 @@
+// Package depgraph builds the graph of which assignments of a script use
+// which names, for embedders that recompute values when their inputs
+// change, the way a spreadsheet does.
+//
+// A script is a sequence of sources, each holding one or more statements,
+// such as the files of a project or the cells of a sheet. Every assignment
+// is a node, depending on the global names its value mentions, including
+// those inside function bodies. A function calling itself does not depend
+// on itself; any other self-reference is a cycle. Names that are used but
+// assigned nowhere in the script are inputs.
+package depgraph
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// Node is an assignment of the script.
+type Node struct {
+	Name string
+	// Index is the position in the script of the source assigning Name.
+	// When a name is assigned more than once, the last assignment counts.
+	Index int
+	// seq is the position in the script of the statement assigning Name,
+	// counting the statements of every source.
+	seq int
+	// Func is set when the value is a function literal.
+	Func bool
+	// Deps are the global names the value uses, sorted.
+	Deps []string
+}
+
+// Graph is the dependency graph of a script.
+type Graph struct {
+	nodes      map[string]*Node
+	dependents map[string][]string
+	inputs     []string
+}
+
+// CycleError reports assignments that depend on each other.
+type CycleError struct {
+	// Cycles are the names of each group of mutually dependent
+	// assignments, as returned by Graph.Cycles.
+	Cycles [][]string
+}
+
+func (e *CycleError) Error() string {
+	parts := make([]string, len(e.Cycles))
+	for i, c := range e.Cycles {
+		parts[i] = strings.Join(c, " -> ") + " -> " + c[0]
+	}
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(parts, "; "))
+}
+
+// Build returns the graph of the statements analysed by tables, in script
+// order.
+func Build(tables []*scopes.Table) *Graph {
+	g := &Graph{nodes: map[string]*Node{}, dependents: map[string][]string{}}
+	seq := 0
+	for i, tab := range tables {
+		for _, stmt := range tab.Stmts() {
+			seq++
+			a, ok := stmt.(*ast.Assignment)
+			if !ok || a.Name == nil {
+				continue
+			}
+			sym := tab.Resolve(a.Name)
+			n := &Node{Name: sym.Name, Index: i, seq: seq, Func: sym.Func != nil}
+			for _, used := range tab.Mentions(stmt) {
+				if used == sym && n.Func {
+					continue
+				}
+				n.Deps = append(n.Deps, used.Name)
+			}
+			sort.Strings(n.Deps)
+			g.nodes[sym.Name] = n
+		}
+	}
+	inputs := map[string]bool{}
+	for _, n := range g.Nodes() {
+		for _, dep := range n.Deps {
+			g.dependents[dep] = append(g.dependents[dep], n.Name)
+			if g.nodes[dep] == nil {
+				inputs[dep] = true
+			}
+		}
+	}
+	for name := range inputs {
+		g.inputs = append(g.inputs, name)
+	}
+	sort.Strings(g.inputs)
+	return g
+}
+
+// FromProject returns the graph of the files of p in path order.
+func FromProject(p *project.Project) *Graph {
+	var tables []*scopes.Table
+	for _, path := range p.Files() {
+		if f := p.File(path); f != nil {
+			tables = append(tables, f.Symbols)
+		}
+	}
+	return Build(tables)
+}
+
+// Nodes returns the assignments in script order.
+func (g *Graph) Nodes() []*Node {
+	out := make([]*Node, 0, len(g.nodes))
+	for _, n := range g.nodes {
+		out = append(out, n)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].seq < out[j].seq })
+	return out
+}
+
+// Node returns the assignment of name, or nil if the script does not
+// assign it.
+func (g *Graph) Node(name string) *Node { return g.nodes[name] }
+
+// Inputs returns the names used but not assigned by the script, sorted.
+func (g *Graph) Inputs() []string { return append([]string(nil), g.inputs...) }
+
+// Dependents returns the assignments using name directly, in script order.
+func (g *Graph) Dependents(name string) []string {
+	return append([]string(nil), g.dependents[name]...)
+}
+
+// Cycles returns each group of assignments that depend on each other,
+// directly or not. Each group lists its names in script order, and groups
+// are ordered by their first name.
+func (g *Graph) Cycles() [][]string {
+	// Tarjan's strongly connected components.
+	index := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	var stack []string
+	var out [][]string
+	var visit func(n *Node)
+	visit = func(n *Node) {
+		index[n.Name] = len(index)
+		low[n.Name] = index[n.Name]
+		stack = append(stack, n.Name)
+		onStack[n.Name] = true
+		for _, dep := range n.Deps {
+			d := g.nodes[dep]
+			switch {
+			case d == nil:
+			case !has(index, dep):
+				visit(d)
+				low[n.Name] = min(low[n.Name], low[dep])
+			case onStack[dep]:
+				low[n.Name] = min(low[n.Name], index[dep])
+			}
+		}
+		if low[n.Name] != index[n.Name] {
+			return
+		}
+		var scc []string
+		for {
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[top] = false
+			scc = append(scc, top)
+			if top == n.Name {
+				break
+			}
+		}
+		if len(scc) > 1 || contains(n.Deps, n.Name) {
+			sort.Slice(scc, func(i, j int) bool { return g.nodes[scc[i]].seq < g.nodes[scc[j]].seq })
+			out = append(out, scc)
+		}
+	}
+	for _, n := range g.Nodes() {
+		if !has(index, n.Name) {
+			visit(n)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return g.nodes[out[i][0]].seq < g.nodes[out[j][0]].seq })
+	return out
+}
+
+// Order returns every assignment after the assignments it depends on,
+// keeping script order where the dependencies allow. It fails with a
+// *CycleError if assignments depend on each other.
+func (g *Graph) Order() ([]string, error) {
+	return g.order(nil)
+}
+
+// Affected returns the assignments to recompute, in order, when the
+// values of the changed names change: the changed assignments themselves
+// and every assignment depending on them, directly or not. Changed names
+// may be inputs. It fails with a *CycleError if the assignments to
+// recompute depend on each other.
+func (g *Graph) Affected(changed ...string) ([]string, error) {
+	keep := map[string]bool{}
+	var mark func(name string)
+	mark = func(name string) {
+		if keep[name] {
+			return
+		}
+		keep[name] = true
+		for _, d := range g.dependents[name] {
+			mark(d)
+		}
+	}
+	for _, name := range changed {
+		mark(name)
+	}
+	return g.order(keep)
+}
+
+// order sorts the assignments in keep, or all of them if keep is nil,
+// topologically with Kahn's algorithm, taking the earliest ready statement
+// first.
+func (g *Graph) order(keep map[string]bool) ([]string, error) {
+	pending := map[string]int{}
+	var nodes []*Node
+	for _, n := range g.Nodes() {
+		if keep != nil && !keep[n.Name] {
+			continue
+		}
+		nodes = append(nodes, n)
+		pending[n.Name] = 0
+	}
+	for _, n := range nodes {
+		for _, dep := range n.Deps {
+			if _, ok := pending[dep]; ok {
+				pending[n.Name]++
+			}
+		}
+	}
+	var ready, out []string
+	for _, n := range nodes {
+		if pending[n.Name] == 0 {
+			ready = append(ready, n.Name)
+		}
+	}
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool { return g.nodes[ready[i]].seq < g.nodes[ready[j]].seq })
+		name := ready[0]
+		ready = ready[1:]
+		out = append(out, name)
+		for _, d := range g.dependents[name] {
+			if _, ok := pending[d]; !ok {
+				continue
+			}
+			if pending[d]--; pending[d] == 0 {
+				ready = append(ready, d)
+			}
+		}
+	}
+	if len(out) < len(nodes) {
+		var cycles [][]string
+		for _, c := range g.Cycles() {
+			if _, ok := pending[c[0]]; ok {
+				cycles = append(cycles, c)
+			}
+		}
+		return out, &CycleError{Cycles: cycles}
+	}
+	return out, nil
+}
+
+func has(m map[string]int, key string) bool {
+	_, ok := m[key]
+	return ok
+}
+
+func contains(names []string, name string) bool {
+	i := sort.SearchStrings(names, name)
+	return i < len(names) && names[i] == name
+}
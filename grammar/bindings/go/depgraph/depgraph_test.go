@@ -0,0 +1,118 @@
+package depgraph_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/depgraph"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+func build(t *testing.T, script ...string) *depgraph.Graph {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	var tables []*scopes.Table
+	for _, src := range script {
+		tree, err := parser.ParseString(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, scopes.FromTree(tree.Tree, []byte(src)))
+		tree.Close()
+	}
+	return depgraph.Build(tables)
+}
+
+func TestGraph(t *testing.T) {
+	g := build(t,
+		"total: net+tax",
+		"tax: net*rate",
+		"net: gross-costs",
+		"fact: {[n] n*fact[n-1]}",
+		"report: fact[total]",
+		"total+1",
+	)
+	if n := g.Node("tax"); n == nil || n.Index != 1 || !slices.Equal(n.Deps, []string{"net", "rate"}) {
+		t.Errorf("Node(tax) = %+v", n)
+	}
+	if n := g.Node("fact"); n == nil || !n.Func || len(n.Deps) != 0 {
+		t.Errorf("Node(fact) = %+v", n)
+	}
+	if got := g.Inputs(); !slices.Equal(got, []string{"costs", "gross", "rate"}) {
+		t.Errorf("Inputs() = %v", got)
+	}
+	if got := g.Dependents("net"); !slices.Equal(got, []string{"total", "tax"}) {
+		t.Errorf("Dependents(net) = %v", got)
+	}
+	if c := g.Cycles(); len(c) != 0 {
+		t.Errorf("Cycles() = %v", c)
+	}
+	order, err := g.Order()
+	if err != nil || !slices.Equal(order, []string{"net", "tax", "total", "fact", "report"}) {
+		t.Errorf("Order() = %v, %v", order, err)
+	}
+	affected, err := g.Affected("rate")
+	if err != nil || !slices.Equal(affected, []string{"tax", "total", "report"}) {
+		t.Errorf("Affected(rate) = %v, %v", affected, err)
+	}
+	affected, err = g.Affected("fact")
+	if err != nil || !slices.Equal(affected, []string{"fact", "report"}) {
+		t.Errorf("Affected(fact) = %v, %v", affected, err)
+	}
+}
+
+func TestCycles(t *testing.T) {
+	g := build(t, "a: b+1", "b: c+1", "c: a+1", "d: d+1", "e: a+d", "f: 2")
+	if got := g.Cycles(); len(got) != 2 || !slices.Equal(got[0], []string{"a", "b", "c"}) || !slices.Equal(got[1], []string{"d"}) {
+		t.Fatalf("Cycles() = %v", got)
+	}
+	order, err := g.Order()
+	var cerr *depgraph.CycleError
+	if !errors.As(err, &cerr) || len(cerr.Cycles) != 2 || !slices.Equal(order, []string{"f"}) {
+		t.Fatalf("Order() = %v, %v", order, err)
+	}
+	if want := "dependency cycle: a -> b -> c -> a; d -> d"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+	if _, err := g.Affected("f"); err != nil {
+		t.Errorf("Affected(f) = %v", err)
+	}
+}
+
+func TestSchedule(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	var tables []*scopes.Table
+	for _, src := range []string{
+		"a: 1",         // 0
+		"b: 2",         // 1
+		"f: {[n] n*c}", // 2
+		"c: a+b",       // 3 uses 0, 1
+		"a+b",          // 4 uses 0, 1
+		"f[2]",         // 5 calls f, so uses c: after 3
+		"d: 5",         // 6
+		"g: f",         // 7 after 2, and after 3 since f uses c
+		"c: 0",         // 8 after 3, and after 5 and 7, which use c
+	} {
+		tree, err := parser.ParseString(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tables = append(tables, scopes.FromTree(tree.Tree, []byte(src)))
+		tree.Close()
+	}
+	got := depgraph.Schedule(tables)
+	want := [][]int{{0, 1, 2, 6}, {3, 4}, {5, 7}, {8}}
+	if !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("Schedule() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,71 @@
+package depgraph
+
+import "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+
+// Schedule groups the statements of a script, analysed by tables in
+// script order, one per statement, into stages for evaluating at once.
+// Statement i belongs to a later stage than every earlier statement it
+// must follow: one assigning a name i uses or assigns, or using a name i
+// assigns.
+// Evaluating the stages in turn, the statements of each in any order,
+// therefore has the effect of evaluating the script in order, even where
+// names are assigned more than once.
+//
+// Calling a function uses the names its body mentions, which is not
+// known from the call alone, so a statement is taken to use, besides the
+// names it mentions, the names mentioned by the latest earlier assignment
+// of each of those, and so on. Stages list statement indices in increasing order.
+func Schedule(tables []*scopes.Table) [][]int {
+	type stmt struct {
+		assigns  string
+		mentions []string
+		uses     map[string]bool
+	}
+	stmts := make([]stmt, len(tables))
+	// last maps each name to the statement assigning it most recently.
+	last := map[string]int{}
+	for i, tab := range tables {
+		s := &stmts[i]
+		for _, sym := range tab.Global.Symbols {
+			if sym.Kind == scopes.Global {
+				s.assigns = sym.Name
+			}
+			if len(sym.Refs) > 0 {
+				s.mentions = append(s.mentions, sym.Name)
+			}
+		}
+		s.uses = map[string]bool{}
+		queue := append([]string(nil), s.mentions...)
+		for len(queue) > 0 {
+			name := queue[0]
+			queue = queue[1:]
+			if s.uses[name] {
+				continue
+			}
+			s.uses[name] = true
+			if j, ok := last[name]; ok {
+				queue = append(queue, stmts[j].mentions...)
+			}
+		}
+		if s.assigns != "" {
+			last[s.assigns] = i
+		}
+	}
+	stage := make([]int, len(tables))
+	var out [][]int
+	for i, s := range stmts {
+		for j, t := range stmts[:i] {
+			if stage[j] < stage[i] {
+				continue
+			}
+			if t.assigns != "" && (s.uses[t.assigns] || s.assigns == t.assigns) || s.assigns != "" && t.uses[s.assigns] {
+				stage[i] = stage[j] + 1
+			}
+		}
+		if stage[i] == len(out) {
+			out = append(out, nil)
+		}
+		out[stage[i]] = append(out[stage[i]], i)
+	}
+	return out
+}
@@ -0,0 +1,342 @@
+package tree_sitter_wabznasm
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// Severity ranks a diagnostic. The values match the Language Server
+// Protocol's DiagnosticSeverity.
+type Severity int
+
+const (
+	SeverityError Severity = iota + 1
+	SeverityWarning
+	SeverityInformation
+	SeverityHint
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	case SeverityInformation:
+		return "info"
+	case SeverityHint:
+		return "hint"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic codes reported by Diagnostics.
+const (
+	// CodeSyntax marks an ERROR node: input the parser had to skip.
+	CodeSyntax = "syntax"
+	// CodeMissing marks a MISSING node: a token the parser inserted to
+	// recover.
+	CodeMissing = "missing"
+)
+
+// Diagnostic is a problem found in a parse tree.
+type Diagnostic struct {
+	Range    tree_sitter.Range
+	Severity Severity
+	Code     string
+	Message  string
+	// Expected lists the tokens the parser would have accepted where the
+	// problem starts, when that can be determined. Named tokens appear bare;
+	// literal tokens are quoted.
+	Expected []string
+	// Fixes are corrections that would resolve the problem, if any: a
+	// missing bracket, for instance, can be inserted.
+	Fixes []Fix
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", d.Range.StartPoint.Row+1, d.Range.StartPoint.Column+1, d.Severity, d.Message)
+}
+
+// Diagnostics walks tree and reports each ERROR and MISSING node, in source
+// order. It returns nil for a tree without errors.
+func Diagnostics(tree *tree_sitter.Tree, source []byte) []Diagnostic {
+	root := tree.RootNode()
+	if !root.HasError() {
+		return nil
+	}
+	c := diagnoser{lang: tree.Language(), source: source}
+	c.walk(root)
+	return c.out
+}
+
+type diagnoser struct {
+	lang   *tree_sitter.Language
+	source []byte
+	out    []Diagnostic
+}
+
+func (c *diagnoser) walk(n *tree_sitter.Node) {
+	switch {
+	case n.IsMissing():
+		what := displayKind(n.Kind(), n.IsNamed())
+		d := Diagnostic{
+			Range:    n.Range(),
+			Severity: SeverityError,
+			Code:     CodeMissing,
+			Message:  "missing " + what,
+			Expected: []string{what},
+		}
+		if !n.IsNamed() {
+			// A missing literal token is its own text; a missing
+			// identifier or number could be anything.
+			d.Fixes = []Fix{{Title: "insert " + what, Edits: []TextEdit{{Range: n.Range(), NewText: n.Kind()}}}}
+		}
+		c.out = append(c.out, d)
+		return
+	case n.IsError():
+		c.out = append(c.out, c.errorDiagnostic(n))
+		return
+	case !n.HasError():
+		return
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		c.walk(n.Child(i))
+	}
+}
+
+// errorDiagnostic describes an ERROR node.
+func (c *diagnoser) errorDiagnostic(n *tree_sitter.Node) Diagnostic {
+	d := Diagnostic{Range: n.Range(), Severity: SeverityError, Code: CodeSyntax}
+	var at *tree_sitter.Node
+	at, d.Expected = c.errorExpected(n)
+	switch {
+	case at != nil:
+		d.Range = at.Range()
+		d.Message = "unexpected " + c.describe(at)
+	case n.EndByte() >= uint(len(c.source)):
+		d.Message = "unexpected end of input"
+	default:
+		d.Message = "syntax error"
+	}
+	if len(d.Expected) > 0 {
+		d.Message += "; expected " + joinOr(summarize(d.Expected))
+	}
+	return d
+}
+
+// errorExpected finds the token an ERROR node went wrong at, or nil if it
+// reaches the end of the input, and what the parser expected there. Each
+// leaf records the parse state in which it was read; the first leaf that
+// state could not accept is the unexpected token, and the state's
+// lookahead set is what was expected. If every leaf was acceptable the
+// parser gave up on the node as a whole: it either ran out of input or
+// could not continue at the node's first token.
+func (c *diagnoser) errorExpected(n *tree_sitter.Node) (*tree_sitter.Node, []string) {
+	if leaf := c.unexpectedLeaf(n); leaf != nil {
+		return leaf, c.expected(leaf.ParseState())
+	}
+	if n.EndByte() >= uint(len(c.source)) {
+		return nil, c.expectedAfter(n, n.EndByte())
+	}
+	first := firstLeaf(n)
+	if first == nil {
+		return nil, nil
+	}
+	expected := c.expectedAt(n)
+	if slices.Contains(expected, displayKind(first.Kind(), first.IsNamed())) {
+		// The parser would have taken the token here but not what
+		// follows it; the replay cannot tell what went wrong.
+		expected = nil
+	}
+	return first, expected
+}
+
+func (c *diagnoser) unexpectedLeaf(n *tree_sitter.Node) *tree_sitter.Node {
+	if n.ChildCount() == 0 {
+		if n.IsExtra() || n.IsMissing() || c.accepts(n.ParseState(), n.GrammarId()) {
+			return nil
+		}
+		return n
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		if leaf := c.unexpectedLeaf(n.Child(i)); leaf != nil {
+			return leaf
+		}
+	}
+	return nil
+}
+
+// firstLeaf returns the first token of n, or nil if n has none.
+func firstLeaf(n *tree_sitter.Node) *tree_sitter.Node {
+	if n.ChildCount() == 0 {
+		if n.IsExtra() || n.StartByte() == n.EndByte() {
+			return nil
+		}
+		return n
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		if leaf := firstLeaf(n.Child(i)); leaf != nil {
+			return leaf
+		}
+	}
+	return nil
+}
+
+// ExpectedAt lists the tokens the parser would have accepted where n
+// starts, in the form of Diagnostic.Expected, or nil if that cannot be
+// determined. For an ERROR node it lists what the input should have
+// continued with, as its diagnostic does.
+func ExpectedAt(tree *tree_sitter.Tree, source []byte, n *tree_sitter.Node) []string {
+	c := diagnoser{lang: tree.Language(), source: source}
+	if n.IsError() {
+		_, expected := c.errorExpected(n)
+		return expected
+	}
+	return c.expectedAt(n)
+}
+
+// expectedAt lists the terminals valid where n starts: those of the state
+// a token was read in, or else of the state the parser reached at n's
+// start within its parent.
+func (c *diagnoser) expectedAt(n *tree_sitter.Node) []string {
+	if n.ChildCount() == 0 && !n.IsError() {
+		return c.expected(n.ParseState())
+	}
+	if p := n.Parent(); p != nil {
+		return c.expectedAfter(p, n.StartByte())
+	}
+	return nil
+}
+
+// expectedAfter lists the terminals valid after the children of n that
+// end by offset. It replays the parser over them: the first child records
+// the state it was read in, and each child, token or rule, leads to the
+// state of the next.
+func (c *diagnoser) expectedAfter(n *tree_sitter.Node, offset uint) []string {
+	var state uint16
+	for i := uint(0); i < n.ChildCount(); i++ {
+		child := n.Child(i)
+		if child.IsExtra() {
+			continue
+		}
+		if child.EndByte() > offset || child.IsError() {
+			break
+		}
+		if state == 0 {
+			if state = child.ParseState(); state == ^uint16(0) {
+				return nil
+			}
+		}
+		if state = c.lang.NextState(state, child.GrammarId()); state == 0 {
+			return nil
+		}
+	}
+	return c.expected(state)
+}
+
+func (c *diagnoser) accepts(state, symbol uint16) bool {
+	if state == 0 || state == ^uint16(0) {
+		// No recorded state: nothing to compare against.
+		return true
+	}
+	it := c.lang.LookaheadIterator(state)
+	if it == nil {
+		return true
+	}
+	defer it.Close()
+	for _, s := range it.Iter() {
+		if s == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// expected lists the terminals valid in state. Tree-sitter numbers every
+// terminal before the first nonterminal, so symbols from source_file up are
+// rules rather than tokens. Comments are accepted everywhere and omitted.
+func (c *diagnoser) expected(state uint16) []string {
+	if state == 0 || state == ^uint16(0) {
+		return nil
+	}
+	it := c.lang.LookaheadIterator(state)
+	if it == nil {
+		return nil
+	}
+	defer it.Close()
+	firstRule := c.lang.IdForNodeKind("source_file", true)
+	var out []string
+	for _, s := range it.Iter() {
+		if s >= firstRule || c.lang.NodeKindForId(s) == "comment" {
+			continue
+		}
+		if s == 0 {
+			out = append(out, "end of input")
+			continue
+		}
+		if !c.lang.NodeKindIsVisible(s) {
+			continue
+		}
+		out = append(out, displayKind(c.lang.NodeKindForId(s), c.lang.NodeKindIsNamed(s)))
+	}
+	sort.Strings(out)
+	return out
+}
+
+func (c *diagnoser) describe(leaf *tree_sitter.Node) string {
+	if !leaf.IsNamed() {
+		return fmt.Sprintf("%q", leaf.Kind())
+	}
+	return fmt.Sprintf("%s %q", leaf.Kind(), leaf.Utf8Text(c.source))
+}
+
+func displayKind(kind string, named bool) string {
+	if named {
+		return kind
+	}
+	return fmt.Sprintf("%q", kind)
+}
+
+// operators are the operator tokens, which summarize collapses.
+var operators = map[string]bool{`"+"`: true, `"-"`: true, `"*"`: true, `"/"`: true, `"%"`: true, `"^"`: true, `"!"`: true}
+
+// summarize shortens a list of expected tokens for a message: when
+// several operators are expected they are named together as "operator".
+func summarize(expected []string) []string {
+	n := 0
+	for _, e := range expected {
+		if operators[e] {
+			n++
+		}
+	}
+	if n < 2 {
+		return expected
+	}
+	// Keep the word after the quoted tokens, which sort first.
+	var out []string
+	for _, e := range expected {
+		if n > 0 && !strings.HasPrefix(e, `"`) {
+			out = append(out, "operator")
+			n = 0
+		}
+		if !operators[e] {
+			out = append(out, e)
+		}
+	}
+	if n > 0 {
+		out = append(out, "operator")
+	}
+	return out
+}
+
+func joinOr(items []string) string {
+	if len(items) == 1 {
+		return items[0]
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " or " + items[len(items)-1]
+}
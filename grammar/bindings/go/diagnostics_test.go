@@ -0,0 +1,103 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"reflect"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+func TestDiagnostics(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+
+	tests := []struct {
+		src      string
+		code     string
+		message  string
+		start    uint
+		expected []string
+	}{
+		{"f: {x+1", tree_sitter_wabznasm.CodeMissing, `missing "}"`, 7, []string{`"}"`}},
+		{"1+*2", tree_sitter_wabznasm.CodeSyntax, `unexpected "*"; expected "(", "-", identifier or number`, 2,
+			[]string{`"("`, `"-"`, "identifier", "number"}},
+		{"a::1", tree_sitter_wabznasm.CodeSyntax, `unexpected ":"; expected "(", "-", "{", identifier or number`, 2,
+			[]string{`"("`, `"-"`, `"{"`, "identifier", "number"}},
+		{"f[1;", tree_sitter_wabznasm.CodeSyntax, `unexpected end of input; expected "(", "-", identifier or number`, 0,
+			[]string{`"("`, `"-"`, "identifier", "number"}},
+		{"x: 1 2 3", tree_sitter_wabznasm.CodeSyntax, `unexpected number "2"; expected ")", ";", "]", "}", operator or end of input`, 5,
+			[]string{`"!"`, `"%"`, `")"`, `"*"`, `"+"`, `"-"`, `"/"`, `";"`, `"]"`, `"^"`, `"}"`, "end of input"}},
+		{"f: {[a b] a}", tree_sitter_wabznasm.CodeSyntax, `unexpected identifier "b"; expected ";" or "]"`, 7,
+			[]string{`";"`, `"]"`}},
+	}
+	for _, tt := range tests {
+		tree, err := parser.ParseString(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		diags := tree_sitter_wabznasm.Diagnostics(tree.Tree, []byte(tt.src))
+		tree.Close()
+		if len(diags) != 1 {
+			t.Errorf("%q: got %d diagnostics %v, want 1", tt.src, len(diags), diags)
+			continue
+		}
+		d := diags[0]
+		if d.Code != tt.code || d.Message != tt.message || d.Range.StartByte != tt.start {
+			t.Errorf("%q: got %s %q at %d, want %s %q at %d", tt.src, d.Code, d.Message, d.Range.StartByte, tt.code, tt.message, tt.start)
+		}
+		if d.Severity != tree_sitter_wabznasm.SeverityError {
+			t.Errorf("%q: severity = %s", tt.src, d.Severity)
+		}
+		if !reflect.DeepEqual(d.Expected, tt.expected) {
+			t.Errorf("%q: expected = %q, want %q", tt.src, d.Expected, tt.expected)
+		}
+	}
+}
+
+func TestExpectedAt(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString("x:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	var errNode *tree_sitter.Node
+	root := tree.RootNode()
+	for i := uint(0); i < root.ChildCount(); i++ {
+		if root.Child(i).IsError() {
+			errNode = root.Child(i)
+		}
+	}
+	if errNode == nil {
+		t.Fatalf("no ERROR node in %s", root.ToSexp())
+	}
+	got := tree_sitter_wabznasm.ExpectedAt(tree.Tree, []byte("x:"), errNode)
+	want := []string{`"("`, `"-"`, `"{"`, "identifier", "number"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExpectedAt = %q, want %q", got, want)
+	}
+}
+
+func TestDiagnosticsClean(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString("add: {[x;y] x+y} \\ ok")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	if diags := tree_sitter_wabznasm.Diagnostics(tree.Tree, []byte("add: {[x;y] x+y} \\ ok")); diags != nil {
+		t.Errorf("Diagnostics = %v, want nil", diags)
+	}
+}
@@ -0,0 +1,166 @@
+// Package diff compares texts line by line and prints the differences as
+// unified diffs, the format of diff -u and git diff.
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context is the number of unchanged lines shown around each change.
+const Context = 3
+
+// Op is the kind of an Edit.
+type Op byte
+
+const (
+	Equal  Op = ' '
+	Delete Op = '-'
+	Insert Op = '+'
+)
+
+// Edit is a line kept, deleted from the old text, or inserted from the new.
+// Line includes its newline, unless it is the last line of a text that
+// does not end in one.
+type Edit struct {
+	Op   Op
+	Line string
+}
+
+// splitLines splits text after each newline.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines returns a shortest edit script turning the lines of a into those
+// of b, computed with Myers' algorithm.
+func Lines(a, b string) []Edit {
+	return edits(splitLines(a), splitLines(b))
+}
+
+func edits(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	offset := n + m + 1
+	v := make([]int, 2*offset+1)
+	// trace[d] holds the furthest x reached on each diagonal k = x-y
+	// before step d, which is what backtracking needs.
+	var trace [][]int
+search:
+	for d := 0; d <= n+m; d++ {
+		trace = append(trace, append([]int(nil), v...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				break search
+			}
+		}
+	}
+
+	var out []Edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || k != d && v[offset+k-1] < v[offset+k+1] {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x, y = x-1, y-1
+			out = append(out, Edit{Equal, a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				out = append(out, Edit{Insert, b[prevY]})
+			} else {
+				out = append(out, Edit{Delete, a[prevX]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+	return out
+}
+
+// Unified returns the unified diff from a, named aName, to b, named bName,
+// or "" if they are equal.
+func Unified(aName, bName, a, b string) string {
+	es := Lines(a, b)
+	// Line numbers in a and b before each edit.
+	ai, bi := make([]int, len(es)+1), make([]int, len(es)+1)
+	for i, e := range es {
+		ai[i+1], bi[i+1] = ai[i], bi[i]
+		if e.Op != Insert {
+			ai[i+1]++
+		}
+		if e.Op != Delete {
+			bi[i+1]++
+		}
+	}
+
+	var out strings.Builder
+	for i := 0; i < len(es); {
+		if es[i].Op == Equal {
+			i++
+			continue
+		}
+		// A hunk runs from its first change to the last one separated
+		// from the previous by at most twice the context.
+		last := i
+		for j := i + 1; j < len(es); j++ {
+			if es[j].Op != Equal {
+				if j-last-1 > 2*Context {
+					break
+				}
+				last = j
+			}
+		}
+		start, end := max(i-Context, 0), min(last+1+Context, len(es))
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", span(ai[start], ai[end]-ai[start]), span(bi[start], bi[end]-bi[start]))
+		for _, e := range es[start:end] {
+			out.WriteByte(byte(e.Op))
+			out.WriteString(e.Line)
+			if !strings.HasSuffix(e.Line, "\n") {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+	return out.String()
+}
+
+// span formats the range of a hunk header: the first line and the count,
+// which is left out when it is one. An empty range is named by the line
+// before it.
+func span(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprint(start + 1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
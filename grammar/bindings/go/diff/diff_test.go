@@ -0,0 +1,87 @@
+package diff_test
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/diff"
+)
+
+func TestUnified(t *testing.T) {
+	for _, tt := range []struct{ name, a, b, want string }{
+		{"equal", "x\ny\n", "x\ny\n", ""},
+		{"change", "a\nb\nc\n", "a\nB\nc\n", "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n"},
+		{"insert into empty", "", "x\n", "--- old\n+++ new\n@@ -0,0 +1 @@\n+x\n"},
+		{"no newline", "x", "x\n", "--- old\n+++ new\n@@ -1 +1 @@\n-x\n\\ No newline at end of file\n+x\n"},
+		{
+			"two hunks",
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"one\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\ntwelve\n",
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+one\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+twelve\n",
+		},
+		{
+			"merged hunk",
+			"1\n2\n3\n4\n5\n6\n7\n",
+			"one\n2\n3\n4\n5\n6\nseven\n",
+			"--- old\n+++ new\n@@ -1,7 +1,7 @@\n-1\n+one\n 2\n 3\n 4\n 5\n 6\n-7\n+seven\n",
+		},
+	} {
+		if got := diff.Unified("old", "new", tt.a, tt.b); got != tt.want {
+			t.Errorf("%s:\n%s\nwant:\n%s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// TestLines checks on random texts that the edit script is minimal for
+// the longest common subsequence and turns a into b.
+func TestLines(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	text := func() string {
+		var b strings.Builder
+		for range r.Intn(12) {
+			b.WriteString(string(rune('a'+r.Intn(4))) + "\n")
+		}
+		return b.String()
+	}
+	for range 500 {
+		a, b := text(), text()
+		var gotA, gotB strings.Builder
+		changes := 0
+		for _, e := range diff.Lines(a, b) {
+			if e.Op != diff.Insert {
+				gotA.WriteString(e.Line)
+			}
+			if e.Op != diff.Delete {
+				gotB.WriteString(e.Line)
+			}
+			if e.Op != diff.Equal {
+				changes++
+			}
+		}
+		if gotA.String() != a || gotB.String() != b {
+			t.Fatalf("script for %q -> %q rebuilds %q -> %q", a, b, gotA.String(), gotB.String())
+		}
+		al, bl := strings.Count(a, "\n"), strings.Count(b, "\n")
+		if want := al + bl - 2*lcs(strings.Split(a, "\n"), strings.Split(b, "\n")) + 2; changes != want {
+			t.Fatalf("%q -> %q: %d changes, want %d", a, b, changes, want)
+		}
+	}
+}
+
+func lcs(a, b []string) int {
+	dp := make([][]int, len(a)+1)
+	for i := range dp {
+		dp[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				dp[i][j] = dp[i+1][j+1] + 1
+			} else {
+				dp[i][j] = max(dp[i+1][j], dp[i][j+1])
+			}
+		}
+	}
+	return dp[0][0]
+}
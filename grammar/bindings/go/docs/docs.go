@@ -0,0 +1,127 @@
+// Package docs associates doc comments with the definitions they describe.
+//
+// A doc comment is a run of backslash comments on consecutive lines ending
+// on the line just above an assignment:
+//
+//	\ add returns the sum of its arguments.
+//	\
+//	\ Both must be numbers.
+//	add: {[x;y] x+y}
+//
+// A blank line between the comments and the assignment detaches them. The
+// leading backslash and one following space are stripped from each line.
+//
+// Site renders the entries of a whole project as cross-linked Markdown or
+// HTML reference pages.
+package docs
+
+import (
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+// Kind classifies a documented definition.
+type Kind int
+
+const (
+	Variable Kind = iota
+	Function
+)
+
+func (k Kind) String() string {
+	if k == Function {
+		return "function"
+	}
+	return "variable"
+}
+
+// DocEntry is the documentation of one definition.
+type DocEntry struct {
+	Symbol string
+	Kind   Kind
+	// Signature lists the parameters of a function, explicit or implicit.
+	Signature []string
+	// Text is the doc comment with comment markers removed, or empty.
+	Text string
+	// Range covers the definition; Doc covers its comment, if any.
+	Range tree_sitter.Range
+	Doc   *tree_sitter.Range
+}
+
+// Extract returns an entry for every assignment in the tree, documented or
+// not, in source order.
+func Extract(tree *tree_sitter.Tree, source []byte) []DocEntry {
+	return FromFile(ast.FromTree(tree, source))
+}
+
+// FromFile is Extract for already converted files, the statements of a
+// source in order.
+func FromFile(files ...*ast.File) []DocEntry {
+	var out []DocEntry
+	for _, f := range files {
+		a, ok := f.Stmt.(*ast.Assignment)
+		if !ok || a.Name == nil {
+			continue
+		}
+		e := DocEntry{Symbol: a.Name.Name, Range: toRange(a.Span)}
+		if fn, ok := a.Value.(*ast.FunctionDef); ok {
+			e.Kind = Function
+			e.Signature = fn.Signature()
+		}
+		if block := leading(f.Comments, a.Pos()); len(block) > 0 {
+			lines := make([]string, len(block))
+			for i, c := range block {
+				lines[i] = strip(c.Text)
+			}
+			e.Text = strings.Join(lines, "\n")
+			doc := toRange(ast.Span{Start: block[0].Start, End: block[len(block)-1].End})
+			e.Doc = &doc
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// Lookup returns the entry for name, or nil.
+func Lookup(entries []DocEntry, name string) *DocEntry {
+	for i := range entries {
+		if entries[i].Symbol == name {
+			return &entries[i]
+		}
+	}
+	return nil
+}
+
+// leading returns the comments forming the doc block of a node at pos.
+// Code precedes no comment before the statement, so each such comment is
+// alone on its line.
+func leading(comments []*ast.Comment, pos ast.Pos) []*ast.Comment {
+	end := len(comments)
+	for end > 0 && comments[end-1].Start.Offset >= pos.Offset {
+		end--
+	}
+	row := pos.Row
+	start := end
+	for start > 0 && comments[start-1].Start.Row+1 == row {
+		start--
+		row = comments[start].Start.Row
+	}
+	return comments[start:end]
+}
+
+func strip(text string) string {
+	text = strings.TrimPrefix(text, `\`)
+	text = strings.TrimPrefix(text, " ")
+	return strings.TrimRight(text, " \t\r")
+}
+
+func toRange(s ast.Span) tree_sitter.Range {
+	return tree_sitter.Range{
+		StartByte:  s.Start.Offset,
+		EndByte:    s.End.Offset,
+		StartPoint: tree_sitter.Point{Row: s.Start.Row, Column: s.Start.Column},
+		EndPoint:   tree_sitter.Point{Row: s.End.Row, Column: s.End.Column},
+	}
+}
@@ -0,0 +1,129 @@
+package docs_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/docs"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func extract(t *testing.T, src string) []docs.DocEntry {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(parser.Close)
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return docs.Extract(tree.Tree, []byte(src))
+}
+
+func TestExtract(t *testing.T) {
+	src := "\\ file header\n\n\\ add returns the sum.\n\\\n\\   Both are numbers.  \nadd: {[x;y] x+y} \\ trailing\n"
+	entries := extract(t, src)
+	if len(entries) != 1 {
+		t.Fatalf("entries = %+v", entries)
+	}
+	e := entries[0]
+	if e.Symbol != "add" || e.Kind != docs.Function || !slices.Equal(e.Signature, []string{"x", "y"}) {
+		t.Errorf("entry = %+v", e)
+	}
+	if want := "add returns the sum.\n\n  Both are numbers."; e.Text != want {
+		t.Errorf("Text = %q, want %q", e.Text, want)
+	}
+	if e.Doc == nil || e.Doc.StartPoint.Row != 2 || e.Doc.EndPoint.Row != 4 {
+		t.Errorf("Doc = %+v", e.Doc)
+	}
+	if e.Range.StartPoint.Row != 5 || e.Range.StartByte != uint(len(src)-len("add: {[x;y] x+y} \\ trailing\n")) {
+		t.Errorf("Range = %+v", e.Range)
+	}
+	if docs.Lookup(entries, "add") != &entries[0] || docs.Lookup(entries, "x") != nil {
+		t.Error("Lookup")
+	}
+}
+
+func TestExtractUndocumented(t *testing.T) {
+	tests := []struct {
+		src  string
+		kind docs.Kind
+	}{
+		{"\\ detached\n\nn: 42", docs.Variable},
+		{"sq: {x*x}", docs.Function},
+	}
+	for _, tt := range tests {
+		entries := extract(t, tt.src)
+		if len(entries) != 1 || entries[0].Text != "" || entries[0].Doc != nil || entries[0].Kind != tt.kind {
+			t.Errorf("Extract(%q) = %+v", tt.src, entries)
+		}
+	}
+	if entries := extract(t, "\\ not a definition\n1+2"); entries != nil {
+		t.Errorf("Extract of an expression = %+v", entries)
+	}
+}
+
+func TestSite(t *testing.T) {
+	root := t.TempDir()
+	for path, src := range map[string]string{
+		"lib/math.wz":  "\\ add returns the sum.\nadd: {[x;y] x+y}",
+		"app.wabznasm": "total: add[1;2]",
+	} {
+		full := filepath.Join(root, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p, err := project.Open(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := docs.NewSite(p)
+
+	out := t.TempDir()
+	if err := site.Write(out, docs.Markdown); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string][]string{
+		"index.md":    {"- [app.wabznasm](app.md) · [total](app.md#total)", "[add](lib/math.md#add)"},
+		"lib/math.md": {"## add", "add[x;y]", "add returns the sum.", "Used by: [app.wabznasm](../app.md)"},
+		"app.md":      {"## total", "Uses: [add](lib/math.md#add)"},
+	}
+	for page, wants := range tests {
+		data, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(page)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range wants {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s lacks %q:\n%s", page, want, data)
+			}
+		}
+	}
+
+	if err := site.Write(out, docs.HTML); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(out, "app.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `<a href="lib/math.html#add">add</a>`; !strings.Contains(string(data), want) {
+		t.Errorf("app.html lacks %q:\n%s", want, data)
+	}
+}
@@ -0,0 +1,249 @@
+package docs
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// Format selects the output of Site.Write.
+type Format int
+
+const (
+	Markdown Format = iota
+	HTML
+)
+
+// Ext returns the file extension of pages in the format.
+func (f Format) Ext() string {
+	if f == HTML {
+		return ".html"
+	}
+	return ".md"
+}
+
+// Site is the reference documentation of a project: one page per source
+// file and an index page listing every file and definition.
+type Site struct {
+	Pages []*Page
+}
+
+// Page documents one source file.
+type Page struct {
+	// Source is the file's path relative to the project root, with forward
+	// slashes.
+	Source  string
+	Entries []*Entry
+}
+
+// Entry is a documented definition with its cross-references.
+type Entry struct {
+	DocEntry
+	// Uses are the globals the definition refers to that are defined in
+	// the project; UsedBy are the pages referring to it.
+	Uses   []Link
+	UsedBy []Link
+}
+
+// Link points at a page, or at a definition on it when Symbol is set.
+type Link struct {
+	Page   *Page
+	Symbol string
+}
+
+// NewSite builds the documentation of the files indexed by p.
+func NewSite(p *project.Project) *Site {
+	s := &Site{}
+	pages := map[string]*Page{}
+	for _, path := range p.Files() {
+		rel, err := filepath.Rel(p.Root, path)
+		if err != nil {
+			rel = path
+		}
+		page := &Page{Source: filepath.ToSlash(rel)}
+		for _, e := range FromFile(p.File(path).Symbols.Files...) {
+			page.Entries = append(page.Entries, &Entry{DocEntry: e})
+		}
+		pages[path] = page
+		s.Pages = append(s.Pages, page)
+	}
+	for _, path := range p.Files() {
+		page, tab := pages[path], p.File(path).Symbols
+		for _, e := range page.Entries {
+			for _, ref := range p.References(e.Symbol) {
+				if ref.File != path && !hasPage(e.UsedBy, pages[ref.File]) {
+					e.UsedBy = append(e.UsedBy, Link{Page: pages[ref.File]})
+				}
+			}
+			e.Uses = uses(p, pages, tab)
+		}
+	}
+	return s
+}
+
+// uses links the names a file refers to but does not define.
+func uses(p *project.Project, pages map[string]*Page, tab *scopes.Table) []Link {
+	var out []Link
+	for _, sym := range tab.Globals() {
+		if sym.Kind != scopes.Free {
+			continue
+		}
+		for _, def := range p.Definitions(sym.Name) {
+			out = append(out, Link{Page: pages[def.File], Symbol: sym.Name})
+		}
+	}
+	return out
+}
+
+func hasPage(links []Link, page *Page) bool {
+	for _, l := range links {
+		if l.Page == page {
+			return true
+		}
+	}
+	return false
+}
+
+// Path returns the page's output path, relative to the site root.
+func (p *Page) Path(f Format) string {
+	return strings.TrimSuffix(p.Source, filepath.Ext(p.Source)) + f.Ext()
+}
+
+// Write renders the site into dir, creating it as needed. The index page
+// is index.md or index.html.
+func (s *Site) Write(dir string, f Format) error {
+	for _, page := range s.Pages {
+		out := filepath.Join(dir, filepath.FromSlash(page.Path(f)))
+		if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(out, s.render(page, f), 0o644); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, "index"+f.Ext()), s.render(nil, f), 0o644)
+}
+
+// render renders page, or the index page when page is nil.
+func (s *Site) render(page *Page, f Format) []byte {
+	from := "index" + f.Ext()
+	if page != nil {
+		from = page.Path(f)
+	}
+	href := func(to *Page, symbol string) string {
+		rel, err := filepath.Rel(filepath.Dir(filepath.FromSlash(from)), filepath.FromSlash(to.Path(f)))
+		if err != nil {
+			rel = to.Path(f)
+		}
+		if symbol != "" {
+			return filepath.ToSlash(rel) + "#" + symbol
+		}
+		return filepath.ToSlash(rel)
+	}
+	var b bytes.Buffer
+	if f == HTML {
+		data := struct {
+			Site *Site
+			Page *Page
+		}{s, page}
+		t := template.Must(htmlPage.Clone()).Funcs(template.FuncMap{"href": href})
+		if err := t.Execute(&b, data); err != nil {
+			// The template and its data are fixed, so this cannot happen.
+			panic(err)
+		}
+		return b.Bytes()
+	}
+	if page == nil {
+		b.WriteString("# Index\n")
+		for _, p := range s.Pages {
+			fmt.Fprintf(&b, "\n- [%s](%s)", p.Source, href(p, ""))
+			for _, e := range p.Entries {
+				fmt.Fprintf(&b, " · [%s](%s)", e.Symbol, href(p, e.Symbol))
+			}
+		}
+		b.WriteString("\n")
+		return b.Bytes()
+	}
+	fmt.Fprintf(&b, "# %s\n", page.Source)
+	for _, e := range page.Entries {
+		fmt.Fprintf(&b, "\n<a id=\"%s\"></a>\n## %s\n\n```wabznasm\n%s\n```\n", e.Symbol, e.Symbol, e.Synopsis())
+		if e.Text != "" {
+			fmt.Fprintf(&b, "\n%s\n", e.Text)
+		}
+		writeLinks(&b, "Uses", e.Uses, href)
+		writeLinks(&b, "Used by", e.UsedBy, href)
+	}
+	return b.Bytes()
+}
+
+func writeLinks(b *bytes.Buffer, label string, links []Link, href func(*Page, string) string) {
+	if len(links) == 0 {
+		return
+	}
+	parts := make([]string, len(links))
+	for i, l := range links {
+		text := l.Page.Source
+		if l.Symbol != "" {
+			text = l.Symbol
+		}
+		parts[i] = fmt.Sprintf("[%s](%s)", text, href(l.Page, l.Symbol))
+	}
+	sort.Strings(parts)
+	fmt.Fprintf(b, "\n%s: %s\n", label, strings.Join(parts, ", "))
+}
+
+// Synopsis returns the definition as it is called: name[x;y] for a function
+// and the bare name for a variable.
+func (e *DocEntry) Synopsis() string {
+	if e.Kind == Function {
+		return e.Symbol + "[" + strings.Join(e.Signature, ";") + "]"
+	}
+	return e.Symbol
+}
+
+var htmlPage = template.Must(template.New("page").Funcs(template.FuncMap{"href": func(*Page, string) string { return "" }}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{if .Page}}{{.Page.Source}}{{else}}Index{{end}}</title>
+</head>
+<body>
+{{- with .Page}}
+<h1>{{.Source}}</h1>
+{{- range .Entries}}
+<section id="{{.Symbol}}">
+<h2>{{.Symbol}}</h2>
+<pre><code>{{.Synopsis}}</code></pre>
+{{- if .Text}}
+<pre class="doc">{{.Text}}</pre>
+{{- end}}
+{{- if .Uses}}
+<p>Uses: {{range $i, $l := .Uses}}{{if $i}}, {{end}}<a href="{{href $l.Page $l.Symbol}}">{{$l.Symbol}}</a>{{end}}</p>
+{{- end}}
+{{- if .UsedBy}}
+<p>Used by: {{range $i, $l := .UsedBy}}{{if $i}}, {{end}}<a href="{{href $l.Page ""}}">{{$l.Page.Source}}</a>{{end}}</p>
+{{- end}}
+</section>
+{{- end}}
+{{- else}}
+<h1>Index</h1>
+<ul>
+{{- range .Site.Pages}}
+<li><a href="{{href . ""}}">{{.Source}}</a>
+{{- $p := .}}{{range .Entries}} · <a href="{{href $p .Symbol}}">{{.Symbol}}</a>{{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+</body>
+</html>
+`))
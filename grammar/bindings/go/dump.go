@@ -0,0 +1,111 @@
+package tree_sitter_wabznasm
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// DumpSExpr renders the full subtree at node as an indented S-expression,
+// one node per line, in the layout of `tree-sitter parse` extended with byte
+// ranges and anonymous tokens:
+//
+//	(assignment [0, 0] - [0, 5] 0..5
+//	  name: (identifier [0, 0] - [0, 1] 0..1)
+//	  operator: ":" [0, 1] - [0, 2] 1..2
+//	  ...)
+//
+// Unlike Node.ToSexp the output is stable enough for golden files.
+func DumpSExpr(node *tree_sitter.Node) string {
+	var b strings.Builder
+	dumpSExpr(&b, node, "", 0)
+	b.WriteByte('\n')
+	return b.String()
+}
+
+func dumpSExpr(b *strings.Builder, n *tree_sitter.Node, field string, depth int) {
+	b.WriteString(strings.Repeat("  ", depth))
+	if field != "" {
+		b.WriteString(field + ": ")
+	}
+	start, end := n.StartPosition(), n.EndPosition()
+	where := fmt.Sprintf("[%d, %d] - [%d, %d] %d..%d", start.Row, start.Column, end.Row, end.Column, n.StartByte(), n.EndByte())
+	if !n.IsNamed() {
+		kind := fmt.Sprintf("%q", n.Kind())
+		if n.IsMissing() {
+			kind = "(MISSING " + kind + ")"
+		}
+		b.WriteString(kind + " " + where)
+		return
+	}
+	b.WriteByte('(')
+	if n.IsMissing() {
+		b.WriteString("MISSING ")
+	}
+	b.WriteString(n.Kind() + " " + where)
+	for i := uint(0); i < n.ChildCount(); i++ {
+		b.WriteByte('\n')
+		dumpSExpr(b, n.Child(i), n.FieldNameForChild(uint32(i)), depth+1)
+	}
+	b.WriteByte(')')
+}
+
+// NodeJSON is the JSON form of a node written by DumpJSON.
+type NodeJSON struct {
+	Kind      string     `json:"kind"`
+	Field     string     `json:"field,omitempty"`
+	Named     bool       `json:"named"`
+	Error     bool       `json:"error,omitempty"`
+	Missing   bool       `json:"missing,omitempty"`
+	Extra     bool       `json:"extra,omitempty"`
+	StartByte uint       `json:"start_byte"`
+	EndByte   uint       `json:"end_byte"`
+	Start     PointJSON  `json:"start"`
+	End       PointJSON  `json:"end"`
+	Text      *string    `json:"text,omitempty"`
+	Children  []NodeJSON `json:"children,omitempty"`
+}
+
+// PointJSON is a zero-based row and byte column.
+type PointJSON struct {
+	Row    uint `json:"row"`
+	Column uint `json:"column"`
+}
+
+// DumpJSON renders the full subtree at node as indented JSON in the shape
+// of NodeJSON. When source is non-nil each leaf carries its source text;
+// pass nil to omit it.
+func DumpJSON(node *tree_sitter.Node, source []byte) ([]byte, error) {
+	return json.MarshalIndent(ToJSON(node, source), "", "  ")
+}
+
+// ToJSON converts the subtree at node to NodeJSON values.
+func ToJSON(node *tree_sitter.Node, source []byte) NodeJSON {
+	return toJSON(node, "", source)
+}
+
+func toJSON(n *tree_sitter.Node, field string, source []byte) NodeJSON {
+	start, end := n.StartPosition(), n.EndPosition()
+	out := NodeJSON{
+		Kind:      n.Kind(),
+		Field:     field,
+		Named:     n.IsNamed(),
+		Error:     n.IsError(),
+		Missing:   n.IsMissing(),
+		Extra:     n.IsExtra(),
+		StartByte: n.StartByte(),
+		EndByte:   n.EndByte(),
+		Start:     PointJSON{Row: start.Row, Column: start.Column},
+		End:       PointJSON{Row: end.Row, Column: end.Column},
+	}
+	if n.ChildCount() == 0 && source != nil && n.EndByte() <= uint(len(source)) {
+		text := string(source[n.StartByte():n.EndByte()])
+		out.Text = &text
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		out.Children = append(out.Children, toJSON(n.Child(i), n.FieldNameForChild(uint32(i)), source))
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+func TestDumpSExpr(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString("f[1]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+
+	call := tree.RootNode().NamedDescendantForByteRange(0, 4)
+	for call.Kind() != "function_call" {
+		call = call.NamedChild(0)
+	}
+	want := `(function_call [0, 0] - [0, 4] 0..4
+  function: (identifier [0, 0] - [0, 1] 0..1)
+  left_bracket: "[" [0, 1] - [0, 2] 1..2
+  args: (argument_list [0, 2] - [0, 3] 2..3
+    arg: (expression [0, 2] - [0, 3] 2..3
+      (additive [0, 2] - [0, 3] 2..3
+        (multiplicative [0, 2] - [0, 3] 2..3
+          (unary [0, 2] - [0, 3] 2..3
+            (power [0, 2] - [0, 3] 2..3
+              (postfix [0, 2] - [0, 3] 2..3
+                (primary [0, 2] - [0, 3] 2..3
+                  (number [0, 2] - [0, 3] 2..3)))))))))
+  right_bracket: "]" [0, 3] - [0, 4] 3..4)
+`
+	if got := tree_sitter_wabznasm.DumpSExpr(call); got != want {
+		t.Errorf("DumpSExpr =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestDumpSExprMissing(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString("(1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	got := tree_sitter_wabznasm.DumpSExpr(tree.RootNode())
+	if want := `right_paren: (MISSING ")") [0, 2] - [0, 2] 2..2`; !strings.Contains(got, want) {
+		t.Errorf("DumpSExpr =\n%s\nmissing %q", got, want)
+	}
+}
+
+func TestDumpJSON(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	src := "x: 4 \\ four"
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+
+	data, err := tree_sitter_wabznasm.DumpJSON(tree.RootNode(), []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var root tree_sitter_wabznasm.NodeJSON
+	if err := json.Unmarshal(data, &root); err != nil {
+		t.Fatal(err)
+	}
+	if root.Kind != "source_file" || root.Text != nil || root.EndByte != uint(len(src)) {
+		t.Errorf("root = %+v", root)
+	}
+	assign := root.Children[0].Children[0]
+	if assign.Kind != "assignment" {
+		t.Fatalf("first grandchild = %s, want assignment", assign.Kind)
+	}
+	name := assign.Children[0]
+	if name.Field != "name" || name.Text == nil || *name.Text != "x" {
+		t.Errorf("name = %+v", name)
+	}
+	var comment *tree_sitter_wabznasm.NodeJSON
+	var find func(n *tree_sitter_wabznasm.NodeJSON)
+	find = func(n *tree_sitter_wabznasm.NodeJSON) {
+		if n.Kind == "comment" {
+			comment = n
+		}
+		for i := range n.Children {
+			find(&n.Children[i])
+		}
+	}
+	find(&root)
+	if comment == nil || !comment.Extra || comment.Start.Column != 5 || *comment.Text != "\\ four" {
+		t.Errorf("comment = %+v", comment)
+	}
+
+	data, err = tree_sitter_wabznasm.DumpJSON(tree.RootNode(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), `"text"`) {
+		t.Errorf("DumpJSON with nil source includes text")
+	}
+}
@@ -0,0 +1,238 @@
+// Package dupes finds copy-pasted functions in wabznasm sources.
+//
+// Every subtree of a function body is fingerprinted with asthash, after
+// the parameters are renamed by position so that {[a;b] a+b} and
+// {[x;y] x+y} look alike. Functions whose bodies have the same fingerprint
+// are exact clones. Bodies that differ are matched node by node from the
+// top, skipping over identical subtrees by their fingerprints: the
+// similarity of two bodies is twice the number of nodes matched over the
+// total number of nodes, so a copy with one number changed scores close
+// to 1 and two unrelated bodies close to 0.
+package dupes
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/asthash"
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// Options tune what is reported.
+type Options struct {
+	// Threshold is the least similarity, between 0 and 1, of two bodies
+	// reported as near clones. Zero means 0.8.
+	Threshold float64
+	// MinNodes is the least number of syntax nodes in a reported body, so
+	// that trivial functions such as {x+1} are not reported. Zero means 30.
+	MinNodes int
+}
+
+// Function is a function definition.
+type Function struct {
+	Path string
+	// Name is the name the function is assigned to.
+	Name  string
+	Range tree_sitter.Range
+
+	body *node
+}
+
+// node is the retained shape of a syntax node of a body.
+type node struct {
+	// label is the kind and field of the node and, for a leaf, its text.
+	label string
+	sum   asthash.Sum
+	// size counts the nodes of the subtree.
+	size     int
+	children []*node
+}
+
+// Clone is a group of functions that are copies of each other.
+type Clone struct {
+	// Functions are the copies, ordered by path and position.
+	Functions []Function
+	// Similarity is 1 for exact clones, which differ at most in layout,
+	// comments and parameter names, and less for near clones.
+	Similarity float64
+}
+
+// Detector collects functions and finds the clones among them.
+type Detector struct {
+	opts  Options
+	funcs []*Function
+}
+
+// New returns a detector with the given options.
+func New(opts Options) *Detector {
+	if opts.Threshold == 0 {
+		opts.Threshold = 0.8
+	}
+	if opts.MinNodes == 0 {
+		opts.MinNodes = 30
+	}
+	return &Detector{opts: opts}
+}
+
+// Add collects the functions defined in a parse tree of the source at
+// path. The tree is not retained.
+func (d *Detector) Add(path string, tree *tree_sitter.Tree, src []byte) {
+	var walk func(n *tree_sitter.Node)
+	walk = func(n *tree_sitter.Node) {
+		if n.Kind() == "function_body" && !n.HasError() {
+			if f := newFunction(path, n, src); f.body.size >= d.opts.MinNodes {
+				d.funcs = append(d.funcs, f)
+			}
+		}
+		for i := uint(0); i < n.NamedChildCount(); i++ {
+			walk(n.NamedChild(i))
+		}
+	}
+	walk(tree.RootNode())
+}
+
+func newFunction(path string, body *tree_sitter.Node, src []byte) *Function {
+	f := &Function{Path: path, Range: body.Range()}
+	if parent := body.Parent(); parent != nil && parent.Kind() == "assignment" {
+		if name := parent.ChildByFieldName("name"); name != nil {
+			f.Name = name.Utf8Text(src)
+		}
+	}
+	params := map[string]string{}
+	if list := body.ChildByFieldName("params"); list != nil {
+		for i := uint(0); i < list.NamedChildCount(); i++ {
+			if p := list.NamedChild(i); p.Kind() == "identifier" {
+				params[p.Utf8Text(src)] = "$" + strconv.Itoa(len(params))
+			}
+		}
+	}
+	leaf := func(n *tree_sitter.Node, text string) string {
+		if p, ok := params[text]; ok && n.Kind() == "identifier" {
+			return p
+		}
+		return text
+	}
+	var build func(n *tree_sitter.Node, field string) *node
+	build = func(n *tree_sitter.Node, field string) *node {
+		nd := &node{label: n.Kind() + " " + field, sum: asthash.HashFunc(n, src, leaf), size: 1}
+		if n.ChildCount() == 0 {
+			nd.label += " " + leaf(n, n.Utf8Text(src))
+		}
+		for i := uint(0); i < n.ChildCount(); i++ {
+			if c := n.Child(i); !c.IsExtra() {
+				child := build(c, n.FieldNameForChild(uint32(i)))
+				nd.children = append(nd.children, child)
+				nd.size += child.size
+			}
+		}
+		return nd
+	}
+	f.body = build(body, "")
+	return f
+}
+
+// Similarity returns the similarity of the bodies of a and b, between 0
+// and 1.
+func Similarity(a, b *Function) float64 {
+	return 2 * float64(common(a.body, b.body)) / float64(a.body.size+b.body.size)
+}
+
+// common counts the nodes a and b have in common, matching them from the
+// top down: nodes match if their labels do, and the children of matching
+// nodes are matched in order if there are as many of them. Identical
+// subtrees are recognized by their hashes without being walked.
+func common(a, b *node) int {
+	if a.sum == b.sum {
+		return a.size
+	}
+	if a.label != b.label || len(a.children) != len(b.children) {
+		return 0
+	}
+	n := 1
+	for i := range a.children {
+		n += common(a.children[i], b.children[i])
+	}
+	return n
+}
+
+// Clones returns the exact and near clones among the functions added so
+// far, most similar first and, among equally similar ones, largest first.
+// A group of exact clones is reported once; a near clone lists the
+// functions of both groups it joins.
+func (d *Detector) Clones() []Clone {
+	var groups [][]*Function
+	index := map[asthash.Sum]int{}
+	for _, f := range d.funcs {
+		if i, ok := index[f.body.sum]; ok {
+			groups[i] = append(groups[i], f)
+			continue
+		}
+		index[f.body.sum] = len(groups)
+		groups = append(groups, []*Function{f})
+	}
+
+	type scored struct {
+		funcs []*Function
+		sim   float64
+		size  int
+	}
+	var found []scored
+	for _, g := range groups {
+		if len(g) > 1 {
+			found = append(found, scored{g, 1, g[0].body.size})
+		}
+	}
+	for i, gi := range groups {
+		a := gi[0]
+		for _, gj := range groups[i+1:] {
+			b := gj[0]
+			// Similarity is at most 2*min/(a+b), the score if the smaller
+			// body were entirely shared.
+			if 2*float64(min(a.body.size, b.body.size))/float64(a.body.size+b.body.size) < d.opts.Threshold {
+				continue
+			}
+			if sim := Similarity(a, b); sim >= d.opts.Threshold {
+				funcs := append(append([]*Function(nil), gi...), gj...)
+				found = append(found, scored{funcs, sim, max(a.body.size, b.body.size)})
+			}
+		}
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].sim != found[j].sim {
+			return found[i].sim > found[j].sim
+		}
+		if found[i].size != found[j].size {
+			return found[i].size > found[j].size
+		}
+		return before(first(found[i].funcs), first(found[j].funcs))
+	})
+	clones := make([]Clone, len(found))
+	for i, s := range found {
+		sort.Slice(s.funcs, func(i, j int) bool { return before(s.funcs[i], s.funcs[j]) })
+		c := Clone{Similarity: s.sim}
+		for _, f := range s.funcs {
+			c.Functions = append(c.Functions, *f)
+		}
+		clones[i] = c
+	}
+	return clones
+}
+
+// first returns the function of funcs that comes first in the sources.
+func first(funcs []*Function) *Function {
+	f := funcs[0]
+	for _, g := range funcs[1:] {
+		if before(g, f) {
+			f = g
+		}
+	}
+	return f
+}
+
+func before(a, b *Function) bool {
+	if a.Path != b.Path {
+		return a.Path < b.Path
+	}
+	return a.Range.StartByte < b.Range.StartByte
+}
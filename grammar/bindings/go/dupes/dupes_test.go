@@ -0,0 +1,76 @@
+package dupes_test
+
+import (
+	"reflect"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/dupes"
+)
+
+func detect(t *testing.T, opts dupes.Options, sources map[string]string) []dupes.Clone {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	d := dupes.New(opts)
+	for path, src := range sources {
+		tree, err := parser.ParseBytes([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Add(path, tree.Tree, []byte(src))
+		tree.Close()
+	}
+	return d.Clones()
+}
+
+func names(c dupes.Clone) []string {
+	var out []string
+	for _, f := range c.Functions {
+		out = append(out, f.Path+":"+f.Name)
+	}
+	return out
+}
+
+func TestClones(t *testing.T) {
+	clones := detect(t, dupes.Options{}, map[string]string{
+		"a.wz": "f: {[a;b] (a+b)*2-a%b}",
+		// The same function, laid out and named differently.
+		"b.wz": "g: {[x; y]\n  \\ scaled sum\n  (x + y) * 2 - x % y\n}",
+		// One number differs.
+		"c.wz": "h: {[x;y] (x+y)*3-x%y}",
+		"d.wz": "k: {[p;q;r] p^q+r*r-1}",
+		// Too small to report, however often it is copied.
+		"e.wz": "inc: {x+1}",
+		"f.wz": "inc: {x+1}",
+	})
+	if len(clones) != 2 {
+		t.Fatalf("got %d clones, want 2: %v", len(clones), clones)
+	}
+	if got, want := names(clones[0]), []string{"a.wz:f", "b.wz:g"}; clones[0].Similarity != 1 || !reflect.DeepEqual(got, want) {
+		t.Errorf("clones[0] = %v at %v, want %v at 1", got, clones[0].Similarity, want)
+	}
+	if got, want := names(clones[1]), []string{"a.wz:f", "b.wz:g", "c.wz:h"}; clones[1].Similarity < 0.9 || clones[1].Similarity == 1 || !reflect.DeepEqual(got, want) {
+		t.Errorf("clones[1] = %v at %v, want %v near 1", got, clones[1].Similarity, want)
+	}
+	if r := clones[0].Functions[1].Range; r.StartPoint.Row != 0 || r.EndPoint.Row != 3 {
+		t.Errorf("b.wz:g spans rows %d-%d, want 0-3", r.StartPoint.Row, r.EndPoint.Row)
+	}
+}
+
+func TestOptions(t *testing.T) {
+	sources := map[string]string{
+		"a.wz": "inc: {x+1}",
+		"b.wz": "inc2: {x+2}",
+	}
+	if clones := detect(t, dupes.Options{}, sources); len(clones) != 0 {
+		t.Errorf("default options: got %v, want none", clones)
+	}
+	clones := detect(t, dupes.Options{MinNodes: 1, Threshold: 0.5}, sources)
+	if len(clones) != 1 || clones[0].Similarity >= 1 {
+		t.Fatalf("got %v, want one near clone", clones)
+	}
+}
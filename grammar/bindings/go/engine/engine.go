@@ -0,0 +1,271 @@
+// Package engine embeds wabznasm in Go programs. An Engine bundles what a
+// host would otherwise assemble from the parser, ast and eval packages, a
+// pool of parsers, the builtins scripts may call and the limits they run
+// under, behind three operations: Compile parses a source once into a
+// Program, Check reports its syntax errors, and Eval runs it. Tools that
+// need the syntax tree itself, to format or lint the source, get it from
+// Parse under the same limits.
+//
+// Each Session holds the globals of one tenant. Sessions of one Engine
+// share its parsers and builtins but never each other's assignments, so a
+// service can give every user, request or job its own:
+//
+//	e := engine.New(engine.Options{Limits: eval.Limits{MaxSteps: 1e6}, Timeout: time.Second})
+//	defer e.Close()
+//	s := e.NewSession()
+//	s.Eval(ctx, []byte("sq: {x*x}"))
+//	v, err := s.Eval(ctx, []byte("sq[12]"))
+//
+// An Engine and its Sessions are safe for concurrent use; a Session runs
+// one evaluation at a time.
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// ErrTooLarge is returned for a source over Options.MaxSourceBytes.
+var ErrTooLarge = errors.New("engine: source too large")
+
+// ErrClosed is returned by an Engine after Close.
+var ErrClosed = errors.New("engine: closed")
+
+// Options configure an Engine. The zero Options offer the registered
+// builtins without limits.
+type Options struct {
+	// Builtins are the builtins scripts may call. Nil means those
+	// registered with eval.Register when the Engine is created; an empty,
+	// non-nil slice offers none.
+	Builtins []*eval.Builtin
+	// Limits cap each evaluation.
+	Limits eval.Limits
+	// Timeout bounds each call of Compile, Check and Eval, on top of the
+	// deadline of its context. Zero means no bound.
+	Timeout time.Duration
+	// MaxSourceBytes rejects longer sources with ErrTooLarge before they
+	// are parsed. Zero means no bound.
+	MaxSourceBytes int
+}
+
+// Engine compiles and evaluates sources. The caller must call Close.
+type Engine struct {
+	opts     Options
+	pool     *tree_sitter_wabznasm.ParserPool
+	builtins *eval.Env
+}
+
+// New returns an Engine configured by opts.
+func New(opts Options) *Engine {
+	builtins := opts.Builtins
+	if builtins == nil {
+		builtins = eval.Builtins()
+	}
+	return &Engine{opts: opts, pool: tree_sitter_wabznasm.NewParserPool(), builtins: eval.BuiltinEnv(builtins...)}
+}
+
+// Close releases the parsers of e. Later calls of Compile, Check and Eval
+// fail with ErrClosed; evaluations of Programs already compiled still
+// run.
+func (e *Engine) Close() {
+	e.pool.Close()
+}
+
+// Program is a compiled source. It holds no parser resources and may be
+// run any number of times, by any Session of any Engine.
+type Program struct {
+	file   *ast.File
+	source []byte
+}
+
+// Source returns the text p was compiled from.
+func (p *Program) Source() []byte { return p.source }
+
+// Compile parses src into a Program. A source with syntax errors fails
+// with an *eval.Error of code eval.CodeSyntax locating the first; Check
+// lists them all.
+func (e *Engine) Compile(ctx context.Context, src []byte) (*Program, error) {
+	ctx, cancel := e.context(ctx)
+	defer cancel()
+	return e.compile(ctx, src)
+}
+
+func (e *Engine) compile(ctx context.Context, src []byte) (*Program, error) {
+	var prog *Program
+	err := e.parse(ctx, src, func(tree *tree_sitter.Tree) error {
+		if tree.RootNode().HasError() {
+			return eval.SyntaxError(tree, src)
+		}
+		source := append([]byte(nil), src...)
+		prog = &Program{file: ast.FromTree(tree, source), source: source}
+		return nil
+	})
+	return prog, err
+}
+
+// Check reports the syntax errors of src, a statement at a time, without
+// evaluating it. The error is set only if src could not be parsed at all,
+// as when it is too large or the parse runs out of time.
+func (e *Engine) Check(ctx context.Context, src []byte) ([]tree_sitter_wabznasm.Diagnostic, error) {
+	s, err := e.ParseScript(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	return s.Diagnostics(), nil
+}
+
+// Parse parses src into a syntax tree, errors included. The caller must
+// close the tree. The error is set only if src could not be parsed at
+// all, as for Check.
+func (e *Engine) Parse(ctx context.Context, src []byte) (*tree_sitter.Tree, error) {
+	ctx, cancel := e.context(ctx)
+	defer cancel()
+	var tree *tree_sitter.Tree
+	err := e.parse(ctx, src, func(t *tree_sitter.Tree) error {
+		tree = t.Clone()
+		return nil
+	})
+	return tree, err
+}
+
+// ParseScript parses src a statement at a time, as Parser.ParseScript
+// does. The caller must close the script. The error is set only if src
+// could not be parsed at all, as for Check.
+func (e *Engine) ParseScript(ctx context.Context, src []byte) (*tree_sitter_wabznasm.Script, error) {
+	ctx, cancel := e.context(ctx)
+	defer cancel()
+	var s *tree_sitter_wabznasm.Script
+	err := e.withParser(src, func(p *tree_sitter_wabznasm.Parser) error {
+		var err error
+		s, err = p.ParseScriptContext(ctx, src)
+		return err
+	})
+	return s, err
+}
+
+// Eval compiles and runs src in a new Session, for one-off evaluations
+// that keep no state.
+func (e *Engine) Eval(ctx context.Context, src []byte) (eval.Value, error) {
+	return e.NewSession().Eval(ctx, src)
+}
+
+// parse parses src with a pooled parser and passes the tree to use, which
+// must not keep it.
+func (e *Engine) parse(ctx context.Context, src []byte, use func(*tree_sitter.Tree) error) error {
+	return e.withParser(src, func(p *tree_sitter_wabznasm.Parser) error {
+		tree, err := p.ParseContext(ctx, src)
+		if err != nil {
+			return err
+		}
+		defer tree.Close()
+		return use(tree.Tree)
+	})
+}
+
+// withParser passes a pooled parser to use, once src is known to be within
+// the size limit.
+func (e *Engine) withParser(src []byte, use func(*tree_sitter_wabznasm.Parser) error) error {
+	if e.opts.MaxSourceBytes > 0 && len(src) > e.opts.MaxSourceBytes {
+		return fmt.Errorf("%w: %d bytes, limit %d", ErrTooLarge, len(src), e.opts.MaxSourceBytes)
+	}
+	p, err := e.pool.Get()
+	if errors.Is(err, tree_sitter_wabznasm.ErrPoolClosed) {
+		return ErrClosed
+	}
+	if err != nil {
+		return err
+	}
+	defer e.pool.Put(p)
+	return use(p)
+}
+
+// context applies the Timeout of e to ctx.
+func (e *Engine) context(ctx context.Context) (context.Context, context.CancelFunc) {
+	if e.opts.Timeout > 0 {
+		return context.WithTimeout(ctx, e.opts.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+// Session is the global environment of one tenant of an Engine.
+type Session struct {
+	engine *Engine
+	mu     sync.Mutex
+	interp *eval.Interpreter
+}
+
+// NewSession returns a Session with no globals.
+func (e *Engine) NewSession() *Session {
+	in := &eval.Interpreter{Globals: eval.NewEnv(e.builtins), Limits: e.opts.Limits}
+	return &Session{engine: e, interp: in}
+}
+
+// Eval compiles src and runs it in s. The compiling and the run share the
+// Timeout of the Engine.
+func (s *Session) Eval(ctx context.Context, src []byte) (eval.Value, error) {
+	ctx, cancel := s.engine.context(ctx)
+	defer cancel()
+	prog, err := s.engine.compile(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return s.run(ctx, prog)
+}
+
+// Run evaluates p in s. An evaluation stopped by the Limits, the Timeout
+// or ctx fails with an *eval.LimitExceededError. A program with no
+// statement evaluates to a nil Value.
+func (s *Session) Run(ctx context.Context, p *Program) (eval.Value, error) {
+	ctx, cancel := s.engine.context(ctx)
+	defer cancel()
+	return s.run(ctx, p)
+}
+
+func (s *Session) run(ctx context.Context, p *Program) (eval.Value, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interp.EvalFileContext(ctx, p.file, p.source)
+}
+
+// Lookup returns the value of the global name in s. Builtins are not
+// globals.
+func (s *Session) Lookup(name string) (eval.Value, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !contains(s.interp.Globals.Names(), name) {
+		return nil, false
+	}
+	return s.interp.Globals.Lookup(name)
+}
+
+// Define assigns v to the global name in s, as an assignment would.
+func (s *Session) Define(name string, v eval.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.interp.Globals.Define(name, v)
+}
+
+// Globals returns the names assigned in s, sorted.
+func (s *Session) Globals() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.interp.Globals.Names()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/engine"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+var double = &eval.Builtin{Name: "double", Params: []string{"x"}, Fn: func(args []eval.Value) (eval.Value, error) {
+	return args[0].(eval.Long) * 2, nil
+}}
+
+func TestEngine(t *testing.T) {
+	e := engine.New(engine.Options{Builtins: []*eval.Builtin{double}})
+	defer e.Close()
+	ctx := context.Background()
+
+	if v, err := e.Eval(ctx, []byte("double[1+2]")); err != nil || v.String() != "6" {
+		t.Errorf("double[1+2] = %v, %v", v, err)
+	}
+
+	prog, err := e.Compile(ctx, []byte("sq: {x*x}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Sessions run the same program without seeing each other's globals.
+	a, b := e.NewSession(), e.NewSession()
+	if _, err := a.Run(ctx, prog); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := a.Eval(ctx, []byte("sq[12]")); err != nil || v.String() != "144" {
+		t.Errorf("sq[12] = %v, %v", v, err)
+	}
+	if _, err := b.Eval(ctx, []byte("sq[12]")); err == nil {
+		t.Error("sq is defined in another session")
+	}
+	if got := a.Globals(); len(got) != 1 || got[0] != "sq" {
+		t.Errorf("Globals() = %v", got)
+	}
+	if _, ok := a.Lookup("double"); ok {
+		t.Error("Lookup found a builtin")
+	}
+	b.Define("n", eval.Long(5))
+	if v, err := b.Eval(ctx, []byte("double[n]")); err != nil || v.String() != "10" {
+		t.Errorf("double[n] = %v, %v", v, err)
+	}
+
+	_, err = e.Compile(ctx, []byte("1+"))
+	var ee *eval.Error
+	if !errors.As(err, &ee) || ee.Code != eval.CodeSyntax {
+		t.Errorf("Compile(1+) = %v, want a syntax error", err)
+	}
+	diags, err := e.Check(ctx, []byte("f[1;"))
+	if err != nil || len(diags) == 0 {
+		t.Errorf("Check(f[1;) = %v, %v", diags, err)
+	}
+	if diags, err := e.Check(ctx, []byte("f[1]")); err != nil || len(diags) != 0 {
+		t.Errorf("Check(f[1]) = %v, %v", diags, err)
+	}
+	if diags, err := e.Check(ctx, []byte("f: {x}\nf[1]")); err != nil || len(diags) != 0 {
+		t.Errorf("Check of two statements = %v, %v", diags, err)
+	}
+	tree, err := e.Parse(ctx, []byte("f[1;"))
+	if err != nil || !tree.RootNode().HasError() {
+		t.Errorf("Parse(f[1;) = %v, %v", tree, err)
+	}
+	tree.Close()
+
+	e.Close()
+	if _, err := e.Compile(ctx, []byte("1")); !errors.Is(err, engine.ErrClosed) {
+		t.Errorf("Compile after Close = %v", err)
+	}
+	if _, err := e.Parse(ctx, []byte("1")); !errors.Is(err, engine.ErrClosed) {
+		t.Errorf("Parse after Close = %v", err)
+	}
+	if v, err := a.Run(ctx, prog); err != nil || v == nil {
+		t.Errorf("Run after Close = %v, %v", v, err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	e := engine.New(engine.Options{
+		Builtins:       []*eval.Builtin{},
+		Limits:         eval.Limits{MaxDepth: 64},
+		Timeout:        50 * time.Millisecond,
+		MaxSourceBytes: 64,
+	})
+	defer e.Close()
+	ctx := context.Background()
+
+	if _, err := e.Eval(ctx, []byte("double[1]")); err == nil {
+		t.Error("called a builtin the engine does not offer")
+	}
+	if _, err := e.Eval(ctx, []byte(strings.Repeat("1+", 40)+"1")); !errors.Is(err, engine.ErrTooLarge) {
+		t.Errorf("long source: %v", err)
+	}
+
+	// f40 makes 2^40 calls, none deeper than 40.
+	s := e.NewSession()
+	s.Define("f0", double)
+	for i := 1; i <= 40; i++ {
+		if _, err := s.Eval(ctx, []byte(fmt.Sprintf("f%d: {f%d[x]+f%[2]d[x]}", i, i-1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start := time.Now()
+	_, err := s.Eval(ctx, []byte("f40[1]"))
+	var le *eval.LimitExceededError
+	if !errors.As(err, &le) || le.Limit != eval.LimitDeadline {
+		t.Errorf("f40[1] = %v, want the deadline", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("f40[1] ran for %v", d)
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	e := engine.New(engine.Options{Builtins: []*eval.Builtin{double}})
+	defer e.Close()
+	ctx := context.Background()
+	shared := e.NewSession()
+	var wg sync.WaitGroup
+	errs := make(chan error, 32)
+	for i := range 16 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := e.NewSession()
+			want := fmt.Sprint(i * 2)
+			if _, err := s.Eval(ctx, []byte(fmt.Sprintf("n: %d", i))); err != nil {
+				errs <- err
+				return
+			}
+			for range 20 {
+				if v, err := s.Eval(ctx, []byte("double[n]")); err != nil || v.String() != want {
+					errs <- fmt.Errorf("session %d: double[n] = %v, %v", i, v, err)
+					return
+				}
+				if _, err := shared.Eval(ctx, []byte("1+1")); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,196 @@
+package eval
+
+import "math"
+
+// Binary applies an infix operator. Longs use checked integer arithmetic
+// with the same rules as the Rust evaluator; if either operand is a float
+// the operation is carried out in floating point following IEEE 754. Lists
+// apply the operator element-wise, extending atoms across the other side;
+// see vector.go for how lists of numbers are carried out.
+func Binary(op string, a, b Value) (Value, error) {
+	la, aList := a.(List)
+	lb, bList := b.(List)
+	if aList || bList {
+		if v, ok, err := vectorBinary(op, a, b); ok {
+			return v, err
+		}
+	}
+	switch {
+	case aList && bList:
+		if len(la) != len(lb) {
+			return nil, &Error{Code: CodeLength, Message: "length mismatch in " + op}
+		}
+		out := make(List, len(la))
+		for i := range la {
+			v, err := Binary(op, la[i], lb[i])
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case aList:
+		out := make(List, len(la))
+		for i := range la {
+			v, err := Binary(op, la[i], b)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case bList:
+		out := make(List, len(lb))
+		for i := range lb {
+			v, err := Binary(op, a, lb[i])
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+
+	x, xLong := a.(Long)
+	y, yLong := b.(Long)
+	if xLong && yLong {
+		return longBinary(op, int64(x), int64(y))
+	}
+	fx, okA := toFloat(a)
+	fy, okB := toFloat(b)
+	if !okA || !okB {
+		return nil, &Error{Code: CodeType, Message: "cannot apply " + op + " to " + a.Kind().String() + " and " + b.Kind().String()}
+	}
+	switch op {
+	case "+":
+		return Float(fx + fy), nil
+	case "-":
+		return Float(fx - fy), nil
+	case "*":
+		return Float(fx * fy), nil
+	case "/":
+		return Float(fx / fy), nil
+	case "%":
+		return Float(math.Mod(fx, fy)), nil
+	case "^":
+		return Float(math.Pow(fx, fy)), nil
+	}
+	return nil, &Error{Code: CodeUnknownOperator, Message: "unknown operator " + op}
+}
+
+func longBinary(op string, x, y int64) (Value, error) {
+	switch op {
+	case "+":
+		s := x + y
+		if (s > x) != (y > 0) {
+			return nil, overflow("addition")
+		}
+		return Long(s), nil
+	case "-":
+		d := x - y
+		if (d < x) != (y > 0) {
+			return nil, overflow("subtraction")
+		}
+		return Long(d), nil
+	case "*":
+		if x == 0 || y == 0 {
+			return Long(0), nil
+		}
+		p := x * y
+		if p/y != x || (x == -1 && y == math.MinInt64) || (y == -1 && x == math.MinInt64) {
+			return nil, overflow("multiplication")
+		}
+		return Long(p), nil
+	case "/", "%":
+		if y == 0 {
+			return nil, &Error{Code: CodeDivisionByZero, Message: "division by zero"}
+		}
+		if x == math.MinInt64 && y == -1 {
+			return nil, overflow("division")
+		}
+		if op == "/" {
+			return Long(x / y), nil
+		}
+		return Long(x % y), nil
+	case "^":
+		return power(x, y)
+	}
+	return nil, &Error{Code: CodeUnknownOperator, Message: "unknown operator " + op}
+}
+
+func power(base, exp int64) (Value, error) {
+	if exp < 0 {
+		return nil, &Error{Code: CodeNegativeExponent, Message: "negative exponent"}
+	}
+	if exp > 63 {
+		return nil, &Error{Code: CodeExponentTooLarge, Message: "exponent too large"}
+	}
+	result := Value(Long(1))
+	for i := int64(0); i < exp; i++ {
+		var err error
+		if result, err = longBinary("*", int64(result.(Long)), base); err != nil {
+			return nil, overflow("exponentiation")
+		}
+	}
+	return result, nil
+}
+
+// Negate applies prefix minus.
+func Negate(v Value) (Value, error) {
+	switch v := v.(type) {
+	case Long:
+		if v == math.MinInt64 {
+			return nil, overflow("negation")
+		}
+		return -v, nil
+	case Float:
+		return -v, nil
+	case List:
+		if n, ok, err := vectorNegate(v); ok {
+			return n, err
+		}
+		out := make(List, len(v))
+		for i, item := range v {
+			n, err := Negate(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n
+		}
+		return out, nil
+	}
+	return nil, &Error{Code: CodeType, Message: "cannot negate " + v.Kind().String()}
+}
+
+// Factorial applies postfix !.
+func Factorial(v Value) (Value, error) {
+	switch v := v.(type) {
+	case Long:
+		if v < 0 {
+			return nil, &Error{Code: CodeFactorialOfNegative, Message: "factorial of negative number"}
+		}
+		if v > 20 {
+			return nil, &Error{Code: CodeFactorialTooLarge, Message: "factorial too large"}
+		}
+		result := Long(1)
+		for i := Long(2); i <= v; i++ {
+			result *= i
+		}
+		return result, nil
+	case List:
+		out := make(List, len(v))
+		for i, item := range v {
+			n, err := Factorial(item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = n
+		}
+		return out, nil
+	}
+	return nil, &Error{Code: CodeType, Message: "factorial of " + v.Kind().String()}
+}
+
+func overflow(what string) *Error {
+	return &Error{Code: CodeIntegerOverflow, Message: "integer overflow in " + what}
+}
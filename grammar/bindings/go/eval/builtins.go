@@ -0,0 +1,285 @@
+package eval
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// CodeBuiltin is the code of errors returned, or panics raised, by the Go
+// function of a builtin.
+const CodeBuiltin = "BUILTIN_ERROR"
+
+// Builtin is a function implemented in Go. Builtins are bound in the scope
+// enclosing an interpreter's globals, so a global of the same name shadows
+// them.
+type Builtin struct {
+	Name string
+	// Params names the parameters; only their number matters.
+	Params []string
+	Fn     func(args []Value) (Value, error)
+}
+
+// Kind returns KindFunction.
+func (b *Builtin) Kind() Kind { return KindFunction }
+
+func (b *Builtin) String() string { return "<builtin " + b.Name + ">" }
+
+// Arity returns the number of arguments the builtin expects.
+func (b *Builtin) Arity() int { return len(b.Params) }
+
+// Call applies the builtin to args. Errors other than *Error, and panics,
+// are reported with CodeBuiltin.
+func (b *Builtin) Call(args []Value) (v Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			v, err = nil, &Error{Code: CodeBuiltin, Message: fmt.Sprintf("%s: panic: %v", b.Name, r)}
+		}
+	}()
+	v, err = b.Fn(args)
+	var e *Error
+	switch {
+	case err == nil && v == nil:
+		return nil, &Error{Code: CodeBuiltin, Message: b.Name + ": no result"}
+	case err != nil && !errors.As(err, &e):
+		return nil, &Error{Code: CodeBuiltin, Message: b.Name + ": " + err.Error()}
+	}
+	return v, err
+}
+
+var (
+	valueType = reflect.TypeOf((*Value)(nil)).Elem()
+	tableType = reflect.TypeOf((*Table)(nil))
+	errorType = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// NewBuiltin wraps a Go function as a builtin. Fn may be any non-variadic
+// function whose parameters and results convert to and from values:
+//
+//   - signed and unsigned integers convert to and from longs,
+//   - floats convert to and from floats, and from longs,
+//   - strings convert to and from symbols,
+//   - bools convert to 1 and 0, and from longs, zero being false,
+//   - slices of these convert to and from lists,
+//   - *Table and Value pass through unchanged.
+//
+// The function may return a single result, or a result and an error. A
+// function working on values directly can be given as a Builtin literal
+// instead.
+func NewBuiltin(name string, fn any) (*Builtin, error) {
+	f := reflect.ValueOf(fn)
+	if f.Kind() != reflect.Func {
+		return nil, fmt.Errorf("builtin %s: %T is not a function", name, fn)
+	}
+	t := f.Type()
+	if t.IsVariadic() {
+		return nil, fmt.Errorf("builtin %s: variadic functions are not supported", name)
+	}
+	params := make([]string, t.NumIn())
+	for i := range params {
+		if !convertible(t.In(i)) {
+			return nil, fmt.Errorf("builtin %s: unsupported parameter type %s", name, t.In(i))
+		}
+		params[i] = t.In(i).String()
+	}
+	switch {
+	case t.NumOut() == 1 && convertible(t.Out(0)):
+	case t.NumOut() == 2 && convertible(t.Out(0)) && t.Out(1) == errorType:
+	default:
+		return nil, fmt.Errorf("builtin %s: results must be a value and optionally an error", name)
+	}
+	b := &Builtin{Name: name, Params: params}
+	b.Fn = func(args []Value) (Value, error) {
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			v, err := fromValue(arg, t.In(i))
+			if err != nil {
+				return nil, &Error{Code: CodeType, Message: fmt.Sprintf("%s: argument %d: %s", name, i+1, err)}
+			}
+			in[i] = v
+		}
+		out := f.Call(in)
+		if len(out) == 2 && !out[1].IsNil() {
+			return nil, out[1].Interface().(error)
+		}
+		return toValue(out[0])
+	}
+	return b, nil
+}
+
+func convertible(t reflect.Type) bool {
+	if t == valueType || t == tableType {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String, reflect.Bool:
+		return true
+	case reflect.Slice:
+		return convertible(t.Elem())
+	}
+	return false
+}
+
+// fromValue converts v to the Go type t.
+func fromValue(v Value, t reflect.Type) (reflect.Value, error) {
+	if t == valueType {
+		return reflect.ValueOf(&v).Elem(), nil
+	}
+	out := reflect.New(t).Elem()
+	mismatch := func() error { return fmt.Errorf("cannot use %s as %s", v.Kind(), t) }
+	if t == tableType {
+		tab, ok := v.(*Table)
+		if !ok {
+			return out, mismatch()
+		}
+		return reflect.ValueOf(tab), nil
+	}
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := v.(Long)
+		if !ok {
+			return out, mismatch()
+		}
+		if out.OverflowInt(int64(n)) {
+			return out, fmt.Errorf("%d overflows %s", n, t)
+		}
+		out.SetInt(int64(n))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := v.(Long)
+		if !ok {
+			return out, mismatch()
+		}
+		if n < 0 || out.OverflowUint(uint64(n)) {
+			return out, fmt.Errorf("%d overflows %s", n, t)
+		}
+		out.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat(v)
+		if !ok {
+			return out, mismatch()
+		}
+		out.SetFloat(f)
+	case reflect.String:
+		s, ok := v.(Symbol)
+		if !ok {
+			return out, mismatch()
+		}
+		out.SetString(string(s))
+	case reflect.Bool:
+		n, ok := v.(Long)
+		if !ok {
+			return out, mismatch()
+		}
+		out.SetBool(n != 0)
+	case reflect.Slice:
+		l, ok := v.(List)
+		if !ok {
+			return out, mismatch()
+		}
+		out = reflect.MakeSlice(t, len(l), len(l))
+		for i, item := range l {
+			e, err := fromValue(item, t.Elem())
+			if err != nil {
+				return out, fmt.Errorf("item %d: %s", i, err)
+			}
+			out.Index(i).Set(e)
+		}
+	}
+	return out, nil
+}
+
+// toValue converts a Go value of a convertible type to a Value.
+func toValue(v reflect.Value) (Value, error) {
+	if v.Type() == valueType {
+		if v.IsNil() {
+			return nil, nil
+		}
+		return v.Interface().(Value), nil
+	}
+	if v.Type() == tableType {
+		if v.IsNil() {
+			return nil, nil
+		}
+		return v.Interface().(*Table), nil
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Long(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return nil, overflow("conversion")
+		}
+		return Long(v.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return Float(v.Float()), nil
+	case reflect.String:
+		return Symbol(v.String()), nil
+	case reflect.Bool:
+		if v.Bool() {
+			return Long(1), nil
+		}
+		return Long(0), nil
+	case reflect.Slice:
+		out := make(List, v.Len())
+		for i := range out {
+			item, err := toValue(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			out[i] = item
+		}
+		return out, nil
+	}
+	return nil, fmt.Errorf("unsupported result type %s", v.Type())
+}
+
+var registry = struct {
+	sync.RWMutex
+	builtins map[string]*Builtin
+}{builtins: map[string]*Builtin{}}
+
+// RegisterBuiltin wraps fn with NewBuiltin and makes it available to
+// interpreters created by New afterwards. Registering a name again
+// replaces the earlier builtin.
+func RegisterBuiltin(name string, fn any) error {
+	b, err := NewBuiltin(name, fn)
+	if err != nil {
+		return err
+	}
+	Register(b)
+	return nil
+}
+
+// Register makes b available to interpreters created by New afterwards.
+func Register(b *Builtin) {
+	registry.Lock()
+	defer registry.Unlock()
+	registry.builtins[b.Name] = b
+}
+
+// Builtins returns the registered builtins in name order.
+func Builtins() []*Builtin {
+	registry.RLock()
+	defer registry.RUnlock()
+	out := make([]*Builtin, 0, len(registry.builtins))
+	for _, b := range registry.builtins {
+		out = append(out, b)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// BuiltinEnv returns a scope binding builtins, for use as the parent of a
+// global environment.
+func BuiltinEnv(builtins ...*Builtin) *Env {
+	env := NewEnv(nil)
+	for _, b := range builtins {
+		env.Define(b.Name, b)
+	}
+	return env
+}
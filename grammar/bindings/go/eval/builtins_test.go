@@ -0,0 +1,104 @@
+package eval_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+func TestBuiltins(t *testing.T) {
+	if err := eval.RegisterBuiltin("hyp", func(a, b float64) float64 { return a*a + b*b }); err != nil {
+		t.Fatal(err)
+	}
+	if err := eval.RegisterBuiltin("upper", func(s string) string { return strings.ToUpper(s) }); err != nil {
+		t.Fatal(err)
+	}
+	if err := eval.RegisterBuiltin("sum", func(xs []int64) (int64, error) {
+		var n int64
+		for _, x := range xs {
+			n += x
+		}
+		if n < 0 {
+			return 0, errors.New("negative sum")
+		}
+		return n, nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	eval.Register(&eval.Builtin{Name: "first", Params: []string{"v"}, Fn: func(args []eval.Value) (eval.Value, error) {
+		if l, ok := args[0].(eval.List); ok && len(l) > 0 {
+			return l[0], nil
+		}
+		return args[0], nil
+	}})
+
+	in := eval.New()
+	for _, tc := range []struct{ src, want string }{
+		{"hyp[3;4]", "25f"},
+		{"hyp[1;2]+1", "6f"},
+		{"f: {[a] hyp[a;a]}", "{[a] hyp[a;a]}"},
+		{"f[2]", "8f"},
+		{"first[7]", "7"},
+		{"hyp", "<builtin hyp>"},
+	} {
+		v, err := in.EvalString(tc.src)
+		if err != nil || v.String() != tc.want {
+			t.Errorf("%s = %v, %v; want %s", tc.src, v, err, tc.want)
+		}
+	}
+
+	in.Globals.Define("xs", eval.List{eval.Long(1), eval.Long(2)})
+	in.Globals.Define("word", eval.Symbol("abc"))
+	if v, err := in.EvalString("sum[xs]*10"); err != nil || v.String() != "30" {
+		t.Errorf("sum[xs]*10 = %v, %v", v, err)
+	}
+	if v, err := in.EvalString("upper[word]"); err != nil || v != eval.Symbol("ABC") {
+		t.Errorf("upper[word] = %v, %v", v, err)
+	}
+
+	for src, code := range map[string]string{
+		"hyp[1]":    eval.CodeArity,
+		"upper[1]":  eval.CodeType,
+		"sum[-5]":   eval.CodeType,
+		"sum[0-xs]": eval.CodeBuiltin,
+		"nope[1]":   eval.CodeUndefined,
+	} {
+		_, err := in.EvalString(src)
+		var e *eval.Error
+		if !errors.As(err, &e) || e.Code != code {
+			t.Errorf("%s: err = %v, want code %s", src, err, code)
+		}
+	}
+
+	// A global shadows a builtin, and a sandbox sees only its own.
+	if v, err := in.EvalString("hyp: 1"); err != nil || v.String() != "1" {
+		t.Errorf("hyp: 1 = %v, %v", v, err)
+	}
+	sandbox := eval.NewWith()
+	if _, err := sandbox.EvalString("hyp[3;4]"); err == nil {
+		t.Error("sandbox called a registered builtin")
+	}
+}
+
+func TestBuiltinPanic(t *testing.T) {
+	b, err := eval.NewBuiltin("boom", func(n int8) int8 { panic("boom") })
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := eval.NewWith(b)
+	for _, src := range []string{"boom[1]", "boom[300]"} {
+		_, err := in.EvalString(src)
+		var e *eval.Error
+		if !errors.As(err, &e) || e.Span.End.Offset == 0 {
+			t.Errorf("%s: err = %v", src, err)
+		}
+	}
+	if _, err := eval.NewBuiltin("bad", func(ch chan int) int { return 0 }); err == nil {
+		t.Error("NewBuiltin accepted a channel parameter")
+	}
+	if _, err := eval.NewBuiltin("bad", 42); err == nil {
+		t.Error("NewBuiltin accepted a non-function")
+	}
+}
@@ -0,0 +1,455 @@
+package eval
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+// Values encode to JSON as objects holding their kind and value:
+//
+//	{"kind":"long","value":42}
+//	{"kind":"float","value":1.5}
+//	{"kind":"symbol","value":"abc"}
+//	{"kind":"list","value":[{"kind":"long","value":1}]}
+//	{"kind":"function","value":"{[a] a+1}"}
+//	{"kind":"builtin","value":"hyp"}
+//	{"kind":"table","value":{"names":["a"],"columns":[{"kind":"list","value":[...]}]}}
+//
+// Floats that JSON numbers cannot hold are encoded as the strings "NaN",
+// "+Inf" and "-Inf". A function is encoded as its source text and a builtin
+// as its name, so decoding them needs an environment: a function closes
+// over it again and a builtin is looked up in it.
+//
+// The binary encoding holds the same information: a version byte,
+// followed by each value as a tag byte and its payload. Longs are zig-zag
+// varints, floats are little-endian IEEE 754 bits, and symbols, function
+// sources and builtin names are length-prefixed. A list is its length
+// followed by its items, and a table the number of its columns followed by
+// the name and list of items of each.
+
+// binaryVersion is the first byte of the binary encoding.
+const binaryVersion = 1
+
+// Binary tags.
+const (
+	tagLong     = 'l'
+	tagFloat    = 'f'
+	tagSymbol   = 's'
+	tagList     = 'L'
+	tagFunction = 'F'
+	tagBuiltin  = 'B'
+	tagTable    = 'T'
+)
+
+// jsonTable is the value of an encoded table; each column is an encoded
+// list.
+type jsonTable struct {
+	Names   []string          `json:"names"`
+	Columns []json.RawMessage `json:"columns"`
+}
+
+type jsonValue struct {
+	Kind  string          `json:"kind"`
+	Value json.RawMessage `json:"value"`
+}
+
+// MarshalJSON encodes v as a tagged JSON object.
+func (v Long) MarshalJSON() ([]byte, error) { return encodeJSON(v) }
+
+// MarshalJSON encodes v as a tagged JSON object.
+func (v Float) MarshalJSON() ([]byte, error) { return encodeJSON(v) }
+
+// MarshalJSON encodes v as a tagged JSON object.
+func (v Symbol) MarshalJSON() ([]byte, error) { return encodeJSON(v) }
+
+// MarshalJSON encodes v as a tagged JSON object.
+func (v List) MarshalJSON() ([]byte, error) { return encodeJSON(v) }
+
+// MarshalJSON encodes f as its source text.
+func (f *Function) MarshalJSON() ([]byte, error) { return encodeJSON(f) }
+
+// MarshalJSON encodes b as its name.
+func (b *Builtin) MarshalJSON() ([]byte, error) { return encodeJSON(b) }
+
+// MarshalJSON encodes t as a tagged JSON object.
+func (t *Table) MarshalJSON() ([]byte, error) { return encodeJSON(t) }
+
+// UnmarshalJSON decodes a long.
+func (v *Long) UnmarshalJSON(data []byte) error { return decodeInto(data, v) }
+
+// UnmarshalJSON decodes a float.
+func (v *Float) UnmarshalJSON(data []byte) error { return decodeInto(data, v) }
+
+// UnmarshalJSON decodes a symbol.
+func (v *Symbol) UnmarshalJSON(data []byte) error { return decodeInto(data, v) }
+
+// UnmarshalJSON decodes a list. Lists holding functions must be decoded
+// with DecodeJSON.
+func (v *List) UnmarshalJSON(data []byte) error { return decodeInto(data, v) }
+
+func decodeInto[T Value](data []byte, dst *T) error {
+	v, err := DecodeJSON(data, nil)
+	if err != nil {
+		return err
+	}
+	t, ok := v.(T)
+	if !ok {
+		var zero T
+		return fmt.Errorf("eval: cannot decode %s into %s", v.Kind(), zero.Kind())
+	}
+	*dst = t
+	return nil
+}
+
+func encodeJSON(v Value) ([]byte, error) {
+	var kind string
+	var value any
+	switch v := v.(type) {
+	case Long:
+		kind, value = "long", int64(v)
+	case Float:
+		kind, value = "float", float64(v)
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			value = strconv.FormatFloat(float64(v), 'g', -1, 64)
+		}
+	case Symbol:
+		kind, value = "symbol", string(v)
+	case List:
+		// Encode the items as raw messages so that an item that cannot be
+		// encoded fails the whole list.
+		items := make([]json.RawMessage, len(v))
+		for i, item := range v {
+			b, err := encodeJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = b
+		}
+		kind, value = "list", items
+	case *Function:
+		if v.Source == "" {
+			return nil, errors.New("eval: cannot encode a function without source")
+		}
+		kind, value = "function", v.Source
+	case *Builtin:
+		kind, value = "builtin", v.Name
+	case *Table:
+		cols := make([]json.RawMessage, len(v.cols))
+		for i, c := range v.cols {
+			b, err := encodeJSON(ColumnList(c))
+			if err != nil {
+				return nil, err
+			}
+			cols[i] = b
+		}
+		kind, value = "table", jsonTable{Names: v.names, Columns: cols}
+	default:
+		return nil, fmt.Errorf("eval: cannot encode %T", v)
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(jsonValue{Kind: kind, Value: raw})
+}
+
+// DecodeJSON decodes a value encoded by MarshalJSON. Functions close over
+// env and builtins are looked up in env; decoding either fails if env is
+// nil.
+func DecodeJSON(data []byte, env *Env) (Value, error) {
+	var jv jsonValue
+	if err := json.Unmarshal(data, &jv); err != nil {
+		return nil, err
+	}
+	switch jv.Kind {
+	case "long":
+		var n int64
+		err := json.Unmarshal(jv.Value, &n)
+		return Long(n), err
+	case "float":
+		var f float64
+		if bytes.HasPrefix(jv.Value, []byte(`"`)) {
+			var s string
+			if err := json.Unmarshal(jv.Value, &s); err != nil {
+				return nil, err
+			}
+			var err error
+			if f, err = strconv.ParseFloat(s, 64); err != nil {
+				return nil, fmt.Errorf("eval: invalid float %q", s)
+			}
+			return Float(f), nil
+		}
+		err := json.Unmarshal(jv.Value, &f)
+		return Float(f), err
+	case "symbol":
+		var s string
+		err := json.Unmarshal(jv.Value, &s)
+		return Symbol(s), err
+	case "list":
+		var items []json.RawMessage
+		if err := json.Unmarshal(jv.Value, &items); err != nil {
+			return nil, err
+		}
+		out := make(List, len(items))
+		for i, item := range items {
+			v, err := DecodeJSON(item, env)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	case "table":
+		var jt jsonTable
+		if err := json.Unmarshal(jv.Value, &jt); err != nil {
+			return nil, err
+		}
+		cols := make([]List, len(jt.Columns))
+		for i, c := range jt.Columns {
+			v, err := DecodeJSON(c, env)
+			if err != nil {
+				return nil, err
+			}
+			l, ok := v.(List)
+			if !ok {
+				return nil, fmt.Errorf("eval: table column %d is a %s", i, v.Kind())
+			}
+			cols[i] = l
+		}
+		return tableOf(jt.Names, cols)
+	case "function", "builtin":
+		var s string
+		if err := json.Unmarshal(jv.Value, &s); err != nil {
+			return nil, err
+		}
+		if jv.Kind == "function" {
+			return decodeFunction(s, env)
+		}
+		return decodeBuiltin(s, env)
+	}
+	return nil, fmt.Errorf("eval: unknown value kind %q", jv.Kind)
+}
+
+// EncodeBinary encodes v in the binary encoding.
+func EncodeBinary(v Value) ([]byte, error) {
+	return appendBinary([]byte{binaryVersion}, v)
+}
+
+func appendBinary(b []byte, v Value) ([]byte, error) {
+	switch v := v.(type) {
+	case Long:
+		b = binary.AppendVarint(append(b, tagLong), int64(v))
+	case Float:
+		b = binary.LittleEndian.AppendUint64(append(b, tagFloat), math.Float64bits(float64(v)))
+	case Symbol:
+		b = appendString(append(b, tagSymbol), string(v))
+	case List:
+		b = binary.AppendUvarint(append(b, tagList), uint64(len(v)))
+		for _, item := range v {
+			var err error
+			if b, err = appendBinary(b, item); err != nil {
+				return nil, err
+			}
+		}
+	case *Function:
+		if v.Source == "" {
+			return nil, errors.New("eval: cannot encode a function without source")
+		}
+		b = appendString(append(b, tagFunction), v.Source)
+	case *Builtin:
+		b = appendString(append(b, tagBuiltin), v.Name)
+	case *Table:
+		b = binary.AppendUvarint(append(b, tagTable), uint64(len(v.cols)))
+		for i, c := range v.cols {
+			b = appendString(b, v.names[i])
+			var err error
+			if b, err = appendBinary(b, ColumnList(c)); err != nil {
+				return nil, err
+			}
+		}
+	default:
+		return nil, fmt.Errorf("eval: cannot encode %T", v)
+	}
+	return b, nil
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(binary.AppendUvarint(b, uint64(len(s))), s...)
+}
+
+var errTruncated = errors.New("eval: truncated binary value")
+
+// DecodeBinary decodes a value encoded by EncodeBinary. Functions and
+// builtins are resolved against env as by DecodeJSON.
+func DecodeBinary(data []byte, env *Env) (Value, error) {
+	if len(data) == 0 {
+		return nil, errTruncated
+	}
+	if data[0] != binaryVersion {
+		return nil, fmt.Errorf("eval: unsupported binary version %d", data[0])
+	}
+	d := &decoder{data: data[1:], env: env}
+	v, err := d.value()
+	if err != nil {
+		return nil, err
+	}
+	if len(d.data) > 0 {
+		return nil, fmt.Errorf("eval: %d trailing bytes after binary value", len(d.data))
+	}
+	return v, nil
+}
+
+type decoder struct {
+	data []byte
+	env  *Env
+}
+
+func (d *decoder) value() (Value, error) {
+	if len(d.data) == 0 {
+		return nil, errTruncated
+	}
+	tag := d.data[0]
+	d.data = d.data[1:]
+	switch tag {
+	case tagLong:
+		n, size := binary.Varint(d.data)
+		if size <= 0 {
+			return nil, errTruncated
+		}
+		d.data = d.data[size:]
+		return Long(n), nil
+	case tagFloat:
+		if len(d.data) < 8 {
+			return nil, errTruncated
+		}
+		f := math.Float64frombits(binary.LittleEndian.Uint64(d.data))
+		d.data = d.data[8:]
+		return Float(f), nil
+	case tagList:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		out := make(List, n)
+		for i := range out {
+			if out[i], err = d.value(); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	case tagTable:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, n)
+		lists := make([]List, n)
+		for i := range names {
+			size, err := d.length()
+			if err != nil {
+				return nil, err
+			}
+			names[i] = string(d.data[:size])
+			d.data = d.data[size:]
+			v, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			l, ok := v.(List)
+			if !ok {
+				return nil, fmt.Errorf("eval: table column %d is a %s", i, v.Kind())
+			}
+			lists[i] = l
+		}
+		return tableOf(names, lists)
+	case tagSymbol, tagFunction, tagBuiltin:
+		n, err := d.length()
+		if err != nil {
+			return nil, err
+		}
+		s := string(d.data[:n])
+		d.data = d.data[n:]
+		switch tag {
+		case tagFunction:
+			return decodeFunction(s, d.env)
+		case tagBuiltin:
+			return decodeBuiltin(s, d.env)
+		}
+		return Symbol(s), nil
+	}
+	return nil, fmt.Errorf("eval: unknown binary tag %q", tag)
+}
+
+// tableOf makes a table of decoded columns.
+func tableOf(names []string, lists []List) (*Table, error) {
+	if len(names) != len(lists) {
+		return nil, fmt.Errorf("eval: %d names for %d table columns", len(names), len(lists))
+	}
+	cols := make([]Column, len(lists))
+	for i, l := range lists {
+		c, err := NewColumn(l)
+		if err != nil {
+			return nil, err
+		}
+		cols[i] = c
+	}
+	return NewTable(names, cols)
+}
+
+// length reads a length prefix. Every item or byte it counts takes at
+// least a byte, which bounds it by the remaining data.
+func (d *decoder) length() (int, error) {
+	n, size := binary.Uvarint(d.data)
+	if size <= 0 || n > uint64(len(d.data)-size) {
+		return 0, errTruncated
+	}
+	d.data = d.data[size:]
+	return int(n), nil
+}
+
+// decodeFunction evaluates the text of a function literal in env.
+func decodeFunction(source string, env *Env) (Value, error) {
+	if env == nil {
+		return nil, errors.New("eval: decoding a function needs an environment")
+	}
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	// A function literal parses as the value of an assignment.
+	text := []byte("f:" + source)
+	tree, err := parser.ParseBytes(text)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	a, ok := ast.FromTree(tree.Tree, text).Stmt.(*ast.Assignment)
+	if ok && !tree.RootNode().HasError() {
+		if def, ok := a.Value.(*ast.FunctionDef); ok && def.EndPos().Offset == uint(len(text)) {
+			in := &Interpreter{source: text}
+			return in.function(def, env), nil
+		}
+	}
+	return nil, fmt.Errorf("eval: invalid function source %q", source)
+}
+
+func decodeBuiltin(name string, env *Env) (Value, error) {
+	if env == nil {
+		return nil, errors.New("eval: decoding a builtin needs an environment")
+	}
+	if v, ok := env.Lookup(name); ok {
+		if b, ok := v.(*Builtin); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("eval: unknown builtin %s", name)
+}
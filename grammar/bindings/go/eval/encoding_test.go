@@ -0,0 +1,172 @@
+package eval_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// randomValue returns an atom or, while depth allows, a list of values.
+func randomValue(r *rand.Rand, depth int) eval.Value {
+	switch n := r.Intn(8); {
+	case n < 2:
+		return eval.Long(r.Int63() - r.Int63())
+	case n == 2:
+		return eval.Long([]int64{0, -1, math.MinInt64, math.MaxInt64}[r.Intn(4)])
+	case n == 3:
+		return eval.Float(r.NormFloat64() * math.Pow(10, float64(r.Intn(40)-20)))
+	case n == 4:
+		return eval.Float([]float64{0, math.Copysign(0, -1), math.NaN(), math.Inf(1), math.Inf(-1), math.MaxFloat64}[r.Intn(6)])
+	case n == 5:
+		b := make([]rune, r.Intn(6))
+		for i := range b {
+			b[i] = []rune("ab_\"\\é\u2603\x00")[r.Intn(8)]
+		}
+		return eval.Symbol(b)
+	}
+	if depth == 0 {
+		return eval.Long(r.Intn(10))
+	}
+	l := make(eval.List, r.Intn(5))
+	for i := range l {
+		l[i] = randomValue(r, depth-1)
+	}
+	return l
+}
+
+// same is Equal, except that floats compare by their bits so that NaN and
+// negative zero round-trip exactly, and functions compare by source.
+func same(a, b eval.Value) bool {
+	switch a := a.(type) {
+	case eval.Float:
+		b, ok := b.(eval.Float)
+		return ok && math.Float64bits(float64(a)) == math.Float64bits(float64(b))
+	case eval.List:
+		b, ok := b.(eval.List)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !same(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case *eval.Function:
+		b, ok := b.(*eval.Function)
+		return ok && a.Source == b.Source
+	}
+	return eval.Equal(a, b)
+}
+
+func TestRoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		v := randomValue(r, 3)
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("json.Marshal(%v): %v", v, err)
+		}
+		got, err := eval.DecodeJSON(data, nil)
+		if err != nil || !same(v, got) {
+			t.Fatalf("JSON %s decodes to %v, %v; want %v", data, got, err, v)
+		}
+		data, err = eval.EncodeBinary(v)
+		if err != nil {
+			t.Fatalf("EncodeBinary(%v): %v", v, err)
+		}
+		got, err = eval.DecodeBinary(data, nil)
+		if err != nil || !same(v, got) {
+			t.Fatalf("binary %x decodes to %v, %v; want %v", data, got, err, v)
+		}
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	v := eval.List{eval.Long(1), eval.Float(math.Inf(-1)), eval.Symbol("a")}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"kind":"list","value":[{"kind":"long","value":1},{"kind":"float","value":"-Inf"},{"kind":"symbol","value":"a"}]}`
+	if string(data) != want {
+		t.Errorf("json = %s\nwant %s", data, want)
+	}
+
+	var l eval.List
+	if err := json.Unmarshal(data, &l); err != nil || !same(l, v) {
+		t.Errorf("Unmarshal = %v, %v", l, err)
+	}
+	var n eval.Long
+	if err := json.Unmarshal(data, &n); err == nil {
+		t.Error("decoding a list into a Long succeeded")
+	}
+}
+
+func TestEncodeFunctions(t *testing.T) {
+	in := eval.NewWith(&eval.Builtin{Name: "neg", Params: []string{"x"}, Fn: func(args []eval.Value) (eval.Value, error) {
+		return eval.Negate(args[0])
+	}})
+	if _, err := in.EvalString("k: 10"); err != nil {
+		t.Fatal(err)
+	}
+	fn, err := in.EvalString("f: {[a] neg[a]*k}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	neg, _ := in.Globals.Lookup("neg")
+	v := eval.List{fn, neg, eval.Long(2)}
+
+	decoded := map[string]eval.Value{}
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded["json"], err = eval.DecodeJSON(data, in.Globals); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := eval.DecodeJSON(data, nil); err == nil {
+		t.Error("decoding a function without an environment succeeded")
+	}
+	data, err = eval.EncodeBinary(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded["binary"], err = eval.DecodeBinary(data, in.Globals); err != nil {
+		t.Fatal(err)
+	}
+	for name, got := range decoded {
+		l := got.(eval.List)
+		if l[1] != neg || !same(l[0], fn) {
+			t.Errorf("%s: decoded %v", name, l)
+		}
+		res, err := in.Apply(l[0], []eval.Value{eval.Long(3)})
+		if err != nil || res != eval.Long(-30) {
+			t.Errorf("%s: f[3] = %v, %v", name, res, err)
+		}
+	}
+
+	if _, err := eval.EncodeBinary(&eval.Function{Params: []string{"x"}}); err == nil {
+		t.Error("encoding a function without source succeeded")
+	}
+}
+
+func TestDecodeBinaryErrors(t *testing.T) {
+	for _, data := range [][]byte{
+		nil,
+		{2, 'l', 0},
+		{1, 'l'},
+		{1, 'f', 0, 0},
+		{1, 's', 5, 'a'},
+		{1, 'L', 200, 1},
+		{1, 'l', 0, 0},
+		{1, '?'},
+	} {
+		if v, err := eval.DecodeBinary(data, nil); err == nil {
+			t.Errorf("DecodeBinary(%v) = %v, want an error", data, v)
+		}
+	}
+}
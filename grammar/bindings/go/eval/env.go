@@ -0,0 +1,43 @@
+package eval
+
+import "sort"
+
+// Env is a lexical environment: a set of bindings with an optional parent
+// scope. Lookups walk outwards through parents.
+type Env struct {
+	vars   map[string]Value
+	parent *Env
+}
+
+// NewEnv returns an empty environment nested in parent, which may be nil.
+func NewEnv(parent *Env) *Env {
+	return &Env{vars: map[string]Value{}, parent: parent}
+}
+
+// Define binds name in this scope, shadowing any outer binding.
+func (e *Env) Define(name string, v Value) {
+	e.vars[name] = v
+}
+
+// Lookup finds name in this scope or an enclosing one.
+func (e *Env) Lookup(name string) (Value, bool) {
+	for s := e; s != nil; s = s.parent {
+		if v, ok := s.vars[name]; ok {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// Parent returns the enclosing scope, or nil for a global scope.
+func (e *Env) Parent() *Env { return e.parent }
+
+// Names returns the names bound directly in this scope, sorted.
+func (e *Env) Names() []string {
+	names := make([]string, 0, len(e.vars))
+	for name := range e.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
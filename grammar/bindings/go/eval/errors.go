@@ -0,0 +1,67 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+// Error codes. Where an equivalent exists they match the codes reported by
+// the Rust evaluator.
+const (
+	CodeDivisionByZero      = "DIVISION_BY_ZERO"
+	CodeIntegerOverflow     = "INTEGER_OVERFLOW"
+	CodeNegativeExponent    = "NEGATIVE_EXPONENT"
+	CodeExponentTooLarge    = "EXPONENT_TOO_LARGE"
+	CodeFactorialOfNegative = "FACTORIAL_OF_NEGATIVE"
+	CodeFactorialTooLarge   = "FACTORIAL_TOO_LARGE"
+	CodeInvalidNumber       = "INVALID_NUMBER"
+	CodeUnknownOperator     = "UNKNOWN_OPERATOR"
+	CodeMissingOperand      = "MISSING_OPERAND"
+	CodeUndefined           = "UNDEFINED_VARIABLE"
+	CodeType                = "TYPE_ERROR"
+	CodeArity               = "ARITY_MISMATCH"
+	CodeLength              = "LENGTH_MISMATCH"
+	CodeSyntax              = "SYNTAX_ERROR"
+)
+
+// Error is an evaluation failure located at a span of the source.
+type Error struct {
+	Code    string
+	Message string
+	Span    ast.Span
+}
+
+func (e *Error) Error() string {
+	if e.Span == (ast.Span{}) {
+		return e.Message
+	}
+	return fmt.Sprintf("%d:%d: %s", e.Span.Start.Row+1, e.Span.Start.Column+1, e.Message)
+}
+
+func errorf(n ast.Node, code, format string, args ...any) *Error {
+	e := &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+	if n != nil {
+		e.Span = ast.Span{Start: n.Pos(), End: n.EndPos()}
+	}
+	return e
+}
+
+// at fills in the span of err if it has none, so errors raised by value
+// helpers are attributed to the expression that triggered them.
+func at(n ast.Node, err error) error {
+	if n == nil {
+		return err
+	}
+	switch e := err.(type) {
+	case *Error:
+		if e.Span == (ast.Span{}) {
+			e.Span = ast.Span{Start: n.Pos(), End: n.EndPos()}
+		}
+	case *LimitExceededError:
+		if e.Span == (ast.Span{}) {
+			e.Span = ast.Span{Start: n.Pos(), End: n.EndPos()}
+		}
+	}
+	return err
+}
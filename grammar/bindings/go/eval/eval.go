@@ -0,0 +1,339 @@
+// Package eval is a tree-walking interpreter for wabznasm. It evaluates the
+// typed AST produced by package ast.
+//
+// Functions declared without a parameter list take the implicit parameters
+// x, y and z, q style: a body mentioning y takes two arguments, one
+// mentioning z takes three. Any other free name resolves through the
+// environment the function was defined in.
+package eval
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+)
+
+// Interpreter evaluates statements against a persistent global environment.
+// An Interpreter is not safe for concurrent use, though it evaluates on
+// several goroutines itself for peach and EvalParallel.
+type Interpreter struct {
+	// Globals holds assignments made by evaluated statements.
+	Globals *Env
+	// Limits caps the resources of each evaluation.
+	Limits Limits
+	// Hook, if set, is called before each expression is evaluated, with
+	// the environment it is evaluated in. An error it returns stops the
+	// evaluation. Debuggers use it to pause between steps.
+	Hook func(e ast.Expr, env *Env) error
+	// Done, if set, is called after each expression is evaluated, with
+	// its value or error. Profilers use it with Hook to time expressions.
+	Done func(e ast.Expr, v Value, err error)
+	// Tracer, if set, is told of each function call, operator and
+	// assignment.
+	Tracer Tracer
+	// Workers bounds the goroutines that peach and EvalParallel evaluate
+	// on. Zero means GOMAXPROCS; one evaluates on the calling goroutine.
+	Workers int
+	source  []byte
+	budget  *budget
+	frames  []Frame
+}
+
+// Frame is a call of a function in progress.
+type Frame struct {
+	// Call is the call expression, or nil for a function applied by the
+	// host through Apply.
+	Call     *ast.Call
+	Function *Function
+	// Env binds the parameters of the call.
+	Env *Env
+}
+
+// Frames returns the calls of functions in progress, outermost first.
+// Calls of builtins are not included.
+func (in *Interpreter) Frames() []Frame {
+	return append([]Frame(nil), in.frames...)
+}
+
+// New returns an interpreter with an empty global environment, enclosed
+// in a scope binding the registered builtins.
+func New() *Interpreter {
+	return NewWith(Builtins()...)
+}
+
+// NewWith returns an interpreter offering only the given builtins, such as
+// a sandbox without the builtins registered by the host.
+func NewWith(builtins ...*Builtin) *Interpreter {
+	return &Interpreter{Globals: NewEnv(BuiltinEnv(builtins...))}
+}
+
+// EvalString parses and evaluates src.
+func (in *Interpreter) EvalString(src string) (Value, error) {
+	return in.EvalStringContext(context.Background(), src)
+}
+
+// EvalStringContext is like EvalString, stopping with a
+// *LimitExceededError when ctx is done.
+func (in *Interpreter) EvalStringContext(ctx context.Context, src string) (Value, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	return in.EvalTreeContext(ctx, tree.Tree, []byte(src))
+}
+
+// EvalTree evaluates a parse tree of source. Trees containing syntax errors
+// are rejected with a CodeSyntax error located at the first error. A source
+// with no statement evaluates to a nil Value.
+func (in *Interpreter) EvalTree(tree *tree_sitter.Tree, source []byte) (Value, error) {
+	return in.EvalTreeContext(context.Background(), tree, source)
+}
+
+// EvalTreeContext is like EvalTree, stopping with a *LimitExceededError
+// when ctx is done.
+func (in *Interpreter) EvalTreeContext(ctx context.Context, tree *tree_sitter.Tree, source []byte) (Value, error) {
+	if tree.RootNode().HasError() {
+		return nil, SyntaxError(tree, source)
+	}
+	return in.EvalFileContext(ctx, ast.FromTree(tree, source), source)
+}
+
+// EvalFile evaluates a converted file. Source is used to record the text of
+// function literals and may be nil.
+func (in *Interpreter) EvalFile(f *ast.File, source []byte) (Value, error) {
+	return in.EvalFileContext(context.Background(), f, source)
+}
+
+// EvalFileContext is like EvalFile, stopping with a *LimitExceededError
+// when ctx is done. The evaluation is timed as an "eval" span of ctx.
+func (in *Interpreter) EvalFileContext(ctx context.Context, f *ast.File, source []byte) (v Value, err error) {
+	if f.Stmt == nil {
+		return nil, nil
+	}
+	ctx, span := telemetry.Start(ctx, "eval", slog.Int("bytes", len(source)))
+	defer func() { span.End(err) }()
+	defer in.begin(ctx)()
+	in.source = source
+	defer func() { in.source = nil }()
+	return in.evalStmt(f.Stmt)
+}
+
+func (in *Interpreter) evalStmt(s ast.Stmt) (Value, error) {
+	name, e, ok := statement(s)
+	if !ok {
+		return nil, errorf(s, CodeSyntax, "syntax error")
+	}
+	v, err := in.Eval(e, in.Globals)
+	if err != nil || name == "" {
+		return v, err
+	}
+	in.assign(name, v)
+	return v, nil
+}
+
+// statement returns the expression s evaluates and the name it assigns,
+// if any; ok is false for a statement that is not supported.
+func statement(s ast.Stmt) (name string, e ast.Expr, ok bool) {
+	switch s := s.(type) {
+	case *ast.Assignment:
+		return s.Name.Name, s.Value, true
+	case *ast.ExprStmt:
+		return "", s.X, true
+	}
+	return "", nil, false
+}
+
+func (in *Interpreter) assign(name string, v Value) {
+	in.Globals.Define(name, v)
+	if in.Tracer != nil {
+		in.Tracer.Assign(name, v)
+	}
+}
+
+// Eval evaluates an expression in env.
+func (in *Interpreter) Eval(e ast.Expr, env *Env) (Value, error) {
+	if in.budget == nil {
+		defer in.begin(context.Background())()
+	}
+	if err := in.budget.step(e); err != nil {
+		return nil, err
+	}
+	if in.Hook != nil && e != nil {
+		if err := in.Hook(e, env); err != nil {
+			return nil, err
+		}
+	}
+	if in.Done != nil && e != nil {
+		v, err := in.eval(e, env)
+		in.Done(e, v, err)
+		return v, err
+	}
+	return in.eval(e, env)
+}
+
+func (in *Interpreter) eval(e ast.Expr, env *Env) (Value, error) {
+	switch e := e.(type) {
+	case nil:
+		return nil, &Error{Code: CodeMissingOperand, Message: "missing operand"}
+	case *ast.NumberLiteral:
+		if e.Err != nil {
+			return nil, errorf(e, CodeInvalidNumber, "invalid number %s", e.Text)
+		}
+		return in.alloc(e, Long(e.Value), nil)
+	case *ast.Ident:
+		if v, ok := env.Lookup(e.Name); ok {
+			return v, nil
+		}
+		return nil, errorf(e, CodeUndefined, "undefined variable: %s", e.Name)
+	case *ast.ParenExpr:
+		return in.Eval(e.X, env)
+	case *ast.UnaryExpr:
+		v, err := in.Eval(e.Operand, env)
+		if err != nil {
+			return nil, at(e, err)
+		}
+		r, err := Negate(v)
+		in.traceOperator(e, e.Op, []Value{v}, r, err)
+		return in.alloc(e, r, err)
+	case *ast.PostfixExpr:
+		v, err := in.Eval(e.Operand, env)
+		if err != nil {
+			return nil, at(e, err)
+		}
+		r, err := Factorial(v)
+		in.traceOperator(e, e.Op, []Value{v}, r, err)
+		return in.alloc(e, r, err)
+	case *ast.BinaryExpr:
+		l, err := in.Eval(e.Left, env)
+		if err != nil {
+			return nil, at(e, err)
+		}
+		r, err := in.Eval(e.Right, env)
+		if err != nil {
+			return nil, at(e, err)
+		}
+		v, err := Binary(e.Op, l, r)
+		in.traceOperator(e, e.Op, []Value{l, r}, v, err)
+		return in.alloc(e, v, err)
+	case *ast.FunctionDef:
+		return in.alloc(e, in.function(e, env), nil)
+	case *ast.Call:
+		v, err := in.call(e, env)
+		return in.alloc(e, v, err)
+	case *ast.BadExpr:
+		return nil, errorf(e, CodeSyntax, "syntax error")
+	}
+	return nil, errorf(e, CodeSyntax, "unsupported expression %T", e)
+}
+
+func (in *Interpreter) function(e *ast.FunctionDef, env *Env) *Function {
+	fn := &Function{Body: e.Body, Closure: env}
+	fn.Params = e.Signature()
+	fn.Implicit = e.Implicit()
+	if end := e.EndPos().Offset; in.source != nil && end <= uint(len(in.source)) {
+		fn.Source = string(in.source[e.Pos().Offset:end])
+	}
+	return fn
+}
+
+func (in *Interpreter) call(e *ast.Call, env *Env) (Value, error) {
+	callee, err := in.Eval(e.Func, env)
+	if err != nil {
+		return nil, err
+	}
+	args := make([]Value, len(e.Args))
+	for i, arg := range e.Args {
+		if args[i], err = in.Eval(arg, env); err != nil {
+			return nil, err
+		}
+	}
+	v, err := in.apply(e, callee, args)
+	return v, at(e, err)
+}
+
+// Apply calls fn with args. Each call counts towards Limits.MaxDepth.
+func (in *Interpreter) Apply(fn Value, args []Value) (Value, error) {
+	if in.budget == nil {
+		defer in.begin(context.Background())()
+	}
+	return in.apply(nil, fn, args)
+}
+
+func (in *Interpreter) traceOperator(e ast.Expr, op string, operands []Value, v Value, err error) {
+	if in.Tracer != nil {
+		in.Tracer.Operator(e, op, operands, v, err)
+	}
+}
+
+// apply calls fn with args on behalf of call, which may be nil.
+func (in *Interpreter) apply(call *ast.Call, fn Value, args []Value) (v Value, err error) {
+	if err := in.budget.enter(); err != nil {
+		return nil, err
+	}
+	defer in.budget.leave()
+	if t := in.Tracer; t != nil {
+		t.Enter(call, fn, args)
+		defer func() { t.Exit(call, fn, v, err) }()
+	}
+	if c, ok := fn.(Callable); ok {
+		if len(args) != c.Arity() {
+			return nil, &Error{Code: CodeArity, Message: ArityMessage(c.Arity(), len(args))}
+		}
+		if c == Callable(Peach) {
+			return in.peach(call, args[0], args[1])
+		}
+		return c.Call(args)
+	}
+	f, ok := fn.(*Function)
+	if !ok {
+		return nil, &Error{Code: CodeType, Message: "cannot call " + fn.Kind().String()}
+	}
+	if len(args) != len(f.Params) {
+		return nil, &Error{Code: CodeArity, Message: ArityMessage(len(f.Params), len(args))}
+	}
+	local := NewEnv(f.Closure)
+	for i, name := range f.Params {
+		local.Define(name, args[i])
+	}
+	in.frames = append(in.frames, Frame{Call: call, Function: f, Env: local})
+	defer func() { in.frames = in.frames[:len(in.frames)-1] }()
+	return in.Eval(f.Body, local)
+}
+
+// ArityMessage describes a call with got arguments to a function taking
+// want.
+func ArityMessage(want, got int) string {
+	noun := "arguments"
+	if want == 1 {
+		noun = "argument"
+	}
+	return fmt.Sprintf("arity mismatch: expected %d %s, got %d", want, noun, got)
+}
+
+// SyntaxError returns a CodeSyntax error located at the first syntax error
+// of tree.
+func SyntaxError(tree *tree_sitter.Tree, source []byte) *Error {
+	e := &Error{Code: CodeSyntax, Message: "syntax error"}
+	diags := tree_sitter_wabznasm.Diagnostics(tree, source)
+	if len(diags) == 0 {
+		return e
+	}
+	r := diags[0].Range
+	e.Message = diags[0].Message
+	e.Span = ast.Span{
+		Start: ast.Pos{Offset: r.StartByte, Row: r.StartPoint.Row, Column: r.StartPoint.Column},
+		End:   ast.Pos{Offset: r.EndByte, Row: r.EndPoint.Row, Column: r.EndPoint.Column},
+	}
+	return e
+}
@@ -0,0 +1,198 @@
+package eval_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func TestArithmetic(t *testing.T) {
+	for src, want := range map[string]string{
+		"1+2*3":      "7",
+		"2^3":        "8",
+		"2^3^2":      "512",
+		"5!":         "120",
+		"-(2+3)":     "-5",
+		"10/3":       "3",
+		"-7%3":       "-1",
+		"2*-3^2":     "-18",
+		"3! \\ note": "6",
+	} {
+		in := eval.New()
+		v, err := in.EvalString(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if v.String() != want {
+			t.Errorf("%s = %s, want %s", src, v, want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	for src, code := range map[string]string{
+		"1/0":                   eval.CodeDivisionByZero,
+		"9223372036854775807+1": eval.CodeIntegerOverflow,
+		"2^-1":                  eval.CodeNegativeExponent,
+		"2^64":                  eval.CodeExponentTooLarge,
+		"21!":                   eval.CodeFactorialTooLarge,
+		"(-1)!":                 eval.CodeFactorialOfNegative,
+		"nope+1":                eval.CodeUndefined,
+		"1+":                    eval.CodeSyntax,
+		"99999999999999999999":  eval.CodeInvalidNumber,
+	} {
+		_, err := eval.New().EvalString(src)
+		var e *eval.Error
+		if !errors.As(err, &e) || e.Code != code {
+			t.Errorf("%s: err = %v, want code %s", src, err, code)
+		}
+	}
+}
+
+func TestErrorSpan(t *testing.T) {
+	_, err := eval.New().EvalString("1+(2/0)")
+	var e *eval.Error
+	if !errors.As(err, &e) {
+		t.Fatalf("err = %v", err)
+	}
+	if e.Span.Start.Offset != 3 || e.Span.End.Offset != 6 {
+		t.Errorf("span = %+v, want 3..6", e.Span)
+	}
+}
+
+func TestFunctionsAndGlobals(t *testing.T) {
+	in := eval.New()
+	steps := []struct{ src, want string }{
+		{"base: 10", "10"},
+		{"add: {[a;b] a+b}", "{[a;b] a+b}"},
+		{"add[2;3]", "5"},
+		{"f: {x+y*base}", "{x+y*base}"},
+		{"f[1;2]", "21"},
+		{"g: {7}", "{7}"},
+		{"g[]", "7"},
+		{"base: 1", "1"},
+		{"f[1;2]", "3"},
+	}
+	for _, s := range steps {
+		v, err := in.EvalString(s.src)
+		if err != nil {
+			t.Fatalf("%s: %v", s.src, err)
+		}
+		if v.String() != s.want {
+			t.Errorf("%s = %s, want %s", s.src, v, s.want)
+		}
+	}
+	fn, _ := in.Globals.Lookup("f")
+	if f := fn.(*eval.Function); !f.Implicit || f.Arity() != 2 {
+		t.Errorf("f = %+v", f)
+	}
+
+	_, err := in.EvalString("add[1]")
+	var e *eval.Error
+	if !errors.As(err, &e) || e.Code != eval.CodeArity {
+		t.Errorf("add[1]: err = %v", err)
+	}
+	_, err = in.EvalString("base[1]")
+	if !errors.As(err, &e) || e.Code != eval.CodeType {
+		t.Errorf("base[1]: err = %v", err)
+	}
+}
+
+func TestValues(t *testing.T) {
+	in := eval.New()
+	in.Globals.Define("v", eval.List{eval.Long(1), eval.Long(2), eval.Long(3)})
+	in.Globals.Define("h", eval.Float(0.5))
+	in.Globals.Define("s", eval.Symbol("abc"))
+
+	for src, want := range map[string]string{
+		"v*2":   "2 4 6",
+		"v+v":   "2 4 6",
+		"h*4":   "2f",
+		"1+h":   "1.5",
+		"-v":    "-1 -2 -3",
+		"s":     "`abc",
+		"h*3%2": "1.5",
+	} {
+		v, err := in.EvalString(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if v.String() != want {
+			t.Errorf("%s = %s, want %s", src, v, want)
+		}
+	}
+	if _, err := in.EvalString("s+1"); err == nil {
+		t.Errorf("expected type error for symbol arithmetic")
+	}
+
+	if c, err := eval.Compare(eval.Long(2), eval.Float(2.5)); err != nil || c != -1 {
+		t.Errorf("Compare = %d, %v", c, err)
+	}
+	if !eval.Equal(eval.List{eval.Long(1)}, eval.List{eval.Long(1)}) {
+		t.Errorf("equal lists compare unequal")
+	}
+}
+
+func TestHookAndFrames(t *testing.T) {
+	in := eval.New()
+	if _, err := in.EvalString("f: {[a] a*2}"); err != nil {
+		t.Fatal(err)
+	}
+	var steps []string
+	depth := map[string]int{}
+	in.Hook = func(e ast.Expr, env *eval.Env) error {
+		name := fmt.Sprintf("%T", e)
+		steps = append(steps, name)
+		if frames := in.Frames(); len(frames) > 0 {
+			if v, ok := frames[len(frames)-1].Env.Lookup("a"); !ok || v.String() != "3" {
+				t.Errorf("frame binds a = %v", v)
+			}
+			depth[name] = len(frames)
+		}
+		return nil
+	}
+	if _, err := in.EvalString("1+f[3]"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"*ast.BinaryExpr", "*ast.NumberLiteral", "*ast.Call", "*ast.Ident", "*ast.NumberLiteral", "*ast.BinaryExpr", "*ast.Ident", "*ast.NumberLiteral"}
+	if !slices.Equal(steps, want) {
+		t.Errorf("steps = %v, want %v", steps, want)
+	}
+	if depth["*ast.Ident"] != 1 || len(in.Frames()) != 0 {
+		t.Errorf("depth = %v, frames left = %d", depth, len(in.Frames()))
+	}
+
+	stop := errors.New("stop")
+	in.Hook = func(ast.Expr, *eval.Env) error { return stop }
+	if _, err := in.EvalString("f[1]"); !errors.Is(err, stop) {
+		t.Errorf("err = %v, want the hook's error", err)
+	}
+}
+
+func TestDone(t *testing.T) {
+	in := eval.New()
+	var depth int
+	var done []string
+	in.Hook = func(ast.Expr, *eval.Env) error { depth++; return nil }
+	in.Done = func(e ast.Expr, v eval.Value, err error) {
+		depth--
+		done = append(done, fmt.Sprintf("%T=%v/%v", e, v, err != nil))
+	}
+	in.EvalString("2*(1%0)")
+	want := []string{"*ast.NumberLiteral=2/false", "*ast.NumberLiteral=1/false", "*ast.NumberLiteral=0/false", "*ast.BinaryExpr=<nil>/true", "*ast.ParenExpr=<nil>/true", "*ast.BinaryExpr=<nil>/true"}
+	if !slices.Equal(done, want) || depth != 0 {
+		t.Errorf("done = %v, depth %d", done, depth)
+	}
+}
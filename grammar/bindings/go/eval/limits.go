@@ -0,0 +1,132 @@
+package eval
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+// Limits caps the resources an evaluation may use, for running untrusted
+// sources. A zero field means no limit. Each call of EvalFile, or of Eval
+// or Apply outside an evaluation, starts with a fresh budget.
+type Limits struct {
+	// MaxSteps is the number of expressions evaluated.
+	MaxSteps int64
+	// MaxDepth is the number of nested function calls.
+	MaxDepth int
+	// MaxCells is the number of values produced by expressions, a list
+	// counting one cell per item.
+	MaxCells int64
+}
+
+// Limit names. The deadline limit is reported when the context of an
+// evaluation is done.
+const (
+	LimitSteps    = "steps"
+	LimitDepth    = "depth"
+	LimitCells    = "cells"
+	LimitDeadline = "deadline"
+)
+
+// LimitExceededError reports an evaluation stopped by its Limits or its
+// context.
+type LimitExceededError struct {
+	// Limit is one of the Limit names.
+	Limit string
+	// Max is the exceeded limit; it is zero for LimitDeadline.
+	Max int64
+	// Span locates the expression being evaluated when the limit was hit.
+	Span ast.Span
+	// Err is the context's error for LimitDeadline.
+	Err error
+}
+
+func (e *LimitExceededError) Error() string {
+	msg := fmt.Sprintf("evaluation exceeded max %s (%d)", e.Limit, e.Max)
+	if e.Limit == LimitDeadline {
+		msg = "evaluation stopped: " + e.Err.Error()
+	}
+	if e.Span == (ast.Span{}) {
+		return msg
+	}
+	return fmt.Sprintf("%d:%d: %s", e.Span.Start.Row+1, e.Span.Start.Column+1, msg)
+}
+
+// Unwrap returns the context's error, so that errors.Is matches
+// context.DeadlineExceeded and context.Canceled.
+func (e *LimitExceededError) Unwrap() error { return e.Err }
+
+// checkEvery is how many steps pass between checks of the context.
+const checkEvery = 1024
+
+// budget tracks the resources used by the evaluation in progress.
+type budget struct {
+	ctx    context.Context
+	limits Limits
+	steps  int64
+	depth  int
+	cells  int64
+}
+
+var noop = func() {}
+
+// begin starts a budget unless an evaluation is already in progress, and
+// returns the function ending it.
+func (in *Interpreter) begin(ctx context.Context) func() {
+	if in.budget != nil {
+		return noop
+	}
+	in.budget = &budget{ctx: ctx, limits: in.Limits}
+	return func() { in.budget = nil }
+}
+
+func (b *budget) step(e ast.Expr) error {
+	b.steps++
+	if max := b.limits.MaxSteps; max > 0 && b.steps > max {
+		return b.exceeded(e, LimitSteps, max)
+	}
+	if b.steps%checkEvery == 0 {
+		return b.done(e)
+	}
+	return nil
+}
+
+// done reports whether the context has ended.
+func (b *budget) done(e ast.Expr) error {
+	if err := b.ctx.Err(); err != nil {
+		le := &LimitExceededError{Limit: LimitDeadline, Err: err}
+		return at(e, le)
+	}
+	return nil
+}
+
+func (b *budget) enter() error {
+	b.depth++
+	if max := b.limits.MaxDepth; max > 0 && b.depth > max {
+		return &LimitExceededError{Limit: LimitDepth, Max: int64(max)}
+	}
+	return b.done(nil)
+}
+
+func (b *budget) leave() { b.depth-- }
+
+// alloc counts the cells of v, the value of e, once it is produced.
+func (in *Interpreter) alloc(e ast.Expr, v Value, err error) (Value, error) {
+	if err != nil {
+		return v, at(e, err)
+	}
+	b := in.budget
+	b.cells++
+	if l, ok := v.(List); ok {
+		b.cells += int64(len(l)) - 1
+	}
+	if max := b.limits.MaxCells; max > 0 && b.cells > max {
+		return nil, b.exceeded(e, LimitCells, max)
+	}
+	return v, nil
+}
+
+func (b *budget) exceeded(e ast.Expr, limit string, max int64) error {
+	return at(e, &LimitExceededError{Limit: limit, Max: max})
+}
@@ -0,0 +1,76 @@
+package eval_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+func limitError(t *testing.T, err error, limit string) *eval.LimitExceededError {
+	t.Helper()
+	var le *eval.LimitExceededError
+	if !errors.As(err, &le) {
+		t.Fatalf("error = %v, want a LimitExceededError", err)
+	}
+	if le.Limit != limit {
+		t.Fatalf("limit = %s, want %s (%v)", le.Limit, limit, err)
+	}
+	return le
+}
+
+func TestLimits(t *testing.T) {
+	iota := &eval.Builtin{Name: "iota", Params: []string{"n"}, Fn: func(args []eval.Value) (eval.Value, error) {
+		out := make(eval.List, args[0].(eval.Long))
+		for i := range out {
+			out[i] = eval.Long(i)
+		}
+		return out, nil
+	}}
+	in := eval.NewWith(iota)
+	in.Limits = eval.Limits{MaxSteps: 100, MaxDepth: 8, MaxCells: 500}
+
+	_, err := in.EvalString(strings.Repeat("1+", 60) + "1")
+	le := limitError(t, err, eval.LimitSteps)
+	if le.Max != 100 || !strings.HasSuffix(err.Error(), ": evaluation exceeded max steps (100)") {
+		t.Errorf("err = %v", err)
+	}
+	// Every statement starts with a fresh budget.
+	if v, err := in.EvalString("1+2+3"); err != nil || v.String() != "6" {
+		t.Errorf("1+2+3 = %v, %v", v, err)
+	}
+
+	if _, err := in.EvalString("f: {f[x]}"); err != nil {
+		t.Fatal(err)
+	}
+	_, err = in.EvalString("f[1]")
+	le = limitError(t, err, eval.LimitDepth)
+	if le.Span.Start.Offset != 4 || le.Span.End.Offset != 8 {
+		t.Errorf("depth span = %v, want the recursive call", le.Span)
+	}
+
+	if _, err := in.EvalString("iota[10]"); err != nil {
+		t.Errorf("iota[10]: %v", err)
+	}
+	_, err = in.EvalString("iota[1000]")
+	limitError(t, err, eval.LimitCells)
+}
+
+func TestContext(t *testing.T) {
+	in := eval.New()
+	if _, err := in.EvalString("f: {f[x]}"); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := in.EvalStringContext(ctx, "f[1]")
+	limitError(t, err, eval.LimitDeadline)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+	if v, err := in.EvalStringContext(context.Background(), "2*3"); err != nil || v.String() != "6" {
+		t.Errorf("2*3 = %v, %v", v, err)
+	}
+}
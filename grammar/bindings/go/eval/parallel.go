@@ -0,0 +1,194 @@
+package eval
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+// Evaluating in parallel relies on evaluation only reading the globals:
+// expressions never assign, so goroutines can share an Interpreter's
+// Globals as long as nothing assigns while they run. Each goroutine
+// evaluates with a fork of the interpreter, holding its own budget and
+// frames. Hook, Done and Tracer are called from one goroutine, in order,
+// so an interpreter with any of them set evaluates on the calling
+// goroutine instead. Builtins called by parallel evaluations must be
+// safe for concurrent use.
+
+// Peach is the builtin peach[f;l], which applies f to each item of the
+// list l on up to Workers goroutines and returns the list of results, in
+// the order of l. If applications fail, the error is that of the first
+// failing item, as if the items had been applied in turn; items after it
+// that have not started are skipped. It is not bound by default: hosts
+// offer it with the builtins they give NewWith, or Register it.
+//
+// An application counts towards the Limits of the evaluation calling
+// peach. MaxDepth applies to each application; MaxSteps and MaxCells
+// apply to each application as if it ran alone, and to their total once
+// all have returned.
+var Peach = &Builtin{Name: "peach", Params: []string{"f", "l"}, Fn: func([]Value) (Value, error) {
+	return nil, &Error{Code: CodeBuiltin, Message: "peach: must be called by an interpreter"}
+}}
+
+// workers returns the number of goroutines to evaluate n tasks on.
+func (in *Interpreter) workers(n int) int {
+	if in.Hook != nil || in.Done != nil || in.Tracer != nil {
+		return 1
+	}
+	w := in.Workers
+	if w <= 0 {
+		w = runtime.GOMAXPROCS(0)
+	}
+	return min(w, n)
+}
+
+// fork returns an interpreter sharing the globals and settings of in, for
+// evaluating on another goroutine.
+func (in *Interpreter) fork() *Interpreter {
+	return &Interpreter{Globals: in.Globals, Limits: in.Limits, Workers: in.Workers, source: in.source}
+}
+
+// run calls task with the indices 0 to n-1 on up to w goroutines, each
+// with its own fork of in, in increasing order of index. It stops starting
+// tasks after one returns false. It returns the forks.
+func (in *Interpreter) run(n, w int, task func(f *Interpreter, i int) bool) []*Interpreter {
+	var next atomic.Int64
+	var stop atomic.Bool
+	forks := make([]*Interpreter, w)
+	var wg sync.WaitGroup
+	for k := range forks {
+		f := in.fork()
+		forks[k] = f
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !stop.Load() {
+				i := int(next.Add(1) - 1)
+				if i >= n {
+					return
+				}
+				if !task(f, i) {
+					stop.Store(true)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return forks
+}
+
+func (in *Interpreter) peach(call *ast.Call, fn, arg Value) (Value, error) {
+	l, ok := arg.(List)
+	if !ok {
+		return nil, &Error{Code: CodeType, Message: "peach: want a list, got " + arg.Kind().String()}
+	}
+	out := make(List, len(l))
+	w := in.workers(len(l))
+	if w <= 1 {
+		for i, item := range l {
+			v, err := in.apply(call, fn, []Value{item})
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+
+	errs := make([]error, len(l))
+	var failed atomic.Int64
+	failed.Store(int64(len(l)))
+	b := in.budget
+	forks := in.run(len(l), w, func(f *Interpreter, i int) bool {
+		if f.budget == nil {
+			f.budget = &budget{ctx: b.ctx, limits: b.limits, steps: b.steps, depth: b.depth, cells: b.cells}
+		}
+		if int64(i) > failed.Load() {
+			return false
+		}
+		out[i], errs[i] = f.apply(call, fn, []Value{l[i]})
+		if errs[i] == nil {
+			return true
+		}
+		for {
+			first := failed.Load()
+			if int64(i) >= first || failed.CompareAndSwap(first, int64(i)) {
+				return false
+			}
+		}
+	})
+	// Items before the first failure all started, so erring items after
+	// it no longer matter.
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	steps, cells := b.steps, b.cells
+	for _, f := range forks {
+		if f.budget != nil {
+			b.steps += f.budget.steps - steps
+			b.cells += f.budget.cells - cells
+		}
+	}
+	if max := b.limits.MaxSteps; max > 0 && b.steps > max {
+		return nil, b.exceeded(call, LimitSteps, max)
+	}
+	if max := b.limits.MaxCells; max > 0 && b.cells > max {
+		return nil, b.exceeded(call, LimitCells, max)
+	}
+	return out, nil
+}
+
+// EvalParallel evaluates statements that are independent of each other,
+// such as a stage of depgraph.Schedule, on up to Workers goroutines, and
+// returns the value and error of each in the order of files. Statements
+// are independent when none assigns a name another uses or assigns.
+// Their assignments are made once all have been evaluated, in order, so
+// that the outcome is that of evaluating them in turn with EvalFileContext,
+// each with its own budget. Sources are as for EvalFile, one for each
+// file; sources may be nil.
+func (in *Interpreter) EvalParallel(ctx context.Context, files []*ast.File, sources [][]byte) ([]Value, []error) {
+	values := make([]Value, len(files))
+	errs := make([]error, len(files))
+	source := func(i int) []byte {
+		if sources == nil {
+			return nil
+		}
+		return sources[i]
+	}
+	w := in.workers(len(files))
+	if w <= 1 {
+		for i, f := range files {
+			values[i], errs[i] = in.EvalFileContext(ctx, f, source(i))
+		}
+		return values, errs
+	}
+	names := make([]string, len(files))
+	in.run(len(files), w, func(f *Interpreter, i int) bool {
+		if files[i].Stmt == nil {
+			return true
+		}
+		name, e, ok := statement(files[i].Stmt)
+		if !ok {
+			errs[i] = errorf(files[i].Stmt, CodeSyntax, "syntax error")
+			return true
+		}
+		end := f.begin(ctx)
+		f.source = source(i)
+		values[i], errs[i] = f.Eval(e, f.Globals)
+		f.source = nil
+		end()
+		names[i] = name
+		return true
+	})
+	for i, name := range names {
+		if name != "" && errs[i] == nil {
+			in.assign(name, values[i])
+		}
+	}
+	return values, errs
+}
@@ -0,0 +1,151 @@
+package eval_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+func longs(n int) eval.List {
+	out := make(eval.List, n)
+	for i := range out {
+		out[i] = eval.Long(i)
+	}
+	return out
+}
+
+func TestPeach(t *testing.T) {
+	var calls atomic.Int64
+	count := &eval.Builtin{Name: "count", Params: []string{"x"}, Fn: func(args []eval.Value) (eval.Value, error) {
+		calls.Add(1)
+		return args[0], nil
+	}}
+	for _, workers := range []int{0, 1, 4} {
+		in := eval.NewWith(eval.Peach, count)
+		in.Workers = workers
+		in.Globals.Define("l", longs(100))
+		if _, err := in.EvalString("sq: {[n] count[n*n]}"); err != nil {
+			t.Fatal(err)
+		}
+		v, err := in.EvalString("peach[sq; l]")
+		if err != nil {
+			t.Fatalf("workers %d: %v", workers, err)
+		}
+		want, _ := eval.Binary("*", longs(100), longs(100))
+		if !eval.Equal(v, want) {
+			t.Errorf("workers %d: peach[sq; l] = %v", workers, v)
+		}
+
+		// The error is that of the first failing item, whichever finishes
+		// first.
+		in.Globals.Define("m", eval.List{eval.Long(1), eval.Long(0), eval.Long(2), eval.Long(0)})
+		in.Globals.Define("d", eval.List{eval.Long(8), eval.Long(9), eval.Long(3), eval.Long(2)})
+		if _, err := in.EvalString("inv: {[n] count[12/n]}"); err != nil {
+			t.Fatal(err)
+		}
+		_, err = in.EvalString("peach[inv; m]")
+		if err == nil || !strings.Contains(err.Error(), "division by zero") {
+			t.Errorf("workers %d: peach[inv; m] = %v", workers, err)
+		}
+		if v, err := in.EvalString("peach[inv; d]"); err != nil || v.String() != "1 1 4 6" {
+			t.Errorf("workers %d: peach[inv; d] = %v, %v", workers, v, err)
+		}
+	}
+	if calls.Load() == 0 {
+		t.Error("count was not called")
+	}
+
+	in := eval.NewWith(eval.Peach)
+	for _, src := range []string{"peach[peach; 1]", "peach[1; peach]"} {
+		if _, err := in.EvalString(src); err == nil {
+			t.Errorf("%s succeeded", src)
+		}
+	}
+	if _, err := eval.Peach.Call([]eval.Value{eval.Long(1), eval.List{}}); err == nil {
+		t.Error("Peach.Call succeeded outside an interpreter")
+	}
+}
+
+func TestPeachLimits(t *testing.T) {
+	in := eval.NewWith(eval.Peach)
+	in.Workers = 4
+	in.Globals.Define("l", longs(64))
+	if _, err := in.EvalString("f: {[n] n+n+n+n}"); err != nil {
+		t.Fatal(err)
+	}
+	// Each application takes a few steps, well within the limit, but
+	// together they exceed it.
+	in.Limits = eval.Limits{MaxSteps: 200}
+	_, err := in.EvalString("peach[f; l]")
+	limitError(t, err, eval.LimitSteps)
+
+	in.Limits = eval.Limits{MaxDepth: 2}
+	if _, err := in.EvalString("peach[f; l]"); err != nil {
+		t.Errorf("depth 2: %v", err)
+	}
+	in.Limits = eval.Limits{MaxDepth: 1}
+	_, err = in.EvalString("peach[f; l]")
+	limitError(t, err, eval.LimitDepth)
+
+	in.Limits = eval.Limits{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := in.EvalStringContext(ctx, "peach[f; l]"); err == nil {
+		t.Error("peach ran after its context was cancelled")
+	}
+}
+
+func parse(t *testing.T, srcs ...string) []*ast.File {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	var files []*ast.File
+	for _, src := range srcs {
+		tree, err := parser.ParseString(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, ast.FromTree(tree.Tree, []byte(src)))
+		tree.Close()
+	}
+	return files
+}
+
+func TestEvalParallel(t *testing.T) {
+	for _, workers := range []int{1, 3} {
+		in := eval.NewWith()
+		in.Workers = workers
+		in.Globals.Define("a", eval.Long(2))
+		files := append(parse(t, "b: a*3", "c: a+1", "a*a", "d: 1/0"), &ast.File{})
+		values, errs := in.EvalParallel(context.Background(), files, nil)
+		var got []string
+		for i := range values {
+			if errs[i] != nil {
+				got = append(got, errs[i].Error())
+			} else {
+				got = append(got, fmt.Sprint(values[i]))
+			}
+		}
+		want := "6|3|4|1:4: division by zero|<nil>"
+		if strings.Join(got, "|") != want {
+			t.Errorf("workers %d: EvalParallel = %s, want %s", workers, strings.Join(got, "|"), want)
+		}
+		for name, want := range map[string]string{"b": "6", "c": "3"} {
+			if v, ok := in.Globals.Lookup(name); !ok || v.String() != want {
+				t.Errorf("workers %d: %s = %v", workers, name, v)
+			}
+		}
+		if _, ok := in.Globals.Lookup("d"); ok {
+			t.Errorf("workers %d: failed assignment was made", workers)
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Column is a column of a Table: the atoms of one kind, held in a
+// contiguous Go slice rather than as boxed values. The concrete types are
+// LongColumn, FloatColumn and SymbolColumn.
+//
+// Columns share memory with the slices they are converted from. That lets
+// a host hand over columnar data it already holds, such as the value
+// buffers of an Apache Arrow record batch as array.Int64.Int64Values and
+// array.Float64.Float64Values return them, without copying; the host must
+// not modify the slices while the table is in use.
+type Column interface {
+	// Type is the kind of the items.
+	Type() Kind
+	Len() int
+	// At returns item i, boxed as a Value.
+	At(i int) Value
+}
+
+// LongColumn is a column of longs.
+type LongColumn []int64
+
+// FloatColumn is a column of floats.
+type FloatColumn []float64
+
+// SymbolColumn is a column of symbols.
+type SymbolColumn []string
+
+func (LongColumn) Type() Kind   { return KindLong }
+func (FloatColumn) Type() Kind  { return KindFloat }
+func (SymbolColumn) Type() Kind { return KindSymbol }
+
+func (c LongColumn) Len() int   { return len(c) }
+func (c FloatColumn) Len() int  { return len(c) }
+func (c SymbolColumn) Len() int { return len(c) }
+
+func (c LongColumn) At(i int) Value   { return Long(c[i]) }
+func (c FloatColumn) At(i int) Value  { return Float(c[i]) }
+func (c SymbolColumn) At(i int) Value { return Symbol(c[i]) }
+
+// NewColumn converts a list of atoms of one kind to a Column. An empty
+// list becomes an empty LongColumn.
+func NewColumn(l List) (Column, error) {
+	if len(l) == 0 {
+		return LongColumn{}, nil
+	}
+	if !homogeneous(l) {
+		return nil, &Error{Code: CodeType, Message: "column mixes " + l[0].Kind().String() + " with other kinds"}
+	}
+	switch l[0].(type) {
+	case Long:
+		out := make(LongColumn, len(l))
+		for i, v := range l {
+			out[i] = int64(v.(Long))
+		}
+		return out, nil
+	case Float:
+		out := make(FloatColumn, len(l))
+		for i, v := range l {
+			out[i] = float64(v.(Float))
+		}
+		return out, nil
+	case Symbol:
+		out := make(SymbolColumn, len(l))
+		for i, v := range l {
+			out[i] = string(v.(Symbol))
+		}
+		return out, nil
+	}
+	return nil, &Error{Code: CodeType, Message: "cannot make a column of " + l[0].Kind().String()}
+}
+
+// ColumnList boxes the items of c as a List. The items of a LongColumn or
+// FloatColumn point into it rather than copy it.
+func ColumnList(c Column) List {
+	switch c := c.(type) {
+	case LongColumn:
+		return boxLongs(c)
+	case FloatColumn:
+		return boxFloats(c)
+	}
+	out := make(List, c.Len())
+	for i := range out {
+		out[i] = c.At(i)
+	}
+	return out
+}
+
+// Table is a table of named columns of equal length, q style. A table is
+// applied like a function of one argument: to a symbol, it gives the
+// column of that name as a list, and to a long, the row of that index as
+// a list with an item for each column.
+type Table struct {
+	names []string
+	cols  []Column
+	rows  int
+}
+
+// NewTable returns a table of the given columns, which it keeps rather
+// than copies. Names must be distinct and columns of equal length.
+func NewTable(names []string, cols []Column) (*Table, error) {
+	if len(names) != len(cols) {
+		return nil, fmt.Errorf("eval: %d names for %d columns", len(names), len(cols))
+	}
+	t := &Table{names: append([]string(nil), names...), cols: append([]Column(nil), cols...)}
+	seen := map[string]bool{}
+	for i, name := range names {
+		if seen[name] {
+			return nil, fmt.Errorf("eval: duplicate column %s", name)
+		}
+		seen[name] = true
+		if i == 0 {
+			t.rows = cols[i].Len()
+		} else if cols[i].Len() != t.rows {
+			return nil, &Error{Code: CodeLength, Message: fmt.Sprintf("column %s has %d rows, want %d", name, cols[i].Len(), t.rows)}
+		}
+	}
+	return t, nil
+}
+
+// Kind returns KindTable.
+func (t *Table) Kind() Kind { return KindTable }
+
+// Names returns the column names in order.
+func (t *Table) Names() []string { return append([]string(nil), t.names...) }
+
+// Len returns the number of rows.
+func (t *Table) Len() int { return t.rows }
+
+// Columns returns the columns in the order of Names.
+func (t *Table) Columns() []Column { return append([]Column(nil), t.cols...) }
+
+// Column returns the column called name.
+func (t *Table) Column(name string) (Column, bool) {
+	for i, n := range t.names {
+		if n == name {
+			return t.cols[i], true
+		}
+	}
+	return nil, false
+}
+
+// Row returns row i as a list with an item for each column.
+func (t *Table) Row(i int) List {
+	out := make(List, len(t.cols))
+	for j, c := range t.cols {
+		out[j] = c.At(i)
+	}
+	return out
+}
+
+// Arity returns 1; see Call.
+func (t *Table) Arity() int { return 1 }
+
+// Call indexes the table by column name or row number.
+func (t *Table) Call(args []Value) (Value, error) {
+	switch a := args[0].(type) {
+	case Symbol:
+		c, ok := t.Column(string(a))
+		if !ok {
+			return nil, &Error{Code: CodeUndefined, Message: "no column " + string(a)}
+		}
+		return ColumnList(c), nil
+	case Long:
+		if a < 0 || int64(a) >= int64(t.rows) {
+			return nil, &Error{Code: CodeLength, Message: fmt.Sprintf("row %d out of range for a table of %d rows", a, t.rows)}
+		}
+		return t.Row(int(a)), nil
+	}
+	return nil, &Error{Code: CodeType, Message: "cannot index a table with " + args[0].Kind().String()}
+}
+
+// String formats t the way q prints tables: a header of the column names,
+// a rule, and a line for each row, each column padded to its widest cell.
+func (t *Table) String() string {
+	cells := make([][]string, len(t.cols))
+	widths := make([]int, len(t.cols))
+	for j, c := range t.cols {
+		cells[j] = make([]string, t.rows)
+		widths[j] = len(t.names[j])
+		for i := range cells[j] {
+			cells[j][i] = cell(c.At(i))
+			widths[j] = max(widths[j], len(cells[j][i]))
+		}
+	}
+	line := func(cell func(j int) string) string {
+		parts := make([]string, len(t.cols))
+		for j := range t.cols {
+			s := cell(j)
+			parts[j] = s + strings.Repeat(" ", widths[j]-len(s))
+		}
+		return strings.TrimRight(strings.Join(parts, " "), " ")
+	}
+	rule := len(t.cols) - 1
+	for _, w := range widths {
+		rule += w
+	}
+	lines := []string{line(func(j int) string { return t.names[j] }), strings.Repeat("-", max(rule, 0))}
+	for i := 0; i < t.rows; i++ {
+		lines = append(lines, line(func(j int) string { return cells[j][i] }))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// cell formats an item of a table, symbols without their backquote.
+func cell(v Value) string {
+	if s, ok := v.(Symbol); ok {
+		return string(s)
+	}
+	return v.String()
+}
+
+func equalTables(a, b *Table) bool {
+	if len(a.names) != len(b.names) || a.rows != b.rows {
+		return false
+	}
+	for j, name := range a.names {
+		if b.names[j] != name || a.cols[j].Type() != b.cols[j].Type() {
+			return false
+		}
+		for i := 0; i < a.rows; i++ {
+			if !Equal(a.cols[j].At(i), b.cols[j].At(i)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+var _ Callable = (*Table)(nil)
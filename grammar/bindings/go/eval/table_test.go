@@ -0,0 +1,125 @@
+package eval_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+func trades(t *testing.T) (*eval.Table, []float64) {
+	t.Helper()
+	prices := []float64{1.5, 2, 10.25}
+	tab, err := eval.NewTable([]string{"sym", "qty", "price"}, []eval.Column{
+		eval.SymbolColumn{"a", "bb", "a"},
+		eval.LongColumn{10, 200, 3},
+		eval.FloatColumn(prices),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tab, prices
+}
+
+func TestTable(t *testing.T) {
+	tab, prices := trades(t)
+	if tab.Kind() != eval.KindTable || tab.Len() != 3 {
+		t.Fatalf("Kind() = %v, Len() = %d", tab.Kind(), tab.Len())
+	}
+	want := "sym qty price\n" +
+		"-------------\n" +
+		"a   10  1.5\n" +
+		"bb  200 2f\n" +
+		"a   3   10.25"
+	if got := tab.String(); got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+
+	// Columns are the host's slices, not copies.
+	c, ok := tab.Column("price")
+	if !ok {
+		t.Fatal("no price column")
+	}
+	if f := c.(eval.FloatColumn); &f[0] != &prices[0] {
+		t.Error("Column copied the slice")
+	}
+
+	in := eval.NewWith()
+	in.Globals.Define("t", tab)
+	in.Globals.Define("price", eval.Symbol("price"))
+	for src, want := range map[string]string{
+		"t[1]":       "(`bb;200;2f)",
+		"t[price]":   "1.5 2f 10.25",
+		"2*t[price]": "3f 4f 20.5",
+		"t[price]-1": "0.5 1f 9.25",
+		"t[0]":       "(`a;10;1.5)",
+	} {
+		v, err := in.EvalString(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if v.String() != want {
+			t.Errorf("%s = %s, want %s", src, v, want)
+		}
+	}
+	for _, src := range []string{"t[3]", "t[-1]", "t[t]"} {
+		if _, err := in.EvalString(src); err == nil {
+			t.Errorf("%s succeeded", src)
+		}
+	}
+	in.Globals.Define("nope", eval.Symbol("nope"))
+	if _, err := in.EvalString("t[nope]"); err == nil {
+		t.Error("t[nope] succeeded")
+	}
+
+	if _, err := eval.NewTable([]string{"a", "b"}, []eval.Column{eval.LongColumn{1}, eval.LongColumn{1, 2}}); err == nil {
+		t.Error("NewTable accepted ragged columns")
+	}
+	if _, err := eval.NewTable([]string{"a", "a"}, []eval.Column{eval.LongColumn{1}, eval.LongColumn{2}}); err == nil {
+		t.Error("NewTable accepted duplicate names")
+	}
+	if _, err := eval.NewColumn(eval.List{eval.Long(1), eval.Float(2)}); err == nil {
+		t.Error("NewColumn accepted mixed kinds")
+	}
+}
+
+func TestTableEncoding(t *testing.T) {
+	tab, _ := trades(t)
+	data, err := json.Marshal(tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := eval.DecodeJSON(data, nil)
+	if err != nil || !eval.Equal(v, tab) {
+		t.Errorf("JSON round trip = %v, %v", v, err)
+	}
+	bin, err := eval.EncodeBinary(tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err = eval.DecodeBinary(bin, nil)
+	if err != nil || !eval.Equal(v, tab) {
+		t.Errorf("binary round trip = %v, %v", v, err)
+	}
+	other, _ := eval.NewTable([]string{"sym"}, []eval.Column{eval.SymbolColumn{"a", "bb", "a"}})
+	if eval.Equal(tab, other) {
+		t.Error("tables of different columns are equal")
+	}
+}
+
+func TestTableBuiltin(t *testing.T) {
+	rows, err := eval.NewBuiltin("rows", func(t *eval.Table) int { return t.Len() })
+	if err != nil {
+		t.Fatal(err)
+	}
+	tab, _ := trades(t)
+	in := eval.NewWith(rows)
+	in.Globals.Define("t", tab)
+	if v, err := in.EvalString("rows[t]"); err != nil || v.String() != "3" {
+		t.Errorf("rows[t] = %v, %v", v, err)
+	}
+	if _, err := in.EvalString("rows[1]"); err == nil {
+		t.Error("rows[1] succeeded")
+	}
+}
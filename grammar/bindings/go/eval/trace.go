@@ -0,0 +1,25 @@
+package eval
+
+import "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+
+// Tracer observes an evaluation as it happens, for hosts that need to
+// see where a formula spends its time or how it arrived at a value.
+// Package trace provides tracers that log the events or record them as
+// spans.
+//
+// Callbacks run on the evaluating goroutine and should return quickly.
+type Tracer interface {
+	// Enter is called when fn, a *Function or a Callable, is applied to
+	// args, before its body runs. Call is the call expression, or nil for
+	// a function applied by the host through Apply.
+	Enter(call *ast.Call, fn Value, args []Value)
+	// Exit is called when the application reported by the matching Enter
+	// returns, with its result or error.
+	Exit(call *ast.Call, fn Value, result Value, err error)
+	// Operator is called when an operator has been applied: e is the
+	// *ast.BinaryExpr, *ast.UnaryExpr or *ast.PostfixExpr, and operands
+	// are the values it was applied to.
+	Operator(e ast.Expr, op string, operands []Value, result Value, err error)
+	// Assign is called when a statement binds name to v.
+	Assign(name string, v Value)
+}
@@ -0,0 +1,59 @@
+package eval_test
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// recorder is a tracer noting each event.
+type recorder []string
+
+func (r *recorder) Enter(call *ast.Call, fn eval.Value, args []eval.Value) {
+	name := "host"
+	if call != nil {
+		name = call.Func.Name
+	}
+	*r = append(*r, fmt.Sprintf("enter %s %v", name, args))
+}
+
+func (r *recorder) Exit(call *ast.Call, fn eval.Value, result eval.Value, err error) {
+	*r = append(*r, fmt.Sprintf("exit %v %v", result, err))
+}
+
+func (r *recorder) Operator(e ast.Expr, op string, operands []eval.Value, result eval.Value, err error) {
+	*r = append(*r, fmt.Sprintf("%s %v = %v %v", op, operands, result, err))
+}
+
+func (r *recorder) Assign(name string, v eval.Value) {
+	*r = append(*r, fmt.Sprintf("%s: %v", name, v))
+}
+
+func TestTracer(t *testing.T) {
+	in := eval.New()
+	var r recorder
+	in.Tracer = &r
+	for _, src := range []string{"f: {[a] -a*2}", "y: f[3]!", "f[1%0]", "g: {x}"} {
+		in.EvalString(src)
+	}
+	g, _ := in.Globals.Lookup("g")
+	in.Apply(g, []eval.Value{eval.Long(7)})
+	want := []string{
+		"f: {[a] -a*2}",
+		"enter f [3]",
+		"- [3] = -3 <nil>",
+		"* [-3 2] = -6 <nil>",
+		"exit -6 <nil>",
+		"! [-6] = <nil> factorial of negative number",
+		"% [1 0] = <nil> division by zero",
+		"g: {x}",
+		"enter host [7]",
+		"exit 7 <nil>",
+	}
+	if !slices.Equal(r, want) {
+		t.Errorf("events:\n%q\nwant:\n%q", r, want)
+	}
+}
@@ -0,0 +1,216 @@
+package eval
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+// Kind identifies the type of a Value.
+type Kind int
+
+// Value kinds.
+const (
+	KindLong Kind = iota
+	KindFloat
+	KindSymbol
+	KindList
+	KindFunction
+	KindTable
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindLong:
+		return "long"
+	case KindFloat:
+		return "float"
+	case KindSymbol:
+		return "symbol"
+	case KindList:
+		return "list"
+	case KindFunction:
+		return "function"
+	case KindTable:
+		return "table"
+	}
+	return "kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Value is the result of evaluating an expression. The concrete types are
+// Long, Float, Symbol, List, *Function and *Table.
+type Value interface {
+	Kind() Kind
+	// String formats the value the way the REPL prints it.
+	String() string
+}
+
+// Long is a 64-bit integer.
+type Long int64
+
+// Float is a 64-bit floating point number.
+type Float float64
+
+// Symbol is an interned name. The grammar has no symbol literals, so
+// symbols only enter a program through host-provided bindings.
+type Symbol string
+
+// List is an ordered collection of values.
+type List []Value
+
+// Function is a function literal together with the environment it closes
+// over.
+type Function struct {
+	// Params are the explicit parameters, or the implicit x, y and z the
+	// body refers to.
+	Params []string
+	// Implicit reports whether Params were inferred from the body.
+	Implicit bool
+	Body     ast.Expr
+	Closure  *Env
+	// Source is the text of the function literal.
+	Source string
+}
+
+func (Long) Kind() Kind      { return KindLong }
+func (Float) Kind() Kind     { return KindFloat }
+func (Symbol) Kind() Kind    { return KindSymbol }
+func (List) Kind() Kind      { return KindList }
+func (*Function) Kind() Kind { return KindFunction }
+
+func (v Long) String() string { return strconv.FormatInt(int64(v), 10) }
+
+func (v Float) String() string {
+	s := strconv.FormatFloat(float64(v), 'g', -1, 64)
+	if !strings.ContainsAny(s, ".eEnI") {
+		// Mark whole floats so they are distinguishable from longs.
+		s += "f"
+	}
+	return s
+}
+
+func (v Symbol) String() string { return "`" + string(v) }
+
+func (v List) String() string {
+	if len(v) == 1 {
+		return "," + v[0].String()
+	}
+	simple := true
+	for _, item := range v {
+		switch item.(type) {
+		case Long, Float, Symbol:
+		default:
+			simple = false
+		}
+	}
+	parts := make([]string, len(v))
+	for i, item := range v {
+		parts[i] = item.String()
+	}
+	if simple && len(v) > 0 && homogeneous(v) {
+		if _, ok := v[0].(Symbol); ok {
+			return strings.Join(parts, "")
+		}
+		return strings.Join(parts, " ")
+	}
+	return "(" + strings.Join(parts, ";") + ")"
+}
+
+func (f *Function) String() string {
+	if f.Source != "" {
+		return f.Source
+	}
+	return "{[" + strings.Join(f.Params, ";") + "] ...}"
+}
+
+// Arity returns the number of arguments the function expects.
+func (f *Function) Arity() int { return len(f.Params) }
+
+// Callable is implemented by function values evaluated by other means than
+// the interpreter, such as compiled closures, so that Apply can call them.
+// Their Kind is KindFunction, except for *Table, which is applied to
+// index it.
+type Callable interface {
+	Value
+	Arity() int
+	// Call applies the function to exactly Arity arguments.
+	Call(args []Value) (Value, error)
+}
+
+func homogeneous(v List) bool {
+	for _, item := range v[1:] {
+		if item.Kind() != v[0].Kind() {
+			return false
+		}
+	}
+	return true
+}
+
+// Equal reports whether a and b are the same value. Functions compare by
+// identity; lists compare element-wise and tables column by column.
+func Equal(a, b Value) bool {
+	switch a := a.(type) {
+	case List:
+		b, ok := b.(List)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !Equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	case *Function:
+		return a == b
+	case *Table:
+		b, ok := b.(*Table)
+		return ok && equalTables(a, b)
+	}
+	return a == b
+}
+
+// Compare orders two atoms, returning -1, 0 or +1. Longs and floats compare
+// numerically with each other and symbols compare lexically. The grammar
+// has no comparison operators, so this is offered to hosts and builtins.
+func Compare(a, b Value) (int, error) {
+	if sa, ok := a.(Symbol); ok {
+		if sb, ok := b.(Symbol); ok {
+			return strings.Compare(string(sa), string(sb)), nil
+		}
+	}
+	fa, okA := toFloat(a)
+	fb, okB := toFloat(b)
+	if !okA || !okB {
+		return 0, &Error{Code: CodeType, Message: "cannot compare " + a.Kind().String() + " with " + b.Kind().String()}
+	}
+	if la, ok := a.(Long); ok {
+		if lb, ok := b.(Long); ok {
+			switch {
+			case la < lb:
+				return -1, nil
+			case la > lb:
+				return 1, nil
+			}
+			return 0, nil
+		}
+	}
+	switch {
+	case fa < fb:
+		return -1, nil
+	case fa > fb:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func toFloat(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case Long:
+		return float64(v), true
+	case Float:
+		return float64(v), true
+	}
+	return 0, false
+}
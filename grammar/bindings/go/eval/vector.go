@@ -0,0 +1,266 @@
+package eval
+
+import (
+	"math"
+	"unsafe"
+)
+
+// Lists of longs, or of floats, are the common case of list arithmetic,
+// and carrying it out item by item through Binary pays for a type switch,
+// an operator lookup and two unboxings per item. Binary instead hands
+// such operands to vectorBinary, which unboxes each list once into a
+// contiguous slice, picks the kernel for the operator once, and runs it in
+// a tight loop. The results are boxed with one allocation for the whole
+// list rather than one for each item; see boxLongs. Lists holding other
+// values, mixing longs with floats, or nesting lists keep the item-by-item
+// path, which the kernels match result for result and error for error.
+
+// numeric classifies an operand of list arithmetic: an atom or a
+// non-empty list of longs or floats, all of one kind. It returns
+// KindLong or KindFloat, the unboxed items, and whether v is a list; ok
+// is false for other values.
+func numeric(v Value) (kind Kind, longs []int64, floats []float64, list, ok bool) {
+	switch v := v.(type) {
+	case Long:
+		return KindLong, []int64{int64(v)}, nil, false, true
+	case Float:
+		return KindFloat, nil, []float64{float64(v)}, false, true
+	case List:
+		if len(v) == 0 {
+			return 0, nil, nil, true, false
+		}
+		switch v[0].(type) {
+		case Long:
+			longs = make([]int64, len(v))
+			for i, item := range v {
+				n, ok := item.(Long)
+				if !ok {
+					return 0, nil, nil, true, false
+				}
+				longs[i] = int64(n)
+			}
+			return KindLong, longs, nil, true, true
+		case Float:
+			floats = make([]float64, len(v))
+			for i, item := range v {
+				f, ok := item.(Float)
+				if !ok {
+					return 0, nil, nil, true, false
+				}
+				floats[i] = float64(f)
+			}
+			return KindFloat, nil, floats, true, true
+		}
+	}
+	return 0, nil, nil, false, false
+}
+
+// widen converts unboxed longs to floats, as Binary does for an operation
+// with a float operand.
+func widen(longs []int64) []float64 {
+	out := make([]float64, len(longs))
+	for i, n := range longs {
+		out[i] = float64(n)
+	}
+	return out
+}
+
+// vectorBinary applies op to a and b, at least one of them a list, with
+// the kernels. ok is false if the operands are not both numeric, leaving
+// them to the item-by-item path.
+func vectorBinary(op string, a, b Value) (v Value, ok bool, err error) {
+	ka, la, fa, aList, okA := numeric(a)
+	kb, lb, fb, bList, okB := numeric(b)
+	if !okA || !okB {
+		return nil, false, nil
+	}
+	n := max(len(la), len(fa))
+	if bList {
+		n = max(len(lb), len(fb))
+	}
+	if aList && bList && max(len(la), len(fa)) != n {
+		return nil, true, &Error{Code: CodeLength, Message: "length mismatch in " + op}
+	}
+	// An atom has one item and a stride of zero, so the kernels extend it
+	// across the list.
+	sa, sb := 0, 0
+	if aList {
+		sa = 1
+	}
+	if bList {
+		sb = 1
+	}
+	if ka == KindLong && kb == KindLong {
+		r := make([]int64, n)
+		if err := longKernel(op, la, sa, lb, sb, r); err != nil {
+			return nil, true, err
+		}
+		return boxLongs(r), true, nil
+	}
+	if ka == KindLong {
+		fa = widen(la)
+	}
+	if kb == KindLong {
+		fb = widen(lb)
+	}
+	r := make([]float64, n)
+	if err := floatKernel(op, fa, sa, fb, sb, r); err != nil {
+		return nil, true, err
+	}
+	return boxFloats(r), true, nil
+}
+
+// longKernel sets out[i] to x[i*sx] op y[i*sy] with the checked
+// arithmetic of longBinary, stopping at the first error.
+func longKernel(op string, x []int64, sx int, y []int64, sy int, out []int64) error {
+	switch op {
+	case "+":
+		for i := range out {
+			a, b := x[i*sx], y[i*sy]
+			s := a + b
+			if (s > a) != (b > 0) {
+				return overflow("addition")
+			}
+			out[i] = s
+		}
+	case "-":
+		for i := range out {
+			a, b := x[i*sx], y[i*sy]
+			d := a - b
+			if (d < a) != (b > 0) {
+				return overflow("subtraction")
+			}
+			out[i] = d
+		}
+	case "*":
+		for i := range out {
+			a, b := x[i*sx], y[i*sy]
+			if a == 0 || b == 0 {
+				out[i] = 0
+				continue
+			}
+			p := a * b
+			if p/b != a || (a == -1 && b == math.MinInt64) || (b == -1 && a == math.MinInt64) {
+				return overflow("multiplication")
+			}
+			out[i] = p
+		}
+	case "/", "%":
+		for i := range out {
+			a, b := x[i*sx], y[i*sy]
+			if b == 0 {
+				return &Error{Code: CodeDivisionByZero, Message: "division by zero"}
+			}
+			if a == math.MinInt64 && b == -1 {
+				return overflow("division")
+			}
+			if op == "/" {
+				out[i] = a / b
+			} else {
+				out[i] = a % b
+			}
+		}
+	case "^":
+		for i := range out {
+			p, err := power(x[i*sx], y[i*sy])
+			if err != nil {
+				return err
+			}
+			out[i] = int64(p.(Long))
+		}
+	default:
+		return &Error{Code: CodeUnknownOperator, Message: "unknown operator " + op}
+	}
+	return nil
+}
+
+// floatKernel sets out[i] to x[i*sx] op y[i*sy] in floating point.
+func floatKernel(op string, x []float64, sx int, y []float64, sy int, out []float64) error {
+	switch op {
+	case "+":
+		for i := range out {
+			out[i] = x[i*sx] + y[i*sy]
+		}
+	case "-":
+		for i := range out {
+			out[i] = x[i*sx] - y[i*sy]
+		}
+	case "*":
+		for i := range out {
+			out[i] = x[i*sx] * y[i*sy]
+		}
+	case "/":
+		for i := range out {
+			out[i] = x[i*sx] / y[i*sy]
+		}
+	case "%":
+		for i := range out {
+			out[i] = math.Mod(x[i*sx], y[i*sy])
+		}
+	case "^":
+		for i := range out {
+			out[i] = math.Pow(x[i*sx], y[i*sy])
+		}
+	default:
+		return &Error{Code: CodeUnknownOperator, Message: "unknown operator " + op}
+	}
+	return nil
+}
+
+// vectorNegate negates a list of longs or of floats with one loop; ok is
+// false for other lists.
+func vectorNegate(l List) (v Value, ok bool, err error) {
+	kind, longs, floats, _, ok := numeric(l)
+	if !ok {
+		return nil, false, nil
+	}
+	if kind == KindLong {
+		for i, n := range longs {
+			if n == math.MinInt64 {
+				return nil, true, overflow("negation")
+			}
+			longs[i] = -n
+		}
+		return boxLongs(longs), true, nil
+	}
+	for i, f := range floats {
+		floats[i] = -f
+	}
+	return boxFloats(floats), true, nil
+}
+
+// iface is the layout of a non-empty interface value such as Value: its
+// method table and a pointer to the data it holds.
+type iface struct {
+	tab  unsafe.Pointer
+	data unsafe.Pointer
+}
+
+// Interface values of the method tables of Long and Float in Value.
+var (
+	longValue  Value = Long(0)
+	floatValue Value = Float(0)
+)
+
+// boxLongs returns a list of the longs xs. Converting each to a Value
+// would allocate a copy of it on the heap; instead each item points into
+// xs, which must not be modified afterwards. Interface values never
+// write to the data they point at, so the items stay immutable.
+func boxLongs(xs []int64) List {
+	return box(xs, longValue)
+}
+
+// boxFloats is boxLongs for floats.
+func boxFloats(xs []float64) List {
+	return box(xs, floatValue)
+}
+
+func box[T int64 | float64](xs []T, proto Value) List {
+	out := make(List, len(xs))
+	tab := (*iface)(unsafe.Pointer(&proto)).tab
+	for i := range xs {
+		item := (*iface)(unsafe.Pointer(&out[i]))
+		item.tab, item.data = tab, unsafe.Pointer(&xs[i])
+	}
+	return out
+}
@@ -0,0 +1,128 @@
+package eval_test
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// itemwise applies op to a and b one item at a time with Binary on atoms,
+// as list arithmetic is defined.
+func itemwise(op string, a, b eval.Value) (eval.Value, error) {
+	la, aList := a.(eval.List)
+	lb, bList := b.(eval.List)
+	if !aList && !bList {
+		return eval.Binary(op, a, b)
+	}
+	n := len(la)
+	if !aList {
+		n = len(lb)
+	} else if bList && len(lb) != n {
+		return nil, &eval.Error{Code: eval.CodeLength, Message: "length mismatch in " + op}
+	}
+	out := make(eval.List, n)
+	for i := range out {
+		x, y := a, b
+		if aList {
+			x = la[i]
+		}
+		if bList {
+			y = lb[i]
+		}
+		v, err := itemwise(op, x, y)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = v
+	}
+	return out, nil
+}
+
+// numbers returns a list of n longs or floats, with the extreme values
+// that exercise the overflow checks and zeros for division.
+func numbers(r *rand.Rand, n int, float bool) eval.List {
+	out := make(eval.List, n)
+	for i := range out {
+		if float {
+			out[i] = eval.Float([]float64{0, -1, 2.5, math.Inf(1), math.NaN(), r.NormFloat64() * 1e6}[r.Intn(6)])
+		} else {
+			out[i] = eval.Long([]int64{0, 1, -1, 3, math.MaxInt64, math.MinInt64, r.Int63n(1 << 20)}[r.Intn(7)])
+		}
+	}
+	return out
+}
+
+func TestVectorArithmetic(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	operand := func(n int) eval.Value {
+		switch r.Intn(5) {
+		case 0:
+			return numbers(r, 1, r.Intn(2) == 0)[0]
+		case 1:
+			// Mixed kinds take the item-by-item path.
+			l := numbers(r, n, false)
+			l[r.Intn(n)] = eval.Float(1.5)
+			return l
+		}
+		return numbers(r, n, r.Intn(2) == 0)
+	}
+	for i := 0; i < 5000; i++ {
+		op := []string{"+", "-", "*", "/", "%", "^", "?"}[r.Intn(7)]
+		n := 1 + r.Intn(6)
+		a, b := operand(n), operand(n)
+		if r.Intn(20) == 0 {
+			b = numbers(r, n+1, false)
+		}
+		got, gotErr := eval.Binary(op, a, b)
+		want, wantErr := itemwise(op, a, b)
+		if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) || wantErr == nil && !same(got, want) {
+			t.Fatalf("%v %s %v = %v, %v; want %v, %v", a, op, b, got, gotErr, want, wantErr)
+		}
+	}
+
+	for _, l := range []eval.List{{eval.Long(1), eval.Long(-2)}, {eval.Float(1), eval.Float(math.Inf(-1))}} {
+		got, err := eval.Negate(l)
+		want, _ := eval.Binary("-", eval.Long(0), l)
+		if err != nil || !same(got, want) {
+			t.Errorf("Negate(%v) = %v, %v", l, got, err)
+		}
+	}
+	if _, err := eval.Negate(eval.List{eval.Long(1), eval.Long(math.MinInt64)}); err == nil {
+		t.Error("Negate of MinInt64 did not overflow")
+	}
+}
+
+func BenchmarkBinary(b *testing.B) {
+	const n = 10000
+	longs := make(eval.List, n)
+	floats := make(eval.List, n)
+	for i := range longs {
+		longs[i] = eval.Long(i)
+		floats[i] = eval.Float(i)
+	}
+	// A float at the end keeps a list of longs off the vector path, for
+	// comparison.
+	mixed := append(eval.List(nil), longs...)
+	mixed[n-1] = eval.Float(n)
+	for _, bench := range []struct {
+		name string
+		a, b eval.Value
+	}{
+		{"longs", longs, longs},
+		{"longs-atom", longs, eval.Long(3)},
+		{"floats", floats, floats},
+		{"longs-floats", longs, floats},
+		{"itemwise", mixed, mixed},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := eval.Binary("*", bench.a, bench.b); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package tree_sitter_wabznasm
+
+import (
+	"sort"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// TextEdit replaces the bytes of Range with NewText. An empty range
+// inserts.
+type TextEdit struct {
+	Range   tree_sitter.Range
+	NewText string
+}
+
+// Fix is a machine-applicable correction for a diagnostic or finding: a
+// set of edits to apply together.
+type Fix struct {
+	// Title describes the fix, as in `insert "]"`.
+	Title string
+	Edits []TextEdit
+}
+
+// ApplyFixes applies fixes to source in order and returns the result and
+// the number applied. A fix is skipped if any of its edits overlaps an
+// edit of a fix already applied, or another edit of its own; the fixes
+// skipped can be computed again from the result and applied in another
+// pass.
+func ApplyFixes(source []byte, fixes []Fix) ([]byte, int) {
+	var taken []TextEdit
+	applied := 0
+	for _, f := range fixes {
+		if overlapsAny(f.Edits, taken) || selfOverlap(f.Edits) {
+			continue
+		}
+		taken = append(taken, f.Edits...)
+		applied++
+	}
+	// An insertion sorts before a replacement starting at the same offset.
+	sort.Slice(taken, func(i, j int) bool {
+		a, b := taken[i].Range, taken[j].Range
+		return a.StartByte < b.StartByte || a.StartByte == b.StartByte && a.EndByte < b.EndByte
+	})
+	out := make([]byte, 0, len(source))
+	last := uint(0)
+	for _, e := range taken {
+		out = append(out, source[last:e.Range.StartByte]...)
+		out = append(out, e.NewText...)
+		last = e.Range.EndByte
+	}
+	return append(out, source[last:]...), applied
+}
+
+func selfOverlap(edits []TextEdit) bool {
+	for i := range edits {
+		if overlapsAny(edits[i:i+1], edits[i+1:]) {
+			return true
+		}
+	}
+	return false
+}
+
+func overlapsAny(edits, others []TextEdit) bool {
+	for _, a := range edits {
+		for _, b := range others {
+			if overlaps(a.Range, b.Range) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// overlaps reports whether two edits' ranges conflict. Two insertions at
+// the same offset conflict, since their order would be arbitrary; an
+// insertion at the edge of a replacement does not.
+func overlaps(a, b tree_sitter.Range) bool {
+	if a.StartByte == a.EndByte && b.StartByte == b.EndByte {
+		return a.StartByte == b.StartByte
+	}
+	return a.StartByte < b.EndByte && b.StartByte < a.EndByte
+}
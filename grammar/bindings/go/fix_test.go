@@ -0,0 +1,69 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+func edit(start, end uint, text string) tree_sitter_wabznasm.TextEdit {
+	return tree_sitter_wabznasm.TextEdit{Range: tree_sitter.Range{StartByte: start, EndByte: end}, NewText: text}
+}
+
+func TestApplyFixes(t *testing.T) {
+	fix := func(edits ...tree_sitter_wabznasm.TextEdit) tree_sitter_wabznasm.Fix {
+		return tree_sitter_wabznasm.Fix{Edits: edits}
+	}
+	tests := []struct {
+		name    string
+		fixes   []tree_sitter_wabznasm.Fix
+		want    string
+		applied int
+	}{
+		{"none", nil, "abcdef", 0},
+		{"insert", []tree_sitter_wabznasm.Fix{fix(edit(3, 3, "X"))}, "abcXdef", 1},
+		{"out of order", []tree_sitter_wabznasm.Fix{fix(edit(4, 6, "")), fix(edit(0, 1, "A"))}, "Abcd", 2},
+		{"multi-edit", []tree_sitter_wabznasm.Fix{fix(edit(0, 0, "("), edit(6, 6, ")"))}, "(abcdef)", 1},
+		{"overlap skipped", []tree_sitter_wabznasm.Fix{fix(edit(1, 4, "")), fix(edit(3, 5, "Z"))}, "aef", 1},
+		{"same insertion point", []tree_sitter_wabznasm.Fix{fix(edit(2, 2, "1")), fix(edit(2, 2, "2"))}, "ab1cdef", 1},
+		{"insertion at replacement edge", []tree_sitter_wabznasm.Fix{fix(edit(2, 4, "")), fix(edit(2, 2, "1"))}, "ab1ef", 2},
+		{"self overlap", []tree_sitter_wabznasm.Fix{fix(edit(0, 3, ""), edit(2, 4, ""))}, "abcdef", 0},
+	}
+	for _, tt := range tests {
+		got, applied := tree_sitter_wabznasm.ApplyFixes([]byte("abcdef"), tt.fixes)
+		if string(got) != tt.want || applied != tt.applied {
+			t.Errorf("%s: got %q, %d applied; want %q, %d", tt.name, got, applied, tt.want, tt.applied)
+		}
+	}
+}
+
+func TestMissingTokenFixes(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+
+	tests := []struct{ src, want string }{
+		{"f: {x+1", "f: {x+1}"},
+		{"f[1;2", "f[1;2]"},
+		{"x: (1+2", "x: (1+2)"},
+		{"f: {[a;b a+b}", "f: {[a;b] a+b}"},
+	}
+	for _, tt := range tests {
+		tree, err := parser.ParseString(tt.src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fixes []tree_sitter_wabznasm.Fix
+		for _, d := range tree_sitter_wabznasm.Diagnostics(tree.Tree, []byte(tt.src)) {
+			fixes = append(fixes, d.Fixes...)
+		}
+		tree.Close()
+		got, _ := tree_sitter_wabznasm.ApplyFixes([]byte(tt.src), fixes)
+		if string(got) != tt.want {
+			t.Errorf("%q: fixed to %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+// Package folding computes the collapsible regions of wabznasm sources:
+// function bodies, bracketed parameter and argument lists, parenthesized
+// expressions, and runs of comment lines.
+package folding
+
+import (
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+// Kind classifies a folding range.
+type Kind string
+
+const (
+	// Region is a bracketed construct.
+	Region Kind = "region"
+	// Comment is a block of two or more consecutive comment lines.
+	Comment Kind = "comment"
+)
+
+// Range is a collapsible region spanning more than one line.
+type Range struct {
+	Kind Kind
+	// NodeKind is the kind of the folded node, or "comment" for a block of
+	// comments.
+	NodeKind string
+	Range    tree_sitter.Range
+}
+
+// FoldingRange is the LSP form of a range, as returned by
+// textDocument/foldingRange. Lines are zero-based.
+type FoldingRange struct {
+	StartLine      uint32 `json:"startLine"`
+	StartCharacter uint32 `json:"startCharacter,omitempty"`
+	EndLine        uint32 `json:"endLine"`
+	EndCharacter   uint32 `json:"endCharacter,omitempty"`
+	Kind           string `json:"kind,omitempty"`
+}
+
+// folded lists the node kinds that fold, beside comments.
+var folded = map[string]bool{
+	tree_sitter_wabznasm.KindFunctionBody:  true,
+	tree_sitter_wabznasm.KindParameterList: true,
+	tree_sitter_wabznasm.KindFunctionCall:  true,
+}
+
+// Ranges returns the multi-line regions of tree in source order. A call
+// folds from its opening bracket, leaving the function name visible.
+func Ranges(tree *tree_sitter.Tree) []Range {
+	var out []Range
+	block := -1 // index in out of the comment block being extended
+	var walk func(n *tree_sitter.Node)
+	walk = func(n *tree_sitter.Node) {
+		switch {
+		case n.Kind() == tree_sitter_wabznasm.KindComment:
+			r := n.Range()
+			if block >= 0 && r.StartPoint.Row == out[block].Range.EndPoint.Row+1 {
+				out[block].Range.EndByte, out[block].Range.EndPoint = r.EndByte, r.EndPoint
+				return
+			}
+			out = append(out, Range{Kind: Comment, NodeKind: n.Kind(), Range: r})
+			block = len(out) - 1
+			return
+		case n.ChildCount() == 0:
+			// Code between two comments ends the block.
+			block = -1
+		case fold(n):
+			r := n.Range()
+			if n.Kind() == tree_sitter_wabznasm.KindFunctionCall {
+				if open := n.ChildByFieldName(tree_sitter_wabznasm.FieldLeftBracket); open != nil {
+					r.StartByte, r.StartPoint = open.StartByte(), open.StartPosition()
+				}
+			}
+			if r.EndPoint.Row > r.StartPoint.Row {
+				out = append(out, Range{Kind: Region, NodeKind: n.Kind(), Range: r})
+			}
+		}
+		for i := uint(0); i < n.ChildCount(); i++ {
+			walk(n.Child(i))
+		}
+	}
+	walk(tree.RootNode())
+
+	// Single comment lines do not fold. They are removed after the walk so
+	// that block indexes out while blocks grow.
+	kept := out[:0]
+	for _, r := range out {
+		if r.Kind != Comment || r.Range.EndPoint.Row > r.Range.StartPoint.Row {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+func fold(n *tree_sitter.Node) bool {
+	if folded[n.Kind()] {
+		return true
+	}
+	// A parenthesized primary.
+	return n.Kind() == tree_sitter_wabznasm.KindPrimary && n.ChildByFieldName(tree_sitter_wabznasm.FieldLeftParen) != nil
+}
+
+// LSP converts ranges to their LSP form, folding whole lines. Comment
+// blocks carry the LSP "comment" kind; bracketed regions carry none, as
+// the LSP "region" kind is meant for explicit region markers.
+func LSP(ranges []Range) []FoldingRange {
+	out := make([]FoldingRange, len(ranges))
+	for i, r := range ranges {
+		out[i] = FoldingRange{
+			StartLine: uint32(r.Range.StartPoint.Row),
+			EndLine:   uint32(r.Range.EndPoint.Row),
+		}
+		if r.Kind == Comment {
+			out[i].Kind = "comment"
+		}
+	}
+	return out
+}
@@ -0,0 +1,59 @@
+package folding_test
+
+import (
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/folding"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func parse(t *testing.T, src string) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(parser.Close)
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+func TestRanges(t *testing.T) {
+	src := "\\ one\n\\ two\n\\ three\nf: {[a;\n  b]\n  g[a;\n    (b+\n     1)]\n} \\ trailing\n\\ lone"
+	type fold struct {
+		kind       folding.Kind
+		node       string
+		start, end uint
+	}
+	want := []fold{
+		{folding.Comment, "comment", 0, 2},
+		{folding.Region, "function_body", 3, 8},
+		{folding.Region, "parameter_list", 3, 4},
+		{folding.Region, "function_call", 5, 7},
+		{folding.Region, "primary", 6, 7},
+		{folding.Comment, "comment", 8, 9},
+	}
+	got := folding.Ranges(parse(t, src).Tree)
+	if len(got) != len(want) {
+		t.Fatalf("Ranges = %+v, want %d ranges", got, len(want))
+	}
+	for i, r := range got {
+		g := fold{r.Kind, r.NodeKind, r.Range.StartPoint.Row, r.Range.EndPoint.Row}
+		if g != want[i] {
+			t.Errorf("range %d = %+v, want %+v", i, g, want[i])
+		}
+	}
+	if c := got[3].Range.StartPoint.Column; c != 3 {
+		t.Errorf("call folds from column %d, want 3", c)
+	}
+
+	lsp := folding.LSP(got)
+	if lsp[0].Kind != "comment" || lsp[1].Kind != "" || lsp[1].StartLine != 3 || lsp[1].EndLine != 8 {
+		t.Errorf("LSP = %+v", lsp[:2])
+	}
+}
@@ -0,0 +1,161 @@
+package format
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// BraceStyle places the closing brace of a function body printed on
+// several lines.
+type BraceStyle int
+
+const (
+	// BraceOwnLine puts the closing brace on a line of its own, level with
+	// the line the function starts on.
+	BraceOwnLine BraceStyle = iota
+	// BraceHug puts the closing brace at the end of the last line of the
+	// body.
+	BraceHug
+)
+
+var braceStyles = []string{BraceOwnLine: "own-line", BraceHug: "hug"}
+
+func (s BraceStyle) String() string {
+	if int(s) < len(braceStyles) {
+		return braceStyles[s]
+	}
+	return "BraceStyle(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Config is a layout. The zero Config is the canonical one.
+type Config struct {
+	// MaxWidth is the width, in characters, past which a function body
+	// written on one line is broken onto several. Zero means no limit.
+	// Nothing else is broken, so lines may still be wider.
+	MaxWidth int
+	// SpaceOperators puts a space on each side of infix operators:
+	// 1 + 2*3 becomes 1 + 2 * 3. Prefix and postfix operators stay
+	// attached.
+	SpaceOperators bool
+	// Braces places the closing brace of multi-line function bodies.
+	Braces BraceStyle
+	// AlignAssignments pads the colons of assignments on consecutive lines
+	// of a script so that their values start in the same column.
+	AlignAssignments bool
+}
+
+// ConfigFile is the name of the file holding the Config of the sources
+// in its directory and the directories beneath it.
+const ConfigFile = ".wabznasmfmt"
+
+// ParseConfig reads a Config from r, named name in errors. Each line sets
+// one field as key = value; blank lines and lines starting with # are
+// ignored, and unset fields keep their canonical value:
+//
+//	# .wabznasmfmt
+//	max_width = 80
+//	space_operators = true
+//	braces = hug
+//	align_assignments = true
+func ParseConfig(r io.Reader, name string) (Config, error) {
+	var c Config
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || text[0] == '#' {
+			continue
+		}
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return Config{}, fmt.Errorf("%s:%d: want key = value, got %q", name, line, text)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		var err error
+		switch key {
+		case "max_width":
+			c.MaxWidth, err = strconv.Atoi(value)
+			if err == nil && c.MaxWidth < 0 {
+				err = errors.New("negative width")
+			}
+		case "space_operators":
+			c.SpaceOperators, err = strconv.ParseBool(value)
+		case "align_assignments":
+			c.AlignAssignments, err = strconv.ParseBool(value)
+		case "braces":
+			i := indexOf(braceStyles, value)
+			if i < 0 {
+				err = fmt.Errorf("want one of %s", strings.Join(braceStyles, ", "))
+			}
+			c.Braces = BraceStyle(i)
+		default:
+			return Config{}, fmt.Errorf("%s:%d: unknown setting %q", name, line, key)
+		}
+		if err != nil {
+			return Config{}, fmt.Errorf("%s:%d: %s: invalid value %q: %v", name, line, key, value, err)
+		}
+	}
+	return c, sc.Err()
+}
+
+func indexOf(list []string, s string) int {
+	for i, v := range list {
+		if v == s {
+			return i
+		}
+	}
+	return -1
+}
+
+// LoadConfig reads the Config file at path.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, err
+	}
+	return ParseConfig(bytes.NewReader(data), path)
+}
+
+// FindConfig returns the path of the Config file that applies in dir:
+// the one in dir or the nearest of its parents. The result is empty if
+// there is none.
+func FindConfig(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		path := filepath.Join(dir, ConfigFile)
+		info, err := os.Stat(path)
+		switch {
+		case err == nil && !info.IsDir():
+			return path, nil
+		case err != nil && !errors.Is(err, fs.ErrNotExist):
+			return "", err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// ConfigFor returns the Config of the source at path, found by
+// FindConfig from its directory, and the file it came from. Without a
+// Config file it returns the canonical Config and an empty file name.
+func ConfigFor(path string) (c Config, file string, err error) {
+	file, err = FindConfig(filepath.Dir(path))
+	if err != nil || file == "" {
+		return Config{}, "", err
+	}
+	c, err = LoadConfig(file)
+	return c, file, err
+}
@@ -0,0 +1,349 @@
+// Package format prints wabznasm source in its canonical layout.
+//
+// The formatter works on the concrete syntax tree, so every token and
+// comment of the input is kept; only the whitespace between them changes.
+// A leading byte order mark is kept too, and lines end as most lines of
+// the input do, with '\n' or "\r\n".
+// The canonical layout follows the compact q style used throughout the
+// language documentation:
+//
+//   - no spaces around infix, prefix or postfix operators: 2+3*4, -x, 5!
+//   - one space after the colon of an assignment: x: 42
+//   - no spaces inside brackets or around separators: f[1;2], {[x;y] x+y}
+//   - one space between a parameter list and the function body
+//   - a function whose body spanned several lines or holds a comment is
+//     printed with the body indented on its own lines and the closing brace
+//     on a line of its own
+//   - trailing comments are separated from code by one space; comments on
+//     their own line stay on their own line, and at most one blank line is
+//     kept between them
+//
+// A Config departs from the canonical layout where a team's conventions
+// differ: it can space binary operators, break function bodies that
+// overflow a line width, leave the closing brace of a multi-line body on
+// its last line and align the values of consecutive assignments. Configs
+// are read from .wabznasmfmt files; see ConfigFor. The package functions
+// use the zero Config, the canonical layout.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/normalize"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/sourcemap"
+)
+
+// ErrSyntax is returned, wrapped with the first diagnostic, when the source
+// does not parse. The formatter never rewrites invalid source.
+var ErrSyntax = errors.New("format: syntax error")
+
+const indentUnit = "  "
+
+// Source formats src and returns the result.
+func Source(src []byte) ([]byte, error) { return Config{}.Source(src) }
+
+// SourceWithMap is like Source, but also returns a map from offsets of src
+// to offsets of the result. Every token keeps its text, so positions in
+// tokens map exactly; the whitespace between two tokens maps to theirs.
+func SourceWithMap(src []byte) ([]byte, *sourcemap.Map, error) {
+	return Config{}.SourceWithMap(src)
+}
+
+// Tree writes the canonical form of a parse tree of src to w.
+func Tree(w io.Writer, tree *tree_sitter.Tree, src []byte) error {
+	return Config{}.Tree(w, tree, src)
+}
+
+// TreeWithMap is like Tree, but also returns a map from offsets of src to
+// offsets of what it writes.
+func TreeWithMap(w io.Writer, tree *tree_sitter.Tree, src []byte) (*sourcemap.Map, error) {
+	return Config{}.TreeWithMap(w, tree, src)
+}
+
+// Source formats src in the style of c and returns the result.
+func (c Config) Source(src []byte) ([]byte, error) {
+	out, _, err := c.SourceWithMap(src)
+	return out, err
+}
+
+// SourceWithMap is like Source, but also returns a map from offsets of src
+// to offsets of the result.
+func (c Config) SourceWithMap(src []byte) ([]byte, *sourcemap.Map, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer parser.Close()
+	tree, err := parser.ParseBytes(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tree.Close()
+	var buf bytes.Buffer
+	m, err := c.TreeWithMap(&buf, tree.Tree, src)
+	if err != nil {
+		return nil, nil, err
+	}
+	return buf.Bytes(), m, nil
+}
+
+// Tree writes a parse tree of src to w in the style of c.
+func (c Config) Tree(w io.Writer, tree *tree_sitter.Tree, src []byte) error {
+	_, err := c.TreeWithMap(w, tree, src)
+	return err
+}
+
+// TreeWithMap is like Tree, but also returns a map from offsets of src to
+// offsets of what it writes.
+func (c Config) TreeWithMap(w io.Writer, tree *tree_sitter.Tree, src []byte) (*sourcemap.Map, error) {
+	if tree.RootNode().HasError() {
+		if diags := tree_sitter_wabznasm.Diagnostics(tree, src); len(diags) > 0 {
+			return nil, fmt.Errorf("%w: %s", ErrSyntax, diags[0])
+		}
+		return nil, ErrSyntax
+	}
+	p := c.print(tree, src)
+	if _, err := w.Write(p.out.Bytes()); err != nil {
+		return nil, err
+	}
+	return p.segments.Map(uint(len(src)), uint(p.out.Len())), nil
+}
+
+// print formats tree. A function body printed on one line that overflows
+// MaxWidth is printed again broken onto lines. The byte order mark and the
+// prevailing line ending of src are kept.
+func (c Config) print(tree *tree_sitter.Tree, src []byte) *printer {
+	broken := map[uintptr]bool{}
+	newline := normalize.DetectLineEnding(src).Newline()
+	for {
+		p := &printer{src: src, cfg: c, broken: broken, newline: newline}
+		if bytes.HasPrefix(src, []byte(normalize.BOM)) {
+			p.out.WriteString(normalize.BOM)
+			p.segments.Add(0, uint(len(normalize.BOM)), 0, uint(len(normalize.BOM)))
+		}
+		p.node(tree.RootNode())
+		if p.started {
+			p.out.WriteString(p.newline)
+		}
+		b, ok := p.overflowing()
+		if !ok {
+			return p
+		}
+		broken[b] = true
+	}
+}
+
+// Separators requested between tokens.
+const (
+	sepNone = iota
+	sepSpace
+	sepNewline
+)
+
+type printer struct {
+	src   []byte
+	cfg   Config
+	out   bytes.Buffer
+	depth int
+	// newline ends each line printed.
+	newline string
+	// sep is the separator requested before the next token.
+	sep int
+	// cont indents the next line as a continuation of the current
+	// expression rather than the start of a new one.
+	cont bool
+	// prevEnd is the source offset just past the last printed token.
+	prevEnd uint
+	started bool
+	// segments pairs the source range of each printed token with its
+	// range in out.
+	segments sourcemap.Builder
+	// broken holds the function bodies to print on several lines though
+	// they fit on one in the source, and inline the bodies printed on one
+	// line, with their ranges in out.
+	broken map[uintptr]bool
+	inline []inlineBody
+}
+
+type inlineBody struct {
+	id         uintptr
+	start, end int
+}
+
+// overflowing returns the first body printed on one line whose line is
+// wider than MaxWidth.
+func (p *printer) overflowing() (uintptr, bool) {
+	if p.cfg.MaxWidth <= 0 {
+		return 0, false
+	}
+	out := p.out.Bytes()
+	for _, b := range p.inline {
+		start := bytes.LastIndexByte(out[:b.start], '\n') + 1
+		end := b.end + bytes.IndexByte(out[b.end:], '\n')
+		line := bytes.TrimSuffix(bytes.TrimPrefix(out[start:end], []byte(normalize.BOM)), []byte("\r"))
+		if utf8.RuneCount(line) > p.cfg.MaxWidth {
+			return b.id, true
+		}
+	}
+	return 0, false
+}
+
+func (p *printer) node(n *tree_sitter.Node) {
+	if n.Kind() == "function_body" {
+		p.functionBody(n)
+		return
+	}
+	if n.ChildCount() == 0 {
+		p.token(n)
+		return
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		p.node(n.Child(i))
+	}
+}
+
+func (p *printer) functionBody(n *tree_sitter.Node) {
+	multi := n.StartPosition().Row != n.EndPosition().Row || hasComment(n) || p.broken[n.Id()]
+	if !multi {
+		start := p.out.Len()
+		if p.sep == sepSpace {
+			start++
+		}
+		defer func() { p.inline = append(p.inline, inlineBody{n.Id(), start, p.out.Len()}) }()
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		child := n.Child(i)
+		switch n.FieldNameForChild(uint32(i)) {
+		case "body":
+			if multi {
+				p.depth++
+				p.lineBreak()
+			} else if p.sep == sepNone && i > 0 && n.Child(i-1).Kind() == "parameter_list" {
+				p.sep = sepSpace
+			}
+			p.node(child)
+		case "right_brace":
+			if multi {
+				p.depth--
+				// A hugging brace still goes on a line of its own after a
+				// trailing comment.
+				if p.cfg.Braces != BraceHug || p.sep == sepNewline {
+					p.lineBreak()
+				}
+			}
+			p.node(child)
+		default:
+			p.node(child)
+		}
+	}
+}
+
+// lineBreak ends the current line; the next token starts a new one at the
+// current depth.
+func (p *printer) lineBreak() {
+	p.sep = sepNewline
+	p.cont = false
+}
+
+func (p *printer) token(n *tree_sitter.Node) {
+	text := string(p.src[n.StartByte():n.EndByte()])
+	if n.Kind() == "comment" && n.IsExtra() {
+		p.comment(n, strings.TrimRight(text, " \t"))
+		return
+	}
+	binary := p.cfg.SpaceOperators && isBinaryOperator(n)
+	if binary && p.sep == sepNone {
+		p.sep = sepSpace
+	}
+	p.separate(n)
+	p.write(n, text)
+	if n.Kind() == ":" || binary {
+		p.sep = sepSpace
+	}
+}
+
+// isBinaryOperator reports whether n is the operator of an infix
+// expression, rather than a prefix minus, a factorial or a colon.
+func isBinaryOperator(n *tree_sitter.Node) bool {
+	if n.IsNamed() {
+		return false
+	}
+	// The only anonymous tokens these nodes hold directly are operators.
+	switch parent := n.Parent(); {
+	case parent == nil:
+		return false
+	case parent.Kind() == "additive", parent.Kind() == "multiplicative", parent.Kind() == "power":
+		return true
+	}
+	return false
+}
+
+func (p *printer) comment(n *tree_sitter.Node, text string) {
+	ownLine := !p.started || bytes.Contains(p.src[p.prevEnd:n.StartByte()], []byte("\n"))
+	switch {
+	case !p.started:
+	case ownLine:
+		p.sep = sepNewline
+	default:
+		p.sep = sepSpace
+	}
+	cont := p.cont
+	p.separate(n)
+	p.write(n, text)
+	// A comment runs to the end of the line, so something must follow it on
+	// the next; keep indenting as a continuation if it interrupted an
+	// expression.
+	p.sep = sepNewline
+	p.cont = cont
+}
+
+// separate writes the separator requested before n.
+func (p *printer) separate(n *tree_sitter.Node) {
+	if !p.started {
+		return
+	}
+	switch p.sep {
+	case sepSpace:
+		p.out.WriteByte(' ')
+	case sepNewline:
+		p.out.WriteString(p.newline)
+		if bytes.Count(p.src[p.prevEnd:n.StartByte()], []byte("\n")) > 1 {
+			p.out.WriteString(p.newline)
+		}
+		p.out.WriteString(strings.Repeat(indentUnit, p.depth))
+		if p.cont {
+			p.out.WriteString(indentUnit)
+		}
+	}
+}
+
+// write prints a token. Any token leaves the line mid-expression; comment
+// restores the state it found afterwards.
+func (p *printer) write(n *tree_sitter.Node, text string) {
+	start := uint(p.out.Len())
+	p.out.WriteString(text)
+	p.segments.Add(n.StartByte(), n.StartByte()+uint(len(text)), start, uint(p.out.Len()))
+	p.sep = sepNone
+	p.cont = true
+	p.prevEnd = n.EndByte()
+	p.started = true
+}
+
+func hasComment(n *tree_sitter.Node) bool {
+	if n.Kind() == "comment" {
+		return true
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		if hasComment(n.Child(i)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,315 @@
+package format_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/gen"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func TestSource(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"1 + 2 * 3", "1+2*3\n"},
+		{"x :42", "x: 42\n"},
+		{"- -5 !", "--5!\n"},
+		{"2 ^ - 3", "2^-3\n"},
+		{"add : { [ x ; y ] x + y }", "add: {[x;y] x+y}\n"},
+		{"f: { x+1 }", "f: {x+1}\n"},
+		{"f[ 1 ; g[ ] ]", "f[1;g[]]\n"},
+		{"( 1 + 2 ) * 3", "(1+2)*3\n"},
+		{"f: {[x;y]\n x + y}", "f: {[x;y]\n  x+y\n}\n"},
+		{"f: { \\ doubles\n x*2 }", "f: { \\ doubles\n  x*2\n}\n"},
+		{"\\ head  \n\n\n\\ two\nx: 1 + \\ mid\n 2    \\ tail", "\\ head\n\n\\ two\nx: 1+ \\ mid\n  2 \\ tail\n"},
+	}
+	for _, tt := range tests {
+		got, err := format.Source([]byte(tt.in))
+		if err != nil {
+			t.Errorf("Source(%q): %v", tt.in, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Source(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+		again, err := format.Source(got)
+		if err != nil || string(again) != string(got) {
+			t.Errorf("Source is not idempotent on %q: %q, %v", got, again, err)
+		}
+	}
+}
+
+func TestSourceIdempotentOnGenerated(t *testing.T) {
+	styled := format.Config{MaxWidth: 30, SpaceOperators: true, Braces: format.BraceHug}
+	for _, cfg := range []format.Config{{}, styled} {
+		g := gen.New(1, gen.Config{Extras: true})
+		for range 500 {
+			src := g.Program()
+			once, err := cfg.Source(src)
+			if err != nil {
+				t.Fatalf("%+v.Source(%q): %v", cfg, src, err)
+			}
+			twice, err := cfg.Source(once)
+			if err != nil || string(twice) != string(once) {
+				t.Fatalf("%+v.Source is not idempotent on %q: %q, then %q, %v", cfg, src, once, twice, err)
+			}
+		}
+	}
+}
+
+func TestSourceWithMap(t *testing.T) {
+	src := []byte("f : { [ x ; y ]\n x  +  undefined }")
+	out, m, err := format.SourceWithMap(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A diagnostic on the original lands on the same text in the output.
+	at := strings.Index(string(src), "undefined")
+	r := m.Project(tree_sitter.Range{StartByte: uint(at), EndByte: uint(at + len("undefined"))}, out)
+	if got := string(out[r.StartByte:r.EndByte]); got != "undefined" {
+		t.Errorf("projected range covers %q in %q", got, out)
+	}
+	if r.StartPoint.Row != 1 || r.StartPoint.Column != 4 {
+		t.Errorf("projected start = %+v, want 1:4", r.StartPoint)
+	}
+	// And back.
+	start, end := m.Invert().Range(r.StartByte, r.EndByte)
+	if start != uint(at) || end != uint(at+len("undefined")) {
+		t.Errorf("inverted range = %d, %d, want %d, %d", start, end, at, at+len("undefined"))
+	}
+}
+
+func TestSourceSyntaxError(t *testing.T) {
+	_, err := format.Source([]byte("f: {x+"))
+	if !errors.Is(err, format.ErrSyntax) {
+		t.Fatalf("err = %v, want ErrSyntax", err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	tests := []struct {
+		cfg     format.Config
+		in      string
+		want    string
+		comment string
+	}{
+		{format.Config{SpaceOperators: true}, "x: 1+2*-3!", "x: 1 + 2 * -3!\n", "binary operators only"},
+		{format.Config{SpaceOperators: true}, "f[a^2;b-c]", "f[a ^ 2;b - c]\n", ""},
+		{format.Config{SpaceOperators: true}, "1+ \\ mid\n2", "1 + \\ mid\n  2\n", ""},
+		{format.Config{Braces: format.BraceHug}, "f: {[x]\n x+1 }", "f: {[x]\n  x+1}\n", ""},
+		{format.Config{Braces: format.BraceHug}, "f: {[x]\n x+1 \\ c\n}", "f: {[x]\n  x+1 \\ c\n}\n", "a comment keeps the brace off its line"},
+		{format.Config{MaxWidth: 16}, "f: {[x;y] x*y+x*y+1}", "f: {[x;y]\n  x*y+x*y+1\n}\n", "breaks an overflowing body"},
+		{format.Config{MaxWidth: 30}, "f: {[x;y] x*y+x*y+1}", "f: {[x;y] x*y+x*y+1}\n", "leaves one that fits"},
+		{format.Config{MaxWidth: 5}, "1+2+3+4", "1+2+3+4\n", "only bodies break"},
+	}
+	for _, tt := range tests {
+		got, err := tt.cfg.Source([]byte(tt.in))
+		if err != nil {
+			t.Errorf("%+v.Source(%q): %v", tt.cfg, tt.in, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%+v.Source(%q) = %q, want %q %s", tt.cfg, tt.in, got, tt.want, tt.comment)
+		}
+		again, err := tt.cfg.Source(got)
+		if err != nil || string(again) != string(got) {
+			t.Errorf("%+v.Source is not idempotent on %q: %q, %v", tt.cfg, got, again, err)
+		}
+	}
+}
+
+func TestScript(t *testing.T) {
+	src := "\\ settings\nx : 1\n\n\n  total:x+ 2\nf:{[a]\n a}\nname :3 \\ why\n\\ end  \n\n"
+	tests := []struct {
+		cfg  format.Config
+		want string
+	}{
+		{format.Config{}, "\\ settings\nx: 1\n\ntotal: x+2\nf: {[a]\n  a\n}\nname: 3 \\ why\n\\ end\n"},
+		{format.Config{AlignAssignments: true}, "\\ settings\nx: 1\n\ntotal: x+2\nf: {[a]\n  a\n}\nname: 3 \\ why\n\\ end\n"},
+	}
+	for _, tt := range tests {
+		got, err := tt.cfg.Script([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%+v.Script = %q, want %q", tt.cfg, got, tt.want)
+		}
+	}
+
+	cfg := format.Config{AlignAssignments: true}
+	got, err := cfg.Script([]byte("x: 1\ntotal: 2\nf: {x}\n\nlong_name: 3\ny: 4\n"))
+	if want := "x:     1\ntotal: 2\nf:     {x}\n\nlong_name: 3\ny:         4\n"; err != nil || string(got) != want {
+		t.Errorf("aligned = %q, %v; want %q", got, err, want)
+	}
+	if again, err := cfg.Script(got); err != nil || string(again) != string(got) {
+		t.Errorf("Script is not idempotent on %q: %q, %v", got, again, err)
+	}
+
+	// A single statement formats as Source formats it.
+	one := "x: 1 + \\ mid\n 2"
+	want, _ := format.Source([]byte(one))
+	if got, err := cfg.Script([]byte(one)); err != nil || string(got) != string(want) {
+		t.Errorf("Script(%q) = %q, %v; want %q", one, got, err, want)
+	}
+
+	if _, err := cfg.Script([]byte("x: 1\ny: {\n")); !errors.Is(err, format.ErrSyntax) || !strings.Contains(err.Error(), "2:") {
+		t.Errorf("broken script: %v", err)
+	}
+}
+
+func TestLineEndings(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"f : {[x]\r\n x + 1 }\r\n", "f: {[x]\r\n  x+1\r\n}\r\n"},
+		{"\xef\xbb\xbfx :1", "\xef\xbb\xbfx: 1\n"},
+		{"\xef\xbb\xbf\\ head\r\nx :1\r\n", "\xef\xbb\xbf\\ head\r\nx: 1\r\n"},
+		// Mixed endings become the prevailing one.
+		{"f: {[x]\r\n x\r\n +1}\n", "f: {[x]\r\n  x+1\r\n}\r\n"},
+	}
+	for _, tt := range tests {
+		got, err := format.Source([]byte(tt.in))
+		if err != nil || string(got) != tt.want {
+			t.Errorf("Source(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+		if got, err := (format.Config{}).Script([]byte(tt.in)); err != nil || string(got) != tt.want {
+			t.Errorf("Script(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+		edits, err := format.Config{}.Edits([]byte(tt.in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := apply([]byte(tt.in), edits); got != tt.want {
+			t.Errorf("Edits of %q give %q, want %q", tt.in, got, tt.want)
+		}
+	}
+
+	src := "\xef\xbb\xbfx : 1\r\n\r\n\r\n\\ note\r\ny:{[a]\r\n a}\r\n"
+	want := "\xef\xbb\xbfx: 1\r\n\r\n\\ note\r\ny: {[a]\r\n  a\r\n}\r\n"
+	if got, err := (format.Config{}).Script([]byte(src)); err != nil || string(got) != want {
+		t.Errorf("Script(%q) = %q, %v; want %q", src, got, err, want)
+	}
+
+	crlf := []byte("f : { [ a ]\r\n a }\r\n")
+	edits, err := format.Config{}.Lines(crlf, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := apply(crlf, edits), "f: {[a]\r\n a }\r\n"; got != want {
+		t.Errorf("Lines gives %q, want %q", got, want)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	c, err := format.ParseConfig(strings.NewReader("# team style\n\nmax_width = 80\nspace_operators=true\nbraces = hug\nalign_assignments = yes\n"), "x")
+	if err == nil {
+		t.Errorf("yes is not a boolean: %+v", c)
+	}
+	c, err = format.ParseConfig(strings.NewReader("# team style\n\nmax_width = 80\nspace_operators=true\nbraces = hug\nalign_assignments = 1\n"), "x")
+	if want := (format.Config{MaxWidth: 80, SpaceOperators: true, Braces: format.BraceHug, AlignAssignments: true}); err != nil || c != want {
+		t.Errorf("ParseConfig = %+v, %v; want %+v", c, err, want)
+	}
+	for _, bad := range []string{"width = 80", "braces = left", "max_width", "max_width = -1"} {
+		if _, err := format.ParseConfig(strings.NewReader("\n"+bad), "style"); err == nil || !strings.HasPrefix(err.Error(), "style:2: ") {
+			t.Errorf("ParseConfig(%q): %v", bad, err)
+		}
+	}
+}
+
+func TestConfigFor(t *testing.T) {
+	root := t.TempDir()
+	deep := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(deep, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if c, file, err := format.ConfigFor(filepath.Join(deep, "x.wz")); err != nil || file != "" || c != (format.Config{}) {
+		t.Errorf("without a file: %+v, %q, %v", c, file, err)
+	}
+	path := filepath.Join(root, "a", format.ConfigFile)
+	if err := os.WriteFile(path, []byte("braces = hug\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if c, file, err := format.ConfigFor(filepath.Join(deep, "x.wz")); err != nil || file != path || c.Braces != format.BraceHug {
+		t.Errorf("from a parent: %+v, %q, %v", c, file, err)
+	}
+}
+
+func apply(src []byte, edits []tree_sitter_wabznasm.TextEdit) string {
+	out, _ := tree_sitter_wabznasm.ApplyFixes(src, []tree_sitter_wabznasm.Fix{{Edits: edits}})
+	return string(out)
+}
+
+func TestRange(t *testing.T) {
+	src := []byte("f : { [ a ; b ]\n a  +  g[ a ; b ] }")
+	edits, err := format.Config{}.Edits(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := format.Source(src)
+	if got := apply(src, edits); got != string(want) {
+		t.Errorf("Edits give %q, want %q", got, want)
+	}
+
+	at := uint(strings.Index(string(src), "g["))
+	edits, err = format.Config{}.Range(src, at, at+uint(len("g[ a ; b ]")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := apply(src, edits), "f : { [ a ; b ]\n a  +  g[a;b] }"; got != want {
+		t.Errorf("Range gives %q, want %q", got, want)
+	}
+
+	edits, err = format.Config{}.Lines(src, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := apply(src, edits), "f: {[a;b]\n a  +  g[ a ; b ] }"; got != want {
+		t.Errorf("Lines gives %q, want %q", got, want)
+	}
+	edits, err = format.Config{}.Lines(src, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := apply(src, edits), "f : { [ a ; b ]\n  a+g[a;b]\n}"; got != want {
+		t.Errorf("Lines gives %q, want %q", got, want)
+	}
+
+	if _, err := (format.Config{}).Range([]byte("f[1;"), 0, 1); !errors.Is(err, format.ErrSyntax) {
+		t.Errorf("broken source: %v", err)
+	}
+}
+
+func TestOnType(t *testing.T) {
+	tests := []struct {
+		src   string
+		ch    byte
+		after string // the text the character was typed at the end of
+		want  string
+	}{
+		{"x: f[ 1 ;2]+ 1", ';', "f[ 1 ;", "x: f[1;2]+ 1"},
+		{"x: f[ 1 ;2]+ 1", ']', "f[ 1 ;2]", "x: f[1;2]+ 1"},
+		{"g : { [ a ] a + 1 }", '}', "a + 1 }", "g : {[a] a+1}"},
+		{"x: ( 1 + 2 )*3", ')', "( 1 + 2 )", "x: (1+2)*3"},
+		{"x: 1 + 2", '+', "1 +", "x: 1 + 2"},
+		{"x: 1 \\ f[ ;", ';', "f[ ;", "x: 1 \\ f[ ;"},
+	}
+	for _, tt := range tests {
+		offset := uint(strings.Index(tt.src, tt.after) + len(tt.after))
+		edits, err := format.Config{}.OnType([]byte(tt.src), offset, tt.ch)
+		if err != nil {
+			t.Errorf("OnType(%q, %q): %v", tt.src, tt.ch, err)
+			continue
+		}
+		if got := apply([]byte(tt.src), edits); got != tt.want {
+			t.Errorf("OnType(%q, %q) gives %q, want %q", tt.src, tt.ch, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package format
+
+import (
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lineindex"
+)
+
+// Edits returns the edits that format src in the style of c, each
+// replacing the whitespace between two tokens. Applied together they give
+// what Script returns.
+func (c Config) Edits(src []byte) ([]tree_sitter_wabznasm.TextEdit, error) {
+	return c.edits(src, func(start, end uint) bool { return true })
+}
+
+// Range returns the edits that format the part of src from byte start to
+// byte end, leaving the rest as it is. The whole source is formatted, so
+// that the layout of the range follows from its context, but only the
+// edits of whitespace inside the range or straddling its ends are
+// returned; whitespace just outside it, such as the line break a closing
+// brace may gain after it, is left alone.
+func (c Config) Range(src []byte, start, end uint) ([]tree_sitter_wabznasm.TextEdit, error) {
+	return c.edits(src, func(gapStart, gapEnd uint) bool {
+		overlaps := gapStart < end && gapEnd > start
+		between := gapStart == gapEnd && start < gapStart && gapStart < end
+		return overlaps || between
+	})
+}
+
+// edits returns the edits of the whitespace between tokens for which
+// keep, given the byte range of the whitespace, returns true.
+func (c Config) edits(src []byte, keep func(gapStart, gapEnd uint) bool) ([]tree_sitter_wabznasm.TextEdit, error) {
+	out, err := c.Script(src)
+	if err != nil {
+		return nil, err
+	}
+	before, err := gaps(src)
+	if err != nil {
+		return nil, err
+	}
+	after, err := gaps(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(before) != len(after) {
+		// The formatter only changes whitespace.
+		panic("format: formatting changed the tokens")
+	}
+	var edits []tree_sitter_wabznasm.TextEdit
+	lines := lineindex.New(src)
+	for i, g := range before {
+		text := string(out[after[i][0]:after[i][1]])
+		if !keep(g[0], g[1]) || string(src[g[0]:g[1]]) == text {
+			continue
+		}
+		edits = append(edits, tree_sitter_wabznasm.TextEdit{
+			Range:   lines.Range(g[0], g[1]),
+			NewText: text,
+		})
+	}
+	return edits, nil
+}
+
+// Lines is like Range for the zero-based lines first to last of src. The
+// indentation of line first is formatted, and the line break ending line
+// last, '\n' or "\r\n", is not.
+func (c Config) Lines(src []byte, first, last uint) ([]tree_sitter_wabznasm.TextEdit, error) {
+	lines := lineindex.New(src)
+	start, end := lines.LineStart(first), lines.LineStart(last+1)
+	if end > start && src[end-1] == '\n' {
+		end--
+		if end > start && src[end-1] == '\r' {
+			end--
+		}
+	}
+	return c.Range(src, start, end)
+}
+
+// OnType returns the edits that format the construct closed by ch, just
+// typed before offset: the function body of a }, the call or parameter
+// list of a ] or ;, and the parentheses of a ). It returns no edits for
+// other characters, or if ch is not a token there, as in a comment.
+func (c Config) OnType(src []byte, offset uint, ch byte) ([]tree_sitter_wabznasm.TextEdit, error) {
+	if !strings.ContainsRune("}];)", rune(ch)) || offset == 0 || offset > uint(len(src)) {
+		return nil, nil
+	}
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	s, err := parser.ParseScript(src)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+	tok := leafEndingAt(s.At(offset-1).Tree.RootNode(), offset)
+	if tok == nil || tok.IsNamed() || tok.Kind() != string(ch) {
+		return nil, nil
+	}
+	n := tok.Parent()
+	if n != nil && n.Kind() == "argument_list" {
+		n = n.Parent()
+	}
+	if n == nil {
+		return nil, nil
+	}
+	return c.Range(src, n.StartByte(), n.EndByte())
+}
+
+// leafEndingAt returns the leaf of n whose last byte is just before
+// offset, or nil if none is.
+func leafEndingAt(n *tree_sitter.Node, offset uint) *tree_sitter.Node {
+	for n.ChildCount() > 0 {
+		var next *tree_sitter.Node
+		for i := uint(0); i < n.ChildCount(); i++ {
+			if c := n.Child(i); c.StartByte() < offset && offset <= c.EndByte() {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		n = next
+	}
+	if n.EndByte() != offset {
+		return nil
+	}
+	return n
+}
+
+// gaps returns the whitespace between the tokens of src as byte ranges:
+// before the first token, between each two, and after the last. The
+// trailing blanks of a comment, which the formatter drops, count as
+// whitespace.
+func gaps(src []byte) ([][2]uint, error) {
+	tokens, err := tree_sitter_wabznasm.Tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	var out [][2]uint
+	prev := uint(0)
+	for _, t := range tokens {
+		if t.IsWhitespace() {
+			continue
+		}
+		text := t.Text
+		if t.IsComment() {
+			text = strings.TrimRight(text, " \t")
+		}
+		out = append(out, [2]uint{prev, t.Range.StartByte})
+		prev = t.Range.StartByte + uint(len(text))
+	}
+	return append(out, [2]uint{prev, uint(len(src))}), nil
+}
@@ -0,0 +1,130 @@
+package format
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/script"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/normalize"
+)
+
+// Script formats src in the style of c. A source holding a single
+// statement is formatted as Source formats it; otherwise src is divided
+// into statements as the REPL divides scripts, and each is formatted on
+// its own. Comment lines between statements are kept, and runs of blank
+// lines become one. The source is normalized first, and the result keeps
+// its byte order mark and prevailing line ending.
+func (c Config) Script(src []byte) ([]byte, error) {
+	t := normalize.Normalize(src, normalize.Options{})
+	out, err := c.script(t.Src)
+	if err != nil {
+		return nil, err
+	}
+	return t.Restore(out), nil
+}
+
+// script formats src, a normalized source, as Script does.
+func (c Config) script(src []byte) ([]byte, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	tree, err := parser.ParseBytes(src)
+	if err != nil {
+		return nil, err
+	}
+	single := !tree.RootNode().HasError()
+	if single {
+		defer tree.Close()
+		var buf bytes.Buffer
+		err := c.Tree(&buf, tree.Tree, src)
+		return buf.Bytes(), err
+	}
+	tree.Close()
+
+	var lines []scriptLine
+	pos, blank := 0, false
+	gap := func(end int) {
+		for _, line := range strings.SplitAfter(string(src[pos:end]), "\n") {
+			switch t := strings.TrimSpace(line); {
+			case line == "":
+			case t == "":
+				blank = true
+			default:
+				lines = append(lines, scriptLine{text: t, blank: blank})
+				blank = false
+			}
+		}
+	}
+	for _, e := range script.Split(string(src)) {
+		gap(e.Offset)
+		isolated := e.Isolate(src)
+		tree, err := parser.ParseBytes(isolated)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		err = c.Tree(&buf, tree.Tree, isolated)
+		tree.Close()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, scriptLine{text: strings.TrimSuffix(buf.String(), "\n"), blank: blank, statement: true})
+		blank = false
+		// A statement ends at the end of a line; the gap starts on the next.
+		pos = e.Offset + len(e.Text)
+		if i := bytes.IndexByte(src[pos:], '\n'); i >= 0 {
+			pos += i + 1
+		} else {
+			pos = len(src)
+		}
+	}
+	gap(len(src))
+
+	if c.AlignAssignments {
+		align(lines)
+	}
+	var out bytes.Buffer
+	for i, l := range lines {
+		if l.blank && i > 0 {
+			out.WriteByte('\n')
+		}
+		out.WriteString(l.text)
+		out.WriteByte('\n')
+	}
+	return out.Bytes(), nil
+}
+
+// scriptLine is a formatted statement or a comment line of a script.
+type scriptLine struct {
+	text string
+	// blank reports a blank line before it.
+	blank     bool
+	statement bool
+}
+
+// assignmentHead matches the name and colon of a formatted assignment.
+var assignmentHead = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*:`)
+
+// align pads the colons of each run of one-line assignments on
+// consecutive lines, so that their values line up.
+func align(lines []scriptLine) {
+	for i := 0; i < len(lines); {
+		j, width := i, 0
+		for ; j < len(lines) && aligned(lines[j]) && (j == i || !lines[j].blank); j++ {
+			width = max(width, len(assignmentHead.FindString(lines[j].text)))
+		}
+		for k := i; k < j; k++ {
+			head := assignmentHead.FindString(lines[k].text)
+			lines[k].text = head + strings.Repeat(" ", width-len(head)) + lines[k].text[len(head):]
+		}
+		i = max(j, i+1)
+	}
+}
+
+func aligned(l scriptLine) bool {
+	return l.statement && !strings.Contains(l.text, "\n") && assignmentHead.MatchString(l.text)
+}
@@ -0,0 +1,174 @@
+// Package fuzz helps fuzz code that consumes wabznasm parse trees.
+//
+// FuzzParse seeds a fuzz target with the grammar's test corpus, parses each
+// input under a time limit, verifies the structural invariants of the tree
+// and the conversions built on it, and then runs the caller's checks:
+//
+//	func FuzzMyRule(f *testing.F) {
+//		fuzz.FuzzParse(f, func(t *testing.T, tree *tree_sitter.Tree, src []byte) {
+//			myrule.Check(tree, src) // must not panic
+//		})
+//	}
+package fuzz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+	"unicode"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/grammartest"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+// Timeout bounds the time a single parse may take before FuzzParse reports
+// a hang.
+var Timeout = 2 * time.Second
+
+// Check is a property that must hold for every parsed input.
+type Check func(t *testing.T, tree *tree_sitter.Tree, source []byte)
+
+// seeds cover constructs that are easy to get wrong, in case the corpus is
+// not available.
+var seeds = []string{
+	"",
+	"x",
+	"2+3*4",
+	"-2^-3!",
+	"add: {[x;y] x+y}",
+	"f: {x*y+z}",
+	"f[1;g[2];(3)]",
+	"\\ comment\nx: 1 \\ trailing",
+	"f: {[x;x] x}",
+	"{}",
+	"(((",
+	"x: ]",
+}
+
+// CorpusDir returns the grammar's test/corpus directory, found relative
+// to this package's source. It returns "" when the source is unavailable,
+// as in binaries built with -trimpath.
+func CorpusDir() string {
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return ""
+	}
+	dir := filepath.Join(filepath.Dir(file), "..", "..", "..", "test", "corpus")
+	if _, err := os.Stat(dir); err != nil {
+		return ""
+	}
+	return dir
+}
+
+// Seed adds the built-in seeds and the source of every corpus case to f.
+func Seed(f *testing.F) {
+	for _, s := range seeds {
+		f.Add([]byte(s))
+	}
+	dir := CorpusDir()
+	if dir == "" {
+		return
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.txt"))
+	for _, path := range paths {
+		cases, err := grammartest.ReadCorpusFile(path)
+		if err != nil {
+			f.Fatalf("reading corpus: %v", err)
+		}
+		for _, c := range cases {
+			f.Add([]byte(c.Source))
+		}
+	}
+}
+
+// FuzzParse seeds f and fuzzes the parser. Each input must parse within
+// Timeout, produce a tree satisfying CheckTree, and survive conversion to
+// the typed AST and diagnostics; then checks run on the tree.
+func FuzzParse(f *testing.F, checks ...Check) {
+	Seed(f)
+	f.Fuzz(func(t *testing.T, src []byte) {
+		tree, err := Parse(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer tree.Close()
+		if err := CheckTree(tree.Tree, src); err != nil {
+			t.Fatalf("%v\nsource: %q\ntree: %s", err, src, tree.RootNode().ToSexp())
+		}
+		ast.FromTree(tree.Tree, src)
+		tree_sitter_wabznasm.Diagnostics(tree.Tree, src)
+		for _, check := range checks {
+			check(t, tree.Tree, src)
+		}
+	})
+}
+
+// Parse parses src, failing if it takes longer than Timeout.
+func Parse(src []byte) (*memtrack.TrackedTree, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), Timeout)
+	defer cancel()
+	tree, err := parser.ParseContext(ctx, src)
+	if errors.Is(err, context.DeadlineExceeded) {
+		return nil, fmt.Errorf("fuzz: parse of %d bytes did not finish within %v", len(src), Timeout)
+	}
+	return tree, err
+}
+
+// CheckTree verifies the structural invariants of a tree parsed from
+// source: the root spans everything but surrounding whitespace, every node
+// lies within its parent and after its previous sibling, and node points
+// agree with byte offsets.
+func CheckTree(tree *tree_sitter.Tree, source []byte) error {
+	root := tree.RootNode()
+	if end := root.EndByte(); end > uint(len(source)) {
+		return fmt.Errorf("root ends at %d, past the %d-byte input", end, len(source))
+	}
+	for _, r := range [][2]uint{{0, root.StartByte()}, {root.EndByte(), uint(len(source))}} {
+		outside := string(source[r[0]:r[1]])
+		for i, c := range outside {
+			if !unicode.IsSpace(c) {
+				return fmt.Errorf("%q at byte %d lies outside the root", c, r[0]+uint(i))
+			}
+		}
+	}
+	return checkNode(root, source)
+}
+
+func checkNode(n *tree_sitter.Node, source []byte) error {
+	if n.StartByte() > n.EndByte() {
+		return fmt.Errorf("%s: start %d after end %d", n.Kind(), n.StartByte(), n.EndByte())
+	}
+	for _, p := range []struct {
+		offset uint
+		point  tree_sitter.Point
+	}{{n.StartByte(), n.StartPosition()}, {n.EndByte(), n.EndPosition()}} {
+		if want := tree_sitter_wabznasm.PointForOffset(source, p.offset); want != p.point {
+			return fmt.Errorf("%s: offset %d at %v, want %v", n.Kind(), p.offset, p.point, want)
+		}
+	}
+	prev := n.StartByte()
+	for i := uint(0); i < n.ChildCount(); i++ {
+		c := n.Child(i)
+		if c.StartByte() < prev || c.EndByte() > n.EndByte() {
+			return fmt.Errorf("%s child %d (%s) spans %d-%d, outside %d-%d", n.Kind(), i, c.Kind(), c.StartByte(), c.EndByte(), prev, n.EndByte())
+		}
+		prev = c.EndByte()
+		if err := checkNode(c, source); err != nil {
+			return err
+		}
+	}
+	return nil
+}
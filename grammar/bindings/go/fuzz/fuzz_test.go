@@ -0,0 +1,37 @@
+package fuzz_test
+
+import (
+	"os"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/fuzz"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func TestCorpusDir(t *testing.T) {
+	if fuzz.CorpusDir() == "" {
+		t.Fatal("corpus directory not found")
+	}
+}
+
+// FuzzParse exercises the parser together with the formatter and linter.
+func FuzzParse(f *testing.F) {
+	fuzz.FuzzParse(f, func(t *testing.T, tree *tree_sitter.Tree, src []byte) {
+		lint.Run(tree, src)
+		out, err := format.Source(src)
+		if err != nil {
+			return
+		}
+		again, err := format.Source(out)
+		if err != nil || string(again) != string(out) {
+			t.Errorf("format is not idempotent on %q: %q then %q (%v)", src, out, again, err)
+		}
+	})
+}
@@ -0,0 +1,306 @@
+// Package gen generates random, syntactically valid wabznasm programs by
+// walking the productions of the grammar, compiled from grammar.json.
+//
+// Programs are for property-based and differential tests: the formatter
+// should be idempotent on them, two evaluators should agree on them, and
+// a reimplementation of the parser should accept each of them. A
+// Generator is seeded, so a failing program can be reproduced from its
+// seed, and its Config bounds the size and nesting of what it generates.
+package gen
+
+//go:generate go run ../cmd/gen-grammar -o grammar_gen.go ../../../src/grammar.json
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp/syntax"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// rule is a production of the grammar, as in grammar.json.
+type rule struct {
+	typ, name, value string
+	content          *rule
+	members          []*rule
+}
+
+// Defaults for the zero fields of Config.
+const (
+	DefaultMaxDepth  = 4
+	DefaultMaxTokens = 40
+	DefaultMaxRepeat = 3
+)
+
+// Config bounds what a Generator generates.
+type Config struct {
+	// MaxDepth bounds how deeply a rule may nest within itself, as an
+	// expression does within parentheses or in the operand of an
+	// operator. Past it, choices take the shortest way out.
+	MaxDepth int
+	// MaxTokens is the size a program grows to before its choices take
+	// the shortest way out and its optional and repeated parts are left
+	// out; the program is finished from there, so it may overrun a
+	// little.
+	MaxTokens int
+	// MaxRepeat bounds the repetitions of repeated parts, and of the
+	// repeated characters of patterns.
+	MaxRepeat int
+	// Extras puts whitespace and comments between some tokens, as the
+	// grammar's extras allow. Whitespace may include newlines.
+	Extras bool
+	// Tokens supplies, by rule name, the texts to choose from instead of
+	// generating the rule, such as a few identifiers for programs to
+	// share, or small numbers.
+	Tokens map[string][]string
+}
+
+// Generator generates programs. It is not safe for concurrent use.
+type Generator struct {
+	cfg      Config
+	rand     *rand.Rand
+	cost     map[string]int
+	patterns map[string]*syntax.Regexp
+
+	// The state of the program being generated.
+	tokens []string
+	nest   map[string]int
+	token  *strings.Builder // the text of a TOKEN being put together
+}
+
+// New returns a generator of programs, seeded with seed.
+func New(seed int64, cfg Config) *Generator {
+	if cfg.MaxDepth <= 0 {
+		cfg.MaxDepth = DefaultMaxDepth
+	}
+	if cfg.MaxTokens <= 0 {
+		cfg.MaxTokens = DefaultMaxTokens
+	}
+	if cfg.MaxRepeat <= 0 {
+		cfg.MaxRepeat = DefaultMaxRepeat
+	}
+	return &Generator{
+		cfg:      cfg,
+		rand:     rand.New(rand.NewSource(seed)),
+		cost:     costs(),
+		patterns: map[string]*syntax.Regexp{},
+	}
+}
+
+// Rules returns the names of the rules of the grammar, in name order.
+func Rules() []string {
+	names := make([]string, 0, len(rules))
+	for name := range rules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Program returns a new program: a derivation of the start rule of the
+// grammar.
+func (g *Generator) Program() []byte {
+	src, _ := g.Rule(start)
+	return src
+}
+
+// Rule returns a new derivation of the rule called name, such as
+// expression.
+func (g *Generator) Rule(name string) ([]byte, error) {
+	if rules[name] == nil {
+		return nil, fmt.Errorf("gen: no rule %q", name)
+	}
+	g.tokens = g.tokens[:0]
+	g.nest = map[string]int{}
+	g.symbol(name)
+	return []byte(g.join()), nil
+}
+
+// join joins the tokens of the program, with a space between tokens that
+// would otherwise run together and, with Extras, some extras between
+// others.
+func (g *Generator) join() string {
+	var b strings.Builder
+	for i, tok := range g.tokens {
+		if i > 0 {
+			prev := g.tokens[i-1]
+			switch {
+			case g.cfg.Extras && g.rand.Intn(4) == 0:
+				b.WriteString(g.extra())
+				if isWord(prev) && isWord(tok) && !strings.HasSuffix(b.String(), "\n") {
+					b.WriteByte(' ')
+				}
+			case isWord(prev) && isWord(tok):
+				b.WriteByte(' ')
+			}
+		}
+		b.WriteString(tok)
+	}
+	return b.String()
+}
+
+// isWord reports whether tok consists of letters, digits and
+// underscores, and so would merge with a neighbouring word.
+func isWord(tok string) bool {
+	for _, r := range tok {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return tok != ""
+}
+
+// extra returns the text of an extra. Comments run to the end of the
+// line, so one is followed by a newline.
+func (g *Generator) extra() string {
+	r := extras[g.rand.Intn(len(extras))]
+	if r.typ == "SYMBOL" {
+		return g.pattern(rules[r.name].value) + "\n"
+	}
+	return g.pattern(r.value)
+}
+
+// exhausted reports whether choices should take the shortest way out.
+func (g *Generator) exhausted() bool {
+	if len(g.tokens) >= g.cfg.MaxTokens {
+		return true
+	}
+	for _, n := range g.nest {
+		if n > g.cfg.MaxDepth {
+			return true
+		}
+	}
+	return false
+}
+
+func (g *Generator) symbol(name string) {
+	if texts := g.cfg.Tokens[name]; len(texts) > 0 {
+		g.emit(texts[g.rand.Intn(len(texts))])
+		return
+	}
+	g.nest[name]++
+	g.walk(rules[name])
+	g.nest[name]--
+}
+
+func (g *Generator) emit(text string) {
+	if g.token != nil {
+		g.token.WriteString(text)
+		return
+	}
+	g.tokens = append(g.tokens, text)
+}
+
+func (g *Generator) walk(r *rule) {
+	switch r.typ {
+	case "BLANK":
+	case "STRING":
+		g.emit(r.value)
+	case "PATTERN":
+		g.emit(g.pattern(r.value))
+	case "SYMBOL":
+		g.symbol(r.name)
+	case "SEQ":
+		for _, m := range r.members {
+			g.walk(m)
+		}
+	case "CHOICE":
+		g.walk(g.choose(r.members))
+	case "REPEAT", "REPEAT1":
+		n := 0
+		if !g.exhausted() {
+			n = g.rand.Intn(g.cfg.MaxRepeat + 1)
+		}
+		if r.typ == "REPEAT1" && n == 0 {
+			n = 1
+		}
+		for range n {
+			g.walk(r.content)
+		}
+	case "TOKEN", "IMMEDIATE_TOKEN":
+		if g.token != nil {
+			g.walk(r.content)
+			return
+		}
+		g.token = &strings.Builder{}
+		g.walk(r.content)
+		text := g.token.String()
+		g.token = nil
+		g.emit(text)
+	default:
+		// FIELD, ALIAS, PREC and the like wrap the production.
+		if r.content != nil {
+			g.walk(r.content)
+		}
+	}
+}
+
+// choose picks a member of a choice: any, or once the program is
+// exhausted, one of the shortest.
+func (g *Generator) choose(members []*rule) *rule {
+	if !g.exhausted() {
+		return members[g.rand.Intn(len(members))]
+	}
+	best := []*rule{}
+	least := -1
+	for _, m := range members {
+		c := cost(m, g.cost)
+		switch {
+		case least < 0 || c < least:
+			best, least = []*rule{m}, c
+		case c == least:
+			best = append(best, m)
+		}
+	}
+	return best[g.rand.Intn(len(best))]
+}
+
+// infinite is the cost of a rule with no finite derivation found yet.
+const infinite = 1 << 30
+
+// costs returns the least number of tokens each rule derives.
+func costs() map[string]int {
+	c := map[string]int{}
+	for name := range rules {
+		c[name] = infinite
+	}
+	for changed := true; changed; {
+		changed = false
+		for name, r := range rules {
+			if n := cost(r, c); n < c[name] {
+				c[name], changed = n, true
+			}
+		}
+	}
+	return c
+}
+
+// cost returns the least number of tokens r derives, given those of the
+// rules it refers to.
+func cost(r *rule, symbols map[string]int) int {
+	switch r.typ {
+	case "BLANK", "REPEAT":
+		return 0
+	case "STRING", "PATTERN", "TOKEN", "IMMEDIATE_TOKEN":
+		return 1
+	case "SYMBOL":
+		return symbols[r.name]
+	case "SEQ":
+		n := 0
+		for _, m := range r.members {
+			n = min(n+cost(m, symbols), infinite)
+		}
+		return n
+	case "CHOICE":
+		n := infinite
+		for _, m := range r.members {
+			n = min(n, cost(m, symbols))
+		}
+		return n
+	}
+	if r.content == nil {
+		return 0
+	}
+	return cost(r.content, symbols)
+}
@@ -0,0 +1,103 @@
+package gen_test
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/gen"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func parses(t *testing.T, src []byte) {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseBytes(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	if tree.RootNode().HasError() {
+		t.Errorf("syntax error in %q: %s", src, tree.RootNode().ToSexp())
+	}
+}
+
+func TestPrograms(t *testing.T) {
+	for _, cfg := range []gen.Config{
+		{},
+		{Extras: true},
+		{MaxDepth: 8, MaxTokens: 200},
+		{MaxDepth: 1, MaxTokens: 1},
+	} {
+		g := gen.New(1, cfg)
+		sizes := map[bool]int{}
+		for range 300 {
+			src := g.Program()
+			parses(t, src)
+			sizes[len(src) > 20]++
+		}
+		if cfg.MaxTokens == 1 && sizes[true] > 0 {
+			t.Errorf("%+v: %d programs over 20 bytes", cfg, sizes[true])
+		}
+		if cfg.MaxTokens == 200 && sizes[true] == 0 {
+			t.Errorf("%+v: no program over 20 bytes", cfg)
+		}
+	}
+}
+
+func TestSeed(t *testing.T) {
+	a, b := gen.New(7, gen.Config{Extras: true}), gen.New(7, gen.Config{Extras: true})
+	for range 20 {
+		if x, y := a.Program(), b.Program(); !bytes.Equal(x, y) {
+			t.Fatalf("same seed, different programs %q and %q", x, y)
+		}
+	}
+}
+
+func TestRule(t *testing.T) {
+	g := gen.New(3, gen.Config{Tokens: map[string][]string{"identifier": {"x", "y"}, "number": {"1"}}})
+	for range 100 {
+		src, err := g.Rule("expression")
+		if err != nil {
+			t.Fatal(err)
+		}
+		parses(t, src)
+		parser, _ := tree_sitter_wabznasm.NewParser()
+		tree, _ := parser.ParseBytes(src)
+		f := ast.FromTree(tree.Tree, src)
+		if _, ok := f.Stmt.(*ast.ExprStmt); !ok {
+			t.Errorf("%q is not an expression", src)
+		}
+		ast.Inspect(f.Stmt, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.Ident:
+				if n.Name != "x" && n.Name != "y" {
+					t.Errorf("%q: identifier %s", src, n.Name)
+				}
+			case *ast.NumberLiteral:
+				if n.Text != "1" {
+					t.Errorf("%q: number %s", src, n.Text)
+				}
+			}
+			return true
+		})
+		tree.Close()
+		parser.Close()
+	}
+	if _, err := g.Rule("nonesuch"); err == nil {
+		t.Error("no error for an unknown rule")
+	}
+	if rules := gen.Rules(); len(rules) == 0 || rules[0] != "additive" {
+		t.Errorf("Rules() = %q", rules)
+	}
+}
@@ -0,0 +1,135 @@
+// Code generated by gen-grammar from grammar.json; DO NOT EDIT.
+
+package gen
+
+// start is the rule of the root node.
+const start = "source_file"
+
+// rules are the productions of the grammar, by name.
+var rules = map[string]*rule{
+	"source_file": {typ: "SYMBOL", name: "statement"},
+	"statement": {typ: "CHOICE", members: []*rule{
+		{typ: "SYMBOL", name: "assignment"},
+		{typ: "SYMBOL", name: "expression"},
+	}},
+	"assignment": {typ: "PREC_RIGHT", content: &rule{typ: "SEQ", members: []*rule{
+		{typ: "FIELD", name: "name", content: &rule{typ: "SYMBOL", name: "identifier"}},
+		{typ: "FIELD", name: "operator", content: &rule{typ: "STRING", value: ":"}},
+		{typ: "FIELD", name: "value", content: &rule{typ: "CHOICE", members: []*rule{
+			{typ: "SYMBOL", name: "function_body"},
+			{typ: "SYMBOL", name: "expression"},
+		}}},
+	}}},
+	"function_body": {typ: "SEQ", members: []*rule{
+		{typ: "FIELD", name: "left_brace", content: &rule{typ: "STRING", value: "{"}},
+		{typ: "CHOICE", members: []*rule{
+			{typ: "FIELD", name: "params", content: &rule{typ: "SYMBOL", name: "parameter_list"}},
+			{typ: "BLANK"},
+		}},
+		{typ: "FIELD", name: "body", content: &rule{typ: "SYMBOL", name: "expression"}},
+		{typ: "FIELD", name: "right_brace", content: &rule{typ: "STRING", value: "}"}},
+	}},
+	"parameter_list": {typ: "SEQ", members: []*rule{
+		{typ: "FIELD", name: "left_bracket", content: &rule{typ: "STRING", value: "["}},
+		{typ: "CHOICE", members: []*rule{
+			{typ: "SEQ", members: []*rule{
+				{typ: "FIELD", name: "param", content: &rule{typ: "SYMBOL", name: "identifier"}},
+				{typ: "REPEAT", content: &rule{typ: "SEQ", members: []*rule{
+					{typ: "FIELD", name: "separator", content: &rule{typ: "STRING", value: ";"}},
+					{typ: "FIELD", name: "param", content: &rule{typ: "SYMBOL", name: "identifier"}},
+				}}},
+			}},
+			{typ: "BLANK"},
+		}},
+		{typ: "FIELD", name: "right_bracket", content: &rule{typ: "STRING", value: "]"}},
+	}},
+	"expression": {typ: "SYMBOL", name: "additive"},
+	"additive": {typ: "CHOICE", members: []*rule{
+		{typ: "PREC_LEFT", content: &rule{typ: "SEQ", members: []*rule{
+			{typ: "FIELD", name: "left", content: &rule{typ: "SYMBOL", name: "additive"}},
+			{typ: "FIELD", name: "operator", content: &rule{typ: "STRING", value: "+"}},
+			{typ: "FIELD", name: "right", content: &rule{typ: "SYMBOL", name: "multiplicative"}},
+		}}},
+		{typ: "PREC_LEFT", content: &rule{typ: "SEQ", members: []*rule{
+			{typ: "FIELD", name: "left", content: &rule{typ: "SYMBOL", name: "additive"}},
+			{typ: "FIELD", name: "operator", content: &rule{typ: "STRING", value: "-"}},
+			{typ: "FIELD", name: "right", content: &rule{typ: "SYMBOL", name: "multiplicative"}},
+		}}},
+		{typ: "SYMBOL", name: "multiplicative"},
+	}},
+	"multiplicative": {typ: "CHOICE", members: []*rule{
+		{typ: "PREC_LEFT", content: &rule{typ: "SEQ", members: []*rule{
+			{typ: "FIELD", name: "left", content: &rule{typ: "SYMBOL", name: "multiplicative"}},
+			{typ: "FIELD", name: "operator", content: &rule{typ: "STRING", value: "*"}},
+			{typ: "FIELD", name: "right", content: &rule{typ: "SYMBOL", name: "unary"}},
+		}}},
+		{typ: "PREC_LEFT", content: &rule{typ: "SEQ", members: []*rule{
+			{typ: "FIELD", name: "left", content: &rule{typ: "SYMBOL", name: "multiplicative"}},
+			{typ: "FIELD", name: "operator", content: &rule{typ: "STRING", value: "/"}},
+			{typ: "FIELD", name: "right", content: &rule{typ: "SYMBOL", name: "unary"}},
+		}}},
+		{typ: "PREC_LEFT", content: &rule{typ: "SEQ", members: []*rule{
+			{typ: "FIELD", name: "left", content: &rule{typ: "SYMBOL", name: "multiplicative"}},
+			{typ: "FIELD", name: "operator", content: &rule{typ: "STRING", value: "%"}},
+			{typ: "FIELD", name: "right", content: &rule{typ: "SYMBOL", name: "unary"}},
+		}}},
+		{typ: "SYMBOL", name: "unary"},
+	}},
+	"unary": {typ: "CHOICE", members: []*rule{
+		{typ: "PREC_RIGHT", content: &rule{typ: "SEQ", members: []*rule{
+			{typ: "FIELD", name: "operator", content: &rule{typ: "STRING", value: "-"}},
+			{typ: "FIELD", name: "operand", content: &rule{typ: "SYMBOL", name: "unary"}},
+		}}},
+		{typ: "SYMBOL", name: "power"},
+	}},
+	"power": {typ: "CHOICE", members: []*rule{
+		{typ: "PREC_RIGHT", content: &rule{typ: "SEQ", members: []*rule{
+			{typ: "FIELD", name: "base", content: &rule{typ: "SYMBOL", name: "postfix"}},
+			{typ: "FIELD", name: "operator", content: &rule{typ: "STRING", value: "^"}},
+			{typ: "FIELD", name: "exponent", content: &rule{typ: "SYMBOL", name: "unary"}},
+		}}},
+		{typ: "SYMBOL", name: "postfix"},
+	}},
+	"postfix": {typ: "CHOICE", members: []*rule{
+		{typ: "PREC_LEFT", content: &rule{typ: "SEQ", members: []*rule{
+			{typ: "FIELD", name: "operand", content: &rule{typ: "SYMBOL", name: "postfix"}},
+			{typ: "FIELD", name: "operator", content: &rule{typ: "STRING", value: "!"}},
+		}}},
+		{typ: "SYMBOL", name: "primary"},
+	}},
+	"primary": {typ: "CHOICE", members: []*rule{
+		{typ: "SYMBOL", name: "function_call"},
+		{typ: "SYMBOL", name: "identifier"},
+		{typ: "SYMBOL", name: "number"},
+		{typ: "SEQ", members: []*rule{
+			{typ: "FIELD", name: "left_paren", content: &rule{typ: "STRING", value: "("}},
+			{typ: "FIELD", name: "expression", content: &rule{typ: "SYMBOL", name: "expression"}},
+			{typ: "FIELD", name: "right_paren", content: &rule{typ: "STRING", value: ")"}},
+		}},
+	}},
+	"function_call": {typ: "PREC", content: &rule{typ: "SEQ", members: []*rule{
+		{typ: "FIELD", name: "function", content: &rule{typ: "SYMBOL", name: "identifier"}},
+		{typ: "FIELD", name: "left_bracket", content: &rule{typ: "STRING", value: "["}},
+		{typ: "CHOICE", members: []*rule{
+			{typ: "FIELD", name: "args", content: &rule{typ: "SYMBOL", name: "argument_list"}},
+			{typ: "BLANK"},
+		}},
+		{typ: "FIELD", name: "right_bracket", content: &rule{typ: "STRING", value: "]"}},
+	}}},
+	"argument_list": {typ: "SEQ", members: []*rule{
+		{typ: "FIELD", name: "arg", content: &rule{typ: "SYMBOL", name: "expression"}},
+		{typ: "REPEAT", content: &rule{typ: "SEQ", members: []*rule{
+			{typ: "FIELD", name: "separator", content: &rule{typ: "STRING", value: ";"}},
+			{typ: "FIELD", name: "arg", content: &rule{typ: "SYMBOL", name: "expression"}},
+		}}},
+	}},
+	"identifier": {typ: "PATTERN", value: "[a-zA-Z_][a-zA-Z0-9_]*"},
+	"number":     {typ: "PATTERN", value: "\\d+"},
+	"comment":    {typ: "PATTERN", value: "\\\\[^\\r\\n]*"},
+}
+
+// extras may appear between any two tokens.
+var extras = []*rule{
+	{typ: "PATTERN", value: "[\\s\\t\\n\\r]+"},
+	{typ: "SYMBOL", name: "comment"},
+}
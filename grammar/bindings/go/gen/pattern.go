@@ -0,0 +1,86 @@
+package gen
+
+import (
+	"regexp/syntax"
+	"strings"
+)
+
+// pattern returns a random string matching the regular expression expr,
+// a PATTERN of the grammar. Characters are drawn from printable ASCII and
+// whitespace where the pattern allows it.
+func (g *Generator) pattern(expr string) string {
+	re := g.patterns[expr]
+	if re == nil {
+		var err error
+		re, err = syntax.Parse(expr, syntax.Perl)
+		if err != nil {
+			// The grammar's patterns are compiled by tree-sitter, so this
+			// is a pattern Go's syntax does not share.
+			panic("gen: pattern " + expr + ": " + err.Error())
+		}
+		re = re.Simplify()
+		g.patterns[expr] = re
+	}
+	var b strings.Builder
+	g.match(&b, re)
+	return b.String()
+}
+
+func (g *Generator) match(b *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		b.WriteString(string(re.Rune))
+	case syntax.OpCharClass:
+		b.WriteRune(g.class(re.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		b.WriteRune(rune(' ' + g.rand.Intn('~'-' '+1)))
+	case syntax.OpCapture:
+		g.match(b, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			g.match(b, sub)
+		}
+	case syntax.OpAlternate:
+		g.match(b, re.Sub[g.rand.Intn(len(re.Sub))])
+	case syntax.OpStar, syntax.OpPlus, syntax.OpQuest, syntax.OpRepeat:
+		least, most := re.Min, re.Max
+		switch re.Op {
+		case syntax.OpStar:
+			least, most = 0, g.cfg.MaxRepeat
+		case syntax.OpPlus:
+			least, most = 1, g.cfg.MaxRepeat
+		case syntax.OpQuest:
+			least, most = 0, 1
+		}
+		if most < 0 || most > least+g.cfg.MaxRepeat {
+			most = least + g.cfg.MaxRepeat
+		}
+		for range least + g.rand.Intn(most-least+1) {
+			g.match(b, re.Sub[0])
+		}
+	}
+	// Empty matches and assertions match nothing.
+}
+
+// class returns a rune of the class given as pairs of bounds, preferring
+// printable ASCII and whitespace.
+func (g *Generator) class(ranges []rune) rune {
+	var usable []rune
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		for _, r := range "\t\n\r" {
+			if lo <= r && r <= hi {
+				usable = append(usable, r, r)
+			}
+		}
+		if lo, hi = max(lo, ' '), min(hi, '~'); lo <= hi {
+			usable = append(usable, lo, hi)
+		}
+	}
+	if len(usable) == 0 {
+		return ranges[0]
+	}
+	i := 2 * g.rand.Intn(len(usable)/2)
+	lo, hi := usable[i], usable[i+1]
+	return lo + rune(g.rand.Intn(int(hi-lo+1)))
+}
@@ -0,0 +1,309 @@
+// Package grammartest runs tree-sitter corpus tests from Go.
+//
+// Corpus files use the format read by `tree-sitter test`:
+//
+//	==================
+//	Name of the test
+//	==================
+//
+//	source text
+//
+//	---
+//
+//	(expected (tree))
+//
+// A header may be followed by attribute lines: ":skip" skips the case and
+// ":error" expects the source to produce a tree containing errors, in which
+// case the expected tree may be omitted. As with the tree-sitter CLI, field
+// names are only compared when the expected tree mentions any.
+package grammartest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Case is one test from a corpus file.
+type Case struct {
+	Name     string
+	Source   string
+	Expected string
+	// Skip and Error are set by the :skip and :error attributes.
+	Skip  bool
+	Error bool
+	// File and Line locate the case's header.
+	File string
+	Line int
+}
+
+var (
+	headerLine  = regexp.MustCompile(`^={3,}\S*$`)
+	dividerLine = regexp.MustCompile(`^-{3,}\S*$`)
+)
+
+// ParseCorpus splits corpus text into cases. File is recorded in each case
+// for error messages and may be empty.
+func ParseCorpus(file string, data []byte) ([]Case, error) {
+	lines := strings.Split(strings.ReplaceAll(string(data), "\r\n", "\n"), "\n")
+	var cases []Case
+	i := 0
+	for i < len(lines) {
+		if !headerLine.MatchString(lines[i]) {
+			if strings.TrimSpace(lines[i]) != "" {
+				return nil, fmt.Errorf("%s:%d: expected a test header", file, i+1)
+			}
+			i++
+			continue
+		}
+		c := Case{File: file, Line: i + 1}
+		i++
+		for ; i < len(lines) && !headerLine.MatchString(lines[i]); i++ {
+			line := strings.TrimSpace(lines[i])
+			switch {
+			case strings.HasPrefix(line, ":"):
+				for _, attr := range strings.Fields(line) {
+					switch attr {
+					case ":skip":
+						c.Skip = true
+					case ":error":
+						c.Error = true
+					}
+				}
+			case c.Name == "":
+				c.Name = line
+			}
+		}
+		if i == len(lines) {
+			return nil, fmt.Errorf("%s:%d: unterminated test header", file, c.Line)
+		}
+		i++ // closing header line
+		start := i
+		for i < len(lines) && !headerLine.MatchString(lines[i]) {
+			i++
+		}
+		body := lines[start:i]
+		divider := -1
+		for j, line := range body {
+			if dividerLine.MatchString(line) {
+				divider = j
+				break
+			}
+		}
+		if divider < 0 {
+			return nil, fmt.Errorf("%s:%d: test %q has no --- divider", file, c.Line, c.Name)
+		}
+		c.Source = strings.Trim(strings.Join(body[:divider], "\n"), "\n")
+		c.Expected = strings.TrimSpace(strings.Join(body[divider+1:], "\n"))
+		cases = append(cases, c)
+	}
+	return cases, nil
+}
+
+// ReadCorpusFile reads and parses a corpus file.
+func ReadCorpusFile(path string) ([]Case, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCorpus(path, data)
+}
+
+// Check parses c.Source with lang and compares the result with c.Expected.
+// It returns the actual tree, normalized the same way as the expectation.
+func Check(lang *tree_sitter.Language, c Case) (actual string, ok bool, err error) {
+	parser := tree_sitter.NewParser()
+	defer parser.Close()
+	if err := parser.SetLanguage(lang); err != nil {
+		return "", false, err
+	}
+	tree := parser.Parse([]byte(c.Source), nil)
+	if tree == nil {
+		return "", false, fmt.Errorf("parse failed")
+	}
+	defer tree.Close()
+
+	expected := Normalize(c.Expected)
+	actual = Normalize(tree.RootNode().ToSexp())
+	if !strings.Contains(expected, ": ") {
+		actual = stripFields(actual)
+	}
+	if c.Error && expected == "" {
+		return actual, tree.RootNode().HasError(), nil
+	}
+	return actual, actual == expected, nil
+}
+
+// RunFile runs every case in a corpus file as a subtest of t.
+func RunFile(t *testing.T, lang *tree_sitter.Language, path string) {
+	t.Helper()
+	cases, err := ReadCorpusFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		t.Run(c.Name, func(t *testing.T) {
+			if c.Skip {
+				t.Skip(":skip")
+			}
+			actual, ok, err := Check(lang, c)
+			if err != nil {
+				t.Fatalf("%s:%d: %v", c.File, c.Line, err)
+			}
+			if ok {
+				return
+			}
+			if c.Error && c.Expected == "" {
+				t.Errorf("%s:%d: expected a parse error, got\n%s", c.File, c.Line, Pretty(actual))
+				return
+			}
+			t.Errorf("%s:%d: tree mismatch (-expected +actual):\n%s", c.File, c.Line, Diff(Pretty(Normalize(c.Expected)), Pretty(actual)))
+		})
+	}
+}
+
+// RunDir runs every .txt corpus file in dir, one subtest per file named after
+// it.
+func RunDir(t *testing.T, lang *tree_sitter.Language, dir string) {
+	t.Helper()
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no corpus files in %s", dir)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".txt"), func(t *testing.T) {
+			RunFile(t, lang, path)
+		})
+	}
+}
+
+// Normalize collapses the whitespace of an S-expression so that trees can be
+// compared as strings.
+func Normalize(sexp string) string {
+	s := strings.Join(strings.Fields(sexp), " ")
+	s = strings.ReplaceAll(s, "( ", "(")
+	return strings.ReplaceAll(s, " )", ")")
+}
+
+var fieldName = regexp.MustCompile(`\b[a-z_][a-z0-9_]*: `)
+
+func stripFields(sexp string) string {
+	return fieldName.ReplaceAllString(sexp, "")
+}
+
+// Pretty indents a normalized S-expression with one node per line, field
+// names leading the line of the node they label.
+func Pretty(sexp string) string {
+	var b strings.Builder
+	depth := 0
+	afterField := false
+	prev := ""
+	for _, tok := range sexpTokens(sexp) {
+		switch {
+		case tok == "(":
+			switch {
+			case afterField:
+				b.WriteByte(' ')
+			case b.Len() > 0:
+				b.WriteString("\n" + strings.Repeat("  ", depth))
+			}
+			b.WriteByte('(')
+			depth++
+			afterField = false
+		case tok == ")":
+			b.WriteByte(')')
+			depth--
+		case strings.HasSuffix(tok, ":"):
+			b.WriteString("\n" + strings.Repeat("  ", depth) + tok)
+			afterField = true
+		default:
+			if prev != "(" {
+				b.WriteByte(' ')
+			}
+			b.WriteString(tok)
+		}
+		prev = tok
+	}
+	return b.String()
+}
+
+// sexpTokens splits an S-expression into parentheses, quoted strings and
+// words.
+func sexpTokens(s string) []string {
+	var toks []string
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(' || c == ')':
+			toks = append(toks, s[i:i+1])
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			j = min(j+1, len(s))
+			toks = append(toks, s[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n()", rune(s[j])) {
+				j++
+			}
+			toks = append(toks, s[i:j])
+			i = j
+		}
+	}
+	return toks
+}
+
+// Diff is a line diff of two texts, marking lines only in a with "-" and
+// lines only in b with "+".
+func Diff(a, b string) string {
+	x, y := strings.Split(a, "\n"), strings.Split(b, "\n")
+	// Longest common subsequence table.
+	lcs := make([][]int, len(x)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(y)+1)
+	}
+	for i := len(x) - 1; i >= 0; i-- {
+		for j := len(y) - 1; j >= 0; j-- {
+			if x[i] == y[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(x) || j < len(y) {
+		switch {
+		case i < len(x) && j < len(y) && x[i] == y[j]:
+			out.WriteString("  " + x[i] + "\n")
+			i++
+			j++
+		case j < len(y) && (i == len(x) || lcs[i][j+1] >= lcs[i+1][j]):
+			out.WriteString("+ " + y[j] + "\n")
+			j++
+		default:
+			out.WriteString("- " + x[i] + "\n")
+			i++
+		}
+	}
+	return out.String()
+}
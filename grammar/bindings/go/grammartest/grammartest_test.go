@@ -0,0 +1,107 @@
+package grammartest_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/grammartest"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func language() *tree_sitter.Language {
+	return tree_sitter.NewLanguage(tree_sitter_wabznasm.Language())
+}
+
+func TestCorpus(t *testing.T) {
+	grammartest.RunDir(t, language(), "../../../test/corpus")
+}
+
+const corpus = `==========
+Number
+==========
+
+42
+
+---
+
+(source_file (statement (expression (additive (multiplicative (unary (power (postfix (primary (number))))))))))
+
+==========
+Skipped
+:skip
+==========
+
+1
+
+---
+
+(nothing)
+
+=====
+Broken
+:error
+=====
+
+1+*
+
+---
+`
+
+func TestParseCorpus(t *testing.T) {
+	cases, err := grammartest.ParseCorpus("inline.txt", []byte(corpus))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 3 {
+		t.Fatalf("got %d cases, want 3", len(cases))
+	}
+	if c := cases[0]; c.Name != "Number" || c.Source != "42" || !strings.HasPrefix(c.Expected, "(source_file") || c.Line != 1 {
+		t.Errorf("case 0 = %+v", c)
+	}
+	if !cases[1].Skip || cases[1].Error {
+		t.Errorf("case 1 attributes = %+v", cases[1])
+	}
+	if c := cases[2]; !c.Error || c.Source != "1+*" || c.Expected != "" {
+		t.Errorf("case 2 = %+v", c)
+	}
+
+	for _, c := range []grammartest.Case{cases[0], cases[2]} {
+		if _, ok, err := grammartest.Check(language(), c); err != nil || !ok {
+			t.Errorf("Check(%s) = %v, %v", c.Name, ok, err)
+		}
+	}
+	actual, ok, err := grammartest.Check(language(), cases[1])
+	if err != nil || ok {
+		t.Errorf("Check(Skipped) = %v, %v; want mismatch", ok, err)
+	}
+	if strings.Contains(actual, ":") {
+		t.Errorf("fields not stripped for an expectation without fields: %s", actual)
+	}
+}
+
+func TestParseCorpusErrors(t *testing.T) {
+	for _, bad := range []string{
+		"stray text\n",
+		"===\nNo divider\n===\n\n1\n",
+		"===\nUnterminated\n",
+	} {
+		if _, err := grammartest.ParseCorpus("bad.txt", []byte(bad)); err == nil {
+			t.Errorf("ParseCorpus(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestDiff(t *testing.T) {
+	got := grammartest.Diff("a\nb\nc", "a\nx\nc")
+	want := "  a\n+ x\n- b\n  c\n"
+	if got != want {
+		t.Errorf("Diff = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,147 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client calls a Server, for Go programs that would rather talk to a
+// shared service than link the parser. It is safe for concurrent use.
+type Client struct {
+	url string
+	hc  *http.Client
+}
+
+// NewClient returns a Client calling the server at url, such as
+// http://localhost:9090, with hc. A nil hc speaks HTTP/2 over TLS for an
+// https URL and in clear text for an http one.
+func NewClient(url string, hc *http.Client) *Client {
+	if hc == nil {
+		var p http.Protocols
+		p.SetHTTP2(true)
+		p.SetUnencryptedHTTP2(true)
+		hc = &http.Client{Transport: &http.Transport{Protocols: &p}}
+	}
+	return &Client{url: strings.TrimSuffix(url, "/"), hc: hc}
+}
+
+// Parse returns the syntax tree of a source and its syntax errors.
+func (c *Client) Parse(ctx context.Context, req *ParseRequest) (*ParseResponse, error) {
+	resp := &ParseResponse{}
+	if err := c.unary(ctx, "Parse", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Analyze calls fn with each syntax error, then each lint finding, of a
+// source as the server streams them. An error returned by fn cancels the
+// call and is returned.
+func (c *Client) Analyze(ctx context.Context, req *AnalyzeRequest, fn func(*Diagnostic) error) error {
+	return c.call(ctx, "Analyze", req, func(data []byte) error {
+		var d Diagnostic
+		if err := Unmarshal(data, &d); err != nil {
+			return err
+		}
+		return fn(&d)
+	})
+}
+
+// Format returns a source formatted.
+func (c *Client) Format(ctx context.Context, req *FormatRequest) (*FormatResponse, error) {
+	resp := &FormatResponse{}
+	if err := c.unary(ctx, "Format", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Eval runs a script and returns the value of its last statement.
+func (c *Client) Eval(ctx context.Context, req *EvalRequest) (*EvalResponse, error) {
+	resp := &EvalResponse{}
+	if err := c.unary(ctx, "Eval", req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// unary calls a method answering with one message, decoded into resp.
+func (c *Client) unary(ctx context.Context, method string, req, resp Message) error {
+	n := 0
+	err := c.call(ctx, method, req, func(data []byte) error {
+		n++
+		return Unmarshal(data, resp)
+	})
+	if err == nil && n != 1 {
+		err = statusf(Internal, "%s answered with %d messages", method, n)
+	}
+	return err
+}
+
+// call calls method with req and passes each response message to recv. It
+// returns a *Status if the call fails on the server.
+func (c *Client) call(ctx context.Context, method string, req Message, recv func([]byte) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	data := Marshal(req)
+	body := make([]byte, 5, 5+len(data))
+	binary.BigEndian.PutUint32(body[1:], uint32(len(data)))
+	r, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+"/"+ServiceName+"/"+method, bytes.NewReader(append(body, data...)))
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", "application/grpc")
+	r.Header.Set("TE", "trailers")
+	if deadline, ok := ctx.Deadline(); ok {
+		r.Header.Set("Grpc-Timeout", formatTimeout(time.Until(deadline)))
+	}
+	resp, err := c.hc.Do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return statusf(Unknown, "HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	for {
+		var head [5]byte
+		if _, err := io.ReadFull(resp.Body, head[:]); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		if head[0] != 0 {
+			return statusf(Internal, "compressed response message")
+		}
+		msg := make([]byte, binary.BigEndian.Uint32(head[1:]))
+		if _, err := io.ReadFull(resp.Body, msg); err != nil {
+			return err
+		}
+		if err := recv(msg); err != nil {
+			return err
+		}
+	}
+	// The status is in the trailers, or in the headers of a response
+	// without messages.
+	status := resp.Trailer.Get("Grpc-Status")
+	message := resp.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = resp.Header.Get("Grpc-Status"), resp.Header.Get("Grpc-Message")
+	}
+	code, err := strconv.ParseUint(status, 10, 32)
+	if err != nil {
+		return statusf(Internal, "invalid grpc-status %q", status)
+	}
+	if Code(code) != OK {
+		return &Status{Code: Code(code), Message: decodeMessage(message)}
+	}
+	return nil
+}
@@ -0,0 +1,179 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/grpc"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+// serve starts a Server speaking HTTP/2 in clear text and returns a Client
+// calling it.
+func serve(t *testing.T, opts grpc.Options) (*grpc.Client, string) {
+	t.Helper()
+	s := grpc.New(opts)
+	t.Cleanup(s.Close)
+	ts := httptest.NewUnstartedServer(s)
+	ts.Config.Protocols = new(http.Protocols)
+	ts.Config.Protocols.SetHTTP1(true)
+	ts.Config.Protocols.SetUnencryptedHTTP2(true)
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return grpc.NewClient(ts.URL, nil), ts.URL
+}
+
+func code(err error) grpc.Code {
+	var st *grpc.Status
+	if errors.As(err, &st) {
+		return st.Code
+	}
+	if err == nil {
+		return grpc.OK
+	}
+	return grpc.Unknown
+}
+
+func TestParse(t *testing.T) {
+	c, _ := serve(t, grpc.Options{})
+	ctx := context.Background()
+	resp, err := c.Parse(ctx, &grpc.ParseRequest{Source: "x: 1+2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Trees) != 1 || resp.Trees[0].Kind != "source_file" || resp.Trees[0].Range.EndByte != 6 || len(resp.Trees[0].Children) == 0 || len(resp.Diagnostics) != 0 {
+		t.Errorf("Parse(x: 1+2) = %+v", resp)
+	}
+	resp, err = c.Parse(ctx, &grpc.ParseRequest{Source: "f: {x+1}\ny: f[2]\n"})
+	if err != nil || len(resp.Trees) != 2 || resp.Trees[1].Range.StartByte != 9 || len(resp.Diagnostics) != 0 {
+		t.Errorf("Parse of two statements = %+v, %v", resp, err)
+	}
+	resp, err = c.Parse(ctx, &grpc.ParseRequest{Source: "f[1;"})
+	if err != nil || len(resp.Diagnostics) == 0 || resp.Diagnostics[0].Severity != "error" {
+		t.Errorf("Parse(f[1;) = %+v, %v", resp, err)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	c, _ := serve(t, grpc.Options{})
+	ctx := context.Background()
+	var got []string
+	err := c.Analyze(ctx, &grpc.AnalyzeRequest{Source: "f: {[x;y] x", Rules: []string{"unused-parameter"}}, func(d *grpc.Diagnostic) error {
+		got = append(got, d.Code+d.Rule)
+		return nil
+	})
+	if err != nil || len(got) < 2 || got[len(got)-1] != "unused-parameter" || strings.Contains(got[0], "unused") {
+		t.Errorf("Analyze streamed %v, %v", got, err)
+	}
+
+	// An error of the callback ends the stream.
+	stop := errors.New("stop")
+	n := 0
+	err = c.Analyze(ctx, &grpc.AnalyzeRequest{Source: "f: {[x;y] x", Rules: []string{"unused-parameter"}}, func(*grpc.Diagnostic) error {
+		n++
+		return stop
+	})
+	if !errors.Is(err, stop) || n != 1 {
+		t.Errorf("Analyze with a failing callback = %v after %d diagnostics", err, n)
+	}
+
+	err = c.Analyze(ctx, &grpc.AnalyzeRequest{Source: "1", Rules: []string{"no-such-rule"}}, func(*grpc.Diagnostic) error { return nil })
+	if code(err) != grpc.InvalidArgument {
+		t.Errorf("Analyze with an unknown rule = %v", err)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	c, _ := serve(t, grpc.Options{})
+	ctx := context.Background()
+	if resp, err := c.Format(ctx, &grpc.FormatRequest{Source: "x:1 + 2"}); err != nil || strings.TrimSpace(resp.Source) != "x: 1+2" {
+		t.Errorf("Format(x:1 + 2) = %+v, %v", resp, err)
+	}
+	if _, err := c.Format(ctx, &grpc.FormatRequest{Source: "x: (1"}); code(err) != grpc.InvalidArgument {
+		t.Errorf("Format(x: (1) = %v", err)
+	}
+}
+
+func TestEval(t *testing.T) {
+	c, _ := serve(t, grpc.Options{Limits: eval.Limits{MaxSteps: 1000}})
+	ctx := context.Background()
+	resp, err := c.Eval(ctx, &grpc.EvalRequest{Source: "sq: {x*x}\n\\ a comment\nsq[12]\n"})
+	if err != nil || resp.Value != "144" || resp.Kind != "long" {
+		t.Errorf("Eval = %+v, %v", resp, err)
+	}
+	if resp, err := c.Eval(ctx, &grpc.EvalRequest{}); err != nil || resp.Value != "" {
+		t.Errorf("Eval of nothing = %+v, %v", resp, err)
+	}
+	if _, err := c.Eval(ctx, &grpc.EvalRequest{Source: "1%0"}); code(err) != grpc.InvalidArgument {
+		t.Errorf("Eval(1%%0) = %v", err)
+	}
+	if _, err := c.Eval(ctx, &grpc.EvalRequest{Source: "f: {[n] n+f[n]}\nf[1]"}); code(err) != grpc.ResourceExhausted {
+		t.Errorf("Eval of endless recursion = %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c, url := serve(t, grpc.Options{Timeout: time.Nanosecond, MaxMessageBytes: 64})
+	ctx := context.Background()
+	if _, err := c.Eval(ctx, &grpc.EvalRequest{Source: "1+1"}); code(err) != grpc.DeadlineExceeded {
+		t.Errorf("Eval past the timeout = %v", err)
+	}
+	if _, err := c.Parse(ctx, &grpc.ParseRequest{Source: strings.Repeat("1+", 64) + "1"}); code(err) != grpc.ResourceExhausted {
+		t.Errorf("Parse of a large message = %v", err)
+	}
+	resp, err := http.Post(url+"/"+grpc.ServiceName+"/Parse", "application/grpc", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusHTTPVersionNotSupported {
+		t.Errorf("HTTP/1.1 request answered %s", resp.Status)
+	}
+}
+
+func TestUnimplemented(t *testing.T) {
+	_, url := serve(t, grpc.Options{})
+	c := grpc.NewClient(url+"/nowhere", nil)
+	if _, err := c.Eval(context.Background(), &grpc.EvalRequest{Source: "1"}); code(err) != grpc.Unimplemented {
+		t.Errorf("Eval at an unknown path = %v", err)
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	in := &grpc.ParseResponse{
+		Trees: []grpc.Node{{Kind: "source_file", Named: true, Children: []grpc.Node{
+			{Kind: "identifier", Field: "name", Named: true, Range: grpc.Range{StartByte: 0, EndByte: 300, End: grpc.Point{Row: 2, Column: 7}}},
+			{Kind: ":", Missing: true},
+		}}},
+		Diagnostics: []grpc.Diagnostic{{Severity: "error", Code: "MISSING", Message: "missing ]", Expected: []string{`"]"`, `";"`}}},
+	}
+	data := grpc.Marshal(in)
+	// Fields the reader does not know are skipped.
+	data = append(data, 0x78, 0x01, 0x82, 0x01, 0x02, 'h', 'i', 0x8d, 0x01, 1, 2, 3, 4)
+	var out grpc.ParseResponse
+	if err := grpc.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Trees) != 1 || len(out.Trees[0].Children) != 2 || !reflect.DeepEqual(out.Trees[0].Children[0], in.Trees[0].Children[0]) || !out.Trees[0].Children[1].Missing {
+		t.Errorf("Trees = %+v", out.Trees)
+	}
+	if len(out.Diagnostics) != 1 || !slices.Equal(out.Diagnostics[0].Expected, in.Diagnostics[0].Expected) || out.Diagnostics[0].Message != "missing ]" {
+		t.Errorf("Diagnostics = %+v", out.Diagnostics)
+	}
+	if err := grpc.Unmarshal(data[:len(data)-3], &out); !errors.Is(err, grpc.ErrWire) {
+		t.Errorf("Unmarshal of a truncated message = %v", err)
+	}
+}
@@ -0,0 +1,301 @@
+package grpc
+
+// Message is a message of the service, as wabznasm.proto declares it.
+type Message interface {
+	encode(*encoder)
+	decode([]byte) error
+}
+
+// Marshal encodes m in the protocol buffers wire format.
+func Marshal(m Message) []byte {
+	var e encoder
+	m.encode(&e)
+	return e.buf
+}
+
+// Unmarshal decodes data into m, merging its fields into those m has, as
+// protocol buffers do. Unknown fields are skipped.
+func Unmarshal(data []byte, m Message) error { return m.decode(data) }
+
+// Point is a zero-based row and byte column.
+type Point struct {
+	Row    uint
+	Column uint
+}
+
+func (p *Point) encode(e *encoder) {
+	e.uint(1, p.Row)
+	e.uint(2, p.Column)
+}
+
+func (p *Point) decode(data []byte) error {
+	return decode(data, func(d *decoder, field int) (err error) {
+		switch field {
+		case 1:
+			p.Row, err = d.uint()
+		case 2:
+			p.Column, err = d.uint()
+		}
+		return err
+	})
+}
+
+// Range is a span of source.
+type Range struct {
+	StartByte uint
+	EndByte   uint
+	Start     Point
+	End       Point
+}
+
+func (r *Range) encode(e *encoder) {
+	e.uint(1, r.StartByte)
+	e.uint(2, r.EndByte)
+	e.message(3, &r.Start)
+	e.message(4, &r.End)
+}
+
+func (r *Range) decode(data []byte) error {
+	return decode(data, func(d *decoder, field int) (err error) {
+		switch field {
+		case 1:
+			r.StartByte, err = d.uint()
+		case 2:
+			r.EndByte, err = d.uint()
+		case 3:
+			err = d.message(&r.Start)
+		case 4:
+			err = d.message(&r.End)
+		}
+		return err
+	})
+}
+
+// Node is a node of a syntax tree.
+type Node struct {
+	Kind string
+	// Field is the name of the field of the parent holding the node, if
+	// any.
+	Field    string
+	Named    bool
+	Error    bool
+	Missing  bool
+	Extra    bool
+	Range    Range
+	Children []Node
+}
+
+func (n *Node) encode(e *encoder) {
+	e.string(1, n.Kind)
+	e.string(2, n.Field)
+	e.bool(3, n.Named)
+	e.bool(4, n.Error)
+	e.bool(5, n.Missing)
+	e.bool(6, n.Extra)
+	e.message(7, &n.Range)
+	for i := range n.Children {
+		e.message(8, &n.Children[i])
+	}
+}
+
+func (n *Node) decode(data []byte) error {
+	return decode(data, func(d *decoder, field int) (err error) {
+		switch field {
+		case 1:
+			n.Kind, err = d.string()
+		case 2:
+			n.Field, err = d.string()
+		case 3:
+			n.Named, err = d.bool()
+		case 4:
+			n.Error, err = d.bool()
+		case 5:
+			n.Missing, err = d.bool()
+		case 6:
+			n.Extra, err = d.bool()
+		case 7:
+			err = d.message(&n.Range)
+		case 8:
+			var c Node
+			err = d.message(&c)
+			n.Children = append(n.Children, c)
+		}
+		return err
+	})
+}
+
+// Diagnostic is a syntax error or a lint finding.
+type Diagnostic struct {
+	Range Range
+	// Severity is error, warning, info or hint.
+	Severity string
+	// Code classifies a syntax error; it is empty for a lint finding.
+	Code    string
+	Message string
+	// Expected lists the tokens the parser would have accepted.
+	Expected []string
+	// Rule names the lint rule of a finding; it is empty for a syntax
+	// error.
+	Rule string
+}
+
+func (m *Diagnostic) encode(e *encoder) {
+	e.message(1, &m.Range)
+	e.string(2, m.Severity)
+	e.string(3, m.Code)
+	e.string(4, m.Message)
+	for _, s := range m.Expected {
+		e.bytes(5, []byte(s))
+	}
+	e.string(6, m.Rule)
+}
+
+func (m *Diagnostic) decode(data []byte) error {
+	return decode(data, func(d *decoder, field int) (err error) {
+		switch field {
+		case 1:
+			err = d.message(&m.Range)
+		case 2:
+			m.Severity, err = d.string()
+		case 3:
+			m.Code, err = d.string()
+		case 4:
+			m.Message, err = d.string()
+		case 5:
+			var s string
+			s, err = d.string()
+			m.Expected = append(m.Expected, s)
+		case 6:
+			m.Rule, err = d.string()
+		}
+		return err
+	})
+}
+
+// ParseRequest is the request of Parse.
+type ParseRequest struct {
+	Source string
+}
+
+func (r *ParseRequest) encode(e *encoder)        { e.string(1, r.Source) }
+func (r *ParseRequest) decode(data []byte) error { return decodeSource(data, &r.Source) }
+
+// ParseResponse is the response of Parse.
+type ParseResponse struct {
+	// Trees are the trees of the statements, in source order.
+	Trees       []Node
+	Diagnostics []Diagnostic
+}
+
+func (r *ParseResponse) encode(e *encoder) {
+	for i := range r.Diagnostics {
+		e.message(2, &r.Diagnostics[i])
+	}
+	for i := range r.Trees {
+		e.message(3, &r.Trees[i])
+	}
+}
+
+func (r *ParseResponse) decode(data []byte) error {
+	return decode(data, func(d *decoder, field int) (err error) {
+		switch field {
+		case 2:
+			var g Diagnostic
+			err = d.message(&g)
+			r.Diagnostics = append(r.Diagnostics, g)
+		case 3:
+			var n Node
+			err = d.message(&n)
+			r.Trees = append(r.Trees, n)
+		}
+		return err
+	})
+}
+
+// AnalyzeRequest is the request of Analyze.
+type AnalyzeRequest struct {
+	Source string
+	// Rules selects lint rules by name; none selects them all.
+	Rules []string
+}
+
+func (r *AnalyzeRequest) encode(e *encoder) {
+	e.string(1, r.Source)
+	for _, s := range r.Rules {
+		e.bytes(2, []byte(s))
+	}
+}
+
+func (r *AnalyzeRequest) decode(data []byte) error {
+	return decode(data, func(d *decoder, field int) (err error) {
+		switch field {
+		case 1:
+			r.Source, err = d.string()
+		case 2:
+			var s string
+			s, err = d.string()
+			r.Rules = append(r.Rules, s)
+		}
+		return err
+	})
+}
+
+// FormatRequest is the request of Format.
+type FormatRequest struct {
+	Source string
+}
+
+func (r *FormatRequest) encode(e *encoder)        { e.string(1, r.Source) }
+func (r *FormatRequest) decode(data []byte) error { return decodeSource(data, &r.Source) }
+
+// FormatResponse is the response of Format.
+type FormatResponse struct {
+	Source string
+}
+
+func (r *FormatResponse) encode(e *encoder)        { e.string(1, r.Source) }
+func (r *FormatResponse) decode(data []byte) error { return decodeSource(data, &r.Source) }
+
+// EvalRequest is the request of Eval.
+type EvalRequest struct {
+	Source string
+}
+
+func (r *EvalRequest) encode(e *encoder)        { e.string(1, r.Source) }
+func (r *EvalRequest) decode(data []byte) error { return decodeSource(data, &r.Source) }
+
+// EvalResponse is the response of Eval.
+type EvalResponse struct {
+	// Value is the value as the REPL prints it, empty for a script with
+	// no statement.
+	Value string
+	// Kind is the kind of the value, such as long or list.
+	Kind string
+}
+
+func (r *EvalResponse) encode(e *encoder) {
+	e.string(1, r.Value)
+	e.string(2, r.Kind)
+}
+
+func (r *EvalResponse) decode(data []byte) error {
+	return decode(data, func(d *decoder, field int) (err error) {
+		switch field {
+		case 1:
+			r.Value, err = d.string()
+		case 2:
+			r.Kind, err = d.string()
+		}
+		return err
+	})
+}
+
+// decodeSource decodes a message whose only field is the string 1.
+func decodeSource(data []byte, source *string) error {
+	return decode(data, func(d *decoder, field int) (err error) {
+		if field == 1 {
+			*source, err = d.string()
+		}
+		return err
+	})
+}
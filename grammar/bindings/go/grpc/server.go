@@ -0,0 +1,389 @@
+// Package grpc serves the wabznasm tooling over gRPC, so that services in
+// other languages can parse, analyze, format and evaluate source without
+// binding the grammar themselves. wabznasm.proto declares the service;
+// clients in other languages are generated from it, and Client calls it
+// from Go.
+//
+// The protocol is implemented on net/http, without gRPC or protocol
+// buffers libraries, so the tools stay free of dependencies. A Server is
+// an http.Handler for HTTP/2, over TLS or, with Protocols allowing
+// unencrypted HTTP/2, in clear text as most gRPC clients expect inside a
+// cluster:
+//
+//	s := grpc.New(grpc.Options{Limits: eval.Limits{MaxSteps: 1e6}})
+//	defer s.Close()
+//	var p http.Protocols
+//	p.SetUnencryptedHTTP2(true)
+//	srv := &http.Server{Addr: ":9090", Handler: s, Protocols: &p}
+//	srv.ListenAndServe()
+//
+// Each call runs under the deadline of its grpc-timeout header, at most
+// Options.Timeout, and stops once the client cancels it; Analyze stops
+// streaming diagnostics at the next one. Messages are never compressed.
+// The work is done by an engine.Engine, under its limits.
+package grpc
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/crash"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/engine"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/script"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+)
+
+// ServiceName is the full name of the service in wabznasm.proto.
+const ServiceName = "wabznasm.v1.Wabznasm"
+
+// Defaults for the zero Options.
+const (
+	DefaultMaxMessageBytes = 4 << 20
+	DefaultTimeout         = 5 * time.Second
+)
+
+// Options configure a Server.
+type Options struct {
+	// MaxMessageBytes bounds the size of a request message.
+	MaxMessageBytes int
+	// Timeout bounds the time spent on a call.
+	Timeout time.Duration
+	// Limits cap each evaluation of Eval.
+	Limits eval.Limits
+	// Logger, if set, logs the calls instead of the default logger of
+	// package telemetry.
+	Logger *slog.Logger
+	// Crash writes the diagnostic bundles of calls that panic; nil writes
+	// them as the zero crash.Reporter does.
+	Crash *crash.Reporter
+}
+
+// Server serves the calls of the service. It is safe for concurrent use.
+type Server struct {
+	opts   Options
+	engine *engine.Engine
+}
+
+// New returns a Server. The caller must call Close.
+func New(opts Options) *Server {
+	if opts.MaxMessageBytes <= 0 {
+		opts.MaxMessageBytes = DefaultMaxMessageBytes
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	e := engine.New(engine.Options{Limits: opts.Limits, MaxSourceBytes: opts.MaxMessageBytes})
+	return &Server{opts: opts, engine: e}
+}
+
+// Close releases the parsers of s.
+func (s *Server) Close() { s.engine.Close() }
+
+// methods are the handlers of the calls, by method name.
+var methods = map[string]func(*Server, *stream) error{
+	"Parse":   (*Server).parse,
+	"Analyze": (*Server).analyze,
+	"Format":  (*Server).format,
+	"Eval":    (*Server).eval,
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "use POST", http.StatusMethodNotAllowed)
+		return
+	}
+	if mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mt != "application/grpc" && mt != "application/grpc+proto" {
+		http.Error(w, "expected Content-Type application/grpc", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	ctx := r.Context()
+	if s.opts.Logger != nil {
+		ctx = telemetry.WithLogger(ctx, s.opts.Logger)
+	}
+	ctx, cancel := context.WithTimeout(ctx, s.opts.Timeout)
+	defer cancel()
+	if d, ok := parseTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+	method := strings.TrimPrefix(r.URL.Path, "/"+ServiceName+"/")
+	ctx, span := telemetry.Start(ctx, "call", slog.String("method", method))
+	st := &stream{ctx: ctx, w: w, body: r.Body, max: s.opts.MaxMessageBytes}
+	start := time.Now()
+
+	var err error
+	func() {
+		defer s.recover(st, &err, &crash.Scope{Operation: r.URL.Path, RequestID: span.RequestID()})
+		if fn, ok := methods[method]; ok && method != r.URL.Path {
+			err = fn(s, st)
+		} else {
+			err = statusf(Unimplemented, "unknown method %s", r.URL.Path)
+		}
+	}()
+	status := toStatus(ctx, err)
+	st.finish(status)
+	err = nil
+	if status.Code != OK {
+		err = status
+	}
+	span.End(err)
+	telemetry.Logger(ctx).InfoContext(ctx, "call",
+		slog.String("method", method),
+		slog.String("code", status.Code.String()),
+		slog.Duration("duration", time.Since(start)))
+}
+
+// recover turns a panic of the call st into an Internal status in *err,
+// with the ID of its diagnostic bundle. It must be deferred directly.
+func (s *Server) recover(st *stream, err *error, scope *crash.Scope) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	scope.Source = st.source
+	b, path := s.opts.Crash.Report(v, scope)
+	telemetry.Logger(st.ctx).ErrorContext(st.ctx, "panic",
+		slog.String("method", scope.Operation),
+		slog.String("panic", b.Panic),
+		slog.String("bundle", path))
+	*err = statusf(Internal, "internal error; crash ID %s", b.ID)
+}
+
+// toStatus maps the error of a call to its status.
+func toStatus(ctx context.Context, err error) *Status {
+	var st *Status
+	var limit *eval.LimitExceededError
+	var evalErr *eval.Error
+	switch {
+	case err == nil:
+		return &Status{Code: OK}
+	case errors.As(err, &st):
+		return st
+	case errors.Is(err, context.DeadlineExceeded) || errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return statusf(DeadlineExceeded, "%v", err)
+	case errors.Is(err, context.Canceled) || errors.Is(ctx.Err(), context.Canceled):
+		return statusf(Canceled, "%v", err)
+	case errors.Is(err, engine.ErrTooLarge), errors.As(err, &limit):
+		return statusf(ResourceExhausted, "%v", err)
+	case errors.As(err, &evalErr):
+		return statusf(InvalidArgument, "%v", err)
+	}
+	return statusf(Internal, "%v", err)
+}
+
+// stream is the request and response of one call.
+type stream struct {
+	ctx  context.Context
+	w    http.ResponseWriter
+	body io.Reader
+	max  int
+	// source is the source of the request, once it is read, for the
+	// diagnostic bundle of a panic.
+	source []byte
+	sent   bool
+}
+
+// recv reads the request message into m.
+func (st *stream) recv(m Message) error {
+	var head [5]byte
+	if _, err := io.ReadFull(st.body, head[:]); err != nil {
+		return statusf(InvalidArgument, "reading request: %v", err)
+	}
+	if head[0] != 0 {
+		return statusf(Unimplemented, "compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(head[1:])
+	if int64(n) > int64(st.max) {
+		return statusf(ResourceExhausted, "request message of %d bytes exceeds %d", n, st.max)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(st.body, data); err != nil {
+		return statusf(InvalidArgument, "reading request: %v", err)
+	}
+	if err := Unmarshal(data, m); err != nil {
+		return statusf(InvalidArgument, "%v", err)
+	}
+	return nil
+}
+
+// send writes the response message m and flushes it to the client.
+func (st *stream) send(m Message) error {
+	if err := st.ctx.Err(); err != nil {
+		return err
+	}
+	st.sent = true
+	data := Marshal(m)
+	frame := make([]byte, 5, 5+len(data))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	if _, err := st.w.Write(append(frame, data...)); err != nil {
+		return err
+	}
+	return http.NewResponseController(st.w).Flush()
+}
+
+// finish ends the response with status, in the trailers or, if no message
+// was sent, in the headers of a trailers-only response.
+func (st *stream) finish(status *Status) {
+	prefix := http.TrailerPrefix
+	if !st.sent {
+		prefix = ""
+		st.w.Header().Set("Content-Type", "application/grpc")
+	}
+	st.w.Header().Set(prefix+"Grpc-Status", strconv.Itoa(int(status.Code)))
+	if status.Message != "" {
+		st.w.Header().Set(prefix+"Grpc-Message", encodeMessage(status.Message))
+	}
+	if !st.sent {
+		st.w.WriteHeader(http.StatusOK)
+	}
+}
+
+// parseScript reads a request holding a source into m and parses the
+// source a statement at a time.
+func (s *Server) parseScript(st *stream, m Message, source *string) (*tree_sitter_wabznasm.Script, error) {
+	if err := st.recv(m); err != nil {
+		return nil, err
+	}
+	st.source = []byte(*source)
+	return s.engine.ParseScript(st.ctx, st.source)
+}
+
+func (s *Server) parse(st *stream) error {
+	var req ParseRequest
+	sc, err := s.parseScript(st, &req, &req.Source)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+	resp := &ParseResponse{}
+	for _, stmt := range sc.Statements {
+		resp.Trees = append(resp.Trees, toNode(tree_sitter_wabznasm.ToJSON(stmt.Tree.RootNode(), stmt.Source)))
+	}
+	for _, d := range sc.Diagnostics() {
+		resp.Diagnostics = append(resp.Diagnostics, toDiagnostic(d))
+	}
+	return st.send(resp)
+}
+
+func (s *Server) analyze(st *stream) error {
+	var req AnalyzeRequest
+	sc, err := s.parseScript(st, &req, &req.Source)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+	var rules []lint.Rule
+	for _, name := range req.Rules {
+		r, ok := lint.Lookup(name)
+		if !ok {
+			return statusf(InvalidArgument, "unknown rule %q", name)
+		}
+		rules = append(rules, r)
+	}
+	for _, d := range sc.Diagnostics() {
+		g := toDiagnostic(d)
+		if err := st.send(&g); err != nil {
+			return err
+		}
+	}
+	for _, f := range lint.RunScript(sc, rules...) {
+		g := Diagnostic{Range: toRange(f.Range), Severity: f.Severity.String(), Message: f.Message, Rule: f.Rule}
+		if err := st.send(&g); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) format(st *stream) error {
+	var req FormatRequest
+	sc, err := s.parseScript(st, &req, &req.Source)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+	for _, stmt := range sc.Statements {
+		if stmt.Tree.RootNode().HasError() {
+			return statusf(InvalidArgument, "%v", eval.SyntaxError(stmt.Tree.Tree, stmt.Source))
+		}
+	}
+	out, err := format.Config{}.Script(sc.Source)
+	if err != nil {
+		return err
+	}
+	return st.send(&FormatResponse{Source: string(out)})
+}
+
+func (s *Server) eval(st *stream) error {
+	var req EvalRequest
+	if err := st.recv(&req); err != nil {
+		return err
+	}
+	st.source = []byte(req.Source)
+	sess := s.engine.NewSession()
+	var v eval.Value
+	for _, e := range script.Split(req.Source) {
+		var err error
+		if v, err = sess.Eval(st.ctx, e.Isolate(st.source)); err != nil {
+			return err
+		}
+	}
+	resp := &EvalResponse{}
+	if v != nil {
+		resp.Value, resp.Kind = v.String(), v.Kind().String()
+	}
+	return st.send(resp)
+}
+
+func toNode(n tree_sitter_wabznasm.NodeJSON) Node {
+	out := Node{
+		Kind:    n.Kind,
+		Field:   n.Field,
+		Named:   n.Named,
+		Error:   n.Error,
+		Missing: n.Missing,
+		Extra:   n.Extra,
+		Range: Range{
+			StartByte: n.StartByte,
+			EndByte:   n.EndByte,
+			Start:     Point(n.Start),
+			End:       Point(n.End),
+		},
+	}
+	for _, c := range n.Children {
+		out.Children = append(out.Children, toNode(c))
+	}
+	return out
+}
+
+func toRange(r tree_sitter.Range) Range {
+	return Range{
+		StartByte: r.StartByte,
+		EndByte:   r.EndByte,
+		Start:     Point{Row: r.StartPoint.Row, Column: r.StartPoint.Column},
+		End:       Point{Row: r.EndPoint.Row, Column: r.EndPoint.Column},
+	}
+}
+
+func toDiagnostic(d tree_sitter_wabznasm.Diagnostic) Diagnostic {
+	return Diagnostic{Range: toRange(d.Range), Severity: d.Severity.String(), Code: d.Code, Message: d.Message, Expected: d.Expected}
+}
@@ -0,0 +1,176 @@
+package grpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ErrWire is returned for a message that is not valid protocol buffers.
+var ErrWire = errors.New("grpc: malformed message")
+
+// Wire types of the protocol buffers encoding.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// encoder appends the fields of a message to buf. Fields holding their
+// zero value are left out, as proto3 does.
+type encoder struct{ buf []byte }
+
+func (e *encoder) tag(field, wire int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wire))
+}
+
+func (e *encoder) uint(field int, v uint) {
+	if v == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, uint64(v))
+}
+
+func (e *encoder) bool(field int, v bool) {
+	if v {
+		e.uint(field, 1)
+	}
+}
+
+func (e *encoder) string(field int, s string) {
+	if s == "" {
+		return
+	}
+	e.bytes(field, []byte(s))
+}
+
+func (e *encoder) bytes(field int, b []byte) {
+	e.tag(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(b)))
+	e.buf = append(e.buf, b...)
+}
+
+// message appends the submessage m, even if it is empty.
+func (e *encoder) message(field int, m interface{ encode(*encoder) }) {
+	var sub encoder
+	m.encode(&sub)
+	e.bytes(field, sub.buf)
+}
+
+// decode calls fn with each field of the message data. fn reads the value
+// of the field from d with the method its wire type calls for; fields fn
+// leaves unread are skipped.
+func decode(data []byte, fn func(d *decoder, field int) error) error {
+	d := &decoder{data: data}
+	for len(d.data) > 0 {
+		key, err := d.varint()
+		if err != nil {
+			return err
+		}
+		d.field, d.wire, d.read = int(key>>3), int(key&7), false
+		if err := fn(d, d.field); err != nil {
+			return err
+		}
+		if !d.read {
+			if err := d.skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decoder reads the fields of a message.
+type decoder struct {
+	data  []byte
+	field int
+	wire  int
+	// read is set once the value of the current field is read.
+	read bool
+}
+
+func (d *decoder) varint() (uint64, error) {
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		return 0, ErrWire
+	}
+	d.data = d.data[n:]
+	return v, nil
+}
+
+func (d *decoder) want(wire int) error {
+	if d.wire != wire {
+		return fmt.Errorf("%w: field %d has wire type %d, want %d", ErrWire, d.field, d.wire, wire)
+	}
+	d.read = true
+	return nil
+}
+
+func (d *decoder) uint() (uint, error) {
+	if err := d.want(wireVarint); err != nil {
+		return 0, err
+	}
+	v, err := d.varint()
+	return uint(v), err
+}
+
+func (d *decoder) bool() (bool, error) {
+	v, err := d.uint()
+	return v != 0, err
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	if err := d.want(wireBytes); err != nil {
+		return nil, err
+	}
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.data)) {
+		return nil, ErrWire
+	}
+	b := d.data[:n]
+	d.data = d.data[n:]
+	return b, nil
+}
+
+func (d *decoder) string() (string, error) {
+	b, err := d.bytes()
+	return string(b), err
+}
+
+// message decodes a submessage into m.
+func (d *decoder) message(m interface{ decode([]byte) error }) error {
+	b, err := d.bytes()
+	if err != nil {
+		return err
+	}
+	return m.decode(b)
+}
+
+// skip skips the value of a field the message does not know.
+func (d *decoder) skip() error {
+	var n int
+	switch d.wire {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireBytes:
+		_, err := d.bytes()
+		return err
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	default:
+		return fmt.Errorf("%w: field %d has wire type %d", ErrWire, d.field, d.wire)
+	}
+	if len(d.data) < n {
+		return ErrWire
+	}
+	d.data = d.data[n:]
+	return nil
+}
@@ -0,0 +1,167 @@
+// Package highlight runs the bundled highlights query over a parse tree and
+// produces a flat stream of non-overlapping tokens suitable for rendering.
+package highlight
+
+import (
+	"sort"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+// Token is a highlighted byte range [Start, End) of the source.
+type Token struct {
+	Start   uint
+	End     uint
+	Capture string
+	Class   string
+}
+
+// Style describes how a capture is rendered.
+type Style struct {
+	// Class is the CSS class used by the HTML renderer.
+	Class string
+	// ANSI is the SGR parameter string used by the ANSI renderer, e.g. "1;34".
+	ANSI string
+}
+
+// Theme maps capture names to styles. Lookups fall back from the most
+// specific dotted name to its parents, so "function.call" matches a
+// "function" entry when no exact entry exists.
+type Theme map[string]Style
+
+// Lookup returns the style for capture.
+func (t Theme) Lookup(capture string) (Style, bool) {
+	for name := capture; name != ""; {
+		if s, ok := t[name]; ok {
+			return s, true
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return Style{}, false
+}
+
+// DefaultTheme covers every capture in the bundled highlights query.
+var DefaultTheme = Theme{
+	"comment":               {Class: "wz-comment", ANSI: "2;37"},
+	"number":                {Class: "wz-number", ANSI: "33"},
+	"function":              {Class: "wz-function", ANSI: "1;34"},
+	"function.call":         {Class: "wz-function-call", ANSI: "34"},
+	"variable":              {Class: "wz-variable", ANSI: ""},
+	"variable.parameter":    {Class: "wz-parameter", ANSI: "36"},
+	"operator":              {Class: "wz-operator", ANSI: "35"},
+	"punctuation":           {Class: "wz-punctuation", ANSI: ""},
+	"punctuation.bracket":   {Class: "wz-bracket", ANSI: ""},
+	"punctuation.delimiter": {Class: "wz-delimiter", ANSI: ""},
+}
+
+// Highlighter holds a compiled highlights query. It is safe to reuse across
+// trees but not for concurrent use.
+type Highlighter struct {
+	query *memtrack.TrackedQuery
+	theme Theme
+}
+
+// New compiles the bundled highlights query. A nil theme selects
+// DefaultTheme. The caller must call Close.
+func New(theme Theme) (*Highlighter, error) {
+	q, err := tree_sitter_wabznasm.HighlightQuery()
+	if err != nil {
+		return nil, err
+	}
+	if theme == nil {
+		theme = DefaultTheme
+	}
+	return &Highlighter{query: q, theme: theme}, nil
+}
+
+// Close releases the compiled query.
+func (h *Highlighter) Close() {
+	h.query.Close()
+}
+
+// Theme returns the theme the highlighter assigns classes from.
+func (h *Highlighter) Theme() Theme { return h.theme }
+
+type capture struct {
+	start, end uint
+	pattern    uint
+	name       string
+}
+
+// Tokens returns the highlighted tokens of tree in source order. When
+// several patterns capture the same node, the earliest pattern in the query
+// wins; when captures nest, the innermost one wins.
+func (h *Highlighter) Tokens(tree *tree_sitter.Tree, source []byte) []Token {
+	cursor := memtrack.NewQueryCursor()
+	defer cursor.Close()
+
+	names := h.query.CaptureNames()
+	best := map[[2]uint]capture{}
+	captures := cursor.Captures(h.query.Query, tree.RootNode(), source)
+	for match, index := captures.Next(); match != nil; match, index = captures.Next() {
+		c := match.Captures[index]
+		key := [2]uint{c.Node.StartByte(), c.Node.EndByte()}
+		if key[0] == key[1] {
+			continue
+		}
+		if prev, ok := best[key]; ok && prev.pattern <= match.PatternIndex {
+			continue
+		}
+		best[key] = capture{start: key[0], end: key[1], pattern: match.PatternIndex, name: names[c.Index]}
+	}
+
+	sorted := make([]capture, 0, len(best))
+	for _, c := range best {
+		sorted = append(sorted, c)
+	}
+	// Paint outer captures first so inner ones overwrite them.
+	sort.Slice(sorted, func(i, j int) bool {
+		wi, wj := sorted[i].end-sorted[i].start, sorted[j].end-sorted[j].start
+		if wi != wj {
+			return wi > wj
+		}
+		return sorted[i].start < sorted[j].start
+	})
+	owner := make([]int, len(source))
+	for i := range owner {
+		owner[i] = -1
+	}
+	for i, c := range sorted {
+		for b := c.start; b < c.end && b < uint(len(source)); b++ {
+			owner[b] = i
+		}
+	}
+
+	var tokens []Token
+	for b := 0; b < len(owner); {
+		o := owner[b]
+		e := b + 1
+		for e < len(owner) && owner[e] == o {
+			e++
+		}
+		if o >= 0 {
+			name := sorted[o].name
+			style, _ := h.theme.Lookup(name)
+			tokens = append(tokens, Token{Start: uint(b), End: uint(e), Capture: name, Class: style.Class})
+		}
+		b = e
+	}
+	return tokens
+}
+
+// Tokens highlights tree with DefaultTheme.
+func Tokens(tree *tree_sitter.Tree, source []byte) ([]Token, error) {
+	h, err := New(nil)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Close()
+	return h.Tokens(tree, source), nil
+}
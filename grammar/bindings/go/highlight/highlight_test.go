@@ -0,0 +1,88 @@
+package highlight_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/highlight"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func tokens(t *testing.T, src string) []highlight.Token {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	toks, err := highlight.Tokens(tree.Tree, []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return toks
+}
+
+func TestTokens(t *testing.T) {
+	src := "add: {[x;y] x+y} \\ sum"
+	var got []string
+	for _, tok := range tokens(t, src) {
+		got = append(got, src[tok.Start:tok.End]+"="+tok.Capture)
+	}
+	want := []string{
+		"add=function", ":=operator", "{=punctuation.bracket", "[=punctuation.bracket",
+		"x=variable.parameter", ";=punctuation.delimiter", "y=variable.parameter",
+		"]=punctuation.bracket", "x=variable", "+=operator", "y=variable",
+		"}=punctuation.bracket", `\ sum=comment`,
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("tokens:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestThemeLookupFallback(t *testing.T) {
+	theme := highlight.Theme{"function": {Class: "fn"}}
+	if s, ok := theme.Lookup("function.call.builtin"); !ok || s.Class != "fn" {
+		t.Errorf("Lookup = %+v, %v", s, ok)
+	}
+	if _, ok := theme.Lookup("number"); ok {
+		t.Errorf("unexpected match for number")
+	}
+}
+
+func TestRenderHTML(t *testing.T) {
+	src := "f[1]"
+	var buf bytes.Buffer
+	if err := highlight.RenderHTML(&buf, []byte(src), tokens(t, src)); err != nil {
+		t.Fatal(err)
+	}
+	want := `<pre class="wabznasm"><code><span class="wz-function-call">f</span>` +
+		`<span class="wz-bracket">[</span><span class="wz-number">1</span>` +
+		`<span class="wz-bracket">]</span></code></pre>` + "\n"
+	if buf.String() != want {
+		t.Errorf("html:\n got %s\nwant %s", buf.String(), want)
+	}
+}
+
+func TestRenderANSI(t *testing.T) {
+	src := "1 + 2"
+	var buf bytes.Buffer
+	if err := highlight.RenderANSI(&buf, []byte(src), tokens(t, src), nil); err != nil {
+		t.Fatal(err)
+	}
+	want := "\x1b[33m1\x1b[0m \x1b[35m+\x1b[0m \x1b[33m2\x1b[0m"
+	if buf.String() != want {
+		t.Errorf("ansi = %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,71 @@
+package highlight
+
+import (
+	"bufio"
+	"html"
+	"io"
+)
+
+// RenderHTML writes source as HTML, wrapping each token in a span carrying
+// its theme class. Text outside tokens is escaped and written as is.
+func RenderHTML(w io.Writer, source []byte, tokens []Token) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<pre class="wabznasm"><code>`)
+	renderRuns(source, tokens, func(text []byte, tok *Token) {
+		if tok == nil || tok.Class == "" {
+			bw.WriteString(html.EscapeString(string(text)))
+			return
+		}
+		bw.WriteString(`<span class="`)
+		bw.WriteString(html.EscapeString(tok.Class))
+		bw.WriteString(`">`)
+		bw.WriteString(html.EscapeString(string(text)))
+		bw.WriteString(`</span>`)
+	})
+	bw.WriteString("</code></pre>\n")
+	return bw.Flush()
+}
+
+// RenderANSI writes source with ANSI SGR escape sequences taken from theme.
+// A nil theme selects DefaultTheme.
+func RenderANSI(w io.Writer, source []byte, tokens []Token, theme Theme) error {
+	if theme == nil {
+		theme = DefaultTheme
+	}
+	bw := bufio.NewWriter(w)
+	renderRuns(source, tokens, func(text []byte, tok *Token) {
+		var sgr string
+		if tok != nil {
+			style, _ := theme.Lookup(tok.Capture)
+			sgr = style.ANSI
+		}
+		if sgr == "" {
+			bw.Write(text)
+			return
+		}
+		bw.WriteString("\x1b[" + sgr + "m")
+		bw.Write(text)
+		bw.WriteString("\x1b[0m")
+	})
+	return bw.Flush()
+}
+
+// renderRuns splits source into alternating unhighlighted and highlighted
+// runs. tokens must be sorted and non-overlapping, as returned by Tokens.
+func renderRuns(source []byte, tokens []Token, emit func(text []byte, tok *Token)) {
+	var at uint
+	for i := range tokens {
+		tok := &tokens[i]
+		if tok.Start > uint(len(source)) || tok.End > uint(len(source)) || tok.Start < at {
+			continue
+		}
+		if tok.Start > at {
+			emit(source[at:tok.Start], nil)
+		}
+		emit(source[tok.Start:tok.End], tok)
+		at = tok.End
+	}
+	if at < uint(len(source)) {
+		emit(source[at:], nil)
+	}
+}
@@ -0,0 +1,160 @@
+package tree_sitter_wabznasm
+
+import (
+	"fmt"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lineindex"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+// IncrementalDocument owns the text of a document together with its
+// statements, parsed as ParseScript parses them, and a parse tree of the
+// whole text, and keeps them in sync as edits are applied. The whole tree
+// is only brought up to date when asked for: a text of many statements
+// parses as one long syntax error, which is costly to recover from on
+// every edit. An IncrementalDocument is not safe for concurrent use.
+type IncrementalDocument struct {
+	parser *Parser
+	source []byte
+	script *Script
+	// tree is the parse of an earlier text, edited to match the current
+	// one, if stale.
+	tree    *memtrack.TrackedTree
+	stale   bool
+	changed []tree_sitter.Range
+	lines   *lineindex.Index
+}
+
+// NewIncrementalDocument parses src and returns a document tracking it.
+// The caller must call Close when done.
+func NewIncrementalDocument(src []byte) (*IncrementalDocument, error) {
+	parser, err := NewParser()
+	if err != nil {
+		return nil, err
+	}
+	source := append([]byte(nil), src...)
+	tree, err := parser.ParseBytes(source)
+	if err != nil {
+		parser.Close()
+		return nil, err
+	}
+	script, err := parser.ParseScript(source)
+	if err != nil {
+		tree.Close()
+		parser.Close()
+		return nil, err
+	}
+	return &IncrementalDocument{parser: parser, source: source, script: script, tree: tree, lines: lineindex.New(source)}, nil
+}
+
+// ApplyEdit replaces the bytes in [start, oldEnd) with newText and
+// re-parses incrementally: only the statements the edit touches are parsed
+// again. newEnd is the end offset of the inserted text in the new document
+// and must equal start+len(newText).
+func (d *IncrementalDocument) ApplyEdit(start, oldEnd, newEnd uint, newText []byte) error {
+	if d.script == nil {
+		return ErrParserClosed
+	}
+	if start > oldEnd || oldEnd > uint(len(d.source)) {
+		return fmt.Errorf("wabznasm: edit range [%d, %d) out of bounds for %d-byte document", start, oldEnd, len(d.source))
+	}
+	if newEnd != start+uint(len(newText)) {
+		return fmt.Errorf("wabznasm: edit new end %d does not match start %d plus %d inserted bytes", newEnd, start, len(newText))
+	}
+
+	next := make([]byte, 0, uint(len(d.source))-(oldEnd-start)+uint(len(newText)))
+	next = append(next, d.source[:start]...)
+	next = append(next, newText...)
+	next = append(next, d.source[oldEnd:]...)
+
+	edit := d.lines.InputEdit(start, oldEnd, newText)
+	script, err := d.parser.reparseScript(d.script, next, edit)
+	if err != nil {
+		return err
+	}
+	d.script.Close()
+	d.script = script
+	d.tree.Edit(&edit)
+	d.stale = true
+	d.source = next
+	d.lines.Edit(start, oldEnd, newText)
+	return nil
+}
+
+// Update replaces the text of the document with src, such as a new
+// version of a file read from disk. The difference is applied as a single
+// edit spanning the bytes between the common prefix and suffix of the two
+// versions, so the re-parse reuses what lies outside it. An unchanged text
+// leaves the tree and ChangedRanges as they are.
+func (d *IncrementalDocument) Update(src []byte) error {
+	if d.script == nil {
+		return ErrParserClosed
+	}
+	old := d.source
+	prefix := 0
+	for prefix < len(old) && prefix < len(src) && old[prefix] == src[prefix] {
+		prefix++
+	}
+	if prefix == len(old) && prefix == len(src) {
+		return nil
+	}
+	suffix := 0
+	for suffix < len(old)-prefix && suffix < len(src)-prefix && old[len(old)-1-suffix] == src[len(src)-1-suffix] {
+		suffix++
+	}
+	newText := src[prefix : len(src)-suffix]
+	return d.ApplyEdit(uint(prefix), uint(len(old)-suffix), uint(prefix+len(newText)), newText)
+}
+
+// Tree returns the parse tree of the whole text, parsing it again, reusing
+// the last one, if the text changed since. It is owned by the document and is
+// invalidated by the next ApplyEdit or Close. If the parse fails, as when
+// the document is closed, Tree returns nil.
+func (d *IncrementalDocument) Tree() *tree_sitter.Tree {
+	if d.script == nil {
+		return nil
+	}
+	if d.stale {
+		tree, err := d.parser.Reparse(d.source, d.tree.Tree)
+		if err != nil {
+			return nil
+		}
+		d.changed = d.tree.ChangedRanges(tree.Tree)
+		d.tree.Close()
+		d.tree, d.stale = tree, false
+	}
+	return d.tree.Tree
+}
+
+// Script returns the statements of the current text. It is owned by the
+// document and is invalidated by the next ApplyEdit or Close.
+func (d *IncrementalDocument) Script() *Script { return d.script }
+
+// Source returns the current document text. Callers must not modify it.
+func (d *IncrementalDocument) Source() []byte { return d.source }
+
+// Lines returns the line index of the current text. It is owned by the
+// document and kept up to date by ApplyEdit.
+func (d *IncrementalDocument) Lines() *lineindex.Index { return d.lines }
+
+// ChangedRanges returns the ranges whose syntactic structure changed in the
+// edits since the tree before the current one, bringing the tree up to
+// date as Tree does.
+func (d *IncrementalDocument) ChangedRanges() []tree_sitter.Range {
+	d.Tree()
+	return d.changed
+}
+
+// Close releases the trees and parser.
+func (d *IncrementalDocument) Close() {
+	if d.script != nil {
+		d.script.Close()
+		d.script = nil
+	}
+	if d.tree != nil {
+		d.tree.Close()
+		d.tree = nil
+	}
+	d.parser.Close()
+}
@@ -0,0 +1,144 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+func TestIncrementalDocumentApplyEdit(t *testing.T) {
+	doc, err := tree_sitter_wabznasm.NewIncrementalDocument([]byte("f: {x+1}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+
+	// Replace "1" with "y*2".
+	if err := doc.ApplyEdit(6, 7, 9, []byte("y*2")); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(doc.Source()); got != "f: {x+y*2}" {
+		t.Fatalf("source = %q", got)
+	}
+	root := doc.Tree().RootNode()
+	if root.HasError() {
+		t.Fatalf("unexpected error: %s", root.ToSexp())
+	}
+	if root.EndByte() != 10 {
+		t.Errorf("root end = %d, want 10", root.EndByte())
+	}
+	if len(doc.ChangedRanges()) == 0 {
+		t.Errorf("expected changed ranges after edit")
+	}
+
+	// Delete the body to introduce an error, then restore it.
+	if err := doc.ApplyEdit(4, 9, 4, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !doc.Tree().RootNode().HasError() {
+		t.Errorf("expected error in %q", doc.Source())
+	}
+	if err := doc.ApplyEdit(4, 4, 5, []byte("7")); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Tree().RootNode().HasError() {
+		t.Errorf("unexpected error in %q", doc.Source())
+	}
+}
+
+func TestIncrementalDocumentRejectsBadEdits(t *testing.T) {
+	doc, err := tree_sitter_wabznasm.NewIncrementalDocument([]byte("1+2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+
+	if err := doc.ApplyEdit(2, 10, 3, []byte("x")); err == nil {
+		t.Errorf("expected out-of-bounds error")
+	}
+	if err := doc.ApplyEdit(0, 1, 5, []byte("x")); err == nil {
+		t.Errorf("expected new-end mismatch error")
+	}
+	if got := string(doc.Source()); got != "1+2" {
+		t.Errorf("source changed after rejected edits: %q", got)
+	}
+}
+
+func TestIncrementalDocumentUpdate(t *testing.T) {
+	doc, err := tree_sitter_wabznasm.NewIncrementalDocument([]byte("f: {[x] x+1}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	for _, src := range []string{"f: {[x] x+10}", "f: {[x] x*x+10}", "f: {[x] x*x+10}", "g: {[x] x*x+10}", "1", ""} {
+		if err := doc.Update([]byte(src)); err != nil {
+			t.Fatal(err)
+		}
+		if got := string(doc.Source()); got != src {
+			t.Fatalf("source = %q, want %q", got, src)
+		}
+		fresh, err := parser.ParseString(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := doc.Tree().RootNode().ToSexp(), fresh.RootNode().ToSexp(); got != want {
+			t.Errorf("after update to %q: tree %s, want %s", src, got, want)
+		}
+		fresh.Close()
+	}
+
+	// Updating to the same text keeps the changed ranges of the last edit.
+	doc.Update([]byte("x: 1"))
+	changed := len(doc.ChangedRanges())
+	doc.Update([]byte("x: 1"))
+	if len(doc.ChangedRanges()) != changed {
+		t.Errorf("no-op update changed ranges to %v", doc.ChangedRanges())
+	}
+}
+
+func TestIncrementalDocumentScript(t *testing.T) {
+	doc, err := tree_sitter_wabznasm.NewIncrementalDocument([]byte("a: 1\nb: a+1\n\\ c\nc: b*2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+
+	for _, src := range []string{
+		"a: 1\nb: a+10\n\\ c\nc: b*2\n",              // within a statement
+		"z: 0\na: 1\nb: a+10\n\\ c\nc: b*2\n",        // before every statement
+		"z: 0\na: 1\nb: a+10\n\\ c\nc: b*2\nd: c\n",  // after every statement
+		"z: 0\na: 1\nb: (a+10\n\\ c\nc: b*2\nd: c\n", // opening a statement onto the next
+		"z: 0\na: 1\n",
+	} {
+		if err := doc.Update([]byte(src)); err != nil {
+			t.Fatal(err)
+		}
+		fresh, err := parser.ParseScript([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := doc.Script().Statements
+		if len(got) != len(fresh.Statements) {
+			t.Fatalf("after update to %q: %d statements, want %d", src, len(got), len(fresh.Statements))
+		}
+		for i, want := range fresh.Statements {
+			g, w := got[i].Tree.RootNode(), want.Tree.RootNode()
+			if got[i].Text != want.Text || g.ToSexp() != w.ToSexp() || g.StartByte() != w.StartByte() || g.EndPosition() != w.EndPosition() {
+				t.Errorf("after update to %q: statement %d is %q %s at %d-%v, want %q %s at %d-%v",
+					src, i, got[i].Text, g.ToSexp(), g.StartByte(), g.EndPosition(), want.Text, w.ToSexp(), w.StartByte(), w.EndPosition())
+			}
+		}
+		fresh.Close()
+	}
+}
@@ -0,0 +1,145 @@
+// Package indent computes the indentation an editor should give a line of
+// wabznasm source.
+//
+// The rules come from the bundled indents.scm and follow the tree-sitter
+// indents.scm conventions: a line inside an @indent.begin node is indented
+// one level deeper than the line the node starts on, and a line that starts
+// with an @indent.branch token, a closing bracket, lines up with the line
+// that opened it. Several constructs opening on the same line add a single
+// level. While the source is being typed, unclosed brackets usually end up
+// in error nodes; there the brackets are matched token by token instead.
+package indent
+
+import (
+	"strings"
+	"sync"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+// Unit is the text of one indentation level, as the formatter writes it.
+const Unit = "  "
+
+var indentsQuery = sync.OnceValues(tree_sitter_wabznasm.IndentsQuery)
+
+// For returns the indentation of line, a zero-based row of source, as a
+// run of Unit.
+func For(tree *tree_sitter.Tree, source []byte, line uint) string {
+	return strings.Repeat(Unit, Level(tree, source, line))
+}
+
+// Level returns the number of indentation levels line should have.
+func Level(tree *tree_sitter.Tree, source []byte, line uint) int {
+	q, err := indentsQuery()
+	if err != nil {
+		// The bundled query compiles; see the package tests.
+		panic(err)
+	}
+	start := tree_sitter_wabznasm.OffsetForPoint(source, tree_sitter.Point{Row: line})
+	target := start
+	for target < uint(len(source)) && (source[target] == ' ' || source[target] == '\t') {
+		target++
+	}
+
+	rows := map[uint]bool{}
+	begins := map[uintptr]uint{}
+	var closed uintptr
+	cursor := memtrack.NewQueryCursor()
+	defer cursor.Close()
+	names := q.CaptureNames()
+	captures := cursor.Captures(q.Query, tree.RootNode(), source)
+	for match, index := captures.Next(); match != nil; match, index = captures.Next() {
+		c := match.Captures[index]
+		n := c.Node
+		if inError(&n) {
+			continue
+		}
+		switch names[c.Index] {
+		case "indent.begin":
+			if n.StartPosition().Row < line && (n.EndByte() > target || unclosed(&n)) {
+				begins[n.Id()] = n.StartPosition().Row
+			}
+		case "indent.branch":
+			if n.StartByte() == target && !n.IsMissing() {
+				if p := n.Parent(); p != nil {
+					closed = p.Id()
+				}
+			}
+		}
+	}
+	for id, row := range begins {
+		if id != closed {
+			rows[row] = true
+		}
+	}
+	errorBrackets(tree.RootNode(), source, line, target, rows)
+	return len(rows)
+}
+
+// unclosed reports whether n ends with a closing token the parser had to
+// insert, so that it extends to the end of the input.
+func unclosed(n *tree_sitter.Node) bool {
+	count := n.ChildCount()
+	return count > 0 && n.Child(count-1).IsMissing()
+}
+
+func inError(n *tree_sitter.Node) bool {
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		if p.IsError() {
+			return true
+		}
+	}
+	return false
+}
+
+var closers = map[string]string{"}": "{", "]": "[", ")": "("}
+
+// errorBrackets adds the rows of the brackets left open at target by the
+// tokens of the outermost error nodes starting before line.
+func errorBrackets(n *tree_sitter.Node, source []byte, line, target uint, rows map[uint]bool) {
+	if n.StartPosition().Row >= line && n.StartByte() >= target {
+		return
+	}
+	if !n.IsError() {
+		for i := uint(0); i < n.ChildCount(); i++ {
+			errorBrackets(n.Child(i), source, line, target, rows)
+		}
+		return
+	}
+	type open struct {
+		kind string
+		row  uint
+	}
+	var stack []open
+	var leaves func(n *tree_sitter.Node)
+	leaves = func(n *tree_sitter.Node) {
+		if n.StartByte() >= target && n.EndByte() > n.StartByte() {
+			return
+		}
+		if n.ChildCount() > 0 {
+			for i := uint(0); i < n.ChildCount(); i++ {
+				leaves(n.Child(i))
+			}
+			return
+		}
+		switch kind := n.Kind(); kind {
+		case "{", "[", "(":
+			if !n.IsMissing() {
+				stack = append(stack, open{kind, n.StartPosition().Row})
+			}
+		case "}", "]", ")":
+			if !n.IsMissing() && len(stack) > 0 && stack[len(stack)-1].kind == closers[kind] {
+				stack = stack[:len(stack)-1]
+			}
+		}
+	}
+	leaves(n)
+	if target < uint(len(source)) && len(stack) > 0 && stack[len(stack)-1].kind == closers[string(source[target])] {
+		stack = stack[:len(stack)-1]
+	}
+	for _, o := range stack {
+		rows[o.row] = true
+	}
+}
@@ -0,0 +1,69 @@
+package indent_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/indent"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func parse(t *testing.T, src string) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(parser.Close)
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+func TestLevel(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []int // per line
+	}{
+		{"f: {\nx+1\n}", []int{0, 1, 0}},
+		{"f: {[a;\nb]\na+b\n}", []int{0, 1, 1, 0}},
+		{"t: g[1;\nh[2;\n3]\n]", []int{0, 1, 2, 0}},
+		{"t: (1+\n2)", []int{0, 1}},
+		{"f: {\n\n}", []int{0, 1, 0}},
+		// Unfinished input, as while typing.
+		{"f: {\n", []int{0, 1}},
+		{"f: {\n  x\n", []int{0, 1, 1}},
+		{"g: {x+\n(1+\n", []int{0, 1, 2}},
+		{"f[1;\n]", []int{0, 0}},
+		{"\\ comment\nx", []int{0, 0}},
+	}
+	for _, tt := range tests {
+		tree := parse(t, tt.src)
+		var got []int
+		for line := range tt.want {
+			got = append(got, indent.Level(tree.Tree, []byte(tt.src), uint(line)))
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Level(%q) = %v, want %v\ntree: %s", tt.src, got, tt.want, tree.RootNode().ToSexp())
+				break
+			}
+		}
+	}
+}
+
+func TestFor(t *testing.T) {
+	src := "f: {\nx}"
+	if got := indent.For(parse(t, src).Tree, []byte(src), 1); got != strings.Repeat(indent.Unit, 1) {
+		t.Errorf("For = %q", got)
+	}
+}
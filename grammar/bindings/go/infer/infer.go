@@ -0,0 +1,393 @@
+// Package infer assigns best-effort types to wabznasm expressions and
+// reports the type errors it can prove before a program runs.
+//
+// Types flow forward from what is known: numbers are longs, builtins and
+// values bound in an environment have the types of their values, and
+// operators combine types the way the evaluator combines values. Whatever
+// cannot be known, such as the parameters of a function, is Unknown, and
+// an operation on an Unknown is never an error. The checker is therefore
+// conservative: it reports calls of numbers, symbols and lists, calls with
+// the wrong number of arguments, and arithmetic on symbols and functions,
+// but only where every operand involved is known.
+//
+// The result of calling a function is inferred by checking its body with
+// the types of the arguments, to a limited depth, so that sq[2] is a long
+// for sq: {x*x}. Errors are reported where a function is defined, with
+// its parameters unknown, not once for every call.
+package infer
+
+import (
+	"fmt"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// Kind classifies a type.
+type Kind int
+
+const (
+	// Unknown is any type.
+	Unknown Kind = iota
+	Long
+	Float
+	Symbol
+	// List is a list of items of one type, or of Unknown for a mixed list.
+	List
+	// Func is a function or builtin.
+	Func
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Unknown:
+		return "unknown"
+	case Long:
+		return "long"
+	case Float:
+		return "float"
+	case Symbol:
+		return "symbol"
+	case List:
+		return "list"
+	case Func:
+		return "function"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Type is the inferred type of an expression.
+type Type struct {
+	Kind Kind
+	// Elem is the type of the items of a List.
+	Elem *Type
+	// Arity is the number of parameters of a Func, or -1 if unknown.
+	Arity int
+
+	// fn and env are the definition of a function literal and the
+	// environment it was defined in, for inferring the results of calls.
+	fn  *ast.FunctionDef
+	env *Env
+}
+
+// Basic types.
+var (
+	UnknownType = Type{Kind: Unknown}
+	LongType    = Type{Kind: Long}
+	FloatType   = Type{Kind: Float}
+	SymbolType  = Type{Kind: Symbol}
+)
+
+// ListOf returns the type of lists of elem.
+func ListOf(elem Type) Type { return Type{Kind: List, Elem: &elem} }
+
+// FuncOf returns the type of functions of arity parameters; -1 means any.
+func FuncOf(arity int) Type { return Type{Kind: Func, Arity: arity} }
+
+func (t Type) String() string {
+	switch t.Kind {
+	case List:
+		if t.Elem == nil || t.Elem.Kind == Unknown {
+			return "list"
+		}
+		return "list of " + t.Elem.String()
+	case Func:
+		switch t.Arity {
+		case -1:
+			return "function"
+		case 1:
+			return "function of 1 argument"
+		}
+		return fmt.Sprintf("function of %d arguments", t.Arity)
+	}
+	return t.Kind.String()
+}
+
+func (t Type) elem() Type {
+	if t.Elem == nil {
+		return UnknownType
+	}
+	return *t.Elem
+}
+
+// Of returns the type of a value.
+func Of(v eval.Value) Type {
+	switch v := v.(type) {
+	case eval.Long:
+		return LongType
+	case eval.Float:
+		return FloatType
+	case eval.Symbol:
+		return SymbolType
+	case eval.List:
+		if len(v) == 0 {
+			return ListOf(UnknownType)
+		}
+		elem := Of(v[0])
+		for _, item := range v[1:] {
+			if Of(item).String() != elem.String() {
+				return ListOf(UnknownType)
+			}
+		}
+		return ListOf(elem)
+	case *eval.Function:
+		return FuncOf(len(v.Params))
+	case eval.Callable:
+		return FuncOf(v.Arity())
+	}
+	return UnknownType
+}
+
+// Env binds names to types.
+type Env struct {
+	parent *Env
+	types  map[string]Type
+}
+
+// NewEnv returns an empty environment enclosed by parent, which may be nil.
+func NewEnv(parent *Env) *Env {
+	return &Env{parent: parent, types: map[string]Type{}}
+}
+
+// BuiltinEnv returns an environment binding the registered builtins.
+func BuiltinEnv() *Env {
+	env := NewEnv(nil)
+	for _, b := range eval.Builtins() {
+		env.Define(b.Name, FuncOf(b.Arity()))
+	}
+	return env
+}
+
+// FromValues returns an environment binding the types of the values bound
+// in env and its parents.
+func FromValues(env *eval.Env) *Env {
+	if env == nil {
+		return nil
+	}
+	out := NewEnv(FromValues(env.Parent()))
+	for _, name := range env.Names() {
+		if v, ok := env.Lookup(name); ok {
+			out.Define(name, Of(v))
+		}
+	}
+	return out
+}
+
+// Define binds name to t.
+func (e *Env) Define(name string, t Type) { e.types[name] = t }
+
+// Lookup returns the type bound to name in e or its parents.
+func (e *Env) Lookup(name string) (Type, bool) {
+	for ; e != nil; e = e.parent {
+		if t, ok := e.types[name]; ok {
+			return t, true
+		}
+	}
+	return UnknownType, false
+}
+
+// Error is a type error.
+type Error struct {
+	Span ast.Span
+	// Code is eval.CodeType or eval.CodeArity, the code of the error that
+	// evaluating the expression would raise.
+	Code    string
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Span.Start.Row+1, e.Span.Start.Column+1, e.Message)
+}
+
+// Info is the result of checking a file.
+type Info struct {
+	// Types holds the type of every expression checked.
+	Types map[ast.Expr]Type
+	// Errors are the type errors found, in source order.
+	Errors []Error
+}
+
+// TypeOf returns the type of e, or UnknownType if it was not checked.
+func (info *Info) TypeOf(e ast.Expr) Type {
+	if t, ok := info.Types[e]; ok {
+		return t
+	}
+	return UnknownType
+}
+
+// maxDepth bounds the nesting of calls whose results are inferred from
+// the bodies of the functions called.
+const maxDepth = 4
+
+// Check infers the types of the statement of f in env. An assignment
+// binds its name in env, so that the files of a script can be checked in
+// order with one environment.
+func Check(f *ast.File, env *Env) *Info {
+	info := &Info{Types: map[ast.Expr]Type{}}
+	c := &checker{info: info}
+	switch s := f.Stmt.(type) {
+	case *ast.Assignment:
+		if s.Name != nil {
+			// Bind the name first, so that a recursive function refers to
+			// itself.
+			if fn, ok := s.Value.(*ast.FunctionDef); ok {
+				env.Define(s.Name.Name, Type{Kind: Func, Arity: len(fn.Signature()), fn: fn, env: env})
+			}
+			env.Define(s.Name.Name, c.expr(s.Value, env))
+		}
+	case *ast.ExprStmt:
+		c.expr(s.X, env)
+	}
+	return info
+}
+
+type checker struct {
+	// info is nil while inferring the result of a call, when neither
+	// types nor errors are recorded.
+	info  *Info
+	depth int
+}
+
+func (c *checker) errorf(n ast.Node, code, format string, args ...any) {
+	if c.info != nil {
+		c.info.Errors = append(c.info.Errors, Error{
+			Span:    ast.Span{Start: n.Pos(), End: n.EndPos()},
+			Code:    code,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+}
+
+func (c *checker) expr(e ast.Expr, env *Env) Type {
+	t := c.infer(e, env)
+	if c.info != nil && e != nil {
+		c.info.Types[e] = t
+	}
+	return t
+}
+
+func (c *checker) infer(e ast.Expr, env *Env) Type {
+	switch e := e.(type) {
+	case *ast.NumberLiteral:
+		return LongType
+	case *ast.Ident:
+		t, _ := env.Lookup(e.Name)
+		return t
+	case *ast.ParenExpr:
+		return c.expr(e.X, env)
+	case *ast.UnaryExpr:
+		t := c.expr(e.Operand, env)
+		r, ok := negate(t)
+		if !ok {
+			c.errorf(e, eval.CodeType, "cannot negate %s", t)
+		}
+		return r
+	case *ast.PostfixExpr:
+		t := c.expr(e.Operand, env)
+		r, ok := factorial(t)
+		if !ok {
+			c.errorf(e, eval.CodeType, "factorial of %s", t)
+		}
+		return r
+	case *ast.BinaryExpr:
+		l := c.expr(e.Left, env)
+		r := c.expr(e.Right, env)
+		t, ok := binary(l, r)
+		if !ok {
+			c.errorf(e, eval.CodeType, "cannot apply %s to %s and %s", e.Op, l, r)
+		}
+		return t
+	case *ast.FunctionDef:
+		local := NewEnv(env)
+		for _, name := range e.Signature() {
+			local.Define(name, UnknownType)
+		}
+		if e.Body != nil {
+			c.expr(e.Body, local)
+		}
+		return Type{Kind: Func, Arity: len(e.Signature()), fn: e, env: env}
+	case *ast.Call:
+		return c.call(e, env)
+	}
+	return UnknownType
+}
+
+func (c *checker) call(e *ast.Call, env *Env) Type {
+	callee := UnknownType
+	if e.Func != nil {
+		callee = c.expr(e.Func, env)
+	}
+	args := make([]Type, len(e.Args))
+	for i, arg := range e.Args {
+		args[i] = c.expr(arg, env)
+	}
+	switch callee.Kind {
+	case Unknown:
+		return UnknownType
+	case Func:
+	default:
+		c.errorf(e, eval.CodeType, "cannot call %s", callee)
+		return UnknownType
+	}
+	if callee.Arity >= 0 && callee.Arity != len(args) {
+		c.errorf(e, eval.CodeArity, "%s", eval.ArityMessage(callee.Arity, len(args)))
+		return UnknownType
+	}
+	if callee.fn == nil || callee.fn.Body == nil || c.depth >= maxDepth {
+		return UnknownType
+	}
+	local := NewEnv(callee.env)
+	for i, name := range callee.fn.Signature() {
+		local.Define(name, args[i])
+	}
+	sub := &checker{depth: c.depth + 1}
+	return sub.expr(callee.fn.Body, local)
+}
+
+// binary returns the type of applying an arithmetic operator to operands
+// of types a and b, reporting false if that is certainly an error.
+func binary(a, b Type) (Type, bool) {
+	switch {
+	case a.Kind == List && b.Kind == List:
+		// The lists may be empty, so their items are not checked.
+		t, _ := binary(a.elem(), b.elem())
+		return ListOf(t), true
+	case a.Kind == List:
+		t, _ := binary(a.elem(), b)
+		return ListOf(t), true
+	case b.Kind == List:
+		t, _ := binary(a, b.elem())
+		return ListOf(t), true
+	case a.Kind == Unknown || b.Kind == Unknown:
+		return UnknownType, true
+	case !numeric(a) || !numeric(b):
+		return UnknownType, false
+	case a.Kind == Float || b.Kind == Float:
+		return FloatType, true
+	}
+	return LongType, true
+}
+
+func numeric(t Type) bool { return t.Kind == Long || t.Kind == Float }
+
+func negate(t Type) (Type, bool) {
+	switch t.Kind {
+	case Long, Float, Unknown:
+		return t, true
+	case List:
+		elem, _ := negate(t.elem())
+		return ListOf(elem), true
+	}
+	return UnknownType, false
+}
+
+func factorial(t Type) (Type, bool) {
+	switch t.Kind {
+	case Long, Unknown:
+		return t, true
+	case List:
+		elem, _ := factorial(t.elem())
+		return ListOf(elem), true
+	}
+	return UnknownType, false
+}
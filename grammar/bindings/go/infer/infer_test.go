@@ -0,0 +1,117 @@
+package infer_test
+
+import (
+	"slices"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/infer"
+)
+
+// check checks the statements of script in order in env, returning the
+// type of the last one and every error.
+func check(t *testing.T, env *infer.Env, script ...string) (infer.Type, []string) {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	var last infer.Type
+	var errs []string
+	for _, src := range script {
+		tree, err := parser.ParseString(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f := ast.FromTree(tree.Tree, []byte(src))
+		tree.Close()
+		info := infer.Check(f, env)
+		for _, e := range info.Errors {
+			errs = append(errs, e.Error())
+		}
+		switch s := f.Stmt.(type) {
+		case *ast.Assignment:
+			last = info.TypeOf(s.Value)
+		case *ast.ExprStmt:
+			last = info.TypeOf(s.X)
+		}
+	}
+	return last, errs
+}
+
+func TestTypes(t *testing.T) {
+	env := infer.NewEnv(nil)
+	env.Define("pi", infer.FloatType)
+	env.Define("names", infer.ListOf(infer.SymbolType))
+	env.Define("prices", infer.ListOf(infer.LongType))
+	for _, tt := range []struct {
+		script []string
+		want   string
+	}{
+		{[]string{"1 + 2 * 3"}, "long"},
+		{[]string{"2 * pi"}, "float"},
+		{[]string{"-pi"}, "float"},
+		{[]string{"prices * pi"}, "list of float"},
+		{[]string{"prices!"}, "list of long"},
+		{[]string{"f: {[a;b] a+b}"}, "function of 2 arguments"},
+		{[]string{"f: {x*y}"}, "function of 2 arguments"},
+		{[]string{"sq: {x*x}", "sq[3]"}, "long"},
+		{[]string{"sq: {x*x}", "sq[pi]"}, "float"},
+		{[]string{"scale: {[k] prices*k}", "scale[2]"}, "list of long"},
+		// Results of recursive calls cannot be known.
+		{[]string{"fact: {[n] n*fact[n-1]}", "fact[5]"}, "unknown"},
+		{[]string{"q * 2"}, "unknown"},
+	} {
+		got, errs := check(t, infer.NewEnv(env), tt.script...)
+		if got.String() != tt.want || len(errs) > 0 {
+			t.Errorf("%q: %s, errors %v; want %s", tt.script, got, errs, tt.want)
+		}
+	}
+}
+
+func TestErrors(t *testing.T) {
+	env := infer.NewEnv(nil)
+	env.Define("rate", infer.LongType)
+	env.Define("ticker", infer.SymbolType)
+	env.Define("prices", infer.ListOf(infer.LongType))
+	env.Define("pair", infer.FuncOf(2))
+	for _, tt := range []struct {
+		script []string
+		want   []string
+	}{
+		{[]string{"rate[1]"}, []string{"1:1: cannot call long"}},
+		{[]string{"prices[2]"}, []string{"1:1: cannot call list of long"}},
+		{[]string{"pair[1]"}, []string{"1:1: arity mismatch: expected 2 arguments, got 1"}},
+		{[]string{"f: {[a] a+1}", "f[1;2]"}, []string{"1:1: arity mismatch: expected 1 argument, got 2"}},
+		{[]string{"ticker + 1"}, []string{"1:1: cannot apply + to symbol and long"}},
+		{[]string{"x: 1 + -ticker"}, []string{"1:8: cannot negate symbol"}},
+		{[]string{"ticker!"}, []string{"1:1: factorial of symbol"}},
+		{[]string{"pair + 1"}, []string{"1:1: cannot apply + to function of 2 arguments and long"}},
+		// Errors inside a function are found with its parameters unknown.
+		{[]string{"f: {[a] a + rate[a]}"}, []string{"1:13: cannot call long"}},
+		// Operands that are not known are never errors.
+		{[]string{"f: {[g;a] g[a] + ticker}"}, nil},
+		{[]string{"prices + ticker"}, nil},
+	} {
+		_, errs := check(t, infer.NewEnv(env), tt.script...)
+		if !slices.Equal(errs, tt.want) {
+			t.Errorf("%q: errors %q, want %q", tt.script, errs, tt.want)
+		}
+	}
+}
+
+func TestFromValues(t *testing.T) {
+	values := eval.NewEnv(nil)
+	values.Define("n", eval.Long(1))
+	values.Define("xs", eval.List{eval.Float(1), eval.Float(2)})
+	values.Define("mixed", eval.List{eval.Float(1), eval.Symbol("a")})
+	env := infer.FromValues(eval.NewEnv(values))
+	for name, want := range map[string]string{"n": "long", "xs": "list of float", "mixed": "list"} {
+		if got, ok := env.Lookup(name); !ok || got.String() != want {
+			t.Errorf("Lookup(%s) = %s, %v; want %s", name, got, ok, want)
+		}
+	}
+}
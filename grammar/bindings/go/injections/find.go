@@ -0,0 +1,157 @@
+package injections
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// Language is the info string, class suffix or type that marks an
+// embedded block as wabznasm.
+const Language = "wabznasm"
+
+// quotePrefix matches the block quote markers and indentation before a
+// Markdown fence or its content.
+var quotePrefix = regexp.MustCompile(`^(?: {0,3}> ?)*`)
+
+// Markdown returns the fenced code blocks of src whose info string starts
+// with "wabznasm". Fences may be indented and nested in block quotes, whose
+// markers are left out of the block. A fence left open runs to the end of
+// the document, as in CommonMark.
+func Markdown(src []byte) []Block {
+	var blocks []Block
+	var open *fence
+	var offsets [][2]uint
+	for start := 0; start < len(src); {
+		end := start + bytes.IndexByte(src[start:], '\n') + 1
+		if end == start {
+			end = len(src)
+		}
+		line := src[start:end]
+		prefix := len(quotePrefix.Find(line))
+		body := line[prefix:]
+		switch {
+		case open == nil:
+			if f, ok := openFence(body); ok {
+				open = &f
+				offsets = nil
+			}
+		case open.closedBy(body):
+			if open.wabznasm {
+				if offsets == nil {
+					// An empty block is located where its content would be.
+					offsets = [][2]uint{{uint(start + prefix), uint(start + prefix)}}
+				}
+				blocks = append(blocks, Ranges(src, offsets...))
+			}
+			open = nil
+		default:
+			offsets = append(offsets, [2]uint{uint(start + prefix), uint(end)})
+		}
+		start = end
+	}
+	if open != nil && open.wabznasm {
+		blocks = append(blocks, Ranges(src, offsets...))
+	}
+	return blocks
+}
+
+type fence struct {
+	char     byte
+	length   int
+	wabznasm bool
+}
+
+// openFence parses an opening code fence: up to three spaces, three or more
+// backticks or tildes, and an info string.
+func openFence(line []byte) (fence, bool) {
+	trimmed := bytes.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) == 0 || trimmed[0] != '`' && trimmed[0] != '~' {
+		return fence{}, false
+	}
+	f := fence{char: trimmed[0]}
+	for f.length < len(trimmed) && trimmed[f.length] == f.char {
+		f.length++
+	}
+	info := string(bytes.TrimSpace(trimmed[f.length:]))
+	if f.length < 3 || f.char == '`' && strings.Contains(info, "`") {
+		return fence{}, false
+	}
+	if fields := strings.Fields(info); len(fields) > 0 {
+		f.wabznasm = strings.EqualFold(fields[0], Language)
+	}
+	return f, true
+}
+
+// closedBy reports whether line closes the fence: up to three spaces and at
+// least as many fence characters, then only white space.
+func (f *fence) closedBy(line []byte) bool {
+	trimmed := bytes.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return false
+	}
+	n := 0
+	for n < len(trimmed) && trimmed[n] == f.char {
+		n++
+	}
+	return n >= f.length && len(bytes.TrimSpace(trimmed[n:])) == 0
+}
+
+var (
+	htmlOpen = regexp.MustCompile(`(?is)<(script|code)\b([^>]*)>`)
+	htmlType = regexp.MustCompile(`(?is)\btype\s*=\s*["']?(?:text|application)/(?:x-)?wabznasm\b`)
+	// htmlClass matches the class Markdown renderers give highlighted code.
+	htmlClass = regexp.MustCompile(`(?is)\bclass\s*=\s*["']?[^"'>]*\blang(?:uage)?-wabznasm\b`)
+	htmlClose = map[string]*regexp.Regexp{
+		"script": regexp.MustCompile(`(?i)</script\s*>`),
+		"code":   regexp.MustCompile(`(?i)</code\s*>`),
+	}
+)
+
+// HTML returns the contents of the script elements of src typed
+// text/wabznasm and of the code elements classed language-wabznasm.
+// Character references are not decoded; wabznasm uses none of the
+// characters HTML escapes.
+func HTML(src []byte) []Block {
+	var blocks []Block
+	for pos := 0; pos < len(src); {
+		m := htmlOpen.FindSubmatchIndex(src[pos:])
+		if m == nil {
+			break
+		}
+		tag := strings.ToLower(string(src[pos+m[2] : pos+m[3]]))
+		attrs := src[pos+m[4] : pos+m[5]]
+		start := pos + m[1]
+		end := len(src)
+		if c := htmlClose[tag].FindIndex(src[start:]); c != nil {
+			end = start + c[0]
+		}
+		if tag == "script" && htmlType.Match(attrs) || tag == "code" && htmlClass.Match(attrs) {
+			blocks = append(blocks, Ranges(src, [2]uint{uint(start), uint(end)}))
+		}
+		pos = end
+	}
+	return blocks
+}
+
+// Delimited returns the text between each open delimiter of src and the
+// following close delimiter, as the tags of a templating language such as
+// "{% wabznasm %}" and "{% end %}" enclose code. A block left open runs to
+// the end of src.
+func Delimited(src []byte, open, close string) []Block {
+	var blocks []Block
+	for pos := 0; pos < len(src); {
+		i := bytes.Index(src[pos:], []byte(open))
+		if i < 0 || open == "" {
+			break
+		}
+		start := pos + i + len(open)
+		end := len(src)
+		if j := bytes.Index(src[start:], []byte(close)); j >= 0 && close != "" {
+			end = start + j
+		}
+		blocks = append(blocks, Ranges(src, [2]uint{uint(start), uint(end)}))
+		pos = end + len(close)
+	}
+	return blocks
+}
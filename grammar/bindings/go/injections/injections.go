@@ -0,0 +1,149 @@
+// Package injections parses wabznasm embedded in other documents, such as
+// the fenced code blocks of Markdown, the script elements of HTML or the
+// tags of a templating language.
+//
+// Each embedded block is parsed on its own, with the parser restricted to
+// the block's ranges of the host document. Trees therefore use host
+// coordinates, and so do the diagnostics and highlights derived from them:
+// they can be reported against the host document directly.
+package injections
+
+import (
+	"sort"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/highlight"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+// Block is an embedded wabznasm source: the ranges of the host document
+// holding its text, in order. A block spans several ranges when the host
+// interleaves its own markup with the code, as a Markdown block quote
+// prefixes each line with '>'.
+type Block struct {
+	Ranges []tree_sitter.Range
+}
+
+// Start returns the offset in the host of the block's first byte.
+func (b Block) Start() uint {
+	if len(b.Ranges) == 0 {
+		return 0
+	}
+	return b.Ranges[0].StartByte
+}
+
+// Text returns the block's source: its ranges of host concatenated.
+func (b Block) Text(host []byte) []byte {
+	var out []byte
+	for _, r := range b.Ranges {
+		out = append(out, host[r.StartByte:r.EndByte]...)
+	}
+	return out
+}
+
+// Parsed is a block and its parse tree.
+type Parsed struct {
+	Block Block
+	Tree  *memtrack.TrackedTree
+}
+
+// Document is a host document and its parsed blocks.
+type Document struct {
+	Source []byte
+	Blocks []Parsed
+}
+
+// Parse parses the blocks of host. The returned document must be closed
+// by the caller, and host must not be modified while it is in use.
+func Parse(host []byte, blocks []Block) (*Document, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	doc := &Document{Source: host}
+	for _, b := range blocks {
+		ranges := b.Ranges
+		if len(ranges) == 0 {
+			// No ranges would include the whole document; an empty range
+			// parses an empty source instead.
+			ranges = []tree_sitter.Range{rangeOf(host, 0, 0)}
+		}
+		if err := parser.Raw().SetIncludedRanges(ranges); err != nil {
+			doc.Close()
+			return nil, err
+		}
+		tree, err := parser.ParseBytes(host)
+		if err != nil {
+			doc.Close()
+			return nil, err
+		}
+		doc.Blocks = append(doc.Blocks, Parsed{Block: b, Tree: tree})
+	}
+	return doc, nil
+}
+
+// Close releases the trees of the document.
+func (d *Document) Close() {
+	for _, p := range d.Blocks {
+		p.Tree.Close()
+	}
+	d.Blocks = nil
+}
+
+// Diagnostics returns the syntax errors of every block, in host order.
+func (d *Document) Diagnostics() []tree_sitter_wabznasm.Diagnostic {
+	var out []tree_sitter_wabznasm.Diagnostic
+	for _, p := range d.Blocks {
+		out = append(out, tree_sitter_wabznasm.Diagnostics(p.Tree.Tree, d.Source)...)
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Range.StartByte < out[j].Range.StartByte })
+	return out
+}
+
+// Highlights returns the highlighted tokens of every block, in host order.
+// Tokens are clipped to the blocks' ranges, so host markup between the
+// ranges of a block is never highlighted.
+func (d *Document) Highlights(h *highlight.Highlighter) []highlight.Token {
+	var out []highlight.Token
+	for _, p := range d.Blocks {
+		for _, tok := range h.Tokens(p.Tree.Tree, d.Source) {
+			for _, r := range p.Block.Ranges {
+				start, end := max(tok.Start, r.StartByte), min(tok.End, r.EndByte)
+				if start < end {
+					clipped := tok
+					clipped.Start, clipped.End = start, end
+					out = append(out, clipped)
+				}
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Start < out[j].Start })
+	return out
+}
+
+// rangeOf returns the range of host between two offsets.
+func rangeOf(host []byte, start, end uint) tree_sitter.Range {
+	return tree_sitter.Range{
+		StartByte:  start,
+		EndByte:    end,
+		StartPoint: tree_sitter_wabznasm.PointForOffset(host, start),
+		EndPoint:   tree_sitter_wabznasm.PointForOffset(host, end),
+	}
+}
+
+// Ranges returns a block of the given [start, end) offset pairs of host,
+// for hosts the finders of this package do not handle. Adjacent pairs are
+// merged.
+func Ranges(host []byte, offsets ...[2]uint) Block {
+	var b Block
+	for _, o := range offsets {
+		if n := len(b.Ranges); n > 0 && b.Ranges[n-1].EndByte == o[0] {
+			b.Ranges[n-1] = rangeOf(host, b.Ranges[n-1].StartByte, o[1])
+			continue
+		}
+		b.Ranges = append(b.Ranges, rangeOf(host, o[0], o[1]))
+	}
+	return b
+}
@@ -0,0 +1,145 @@
+package injections_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/highlight"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/injections"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+const markdown = "# Notes\n" +
+	"\n" +
+	"```wabznasm\n" +
+	"square: {x*x}\n" +
+	"```\n" +
+	"\n" +
+	"```python\n" +
+	"print(1 +)\n" +
+	"```\n" +
+	"\n" +
+	"> Quoted:\n" +
+	"> ~~~~ wabznasm title=broken\n" +
+	"> total: 1 +\n" +
+	">   2 *\n" +
+	"> ~~~~\n" +
+	"\n" +
+	"```Wabznasm\n" +
+	"square[3]\n"
+
+func texts(src []byte, blocks []injections.Block) []string {
+	out := make([]string, len(blocks))
+	for i, b := range blocks {
+		out[i] = string(b.Text(src))
+	}
+	return out
+}
+
+func TestMarkdown(t *testing.T) {
+	src := []byte(markdown)
+	blocks := injections.Markdown(src)
+	want := []string{"square: {x*x}\n", "total: 1 +\n  2 *\n", "square[3]\n"}
+	if got := texts(src, blocks); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("blocks = %q, want %q", got, want)
+	}
+	if n := len(blocks[1].Ranges); n != 2 {
+		t.Errorf("quoted block has %d ranges, want one per line", n)
+	}
+
+	doc, err := injections.Parse(src, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+	diags := doc.Diagnostics()
+	if len(diags) != 1 {
+		t.Fatalf("diagnostics = %v, want one", diags)
+	}
+	// The error is reported where it is in the host: line 14, after the
+	// block quote marker.
+	if p := diags[0].Range.StartPoint; p.Row != 13 || p.Column < 2 {
+		t.Errorf("diagnostic at %v: %v", p, diags[0])
+	}
+	if root := doc.Blocks[0].Tree.RootNode(); root.StartByte() != uint(strings.Index(markdown, "square")) {
+		t.Errorf("first block starts at %d", root.StartByte())
+	}
+
+	h, err := highlight.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	var lit []string
+	for _, tok := range doc.Highlights(h) {
+		text := markdown[tok.Start:tok.End]
+		if strings.Contains(text, ">") || strings.Contains(text, "`") {
+			t.Errorf("token %q highlights host markup", text)
+		}
+		lit = append(lit, text)
+	}
+	if got := strings.Join(lit, " "); !strings.Contains(got, "square") || !strings.Contains(got, "total") {
+		t.Errorf("highlighted %q", got)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	src := []byte(`<p>Try it:</p>
+<script type="text/wabznasm">f: {[a] a+1}</script>
+<script>var x = 1 +;</script>
+<pre><code class="hl language-wabznasm">f[41]</code></pre>
+<code>not wabznasm</code>`)
+	blocks := injections.HTML(src)
+	if got := texts(src, blocks); strings.Join(got, "|") != "f: {[a] a+1}|f[41]" {
+		t.Fatalf("blocks = %q", got)
+	}
+	doc, err := injections.Parse(src, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+	if d := doc.Diagnostics(); len(d) != 0 {
+		t.Errorf("diagnostics = %v", d)
+	}
+}
+
+func TestDelimited(t *testing.T) {
+	src := []byte("Total: {{ 1 + }} of {{ n*2 }} and {{ open")
+	blocks := injections.Delimited(src, "{{", "}}")
+	if got := texts(src, blocks); strings.Join(got, "|") != " 1 + | n*2 | open" {
+		t.Fatalf("blocks = %q", got)
+	}
+	doc, err := injections.Parse(src, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+	diags := doc.Diagnostics()
+	if len(diags) != 1 || diags[0].Range.StartByte < 9 || diags[0].Range.EndByte > 14 {
+		t.Errorf("diagnostics = %v, want one inside the first tag", diags)
+	}
+}
+
+func TestEmptyBlock(t *testing.T) {
+	src := []byte("text\n```wabznasm\n```\n")
+	blocks := injections.Markdown(src)
+	if len(blocks) != 1 || len(blocks[0].Text(src)) != 0 {
+		t.Fatalf("blocks = %v", blocks)
+	}
+	doc, err := injections.Parse(src, blocks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer doc.Close()
+	// Like an empty file, an empty block is a syntax error, reported
+	// where the block is.
+	d := doc.Diagnostics()
+	if len(d) != 1 || d[0].Range.StartPoint.Row != 2 {
+		t.Errorf("diagnostics = %v, want one on line 3", d)
+	}
+}
@@ -0,0 +1,46 @@
+//go:build unix
+
+// Package dl loads tree-sitter languages from shared objects at run time.
+package dl
+
+// #cgo linux LDFLAGS: -ldl
+// #include <dlfcn.h>
+// #include <stdlib.h>
+//
+// typedef const void *(*language_fn)(void);
+//
+// static const void *call_language(void *fn) { return ((language_fn)fn)(); }
+import "C"
+
+import (
+	"errors"
+	"fmt"
+	"unsafe"
+)
+
+// Language opens the shared object at path and returns the language its
+// function symbol returns, such as tree_sitter_calc. Errors do not name
+// path, which callers add. The object stays
+// loaded for the life of the process, since trees and parsers refer to
+// the language's tables.
+func Language(path, symbol string) (unsafe.Pointer, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	handle := C.dlopen(cpath, C.RTLD_NOW|C.RTLD_LOCAL)
+	if handle == nil {
+		return nil, errors.New(C.GoString(C.dlerror()))
+	}
+	csym := C.CString(symbol)
+	defer C.free(unsafe.Pointer(csym))
+	fn := C.dlsym(handle, csym)
+	if fn == nil {
+		err := fmt.Errorf("no symbol %s", symbol)
+		C.dlclose(handle)
+		return nil, err
+	}
+	lang := C.call_language(fn)
+	if lang == nil {
+		return nil, fmt.Errorf("%s returned no language", symbol)
+	}
+	return unsafe.Pointer(lang), nil
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package dl
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// Language reports that shared objects cannot be loaded on this system.
+func Language(path, symbol string) (unsafe.Pointer, error) {
+	return nil, fmt.Errorf("loading shared objects is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,64 @@
+// Package group runs a set of tasks on a bounded number of goroutines and
+// collects the first error, in the manner of golang.org/x/sync/errgroup,
+// which the module does not depend on.
+package group
+
+import (
+	"context"
+	"sync"
+)
+
+// Group is a set of tasks sharing a context that is cancelled when a task
+// fails or Wait returns.
+type Group struct {
+	cancel func()
+	wg     sync.WaitGroup
+	sem    chan struct{}
+
+	once sync.Once
+	err  error
+}
+
+// WithContext returns a group that runs at most limit tasks at once, or
+// any number if limit is not positive, and a context derived from ctx for
+// its tasks.
+func WithContext(ctx context.Context, limit int) (*Group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	g := &Group{cancel: cancel}
+	if limit > 0 {
+		g.sem = make(chan struct{}, limit)
+	}
+	return g, ctx
+}
+
+// Go runs f on a new goroutine, waiting first until fewer than the limit
+// of tasks are running. The first task to return an error cancels the
+// group's context.
+func (g *Group) Go(f func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+	g.wg.Add(1)
+	go func() {
+		defer func() {
+			if g.sem != nil {
+				<-g.sem
+			}
+			g.wg.Done()
+		}()
+		if err := f(); err != nil {
+			g.once.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+// Wait waits for every task, cancels the context and returns the first
+// error.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
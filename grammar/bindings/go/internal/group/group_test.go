@@ -0,0 +1,45 @@
+package group_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/group"
+)
+
+func TestLimit(t *testing.T) {
+	g, _ := group.WithContext(context.Background(), 2)
+	var running, peak atomic.Int32
+	for range 20 {
+		g.Go(func() error {
+			n := running.Add(1)
+			defer running.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(time.Millisecond)
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if peak.Load() > 2 {
+		t.Errorf("%d tasks ran at once, limit 2", peak.Load())
+	}
+}
+
+func TestFirstErrorCancels(t *testing.T) {
+	boom := errors.New("boom")
+	g, ctx := group.WithContext(context.Background(), 0)
+	g.Go(func() error { return boom })
+	g.Go(func() error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := g.Wait(); err != boom {
+		t.Errorf("Wait = %v, want boom", err)
+	}
+}
@@ -0,0 +1,105 @@
+// Package script divides wabznasm scripts into statements. It is shared
+// by Parser.ParseScript of the bindings, the REPL, the formatter, the
+// anonymizer and the gRPC server, and depends on neither the parser nor
+// the interpreter, so that it builds for js/wasm.
+package script
+
+import "strings"
+
+// Entry is a statement of a script or cell and its byte offset in it.
+type Entry struct {
+	Text   string
+	Offset int
+}
+
+// Split splits a script into statements: one per line, except that a line
+// leaving brackets open continues on the next. Blank lines and lines
+// holding only a comment are skipped.
+func Split(code string) []Entry {
+	var out []Entry
+	var cur *Entry
+	off := 0
+	for _, line := range strings.SplitAfter(code, "\n") {
+		start := off
+		off += len(line)
+		if cur == nil {
+			if t := strings.TrimSpace(line); t == "" || strings.HasPrefix(t, `\`) {
+				continue
+			}
+			cur = &Entry{Offset: start}
+		}
+		cur.Text += line
+		if !NeedsContinuation(cur.Text) {
+			cur.Text = strings.TrimRight(cur.Text, "\r\n")
+			out = append(out, *cur)
+			cur = nil
+		}
+	}
+	if cur != nil {
+		cur.Text = strings.TrimRight(cur.Text, "\r\n")
+		out = append(out, *cur)
+	}
+	return out
+}
+
+// Isolate returns script with everything but e blanked out, so that the
+// positions of a parse of the result are positions in script.
+func (e Entry) Isolate(script []byte) []byte {
+	out := make([]byte, len(script))
+	for i, b := range script {
+		if b == '\n' || e.Offset <= i && i < e.Offset+len(e.Text) {
+			out[i] = b
+		} else {
+			out[i] = ' '
+		}
+	}
+	return out
+}
+
+// Extent returns the bytes [start, end) of script that go with entries[i],
+// where entries are those Split returned for script: the lines of the
+// entry and the comment and blank lines between it and the entry before
+// it, and for the last entry the lines after it too. The extents of the
+// entries divide script between them, so that each comment goes with the
+// statement it documents.
+func Extent(script string, entries []Entry, i int) (start, end int) {
+	if i > 0 {
+		start = lineEnd(script, entries[i-1])
+	}
+	end = len(script)
+	if i < len(entries)-1 {
+		end = lineEnd(script, entries[i])
+	}
+	return start, end
+}
+
+// lineEnd returns the offset just past the line on which e ends.
+func lineEnd(script string, e Entry) int {
+	end := e.Offset + len(e.Text)
+	if i := strings.IndexByte(script[end:], '\n'); i >= 0 {
+		return end + i + 1
+	}
+	return len(script)
+}
+
+// NeedsContinuation reports whether src has unclosed parentheses, brackets
+// or braces and so should be continued on the next line. Text inside
+// comments is ignored.
+func NeedsContinuation(src string) bool {
+	depth := 0
+	inComment := false
+	for _, r := range src {
+		switch {
+		case r == '\n':
+			inComment = false
+		case inComment:
+		case r == '\\':
+			inComment = true
+		case r == '(' || r == '[' || r == '{':
+			depth++
+		case r == ')' || r == ']' || r == '}':
+			depth--
+		}
+	}
+	return depth > 0
+}
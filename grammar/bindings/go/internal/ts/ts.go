@@ -0,0 +1,40 @@
+//go:build !(js && wasm)
+
+// Package ts is the tree-sitter runtime of the parser wrapper, formatter
+// and highlighter. Natively it is go-tree-sitter, whose types it aliases,
+// so trees built by those packages are go-tree-sitter trees. On js/wasm,
+// where cgo is unavailable, it implements the same API over web-tree-sitter
+// and the wasm build of the grammar; see ts_js.go.
+package ts
+
+import tree_sitter "github.com/tree-sitter/go-tree-sitter"
+
+type (
+	InputEdit    = tree_sitter.InputEdit
+	Language     = tree_sitter.Language
+	Node         = tree_sitter.Node
+	ParseOptions = tree_sitter.ParseOptions
+	ParseState   = tree_sitter.ParseState
+	Parser       = tree_sitter.Parser
+	Point        = tree_sitter.Point
+	Query        = tree_sitter.Query
+	QueryCursor  = tree_sitter.QueryCursor
+	Range        = tree_sitter.Range
+	Tree         = tree_sitter.Tree
+)
+
+// LanguageMetadata is the version of a grammar.
+type LanguageMetadata = tree_sitter.LanguageMetadata
+
+// The ABI versions of the grammars the runtime can load.
+const (
+	LANGUAGE_VERSION                = tree_sitter.LANGUAGE_VERSION
+	MIN_COMPATIBLE_LANGUAGE_VERSION = tree_sitter.MIN_COMPATIBLE_LANGUAGE_VERSION
+)
+
+var (
+	NewLanguage    = tree_sitter.NewLanguage
+	NewParser      = tree_sitter.NewParser
+	NewQuery       = tree_sitter.NewQuery
+	NewQueryCursor = tree_sitter.NewQueryCursor
+)
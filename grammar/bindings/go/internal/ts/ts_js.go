@@ -0,0 +1,478 @@
+//go:build js && wasm
+
+package ts
+
+import (
+	"errors"
+	"fmt"
+	"syscall/js"
+	"unsafe"
+)
+
+// This file implements the part of the go-tree-sitter API the parser
+// wrapper, formatter and highlighter use, over web-tree-sitter (0.25 or
+// later) running the wasm build of the grammar. The page loads both and
+// hands them over with Load before the first parse.
+//
+// web-tree-sitter reads JavaScript strings and counts offsets and columns
+// in UTF-16 code units, where go-tree-sitter counts bytes of UTF-8. Source
+// is therefore passed with each byte as one code unit, so that offsets and
+// columns come back in bytes. The grammar's tokens are ASCII, and other
+// bytes only appear in comments and errors, so the trees are the same.
+
+var runtime struct {
+	parser, query, language js.Value
+}
+
+// ErrNotLoaded is returned when a parser is created before Load.
+var ErrNotLoaded = errors.New("ts: web-tree-sitter is not loaded")
+
+// Load registers the web-tree-sitter module, whose Parser and Query
+// classes are used, and the wabznasm language loaded with Language.load.
+// Parser.init must have completed.
+func Load(module, language js.Value) {
+	runtime.parser = module.Get("Parser")
+	runtime.query = module.Get("Query")
+	runtime.language = language
+	if v := module.Get("LANGUAGE_VERSION"); v.Type() == js.TypeNumber {
+		LANGUAGE_VERSION = uint32(v.Int())
+	}
+	if v := module.Get("MIN_COMPATIBLE_VERSION"); v.Type() == js.TypeNumber {
+		MIN_COMPATIBLE_LANGUAGE_VERSION = uint32(v.Int())
+	}
+}
+
+// The ABI versions of the grammars the runtime can load: those of
+// web-tree-sitter 0.25 until Load reads the module's own.
+var (
+	LANGUAGE_VERSION                uint32 = 15
+	MIN_COMPATIBLE_LANGUAGE_VERSION uint32 = 13
+)
+
+// Loaded reports whether Load has been called.
+func Loaded() bool { return runtime.language.Truthy() }
+
+// latin1 decodes bytes one to one into code units; see the comment above.
+var latin1 = js.Global().Get("TextDecoder").New("latin1")
+
+func jsString(b []byte) js.Value {
+	arr := js.Global().Get("Uint8Array").New(len(b))
+	js.CopyBytesToJS(arr, b)
+	return latin1.Call("decode", arr)
+}
+
+// Point is a zero-based row and byte column.
+type Point struct {
+	Row    uint
+	Column uint
+}
+
+func pointFrom(v js.Value) Point {
+	return Point{Row: uint(v.Get("row").Int()), Column: uint(v.Get("column").Int())}
+}
+
+func (p Point) js() js.Value {
+	return js.ValueOf(map[string]any{"row": int(p.Row), "column": int(p.Column)})
+}
+
+// Range is a span of source.
+type Range struct {
+	StartByte  uint
+	EndByte    uint
+	StartPoint Point
+	EndPoint   Point
+}
+
+func rangeFrom(v js.Value) Range {
+	return Range{
+		StartByte:  uint(v.Get("startIndex").Int()),
+		EndByte:    uint(v.Get("endIndex").Int()),
+		StartPoint: pointFrom(v.Get("startPosition")),
+		EndPoint:   pointFrom(v.Get("endPosition")),
+	}
+}
+
+func (r Range) js() js.Value {
+	return js.ValueOf(map[string]any{
+		"startIndex":    int(r.StartByte),
+		"endIndex":      int(r.EndByte),
+		"startPosition": r.StartPoint.js(),
+		"endPosition":   r.EndPoint.js(),
+	})
+}
+
+// InputEdit describes an edit to source, for Tree.Edit.
+type InputEdit struct {
+	StartByte      uint
+	OldEndByte     uint
+	NewEndByte     uint
+	StartPosition  Point
+	OldEndPosition Point
+	NewEndPosition Point
+}
+
+// Language is the wabznasm language loaded by web-tree-sitter.
+type Language struct{ v js.Value }
+
+// NewLanguage returns the language registered with Load. The pointer that
+// identifies a language natively has no meaning here and is ignored.
+func NewLanguage(unsafe.Pointer) *Language {
+	if !Loaded() {
+		return nil
+	}
+	return &Language{runtime.language}
+}
+
+func (l *Language) AbiVersion() uint32 {
+	if v := l.v.Get("abiVersion"); v.Type() == js.TypeNumber {
+		return uint32(v.Int())
+	}
+	return uint32(l.v.Get("version").Int())
+}
+
+// LanguageMetadata is the version of a grammar.
+type LanguageMetadata struct {
+	MajorVersion uint8
+	MinorVersion uint8
+	PatchVersion uint8
+}
+
+func (l *Language) Metadata() *LanguageMetadata {
+	v := l.v.Get("metadata")
+	if v.IsNull() || v.IsUndefined() {
+		return nil
+	}
+	return &LanguageMetadata{
+		MajorVersion: uint8(v.Get("major_version").Int()),
+		MinorVersion: uint8(v.Get("minor_version").Int()),
+		PatchVersion: uint8(v.Get("patch_version").Int()),
+	}
+}
+
+func (l *Language) NodeKindCount() uint32   { return uint32(l.v.Get("nodeTypeCount").Int()) }
+func (l *Language) ParseStateCount() uint32 { return uint32(l.v.Get("stateCount").Int()) }
+func (l *Language) FieldCount() uint32      { return uint32(l.v.Get("fieldCount").Int()) }
+
+func (l *Language) FieldNameForId(id uint16) string {
+	v := l.v.Call("fieldNameForId", int(id))
+	if v.IsNull() || v.IsUndefined() {
+		return ""
+	}
+	return v.String()
+}
+
+func (l *Language) NodeKindForId(id uint16) string {
+	v := l.v.Call("nodeTypeForId", int(id))
+	if v.IsNull() || v.IsUndefined() {
+		return ""
+	}
+	return v.String()
+}
+
+func (l *Language) IdForNodeKind(kind string, named bool) uint16 {
+	return uint16(l.v.Call("idForNodeType", kind, named).Int())
+}
+
+func (l *Language) NodeKindIsNamed(id uint16) bool {
+	return l.v.Call("nodeTypeIsNamed", int(id)).Bool()
+}
+
+func (l *Language) NodeKindIsVisible(id uint16) bool {
+	return l.v.Call("nodeTypeIsVisible", int(id)).Bool()
+}
+
+func (l *Language) NextState(state, id uint16) uint16 {
+	return uint16(l.v.Call("nextState", int(state), int(id)).Int())
+}
+
+// LookaheadIterator lists the symbols valid in a parse state.
+type LookaheadIterator struct{ v js.Value }
+
+func (l *Language) LookaheadIterator(state uint16) *LookaheadIterator {
+	v := l.v.Call("lookaheadIterator", int(state))
+	if v.IsNull() || v.IsUndefined() {
+		return nil
+	}
+	return &LookaheadIterator{v}
+}
+
+func (l *LookaheadIterator) Close() { l.v.Call("delete") }
+
+// Iter returns the symbols of the state. The JavaScript iterator yields
+// names; the id of each is read from the iterator as it advances.
+func (l *LookaheadIterator) Iter() []uint16 {
+	key := js.Global().Get("Symbol").Get("iterator")
+	it := js.Global().Get("Reflect").Call("get", l.v, key).Call("call", l.v)
+	var out []uint16
+	for !it.Call("next").Get("done").Bool() {
+		out = append(out, uint16(l.v.Get("currentTypeId").Int()))
+	}
+	return out
+}
+
+// ParseState is the progress of a parse, passed to a progress callback.
+type ParseState struct {
+	CurrentByteOffset uint32
+	HasError          bool
+}
+
+// ParseOptions are options of Parser.ParseWithOptions.
+type ParseOptions struct {
+	// ProgressCallback is called periodically during a parse; returning
+	// true cancels it.
+	ProgressCallback func(ParseState) bool
+}
+
+// Parser is a web-tree-sitter parser.
+type Parser struct {
+	v      js.Value
+	ranges []Range
+}
+
+// NewParser returns a parser, or nil before Load.
+func NewParser() *Parser {
+	if !Loaded() {
+		return nil
+	}
+	return &Parser{v: runtime.parser.New()}
+}
+
+func (p *Parser) SetLanguage(l *Language) error {
+	if l == nil {
+		return ErrNotLoaded
+	}
+	p.v.Call("setLanguage", l.v)
+	return nil
+}
+
+func (p *Parser) Close() { p.v.Call("delete") }
+
+func (p *Parser) Reset() { p.v.Call("reset") }
+
+// SetIncludedRanges restricts later parses to ranges.
+func (p *Parser) SetIncludedRanges(ranges []Range) error {
+	p.ranges = append([]Range(nil), ranges...)
+	return nil
+}
+
+func (p *Parser) Parse(text []byte, oldTree *Tree) *Tree {
+	return p.parse(jsString(text), oldTree, nil)
+}
+
+// ParseWithOptions parses the source returned by callback, which is asked
+// for the bytes at each offset the parser reads until it returns none.
+func (p *Parser) ParseWithOptions(callback func(int, Point) []byte, oldTree *Tree, options *ParseOptions) *Tree {
+	read := js.FuncOf(func(_ js.Value, args []js.Value) any {
+		chunk := callback(args[0].Int(), pointFrom(args[1]))
+		if len(chunk) == 0 {
+			return js.Undefined()
+		}
+		return jsString(chunk)
+	})
+	defer read.Release()
+	return p.parse(read.Value, oldTree, options)
+}
+
+// parse parses input, a string or a read callback.
+func (p *Parser) parse(input js.Value, oldTree *Tree, options *ParseOptions) *Tree {
+	opts := map[string]any{}
+	if len(p.ranges) > 0 {
+		ranges := make([]any, len(p.ranges))
+		for i, r := range p.ranges {
+			ranges[i] = r.js()
+		}
+		opts["includedRanges"] = ranges
+	}
+	if options != nil && options.ProgressCallback != nil {
+		cb := js.FuncOf(func(_ js.Value, args []js.Value) any {
+			return options.ProgressCallback(ParseState{
+				CurrentByteOffset: uint32(args[0].Get("currentOffset").Int()),
+				HasError:          args[0].Get("hasError").Bool(),
+			})
+		})
+		defer cb.Release()
+		opts["progressCallback"] = cb
+	}
+	old := js.Null()
+	if oldTree != nil {
+		old = oldTree.v
+	}
+	v := p.v.Call("parse", input, old, opts)
+	if v.IsNull() || v.IsUndefined() {
+		return nil
+	}
+	return &Tree{v}
+}
+
+// Tree is a syntax tree.
+type Tree struct{ v js.Value }
+
+func (t *Tree) RootNode() *Node { return nodeFrom(t.v.Get("rootNode")) }
+
+func (t *Tree) Language() *Language { return &Language{t.v.Get("language")} }
+
+func (t *Tree) Edit(edit *InputEdit) {
+	t.v.Call("edit", map[string]any{
+		"startIndex":     int(edit.StartByte),
+		"oldEndIndex":    int(edit.OldEndByte),
+		"newEndIndex":    int(edit.NewEndByte),
+		"startPosition":  edit.StartPosition.js(),
+		"oldEndPosition": edit.OldEndPosition.js(),
+		"newEndPosition": edit.NewEndPosition.js(),
+	})
+}
+
+func (t *Tree) ChangedRanges(other *Tree) []Range {
+	v := t.v.Call("getChangedRanges", other.v)
+	out := make([]Range, v.Length())
+	for i := range out {
+		out[i] = rangeFrom(v.Index(i))
+	}
+	return out
+}
+
+func (t *Tree) Clone() *Tree { return &Tree{t.v.Call("copy")} }
+
+func (t *Tree) Close() { t.v.Call("delete") }
+
+// Node is a node of a syntax tree.
+type Node struct{ v js.Value }
+
+func nodeFrom(v js.Value) *Node {
+	if v.IsNull() || v.IsUndefined() {
+		return nil
+	}
+	return &Node{v}
+}
+
+func (n *Node) Id() uintptr          { return uintptr(n.v.Get("id").Int()) }
+func (n *Node) Kind() string         { return n.v.Get("type").String() }
+func (n *Node) KindId() uint16       { return uint16(n.v.Get("typeId").Int()) }
+func (n *Node) GrammarId() uint16    { return uint16(n.v.Get("grammarId").Int()) }
+func (n *Node) IsNamed() bool        { return n.v.Get("isNamed").Bool() }
+func (n *Node) IsExtra() bool        { return n.v.Get("isExtra").Bool() }
+func (n *Node) IsError() bool        { return n.v.Get("isError").Bool() }
+func (n *Node) IsMissing() bool      { return n.v.Get("isMissing").Bool() }
+func (n *Node) HasError() bool       { return n.v.Get("hasError").Bool() }
+func (n *Node) ParseState() uint16   { return uint16(n.v.Get("parseState").Int()) }
+func (n *Node) StartByte() uint      { return uint(n.v.Get("startIndex").Int()) }
+func (n *Node) EndByte() uint        { return uint(n.v.Get("endIndex").Int()) }
+func (n *Node) StartPosition() Point { return pointFrom(n.v.Get("startPosition")) }
+func (n *Node) EndPosition() Point   { return pointFrom(n.v.Get("endPosition")) }
+func (n *Node) ChildCount() uint     { return uint(n.v.Get("childCount").Int()) }
+func (n *Node) Child(i uint) *Node   { return nodeFrom(n.v.Call("child", int(i))) }
+func (n *Node) Parent() *Node        { return nodeFrom(n.v.Get("parent")) }
+func (n *Node) ToSexp() string       { return n.v.Call("toString").String() }
+
+func (n *Node) Range() Range {
+	return Range{StartByte: n.StartByte(), EndByte: n.EndByte(), StartPoint: n.StartPosition(), EndPoint: n.EndPosition()}
+}
+
+func (n *Node) FieldNameForChild(i uint32) string {
+	v := n.v.Call("fieldNameForChild", int(i))
+	if v.IsNull() || v.IsUndefined() {
+		return ""
+	}
+	return v.String()
+}
+
+func (n *Node) Utf8Text(source []byte) string {
+	return string(source[n.StartByte():n.EndByte()])
+}
+
+// QueryError is an error compiling a query.
+type QueryError struct {
+	Message string
+	Offset  uint
+}
+
+func (e QueryError) Error() string { return e.Message }
+
+// Query is a compiled query.
+type Query struct {
+	v     js.Value
+	names []string
+}
+
+// NewQuery compiles source.
+func NewQuery(language *Language, source string) (q *Query, qerr *QueryError) {
+	if language == nil {
+		return nil, &QueryError{Message: ErrNotLoaded.Error()}
+	}
+	defer func() {
+		// web-tree-sitter throws on invalid queries.
+		if r := recover(); r != nil {
+			q, qerr = nil, &QueryError{Message: fmt.Sprint(r)}
+			if err, ok := r.(js.Error); ok {
+				qerr.Message = err.Get("message").String()
+				if i := err.Get("index"); i.Type() == js.TypeNumber {
+					qerr.Offset = uint(i.Int())
+				}
+			}
+		}
+	}()
+	v := runtime.query.New(language.v, source)
+	names := v.Get("captureNames")
+	q = &Query{v: v, names: make([]string, names.Length())}
+	for i := range q.names {
+		q.names[i] = names.Index(i).String()
+	}
+	return q, nil
+}
+
+func (q *Query) CaptureNames() []string { return q.names }
+
+func (q *Query) Close() { q.v.Call("delete") }
+
+// QueryCursor runs queries.
+type QueryCursor struct{}
+
+func NewQueryCursor() *QueryCursor { return &QueryCursor{} }
+
+func (qc *QueryCursor) Close() {}
+
+// QueryCapture is a node captured by a query.
+type QueryCapture struct {
+	Node  Node
+	Index uint32
+}
+
+// QueryMatch is a match of a pattern. Matches returned by
+// QueryCaptures.Next hold the one capture they report.
+type QueryMatch struct {
+	Captures     []QueryCapture
+	PatternIndex uint
+}
+
+// QueryCaptures is the sequence of captures of a query, in order.
+type QueryCaptures struct {
+	query *Query
+	v     js.Value
+	next  int
+}
+
+// Captures runs query over node. text is unused: web-tree-sitter checks
+// predicates against the source it parsed.
+func (qc *QueryCursor) Captures(query *Query, node *Node, text []byte) QueryCaptures {
+	return QueryCaptures{query: query, v: query.v.Call("captures", node.v)}
+}
+
+func (c *QueryCaptures) Next() (*QueryMatch, uint) {
+	if c.next >= c.v.Length() {
+		return nil, 0
+	}
+	v := c.v.Index(c.next)
+	c.next++
+	name := v.Get("name").String()
+	index := uint32(0)
+	for i, n := range c.query.names {
+		if n == name {
+			index = uint32(i)
+			break
+		}
+	}
+	return &QueryMatch{
+		Captures:     []QueryCapture{{Node: Node{v.Get("node")}, Index: index}},
+		PatternIndex: uint(v.Get("patternIndex").Int()),
+	}, 0
+}
@@ -0,0 +1,70 @@
+package jupyter
+
+import (
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// MaxDisplayRows bounds the rows of a table shown in HTML; the rest are
+// summarized in a final row.
+const MaxDisplayRows = 100
+
+// Display returns the MIME bundle of v for an execute_result or
+// display_data message. Every value has a text/plain form, as the REPL
+// prints it. Lists also have an HTML table: a list of equally long lists
+// is shown as rows, any other list as a single column of items.
+func Display(v eval.Value) map[string]string {
+	data := map[string]string{"text/plain": v.String()}
+	if l, ok := v.(eval.List); ok && len(l) > 0 {
+		data["text/html"] = table(l)
+	}
+	return data
+}
+
+// rows returns the items of l as the rows of a table: the items
+// themselves if they are lists of one length, else one item per row.
+func rows(l eval.List) [][]eval.Value {
+	out := make([][]eval.Value, len(l))
+	width := -1
+	for i, item := range l {
+		row, ok := item.(eval.List)
+		if !ok || width >= 0 && len(row) != width || len(row) == 0 {
+			width = -2
+			break
+		}
+		width = len(row)
+		out[i] = row
+	}
+	if width < 0 {
+		for i, item := range l {
+			out[i] = []eval.Value{item}
+		}
+	}
+	return out
+}
+
+func table(l eval.List) string {
+	rs := rows(l)
+	var b strings.Builder
+	b.WriteString("<table>\n<thead><tr><th></th>")
+	for j := range rs[0] {
+		b.WriteString("<th>" + strconv.Itoa(j) + "</th>")
+	}
+	b.WriteString("</tr></thead>\n<tbody>\n")
+	for i, row := range rs {
+		if i == MaxDisplayRows {
+			b.WriteString("<tr><th>…</th><td colspan=\"" + strconv.Itoa(len(row)) + "\">" + strconv.Itoa(len(rs)-i) + " more rows</td></tr>\n")
+			break
+		}
+		b.WriteString("<tr><th>" + strconv.Itoa(i) + "</th>")
+		for _, cell := range row {
+			b.WriteString("<td>" + html.EscapeString(cell.String()) + "</td>")
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</tbody>\n</table>")
+	return b.String()
+}
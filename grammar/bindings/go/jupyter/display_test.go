@@ -0,0 +1,49 @@
+package jupyter_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/jupyter"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func TestDisplay(t *testing.T) {
+	if d := jupyter.Display(eval.Long(42)); d["text/plain"] != "42" || d["text/html"] != "" {
+		t.Errorf("Display(42) = %v", d)
+	}
+
+	table := eval.List{eval.List{eval.Long(1), eval.Symbol("a")}, eval.List{eval.Long(2), eval.Symbol("b")}}
+	html := jupyter.Display(table)["text/html"]
+	if n := strings.Count(html, "<tr>"); n != 3 {
+		t.Errorf("table has %d rows, want a header and two rows:\n%s", n, html)
+	}
+	if !strings.Contains(html, "<td>1</td><td>`a</td>") {
+		t.Errorf("table lacks its first row:\n%s", html)
+	}
+
+	// Rows of different lengths are shown one item per row.
+	ragged := eval.List{eval.List{eval.Long(1)}, eval.List{eval.Long(2), eval.Long(3)}, eval.Symbol("<")}
+	html = jupyter.Display(ragged)["text/html"]
+	if n := strings.Count(html, "<td>"); n != 3 {
+		t.Errorf("ragged list has %d cells, want 3:\n%s", n, html)
+	}
+	if !strings.Contains(html, "&lt;") {
+		t.Errorf("cells are not escaped:\n%s", html)
+	}
+
+	long := make(eval.List, jupyter.MaxDisplayRows+5)
+	for i := range long {
+		long[i] = eval.Long(i)
+	}
+	html = jupyter.Display(long)["text/html"]
+	if !strings.Contains(html, "5 more rows") || strings.Contains(html, ">100</td>") {
+		t.Errorf("long list is not truncated:\n%s", html)
+	}
+}
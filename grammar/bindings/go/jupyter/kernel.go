@@ -0,0 +1,479 @@
+// Package jupyter implements a Jupyter kernel for wabznasm, so that
+// notebooks and consoles can evaluate wabznasm cells.
+//
+// The kernel speaks version 5.3 of the Jupyter messaging protocol over its
+// own implementation of ZeroMQ's wire protocol. A cell holds one or more
+// statements, one per line unless brackets continue a line, evaluated in
+// order against globals that persist across cells; the value of the last
+// statement is the cell's result. Lists are displayed as HTML tables as
+// well as text. The parser answers completeness checks, so consoles know
+// when a cell needs more lines, and package complete proposes completions.
+package jupyter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/complete"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/position"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+)
+
+// Version is the kernel's implementation and language version.
+const Version = "0.1.0"
+
+// Kernel is a running wabznasm kernel.
+type Kernel struct {
+	info    ConnectionInfo
+	signer  *Signer
+	session string
+
+	shell, control, stdin, iopub, hb *socket
+
+	// evalMu guards the interpreter, which requests on the shell and
+	// control sockets share.
+	evalMu sync.Mutex
+	interp *eval.Interpreter
+	count  int
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	stop   chan struct{}
+	once   sync.Once
+}
+
+// NewKernel binds the sockets described by info. A port of zero binds a
+// free port; Connection reports the ports bound.
+func NewKernel(info ConnectionInfo) (*Kernel, error) {
+	if info.Transport != "" && info.Transport != "tcp" {
+		return nil, fmt.Errorf("jupyter: unsupported transport %q", info.Transport)
+	}
+	signer, err := NewSigner(info)
+	if err != nil {
+		return nil, err
+	}
+	k := &Kernel{info: info, signer: signer, session: newID(), interp: eval.New(), stop: make(chan struct{})}
+	for _, s := range []struct {
+		sock **socket
+		kind string
+		port *int
+	}{
+		{&k.shell, "ROUTER", &k.info.ShellPort},
+		{&k.control, "ROUTER", &k.info.ControlPort},
+		{&k.stdin, "ROUTER", &k.info.StdinPort},
+		{&k.iopub, "PUB", &k.info.IOPubPort},
+		{&k.hb, "REP", &k.info.HBPort},
+	} {
+		sock, err := listen(s.kind, net.JoinHostPort(info.IP, strconv.Itoa(*s.port)))
+		if err != nil {
+			k.Close()
+			return nil, err
+		}
+		*s.sock = sock
+		*s.port = sock.port()
+	}
+	k.info.Transport = "tcp"
+	return k, nil
+}
+
+// Connection returns the connection information of the kernel, with the
+// ports it bound.
+func (k *Kernel) Connection() ConnectionInfo { return k.info }
+
+// Run serves requests until a shutdown request or the end of ctx.
+func (k *Kernel) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	k.publish(nil, "status", map[string]string{"execution_state": "starting"})
+	errs := make(chan error, 2)
+	for _, sock := range []*socket{k.shell, k.control} {
+		go func() { errs <- k.serve(ctx, sock) }()
+	}
+	select {
+	case <-k.stop:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errs:
+		return err
+	}
+}
+
+// Close unbinds the kernel's sockets.
+func (k *Kernel) Close() error {
+	k.shutdown()
+	var errs []error
+	for _, s := range []*socket{k.shell, k.control, k.stdin, k.iopub, k.hb} {
+		if s != nil {
+			errs = append(errs, s.close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (k *Kernel) shutdown() {
+	k.once.Do(func() { close(k.stop) })
+	k.interrupt()
+}
+
+func (k *Kernel) interrupt() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.cancel != nil {
+		k.cancel()
+	}
+}
+
+func (k *Kernel) serve(ctx context.Context, sock *socket) error {
+	for {
+		frames, err := sock.recv(ctx)
+		if err != nil {
+			select {
+			case <-k.stop:
+				return nil
+			default:
+			}
+			return err
+		}
+		// Messages that fail to decode or verify are dropped, as the
+		// sender cannot be trusted with a reply.
+		if msg, err := k.signer.Decode(frames); err == nil {
+			k.handle(sock, msg)
+		}
+	}
+}
+
+func (k *Kernel) handle(sock *socket, req *Message) {
+	k.publish(req, "status", map[string]string{"execution_state": "busy"})
+	defer k.publish(req, "status", map[string]string{"execution_state": "idle"})
+	var content map[string]any
+	switch req.Header.MsgType {
+	case "kernel_info_request":
+		content = map[string]any{
+			"status":                 "ok",
+			"protocol_version":       ProtocolVersion,
+			"implementation":         "wabznasm",
+			"implementation_version": Version,
+			"language_info": map[string]any{
+				"name":           "wabznasm",
+				"version":        Version,
+				"mimetype":       "text/x-wabznasm",
+				"file_extension": ".wabznasm",
+			},
+			"banner":     "wabznasm " + Version,
+			"help_links": []any{},
+		}
+	case "execute_request":
+		content = k.execute(req)
+	case "is_complete_request":
+		var r struct {
+			Code string `json:"code"`
+		}
+		json.Unmarshal(req.Content, &r)
+		content = map[string]any{"status": IsComplete(r.Code)}
+		if content["status"] == "incomplete" {
+			content["indent"] = ""
+		}
+	case "complete_request":
+		content = k.complete(req)
+	case "inspect_request":
+		content = k.inspect(req)
+	case "comm_info_request":
+		content = map[string]any{"status": "ok", "comms": map[string]any{}}
+	case "history_request":
+		content = map[string]any{"status": "ok", "history": []any{}}
+	case "interrupt_request":
+		k.interrupt()
+		content = map[string]any{"status": "ok"}
+	case "shutdown_request":
+		var r struct {
+			Restart bool `json:"restart"`
+		}
+		json.Unmarshal(req.Content, &r)
+		content = map[string]any{"status": "ok", "restart": r.Restart}
+		defer k.shutdown()
+	default:
+		return
+	}
+	k.reply(sock, req, strings.TrimSuffix(req.Header.MsgType, "_request")+"_reply", content)
+}
+
+func (k *Kernel) message(parent *Message, msgType string, content any) *Message {
+	m := &Message{Header: Header{
+		MsgID:    newID(),
+		Session:  k.session,
+		Username: "kernel",
+		Date:     now(),
+		MsgType:  msgType,
+		Version:  ProtocolVersion,
+	}}
+	if parent != nil {
+		m.Parent = &parent.Header
+	}
+	m.Content, _ = json.Marshal(content)
+	return m
+}
+
+func (k *Kernel) reply(sock *socket, req *Message, msgType string, content any) {
+	m := k.message(req, msgType, content)
+	m.Identities = req.Identities
+	if frames, err := k.signer.Encode(m); err == nil {
+		sock.send(frames)
+	}
+}
+
+// publish broadcasts a message on the IOPub socket, with the message type
+// as its topic.
+func (k *Kernel) publish(parent *Message, msgType string, content any) {
+	m := k.message(parent, msgType, content)
+	m.Identities = [][]byte{[]byte("kernel." + k.session + "." + msgType)}
+	if frames, err := k.signer.Encode(m); err == nil {
+		k.iopub.send(frames)
+	}
+}
+
+func (k *Kernel) execute(req *Message) map[string]any {
+	var r struct {
+		Code   string `json:"code"`
+		Silent bool   `json:"silent"`
+	}
+	json.Unmarshal(req.Content, &r)
+
+	k.evalMu.Lock()
+	defer k.evalMu.Unlock()
+	if !r.Silent {
+		k.count++
+	}
+	k.publish(req, "execute_input", map[string]any{"code": r.Code, "execution_count": k.count})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	k.mu.Lock()
+	k.cancel = cancel
+	k.mu.Unlock()
+	defer func() {
+		k.mu.Lock()
+		k.cancel = nil
+		k.mu.Unlock()
+		cancel()
+	}()
+
+	v, err := k.run(ctx, r.Code)
+	if err != nil {
+		content := errorContent(err)
+		k.publish(req, "error", content)
+		content["status"] = "error"
+		content["execution_count"] = k.count
+		return content
+	}
+	if v != nil && !r.Silent {
+		k.publish(req, "execute_result", map[string]any{
+			"execution_count": k.count,
+			"data":            Display(v),
+			"metadata":        map[string]any{},
+		})
+	}
+	return map[string]any{"status": "ok", "execution_count": k.count, "user_expressions": map[string]any{}, "payload": []any{}}
+}
+
+// cellError is an error raised by one entry of a cell.
+type cellError struct {
+	entry string
+	err   error
+}
+
+func (e *cellError) Error() string { return e.err.Error() }
+func (e *cellError) Unwrap() error { return e.err }
+
+// run evaluates the entries of a cell, as split by repl.Split, and
+// returns the value of the last.
+func (k *Kernel) run(ctx context.Context, code string) (eval.Value, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	var last eval.Value
+	for _, e := range repl.Split(code) {
+		tree, err := parser.ParseString(e.Text)
+		if err != nil {
+			return nil, &cellError{e.Text, err}
+		}
+		v, err := k.interp.EvalTreeContext(ctx, tree.Tree, []byte(e.Text))
+		tree.Close()
+		if err != nil {
+			return nil, &cellError{e.Text, err}
+		}
+		last = v
+	}
+	return last, nil
+}
+
+// errorContent describes err as the content of an error message, the
+// traceback pointing at the offending source as the REPL does.
+func errorContent(err error) map[string]any {
+	ename, span := "Error", ast.Span{}
+	var e *eval.Error
+	var le *eval.LimitExceededError
+	switch {
+	case errors.As(err, &le) && le.Limit == eval.LimitDeadline:
+		ename = "Interrupted"
+	case errors.As(err, &le):
+		ename, span = "LimitExceeded", le.Span
+	case errors.As(err, &e):
+		ename, span = e.Code, e.Span
+	}
+	traceback := []string{ename + ": " + err.Error()}
+	var ce *cellError
+	if errors.As(err, &ce) && span != (ast.Span{}) {
+		lines := strings.Split(ce.entry, "\n")
+		if row := int(span.Start.Row); row < len(lines) {
+			line := lines[row]
+			col := min(int(span.Start.Column), len(line))
+			width := 1
+			if span.End.Row == span.Start.Row && span.End.Column > span.Start.Column {
+				width = int(span.End.Column - span.Start.Column)
+			}
+			traceback = append(traceback, "  "+line, "  "+strings.Repeat(" ", col)+strings.Repeat("^", width))
+		}
+	}
+	return map[string]any{"ename": ename, "evalue": err.Error(), "traceback": traceback}
+}
+
+// IsComplete reports whether a cell is ready to run, for consoles deciding
+// whether Enter runs a cell or starts a new line. It returns "complete",
+// "incomplete" if the last statement leaves brackets open or ends where
+// more input is expected, or "invalid" if the cell has a syntax error
+// that more input cannot fix.
+func IsComplete(code string) string {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return "unknown"
+	}
+	defer parser.Close()
+	es := repl.Split(code)
+	for i, e := range es {
+		if repl.NeedsContinuation(e.Text) {
+			return "incomplete"
+		}
+		tree, err := parser.ParseString(e.Text)
+		if err != nil {
+			return "unknown"
+		}
+		diags := tree_sitter_wabznasm.Diagnostics(tree.Tree, []byte(e.Text))
+		tree.Close()
+		if len(diags) == 0 {
+			continue
+		}
+		if i < len(es)-1 {
+			return "invalid"
+		}
+		// More input can supply a missing token or finish a statement cut
+		// short, but not take back an unexpected token, which is reported
+		// with what was expected instead.
+		end := uint(len(strings.TrimRight(e.Text, " \t")))
+		for _, d := range diags {
+			if d.Code != tree_sitter_wabznasm.CodeMissing && (d.Range.EndByte < end || len(d.Expected) > 0) {
+				return "invalid"
+			}
+		}
+		return "incomplete"
+	}
+	return "complete"
+}
+
+// globals lists the names an interpreter can see, for completion.
+type globals struct{ env *eval.Env }
+
+func (g globals) Globals() []string {
+	var names []string
+	for env := g.env; env != nil; env = env.Parent() {
+		names = append(names, env.Names()...)
+	}
+	return names
+}
+
+func (k *Kernel) complete(req *Message) map[string]any {
+	var r struct {
+		Code      string `json:"code"`
+		CursorPos int    `json:"cursor_pos"`
+	}
+	json.Unmarshal(req.Content, &r)
+	cursor := byteOffset(r.Code, r.CursorPos)
+	out := map[string]any{"status": "ok", "matches": []string{}, "cursor_start": r.CursorPos, "cursor_end": r.CursorPos, "metadata": map[string]any{}}
+
+	e := repl.Entry{Text: r.Code}
+	for _, c := range repl.Split(r.Code) {
+		if c.Offset <= cursor && cursor <= c.Offset+len(c.Text) {
+			e = c
+		}
+	}
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return out
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(e.Text)
+	if err != nil {
+		return out
+	}
+	defer tree.Close()
+	k.evalMu.Lock()
+	res := complete.Complete(tree.Tree, []byte(e.Text), uint(cursor-e.Offset), complete.Options{Globals: globals{k.interp.Globals}})
+	k.evalMu.Unlock()
+	matches := []string{}
+	for _, item := range res.Items {
+		if item.Kind != complete.Operator && item.Kind != complete.Snippet {
+			matches = append(matches, item.Label)
+		}
+	}
+	out["matches"] = matches
+	out["cursor_start"] = int(position.Units([]byte(r.Code[:e.Offset+int(res.Start)]), position.UTF32))
+	return out
+}
+
+func (k *Kernel) inspect(req *Message) map[string]any {
+	var r struct {
+		Code      string `json:"code"`
+		CursorPos int    `json:"cursor_pos"`
+	}
+	json.Unmarshal(req.Content, &r)
+	cursor := byteOffset(r.Code, r.CursorPos)
+	isName := func(c byte) bool {
+		return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+	}
+	start, end := cursor, cursor
+	for start > 0 && isName(r.Code[start-1]) {
+		start--
+	}
+	for end < len(r.Code) && isName(r.Code[end]) {
+		end++
+	}
+	out := map[string]any{"status": "ok", "found": false, "data": map[string]any{}, "metadata": map[string]any{}}
+	name := r.Code[start:end]
+	if name == "" {
+		return out
+	}
+	k.evalMu.Lock()
+	v, ok := k.interp.Globals.Lookup(name)
+	k.evalMu.Unlock()
+	if ok {
+		out["found"] = true
+		out["data"] = map[string]string{"text/plain": name + ": " + v.String()}
+	}
+	return out
+}
+
+// byteOffset converts a cursor position in code points, as the protocol
+// counts them, to a byte offset in code.
+func byteOffset(code string, pos int) int {
+	return int(position.ByteOffset([]byte(code), uint(max(pos, 0)), position.UTF32))
+}
@@ -0,0 +1,256 @@
+package jupyter_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/jupyter"
+)
+
+func TestIsComplete(t *testing.T) {
+	for _, tt := range []struct{ code, want string }{
+		{"1+2", "complete"},
+		{"x: 1\nx*2", "complete"},
+		{"\\ a comment\n", "complete"},
+		{"f: {[x]", "incomplete"},
+		{"f: {[x]\n  x*2", "incomplete"},
+		{"1+", "incomplete"},
+		{"1 + )", "invalid"},
+		{"1 + )\n2", "invalid"},
+	} {
+		if got := jupyter.IsComplete(tt.code); got != tt.want {
+			t.Errorf("IsComplete(%q) = %s, want %s", tt.code, got, tt.want)
+		}
+	}
+}
+
+// client drives a kernel as a notebook would.
+type client struct {
+	t      *testing.T
+	signer *jupyter.Signer
+	shell  *jupyter.Conn
+	iopub  chan *jupyter.Message
+}
+
+func startKernel(t *testing.T) (*client, chan error) {
+	t.Helper()
+	info := jupyter.ConnectionInfo{IP: "127.0.0.1", Key: "secret", SignatureScheme: "hmac-sha256"}
+	k, err := jupyter.NewKernel(info)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { k.Close() })
+	info = k.Connection()
+	done := make(chan error, 1)
+	go func() { done <- k.Run(context.Background()) }()
+
+	dial := func(port int, kind string) *jupyter.Conn {
+		c, err := jupyter.Dial(net.JoinHostPort(info.IP, strconv.Itoa(port)), kind)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+	signer, _ := jupyter.NewSigner(info)
+	c := &client{t: t, signer: signer, shell: dial(info.ShellPort, "DEALER"), iopub: make(chan *jupyter.Message, 64)}
+	sub := dial(info.IOPubPort, "SUB")
+	go func() {
+		for {
+			frames, err := sub.ReadMessage()
+			if err != nil {
+				close(c.iopub)
+				return
+			}
+			if m, err := signer.Decode(frames); err == nil {
+				c.iopub <- m
+			}
+		}
+	}()
+
+	hb := dial(info.HBPort, "REQ")
+	if err := hb.WriteMessage([][]byte{[]byte("ping")}); err != nil {
+		t.Fatal(err)
+	}
+	if msg, err := hb.ReadMessage(); err != nil || string(msg[len(msg)-1]) != "ping" {
+		t.Fatalf("heartbeat = %q, %v", msg, err)
+	}
+
+	// IOPub subscriptions take effect asynchronously, so ask for kernel
+	// info until its status messages arrive, as Jupyter clients do.
+	for {
+		c.send("kernel_info_request", map[string]any{})
+		c.reply()
+		select {
+		case <-c.iopub:
+		case <-time.After(100 * time.Millisecond):
+			continue
+		}
+		break
+	}
+	c.drain()
+	return c, done
+}
+
+// drain discards IOPub messages until the kernel has been idle briefly.
+func (c *client) drain() {
+	for {
+		select {
+		case <-c.iopub:
+		case <-time.After(100 * time.Millisecond):
+			return
+		}
+	}
+}
+
+func (c *client) send(msgType string, content any) string {
+	c.t.Helper()
+	m := &jupyter.Message{Header: jupyter.Header{MsgID: strconv.FormatInt(time.Now().UnixNano(), 10), MsgType: msgType, Version: jupyter.ProtocolVersion}}
+	m.Content, _ = json.Marshal(content)
+	frames, err := c.signer.Encode(m)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.shell.WriteMessage(frames); err != nil {
+		c.t.Fatal(err)
+	}
+	return m.Header.MsgID
+}
+
+func (c *client) reply() (*jupyter.Message, map[string]any) {
+	c.t.Helper()
+	frames, err := c.shell.ReadMessage()
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	m, err := c.signer.Decode(frames)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	var content map[string]any
+	if err := json.Unmarshal(m.Content, &content); err != nil {
+		c.t.Fatal(err)
+	}
+	return m, content
+}
+
+// request sends a request and returns its reply and the messages published
+// while the kernel was busy with it.
+func (c *client) request(msgType string, content any) (map[string]any, []*jupyter.Message) {
+	c.t.Helper()
+	id := c.send(msgType, content)
+	m, reply := c.reply()
+	if m.Parent == nil || m.Parent.MsgID != id {
+		c.t.Fatalf("reply to %s has parent %+v", msgType, m.Parent)
+	}
+	var published []*jupyter.Message
+	for {
+		select {
+		case p := <-c.iopub:
+			if p.Parent == nil || p.Parent.MsgID != id {
+				continue
+			}
+			published = append(published, p)
+			if p.Header.MsgType == "status" && string(p.Content) == `{"execution_state":"idle"}` {
+				return reply, published
+			}
+		case <-time.After(5 * time.Second):
+			c.t.Fatalf("%s: kernel never became idle", msgType)
+		}
+	}
+}
+
+func find(ms []*jupyter.Message, msgType string) map[string]any {
+	for _, m := range ms {
+		if m.Header.MsgType == msgType {
+			var content map[string]any
+			json.Unmarshal(m.Content, &content)
+			return content
+		}
+	}
+	return nil
+}
+
+func TestKernel(t *testing.T) {
+	if err := eval.RegisterBuiltin("pairs", func(n int64) [][]int64 {
+		out := make([][]int64, n)
+		for i := range out {
+			out[i] = []int64{int64(i), int64(i * i)}
+		}
+		return out
+	}); err != nil {
+		t.Fatal(err)
+	}
+	c, done := startKernel(t)
+
+	reply, _ := c.request("kernel_info_request", map[string]any{})
+	if info, _ := reply["language_info"].(map[string]any); info["name"] != "wabznasm" || reply["protocol_version"] != jupyter.ProtocolVersion {
+		t.Errorf("kernel_info_reply = %v", reply)
+	}
+
+	reply, published := c.request("execute_request", map[string]any{"code": "square: {[x]\n  x*x}\n\\ the result\nsquare[4]+1"})
+	if reply["status"] != "ok" || reply["execution_count"] != 1.0 {
+		t.Errorf("execute_reply = %v", reply)
+	}
+	if input := find(published, "execute_input"); input == nil || input["execution_count"] != 1.0 {
+		t.Errorf("execute_input = %v", input)
+	}
+	result := find(published, "execute_result")
+	if data, _ := result["data"].(map[string]any); data["text/plain"] != "17" {
+		t.Errorf("execute_result = %v", result)
+	}
+
+	_, published = c.request("execute_request", map[string]any{"code": "pairs[3]"})
+	data, _ := find(published, "execute_result")["data"].(map[string]any)
+	if html, _ := data["text/html"].(string); html == "" {
+		t.Errorf("list result has no table: %v", data)
+	}
+
+	reply, published = c.request("execute_request", map[string]any{"code": "square[1]\nsquare[1]/0"})
+	errContent := find(published, "error")
+	if reply["status"] != "error" || reply["ename"] != eval.CodeDivisionByZero || errContent == nil {
+		t.Errorf("execute_reply = %v, error = %v", reply, errContent)
+	}
+	if tb, _ := errContent["traceback"].([]any); len(tb) != 3 || tb[1] != "  square[1]/0" || tb[2] != "  ^^^^^^^^^^^" {
+		t.Errorf("traceback = %q", tb)
+	}
+	if find(published, "execute_result") != nil {
+		t.Error("failed cell published a result")
+	}
+
+	reply, _ = c.request("is_complete_request", map[string]any{"code": "f: {[x] x+"})
+	if reply["status"] != "incomplete" {
+		t.Errorf("is_complete_reply = %v", reply)
+	}
+
+	// Positions count code points: é is two bytes.
+	code := "\\ é\nsq"
+	reply, _ = c.request("complete_request", map[string]any{"code": code, "cursor_pos": 6})
+	matches, _ := reply["matches"].([]any)
+	if len(matches) != 1 || matches[0] != "square" || reply["cursor_start"] != 4.0 || reply["cursor_end"] != 6.0 {
+		t.Errorf("complete_reply = %v", reply)
+	}
+
+	reply, _ = c.request("inspect_request", map[string]any{"code": "square[2]", "cursor_pos": 3})
+	if reply["found"] != true {
+		t.Errorf("inspect_reply = %v", reply)
+	}
+
+	reply, _ = c.request("shutdown_request", map[string]any{"restart": false})
+	if reply["status"] != "ok" {
+		t.Errorf("shutdown_reply = %v", reply)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Run = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("kernel did not shut down")
+	}
+}
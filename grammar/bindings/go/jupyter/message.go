@@ -0,0 +1,169 @@
+package jupyter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ProtocolVersion is the version of the Jupyter messaging protocol the
+// kernel implements.
+const ProtocolVersion = "5.3"
+
+// ConnectionInfo is the content of a Jupyter connection file, which the
+// notebook server writes and passes to the kernel.
+type ConnectionInfo struct {
+	Transport       string `json:"transport"`
+	IP              string `json:"ip"`
+	ShellPort       int    `json:"shell_port"`
+	ControlPort     int    `json:"control_port"`
+	StdinPort       int    `json:"stdin_port"`
+	IOPubPort       int    `json:"iopub_port"`
+	HBPort          int    `json:"hb_port"`
+	Key             string `json:"key"`
+	SignatureScheme string `json:"signature_scheme"`
+	KernelName      string `json:"kernel_name,omitempty"`
+}
+
+// ReadConnectionFile reads the connection file at path.
+func ReadConnectionFile(path string) (ConnectionInfo, error) {
+	var info ConnectionInfo
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, fmt.Errorf("%s: %w", path, err)
+	}
+	return info, nil
+}
+
+// Header identifies a message.
+type Header struct {
+	MsgID    string `json:"msg_id"`
+	Session  string `json:"session"`
+	Username string `json:"username"`
+	Date     string `json:"date"`
+	MsgType  string `json:"msg_type"`
+	Version  string `json:"version"`
+}
+
+// Message is a Jupyter message.
+type Message struct {
+	// Identities route a reply back to the peer that sent a request.
+	Identities [][]byte
+	Header     Header
+	// Parent is the header of the request a message answers, or nil.
+	Parent   *Header
+	Metadata map[string]any
+	Content  json.RawMessage
+	Buffers  [][]byte
+}
+
+// delimiter separates the identities of a message from its parts.
+const delimiter = "<IDS|MSG>"
+
+// ErrSignature is returned for a message whose signature does not match.
+var ErrSignature = errors.New("jupyter: invalid message signature")
+
+// Signer encodes messages to frames and back, signing them with the key
+// of a connection.
+type Signer struct {
+	key []byte
+}
+
+// NewSigner returns a signer for the key and scheme of info. Only the
+// hmac-sha256 scheme is supported; an empty key disables signing.
+func NewSigner(info ConnectionInfo) (*Signer, error) {
+	if info.Key != "" && info.SignatureScheme != "hmac-sha256" {
+		return nil, fmt.Errorf("jupyter: unsupported signature scheme %q", info.SignatureScheme)
+	}
+	return &Signer{key: []byte(info.Key)}, nil
+}
+
+func (s *Signer) sign(parts [][]byte) []byte {
+	if len(s.key) == 0 {
+		return nil
+	}
+	mac := hmac.New(sha256.New, s.key)
+	for _, p := range parts {
+		mac.Write(p)
+	}
+	return []byte(hex.EncodeToString(mac.Sum(nil)))
+}
+
+// Decode parses the frames of a message and checks its signature.
+func (s *Signer) Decode(frames [][]byte) (*Message, error) {
+	i := 0
+	for i < len(frames) && string(frames[i]) != delimiter {
+		i++
+	}
+	if len(frames)-i < 6 {
+		return nil, errors.New("jupyter: malformed message")
+	}
+	m := &Message{Identities: frames[:i]}
+	sig, parts := frames[i+1], frames[i+2:i+6]
+	if len(s.key) > 0 && !hmac.Equal(sig, s.sign(parts)) {
+		return nil, ErrSignature
+	}
+	if err := json.Unmarshal(parts[0], &m.Header); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(bytes.TrimSpace(parts[1]), []byte("{}")) {
+		m.Parent = new(Header)
+		if err := json.Unmarshal(parts[1], m.Parent); err != nil {
+			return nil, err
+		}
+	}
+	if err := json.Unmarshal(parts[2], &m.Metadata); err != nil {
+		return nil, err
+	}
+	m.Content = parts[3]
+	m.Buffers = frames[i+6:]
+	return m, nil
+}
+
+// Encode returns the signed frames of m.
+func (s *Signer) Encode(m *Message) ([][]byte, error) {
+	header, err := json.Marshal(m.Header)
+	if err != nil {
+		return nil, err
+	}
+	parent := []byte("{}")
+	if m.Parent != nil {
+		if parent, err = json.Marshal(m.Parent); err != nil {
+			return nil, err
+		}
+	}
+	metadata := []byte("{}")
+	if len(m.Metadata) > 0 {
+		if metadata, err = json.Marshal(m.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	content := []byte(m.Content)
+	if len(content) == 0 {
+		content = []byte("{}")
+	}
+	parts := [][]byte{header, parent, metadata, content}
+	frames := append([][]byte(nil), m.Identities...)
+	frames = append(frames, []byte(delimiter), s.sign(parts))
+	frames = append(frames, parts...)
+	return append(frames, m.Buffers...), nil
+}
+
+// newID returns a random message or session identifier.
+func newID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func now() string { return time.Now().UTC().Format("2006-01-02T15:04:05.000000Z") }
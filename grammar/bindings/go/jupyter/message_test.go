@@ -0,0 +1,49 @@
+package jupyter_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/jupyter"
+)
+
+func TestSigner(t *testing.T) {
+	s, err := jupyter.NewSigner(jupyter.ConnectionInfo{Key: "secret", SignatureScheme: "hmac-sha256"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &jupyter.Message{
+		Identities: [][]byte{[]byte("peer")},
+		Header:     jupyter.Header{MsgID: "1", MsgType: "execute_request", Version: jupyter.ProtocolVersion},
+		Parent:     &jupyter.Header{MsgID: "0"},
+		Content:    []byte(`{"code":"1+1"}`),
+		Buffers:    [][]byte{{1, 2}},
+	}
+	frames, err := s.Encode(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Decode(frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Header != m.Header || got.Parent == nil || got.Parent.MsgID != "0" ||
+		string(got.Content) != string(m.Content) || len(got.Identities) != 1 || len(got.Buffers) != 1 {
+		t.Errorf("decoded %+v, want %+v", got, m)
+	}
+
+	// Tampering with the content invalidates the signature.
+	frames[len(frames)-2] = []byte(`{"code":"2+2"}`)
+	if _, err := s.Decode(frames); !errors.Is(err, jupyter.ErrSignature) {
+		t.Errorf("tampered message: err = %v, want ErrSignature", err)
+	}
+
+	if _, err := jupyter.NewSigner(jupyter.ConnectionInfo{Key: "k", SignatureScheme: "hmac-md5"}); err == nil {
+		t.Error("hmac-md5 accepted")
+	}
+	unsigned, _ := jupyter.NewSigner(jupyter.ConnectionInfo{})
+	frames, _ = unsigned.Encode(m)
+	if sig := frames[2]; len(sig) != 0 {
+		t.Errorf("unsigned message has signature %q", sig)
+	}
+}
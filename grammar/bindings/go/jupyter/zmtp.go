@@ -0,0 +1,369 @@
+package jupyter
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// The kernel speaks ZMTP 3.0, the wire protocol of ZeroMQ, with the NULL
+// security mechanism: message integrity comes from the HMAC signatures of
+// the Jupyter protocol. Only what the kernel's sockets need is
+// implemented: ROUTER, PUB and REP on the binding side, and the client side
+// of a connection for driving a kernel.
+
+// Frame flags.
+const (
+	flagMore    = 0x01
+	flagLong    = 0x02
+	flagCommand = 0x04
+)
+
+// maxFrame bounds the size of a received frame.
+const maxFrame = 64 << 20
+
+// ErrClosed is returned by operations on a closed socket.
+var ErrClosed = errors.New("jupyter: socket closed")
+
+// Conn is a ZMTP connection that has completed its handshake.
+type Conn struct {
+	c   net.Conn
+	r   *bufio.Reader
+	wmu sync.Mutex
+	w   *bufio.Writer
+	// PeerType is the socket type the peer announced, such as "DEALER".
+	PeerType string
+	// Identity is the identity the peer announced, if any.
+	Identity []byte
+}
+
+// Dial connects to a ZMTP socket at addr as a socket of the given type,
+// such as "DEALER" for a kernel's shell socket or "SUB" for its IOPub
+// socket. A SUB connection subscribes to every message.
+func Dial(addr, socketType string) (*Conn, error) {
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := handshake(c, socketType, nil)
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	if socketType == "SUB" {
+		// ZMTP 3.0 subscriptions are messages: 1 and the topic prefix.
+		if err := conn.WriteMessage([][]byte{{1}}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+func greeting() []byte {
+	g := make([]byte, 64)
+	g[0], g[9] = 0xff, 0x7f
+	g[10], g[11] = 3, 0
+	copy(g[12:32], "NULL")
+	return g
+}
+
+// handshake exchanges greetings and READY commands on c.
+func handshake(c net.Conn, socketType string, identity []byte) (*Conn, error) {
+	conn := &Conn{c: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+	if _, err := c.Write(greeting()); err != nil {
+		return nil, err
+	}
+	var peer [64]byte
+	if _, err := io.ReadFull(conn.r, peer[:]); err != nil {
+		return nil, err
+	}
+	if peer[0] != 0xff || peer[9]&1 != 1 {
+		return nil, errors.New("jupyter: peer is not a ZMTP socket")
+	}
+	if peer[10] < 3 {
+		return nil, fmt.Errorf("jupyter: unsupported ZMTP version %d.%d", peer[10], peer[11])
+	}
+	if mech := string(bytes.TrimRight(peer[12:32], "\x00")); mech != "NULL" {
+		return nil, fmt.Errorf("jupyter: unsupported security mechanism %s", mech)
+	}
+
+	ready := []byte{5}
+	ready = append(ready, "READY"...)
+	ready = appendProperty(ready, "Socket-Type", []byte(socketType))
+	if identity != nil {
+		ready = appendProperty(ready, "Identity", identity)
+	}
+	if err := conn.writeFrames(flagCommand, [][]byte{ready}); err != nil {
+		return nil, err
+	}
+	flags, body, err := conn.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	if flags&flagCommand == 0 || len(body) < 6 || string(body[1:6]) != "READY" {
+		return nil, errors.New("jupyter: expected a READY command")
+	}
+	props, err := parseProperties(body[6:])
+	if err != nil {
+		return nil, err
+	}
+	conn.PeerType = string(props["Socket-Type"])
+	conn.Identity = props["Identity"]
+	return conn, nil
+}
+
+func appendProperty(b []byte, name string, value []byte) []byte {
+	b = append(b, byte(len(name)))
+	b = append(b, name...)
+	b = binary.BigEndian.AppendUint32(b, uint32(len(value)))
+	return append(b, value...)
+}
+
+func parseProperties(b []byte) (map[string][]byte, error) {
+	props := map[string][]byte{}
+	for len(b) > 0 {
+		n := int(b[0])
+		if len(b) < 1+n+4 {
+			return nil, errors.New("jupyter: malformed READY properties")
+		}
+		name := string(b[1 : 1+n])
+		size := binary.BigEndian.Uint32(b[1+n:])
+		b = b[1+n+4:]
+		if uint32(len(b)) < size {
+			return nil, errors.New("jupyter: malformed READY properties")
+		}
+		props[name] = b[:size]
+		b = b[size:]
+	}
+	return props, nil
+}
+
+func (c *Conn) readFrame() (flags byte, body []byte, err error) {
+	if flags, err = c.r.ReadByte(); err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&flagLong != 0 {
+		var b [8]byte
+		if _, err := io.ReadFull(c.r, b[:]); err != nil {
+			return 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(b[:])
+	} else {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			return 0, nil, err
+		}
+		size = uint64(b)
+	}
+	if size > maxFrame {
+		return 0, nil, fmt.Errorf("jupyter: frame of %d bytes is too large", size)
+	}
+	body = make([]byte, size)
+	_, err = io.ReadFull(c.r, body)
+	return flags, body, err
+}
+
+// ReadMessage returns the frames of the next message. Commands other than
+// PING are skipped; PING is answered.
+func (c *Conn) ReadMessage() ([][]byte, error) {
+	var frames [][]byte
+	for {
+		flags, body, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+		if flags&flagCommand != 0 {
+			if len(body) >= 5 && string(body[1:5]) == "PING" {
+				pong := append([]byte{4}, "PONG"...)
+				if len(body) > 7 {
+					// The context follows the command name and TTL.
+					pong = append(pong, body[7:]...)
+				}
+				if err := c.writeFrames(flagCommand, [][]byte{pong}); err != nil {
+					return nil, err
+				}
+			}
+			continue
+		}
+		frames = append(frames, body)
+		if flags&flagMore == 0 {
+			return frames, nil
+		}
+	}
+}
+
+// WriteMessage sends a message of one or more frames. It is safe for
+// concurrent use.
+func (c *Conn) WriteMessage(frames [][]byte) error {
+	return c.writeFrames(0, frames)
+}
+
+func (c *Conn) writeFrames(kind byte, frames [][]byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	for i, f := range frames {
+		flags := kind
+		if i < len(frames)-1 {
+			flags |= flagMore
+		}
+		if len(f) > 255 {
+			c.w.WriteByte(flags | flagLong)
+			c.w.Write(binary.BigEndian.AppendUint64(nil, uint64(len(f))))
+		} else {
+			c.w.WriteByte(flags)
+			c.w.WriteByte(byte(len(f)))
+		}
+		c.w.Write(f)
+	}
+	return c.w.Flush()
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error { return c.c.Close() }
+
+// socket is the binding side of a ZeroMQ socket: ROUTER sockets receive
+// messages prefixed with the identity of their sender and route replies by
+// it, PUB sockets send to every peer, and REP sockets, used for the
+// heartbeat, echo what they receive.
+type socket struct {
+	kind string
+	ln   net.Listener
+	in   chan [][]byte
+	done chan struct{}
+
+	mu     sync.Mutex
+	peers  map[string]*Conn
+	nextID uint32
+	closed bool
+}
+
+func listen(kind, addr string) (*socket, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &socket{kind: kind, ln: ln, in: make(chan [][]byte, 16), done: make(chan struct{}), peers: map[string]*Conn{}}
+	go s.accept()
+	return s, nil
+}
+
+// port returns the port the socket is bound to.
+func (s *socket) port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+func (s *socket) accept() {
+	for {
+		c, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		go s.serve(c)
+	}
+}
+
+func (s *socket) serve(c net.Conn) {
+	conn, err := handshake(c, s.kind, nil)
+	if err != nil {
+		c.Close()
+		return
+	}
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		c.Close()
+		return
+	}
+	id := string(conn.Identity)
+	if id == "" || s.peers[id] != nil {
+		// Generated identities start with a zero byte, as in libzmq, so
+		// that they cannot clash with identities peers choose.
+		s.nextID++
+		id = string(binary.BigEndian.AppendUint32([]byte{0}, s.nextID))
+	}
+	s.peers[id] = conn
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.peers, id)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	for {
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		switch s.kind {
+		case "ROUTER":
+			select {
+			case s.in <- append([][]byte{[]byte(id)}, msg...):
+			case <-s.done:
+				return
+			}
+		case "REP":
+			if err := conn.WriteMessage(msg); err != nil {
+				return
+			}
+		}
+		// PUB sockets receive subscriptions only; every message is sent
+		// to every peer.
+	}
+}
+
+// recv returns the next message received by a ROUTER socket, its first
+// frame the identity of the sender.
+func (s *socket) recv(ctx context.Context) ([][]byte, error) {
+	select {
+	case msg := <-s.in:
+		return msg, nil
+	case <-s.done:
+		return nil, ErrClosed
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// send sends a message. On a ROUTER socket the first frame is the identity
+// of the peer; messages to unknown peers are dropped, as ZeroMQ does.
+func (s *socket) send(frames [][]byte) error {
+	s.mu.Lock()
+	var conns []*Conn
+	switch s.kind {
+	case "ROUTER":
+		if c := s.peers[string(frames[0])]; c != nil {
+			conns = append(conns, c)
+		}
+		frames = frames[1:]
+	default:
+		for _, c := range s.peers {
+			conns = append(conns, c)
+		}
+	}
+	s.mu.Unlock()
+	for _, c := range conns {
+		// A peer that fails has gone; its serve loop removes it.
+		c.WriteMessage(frames)
+	}
+	return nil
+}
+
+func (s *socket) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	close(s.done)
+	for _, c := range s.peers {
+		c.Close()
+	}
+	return s.ln.Close()
+}
@@ -0,0 +1,170 @@
+// Package lineindex maps between byte offsets and line positions of a
+// source without rescanning it. An Index records where each line starts,
+// built once per document, and answers conversions by binary search:
+//
+//	ix := lineindex.New(src)
+//	p := ix.Point(offset)                    // row and byte column
+//	lsp := ix.Position(src, offset, position.UTF16)
+//
+// Edit updates the index for an edit of the document by scanning only the
+// inserted text, so an editor session keeps one index for its life.
+//
+// Lines end at '\n', as tree-sitter rows do.
+package lineindex
+
+import (
+	"sort"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/position"
+)
+
+// Index is the line index of a source. The zero Index is that of the empty
+// source.
+type Index struct {
+	// starts holds the offsets of the lines after the first, each just
+	// past a '\n'.
+	starts []uint
+	length uint
+}
+
+// New returns the index of src.
+func New(src []byte) *Index {
+	ix := &Index{length: uint(len(src))}
+	ix.starts = appendStarts(ix.starts, src, 0)
+	return ix
+}
+
+// appendStarts appends the offsets of the lines starting in text, which
+// begins at offset base, to starts.
+func appendStarts(starts []uint, text []byte, base uint) []uint {
+	for i, b := range text {
+		if b == '\n' {
+			starts = append(starts, base+uint(i)+1)
+		}
+	}
+	return starts
+}
+
+// Len returns the length of the source in bytes.
+func (ix *Index) Len() uint { return ix.length }
+
+// Lines returns the number of lines; a source ending in '\n' has an empty
+// last line.
+func (ix *Index) Lines() int { return len(ix.starts) + 1 }
+
+// LineStart returns the offset of the start of line row, or the length of
+// the source if there is no such line.
+func (ix *Index) LineStart(row uint) uint {
+	switch {
+	case row == 0:
+		return 0
+	case row > uint(len(ix.starts)):
+		return ix.length
+	}
+	return ix.starts[row-1]
+}
+
+// LineEnd returns the offset of the end of line row, before its '\n', or
+// the length of the source if there is no such line.
+func (ix *Index) LineEnd(row uint) uint {
+	if row >= uint(len(ix.starts)) {
+		return ix.length
+	}
+	return ix.starts[row] - 1
+}
+
+// row returns the line containing offset, which must not exceed the length
+// of the source.
+func (ix *Index) row(offset uint) uint {
+	return uint(sort.Search(len(ix.starts), func(i int) bool { return ix.starts[i] > offset }))
+}
+
+// Point returns the row and byte column of offset. Offsets past the end of
+// the source clamp to it.
+func (ix *Index) Point(offset uint) tree_sitter.Point {
+	offset = min(offset, ix.length)
+	row := ix.row(offset)
+	return tree_sitter.Point{Row: row, Column: offset - ix.LineStart(row)}
+}
+
+// Offset returns the offset of p, a row and byte column. Columns past the
+// end of a line clamp to the line end, and rows past the last clamp to the
+// end of the source.
+func (ix *Index) Offset(p tree_sitter.Point) uint {
+	if p.Row >= uint(ix.Lines()) {
+		return ix.length
+	}
+	start := ix.LineStart(p.Row)
+	return start + min(p.Column, ix.LineEnd(p.Row)-start)
+}
+
+// Range returns the range from offset start to offset end.
+func (ix *Index) Range(start, end uint) tree_sitter.Range {
+	return tree_sitter.Range{StartByte: start, EndByte: end, StartPoint: ix.Point(start), EndPoint: ix.Point(end)}
+}
+
+// Position returns the position of offset in src, the indexed source, in
+// code units of enc. Only the line of offset is scanned.
+func (ix *Index) Position(src []byte, offset uint, enc position.Encoding) position.Position {
+	p := ix.Point(offset)
+	start := ix.LineStart(p.Row)
+	return position.Position{Line: p.Row, Column: position.Units(src[start:start+p.Column], enc)}
+}
+
+// PositionOffset returns the offset in src, the indexed source, of p, a
+// position in code units of enc. It clamps as Offset does.
+func (ix *Index) PositionOffset(src []byte, p position.Position, enc position.Encoding) uint {
+	if p.Line >= uint(ix.Lines()) {
+		return ix.length
+	}
+	start := ix.LineStart(p.Line)
+	return start + position.ByteOffset(src[start:ix.LineEnd(p.Line)], p.Column, enc)
+}
+
+// Edit updates the index for the replacement of the bytes from start to
+// oldEnd, offsets before the edit, with newText.
+func (ix *Index) Edit(start, oldEnd uint, newText []byte) {
+	oldEnd = min(oldEnd, ix.length)
+	start = min(start, oldEnd)
+	// Lines starting in (start, oldEnd] started in the replaced bytes.
+	first := sort.Search(len(ix.starts), func(i int) bool { return ix.starts[i] > start })
+	last := sort.Search(len(ix.starts), func(i int) bool { return ix.starts[i] > oldEnd })
+	inserted := appendStarts(nil, newText, start)
+	// delta wraps around when the edit shrinks the source, which the
+	// unsigned sums below undo.
+	delta := uint(len(newText)) - (oldEnd - start)
+
+	tail := ix.starts[last:]
+	starts := make([]uint, 0, first+len(inserted)+len(tail))
+	starts = append(starts, ix.starts[:first]...)
+	starts = append(starts, inserted...)
+	for _, s := range tail {
+		starts = append(starts, s+delta)
+	}
+	ix.starts = starts
+	ix.length += delta
+}
+
+// InputEdit returns the tree-sitter edit of the replacement Edit would
+// index, with the points it needs, computed before the index is updated.
+func (ix *Index) InputEdit(start, oldEnd uint, newText []byte) tree_sitter.InputEdit {
+	startPoint := ix.Point(start)
+	newEnd := startPoint
+	for _, b := range newText {
+		if b == '\n' {
+			newEnd.Row++
+			newEnd.Column = 0
+		} else {
+			newEnd.Column++
+		}
+	}
+	return tree_sitter.InputEdit{
+		StartByte:      start,
+		OldEndByte:     oldEnd,
+		NewEndByte:     start + uint(len(newText)),
+		StartPosition:  startPoint,
+		OldEndPosition: ix.Point(oldEnd),
+		NewEndPosition: newEnd,
+	}
+}
@@ -0,0 +1,89 @@
+package lineindex_test
+
+import (
+	"math/rand"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lineindex"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/position"
+)
+
+func TestConversions(t *testing.T) {
+	src := []byte("x: 1\n\n\\ 😀 y\nz")
+	ix := lineindex.New(src)
+	if ix.Lines() != 4 || ix.Len() != uint(len(src)) {
+		t.Fatalf("Lines = %d, Len = %d", ix.Lines(), ix.Len())
+	}
+	points := map[uint]tree_sitter.Point{
+		0:    {Row: 0, Column: 0},
+		4:    {Row: 0, Column: 4},
+		5:    {Row: 1, Column: 0},
+		6:    {Row: 2, Column: 0},
+		13:   {Row: 2, Column: 7},
+		15:   {Row: 3, Column: 0},
+		1000: {Row: 3, Column: 1},
+	}
+	for off, want := range points {
+		if got := ix.Point(off); got != want {
+			t.Errorf("Point(%d) = %+v, want %+v", off, got, want)
+		}
+	}
+	offsets := map[tree_sitter.Point]uint{
+		{Row: 0, Column: 99}: 4,
+		{Row: 1, Column: 3}:  5,
+		{Row: 2, Column: 7}:  13,
+		{Row: 9, Column: 0}:  uint(len(src)),
+	}
+	for p, want := range offsets {
+		if got := ix.Offset(p); got != want {
+			t.Errorf("Offset(%+v) = %d, want %d", p, got, want)
+		}
+	}
+	// y follows 😀, two UTF-16 code units.
+	y := uint(len("x: 1\n\n\\ 😀 "))
+	if got := ix.Position(src, y, position.UTF16); got != (position.Position{Line: 2, Column: 5}) {
+		t.Errorf("Position = %+v", got)
+	}
+	if got := ix.PositionOffset(src, position.Position{Line: 2, Column: 4}, position.UTF32); got != y {
+		t.Errorf("PositionOffset = %d, want %d", got, y)
+	}
+}
+
+// TestEdit checks that an edited index matches the index of the edited
+// source, over random edits.
+func TestEdit(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	pieces := []string{"", "a", "\n", "bc\n", "\n\n", "d\ne", "😀"}
+	src := []byte("f: {x}\ng: 1\n\nh: 2")
+	ix := lineindex.New(src)
+	for i := 0; i < 2000; i++ {
+		start := uint(rng.Intn(len(src) + 1))
+		oldEnd := start + uint(rng.Intn(len(src)-int(start)+1))
+		text := []byte(pieces[rng.Intn(len(pieces))])
+
+		edit := ix.InputEdit(start, oldEnd, text)
+		next := append(append(append([]byte(nil), src[:start]...), text...), src[oldEnd:]...)
+		want := lineindex.New(next)
+		if edit.NewEndPosition != want.Point(edit.NewEndByte) || edit.OldEndPosition != lineindex.New(src).Point(oldEnd) {
+			t.Fatalf("edit %d: InputEdit = %+v", i, edit)
+		}
+		ix.Edit(start, oldEnd, text)
+		if !same(ix, want) {
+			t.Fatalf("edit %d of %q: [%d, %d) -> %q: index %+v, want %+v", i, src, start, oldEnd, text, ix, want)
+		}
+		src = next
+	}
+}
+
+func same(a, b *lineindex.Index) bool {
+	if a.Lines() != b.Lines() || a.Len() != b.Len() {
+		return false
+	}
+	for row := uint(0); row < uint(a.Lines()); row++ {
+		if a.LineStart(row) != b.LineStart(row) {
+			return false
+		}
+	}
+	return true
+}
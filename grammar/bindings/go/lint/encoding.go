@@ -0,0 +1,42 @@
+package lint
+
+import (
+	"fmt"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lineindex"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/normalize"
+)
+
+type invalidUTF8 struct{}
+
+func (invalidUTF8) Name() string { return "invalid-utf8" }
+func (invalidUTF8) Doc() string {
+	return "reports bytes that are not valid UTF-8, which editors show as replacement characters"
+}
+
+// Check reports each run of invalid bytes, offering to replace it with the
+// U+FFFD an editor would show.
+func (r invalidUTF8) Check(tree *tree_sitter.Tree, source []byte) []Finding {
+	invalid := normalize.Detect(source).Invalid
+	if len(invalid) == 0 {
+		return nil
+	}
+	lines := lineindex.New(source)
+	var out []Finding
+	for _, b := range invalid {
+		rng := lines.Range(b.Start, b.End)
+		out = append(out, Finding{
+			Rule:     r.Name(),
+			Range:    rng,
+			Severity: tree_sitter_wabznasm.SeverityWarning,
+			Message:  fmt.Sprintf("invalid UTF-8 %q", source[b.Start:b.End]),
+			Fixes: []tree_sitter_wabznasm.Fix{{
+				Title: "replace with U+FFFD",
+				Edits: []tree_sitter_wabznasm.TextEdit{{Range: rng, NewText: "\uFFFD"}},
+			}},
+		})
+	}
+	return out
+}
@@ -0,0 +1,111 @@
+// Package lint checks wabznasm source against a set of rules.
+//
+// Rules implement the Rule interface and are collected in a registry; the
+// rules shipped with the package register themselves and further rules can
+// be added with Register. Run applies a set of rules to a parse tree and
+// returns their findings in source order, less those that comments
+// starting with IgnoreDirective suppress; RunScript does the same for each
+// statement of a script.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+// Finding is a problem reported by a rule.
+type Finding struct {
+	Rule     string
+	Range    tree_sitter.Range
+	Severity tree_sitter_wabznasm.Severity
+	Message  string
+	// Fixes are machine-applicable corrections, if the rule offers any.
+	Fixes []tree_sitter_wabznasm.Fix
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%d:%d: %s (%s)", f.Range.StartPoint.Row+1, f.Range.StartPoint.Column+1, f.Message, f.Rule)
+}
+
+// Rule is a single check.
+type Rule interface {
+	// Name identifies the rule in findings and configuration, e.g.
+	// "unused-parameter".
+	Name() string
+	// Doc is a one-line description of what the rule reports.
+	Doc() string
+	// Check inspects a parse tree of source. Trees may contain syntax
+	// errors; rules should skip what they cannot interpret.
+	Check(tree *tree_sitter.Tree, source []byte) []Finding
+}
+
+var (
+	mu       sync.RWMutex
+	registry = map[string]Rule{}
+)
+
+// Register adds r to the registry. It panics if a rule with the same name is
+// already registered.
+func Register(r Rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, dup := registry[r.Name()]; dup {
+		panic("lint: rule registered twice: " + r.Name())
+	}
+	registry[r.Name()] = r
+}
+
+// Lookup returns the registered rule called name.
+func Lookup(name string) (Rule, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	r, ok := registry[name]
+	return r, ok
+}
+
+// Rules returns the registered rules sorted by name.
+func Rules() []Rule {
+	mu.RLock()
+	defer mu.RUnlock()
+	rules := make([]Rule, 0, len(registry))
+	for _, r := range registry {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].Name() < rules[j].Name() })
+	return rules
+}
+
+// Run applies rules to tree, or every registered rule if none are given,
+// and returns the findings not suppressed, ordered by position then rule
+// name.
+func Run(tree *tree_sitter.Tree, source []byte, rules ...Rule) []Finding {
+	if len(rules) == 0 {
+		rules = Rules()
+	}
+	var out []Finding
+	for _, r := range rules {
+		out = append(out, r.Check(tree, source)...)
+	}
+	out = suppress(tree, source, rules, out)
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].Range.StartByte != out[j].Range.StartByte {
+			return out[i].Range.StartByte < out[j].Range.StartByte
+		}
+		return out[i].Rule < out[j].Rule
+	})
+	return out
+}
+
+// RunScript is Run for each statement of s, and returns the findings of
+// the statements in turn.
+func RunScript(s *tree_sitter_wabznasm.Script, rules ...Rule) []Finding {
+	var out []Finding
+	for _, st := range s.Statements {
+		out = append(out, Run(st.Tree.Tree, st.Source, rules...)...)
+	}
+	return out
+}
@@ -0,0 +1,187 @@
+package lint_test
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func findings(t *testing.T, src string, rules ...string) []lint.Finding {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	var rs []lint.Rule
+	for _, name := range rules {
+		r, ok := lint.Lookup(name)
+		if !ok {
+			t.Fatalf("rule %s not registered", name)
+		}
+		rs = append(rs, r)
+	}
+	return lint.Run(tree.Tree, []byte(src), rs...)
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		src  string
+		want []string // "rule@offset"
+	}{
+		{"add: {[x;y] x+y}", nil},
+		{"f: {x+1}", nil},
+		{"f: {[x;y] x*2}", []string{"unused-parameter@7"}},
+		{"f: {[x;x] x}", []string{"shadowed-parameter@7"}},
+		{"f: {[f] f+1}", []string{"shadowed-parameter@5"}},
+		{"f: {[a] g[1]}", []string{"unused-parameter@5"}},
+		{"f: {}", []string{"empty-body@3"}},
+		{"f: {[x]}", []string{"empty-body@3", "unused-parameter@5"}},
+		{"f: {[a;b;c;d;e;g;h] a+b+c+d+e+g+h}", []string{"complexity@0"}},
+		{"f: {[a] a+f[a;1]}", []string{"type-error@10"}},
+	}
+	for _, tt := range tests {
+		got := findings(t, tt.src)
+		var keys []string
+		for _, f := range got {
+			keys = append(keys, f.Rule+"@"+strconv.FormatUint(uint64(f.Range.StartByte), 10))
+		}
+		if !slices.Equal(keys, tt.want) {
+			t.Errorf("%q: findings %v, want %v", tt.src, keys, tt.want)
+		}
+	}
+}
+
+func TestRunSelectedRules(t *testing.T) {
+	got := findings(t, "f: {[x]}", "empty-body")
+	if len(got) != 1 || got[0].Rule != "empty-body" || got[0].Severity != tree_sitter_wabznasm.SeverityWarning {
+		t.Errorf("findings = %v", got)
+	}
+}
+
+func TestUnusedParameterFix(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"f: {[x;y] x*2}", "f: {[x] x*2}"},
+		{"f: {[x;y] y*2}", "f: {[y] y*2}"},
+		{"f: {[a;b;c] a+c}", "f: {[a;c] a+c}"},
+		{"f: {[a] g[1]}", "f: {[a] g[1]}"},
+	}
+	for _, tt := range tests {
+		var fixes []tree_sitter_wabznasm.Fix
+		for _, f := range findings(t, tt.src, "unused-parameter") {
+			fixes = append(fixes, f.Fixes...)
+		}
+		got, _ := tree_sitter_wabznasm.ApplyFixes([]byte(tt.src), fixes)
+		if string(got) != tt.want {
+			t.Errorf("%q: fixed to %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+type customRule struct{}
+
+func (customRule) Name() string { return "test-custom" }
+func (customRule) Doc() string  { return "flags every tree" }
+func (r customRule) Check(tree *tree_sitter.Tree, _ []byte) []lint.Finding {
+	return []lint.Finding{{Rule: r.Name(), Range: tree.RootNode().Range(), Message: "custom"}}
+}
+
+func TestRegister(t *testing.T) {
+	lint.Register(customRule{})
+	if _, ok := lint.Lookup("test-custom"); !ok {
+		t.Fatal("registered rule not found")
+	}
+	got := findings(t, "1+2", "test-custom")
+	if len(got) != 1 || got[0].Message != "custom" {
+		t.Errorf("findings = %v", got)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("registering a duplicate rule did not panic")
+		}
+	}()
+	lint.Register(customRule{})
+}
+
+func TestSuppressions(t *testing.T) {
+	// The rules are named, so that rules other tests register do not run.
+	rules := []string{"empty-body", "unused-parameter", "unused-suppression"}
+	tests := []struct {
+		src  string
+		want []string
+	}{
+		{"\\ wabznasm:ignore unused-parameter kept for callers\nf: {[x;y] x*2}", nil},
+		{"f: {[x;y]\n  \\ wabznasm:ignore unused-parameter\n  x}", nil},
+		{"f: {[x;y] x*2} \\ wabznasm:ignore unused-parameter", nil},
+		{"\\ wabznasm:ignore empty-body,unused-parameter\nf: {[x;y]}", nil},
+		{"\\ wabznasm:ignore empty-body\nf: {[x;y] x*2}", []string{"unused-suppression@0", "unused-parameter@36"}},
+		{"\\ wabznasm:ignore nope\nf: 1", []string{"unused-suppression@0"}},
+		{"\\ wabznasm:ignored unused-parameter\nf: {[x;y] x}", []string{"unused-parameter@43"}},
+	}
+	for _, tt := range tests {
+		var keys []string
+		for _, f := range findings(t, tt.src, rules...) {
+			keys = append(keys, f.Rule+"@"+strconv.FormatUint(uint64(f.Range.StartByte), 10))
+		}
+		if !slices.Equal(keys, tt.want) {
+			t.Errorf("%q: findings %v, want %v", tt.src, keys, tt.want)
+		}
+	}
+
+	// Suppressions of rules not run are not reported as unused.
+	if got := findings(t, "\\ wabznasm:ignore unused-parameter\nf: {[x] 1}", "empty-body", "unused-suppression"); len(got) != 0 {
+		t.Errorf("findings = %v", got)
+	}
+
+	src := "\\ wabznasm:ignore empty-body why not\nf: {[x] x}"
+	got := findings(t, src, rules...)
+	if len(got) != 1 || len(got[0].Fixes) != 1 {
+		t.Fatalf("findings = %v", got)
+	}
+	if fixed, _ := tree_sitter_wabznasm.ApplyFixes([]byte(src), got[0].Fixes); string(fixed) != "f: {[x] x}" {
+		t.Errorf("fixed to %q", fixed)
+	}
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	src := "f: 1 \\ caf\xe9\r\ng: 2 \\ \xff\xfe ok \xc3"
+	got := findings(t, src, "invalid-utf8")
+	if len(got) != 3 {
+		t.Fatalf("findings = %v", got)
+	}
+	wants := []struct {
+		start, end uint
+		point      tree_sitter.Point
+	}{{10, 11, tree_sitter.Point{Row: 0, Column: 10}}, {20, 22, tree_sitter.Point{Row: 1, Column: 7}}, {26, 27, tree_sitter.Point{Row: 1, Column: 13}}}
+	for i, want := range wants {
+		r := got[i].Range
+		if r.StartByte != want.start || r.EndByte != want.end || r.StartPoint != want.point {
+			t.Errorf("finding %d at %v, want %d-%d at %v", i, r, want.start, want.end, want.point)
+		}
+	}
+	if got[1].Message != `invalid UTF-8 "\xff\xfe"` {
+		t.Errorf("message = %q", got[1].Message)
+	}
+	fixed, n := tree_sitter_wabznasm.ApplyFixes([]byte(src), append(append(got[0].Fixes, got[1].Fixes...), got[2].Fixes...))
+	if n != 3 || string(fixed) != "f: 1 \\ caf\uFFFD\r\ng: 2 \\ \uFFFD ok \uFFFD" {
+		t.Errorf("fixed %d to %q", n, fixed)
+	}
+	if got := findings(t, "f: 1 \\ café", "invalid-utf8"); len(got) != 0 {
+		t.Errorf("valid source: findings = %v", got)
+	}
+}
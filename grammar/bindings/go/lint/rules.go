@@ -0,0 +1,260 @@
+package lint
+
+import (
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/infer"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/metrics"
+)
+
+// Function parameters are the only local variables the language has, so
+// the unused-local check is phrased in terms of them. The language has no
+// conditional forms yet, so there is no rule for assignments in conditions.
+func init() {
+	Register(unusedParameter{})
+	Register(shadowedParameter{})
+	Register(emptyBody{})
+	Register(complexity{metrics.DefaultThresholds})
+	Register(typeError{})
+	Register(unusedSuppression{})
+	Register(invalidUTF8{})
+}
+
+type unusedParameter struct{}
+
+func (unusedParameter) Name() string { return "unused-parameter" }
+func (unusedParameter) Doc() string {
+	return "reports explicit parameters the function body never refers to"
+}
+
+// Check resolves references with the bundled locals query: a definition in
+// a scope is used if some reference inside the same scope, other than a
+// definition itself, has the same name.
+func (r unusedParameter) Check(tree *tree_sitter.Tree, source []byte) []Finding {
+	q, err := tree_sitter_wabznasm.LocalsQuery()
+	if err != nil {
+		return nil
+	}
+	defer q.Close()
+	cursor := memtrack.NewQueryCursor()
+	defer cursor.Close()
+
+	type scope struct {
+		node tree_sitter.Node
+		defs []tree_sitter.Node
+	}
+	var scopes []*scope
+	definitions := map[uintptr]bool{}
+	var refs []tree_sitter.Node
+	names := q.CaptureNames()
+	captures := cursor.Captures(q.Query, tree.RootNode(), source)
+	for match, index := captures.Next(); match != nil; match, index = captures.Next() {
+		c := match.Captures[index]
+		switch names[c.Index] {
+		case "local.scope":
+			scopes = append(scopes, &scope{node: c.Node})
+		case "local.definition":
+			definitions[c.Node.Id()] = true
+			// Assignment names are globals; only parameter lists define
+			// locals of a function scope.
+			if p := c.Node.Parent(); p != nil && p.Kind() == "parameter_list" {
+				for i := len(scopes) - 1; i >= 0; i-- {
+					if contains(scopes[i].node, c.Node) {
+						scopes[i].defs = append(scopes[i].defs, c.Node)
+						break
+					}
+				}
+			}
+		case "local.reference":
+			refs = append(refs, c.Node)
+		}
+	}
+
+	var out []Finding
+	for _, s := range scopes {
+		for _, def := range s.defs {
+			name := def.Utf8Text(source)
+			used := false
+			for _, ref := range refs {
+				if !definitions[ref.Id()] && contains(s.node, ref) && ref.Utf8Text(source) == name {
+					used = true
+					break
+				}
+			}
+			if !used {
+				out = append(out, Finding{
+					Rule:     r.Name(),
+					Range:    def.Range(),
+					Severity: tree_sitter_wabznasm.SeverityWarning,
+					Message:  "parameter " + name + " is never used",
+					Fixes:    removeParameter(def, name),
+				})
+			}
+		}
+	}
+	return out
+}
+
+// removeParameter returns a fix deleting def and the separator next to
+// it from its parameter list. A sole parameter is left alone: removing
+// the list would make the body's x, y and z implicit parameters. Calls
+// passing the parameter need one argument fewer afterwards, which the
+// title says.
+func removeParameter(def tree_sitter.Node, name string) []tree_sitter_wabznasm.Fix {
+	r := def.Range()
+	if prev := def.PrevSibling(); prev != nil && prev.Kind() == ";" {
+		r.StartByte, r.StartPoint = prev.StartByte(), prev.StartPosition()
+	} else if next := def.NextSibling(); next != nil && next.Kind() == ";" {
+		r.EndByte, r.EndPoint = next.EndByte(), next.EndPosition()
+	} else {
+		return nil
+	}
+	return []tree_sitter_wabznasm.Fix{{
+		Title: "remove parameter " + name + " (callers must drop its argument)",
+		Edits: []tree_sitter_wabznasm.TextEdit{{Range: r}},
+	}}
+}
+
+type shadowedParameter struct{}
+
+func (shadowedParameter) Name() string { return "shadowed-parameter" }
+func (shadowedParameter) Doc() string {
+	return "reports parameters that repeat an earlier parameter or hide the function's own name"
+}
+
+func (r shadowedParameter) Check(tree *tree_sitter.Tree, source []byte) []Finding {
+	var out []Finding
+	walk(tree.RootNode(), func(n *tree_sitter.Node) {
+		if n.Kind() != "parameter_list" {
+			return
+		}
+		var self string
+		if body := n.Parent(); body != nil && body.Kind() == "function_body" {
+			if a := body.Parent(); a != nil && a.Kind() == "assignment" {
+				if name := a.ChildByFieldName("name"); name != nil {
+					self = name.Utf8Text(source)
+				}
+			}
+		}
+		seen := map[string]bool{}
+		cursor := memtrack.Walk(n)
+		defer cursor.Close()
+		for _, p := range n.ChildrenByFieldName("param", cursor.TreeCursor) {
+			name := p.Utf8Text(source)
+			var msg string
+			switch {
+			case seen[name]:
+				msg = "parameter " + name + " repeats an earlier parameter"
+			case name == self:
+				msg = "parameter " + name + " hides the function " + self
+			}
+			seen[name] = true
+			if msg != "" {
+				out = append(out, Finding{
+					Rule:     r.Name(),
+					Range:    p.Range(),
+					Severity: tree_sitter_wabznasm.SeverityWarning,
+					Message:  msg,
+				})
+			}
+		}
+	})
+	return out
+}
+
+type emptyBody struct{}
+
+func (emptyBody) Name() string { return "empty-body" }
+func (emptyBody) Doc() string  { return "reports function literals with no body expression" }
+
+// Check looks for function bodies whose expression the parser had to
+// invent: `{}` and `{[x]}` parse with a MISSING identifier as the body.
+func (r emptyBody) Check(tree *tree_sitter.Tree, source []byte) []Finding {
+	var out []Finding
+	walk(tree.RootNode(), func(n *tree_sitter.Node) {
+		if n.Kind() != "function_body" {
+			return
+		}
+		body := n.ChildByFieldName("body")
+		if body == nil || body.StartByte() == body.EndByte() {
+			out = append(out, Finding{
+				Rule:     r.Name(),
+				Range:    n.Range(),
+				Severity: tree_sitter_wabznasm.SeverityWarning,
+				Message:  "function body is empty",
+			})
+		}
+	})
+	return out
+}
+
+type complexity struct {
+	limits metrics.Thresholds
+}
+
+func (complexity) Name() string { return "complexity" }
+func (complexity) Doc() string {
+	return "reports statements with too many parameters, operations, nesting levels or tokens"
+}
+
+func (r complexity) Check(tree *tree_sitter.Tree, source []byte) []Finding {
+	var out []Finding
+	for _, m := range metrics.Analyze(tree, source) {
+		what := "statement"
+		if m.Func {
+			what = "function " + m.Name
+		} else if m.Name != "" {
+			what = "value of " + m.Name
+		}
+		for _, msg := range r.limits.Exceeded(m) {
+			out = append(out, Finding{
+				Rule:     r.Name(),
+				Range:    m.Range,
+				Severity: tree_sitter_wabznasm.SeverityWarning,
+				Message:  what + ": " + msg,
+			})
+		}
+	}
+	return out
+}
+
+type typeError struct{}
+
+func (typeError) Name() string { return "type-error" }
+func (typeError) Doc() string {
+	return "reports calls and operations that certainly fail, such as calling a number"
+}
+
+// Check infers types with only the registered builtins known: globals of
+// other files are unknown, and operations on them are not reported.
+func (r typeError) Check(tree *tree_sitter.Tree, source []byte) []Finding {
+	var out []Finding
+	info := infer.Check(ast.FromTree(tree, source), infer.NewEnv(infer.BuiltinEnv()))
+	for _, e := range info.Errors {
+		out = append(out, Finding{
+			Rule: r.Name(),
+			Range: tree_sitter.Range{
+				StartByte:  e.Span.Start.Offset,
+				EndByte:    e.Span.End.Offset,
+				StartPoint: tree_sitter.Point{Row: e.Span.Start.Row, Column: e.Span.Start.Column},
+				EndPoint:   tree_sitter.Point{Row: e.Span.End.Row, Column: e.Span.End.Column},
+			},
+			Severity: tree_sitter_wabznasm.SeverityError,
+			Message:  e.Message,
+		})
+	}
+	return out
+}
+
+func walk(n *tree_sitter.Node, visit func(*tree_sitter.Node)) {
+	visit(n)
+	for i := uint(0); i < n.NamedChildCount(); i++ {
+		walk(n.NamedChild(i), visit)
+	}
+}
+
+func contains(outer, inner tree_sitter.Node) bool {
+	return outer.StartByte() <= inner.StartByte() && inner.EndByte() <= outer.EndByte()
+}
@@ -0,0 +1,204 @@
+package lint
+
+import (
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+// IgnoreDirective starts a comment suppressing findings:
+//
+//	\ wabznasm:ignore unused-parameter kept for callers passing two arguments
+//	f: {[x;y] x*2}
+//
+// The directive names the rules to suppress, separated by commas, and may
+// give a reason after them. Inside a function body it suppresses the
+// findings of the body; after a statement on the same line it suppresses
+// the findings of that statement; elsewhere it suppresses the findings of
+// the next statement.
+const IgnoreDirective = "wabznasm:ignore"
+
+// Suppression is a comment suppressing findings.
+type Suppression struct {
+	// Comment is the range of the comment.
+	Comment tree_sitter.Range
+	Rules   []string
+	Reason  string
+	// Scope is the range whose findings are suppressed; it is empty if
+	// the comment has nothing to apply to.
+	Scope tree_sitter.Range
+}
+
+// covers reports whether s suppresses f.
+func (s Suppression) covers(f Finding) bool {
+	if f.Range.StartByte < s.Scope.StartByte || f.Range.EndByte > s.Scope.EndByte || s.Scope.StartByte == s.Scope.EndByte {
+		return false
+	}
+	for _, r := range s.Rules {
+		if r == f.Rule {
+			return true
+		}
+	}
+	return false
+}
+
+// Suppressions returns the suppression comments of tree, in source order.
+func Suppressions(tree *tree_sitter.Tree, source []byte) []Suppression {
+	var out []Suppression
+	root := tree.RootNode()
+	var walk func(n *tree_sitter.Node)
+	walk = func(n *tree_sitter.Node) {
+		if n.Kind() == tree_sitter_wabznasm.KindComment {
+			if s, ok := parseSuppression(root, n, source); ok {
+				out = append(out, s)
+			}
+			return
+		}
+		for i := uint(0); i < n.ChildCount(); i++ {
+			walk(n.Child(i))
+		}
+	}
+	walk(root)
+	return out
+}
+
+// parseSuppression parses the comment n, reporting whether it is a
+// suppression.
+func parseSuppression(root, n *tree_sitter.Node, source []byte) (Suppression, bool) {
+	text := strings.TrimSpace(strings.TrimPrefix(n.Utf8Text(source), `\`))
+	rest, ok := strings.CutPrefix(text, IgnoreDirective)
+	if !ok || rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return Suppression{}, false
+	}
+	fields := strings.Fields(rest)
+	s := Suppression{Comment: n.Range()}
+	if len(fields) > 0 {
+		for _, r := range strings.Split(fields[0], ",") {
+			if r != "" {
+				s.Rules = append(s.Rules, r)
+			}
+		}
+		s.Reason = strings.Join(fields[1:], " ")
+	}
+	if scope := suppressionScope(root, n); scope != nil {
+		s.Scope = scope.Range()
+	} else {
+		s.Scope = tree_sitter.Range{StartByte: n.EndByte(), EndByte: n.EndByte()}
+	}
+	return s, true
+}
+
+// suppressionScope returns the node whose findings the comment n
+// suppresses, or nil.
+func suppressionScope(root, n *tree_sitter.Node) *tree_sitter.Node {
+	for p := n.Parent(); p != nil; p = p.Parent() {
+		if p.Kind() == tree_sitter_wabznasm.KindFunctionBody {
+			return p
+		}
+	}
+	var next *tree_sitter.Node
+	for i := uint(0); i < root.NamedChildCount(); i++ {
+		st := root.NamedChild(i)
+		if st.Kind() != tree_sitter_wabznasm.KindStatement {
+			continue
+		}
+		if st.EndByte() <= n.StartByte() && st.EndPosition().Row == n.StartPosition().Row {
+			return st
+		}
+		if next == nil && st.StartByte() >= n.EndByte() {
+			next = st
+		}
+	}
+	return next
+}
+
+// suppress removes the findings that suppression comments cover. If
+// rules include unused-suppression, it adds a finding for each
+// suppression that removed nothing, unless it names a rule that was not
+// run.
+func suppress(tree *tree_sitter.Tree, source []byte, rules []Rule, findings []Finding) []Finding {
+	sups := Suppressions(tree, source)
+	if len(sups) == 0 {
+		return findings
+	}
+	used := make([]bool, len(sups))
+	out := findings[:0]
+	for _, f := range findings {
+		covered := false
+		for i, s := range sups {
+			if s.covers(f) {
+				used[i], covered = true, true
+			}
+		}
+		if !covered {
+			out = append(out, f)
+		}
+	}
+
+	ran := map[string]bool{}
+	for _, r := range rules {
+		ran[r.Name()] = true
+	}
+	if !ran[unusedSuppression{}.Name()] {
+		return out
+	}
+	for i, s := range sups {
+		if used[i] {
+			continue
+		}
+		message := "suppression comment names no rule"
+		if len(s.Rules) > 0 {
+			message = "suppression of " + strings.Join(s.Rules, ", ") + " suppresses nothing"
+		}
+		complete := true
+		for _, r := range s.Rules {
+			if _, known := Lookup(r); !known {
+				message = "suppression names unknown rule " + r
+				break
+			}
+			complete = complete && ran[r]
+		}
+		if !complete {
+			continue
+		}
+		out = append(out, Finding{
+			Rule:     unusedSuppression{}.Name(),
+			Range:    s.Comment,
+			Severity: tree_sitter_wabznasm.SeverityWarning,
+			Message:  message,
+			Fixes:    []tree_sitter_wabznasm.Fix{{Title: "remove the comment", Edits: []tree_sitter_wabznasm.TextEdit{removeComment(source, s.Comment)}}},
+		})
+	}
+	return out
+}
+
+// removeComment returns the edit deleting the comment at r: its whole
+// line if nothing else is on it, else the comment and the space before
+// it.
+func removeComment(source []byte, r tree_sitter.Range) tree_sitter_wabznasm.TextEdit {
+	start, end := r.StartByte, r.EndByte
+	for start > 0 && (source[start-1] == ' ' || source[start-1] == '\t') {
+		start--
+	}
+	if (start == 0 || source[start-1] == '\n') && int(end) < len(source) && source[end] == '\n' {
+		end++
+	}
+	return tree_sitter_wabznasm.TextEdit{Range: tree_sitter.Range{
+		StartByte:  start,
+		EndByte:    end,
+		StartPoint: tree_sitter_wabznasm.PointForOffset(source, start),
+		EndPoint:   tree_sitter_wabznasm.PointForOffset(source, end),
+	}}
+}
+
+type unusedSuppression struct{}
+
+func (unusedSuppression) Name() string { return "unused-suppression" }
+func (unusedSuppression) Doc() string {
+	return "reports " + IgnoreDirective + " comments that suppress no finding"
+}
+
+// Check reports nothing: whether a suppression is used depends on the
+// findings of the other rules, so Run reports unused ones itself.
+func (unusedSuppression) Check(*tree_sitter.Tree, []byte) []Finding { return nil }
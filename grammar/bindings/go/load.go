@@ -0,0 +1,96 @@
+//go:build !(js && wasm)
+
+package tree_sitter_wabznasm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/dl"
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// LanguageSymbol is the function returning the language in shared objects
+// built from this grammar.
+const LanguageSymbol = "tree_sitter_calc"
+
+// ErrWasm reports a wasm grammar, which needs the wasm store of a
+// tree-sitter runtime built with wasm support; these bindings link one
+// without it.
+var ErrWasm = errors.New("wabznasm: wasm grammars are not supported by these bindings; build the grammar as a shared object")
+
+// used is the language set by UseLanguage.
+var used atomic.Pointer[tree_sitter.Language]
+
+// LoadLanguageFrom loads the grammar of the shared object at path, as
+// tree-sitter build makes from a checkout of this grammar, so that tools
+// can be tried on a grammar under development without rebuilding the
+// bindings. The object is copied before it is opened, so that rebuilding
+// it in place neither disturbs the language returned nor stops a later
+// call from loading the new build; each load stays in memory for the life
+// of the process.
+func LoadLanguageFrom(path string) (*tree_sitter.Language, error) {
+	return LoadLanguageSymbol(path, LanguageSymbol)
+}
+
+// LoadLanguageSymbol is like LoadLanguageFrom for an object whose language
+// function is symbol, or LanguageSymbol if it is empty.
+func LoadLanguageSymbol(path, symbol string) (*tree_sitter.Language, error) {
+	if filepath.Ext(path) == ".wasm" {
+		return nil, fmt.Errorf("load %s: %w", path, ErrWasm)
+	}
+	if symbol == "" {
+		symbol = LanguageSymbol
+	}
+	tmp, err := copyObject(path)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	// The mapping outlives the file.
+	defer os.Remove(tmp)
+	ptr, err := dl.Language(tmp, symbol)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	lang := tree_sitter.NewLanguage(ptr)
+	if err := CheckABI(lang); err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return lang, nil
+}
+
+// copyObject copies the file at path to a new temporary file, whose name
+// the dynamic loader has not seen, and returns its name.
+func copyObject(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+	out, err := os.CreateTemp("", "wabznasm-grammar-*"+filepath.Ext(path))
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// UseLanguage makes lang the language that Language returns, so that the
+// parsers, documents and queries made from then on use it; nil restores
+// the grammar compiled in. Those made before keep the language they were
+// made with, except that a ParserPool discards its idle parsers of
+// another.
+func UseLanguage(lang *tree_sitter.Language) {
+	used.Store(lang)
+}
@@ -0,0 +1,87 @@
+//go:build !(js && wasm)
+
+package tree_sitter_wabznasm_test
+
+import (
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"unsafe"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+// buildGrammar compiles the grammar's parser into a shared object.
+func buildGrammar(t *testing.T) string {
+	t.Helper()
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+	lib := filepath.Join(t.TempDir(), "calc.so")
+	out, err := exec.Command(cc, "-shared", "-fPIC", "-O0", "-I../../src", "-o", lib, "../../src/parser.c").CombinedOutput()
+	if err != nil {
+		t.Skipf("cannot build the parser: %v\n%s", err, out)
+	}
+	return lib
+}
+
+func TestLoadLanguageFrom(t *testing.T) {
+	lib := buildGrammar(t)
+	lang, err := tree_sitter_wabznasm.LoadLanguageFrom(lib)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := tree_sitter_wabznasm.InfoOf(lang), tree_sitter_wabznasm.Info(); got != want {
+		t.Errorf("loaded %+v, builtin %+v", got, want)
+	}
+
+	pool := tree_sitter_wabznasm.NewParserPool()
+	defer pool.Close()
+	held, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Put(held)
+
+	tree_sitter_wabznasm.UseLanguage(lang)
+	t.Cleanup(func() { tree_sitter_wabznasm.UseLanguage(nil) })
+	if tree_sitter_wabznasm.Language() != unsafe.Pointer(lang.Inner) {
+		t.Fatal("Language() is not the loaded grammar")
+	}
+	p, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p == held {
+		t.Error("pool kept a parser for the replaced grammar")
+	}
+	tree, err := p.ParseBytes(snippet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.RootNode().HasError() || tree.Language().Inner != lang.Inner {
+		t.Errorf("tree %s", tree.RootNode().ToSexp())
+	}
+	tree.Close()
+	pool.Put(p)
+
+	tree_sitter_wabznasm.UseLanguage(nil)
+	if tree_sitter_wabznasm.Language() != tree_sitter_wabznasm.BuiltinLanguage() {
+		t.Error("UseLanguage(nil) did not restore the builtin grammar")
+	}
+
+	if _, err := tree_sitter_wabznasm.LoadLanguageSymbol(lib, "tree_sitter_nonesuch"); err == nil {
+		t.Error("no error for a missing symbol")
+	}
+}
+
+func TestLoadLanguageErrors(t *testing.T) {
+	if _, err := tree_sitter_wabznasm.LoadLanguageFrom("grammar.wasm"); !errors.Is(err, tree_sitter_wabznasm.ErrWasm) {
+		t.Errorf("wasm: %v", err)
+	}
+	if _, err := tree_sitter_wabznasm.LoadLanguageFrom(filepath.Join(t.TempDir(), "none.so")); err == nil {
+		t.Error("no error for a missing object")
+	}
+}
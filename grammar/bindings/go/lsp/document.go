@@ -0,0 +1,97 @@
+package lsp
+
+import (
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/position"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// document is an open text document and its incrementally maintained
+// tree and statements. The features of the server work on the statements,
+// as a script is parsed; the tree of the whole text is kept for crash
+// reports.
+type document struct {
+	uri     string
+	version int32
+	inc     *tree_sitter_wabznasm.IncrementalDocument
+	// enc is the encoding the columns of positions are counted in.
+	enc position.Encoding
+}
+
+func newDocument(uri string, version int32, text string, enc position.Encoding) (*document, error) {
+	inc, err := tree_sitter_wabznasm.NewIncrementalDocument([]byte(text))
+	if err != nil {
+		return nil, err
+	}
+	return &document{uri: uri, version: version, inc: inc, enc: enc}, nil
+}
+
+// reparse parses the document again from scratch, with the language in
+// use; see tree_sitter_wabznasm.UseLanguage.
+func (d *document) reparse() error {
+	inc, err := tree_sitter_wabznasm.NewIncrementalDocument(d.source())
+	if err != nil {
+		return err
+	}
+	d.inc.Close()
+	d.inc = inc
+	return nil
+}
+
+func (d *document) source() []byte          { return d.inc.Source() }
+func (d *document) tree() *tree_sitter.Tree { return d.inc.Tree() }
+
+func (d *document) close() { d.inc.Close() }
+
+// stmts returns the statements of the text.
+func (d *document) stmts() *tree_sitter_wabznasm.Script { return d.inc.Script() }
+
+// statementAt returns the statement whose lines hold offset.
+func (d *document) statementAt(offset uint) *tree_sitter_wabznasm.Statement {
+	return d.stmts().At(offset)
+}
+
+// symbols analyses the names in the document.
+func (d *document) symbols() *scopes.Table { return scopes.FromScript(d.stmts()) }
+
+// apply applies one content change from textDocument/didChange.
+func (d *document) apply(change TextDocumentContentChangeEvent) error {
+	src := d.source()
+	start, end := uint(0), uint(len(src))
+	if change.Range != nil {
+		start = d.offset(change.Range.Start)
+		end = d.offset(change.Range.End)
+		if end < start {
+			start, end = end, start
+		}
+	}
+	return d.inc.ApplyEdit(start, end, start+uint(len(change.Text)), []byte(change.Text))
+}
+
+// offset converts an LSP position, whose character is counted in units of
+// the document's encoding, to a byte offset. Positions past the end of a
+// line clamp to the line end, and positions past the last line clamp to
+// the end of the document.
+func (d *document) offset(p Position) uint {
+	return d.inc.Lines().PositionOffset(d.source(), position.Position{Line: uint(p.Line), Column: uint(p.Character)}, d.enc)
+}
+
+// position converts a byte offset to an LSP position.
+func (d *document) position(offset uint) Position {
+	p := d.inc.Lines().Position(d.source(), offset, d.enc)
+	return Position{Line: uint32(p.Line), Character: uint32(p.Column)}
+}
+
+// point returns the row and byte column of offset.
+func (d *document) point(offset uint) tree_sitter.Point {
+	return d.inc.Lines().Point(offset)
+}
+
+func (d *document) rangeOf(start, end uint) Range {
+	return Range{Start: d.position(start), End: d.position(end)}
+}
+
+func (d *document) nodeRange(n *tree_sitter.Node) Range {
+	return d.rangeOf(n.StartByte(), n.EndByte())
+}
@@ -0,0 +1,360 @@
+package lsp
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/docs"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/folding"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/highlight"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/indent"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/outline"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/selection"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/semantic"
+)
+
+const diagnosticSource = "wabznasm"
+
+// identifierPattern matches the names of the grammar, for clients that
+// check linked edits against it.
+const identifierPattern = `[a-zA-Z_][a-zA-Z0-9_]*`
+
+// problem is a diagnostic of the document and the fixes offered for it.
+type problem struct {
+	diag  Diagnostic
+	fixes []tree_sitter_wabznasm.Fix
+}
+
+// problems reports ERROR and MISSING nodes in the trees of the document's
+// statements, then the findings of the registered lint rules.
+func (d *document) problems() []problem {
+	var out []problem
+	for _, diag := range d.stmts().Diagnostics() {
+		out = append(out, problem{Diagnostic{
+			Range:    d.rangeOf(diag.Range.StartByte, diag.Range.EndByte),
+			Severity: int(diag.Severity),
+			Code:     diag.Code,
+			Source:   diagnosticSource,
+			Message:  diag.Message,
+		}, diag.Fixes})
+	}
+	for _, f := range lint.RunScript(d.stmts()) {
+		out = append(out, problem{Diagnostic{
+			Range:    d.rangeOf(f.Range.StartByte, f.Range.EndByte),
+			Severity: int(f.Severity),
+			Code:     f.Rule,
+			Source:   diagnosticSource,
+			Message:  f.Message,
+		}, f.Fixes})
+	}
+	return out
+}
+
+func (d *document) diagnostics() []Diagnostic {
+	out := []Diagnostic{}
+	for _, p := range d.problems() {
+		out = append(out, p.diag)
+	}
+	return out
+}
+
+// codeActions returns a quick fix for each fix of a problem that
+// intersects r.
+func (d *document) codeActions(r Range) []CodeAction {
+	out := []CodeAction{}
+	start, end := d.offset(r.Start), d.offset(r.End)
+	for _, p := range d.problems() {
+		ps, pe := d.offset(p.diag.Range.Start), d.offset(p.diag.Range.End)
+		if pe < start || ps > end {
+			continue
+		}
+		for _, fix := range p.fixes {
+			edits := make([]TextEdit, len(fix.Edits))
+			for i, e := range fix.Edits {
+				edits[i] = TextEdit{Range: d.rangeOf(e.Range.StartByte, e.Range.EndByte), NewText: e.NewText}
+			}
+			out = append(out, CodeAction{
+				Title:       fix.Title,
+				Kind:        CodeActionKindQuickFix,
+				Diagnostics: []Diagnostic{p.diag},
+				Edit:        &WorkspaceEdit{Changes: map[string][]TextEdit{d.uri: edits}},
+			})
+		}
+	}
+	return out
+}
+
+// hover describes the identifier or number under the cursor.
+func (d *document) hover(pos Position) *Hover {
+	off := d.offset(pos)
+	n := d.statementAt(off).Tree.RootNode().NamedDescendantForByteRange(off, off)
+	if n == nil {
+		return nil
+	}
+	var text string
+	switch n.Kind() {
+	case "number":
+		text = "(number) " + n.Utf8Text(d.source())
+	case "identifier":
+		text = d.describeIdent(n)
+	default:
+		return nil
+	}
+	value := "```wabznasm\n" + text + "\n```"
+	if doc := d.docComment(off); doc != "" {
+		value += "\n\n" + doc
+	}
+	r := d.nodeRange(n)
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: value},
+		Range:    &r,
+	}
+}
+
+// docComment returns the doc comment of the global defined in this document
+// and named at off, if any.
+func (d *document) docComment(off uint) string {
+	tab := d.symbols()
+	sym := tab.ResolveAt(off)
+	if sym == nil || sym.Kind != scopes.Global {
+		return ""
+	}
+	if e := docs.Lookup(docs.FromFile(tab.Files...), sym.Name); e != nil {
+		return e.Text
+	}
+	return ""
+}
+
+func (d *document) describeIdent(n *tree_sitter.Node) string {
+	src := d.source()
+	name := n.Utf8Text(src)
+	parent := n.Parent()
+	if parent != nil && parent.Kind() == "parameter_list" {
+		return "(parameter) " + name
+	}
+	// References inside a function body may be parameters.
+	for p := parent; p != nil; p = p.Parent() {
+		if p.Kind() != "function_body" {
+			continue
+		}
+		if params := p.ChildByFieldName("params"); params != nil {
+			cursor := memtrack.Walk(params)
+			for _, param := range params.ChildrenByFieldName("param", cursor.TreeCursor) {
+				if param.Utf8Text(src) == name {
+					cursor.Close()
+					return "(parameter) " + name
+				}
+			}
+			cursor.Close()
+		} else if name == "x" || name == "y" || name == "z" {
+			return "(implicit parameter) " + name
+		}
+		break
+	}
+	if def := d.definition(name); def != nil {
+		return describeAssignment(def)
+	}
+	return "(global) " + name
+}
+
+// definition returns the first assignment defining name in this document,
+// if any.
+func (d *document) definition(name string) *ast.Assignment {
+	for _, stmt := range d.symbols().Stmts() {
+		if a, ok := stmt.(*ast.Assignment); ok && a.Name != nil && a.Name.Name == name {
+			return a
+		}
+	}
+	return nil
+}
+
+func describeAssignment(a *ast.Assignment) string {
+	if fn, ok := a.Value.(*ast.FunctionDef); ok {
+		return "(function) " + a.Name.Name + "[" + strings.Join(fn.Signature(), ";") + "]"
+	}
+	return "(variable) " + a.Name.Name
+}
+
+// documentSymbols returns the definitions in the document.
+func (d *document) documentSymbols() []DocumentSymbol {
+	var symbols []outline.Symbol
+	for _, f := range d.symbols().Files {
+		symbols = append(symbols, outline.File(f)...)
+	}
+	return d.lspSymbols(symbols)
+}
+
+func (d *document) lspSymbols(symbols []outline.Symbol) []DocumentSymbol {
+	out := []DocumentSymbol{}
+	for _, s := range symbols {
+		sym := DocumentSymbol{
+			Name:           s.Name,
+			Detail:         s.Detail,
+			Kind:           SymbolKindVariable,
+			Range:          d.spanRange(s.Span),
+			SelectionRange: d.spanRange(s.Selection),
+		}
+		if s.Kind == outline.Function {
+			sym.Kind = SymbolKindFunction
+		}
+		if len(s.Children) > 0 {
+			sym.Children = d.lspSymbols(s.Children)
+		}
+		out = append(out, sym)
+	}
+	return out
+}
+
+func (d *document) spanRange(s ast.Span) Range {
+	return d.rangeOf(s.Start.Offset, s.End.Offset)
+}
+
+// foldingRanges returns multi-line function bodies, bracketed lists and
+// comment blocks.
+func (d *document) foldingRanges() []FoldingRange {
+	out := []FoldingRange{}
+	var ranges []folding.Range
+	for _, st := range d.stmts().Statements {
+		ranges = append(ranges, folding.Ranges(st.Tree.Tree)...)
+	}
+	for _, r := range folding.LSP(ranges) {
+		out = append(out, FoldingRange(r))
+	}
+	return out
+}
+
+// selectionRanges returns, for each position, the chain of syntactic
+// ranges enclosing it. A position outside every node gets an empty range
+// there, as the protocol wants one result per position.
+func (d *document) selectionRanges(positions []Position) []SelectionRange {
+	out := make([]SelectionRange, len(positions))
+	for i, pos := range positions {
+		offset := d.offset(pos)
+		var chain *SelectionRange
+		ranges := selection.Ranges(d.statementAt(offset).Tree.Tree, d.point(offset))
+		for j := len(ranges) - 1; j >= 0; j-- {
+			chain = &SelectionRange{Range: d.rangeOf(ranges[j].StartByte, ranges[j].EndByte), Parent: chain}
+		}
+		if chain == nil {
+			chain = &SelectionRange{Range: d.rangeOf(offset, offset)}
+		}
+		out[i] = *chain
+	}
+	return out
+}
+
+// linkedEditingRanges returns the occurrences of the parameter at pos, so
+// that renaming it in its parameter list renames its uses in the body as
+// it is typed. Globals may be used by other documents, and the implicit
+// x, y and z can only be renamed by adding a parameter list, so neither
+// is linked.
+func (d *document) linkedEditingRanges(pos Position) *LinkedEditingRanges {
+	tab := d.symbols()
+	sym := tab.ResolveAt(d.offset(pos))
+	if sym == nil || sym.Kind != scopes.Param {
+		return nil
+	}
+	out := &LinkedEditingRanges{WordPattern: identifierPattern}
+	for _, id := range tab.Occurrences(sym) {
+		out.Ranges = append(out.Ranges, d.spanRange(id.Span))
+	}
+	return out
+}
+
+// formatConfig returns the formatter style of the document: that of the
+// nearest .wabznasmfmt file for a document on disk, and the canonical
+// style otherwise or if the file cannot be read.
+func (d *document) formatConfig() format.Config {
+	u, err := url.Parse(d.uri)
+	if err != nil || u.Scheme != "file" {
+		return format.Config{}
+	}
+	cfg, _, err := format.ConfigFor(filepath.FromSlash(u.Path))
+	if err != nil {
+		return format.Config{}
+	}
+	return cfg
+}
+
+// formatting formats the document, or the part of it in r if r is not
+// nil. The formatter's layout is fixed by its style, so the client's
+// indentation options do not apply. A document that does not parse is
+// left alone.
+func (d *document) formatting(r *Range) []TextEdit {
+	src := d.source()
+	var edits []tree_sitter_wabznasm.TextEdit
+	var err error
+	if r == nil {
+		edits, err = d.formatConfig().Edits(src)
+	} else {
+		edits, err = d.formatConfig().Range(src, d.offset(r.Start), d.offset(r.End))
+	}
+	return d.textEdits(edits, err)
+}
+
+// formatSeparator formats the call or parameter list in which a ; was
+// just typed before pos.
+func (d *document) formatSeparator(pos Position) []TextEdit {
+	edits, err := d.formatConfig().OnType(d.source(), d.offset(pos), ';')
+	return d.textEdits(edits, err)
+}
+
+func (d *document) textEdits(edits []tree_sitter_wabznasm.TextEdit, err error) []TextEdit {
+	out := []TextEdit{}
+	if err != nil {
+		return out
+	}
+	for _, e := range edits {
+		out = append(out, TextEdit{Range: d.rangeOf(e.Range.StartByte, e.Range.EndByte), NewText: e.NewText})
+	}
+	return out
+}
+
+// onTypeFormatting reindents the line of pos, returning no edits when its
+// indentation is already right.
+func (d *document) onTypeFormatting(pos Position, opts FormattingOptions) []TextEdit {
+	src := d.source()
+	start := d.offset(Position{Line: pos.Line})
+	st := d.statementAt(start)
+	level := indent.Level(st.Tree.Tree, st.Source, uint(pos.Line))
+	unit := indent.Unit
+	switch {
+	case !opts.InsertSpaces && opts.TabSize > 0:
+		unit = "\t"
+	case opts.TabSize > 0:
+		unit = strings.Repeat(" ", int(opts.TabSize))
+	}
+	want := strings.Repeat(unit, level)
+	end := start
+	for end < uint(len(src)) && (src[end] == ' ' || src[end] == '\t') {
+		end++
+	}
+	if string(src[start:end]) == want {
+		return []TextEdit{}
+	}
+	return []TextEdit{{Range: d.rangeOf(start, end), NewText: want}}
+}
+
+func (d *document) semanticTokens() (*SemanticTokens, error) {
+	var tokens []highlight.Token
+	for _, st := range d.stmts().Statements {
+		toks, err := highlight.Tokens(st.Tree.Tree, st.Source)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, toks...)
+	}
+	data := semantic.EncodeIn(tokens, d.source(), d.enc)
+	if data == nil {
+		data = []uint32{}
+	}
+	return &SemanticTokens{Data: data}, nil
+}
@@ -0,0 +1,66 @@
+package lsp
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+)
+
+// grammar is a shared object the server parses with, and the state of the
+// file when it was last loaded.
+type grammar struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// SetGrammar makes the server parse with the grammar of the shared object
+// at path, loaded by tree_sitter_wabznasm.LoadLanguageFrom, instead of the
+// one compiled in. Before each message the server checks whether the file
+// has changed and if so loads it again and reparses the open documents,
+// so that a grammar under development can be tried in an editor as it is
+// rebuilt. It returns the error of the first load, after which the server
+// keeps parsing with the grammar it had.
+func (s *Server) SetGrammar(path string) error {
+	s.grammar = &grammar{path: path}
+	return s.reloadGrammar()
+}
+
+// reloadGrammar loads the grammar again if its file has changed since it
+// was last loaded. A failed load is not retried until the file changes
+// again.
+func (s *Server) reloadGrammar() error {
+	g := s.grammar
+	if g == nil {
+		return nil
+	}
+	info, err := os.Stat(g.path)
+	if err != nil {
+		return err
+	}
+	if info.Size() == g.size && info.ModTime().Equal(g.modTime) {
+		return nil
+	}
+	g.size, g.modTime = info.Size(), info.ModTime()
+	lang, err := tree_sitter_wabznasm.LoadLanguageFrom(g.path)
+	if err != nil {
+		return err
+	}
+	tree_sitter_wabznasm.UseLanguage(lang)
+	telemetry.Logger(s.ctx).InfoContext(s.ctx, "grammar loaded",
+		slog.String("path", g.path),
+		slog.String("hash", tree_sitter_wabznasm.InfoOf(lang).Hash))
+	for _, doc := range s.docs {
+		if err := doc.reparse(); err != nil {
+			return fmt.Errorf("reparse %s: %w", doc.uri, err)
+		}
+		if rerr := s.publish(doc); rerr != nil {
+			return fmt.Errorf("publish %s: %s", doc.uri, rerr.Message)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package lsp
+
+import "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+
+// MemoryStats is the result of wabznasm/memoryStats: the tree-sitter
+// objects and C memory of the process, and the open documents, each of
+// which holds a parser, a tree and the trees of its statements.
+type MemoryStats struct {
+	memtrack.Stats
+	Documents int `json:"documents"`
+}
+
+func (s *Server) memoryStats() MemoryStats {
+	return MemoryStats{Stats: memtrack.Read(), Documents: len(s.docs)}
+}
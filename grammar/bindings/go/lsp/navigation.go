@@ -0,0 +1,165 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/complete"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/definition"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/references"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/signature"
+)
+
+// openIndex resolves globals across the open documents. Locations name
+// documents by URI.
+type openIndex struct{ s *Server }
+
+type indexedDoc struct {
+	uri string
+	tab *scopes.Table
+}
+
+// tables analyses the open documents in URI order.
+func (ix openIndex) tables() []indexedDoc {
+	uris := make([]string, 0, len(ix.s.docs))
+	for uri := range ix.s.docs {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+	out := make([]indexedDoc, len(uris))
+	for i, uri := range uris {
+		out[i] = indexedDoc{uri: uri, tab: ix.s.docs[uri].symbols()}
+	}
+	return out
+}
+
+func (ix openIndex) Definitions(name string) []definition.Location {
+	var out []definition.Location
+	for _, t := range ix.tables() {
+		if sym := t.tab.Global.Symbols[name]; sym != nil && sym.Kind == scopes.Global {
+			for _, id := range append([]*ast.Ident{sym.Decl}, sym.Redefs...) {
+				out = append(out, definition.Location{File: t.uri, Span: ast.Span{Start: id.Pos(), End: id.EndPos()}})
+			}
+		}
+	}
+	return out
+}
+
+func (ix openIndex) References(name string) []definition.Location {
+	var out []definition.Location
+	for _, t := range ix.tables() {
+		if sym := t.tab.Global.Symbols[name]; sym != nil {
+			for _, id := range sym.Refs {
+				out = append(out, definition.Location{File: t.uri, Span: ast.Span{Start: id.Pos(), End: id.EndPos()}})
+			}
+		}
+	}
+	return out
+}
+
+// Globals lists the globals defined by the open documents.
+func (ix openIndex) Globals() []string {
+	seen := map[string]bool{}
+	var out []string
+	for _, t := range ix.tables() {
+		for name, sym := range t.tab.Global.Symbols {
+			if sym.Kind == scopes.Global && !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
+
+// Signature returns the parameters of the global function name, as the
+// first open document in URI order defines it.
+func (ix openIndex) Signature(name string) ([]string, bool) {
+	for _, t := range ix.tables() {
+		if sym := t.tab.Global.Symbols[name]; sym != nil && sym.Func != nil {
+			return sym.Func.Signature(), true
+		}
+	}
+	return nil, false
+}
+
+func (s *Server) definition(doc *document, pos Position) []Location {
+	sym := doc.symbols().ResolveAt(doc.offset(pos))
+	if sym == nil {
+		return []Location{}
+	}
+	return s.locations(definition.Of(openIndex{s}, doc.uri, sym))
+}
+
+func (s *Server) references(doc *document, pos Position, includeDecl bool) []Location {
+	tab := doc.symbols()
+	sym := tab.ResolveAt(doc.offset(pos))
+	if sym == nil {
+		return []Location{}
+	}
+	return s.locations(references.Of(openIndex{s}, doc.uri, tab, sym, includeDecl))
+}
+
+func (s *Server) locations(locs []definition.Location) []Location {
+	out := []Location{}
+	for _, l := range locs {
+		doc, ok := s.docs[l.File]
+		if !ok {
+			continue
+		}
+		out = append(out, Location{URI: l.File, Range: doc.rangeOf(l.Span.Start.Offset, l.Span.End.Offset)})
+	}
+	return out
+}
+
+var completionKinds = map[complete.Kind]int{
+	complete.Variable:  CompletionItemKindVariable,
+	complete.Parameter: CompletionItemKindVariable,
+	complete.Function:  CompletionItemKindFunction,
+	complete.Operator:  CompletionItemKindOperator,
+	complete.Snippet:   CompletionItemKindSnippet,
+}
+
+// completion proposes names in scope, including the globals of the other
+// open documents, operators and snippets. Each item replaces the name
+// prefix before pos and sorts in the ranked order.
+func (s *Server) completion(doc *document, pos Position) CompletionList {
+	offset := doc.offset(pos)
+	st := doc.statementAt(offset)
+	res := complete.Complete(st.Tree.Tree, st.Source, offset, complete.Options{Globals: openIndex{s}})
+	list := CompletionList{Items: []CompletionItem{}}
+	for i, item := range res.Items {
+		format := InsertTextFormatPlainText
+		if item.Kind == complete.Snippet {
+			format = InsertTextFormatSnippet
+		}
+		list.Items = append(list.Items, CompletionItem{
+			Label:            item.Label,
+			Kind:             completionKinds[item.Kind],
+			Detail:           item.Detail,
+			SortText:         fmt.Sprintf("%04d", i),
+			InsertTextFormat: format,
+			TextEdit:         &TextEdit{Range: doc.rangeOf(res.Start, offset), NewText: item.InsertText},
+		})
+	}
+	return list
+}
+
+// signatureHelp describes the call enclosing pos, resolving functions
+// across the open documents.
+func (s *Server) signatureHelp(doc *document, pos Position) *SignatureHelp {
+	offset := doc.offset(pos)
+	st := doc.statementAt(offset)
+	h := signature.AtIn(openIndex{s}, st.Tree.Tree, st.Source, doc.point(offset))
+	if h == nil {
+		return nil
+	}
+	info := SignatureInformation{Label: h.Label(), Parameters: []ParameterInformation{}}
+	for i := range h.Params {
+		start, end := h.ParamOffsets(i)
+		info.Parameters = append(info.Parameters, ParameterInformation{Label: [2]uint32{uint32(start), uint32(end)}})
+	}
+	return &SignatureHelp{Signatures: []SignatureInformation{info}, ActiveParameter: uint32(h.Active)}
+}
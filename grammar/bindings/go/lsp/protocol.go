@@ -0,0 +1,383 @@
+package lsp
+
+import "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/semantic"
+
+// The subset of the Language Server Protocol 3.17 types the server uses.
+
+// Position is a zero-based line and character offset, counted in UTF-16
+// code units unless the client and server agreed on another encoding.
+type Position struct {
+	Line      uint32 `json:"line"`
+	Character uint32 `json:"character"`
+}
+
+// Range is a half-open range between two positions.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// DiagnosticSeverity values.
+const (
+	SeverityError       = 1
+	SeverityWarning     = 2
+	SeverityInformation = 3
+	SeverityHint        = 4
+)
+
+// Diagnostic is a problem reported for a range of a document.
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity,omitempty"`
+	Code     string `json:"code,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Message  string `json:"message"`
+}
+
+// PublishDiagnosticsParams is sent with textDocument/publishDiagnostics.
+type PublishDiagnosticsParams struct {
+	URI         string       `json:"uri"`
+	Version     *int32       `json:"version,omitempty"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// ShowMessageParams is sent with window/showMessage.
+type ShowMessageParams struct {
+	Type    int    `json:"type"`
+	Message string `json:"message"`
+}
+
+// messageTypeError is the MessageType of an error message.
+const messageTypeError = 1
+
+// TextDocumentIdentifier names a document.
+type TextDocumentIdentifier struct {
+	URI string `json:"uri"`
+}
+
+// TextDocumentItem is an opened document.
+type TextDocumentItem struct {
+	URI        string `json:"uri"`
+	LanguageID string `json:"languageId"`
+	Version    int32  `json:"version"`
+	Text       string `json:"text"`
+}
+
+// VersionedTextDocumentIdentifier names a document at a version.
+type VersionedTextDocumentIdentifier struct {
+	URI     string `json:"uri"`
+	Version int32  `json:"version"`
+}
+
+// DidOpenTextDocumentParams is sent with textDocument/didOpen.
+type DidOpenTextDocumentParams struct {
+	TextDocument TextDocumentItem `json:"textDocument"`
+}
+
+// TextDocumentContentChangeEvent is a full or ranged document change. A nil
+// Range replaces the entire document.
+type TextDocumentContentChangeEvent struct {
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
+}
+
+// DidChangeTextDocumentParams is sent with textDocument/didChange.
+type DidChangeTextDocumentParams struct {
+	TextDocument   VersionedTextDocumentIdentifier  `json:"textDocument"`
+	ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+}
+
+// DidCloseTextDocumentParams is sent with textDocument/didClose.
+type DidCloseTextDocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// TextDocumentPositionParams identifies a position in a document.
+type TextDocumentPositionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Position     Position               `json:"position"`
+}
+
+// ReferenceParams are the parameters of textDocument/references.
+type ReferenceParams struct {
+	TextDocumentPositionParams
+	Context ReferenceContext `json:"context"`
+}
+
+// ReferenceContext controls whether declarations count as references.
+type ReferenceContext struct {
+	IncludeDeclaration bool `json:"includeDeclaration"`
+}
+
+// Location is a range in a document.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// DocumentParams identifies a document for whole-document requests.
+type DocumentParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+}
+
+// TextEdit replaces a range of a document with NewText.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit is a set of edits to documents, keyed by URI.
+type WorkspaceEdit struct {
+	Changes map[string][]TextEdit `json:"changes"`
+}
+
+// CodeActionKindQuickFix is the kind of code actions that fix a
+// diagnostic.
+const CodeActionKindQuickFix = "quickfix"
+
+// CodeActionParams are the parameters of textDocument/codeAction.
+type CodeActionParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+}
+
+// CodeAction is a change the client can offer for a range, such as a
+// quick fix for the diagnostics it resolves.
+type CodeAction struct {
+	Title       string         `json:"title"`
+	Kind        string         `json:"kind,omitempty"`
+	Diagnostics []Diagnostic   `json:"diagnostics,omitempty"`
+	Edit        *WorkspaceEdit `json:"edit,omitempty"`
+}
+
+// FormattingOptions are the client's preferences for formatting requests.
+type FormattingOptions struct {
+	TabSize      uint32 `json:"tabSize"`
+	InsertSpaces bool   `json:"insertSpaces"`
+}
+
+// DocumentFormattingParams are the parameters of textDocument/formatting.
+type DocumentFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Options      FormattingOptions      `json:"options"`
+}
+
+// DocumentRangeFormattingParams are the parameters of
+// textDocument/rangeFormatting.
+type DocumentRangeFormattingParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Range        Range                  `json:"range"`
+	Options      FormattingOptions      `json:"options"`
+}
+
+// DocumentOnTypeFormattingParams are the parameters of
+// textDocument/onTypeFormatting. Position is just after the typed Ch.
+type DocumentOnTypeFormattingParams struct {
+	TextDocumentPositionParams
+	Ch      string            `json:"ch"`
+	Options FormattingOptions `json:"options"`
+}
+
+// Completion item kinds used by the server.
+const (
+	CompletionItemKindFunction = 3
+	CompletionItemKindVariable = 6
+	CompletionItemKindSnippet  = 15
+	CompletionItemKindOperator = 24
+)
+
+// Insert text formats of a completion item.
+const (
+	InsertTextFormatPlainText = 1
+	InsertTextFormatSnippet   = 2
+)
+
+// CompletionItem is a proposal returned by textDocument/completion.
+type CompletionItem struct {
+	Label            string    `json:"label"`
+	Kind             int       `json:"kind,omitempty"`
+	Detail           string    `json:"detail,omitempty"`
+	SortText         string    `json:"sortText,omitempty"`
+	InsertTextFormat int       `json:"insertTextFormat,omitempty"`
+	TextEdit         *TextEdit `json:"textEdit,omitempty"`
+}
+
+// CompletionList is the result of textDocument/completion.
+type CompletionList struct {
+	IsIncomplete bool             `json:"isIncomplete"`
+	Items        []CompletionItem `json:"items"`
+}
+
+// SignatureHelp is the result of textDocument/signatureHelp.
+type SignatureHelp struct {
+	Signatures      []SignatureInformation `json:"signatures"`
+	ActiveSignature uint32                 `json:"activeSignature"`
+	ActiveParameter uint32                 `json:"activeParameter"`
+}
+
+// SignatureInformation is the signature of a callable.
+type SignatureInformation struct {
+	Label      string                 `json:"label"`
+	Parameters []ParameterInformation `json:"parameters"`
+}
+
+// ParameterInformation is a parameter of a signature. Label holds the start
+// and end offsets of the parameter in the signature's label.
+type ParameterInformation struct {
+	Label [2]uint32 `json:"label"`
+}
+
+// MarkupContent is documentation text in a given format.
+type MarkupContent struct {
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// Hover is the result of textDocument/hover.
+type Hover struct {
+	Contents MarkupContent `json:"contents"`
+	Range    *Range        `json:"range,omitempty"`
+}
+
+// SymbolKind values used by the server.
+const (
+	SymbolKindFunction = 12
+	SymbolKindVariable = 13
+)
+
+// DocumentSymbol is a hierarchical symbol returned by
+// textDocument/documentSymbol.
+type DocumentSymbol struct {
+	Name           string           `json:"name"`
+	Detail         string           `json:"detail,omitempty"`
+	Kind           int              `json:"kind"`
+	Range          Range            `json:"range"`
+	SelectionRange Range            `json:"selectionRange"`
+	Children       []DocumentSymbol `json:"children,omitempty"`
+}
+
+// FoldingRange is a collapsible region returned by textDocument/foldingRange.
+type FoldingRange struct {
+	StartLine      uint32 `json:"startLine"`
+	StartCharacter uint32 `json:"startCharacter,omitempty"`
+	EndLine        uint32 `json:"endLine"`
+	EndCharacter   uint32 `json:"endCharacter,omitempty"`
+	Kind           string `json:"kind,omitempty"`
+}
+
+// SelectionRangeParams are the parameters of textDocument/selectionRange.
+type SelectionRangeParams struct {
+	TextDocument TextDocumentIdentifier `json:"textDocument"`
+	Positions    []Position             `json:"positions"`
+}
+
+// SelectionRange is a range to select and the larger range enclosing it,
+// as returned by textDocument/selectionRange.
+type SelectionRange struct {
+	Range  Range           `json:"range"`
+	Parent *SelectionRange `json:"parent,omitempty"`
+}
+
+// LinkedEditingRanges is the result of textDocument/linkedEditingRange:
+// ranges that change together as one of them is typed in.
+type LinkedEditingRanges struct {
+	Ranges      []Range `json:"ranges"`
+	WordPattern string  `json:"wordPattern,omitempty"`
+}
+
+// SemanticTokens is the result of textDocument/semanticTokens/full.
+type SemanticTokens struct {
+	Data []uint32 `json:"data"`
+}
+
+// InitializeResult is the result of initialize.
+type InitializeResult struct {
+	Capabilities ServerCapabilities `json:"capabilities"`
+	ServerInfo   *ServerInfo        `json:"serverInfo,omitempty"`
+}
+
+// ServerInfo identifies the server.
+type ServerInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+// InitializeParams is the part of the initialize request the server reads.
+type InitializeParams struct {
+	Capabilities ClientCapabilities `json:"capabilities"`
+}
+
+// ClientCapabilities is the part of the client's capabilities the server
+// reads.
+type ClientCapabilities struct {
+	General *GeneralClientCapabilities `json:"general,omitempty"`
+}
+
+// GeneralClientCapabilities lists the position encodings the client
+// supports, most preferred first.
+type GeneralClientCapabilities struct {
+	PositionEncodings []string `json:"positionEncodings,omitempty"`
+}
+
+// ServerCapabilities advertises the features the server implements.
+type ServerCapabilities struct {
+	// PositionEncoding is the encoding the columns of positions are
+	// counted in, chosen from those the client supports.
+	PositionEncoding       string                  `json:"positionEncoding,omitempty"`
+	TextDocumentSync       TextDocumentSyncOptions `json:"textDocumentSync"`
+	HoverProvider          bool                    `json:"hoverProvider"`
+	DocumentSymbolProvider bool                    `json:"documentSymbolProvider"`
+	FoldingRangeProvider   bool                    `json:"foldingRangeProvider"`
+	DefinitionProvider     bool                    `json:"definitionProvider"`
+	ReferencesProvider     bool                    `json:"referencesProvider"`
+	SemanticTokensProvider SemanticTokensOptions   `json:"semanticTokensProvider"`
+	CompletionProvider     *CompletionOptions      `json:"completionProvider,omitempty"`
+	SignatureHelpProvider  *SignatureHelpOptions   `json:"signatureHelpProvider,omitempty"`
+	CodeActionProvider     bool                    `json:"codeActionProvider"`
+	SelectionRangeProvider bool                    `json:"selectionRangeProvider"`
+
+	LinkedEditingRangeProvider      bool `json:"linkedEditingRangeProvider"`
+	DocumentFormattingProvider      bool `json:"documentFormattingProvider"`
+	DocumentRangeFormattingProvider bool `json:"documentRangeFormattingProvider"`
+
+	DocumentOnTypeFormattingProvider *DocumentOnTypeFormattingOptions `json:"documentOnTypeFormattingProvider,omitempty"`
+}
+
+// Text document sync kinds.
+const (
+	SyncNone        = 0
+	SyncFull        = 1
+	SyncIncremental = 2
+)
+
+// TextDocumentSyncOptions describes how documents are synchronised.
+type TextDocumentSyncOptions struct {
+	OpenClose bool `json:"openClose"`
+	Change    int  `json:"change"`
+}
+
+// DocumentOnTypeFormattingOptions lists the characters that trigger
+// textDocument/onTypeFormatting.
+type DocumentOnTypeFormattingOptions struct {
+	FirstTriggerCharacter string   `json:"firstTriggerCharacter"`
+	MoreTriggerCharacter  []string `json:"moreTriggerCharacter,omitempty"`
+}
+
+// CompletionOptions lists the characters, beside those of names, that
+// trigger textDocument/completion.
+type CompletionOptions struct {
+	TriggerCharacters []string `json:"triggerCharacters,omitempty"`
+}
+
+// SignatureHelpOptions lists the characters that trigger
+// textDocument/signatureHelp.
+type SignatureHelpOptions struct {
+	TriggerCharacters   []string `json:"triggerCharacters,omitempty"`
+	RetriggerCharacters []string `json:"retriggerCharacters,omitempty"`
+}
+
+// SemanticTokensOptions advertises semantic token support.
+type SemanticTokensOptions struct {
+	Legend semantic.Legend `json:"legend"`
+	Full   bool            `json:"full"`
+}
@@ -0,0 +1,450 @@
+// Package lsp implements a Language Server Protocol server for wabznasm on
+// top of the tree-sitter bindings.
+//
+// Each message is handled in a span of package telemetry named after its
+// method, within which publishing diagnostics is an "analyze" span.
+// Requests that fail are logged at warn level.
+//
+// A message whose handling panics is answered with an internal error, and
+// the client is shown where its diagnostic bundle (see package crash) was
+// written; the server goes on serving.
+//
+// Besides the protocol's methods the server answers wabznasm/memoryStats,
+// which takes no parameters, with a MemoryStats describing the memory held
+// outside the Go heap, for finding what a long session leaks.
+package lsp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/crash"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/position"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/semantic"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+)
+
+// Version is reported to clients in the initialize response.
+const Version = "0.1.0"
+
+// Server is a language server speaking JSON-RPC over a reader and writer,
+// typically stdin and stdout. Requests are handled one at a time in the
+// order they arrive.
+type Server struct {
+	conn     *conn
+	docs     map[string]*document
+	shutdown bool
+	// ctx is the context of the message being handled.
+	ctx   context.Context
+	crash *crash.Reporter
+	// encoding is the position encoding agreed with the client.
+	encoding position.Encoding
+	// grammar is set by SetGrammar.
+	grammar *grammar
+}
+
+// NewServer returns a server reading requests from r and writing responses
+// and notifications to w.
+func NewServer(r io.Reader, w io.Writer) *Server {
+	// wabznasm/memoryStats reports the C memory of the process.
+	memtrack.EnableCAccounting()
+	return &Server{conn: newConn(r, w), docs: map[string]*document{}, ctx: context.Background(), encoding: position.UTF16}
+}
+
+// SetCrashReporter sets the reporter writing the diagnostic bundles of
+// messages whose handling panics; nil writes them as the zero
+// crash.Reporter does.
+func (s *Server) SetCrashReporter(r *crash.Reporter) { s.crash = r }
+
+// Run serves requests until the client sends exit or closes the input. It
+// returns nil after an orderly shutdown.
+func (s *Server) Run() error {
+	defer s.closeAll()
+	for {
+		body, err := s.conn.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) && s.shutdown {
+				return nil
+			}
+			return err
+		}
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			if err := s.conn.reply(json.RawMessage("null"), nil, &ResponseError{Code: codeParseError, Message: err.Error()}); err != nil {
+				return err
+			}
+			continue
+		}
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("lsp: exit before shutdown")
+			}
+			return nil
+		}
+		var span *telemetry.Span
+		s.ctx, span = telemetry.Start(context.Background(), req.Method)
+		if err := s.reloadGrammar(); err != nil {
+			s.conn.notify("window/showMessage", ShowMessageParams{Type: messageTypeError, Message: "wabznasm-lsp: grammar: " + err.Error()})
+		}
+		result, rerr := s.safeHandle(&req)
+		if rerr != nil {
+			span.End(errors.New(rerr.Message))
+			telemetry.Logger(s.ctx).WarnContext(s.ctx, "request failed",
+				slog.String("method", req.Method),
+				slog.Int("code", rerr.Code),
+				slog.String("error", rerr.Message))
+		} else {
+			span.End(nil)
+		}
+		if req.ID == nil {
+			continue
+		}
+		if err := s.conn.reply(*req.ID, result, rerr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) closeAll() {
+	for uri, doc := range s.docs {
+		doc.close()
+		delete(s.docs, uri)
+	}
+}
+
+// safeHandle is handle, turning a panic into an internal error with a
+// diagnostic bundle.
+func (s *Server) safeHandle(req *request) (result any, rerr *ResponseError) {
+	defer func() {
+		v := recover()
+		if v == nil {
+			return
+		}
+		scope := &crash.Scope{Operation: req.Method, RequestID: telemetry.RequestID(s.ctx)}
+		var p struct {
+			TextDocument TextDocumentIdentifier `json:"textDocument"`
+		}
+		if json.Unmarshal(req.Params, &p) == nil {
+			if doc, ok := s.docs[p.TextDocument.URI]; ok {
+				scope.URI, scope.Source, scope.Tree = doc.uri, doc.source(), doc.tree()
+			}
+		}
+		b, path := s.crash.Report(v, scope)
+		message := fmt.Sprintf("wabznasm-lsp: internal error in %s: %s", req.Method, b.Panic)
+		if path != "" {
+			message += "; please attach " + path + " to a bug report"
+		}
+		s.conn.notify("window/showMessage", ShowMessageParams{Type: messageTypeError, Message: message})
+		result, rerr = nil, &ResponseError{Code: codeInternalError, Message: message}
+	}()
+	return s.handle(req)
+}
+
+func (s *Server) handle(req *request) (any, *ResponseError) {
+	switch req.Method {
+	case "initialize":
+		var p InitializeParams
+		if len(req.Params) > 0 {
+			if rerr := decode(req.Params, &p); rerr != nil {
+				return nil, rerr
+			}
+		}
+		return s.initialize(p), nil
+	case "initialized", "$/cancelRequest", "$/setTrace":
+		return nil, nil
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+	case "textDocument/didOpen":
+		var p DidOpenTextDocumentParams
+		if rerr := decode(req.Params, &p); rerr != nil {
+			return nil, rerr
+		}
+		return nil, s.didOpen(p)
+	case "textDocument/didChange":
+		var p DidChangeTextDocumentParams
+		if rerr := decode(req.Params, &p); rerr != nil {
+			return nil, rerr
+		}
+		return nil, s.didChange(p)
+	case "textDocument/didClose":
+		var p DidCloseTextDocumentParams
+		if rerr := decode(req.Params, &p); rerr != nil {
+			return nil, rerr
+		}
+		return nil, s.didClose(p)
+	case "textDocument/hover":
+		var p TextDocumentPositionParams
+		doc, rerr := s.positionDocument(req.Params, &p)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if h := doc.hover(p.Position); h != nil {
+			return h, nil
+		}
+		return nil, nil
+	case "textDocument/documentSymbol":
+		doc, rerr := s.paramDocument(req.Params)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return doc.documentSymbols(), nil
+	case "textDocument/foldingRange":
+		doc, rerr := s.paramDocument(req.Params)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return doc.foldingRanges(), nil
+	case "textDocument/selectionRange":
+		var p SelectionRangeParams
+		if rerr := decode(req.Params, &p); rerr != nil {
+			return nil, rerr
+		}
+		doc, rerr := s.lookup(p.TextDocument.URI)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return doc.selectionRanges(p.Positions), nil
+	case "textDocument/linkedEditingRange":
+		var p TextDocumentPositionParams
+		doc, rerr := s.positionDocument(req.Params, &p)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if r := doc.linkedEditingRanges(p.Position); r != nil {
+			return r, nil
+		}
+		return nil, nil
+	case "textDocument/definition":
+		var p TextDocumentPositionParams
+		doc, rerr := s.positionDocument(req.Params, &p)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return s.definition(doc, p.Position), nil
+	case "textDocument/references":
+		var p ReferenceParams
+		if rerr := decode(req.Params, &p); rerr != nil {
+			return nil, rerr
+		}
+		doc, rerr := s.lookup(p.TextDocument.URI)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return s.references(doc, p.Position, p.Context.IncludeDeclaration), nil
+	case "textDocument/completion":
+		var p TextDocumentPositionParams
+		doc, rerr := s.positionDocument(req.Params, &p)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return s.completion(doc, p.Position), nil
+	case "textDocument/signatureHelp":
+		var p TextDocumentPositionParams
+		doc, rerr := s.positionDocument(req.Params, &p)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if h := s.signatureHelp(doc, p.Position); h != nil {
+			return h, nil
+		}
+		return nil, nil
+	case "textDocument/formatting":
+		var p DocumentFormattingParams
+		if rerr := decode(req.Params, &p); rerr != nil {
+			return nil, rerr
+		}
+		doc, rerr := s.lookup(p.TextDocument.URI)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return doc.formatting(nil), nil
+	case "textDocument/rangeFormatting":
+		var p DocumentRangeFormattingParams
+		if rerr := decode(req.Params, &p); rerr != nil {
+			return nil, rerr
+		}
+		doc, rerr := s.lookup(p.TextDocument.URI)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return doc.formatting(&p.Range), nil
+	case "textDocument/onTypeFormatting":
+		var p DocumentOnTypeFormattingParams
+		if rerr := decode(req.Params, &p); rerr != nil {
+			return nil, rerr
+		}
+		doc, rerr := s.lookup(p.TextDocument.URI)
+		if rerr != nil {
+			return nil, rerr
+		}
+		if p.Ch == ";" {
+			return doc.formatSeparator(p.Position), nil
+		}
+		return doc.onTypeFormatting(p.Position, p.Options), nil
+	case "textDocument/codeAction":
+		var p CodeActionParams
+		if rerr := decode(req.Params, &p); rerr != nil {
+			return nil, rerr
+		}
+		doc, rerr := s.lookup(p.TextDocument.URI)
+		if rerr != nil {
+			return nil, rerr
+		}
+		return doc.codeActions(p.Range), nil
+	case "textDocument/semanticTokens/full":
+		doc, rerr := s.paramDocument(req.Params)
+		if rerr != nil {
+			return nil, rerr
+		}
+		tokens, err := doc.semanticTokens()
+		if err != nil {
+			return nil, &ResponseError{Code: codeInternalError, Message: err.Error()}
+		}
+		return tokens, nil
+	case "wabznasm/memoryStats":
+		return s.memoryStats(), nil
+	}
+	if req.ID == nil {
+		// Unknown notifications are ignored.
+		return nil, nil
+	}
+	return nil, &ResponseError{Code: codeMethodNotFound, Message: "method not found: " + req.Method}
+}
+
+// initialize answers the initialize request. Positions are counted in the
+// encoding the client prefers most, and in UTF-16, which every client
+// supports, if it lists none.
+func (s *Server) initialize(p InitializeParams) InitializeResult {
+	s.encoding = position.UTF16
+	if g := p.Capabilities.General; g != nil {
+		for _, name := range g.PositionEncodings {
+			if e, ok := position.ParseEncoding(name); ok {
+				s.encoding = e
+				break
+			}
+		}
+	}
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			PositionEncoding:       s.encoding.String(),
+			TextDocumentSync:       TextDocumentSyncOptions{OpenClose: true, Change: SyncIncremental},
+			HoverProvider:          true,
+			DocumentSymbolProvider: true,
+			FoldingRangeProvider:   true,
+			DefinitionProvider:     true,
+			ReferencesProvider:     true,
+			SemanticTokensProvider: SemanticTokensOptions{Legend: semantic.DefaultLegend, Full: true},
+			CompletionProvider:     &CompletionOptions{TriggerCharacters: []string{":", "[", ";"}},
+			SignatureHelpProvider:  &SignatureHelpOptions{TriggerCharacters: []string{"["}, RetriggerCharacters: []string{";"}},
+			CodeActionProvider:     true,
+			SelectionRangeProvider: true,
+
+			LinkedEditingRangeProvider:      true,
+			DocumentFormattingProvider:      true,
+			DocumentRangeFormattingProvider: true,
+			DocumentOnTypeFormattingProvider: &DocumentOnTypeFormattingOptions{
+				FirstTriggerCharacter: "\n",
+				MoreTriggerCharacter:  []string{"}", "]", ")", ";"},
+			},
+		},
+		ServerInfo: &ServerInfo{Name: "wabznasm-lsp", Version: Version},
+	}
+}
+
+func (s *Server) didOpen(p DidOpenTextDocumentParams) *ResponseError {
+	item := p.TextDocument
+	if old, ok := s.docs[item.URI]; ok {
+		old.close()
+	}
+	doc, err := newDocument(item.URI, item.Version, item.Text, s.encoding)
+	if err != nil {
+		return &ResponseError{Code: codeInternalError, Message: err.Error()}
+	}
+	s.docs[item.URI] = doc
+	return s.publish(doc)
+}
+
+func (s *Server) didChange(p DidChangeTextDocumentParams) *ResponseError {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return &ResponseError{Code: codeInvalidParams, Message: "document not open: " + p.TextDocument.URI}
+	}
+	for _, change := range p.ContentChanges {
+		if err := doc.apply(change); err != nil {
+			return &ResponseError{Code: codeInternalError, Message: err.Error()}
+		}
+	}
+	doc.version = p.TextDocument.Version
+	return s.publish(doc)
+}
+
+func (s *Server) didClose(p DidCloseTextDocumentParams) *ResponseError {
+	doc, ok := s.docs[p.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	doc.close()
+	delete(s.docs, p.TextDocument.URI)
+	if err := s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         p.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	}); err != nil {
+		return &ResponseError{Code: codeInternalError, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) publish(doc *document) *ResponseError {
+	version := doc.version
+	_, span := telemetry.Start(s.ctx, "analyze", slog.String("uri", doc.uri), slog.Int("bytes", len(doc.source())))
+	diags := doc.diagnostics()
+	span.SetAttrs(slog.Int("diagnostics", len(diags)))
+	span.End(nil)
+	if err := s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Version:     &version,
+		Diagnostics: diags,
+	}); err != nil {
+		return &ResponseError{Code: codeInternalError, Message: err.Error()}
+	}
+	return nil
+}
+
+func (s *Server) lookup(uri string) (*document, *ResponseError) {
+	doc, ok := s.docs[uri]
+	if !ok {
+		return nil, &ResponseError{Code: codeInvalidParams, Message: "document not open: " + uri}
+	}
+	return doc, nil
+}
+
+func (s *Server) paramDocument(raw json.RawMessage) (*document, *ResponseError) {
+	var p DocumentParams
+	if rerr := decode(raw, &p); rerr != nil {
+		return nil, rerr
+	}
+	return s.lookup(p.TextDocument.URI)
+}
+
+func (s *Server) positionDocument(raw json.RawMessage, p *TextDocumentPositionParams) (*document, *ResponseError) {
+	if rerr := decode(raw, p); rerr != nil {
+		return nil, rerr
+	}
+	return s.lookup(p.TextDocument.URI)
+}
+
+func decode(raw json.RawMessage, v any) *ResponseError {
+	if len(raw) == 0 {
+		return &ResponseError{Code: codeInvalidParams, Message: "missing params"}
+	}
+	if err := json.Unmarshal(raw, v); err != nil {
+		return &ResponseError{Code: codeInvalidParams, Message: fmt.Sprintf("invalid params: %v", err)}
+	}
+	return nil
+}
@@ -0,0 +1,697 @@
+package lsp_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/crash"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lsp"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+// client drives a Server over in-memory pipes.
+type client struct {
+	t      *testing.T
+	w      io.WriteCloser
+	msgs   chan map[string]json.RawMessage
+	nextID int
+	done   chan error
+	// notifications received while waiting for responses, by method.
+	notes map[string][]json.RawMessage
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	return newClientWith(t, nil)
+}
+
+// newClientWith is newClient, calling setup, if set, on the server before
+// it runs.
+func newClientWith(t *testing.T, setup func(*lsp.Server)) *client {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &client{
+		t:     t,
+		w:     inW,
+		msgs:  make(chan map[string]json.RawMessage, 64),
+		done:  make(chan error, 1),
+		notes: map[string][]json.RawMessage{},
+	}
+	// Read server output continuously so server writes never block on the
+	// synchronous pipe while the client is itself writing.
+	go func() {
+		r := bufio.NewReader(outR)
+		defer close(c.msgs)
+		for {
+			header, err := textproto.NewReader(r).ReadMIMEHeader()
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(header.Get("Content-Length"))
+			body := make([]byte, n)
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			var msg map[string]json.RawMessage
+			if err := json.Unmarshal(body, &msg); err != nil {
+				return
+			}
+			c.msgs <- msg
+		}
+	}()
+	srv := lsp.NewServer(inR, outW)
+	if setup != nil {
+		setup(srv)
+	}
+	go func() {
+		err := srv.Run()
+		outW.Close()
+		c.done <- err
+	}()
+	return c
+}
+
+func (c *client) send(msg map[string]any) {
+	c.t.Helper()
+	msg["jsonrpc"] = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+func (c *client) readMessage() map[string]json.RawMessage {
+	c.t.Helper()
+	msg, ok := <-c.msgs
+	if !ok {
+		c.t.Fatal("server closed its output")
+	}
+	return msg
+}
+
+// call sends a request and decodes its result into out, recording any
+// notifications that arrive first.
+func (c *client) call(method string, params any, out any) {
+	c.t.Helper()
+	c.nextID++
+	id := c.nextID
+	c.send(map[string]any{"id": id, "method": method, "params": params})
+	for {
+		msg := c.readMessage()
+		if m, ok := msg["method"]; ok {
+			var name string
+			json.Unmarshal(m, &name)
+			c.notes[name] = append(c.notes[name], msg["params"])
+			continue
+		}
+		if string(msg["id"]) != strconv.Itoa(id) {
+			c.t.Fatalf("unexpected response id %s", msg["id"])
+		}
+		if e, ok := msg["error"]; ok {
+			c.t.Fatalf("%s failed: %s", method, e)
+		}
+		if out != nil {
+			if err := json.Unmarshal(msg["result"], out); err != nil {
+				c.t.Fatal(err)
+			}
+		}
+		return
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.send(map[string]any{"method": method, "params": params})
+}
+
+func (c *client) shutdown() {
+	c.t.Helper()
+	c.call("shutdown", nil, nil)
+	c.notify("exit", nil)
+	c.w.Close()
+	if err := <-c.done; err != nil {
+		c.t.Fatalf("server exited with %v", err)
+	}
+}
+
+func (c *client) lastDiagnostics() lsp.PublishDiagnosticsParams {
+	c.t.Helper()
+	notes := c.notes["textDocument/publishDiagnostics"]
+	if len(notes) == 0 {
+		c.t.Fatal("no diagnostics published")
+	}
+	var p lsp.PublishDiagnosticsParams
+	if err := json.Unmarshal(notes[len(notes)-1], &p); err != nil {
+		c.t.Fatal(err)
+	}
+	return p
+}
+
+const uri = "file:///test.wz"
+
+func open(c *client, text string) {
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "wabznasm", "version": 1, "text": text},
+	})
+}
+
+func doc() map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": uri}}
+}
+
+func TestInitialize(t *testing.T) {
+	c := newClient(t)
+	var res lsp.InitializeResult
+	c.call("initialize", map[string]any{"capabilities": map[string]any{}}, &res)
+	caps := res.Capabilities
+	if !caps.HoverProvider || !caps.DefinitionProvider || !caps.ReferencesProvider || !caps.DocumentSymbolProvider || !caps.FoldingRangeProvider || !caps.SemanticTokensProvider.Full || caps.DocumentOnTypeFormattingProvider == nil || caps.CompletionProvider == nil || caps.SignatureHelpProvider == nil || !caps.CodeActionProvider || !caps.SelectionRangeProvider || !caps.LinkedEditingRangeProvider || !caps.DocumentFormattingProvider || !caps.DocumentRangeFormattingProvider {
+		t.Errorf("missing capabilities: %+v", caps)
+	}
+	if caps.TextDocumentSync.Change != lsp.SyncIncremental {
+		t.Errorf("sync = %d", caps.TextDocumentSync.Change)
+	}
+	c.shutdown()
+}
+
+func TestDiagnosticsFollowEdits(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "f: {x+}")
+
+	var symbols []lsp.DocumentSymbol
+	c.call("textDocument/documentSymbol", doc(), &symbols)
+	diags := c.lastDiagnostics()
+	if len(diags.Diagnostics) == 0 {
+		t.Fatalf("expected diagnostics for broken source")
+	}
+
+	// Fix the body by inserting "1" before the closing brace.
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []map[string]any{{
+			"range": map[string]any{
+				"start": map[string]any{"line": 0, "character": 6},
+				"end":   map[string]any{"line": 0, "character": 6},
+			},
+			"text": "1",
+		}},
+	})
+	c.call("textDocument/documentSymbol", doc(), &symbols)
+	diags = c.lastDiagnostics()
+	if len(diags.Diagnostics) != 0 || *diags.Version != 2 {
+		t.Errorf("diagnostics after fix = %+v", diags)
+	}
+	if len(symbols) != 1 || symbols[0].Name != "f" || symbols[0].Kind != lsp.SymbolKindFunction || symbols[0].Detail != "[x]" {
+		t.Errorf("symbols = %+v", symbols)
+	}
+	c.shutdown()
+}
+
+func TestCodeActions(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "f: {[a;b] g[b;1}")
+
+	var actions []lsp.CodeAction
+	whole := map[string]any{
+		"start": map[string]any{"line": 0, "character": 0},
+		"end":   map[string]any{"line": 0, "character": 16},
+	}
+	c.call("textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        whole,
+		"context":      map[string]any{"diagnostics": []any{}},
+	}, &actions)
+	var titles []string
+	for _, a := range actions {
+		titles = append(titles, a.Title)
+		if a.Kind != lsp.CodeActionKindQuickFix || len(a.Diagnostics) != 1 || a.Edit == nil || len(a.Edit.Changes[uri]) == 0 {
+			t.Errorf("action %+v", a)
+		}
+	}
+	want := []string{`insert "]"`, "remove parameter a (callers must drop its argument)"}
+	if !slices.Equal(titles, want) {
+		t.Fatalf("titles = %q, want %q", titles, want)
+	}
+	edit := actions[0].Edit.Changes[uri][0]
+	if edit.NewText != "]" || edit.Range.Start != (lsp.Position{Line: 0, Character: 15}) || edit.Range.End != edit.Range.Start {
+		t.Errorf("edit = %+v", edit)
+	}
+
+	// A range away from both problems offers nothing.
+	c.call("textDocument/codeAction", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range": map[string]any{
+			"start": map[string]any{"line": 0, "character": 0},
+			"end":   map[string]any{"line": 0, "character": 1},
+		},
+		"context": map[string]any{"diagnostics": []any{}},
+	}, &actions)
+	if len(actions) != 0 {
+		t.Errorf("actions away from problems = %+v", actions)
+	}
+	c.shutdown()
+}
+
+func TestHover(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "add: {[x;y] x+y}")
+
+	hoverAt := func(char int) string {
+		var h *lsp.Hover
+		c.call("textDocument/hover", map[string]any{
+			"textDocument": map[string]any{"uri": uri},
+			"position":     map[string]any{"line": 0, "character": char},
+		}, &h)
+		if h == nil {
+			return ""
+		}
+		return strings.Trim(strings.TrimPrefix(h.Contents.Value, "```wabznasm"), "`\n")
+	}
+	if got := hoverAt(1); got != "(function) add[x;y]" {
+		t.Errorf("hover on name = %q", got)
+	}
+	if got := hoverAt(12); got != "(parameter) x" {
+		t.Errorf("hover on reference = %q", got)
+	}
+	if got := hoverAt(3); got != "" {
+		t.Errorf("hover on operator = %q", got)
+	}
+	c.shutdown()
+}
+
+func TestHoverDocComment(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "\\ Adds two numbers.\nadd: {[x;y] x+y}")
+
+	var h *lsp.Hover
+	c.call("textDocument/hover", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 1, "character": 1},
+	}, &h)
+	if want := "```wabznasm\n(function) add[x;y]\n```\n\nAdds two numbers."; h == nil || h.Contents.Value != want {
+		t.Errorf("hover = %+v, want %q", h, want)
+	}
+	c.shutdown()
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "add: {[x;y] x+y}")
+	const other = "file:///use.wz"
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": other, "languageId": "wabznasm", "version": 1, "text": "add[1;add[2;3]]"},
+	})
+	at := func(u string, char int) map[string]any {
+		return map[string]any{
+			"textDocument": map[string]any{"uri": u},
+			"position":     map[string]any{"line": 0, "character": char},
+		}
+	}
+
+	var locs []lsp.Location
+	c.call("textDocument/definition", at(other, 7), &locs)
+	if len(locs) != 1 || locs[0].URI != uri || locs[0].Range.End.Character != 3 {
+		t.Errorf("definition of add = %+v", locs)
+	}
+	c.call("textDocument/definition", at(uri, 14), &locs)
+	if len(locs) != 1 || locs[0].URI != uri || locs[0].Range.Start.Character != 9 {
+		t.Errorf("definition of y = %+v", locs)
+	}
+
+	params := at(uri, 1)
+	params["context"] = map[string]any{"includeDeclaration": true}
+	c.call("textDocument/references", params, &locs)
+	if len(locs) != 3 || locs[0].URI != uri || locs[1].URI != other || locs[2].Range.Start.Character != 6 {
+		t.Errorf("references of add = %+v", locs)
+	}
+	c.shutdown()
+}
+
+func TestFoldingAndSemanticTokens(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "f: {\n  x+1\n}")
+
+	var folds []lsp.FoldingRange
+	c.call("textDocument/foldingRange", doc(), &folds)
+	if len(folds) != 1 || folds[0].StartLine != 0 || folds[0].EndLine != 2 {
+		t.Errorf("folds = %+v", folds)
+	}
+
+	var tokens lsp.SemanticTokens
+	c.call("textDocument/semanticTokens/full", doc(), &tokens)
+	if len(tokens.Data) == 0 || len(tokens.Data)%5 != 0 {
+		t.Errorf("tokens = %v", tokens.Data)
+	}
+	c.shutdown()
+}
+
+func TestSelectionRange(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "f: {x+1}")
+
+	var ranges []lsp.SelectionRange
+	c.call("textDocument/selectionRange", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"positions":    []map[string]any{{"line": 0, "character": 4}},
+	}, &ranges)
+	var got []uint32
+	if len(ranges) == 1 {
+		for r := &ranges[0]; r != nil; r = r.Parent {
+			got = append(got, r.Range.Start.Character, r.Range.End.Character)
+		}
+	}
+	// x, x+1, {x+1}, the whole source.
+	if want := []uint32{4, 5, 4, 7, 3, 8, 0, 8}; !slices.Equal(got, want) {
+		t.Errorf("selection ranges %v, want %v", got, want)
+	}
+	c.shutdown()
+}
+
+func TestLinkedEditingRange(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "f: {[a;b] a*b+a}")
+
+	at := func(character int) map[string]any {
+		return map[string]any{"textDocument": map[string]any{"uri": uri}, "position": map[string]any{"line": 0, "character": character}}
+	}
+	var linked *lsp.LinkedEditingRanges
+	c.call("textDocument/linkedEditingRange", at(5), &linked)
+	var got []uint32
+	if linked != nil {
+		for _, r := range linked.Ranges {
+			got = append(got, r.Start.Character)
+		}
+	}
+	if want := []uint32{5, 10, 14}; !slices.Equal(got, want) {
+		t.Errorf("linked ranges start at %v, want %v", got, want)
+	}
+	linked = nil
+	c.call("textDocument/linkedEditingRange", at(0), &linked)
+	if linked != nil {
+		t.Errorf("global f linked: %+v", linked)
+	}
+	c.shutdown()
+}
+
+func TestFormatting(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "x: f[ 1 ; 2 ] + g[ 3 ; 4 ]")
+
+	var edits []lsp.TextEdit
+	c.call("textDocument/formatting", map[string]any{"textDocument": map[string]any{"uri": uri}, "options": map[string]any{"tabSize": 2}}, &edits)
+	// Ten gaps lose their spaces and a final newline is added.
+	if len(edits) != 11 {
+		t.Errorf("formatting edits = %+v", edits)
+	}
+
+	c.call("textDocument/rangeFormatting", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"range":        map[string]any{"start": map[string]any{"line": 0, "character": 3}, "end": map[string]any{"line": 0, "character": 13}},
+		"options":      map[string]any{"tabSize": 2},
+	}, &edits)
+	for _, e := range edits {
+		if e.Range.Start.Character < 3 || e.Range.End.Character > 13 || e.NewText != "" {
+			t.Errorf("range formatting edit %+v outside f[ 1 ; 2 ]", e)
+		}
+	}
+	if len(edits) != 4 {
+		t.Errorf("range formatting edits = %+v", edits)
+	}
+
+	p := doc()
+	p["position"] = map[string]any{"line": 0, "character": 22}
+	p["ch"] = ";"
+	p["options"] = map[string]any{"tabSize": 2}
+	c.call("textDocument/onTypeFormatting", p, &edits)
+	for _, e := range edits {
+		if e.Range.Start.Character < 16 {
+			t.Errorf("edit %+v outside g[ 3 ; 4 ]", e)
+		}
+	}
+	if len(edits) != 4 {
+		t.Errorf("edits after ; = %+v", edits)
+	}
+	c.shutdown()
+}
+
+func TestOnTypeFormatting(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "f: {\nx+1\n  }")
+
+	params := func(line int, ch string) map[string]any {
+		p := doc()
+		p["position"] = map[string]any{"line": line, "character": 1}
+		p["ch"] = ch
+		p["options"] = map[string]any{"tabSize": 4, "insertSpaces": true}
+		return p
+	}
+	var edits []lsp.TextEdit
+	c.call("textDocument/onTypeFormatting", params(1, "\n"), &edits)
+	if len(edits) != 1 || edits[0].NewText != "    " || edits[0].Range.Start.Line != 1 || edits[0].Range.End.Character != 0 {
+		t.Errorf("edits after newline = %+v", edits)
+	}
+	c.call("textDocument/onTypeFormatting", params(2, "}"), &edits)
+	if len(edits) != 1 || edits[0].NewText != "" || edits[0].Range.End.Character != 2 {
+		t.Errorf("edits after brace = %+v", edits)
+	}
+	c.shutdown()
+}
+
+func TestCompletion(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "sum: {[total;tail] t}")
+	const other = "file:///lib.wz"
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": other, "languageId": "wabznasm", "version": 1, "text": "tax: 0.2"},
+	})
+
+	var list lsp.CompletionList
+	c.call("textDocument/completion", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 0, "character": 20},
+	}, &list)
+	var labels []string
+	for _, item := range list.Items {
+		labels = append(labels, item.Label)
+	}
+	if got := strings.Join(labels, " "); got != "tail total tax" {
+		t.Fatalf("labels = %q", got)
+	}
+	first := list.Items[0]
+	if first.Kind != lsp.CompletionItemKindVariable || first.TextEdit == nil || first.TextEdit.Range.Start.Character != 19 || first.TextEdit.NewText != "tail" {
+		t.Errorf("first item = %+v", first)
+	}
+	if list.Items[0].SortText >= list.Items[2].SortText {
+		t.Errorf("sort texts = %q, %q", list.Items[0].SortText, list.Items[2].SortText)
+	}
+	c.shutdown()
+}
+
+func TestSignatureHelp(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "add[1;")
+	const lib = "file:///lib.wz"
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": lib, "languageId": "wabznasm", "version": 1, "text": "add: {[x;y] x+y}"},
+	})
+
+	var h *lsp.SignatureHelp
+	c.call("textDocument/signatureHelp", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 0, "character": 6},
+	}, &h)
+	if h == nil || len(h.Signatures) != 1 || h.Signatures[0].Label != "add[x;y]" || h.ActiveParameter != 1 {
+		t.Fatalf("signature help = %+v", h)
+	}
+	if p := h.Signatures[0].Parameters[1].Label; p != [2]uint32{6, 7} {
+		t.Errorf("label of y = %v", p)
+	}
+	c.call("textDocument/signatureHelp", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": 0, "character": 0},
+	}, &h)
+	if h != nil {
+		t.Errorf("signature help outside a call = %+v", h)
+	}
+	c.shutdown()
+}
+
+func TestUnknownRequest(t *testing.T) {
+	c := newClient(t)
+	c.nextID++
+	c.send(map[string]any{"id": c.nextID, "method": "workspace/bogus"})
+	msg := c.readMessage()
+	var e lsp.ResponseError
+	if err := json.Unmarshal(msg["error"], &e); err != nil || e.Code != -32601 {
+		t.Errorf("error = %s", msg["error"])
+	}
+	c.shutdown()
+}
+
+// panicRule panics on documents mentioning kaboom.
+type panicRule struct{}
+
+func (panicRule) Name() string { return "test-panic" }
+func (panicRule) Doc() string  { return "panics on kaboom" }
+func (panicRule) Check(_ *tree_sitter.Tree, src []byte) []lint.Finding {
+	if strings.Contains(string(src), "kaboom") {
+		panic("rule exploded")
+	}
+	return nil
+}
+
+func TestPanicRecovery(t *testing.T) {
+	lint.Register(panicRule{})
+	dir := t.TempDir()
+	c := newClientWith(t, func(s *lsp.Server) { s.SetCrashReporter(&crash.Reporter{Dir: dir}) })
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "kaboom: 1")
+	var symbols []lsp.DocumentSymbol
+	c.call("textDocument/documentSymbol", doc(), &symbols)
+	if len(symbols) != 1 {
+		t.Errorf("symbols after a panic = %+v", symbols)
+	}
+
+	shown := c.notes["window/showMessage"]
+	if len(shown) != 1 {
+		t.Fatalf("showMessage = %s", shown)
+	}
+	var msg lsp.ShowMessageParams
+	json.Unmarshal(shown[0], &msg)
+	if msg.Type != 1 || !strings.Contains(msg.Message, "rule exploded") || !strings.Contains(msg.Message, dir) {
+		t.Errorf("message = %+v", msg)
+	}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(paths) != 1 {
+		t.Fatalf("bundles = %v", paths)
+	}
+	data, _ := os.ReadFile(paths[0])
+	var b crash.Bundle
+	if err := json.Unmarshal(data, &b); err != nil || b.Operation != "textDocument/didOpen" || b.URI != uri || b.Source.Bytes != 9 || b.Tree == "" {
+		t.Errorf("bundle = %+v, %v", b, err)
+	}
+	c.shutdown()
+}
+
+func TestPositionEncoding(t *testing.T) {
+	tests := []struct {
+		offered []string
+		want    string
+		end     uint32 // end of the error covering 😀
+	}{
+		{nil, "utf-16", 5},
+		{[]string{"utf-8", "utf-16"}, "utf-8", 7},
+		{[]string{"latin1", "utf-32"}, "utf-32", 4},
+	}
+	for _, tt := range tests {
+		c := newClient(t)
+		var res lsp.InitializeResult
+		c.call("initialize", map[string]any{"capabilities": map[string]any{"general": map[string]any{"positionEncodings": tt.offered}}}, &res)
+		if res.Capabilities.PositionEncoding != tt.want {
+			t.Errorf("offered %v: encoding %q, want %q", tt.offered, res.Capabilities.PositionEncoding, tt.want)
+		}
+		open(c, "g: 😀 1+1")
+		var symbols []lsp.DocumentSymbol
+		c.call("textDocument/documentSymbol", doc(), &symbols)
+		diags := c.lastDiagnostics().Diagnostics
+		if len(diags) != 1 || diags[0].Range.Start.Character != 3 || diags[0].Range.End.Character != tt.end {
+			t.Errorf("%s: diagnostics %+v, want one ending at %d", tt.want, diags, tt.end)
+		}
+		c.shutdown()
+	}
+}
+
+func TestSetGrammar(t *testing.T) {
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+	lib := filepath.Join(t.TempDir(), "calc.so")
+	if out, err := exec.Command(cc, "-shared", "-fPIC", "-O0", "-I../../../src", "-o", lib, "../../../src/parser.c").CombinedOutput(); err != nil {
+		t.Skipf("cannot build the parser: %v\n%s", err, out)
+	}
+	t.Cleanup(func() { tree_sitter_wabznasm.UseLanguage(nil) })
+
+	var setErr error
+	c := newClientWith(t, func(s *lsp.Server) { setErr = s.SetGrammar(lib) })
+	if setErr != nil {
+		t.Fatal(setErr)
+	}
+	if tree_sitter_wabznasm.Language() == tree_sitter_wabznasm.BuiltinLanguage() {
+		t.Fatal("SetGrammar did not load the grammar")
+	}
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "f: {x+1}")
+	var symbols []lsp.DocumentSymbol
+	c.call("textDocument/documentSymbol", doc(), &symbols)
+	if len(symbols) != 1 || len(c.lastDiagnostics().Diagnostics) != 0 {
+		t.Errorf("symbols = %+v", symbols)
+	}
+
+	// A broken rebuild is reported, and the server keeps its grammar.
+	if err := os.WriteFile(lib, []byte("not an object"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c.call("textDocument/documentSymbol", doc(), &symbols)
+	shown := c.notes["window/showMessage"]
+	if len(shown) != 1 {
+		t.Fatalf("showMessage = %s", shown)
+	}
+	var msg lsp.ShowMessageParams
+	json.Unmarshal(shown[0], &msg)
+	if msg.Type != 1 || !strings.Contains(msg.Message, "calc.so") {
+		t.Errorf("message = %+v", msg)
+	}
+	if len(symbols) != 1 {
+		t.Errorf("symbols after a failed reload = %+v", symbols)
+	}
+	c.shutdown()
+
+	if err := lsp.NewServer(strings.NewReader(""), io.Discard).SetGrammar(filepath.Join(t.TempDir(), "none.so")); err == nil {
+		t.Error("no error for a missing grammar")
+	}
+}
+
+func TestMemoryStats(t *testing.T) {
+	c := newClient(t)
+	c.call("initialize", map[string]any{}, nil)
+	open(c, "f: {x+1}")
+	var stats lsp.MemoryStats
+	c.call("wabznasm/memoryStats", nil, &stats)
+	if stats.Documents != 1 || stats.Objects["parser"].Live < 1 || stats.Objects["tree"].Live < 1 {
+		t.Errorf("stats = %+v", stats)
+	}
+	c.shutdown()
+}
@@ -0,0 +1,166 @@
+//go:build cgo && linux
+
+package memtrack
+
+/*
+#include <malloc.h>
+#include <pthread.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+// The blocks the hooks allocated, with their sizes, in a chained hash
+// table. The library also frees blocks it did not allocate through the
+// hooks, such as the strings go-tree-sitter makes with C.CString, and
+// those must not be counted.
+typedef struct entry {
+	void *p;
+	size_t size;
+	struct entry *next;
+} entry;
+
+static pthread_mutex_t mu = PTHREAD_MUTEX_INITIALIZER;
+static entry **buckets;
+static size_t nbuckets, nentries;
+static long long live_bytes, live_allocs;
+
+static size_t slot(void *p, size_t n) {
+	uint64_t x = (uint64_t)(uintptr_t)p * 0x9E3779B97F4A7C15ull;
+	return (size_t)(x ^ (x >> 32)) & (n - 1);
+}
+
+static void grow(void) {
+	size_t n = nbuckets ? 2 * nbuckets : 1024;
+	entry **b = calloc(n, sizeof *b);
+	if (!b) return;
+	for (size_t i = 0; i < nbuckets; i++) {
+		for (entry *e = buckets[i], *next; e; e = next) {
+			next = e->next;
+			size_t s = slot(e->p, n);
+			e->next = b[s];
+			b[s] = e;
+		}
+	}
+	free(buckets);
+	buckets = b;
+	nbuckets = n;
+}
+
+// add counts p, which the hooks allocated. mu is held.
+static void add(void *p) {
+	if (nentries >= nbuckets) grow();
+	entry *e = malloc(sizeof *e);
+	if (!e || !nbuckets) {
+		free(e);
+		return;
+	}
+	e->p = p;
+	e->size = malloc_usable_size(p);
+	size_t s = slot(p, nbuckets);
+	e->next = buckets[s];
+	buckets[s] = e;
+	nentries++;
+	live_bytes += e->size;
+	live_allocs++;
+}
+
+// drop stops counting p, if the hooks allocated it, and reports whether
+// they did. mu is held.
+static int drop(void *p) {
+	if (!nbuckets) return 0;
+	for (entry **ep = &buckets[slot(p, nbuckets)]; *ep; ep = &(*ep)->next) {
+		if ((*ep)->p == p) {
+			entry *e = *ep;
+			*ep = e->next;
+			nentries--;
+			live_bytes -= e->size;
+			live_allocs--;
+			free(e);
+			return 1;
+		}
+	}
+	return 0;
+}
+
+static void *counted(void *p) {
+	if (p) {
+		pthread_mutex_lock(&mu);
+		add(p);
+		pthread_mutex_unlock(&mu);
+	}
+	return p;
+}
+
+static void *wz_malloc(size_t n) { return counted(malloc(n)); }
+static void *wz_calloc(size_t n, size_t size) { return counted(calloc(n, size)); }
+
+static void wz_free(void *p) {
+	if (p) {
+		pthread_mutex_lock(&mu);
+		drop(p);
+		pthread_mutex_unlock(&mu);
+	}
+	free(p);
+}
+
+// The old block is dropped before realloc, while no other thread can be
+// given its address.
+static void *wz_realloc(void *old, size_t n) {
+	pthread_mutex_lock(&mu);
+	int had = old && drop(old);
+	void *p = realloc(old, n);
+	if (p) add(p);
+	else if (had && n != 0) add(old);
+	pthread_mutex_unlock(&mu);
+	return p;
+}
+
+static long long wz_live_bytes(void) {
+	pthread_mutex_lock(&mu);
+	long long n = live_bytes;
+	pthread_mutex_unlock(&mu);
+	return n;
+}
+
+static long long wz_live_allocs(void) {
+	pthread_mutex_lock(&mu);
+	long long n = live_allocs;
+	pthread_mutex_unlock(&mu);
+	return n;
+}
+*/
+import "C"
+
+import (
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+var (
+	installOnce sync.Once
+	installed   atomic.Bool
+)
+
+// enableC installs the counting allocator. Blocks allocated before, and
+// so freed through it without its having allocated them, are not
+// counted.
+func enableC() {
+	installOnce.Do(func() {
+		tree_sitter.SetAllocator(
+			func(size uint) unsafe.Pointer { return C.wz_malloc(C.size_t(size)) },
+			func(num, size uint) unsafe.Pointer { return C.wz_calloc(C.size_t(num), C.size_t(size)) },
+			func(ptr unsafe.Pointer, size uint) unsafe.Pointer { return C.wz_realloc(ptr, C.size_t(size)) },
+			func(ptr unsafe.Pointer) { C.wz_free(ptr) },
+		)
+		installed.Store(true)
+	})
+}
+
+func cBytes() (bytes, allocs int64, ok bool) {
+	if !installed.Load() {
+		return 0, 0, false
+	}
+	return int64(C.wz_live_bytes()), int64(C.wz_live_allocs()), true
+}
@@ -0,0 +1,9 @@
+//go:build !(cgo && linux)
+
+package memtrack
+
+// enableC and cBytes do nothing where the allocator cannot measure the
+// blocks it frees.
+func enableC() {}
+
+func cBytes() (bytes, allocs int64, ok bool) { return 0, 0, false }
@@ -0,0 +1,25 @@
+//go:build !(js && wasm)
+
+package memtrack
+
+import (
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// TrackedTreeCursor is a tree cursor whose Close is accounted for.
+type TrackedTreeCursor struct {
+	*tree_sitter.TreeCursor
+	h *Handle
+}
+
+// Walk returns a tracked cursor at n.
+func Walk(n *tree_sitter.Node) *TrackedTreeCursor {
+	return &TrackedTreeCursor{n.Walk(), Open(TreeCursor)}
+}
+
+// Close releases the cursor. Calling Close more than once is a no-op.
+func (c *TrackedTreeCursor) Close() {
+	if c.h.Close() {
+		c.TreeCursor.Close()
+	}
+}
@@ -0,0 +1,241 @@
+// Package memtrack accounts for the tree-sitter objects that hold memory
+// outside the Go heap: parsers, trees, queries and cursors. The garbage
+// collector does not see that memory, so an object that is dropped
+// without being closed leaks it, and a process that does so steadily,
+// such as a language server open for days, grows without its Go heap
+// profile saying why.
+//
+// Every tracked object holds a Handle, opened when the object is made and
+// closed with it; Read reports how many of each kind are live. The Parser
+// of the bindings is tracked, and returns its trees and queries wrapped by
+// NewTree and NewQuery; the bindings make their cursors with
+// NewQueryCursor and Walk. These counts are cheap and always kept. Where
+// the runtime allows, and once EnableCAccounting has been called, Read
+// also reports the bytes the C library holds, which include the objects
+// nothing tracks.
+//
+// With DetectLeaks on, each new Handle records where it was opened, and a
+// handle that is garbage collected while still open is reported by Leaks.
+// Tests use Main to fail on the leaks of a test binary, and Check for
+// those of one test:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(memtrack.Main(m.Run))
+//	}
+//
+//	func TestSomething(t *testing.T) {
+//		defer memtrack.Check(t)
+//		...
+//	}
+package memtrack
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kind is a kind of tracked object.
+type Kind int
+
+const (
+	Parser Kind = iota
+	Tree
+	Query
+	QueryCursor
+	TreeCursor
+	numKinds
+)
+
+var kindNames = [numKinds]string{"parser", "tree", "query", "query_cursor", "tree_cursor"}
+
+func (k Kind) String() string {
+	if k < 0 || k >= numKinds {
+		return fmt.Sprintf("Kind(%d)", int(k))
+	}
+	return kindNames[k]
+}
+
+var (
+	opened [numKinds]atomic.Int64
+	closed [numKinds]atomic.Int64
+)
+
+// Handle is the account of one object. Its methods are safe for
+// concurrent use, and are no-ops on a nil Handle.
+type Handle struct {
+	kind   Kind
+	closed atomic.Bool
+	// stack is where the handle was opened, if leaks are being detected.
+	stack []uintptr
+}
+
+// Open accounts for a new object of kind. The caller must Close the
+// handle when it releases the object.
+func Open(kind Kind) *Handle {
+	opened[kind].Add(1)
+	h := &Handle{kind: kind}
+	if detecting.Load() {
+		pcs := make([]uintptr, 32)
+		h.stack = pcs[:runtime.Callers(2, pcs)]
+		runtime.SetFinalizer(h, finalize)
+	}
+	return h
+}
+
+// Close accounts for the release of the object, and reports whether this
+// was the first Close, so that wrappers can release the object only once.
+func (h *Handle) Close() bool {
+	if h == nil || !h.closed.CompareAndSwap(false, true) {
+		return false
+	}
+	closed[h.kind].Add(1)
+	return true
+}
+
+// Counts are the objects of one kind made and released so far.
+type Counts struct {
+	Opened int64 `json:"opened"`
+	Closed int64 `json:"closed"`
+	Live   int64 `json:"live"`
+}
+
+// Stats are the tracked objects and C memory of the process.
+type Stats struct {
+	// Objects counts the objects of each kind, by Kind.String.
+	Objects map[string]Counts `json:"objects"`
+	// Bytes is the memory the tree-sitter C library holds, and Allocs the
+	// number of its allocations, if BytesTracked reports that
+	// EnableCAccounting was called and the runtime lets them be measured.
+	Bytes        int64 `json:"bytes"`
+	Allocs       int64 `json:"allocs"`
+	BytesTracked bool  `json:"bytes_tracked"`
+}
+
+// Read returns the current Stats.
+func Read() Stats {
+	s := Stats{Objects: make(map[string]Counts, numKinds)}
+	for k := Kind(0); k < numKinds; k++ {
+		c := Counts{Closed: closed[k].Load()}
+		c.Opened = opened[k].Load()
+		c.Live = c.Opened - c.Closed
+		s.Objects[k.String()] = c
+	}
+	s.Bytes, s.Allocs, s.BytesTracked = cBytes()
+	return s
+}
+
+// Live returns the number of live objects of kind.
+func Live(kind Kind) int64 {
+	return opened[kind].Load() - closed[kind].Load()
+}
+
+var (
+	detecting atomic.Bool
+	leakMu    sync.Mutex
+	leaks     []Leak
+)
+
+// Leak is an object garbage collected without being closed.
+type Leak struct {
+	Kind Kind
+	// Stack is where the object was made.
+	Stack string
+}
+
+func (l Leak) String() string {
+	return fmt.Sprintf("%s leaked, made at\n%s", l.Kind, l.Stack)
+}
+
+// DetectLeaks turns the recording of leaks on or off, for the handles
+// opened afterwards. Recording costs a stack trace and a finalizer per
+// object, so it is meant for tests and debugging.
+func DetectLeaks(on bool) {
+	detecting.Store(on)
+}
+
+func finalize(h *Handle) {
+	if h.closed.Load() {
+		return
+	}
+	var b strings.Builder
+	frames := runtime.CallersFrames(h.stack)
+	for {
+		f, more := frames.Next()
+		fmt.Fprintf(&b, "\t%s\n\t\t%s:%d\n", f.Function, f.File, f.Line)
+		if !more {
+			break
+		}
+	}
+	leakMu.Lock()
+	leaks = append(leaks, Leak{Kind: h.kind, Stack: b.String()})
+	leakMu.Unlock()
+}
+
+// Leaks collects garbage, so that unreachable handles are finalized, and
+// returns and forgets the leaks found since the last call.
+func Leaks() []Leak {
+	// Finalizers run on a goroutine of their own after a collection;
+	// a second collection and a short wait let them finish.
+	runtime.GC()
+	runtime.GC()
+	time.Sleep(10 * time.Millisecond)
+	leakMu.Lock()
+	defer leakMu.Unlock()
+	out := leaks
+	leaks = nil
+	return out
+}
+
+// TB is the part of testing.TB that Check uses.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Check reports each leak found by Leaks as an error of t.
+func Check(t TB) {
+	t.Helper()
+	for _, l := range Leaks() {
+		t.Errorf("memtrack: %s", l)
+	}
+}
+
+// EnableCAccounting has the C library allocate through hooks that count
+// the bytes it holds, for Read to report. The hooks serialize every
+// allocation, slowing parsing down by a third or more, so they are meant
+// for long-running processes that report their memory, such as the
+// language server, and for tests; a command that parses and exits does
+// without. Blocks allocated before the call are not counted. Calling it
+// again is a no-op.
+func EnableCAccounting() { enableC() }
+
+// Main turns DetectLeaks and EnableCAccounting on, calls run, such as the Run method of a
+// testing.M, and Checks for leaks afterwards. It returns the exit status
+// of run, or 1 if it passed and leaks were found, having printed them to
+// standard error.
+func Main(run func() int) int {
+	DetectLeaks(true)
+	EnableCAccounting()
+	code := run()
+	var t stderrTB
+	Check(&t)
+	if t.failed && code == 0 {
+		code = 1
+	}
+	return code
+}
+
+// stderrTB is the TB of Main.
+type stderrTB struct{ failed bool }
+
+func (*stderrTB) Helper() {}
+
+func (t *stderrTB) Errorf(format string, args ...any) {
+	t.failed = true
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
@@ -0,0 +1,142 @@
+package memtrack_test
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/bench"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestCounts(t *testing.T) {
+	parsers, trees := memtrack.Live(memtrack.Parser), memtrack.Live(memtrack.Tree)
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The trees a Parser returns are tracked.
+	tree, err := parser.ParseString("f: {x+1}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, err := tree_sitter_wabznasm.NewIncrementalDocument([]byte("1+2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.ApplyEdit(0, 1, 1, []byte("3")); err != nil {
+		t.Fatal(err)
+	}
+	if got := memtrack.Live(memtrack.Parser) - parsers; got != 2 {
+		t.Errorf("%d live parsers, want 2", got)
+	}
+	// The document holds the tree of its text and that of its statement.
+	if got := memtrack.Live(memtrack.Tree) - trees; got != 3 {
+		t.Errorf("%d live trees, want 3", got)
+	}
+
+	tree.Close()
+	tree.Close()
+	doc.Close()
+	parser.Close()
+	parser.Close()
+	if memtrack.Live(memtrack.Parser) != parsers || memtrack.Live(memtrack.Tree) != trees {
+		t.Errorf("live after Close: %+v", memtrack.Read().Objects)
+	}
+	if c := memtrack.Read().Objects["parser"]; c.Live != c.Opened-c.Closed || c.Opened < 2 {
+		t.Errorf("parser counts %+v", c)
+	}
+}
+
+func TestBytes(t *testing.T) {
+	memtrack.EnableCAccounting()
+	before := memtrack.Read()
+	if !before.BytesTracked {
+		t.Skip("C memory is not measured on " + runtime.GOOS)
+	}
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseBytes(bench.Chain(2000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	during := memtrack.Read()
+	tree.Close()
+	after := memtrack.Read()
+	if during.Bytes-before.Bytes < 10000 || during.Allocs <= before.Allocs {
+		t.Errorf("a large tree holds %d bytes in %d allocations", during.Bytes-before.Bytes, during.Allocs-before.Allocs)
+	}
+	if after.Bytes >= during.Bytes {
+		t.Errorf("closing the tree left %d of %d bytes", after.Bytes, during.Bytes)
+	}
+}
+
+func TestBytesBalance(t *testing.T) {
+	memtrack.EnableCAccounting()
+	if !memtrack.Read().BytesTracked {
+		t.Skip("C memory is not measured on " + runtime.GOOS)
+	}
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Reading the source allocates the strings go-tree-sitter frees
+	// through the hooks without allocating them there. A first parse
+	// grows the parser's buffers, which it keeps.
+	parse := func() {
+		tree, err := parser.ParseString("f: {[a;b] a*b+1}")
+		if err != nil {
+			t.Fatal(err)
+		}
+		tree.Close()
+	}
+	parse()
+	before := memtrack.Read()
+	for range 100 {
+		parse()
+	}
+	after := memtrack.Read()
+	parser.Close()
+	if after.Bytes != before.Bytes || after.Allocs != before.Allocs {
+		t.Errorf("after 100 parses: %d bytes in %d allocations, want %d in %d", after.Bytes, after.Allocs, before.Bytes, before.Allocs)
+	}
+	if s := memtrack.Read(); s.Bytes < 0 || s.Allocs < 0 {
+		t.Errorf("counters went negative: %d bytes in %d allocations", s.Bytes, s.Allocs)
+	}
+}
+
+// recorder collects the errors of Check.
+type recorder struct{ errors []string }
+
+func (r *recorder) Helper() {}
+func (r *recorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+//go:noinline
+func leakCursor() {
+	memtrack.NewQueryCursor()
+}
+
+func TestLeaks(t *testing.T) {
+	memtrack.DetectLeaks(true)
+	defer memtrack.DetectLeaks(false)
+	memtrack.Leaks()
+
+	closed := memtrack.NewQueryCursor()
+	closed.Close()
+	leakCursor()
+	var r recorder
+	memtrack.Check(&r)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "query_cursor leaked") || !strings.Contains(r.errors[0], "leakCursor") {
+		t.Errorf("Check reported %q", r.errors)
+	}
+	if leaks := memtrack.Leaks(); len(leaks) != 0 {
+		t.Errorf("leaks reported twice: %v", leaks)
+	}
+}
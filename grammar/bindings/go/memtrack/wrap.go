@@ -0,0 +1,66 @@
+package memtrack
+
+import (
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// TrackedTree is a tree whose Close is accounted for.
+type TrackedTree struct {
+	*tree_sitter.Tree
+	h *Handle
+}
+
+// NewTree tracks t, which the returned tree's Close releases. It returns
+// nil for a nil t.
+func NewTree(t *tree_sitter.Tree) *TrackedTree {
+	if t == nil {
+		return nil
+	}
+	return &TrackedTree{t, Open(Tree)}
+}
+
+// Close releases the tree. Calling Close more than once is a no-op.
+func (t *TrackedTree) Close() {
+	if t.h.Close() {
+		t.Tree.Close()
+	}
+}
+
+// TrackedQuery is a query whose Close is accounted for.
+type TrackedQuery struct {
+	*tree_sitter.Query
+	h *Handle
+}
+
+// NewQuery tracks q, as NewTree does a tree.
+func NewQuery(q *tree_sitter.Query) *TrackedQuery {
+	if q == nil {
+		return nil
+	}
+	return &TrackedQuery{q, Open(Query)}
+}
+
+// Close releases the query. Calling Close more than once is a no-op.
+func (q *TrackedQuery) Close() {
+	if q.h.Close() {
+		q.Query.Close()
+	}
+}
+
+// TrackedQueryCursor is a query cursor whose Close is accounted for.
+type TrackedQueryCursor struct {
+	*tree_sitter.QueryCursor
+	h *Handle
+}
+
+// NewQueryCursor returns a new, tracked query cursor.
+func NewQueryCursor() *TrackedQueryCursor {
+	return &TrackedQueryCursor{tree_sitter.NewQueryCursor(), Open(QueryCursor)}
+}
+
+// Close releases the cursor. Calling Close more than once is a no-op.
+func (c *TrackedQueryCursor) Close() {
+	if c.h.Close() {
+		c.QueryCursor.Close()
+	}
+}
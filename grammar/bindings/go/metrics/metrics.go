@@ -0,0 +1,148 @@
+// Package metrics measures the size and density of wabznasm statements, to
+// keep dense one-liners in check.
+//
+// The language has no branches, so cyclomatic complexity would be 1 for
+// every function. Complexity here counts operations instead: one for the
+// statement, plus one for every operator and call in it. Depth is how
+// deeply operations nest, and Length is the number of tokens.
+package metrics
+
+import (
+	"fmt"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// Metrics are the measurements of one statement.
+type Metrics struct {
+	// Name is the name the statement assigns; empty for an expression.
+	Name string
+	// Func is set when the statement assigns a function literal.
+	Func bool
+	// Range is that of the statement.
+	Range tree_sitter.Range
+	// Params is the number of parameters of a function, explicit or
+	// implicit.
+	Params int
+	// Complexity is one more than the number of operators and calls.
+	Complexity int
+	// Depth is the greatest number of operations enclosing one another:
+	// 0 for a lone name or number, 1 for x+1, 2 for f[x+1].
+	Depth int
+	// Length is the number of tokens of the statement, not counting
+	// comments.
+	Length int
+}
+
+// Analyze measures the statements of a parse tree of source. A source
+// holds one statement, so there is at most one entry; there is none when
+// the source has syntax errors.
+func Analyze(tree *tree_sitter.Tree, source []byte) []Metrics {
+	if tree.RootNode().HasError() {
+		return nil
+	}
+	f := ast.FromTree(tree, source)
+	var m Metrics
+	var value ast.Expr
+	switch s := f.Stmt.(type) {
+	case *ast.Assignment:
+		if s.Name != nil {
+			m.Name = s.Name.Name
+		}
+		value = s.Value
+	case *ast.ExprStmt:
+		value = s.X
+	}
+	if value == nil {
+		return nil
+	}
+	m.Range = toRange(ast.Span{Start: f.Stmt.Pos(), End: f.Stmt.EndPos()})
+	if fn, ok := value.(*ast.FunctionDef); ok {
+		m.Func = true
+		m.Params = len(fn.Signature())
+		if fn.Body != nil {
+			value = fn.Body
+		}
+	}
+	m.Complexity = 1 + operations(value)
+	m.Depth = depth(value)
+	for _, tok := range tree_sitter_wabznasm.Tokens(tree, source) {
+		if !tok.Extra && m.Range.StartByte <= tok.Range.StartByte && tok.Range.EndByte <= m.Range.EndByte {
+			m.Length++
+		}
+	}
+	return []Metrics{m}
+}
+
+func operations(e ast.Expr) int {
+	n := 0
+	ast.Inspect(e, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.BinaryExpr, *ast.UnaryExpr, *ast.PostfixExpr, *ast.Call:
+			n++
+		}
+		return true
+	})
+	return n
+}
+
+func depth(e ast.Expr) int {
+	switch e := e.(type) {
+	case *ast.BinaryExpr:
+		return 1 + max(depth(e.Left), depth(e.Right))
+	case *ast.UnaryExpr:
+		return 1 + depth(e.Operand)
+	case *ast.PostfixExpr:
+		return 1 + depth(e.Operand)
+	case *ast.ParenExpr:
+		return depth(e.X)
+	case *ast.FunctionDef:
+		return depth(e.Body)
+	case *ast.Call:
+		d := 0
+		for _, arg := range e.Args {
+			d = max(d, depth(arg))
+		}
+		return 1 + d
+	}
+	return 0
+}
+
+func toRange(s ast.Span) tree_sitter.Range {
+	return tree_sitter.Range{
+		StartByte:  s.Start.Offset,
+		EndByte:    s.End.Offset,
+		StartPoint: tree_sitter.Point{Row: s.Start.Row, Column: s.Start.Column},
+		EndPoint:   tree_sitter.Point{Row: s.End.Row, Column: s.End.Column},
+	}
+}
+
+// Thresholds are the largest values of each metric considered acceptable.
+// A zero field is not checked.
+type Thresholds struct {
+	Params     int
+	Complexity int
+	Depth      int
+	Length     int
+}
+
+// DefaultThresholds are the thresholds of the complexity lint rule.
+var DefaultThresholds = Thresholds{Params: 6, Complexity: 15, Depth: 6, Length: 60}
+
+// Exceeded describes each metric of m above its threshold, as in
+// "complexity 18 exceeds 15".
+func (t Thresholds) Exceeded(m Metrics) []string {
+	var out []string
+	check := func(name string, value, limit int) {
+		if limit > 0 && value > limit {
+			out = append(out, fmt.Sprintf("%s %d exceeds %d", name, value, limit))
+		}
+	}
+	check("parameters", m.Params, t.Params)
+	check("complexity", m.Complexity, t.Complexity)
+	check("depth", m.Depth, t.Depth)
+	check("length", m.Length, t.Length)
+	return out
+}
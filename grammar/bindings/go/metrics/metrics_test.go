@@ -0,0 +1,66 @@
+package metrics_test
+
+import (
+	"slices"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/metrics"
+)
+
+func analyze(t *testing.T, src string) []metrics.Metrics {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	return metrics.Analyze(tree.Tree, []byte(src))
+}
+
+func TestAnalyze(t *testing.T) {
+	for _, tt := range []struct {
+		src  string
+		want metrics.Metrics
+	}{
+		{"x", metrics.Metrics{Complexity: 1, Length: 1}},
+		{"x: 1 + 2 \\ sum", metrics.Metrics{Name: "x", Complexity: 2, Depth: 1, Length: 5}},
+		{"f: {[a;b] g[a+1; -b]}", metrics.Metrics{Name: "f", Func: true, Params: 2, Complexity: 4, Depth: 2, Length: 18}},
+		{"f: {x*y}", metrics.Metrics{Name: "f", Func: true, Params: 2, Complexity: 2, Depth: 1, Length: 7}},
+		{"((1+2)*(3-4))^2!", metrics.Metrics{Complexity: 6, Depth: 3, Length: 16}},
+	} {
+		got := analyze(t, tt.src)
+		if len(got) != 1 {
+			t.Errorf("Analyze(%q) = %v, want one entry", tt.src, got)
+			continue
+		}
+		m := got[0]
+		if m.Range.StartByte != 0 {
+			t.Errorf("Analyze(%q) starts at %d, want 0", tt.src, m.Range.StartByte)
+		}
+		m.Range = tt.want.Range
+		if m != tt.want {
+			t.Errorf("Analyze(%q) = %+v, want %+v", tt.src, m, tt.want)
+		}
+	}
+	if got := analyze(t, "x: ]"); len(got) != 0 {
+		t.Errorf("Analyze of invalid source = %v, want none", got)
+	}
+}
+
+func TestExceeded(t *testing.T) {
+	m := metrics.Metrics{Params: 7, Complexity: 15, Depth: 7, Length: 10}
+	got := metrics.DefaultThresholds.Exceeded(m)
+	want := []string{"parameters 7 exceeds 6", "depth 7 exceeds 6"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Exceeded = %v, want %v", got, want)
+	}
+	if got := (metrics.Thresholds{}).Exceeded(m); got != nil {
+		t.Errorf("zero thresholds: Exceeded = %v, want none", got)
+	}
+}
@@ -0,0 +1,97 @@
+// Package minify prints wabznasm source in the fewest bytes that parse to
+// the same tree, for embedding formulas where space is short and for
+// cache keys that should not change with layout.
+//
+// Comments and whitespace are dropped, except for a single space between
+// two tokens that would otherwise run together, as in the parameters of
+// {[x;y] x y}. Every result is re-parsed and compared with the original
+// tree, node for node, before it is returned, so a minified source is
+// never silently different.
+package minify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/asthash"
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+var (
+	// ErrSyntax is returned, wrapped with the first diagnostic, when the
+	// source does not parse. Invalid source is never minified.
+	ErrSyntax = errors.New("minify: syntax error")
+	// ErrNotPreserved is returned if the minified source would parse to a
+	// different tree. It indicates a bug in the minifier.
+	ErrNotPreserved = errors.New("minify: minified source parses differently")
+)
+
+// Source minifies src and returns the result.
+func Source(src []byte) ([]byte, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	tree, err := parser.ParseBytes(src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	var buf bytes.Buffer
+	if err := Tree(&buf, tree.Tree, src); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Tree writes the minified form of a parse tree of src to w.
+func Tree(w io.Writer, tree *tree_sitter.Tree, src []byte) error {
+	if tree.RootNode().HasError() {
+		if diags := tree_sitter_wabznasm.Diagnostics(tree, src); len(diags) > 0 {
+			return fmt.Errorf("%w: %s", ErrSyntax, diags[0])
+		}
+		return ErrSyntax
+	}
+	var out []byte
+	for _, tok := range tree_sitter_wabznasm.Tokens(tree, src) {
+		if tok.Extra {
+			continue
+		}
+		if len(out) > 0 && isWord(out[len(out)-1]) && isWord(tok.Text[0]) {
+			out = append(out, ' ')
+		}
+		out = append(out, tok.Text...)
+	}
+	if err := verify(tree, src, out); err != nil {
+		return err
+	}
+	_, err := w.Write(out)
+	return err
+}
+
+// isWord reports whether c can continue an identifier or number.
+func isWord(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// verify re-parses out and compares its tree with the original.
+func verify(tree *tree_sitter.Tree, src, out []byte) error {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return err
+	}
+	defer parser.Close()
+	again, err := parser.ParseBytes(out)
+	if err != nil {
+		return err
+	}
+	defer again.Close()
+	if !asthash.Equal(tree.RootNode(), src, again.RootNode(), out) {
+		return fmt.Errorf("%w: %q", ErrNotPreserved, out)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package minify_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/fuzz"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/minify"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func TestSource(t *testing.T) {
+	for _, tt := range []struct{ src, want string }{
+		{"x : 1 + 2", "x:1+2"},
+		{"f: {[x; y]\n  \\ add them\n  x + y\n}", "f:{[x;y]x+y}"},
+		{"2 * ( 3 + 4 ) ^ 2 !", "2*(3+4)^2!"},
+		{"1 - -2", "1--2"},
+		{"g: {[a] f[a; 10]} \\ trailing", "g:{[a]f[a;10]}"},
+	} {
+		got, err := minify.Source([]byte(tt.src))
+		if err != nil {
+			t.Errorf("Source(%q): %v", tt.src, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("Source(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+	if _, err := minify.Source([]byte("x: 1 +")); !errors.Is(err, minify.ErrSyntax) {
+		t.Errorf("invalid source: err = %v, want ErrSyntax", err)
+	}
+}
+
+func FuzzMinify(f *testing.F) {
+	fuzz.FuzzParse(f, func(t *testing.T, tree *tree_sitter.Tree, src []byte) {
+		if tree.RootNode().HasError() {
+			return
+		}
+		out, err := minify.Source(src)
+		if err != nil {
+			t.Fatalf("Source(%q): %v", src, err)
+		}
+		if len(out) > len(src) {
+			t.Errorf("Source(%q) = %q is longer", src, out)
+		}
+		again, err := minify.Source(out)
+		if err != nil || string(again) != string(out) {
+			t.Errorf("Source(%q) = %q, %v; not idempotent", out, again, err)
+		}
+	})
+}
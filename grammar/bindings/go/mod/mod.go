@@ -0,0 +1,277 @@
+// Package mod describes wabznasm modules, libraries of sources shared
+// between projects, and vendors the modules a project requires.
+//
+// A module is a directory with a manifest, wabznasm.mod, naming it and
+// the modules it requires:
+//
+//	# wabznasm.mod
+//	name stats
+//	version 1.2.0
+//	files lib/*.wz
+//	require vectors https://github.com/example/vectors.git v0.3.1
+//
+// Each require line gives the name the module is vendored under, the git
+// URL it is fetched from and the tag, branch or commit to fetch. Files
+// lists the patterns, relative to the module root, of the sources the
+// module shares; without it every source outside vendor and hidden
+// directories is shared.
+//
+// Vendor copies the shared sources of each required module, and of the
+// modules they require in turn, into vendor/<name> under the project
+// root, where project indexes them, and records the commit and a sum of
+// the files of each in wabznasm.lock. Later runs fetch the locked commits
+// rather than resolving the refs again, until an update is asked for;
+// Verify checks that the vendored files still match their sums.
+package mod
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+// File names of a module.
+const (
+	ManifestFile = "wabznasm.mod"
+	LockFile     = "wabznasm.lock"
+)
+
+// Manifest is the content of a manifest file.
+type Manifest struct {
+	Name    string
+	Version string
+	// Files are the patterns of the sources the module shares, in the
+	// syntax of filepath.Match with slashes, relative to the module root.
+	Files   []string
+	Require []Requirement
+}
+
+// Requirement is a module a manifest requires.
+type Requirement struct {
+	Name string
+	URL  string
+	// Ref is the tag, branch or commit to fetch.
+	Ref string
+}
+
+// validName matches module names, which name directories of vendor.
+var validName = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
+// ParseManifest reads a manifest from r, named name in errors. Blank
+// lines and lines starting with # are ignored.
+func ParseManifest(r io.Reader, name string) (*Manifest, error) {
+	m := &Manifest{}
+	err := fields(r, name, func(line int, f []string) error {
+		switch f[0] {
+		case "name", "version":
+			if len(f) != 2 {
+				return fmt.Errorf("%s takes one argument", f[0])
+			}
+			if f[0] == "version" {
+				m.Version = f[1]
+				return nil
+			}
+			if !validName.MatchString(f[1]) {
+				return fmt.Errorf("invalid module name %q", f[1])
+			}
+			m.Name = f[1]
+		case "files":
+			if len(f) < 2 {
+				return errors.New("files takes at least one pattern")
+			}
+			for _, p := range f[1:] {
+				if _, err := filepath.Match(p, ""); err != nil || filepath.IsAbs(p) || strings.HasPrefix(filepath.Clean(p), "..") {
+					return fmt.Errorf("invalid pattern %q", p)
+				}
+			}
+			m.Files = append(m.Files, f[1:]...)
+		case "require":
+			if len(f) != 4 {
+				return errors.New("want require name url ref")
+			}
+			if !validName.MatchString(f[1]) {
+				return fmt.Errorf("invalid module name %q", f[1])
+			}
+			for _, r := range m.Require {
+				if r.Name == f[1] {
+					return fmt.Errorf("%s is required twice", f[1])
+				}
+			}
+			m.Require = append(m.Require, Requirement{Name: f[1], URL: f[2], Ref: f[3]})
+		default:
+			return fmt.Errorf("unknown directive %q", f[0])
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if m.Name == "" {
+		return nil, fmt.Errorf("%s: no module name", name)
+	}
+	return m, nil
+}
+
+// fields calls line with the fields of each line of r that is neither
+// blank nor a comment, prefixing its errors with name and the line
+// number.
+func fields(r io.Reader, name string, line func(n int, f []string) error) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		f := strings.Fields(sc.Text())
+		if len(f) == 0 || strings.HasPrefix(f[0], "#") {
+			continue
+		}
+		if err := line(n, f); err != nil {
+			return fmt.Errorf("%s:%d: %w", name, n, err)
+		}
+	}
+	return sc.Err()
+}
+
+// ReadManifest reads the manifest of the module rooted at dir.
+func ReadManifest(dir string) (*Manifest, error) {
+	path := filepath.Join(dir, ManifestFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseManifest(bytes.NewReader(data), path)
+}
+
+// Bytes returns the manifest in the form ParseManifest reads.
+func (m *Manifest) Bytes() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "name %s\n", m.Name)
+	if m.Version != "" {
+		fmt.Fprintf(&b, "version %s\n", m.Version)
+	}
+	if len(m.Files) > 0 {
+		fmt.Fprintf(&b, "files %s\n", strings.Join(m.Files, " "))
+	}
+	if len(m.Require) > 0 {
+		b.WriteByte('\n')
+	}
+	for _, r := range m.Require {
+		fmt.Fprintf(&b, "require %s %s %s\n", r.Name, r.URL, r.Ref)
+	}
+	return b.Bytes()
+}
+
+// Sources returns the paths, relative to root and slash-separated, of the
+// sources the module rooted at root shares, sorted.
+func (m *Manifest) Sources(root string) ([]string, error) {
+	var out []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && (d.Name()[0] == '.' || rel == project.VendorDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		rel = filepath.ToSlash(rel)
+		if m.shares(rel) {
+			out = append(out, rel)
+		}
+		return nil
+	})
+	sort.Strings(out)
+	return out, err
+}
+
+func (m *Manifest) shares(rel string) bool {
+	if len(m.Files) == 0 {
+		return project.IsSource(rel)
+	}
+	for _, p := range m.Files {
+		if ok, _ := filepath.Match(p, filepath.FromSlash(rel)); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Lock is the content of a lock file: the modules vendored, sorted by
+// name.
+type Lock struct {
+	Modules []Locked
+}
+
+// Locked is a vendored module.
+type Locked struct {
+	Name, URL, Ref string
+	// Commit is the commit Ref resolved to.
+	Commit string
+	// Sum digests the vendored files; see Sum.
+	Sum string
+}
+
+// ParseLock reads a lock file from r, named name in errors.
+func ParseLock(r io.Reader, name string) (*Lock, error) {
+	l := &Lock{}
+	err := fields(r, name, func(_ int, f []string) error {
+		if len(f) != 5 {
+			return errors.New("want name url ref commit sum")
+		}
+		l.Modules = append(l.Modules, Locked{Name: f[0], URL: f[1], Ref: f[2], Commit: f[3], Sum: f[4]})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// ReadLock reads the lock file of the project rooted at dir. A project
+// that has none has an empty Lock.
+func ReadLock(dir string) (*Lock, error) {
+	path := filepath.Join(dir, LockFile)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &Lock{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseLock(bytes.NewReader(data), path)
+}
+
+// Bytes returns the lock file in the form ParseLock reads.
+func (l *Lock) Bytes() []byte {
+	var b bytes.Buffer
+	b.WriteString("# Generated by wabznasm mod vendor. Do not edit.\n")
+	for _, m := range l.Modules {
+		fmt.Fprintf(&b, "%s %s %s %s %s\n", m.Name, m.URL, m.Ref, m.Commit, m.Sum)
+	}
+	return b.Bytes()
+}
+
+// Find returns the locked module called name.
+func (l *Lock) Find(name string) (Locked, bool) {
+	for _, m := range l.Modules {
+		if m.Name == name {
+			return m, true
+		}
+	}
+	return Locked{}, false
+}
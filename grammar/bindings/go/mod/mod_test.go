@@ -0,0 +1,274 @@
+package mod_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/mod"
+)
+
+func write(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, text := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	src := `# stats
+name stats
+version 1.2.0
+files lib/*.wz main.wz
+
+require vectors https://example.com/vectors.git v0.3.1
+`
+	m, err := mod.ParseManifest(strings.NewReader(src), mod.ManifestFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &mod.Manifest{
+		Name:    "stats",
+		Version: "1.2.0",
+		Files:   []string{"lib/*.wz", "main.wz"},
+		Require: []mod.Requirement{{Name: "vectors", URL: "https://example.com/vectors.git", Ref: "v0.3.1"}},
+	}
+	if !reflect.DeepEqual(m, want) {
+		t.Fatalf("ParseManifest = %+v, want %+v", m, want)
+	}
+	back, err := mod.ParseManifest(strings.NewReader(string(m.Bytes())), "bytes")
+	if err != nil || !reflect.DeepEqual(back, m) {
+		t.Errorf("round trip = %+v, %v", back, err)
+	}
+
+	for _, bad := range []string{
+		"version 1",
+		"name ../up",
+		"name a\nrequire b url",
+		"name a\nrequire b u r\nrequire b u r",
+		"name a\nfiles ../*.wz",
+		"name a\nexclude x",
+	} {
+		if _, err := mod.ParseManifest(strings.NewReader(bad), "bad"); err == nil {
+			t.Errorf("ParseManifest(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestSources(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, map[string]string{
+		"a.wz":            "a: 1",
+		"lib/b.wabznasm":  "b: 2",
+		"notes.txt":       "",
+		".git/c.wz":       "",
+		"vendor/dep/d.wz": "",
+		"lib/sub/e.wz":    "",
+		mod.ManifestFile:  "name x\n",
+	})
+	all, err := (&mod.Manifest{Name: "x"}).Sources(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.wz", "lib/b.wabznasm", "lib/sub/e.wz"}; !reflect.DeepEqual(all, want) {
+		t.Errorf("Sources = %v, want %v", all, want)
+	}
+	some, err := (&mod.Manifest{Name: "x", Files: []string{"lib/*"}}).Sources(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"lib/b.wabznasm"}; !reflect.DeepEqual(some, want) {
+		t.Errorf("Sources with files = %v, want %v", some, want)
+	}
+}
+
+// fetcher checks out repositories held as directories of a map from URL
+// to revision, recording the revisions asked for.
+type fetcher struct {
+	t     *testing.T
+	repos map[string]map[string]map[string]string
+	asked []string
+}
+
+func (f *fetcher) Fetch(_ context.Context, url, rev, dir string) (string, error) {
+	f.asked = append(f.asked, url+"@"+rev)
+	revs, ok := f.repos[url]
+	if !ok {
+		return "", errors.New("no such repository")
+	}
+	commit := rev
+	if !strings.HasPrefix(commit, "commit-") {
+		commit = "commit-" + rev
+	}
+	files, ok := revs[strings.TrimPrefix(commit, "commit-")]
+	if !ok {
+		return "", errors.New("no such revision")
+	}
+	write(f.t, dir, files)
+	return commit, nil
+}
+
+func TestVendor(t *testing.T) {
+	root := t.TempDir()
+	write(t, root, map[string]string{
+		mod.ManifestFile: "name app\nrequire stats https://x/stats v1\nrequire vectors https://x/vectors v1\n",
+		"main.wz":        "mean[1;2]",
+	})
+	f := &fetcher{t: t, repos: map[string]map[string]map[string]string{
+		"https://x/stats": {"v1": {
+			mod.ManifestFile: "name stats\nfiles lib/*.wz\nrequire vectors https://x/vectors v2\nrequire util https://x/util v1\n",
+			"lib/mean.wz":    "mean: {[x;y] x+y}",
+			"stats_test.wz":  "",
+		}},
+		"https://x/vectors": {
+			"v1": {mod.ManifestFile: "name vectors\n", "v.wz": "dot: 1"},
+			"v2": {mod.ManifestFile: "name vectors\n", "v.wz": "dot: 2"},
+		},
+		"https://x/util": {"v1": {mod.ManifestFile: "name util\n", "u.wz": "id: {[x] x}"}},
+	}}
+
+	lock, err := mod.Vendor(context.Background(), root, mod.Options{Fetcher: f})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, l := range lock.Modules {
+		names = append(names, l.Name+"@"+l.Commit)
+	}
+	// The project's own requirement of vectors wins over that of stats.
+	if want := []string{"stats@commit-v1", "util@commit-v1", "vectors@commit-v1"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("locked %v, want %v", names, want)
+	}
+	for path, want := range map[string]string{
+		"vendor/stats/lib/mean.wz":         "mean: {[x;y] x+y}",
+		"vendor/vectors/v.wz":              "dot: 1",
+		"vendor/stats/" + mod.ManifestFile: "name stats\nfiles lib/*.wz\nrequire vectors https://x/vectors v2\nrequire util https://x/util v1\n",
+	} {
+		if got, err := os.ReadFile(filepath.Join(root, path)); err != nil || string(got) != want {
+			t.Errorf("%s = %q, %v", path, got, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "vendor/stats/stats_test.wz")); err == nil {
+		t.Error("vendored a file the manifest does not share")
+	}
+	read, err := mod.ReadLock(root)
+	if err != nil || !reflect.DeepEqual(read, lock) {
+		t.Errorf("ReadLock = %+v, %v", read, err)
+	}
+	if err := mod.Verify(root); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+
+	// Vendoring again fetches the locked commits.
+	f.asked = nil
+	if _, err := mod.Vendor(context.Background(), root, mod.Options{Fetcher: f}); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"https://x/stats@commit-v1", "https://x/vectors@commit-v1", "https://x/util@commit-v1"}; !reflect.DeepEqual(f.asked, want) {
+		t.Errorf("fetched %v, want %v", f.asked, want)
+	}
+
+	// Edited vendored files fail verification.
+	write(t, root, map[string]string{"vendor/util/u.wz": "id: 0"})
+	if err := mod.Verify(root); !errors.Is(err, mod.ErrMismatch) {
+		t.Errorf("Verify after edit = %v", err)
+	}
+
+	// Dropped requirements leave vendor.
+	write(t, root, map[string]string{mod.ManifestFile: "name app\nrequire vectors https://x/vectors v2\n"})
+	if err := mod.Verify(root); err == nil {
+		t.Error("Verify passed with a requirement not locked")
+	}
+	if _, err := mod.Vendor(context.Background(), root, mod.Options{Fetcher: f}); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(filepath.Join(root, "vendor"))
+	if len(entries) != 1 || entries[0].Name() != "vectors" {
+		t.Errorf("vendor holds %v", entries)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "vendor/vectors/v.wz")); string(got) != "dot: 2" {
+		t.Errorf("vectors not updated: %q", got)
+	}
+}
+
+func TestVendorConflict(t *testing.T) {
+	root := t.TempDir()
+	write(t, root, map[string]string{
+		mod.ManifestFile: "name app\nrequire a https://x/a v1\nrequire b https://x/b v1\n",
+	})
+	f := &fetcher{t: t, repos: map[string]map[string]map[string]string{
+		"https://x/a": {"v1": {mod.ManifestFile: "name a\nrequire b https://fork/b v1\n"}},
+		"https://x/b": {"v1": {mod.ManifestFile: "name b\n"}},
+	}}
+	if _, err := mod.Vendor(context.Background(), root, mod.Options{Fetcher: f}); err == nil || !strings.Contains(err.Error(), "required from both") {
+		t.Errorf("Vendor = %v, want a conflict", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, mod.LockFile)); err == nil {
+		t.Error("wrote a lock file after a failure")
+	}
+
+	write(t, root, map[string]string{mod.ManifestFile: "name app\nrequire c https://x/b v1\n"})
+	if _, err := mod.Vendor(context.Background(), root, mod.Options{Fetcher: f}); err == nil || !strings.Contains(err.Error(), "declares module b") {
+		t.Errorf("Vendor = %v, want a name mismatch", err)
+	}
+}
+
+func TestGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	repo := t.TempDir()
+	write(t, repo, map[string]string{mod.ManifestFile: "name util\n", "u.wz": "id: {[x] x}"})
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		cmd.Dir = repo
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	git("init", "--quiet")
+	git("add", ".")
+	git("commit", "--quiet", "-m", "one")
+	git("tag", "v1")
+	first := git("rev-parse", "HEAD")
+	write(t, repo, map[string]string{"u.wz": "id: 0"})
+	git("commit", "--quiet", "-am", "two")
+
+	dir := filepath.Join(t.TempDir(), "util")
+	commit, err := mod.Git{}.Fetch(context.Background(), repo, "v1", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit != first {
+		t.Errorf("Fetch resolved v1 to %s, want %s", commit, first)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "u.wz")); string(got) != "id: {[x] x}" {
+		t.Errorf("checked out %q", got)
+	}
+	if _, err := (mod.Git{}).Fetch(context.Background(), repo, "v9", filepath.Join(t.TempDir(), "x")); err == nil {
+		t.Error("Fetch of a missing ref succeeded")
+	}
+}
+
+func TestGitRev(t *testing.T) {
+	// The revision is rejected before git runs, so no git is needed.
+	g := mod.Git{Path: filepath.Join(t.TempDir(), "no-git")}
+	_, err := g.Fetch(context.Background(), "https://example.com/util.git", "--upload-pack=touch pwned", t.TempDir())
+	if !errors.Is(err, mod.ErrRev) {
+		t.Errorf("Fetch of a revision starting with - = %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package mod
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+// A Fetcher checks out modules.
+type Fetcher interface {
+	// Fetch checks out rev of the repository at url into the empty
+	// directory dir and returns the commit it resolved to. Rev is a ref
+	// from a manifest or a commit from a lock file.
+	Fetch(ctx context.Context, url, rev, dir string) (commit string, err error)
+}
+
+// ErrRev reports a revision that git would read as an option.
+var ErrRev = errors.New("mod: revision starts with -")
+
+// Git is the Fetcher that runs the git command.
+type Git struct {
+	// Path is the git executable; empty means git from PATH.
+	Path string
+}
+
+// Fetch clones url into dir and checks out rev there.
+func (g Git) Fetch(ctx context.Context, url, rev, dir string) (string, error) {
+	if strings.HasPrefix(rev, "-") {
+		return "", fmt.Errorf("%w: %q", ErrRev, rev)
+	}
+	if _, err := g.run(ctx, "", "clone", "--quiet", "--no-checkout", "--", url, dir); err != nil {
+		return "", err
+	}
+	if _, err := g.run(ctx, dir, "-c", "advice.detachedHead=false", "checkout", "--quiet", rev, "--"); err != nil {
+		return "", err
+	}
+	commit, err := g.run(ctx, dir, "rev-parse", "HEAD")
+	return strings.TrimSpace(commit), err
+}
+
+func (g Git) run(ctx context.Context, dir string, args ...string) (string, error) {
+	path := g.Path
+	if path == "" {
+		path = "git"
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return "", fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return stdout.String(), nil
+}
+
+// Options configure Vendor.
+type Options struct {
+	// Fetcher checks out modules; nil means Git{}.
+	Fetcher Fetcher
+	// Update resolves the refs of the manifests again instead of fetching
+	// the commits the lock file pins.
+	Update bool
+}
+
+// Vendor fetches the modules the manifest at root requires, and those
+// they require in turn, copies the sources each shares together with its
+// manifest into root/vendor/<name>, and writes the lock file. It returns
+// the lock written.
+//
+// A module required by several others is vendored once, by the first
+// requirement met breadth-first from root, and must come from the same
+// URL wherever it is required. Directories in vendor for modules no
+// longer required are removed.
+func Vendor(ctx context.Context, root string, opts Options) (*Lock, error) {
+	fetcher := opts.Fetcher
+	if fetcher == nil {
+		fetcher = Git{}
+	}
+	m, err := ReadManifest(root)
+	if err != nil {
+		return nil, err
+	}
+	old, err := ReadLock(root)
+	if err != nil {
+		return nil, err
+	}
+	vendor := filepath.Join(root, project.VendorDir)
+	if err := os.MkdirAll(vendor, 0o755); err != nil {
+		return nil, err
+	}
+	// Modules are checked out and staged in a directory of vendor, so that
+	// they move into place without crossing file systems.
+	tmp, err := os.MkdirTemp(vendor, ".fetch-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	lock := &Lock{}
+	by := map[string]Requirement{}
+	queue := append([]Requirement(nil), m.Require...)
+	for len(queue) > 0 {
+		req := queue[0]
+		queue = queue[1:]
+		if req.Name == m.Name {
+			return nil, fmt.Errorf("%s requires itself", req.Name)
+		}
+		if prev, ok := by[req.Name]; ok {
+			if prev.URL != req.URL {
+				return nil, fmt.Errorf("%s is required from both %s and %s", req.Name, prev.URL, req.URL)
+			}
+			continue
+		}
+		by[req.Name] = req
+
+		rev := req.Ref
+		if l, ok := old.Find(req.Name); ok && !opts.Update && l.URL == req.URL && l.Ref == req.Ref {
+			rev = l.Commit
+		}
+		checkout := filepath.Join(tmp, "src", req.Name)
+		commit, err := fetcher.Fetch(ctx, req.URL, rev, checkout)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s: %w", req.Name, err)
+		}
+		dep, err := ReadManifest(checkout)
+		if err != nil {
+			return nil, fmt.Errorf("fetching %s: %w", req.Name, err)
+		}
+		if dep.Name != req.Name {
+			return nil, fmt.Errorf("fetching %s: %s declares module %s", req.Name, req.URL, dep.Name)
+		}
+		stage := filepath.Join(tmp, "stage", req.Name)
+		if err := copyModule(dep, checkout, stage); err != nil {
+			return nil, fmt.Errorf("vendoring %s: %w", req.Name, err)
+		}
+		sum, err := Sum(stage)
+		if err != nil {
+			return nil, err
+		}
+		lock.Modules = append(lock.Modules, Locked{Name: req.Name, URL: req.URL, Ref: req.Ref, Commit: commit, Sum: sum})
+		queue = append(queue, dep.Require...)
+	}
+	sort.Slice(lock.Modules, func(i, j int) bool { return lock.Modules[i].Name < lock.Modules[j].Name })
+
+	entries, err := os.ReadDir(vendor)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.Name()[0] == '.' {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(vendor, e.Name())); err != nil {
+			return nil, err
+		}
+	}
+	for _, l := range lock.Modules {
+		if err := os.Rename(filepath.Join(tmp, "stage", l.Name), filepath.Join(vendor, l.Name)); err != nil {
+			return nil, err
+		}
+	}
+	if len(lock.Modules) == 0 {
+		os.RemoveAll(tmp)
+		os.Remove(vendor)
+	}
+	return lock, os.WriteFile(filepath.Join(root, LockFile), lock.Bytes(), 0o644)
+}
+
+// copyModule copies the manifest and shared sources of m, a module
+// checked out at src, into dst.
+func copyModule(m *Manifest, src, dst string) error {
+	files, err := m.Sources(src)
+	if err != nil {
+		return err
+	}
+	for _, rel := range append([]string{ManifestFile}, files...) {
+		data, err := os.ReadFile(filepath.Join(src, rel))
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dst, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Sum digests the files under dir: it is the hex SHA-256 of a line
+// "<hex SHA-256 of the file> <slash-separated path>" for each file, in
+// path order, prefixed with h1:.
+func Sum(dir string) (string, error) {
+	var lines []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(data)
+		lines = append(lines, hex.EncodeToString(h[:])+" "+filepath.ToSlash(rel)+"\n")
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(lines)
+	h := sha256.New()
+	for _, l := range lines {
+		h.Write([]byte(l))
+	}
+	return "h1:" + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ErrMismatch is wrapped by the errors Verify returns for vendored
+// modules that differ from the lock file.
+var ErrMismatch = errors.New("vendored module does not match lock file")
+
+// Verify checks that the project rooted at root has vendored every module
+// its manifest requires, and that the files of each still match the sum
+// in the lock file. It returns the problems found joined into one error.
+func Verify(root string) error {
+	m, err := ReadManifest(root)
+	if err != nil {
+		return err
+	}
+	lock, err := ReadLock(root)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, req := range m.Require {
+		if l, ok := lock.Find(req.Name); !ok || l.URL != req.URL || l.Ref != req.Ref {
+			errs = append(errs, fmt.Errorf("%s: %s %s is not locked; run wabznasm mod vendor", req.Name, req.URL, req.Ref))
+		}
+	}
+	for _, l := range lock.Modules {
+		sum, err := Sum(filepath.Join(root, project.VendorDir, l.Name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", l.Name, err))
+			continue
+		}
+		if sum != l.Sum {
+			errs = append(errs, fmt.Errorf("%s: %w", l.Name, ErrMismatch))
+		}
+	}
+	return errors.Join(errs...)
+}
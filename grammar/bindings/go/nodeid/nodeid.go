@@ -0,0 +1,207 @@
+// Package nodeid gives the nodes of a syntax tree identifiers that
+// survive edits elsewhere in the source, so that annotations such as
+// review comments and cached analyses can be re-anchored after the text
+// moves under them.
+//
+// The identifier of a node digests its path, the kinds and fields of it
+// and its ancestors, and its content, its structure and leaf text as
+// package asthash sees them, together with how many nodes before it
+// share both. It does not depend on byte offsets, whitespace or
+// comments, so edits outside a node and its ancestors' kinds leave it
+// unchanged. An edit inside a node changes it and its ancestors'
+// identifiers; Reanchor then falls back to the node in the same place,
+// or the nearest enclosing node that survived.
+package nodeid
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// ID identifies a node. It is safe to store.
+type ID [16]byte
+
+// String returns the identifier in hexadecimal.
+func (id ID) String() string { return hex.EncodeToString(id[:]) }
+
+// Parse parses an identifier in hexadecimal, as String returns it.
+func Parse(s string) (ID, bool) {
+	var id ID
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(id) {
+		return id, false
+	}
+	copy(id[:], b)
+	return id, true
+}
+
+type sum [sha256.Size]byte
+
+// Node is a named node of a tree and its identifier.
+type Node struct {
+	ID   ID
+	Kind string
+	// Field is the field of the node in its parent, if any.
+	Field      string
+	StartByte  uint
+	EndByte    uint
+	Start, End tree_sitter.Point
+	// Parent is the index of the parent in Map.Nodes, or -1 for the root.
+	Parent int
+
+	path, content sum
+}
+
+// Map holds the identifiers of the named nodes of a tree, comments
+// aside.
+type Map struct {
+	// Nodes are in preorder.
+	Nodes  []Node
+	byID   map[ID]int
+	byPath map[sum][]int
+}
+
+// Assign returns the identifiers of the nodes of tree, whose text is
+// source.
+func Assign(tree *tree_sitter.Tree, source []byte) *Map {
+	m := &Map{byID: map[ID]int{}, byPath: map[sum][]int{}}
+	m.visit(tree.RootNode(), "", -1, sum{}, source)
+	seen := map[[2]sum]int{}
+	for i := range m.Nodes {
+		n := &m.Nodes[i]
+		key := [2]sum{n.path, n.content}
+		h := sha256.New()
+		h.Write(n.path[:])
+		h.Write(n.content[:])
+		h.Write(binary.AppendUvarint(nil, uint64(seen[key])))
+		seen[key]++
+		copy(n.ID[:], h.Sum(nil))
+		m.byID[n.ID] = i
+		m.byPath[n.path] = append(m.byPath[n.path], i)
+	}
+	return m
+}
+
+// visit adds the named node n and its named descendants, and writes the
+// content of n to the content hash of its parent.
+func (m *Map) visit(n *tree_sitter.Node, field string, parent int, parentPath sum, src []byte) sum {
+	h := sha256.New()
+	var flags byte
+	if n.IsMissing() {
+		flags = 1
+	}
+	h.Write([]byte{'(', flags})
+	writeString(h, n.Kind())
+	if n.ChildCount() == 0 {
+		writeString(h, n.Utf8Text(src))
+	}
+
+	p := sha256.New()
+	p.Write(parentPath[:])
+	writeString(p, n.Kind())
+	writeString(p, field)
+	var path sum
+	p.Sum(path[:0])
+
+	idx := len(m.Nodes)
+	m.Nodes = append(m.Nodes, Node{
+		Kind:      n.Kind(),
+		Field:     field,
+		StartByte: n.StartByte(),
+		EndByte:   n.EndByte(),
+		Start:     n.StartPosition(),
+		End:       n.EndPosition(),
+		Parent:    parent,
+		path:      path,
+	})
+	for i := uint(0); i < n.ChildCount(); i++ {
+		c := n.Child(i)
+		if c.IsExtra() {
+			continue
+		}
+		f := n.FieldNameForChild(uint32(i))
+		writeString(h, f)
+		if c.IsNamed() {
+			content := m.visit(c, f, idx, path, src)
+			h.Write(content[:])
+			continue
+		}
+		// Anonymous tokens are part of the content of their parent, but
+		// not nodes of their own.
+		writeString(h, c.Kind())
+		writeString(h, c.Utf8Text(src))
+	}
+	h.Write([]byte{')'})
+	h.Sum(m.Nodes[idx].content[:0])
+	return m.Nodes[idx].content
+}
+
+// writeString writes s with its length, so that adjacent strings cannot
+// run together.
+func writeString(h hash.Hash, s string) {
+	h.Write(binary.AppendUvarint(nil, uint64(len(s))))
+	h.Write([]byte(s))
+}
+
+// Lookup returns the node identified by id.
+func (m *Map) Lookup(id ID) (Node, bool) {
+	i, ok := m.byID[id]
+	if !ok {
+		return Node{}, false
+	}
+	return m.Nodes[i], true
+}
+
+// At returns the innermost node containing the byte at offset, to anchor
+// an annotation made there.
+func (m *Map) At(offset uint) (Node, bool) {
+	best := -1
+	for i, n := range m.Nodes {
+		// Later nodes in preorder that contain offset are nested deeper.
+		if n.StartByte <= offset && offset < n.EndByte {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Node{}, false
+	}
+	return m.Nodes[best], true
+}
+
+// Reanchor finds in m the node that id identified in old, the map of an
+// earlier version of the source. If the node is unchanged it is found by
+// id, and exact is true. Otherwise it is the node in the same place: the
+// one with the same path that as many nodes with that path precede. If
+// there is none, the nearest ancestor of the old node that can be found
+// either way is returned. The result is false if neither the node nor
+// any ancestor is found.
+func (m *Map) Reanchor(old *Map, id ID) (n Node, exact, ok bool) {
+	if n, ok := m.Lookup(id); ok {
+		return n, true, true
+	}
+	i, ok := old.byID[id]
+	if !ok {
+		return Node{}, false, false
+	}
+	for ; i >= 0; i = old.Nodes[i].Parent {
+		o := old.Nodes[i]
+		if n, ok := m.Lookup(o.ID); ok {
+			return n, false, true
+		}
+		rank := 0
+		for _, j := range old.byPath[o.path] {
+			if j == i {
+				break
+			}
+			rank++
+		}
+		if same := m.byPath[o.path]; rank < len(same) {
+			return m.Nodes[same[rank]], false, true
+		}
+	}
+	return Node{}, false, false
+}
@@ -0,0 +1,121 @@
+package nodeid_test
+
+import (
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/nodeid"
+)
+
+func assign(t *testing.T, src string) *nodeid.Map {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	return nodeid.Assign(tree.Tree, []byte(src))
+}
+
+// at returns the innermost node of kind at the start of text in src.
+func at(t *testing.T, m *nodeid.Map, src, text, kind string) nodeid.Node {
+	t.Helper()
+	off := strings.Index(src, text)
+	if off < 0 {
+		t.Fatalf("%q not in %q", text, src)
+	}
+	var found *nodeid.Node
+	for i, n := range m.Nodes {
+		if n.Kind == kind && n.StartByte == uint(off) && n.EndByte == uint(off+len(text)) {
+			found = &m.Nodes[i]
+		}
+	}
+	if found == nil {
+		t.Fatalf("no %s at %q in %q", kind, text, src)
+	}
+	return *found
+}
+
+func TestStableAcrossEdits(t *testing.T) {
+	const before = "f: {[x] x*2+y}"
+	const after = "f: {[x]\n  \\ scaled\n  x * 2 + z\n}"
+	a, b := assign(t, before), assign(t, after)
+
+	mul := at(t, a, before, "x*2", "multiplicative")
+	if got := at(t, b, after, "x * 2", "multiplicative"); got.ID != mul.ID {
+		t.Errorf("x*2 changed identity: %s, now %s", mul.ID, got.ID)
+	}
+	if n, ok := b.Lookup(mul.ID); !ok || n.StartByte != uint(strings.Index(after, "x * 2")) {
+		t.Errorf("Lookup(x*2) = %+v, %v", n, ok)
+	}
+	if at(t, a, before, "x*2+y", "additive").ID == at(t, b, after, "x * 2 + z", "additive").ID {
+		t.Error("the edited sum kept its identity")
+	}
+	params := at(t, a, before, "[x]", "parameter_list")
+	if _, ok := b.Lookup(params.ID); !ok {
+		t.Error("parameter list changed identity")
+	}
+
+	// The same source has the same identifiers.
+	again := assign(t, before)
+	for i, n := range a.Nodes {
+		if again.Nodes[i].ID != n.ID {
+			t.Fatalf("node %d: %s, then %s", i, n.ID, again.Nodes[i].ID)
+		}
+	}
+}
+
+func TestTwins(t *testing.T) {
+	m := assign(t, "f: {[x;x] x}")
+	var params []nodeid.ID
+	seen := map[nodeid.ID]bool{}
+	for _, n := range m.Nodes {
+		if n.Field == "param" {
+			params = append(params, n.ID)
+		}
+		if seen[n.ID] {
+			t.Errorf("%s %s is not unique", n.Kind, n.ID)
+		}
+		seen[n.ID] = true
+	}
+	if len(params) != 2 {
+		t.Errorf("%d parameters, want 2", len(params))
+	}
+}
+
+func TestReanchor(t *testing.T) {
+	const before = "f: {[x] x*2+y}"
+	const after = "f: {[x] x*2+zz}"
+	a, b := assign(t, before), assign(t, after)
+
+	anchor, ok := a.At(uint(strings.Index(before, "y")))
+	if !ok || anchor.Kind != "identifier" {
+		t.Fatalf("At(y) = %+v, %v", anchor, ok)
+	}
+	n, exact, ok := b.Reanchor(a, anchor.ID)
+	if !ok || exact || n.Kind != "identifier" || n.StartByte != uint(strings.Index(after, "zz")) {
+		t.Errorf("Reanchor(y) = %+v, exact %v, %v; want zz, inexact", n, exact, ok)
+	}
+
+	mul := at(t, a, before, "x*2", "multiplicative")
+	if n, exact, ok := b.Reanchor(a, mul.ID); !ok || !exact || n.ID != mul.ID {
+		t.Errorf("Reanchor(x*2) = %+v, exact %v, %v", n, exact, ok)
+	}
+	if _, _, ok := b.Reanchor(a, nodeid.ID{1}); ok {
+		t.Error("unknown identifier reanchored")
+	}
+
+	s := mul.ID.String()
+	if id, ok := nodeid.Parse(s); !ok || id != mul.ID {
+		t.Errorf("Parse(%q) = %s, %v", s, id, ok)
+	}
+	if _, ok := nodeid.Parse("xyz"); ok {
+		t.Error("Parse accepted xyz")
+	}
+}
@@ -0,0 +1,60 @@
+package tree_sitter_wabznasm
+
+//go:generate go run ./cmd/gen-nodetypes -o nodetypes_gen.go ../../src/node-types.json
+//go:generate go run ./cmd/gen-ast -o cst/cst_gen.go ../../src/node-types.json
+
+// KindInfo describes a node kind as listed in the grammar's
+// node-types.json.
+type KindInfo struct {
+	Kind string
+	// Named is false for literal tokens such as "+" and "[".
+	Named bool
+	// Extra kinds, such as comments, may appear anywhere.
+	Extra bool
+	// Root is set for the kind of the root node.
+	Root bool
+	// Supertype kinds are hidden rules whose Subtypes appear in their place.
+	Supertype bool
+	Subtypes  []string
+	// Fields lists the fields of the kind in name order.
+	Fields []FieldInfo
+	// Children describes the named children that are not in a field, or is
+	// nil if there are none.
+	Children *FieldInfo
+}
+
+// FieldInfo describes a field of a node kind, or its other children.
+type FieldInfo struct {
+	// Name is empty for the children outside any field.
+	Name     string
+	Multiple bool
+	Required bool
+	// Types are the kinds the field may hold.
+	Types []string
+}
+
+// NodeKinds returns every node kind of the grammar: the named kinds in name
+// order, then the literal tokens.
+func NodeKinds() []KindInfo {
+	return append([]KindInfo(nil), nodeKinds...)
+}
+
+// LookupKind returns the description of a named or literal node kind.
+func LookupKind(kind string, named bool) (KindInfo, bool) {
+	for _, k := range nodeKinds {
+		if k.Kind == kind && k.Named == named {
+			return k, true
+		}
+	}
+	return KindInfo{}, false
+}
+
+// Field returns the field of k with the given name.
+func (k KindInfo) Field(name string) (FieldInfo, bool) {
+	for _, f := range k.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return FieldInfo{}, false
+}
@@ -0,0 +1,171 @@
+// Code generated by gen-nodetypes from node-types.json; DO NOT EDIT.
+
+package tree_sitter_wabznasm
+
+// Node kinds, as returned by Node.Kind.
+const (
+	KindAdditive       = "additive"
+	KindArgumentList   = "argument_list"
+	KindAssignment     = "assignment"
+	KindComment        = "comment"
+	KindExpression     = "expression"
+	KindFunctionBody   = "function_body"
+	KindFunctionCall   = "function_call"
+	KindIdentifier     = "identifier"
+	KindMultiplicative = "multiplicative"
+	KindNumber         = "number"
+	KindParameterList  = "parameter_list"
+	KindPostfix        = "postfix"
+	KindPower          = "power"
+	KindPrimary        = "primary"
+	KindSourceFile     = "source_file"
+	KindStatement      = "statement"
+	KindUnary          = "unary"
+	KindBang           = "!"
+	KindPercent        = "%"
+	KindLParen         = "("
+	KindRParen         = ")"
+	KindStar           = "*"
+	KindPlus           = "+"
+	KindMinus          = "-"
+	KindSlash          = "/"
+	KindColon          = ":"
+	KindSemicolon      = ";"
+	KindLBracket       = "["
+	KindRBracket       = "]"
+	KindCaret          = "^"
+	KindLBrace         = "{"
+	KindRBrace         = "}"
+)
+
+// Field names, as returned by Node.FieldNameForChild.
+const (
+	FieldArg          = "arg"
+	FieldArgs         = "args"
+	FieldBase         = "base"
+	FieldBody         = "body"
+	FieldExponent     = "exponent"
+	FieldExpression   = "expression"
+	FieldFunction     = "function"
+	FieldLeft         = "left"
+	FieldLeftBrace    = "left_brace"
+	FieldLeftBracket  = "left_bracket"
+	FieldLeftParen    = "left_paren"
+	FieldName         = "name"
+	FieldOperand      = "operand"
+	FieldOperator     = "operator"
+	FieldParam        = "param"
+	FieldParams       = "params"
+	FieldRight        = "right"
+	FieldRightBrace   = "right_brace"
+	FieldRightBracket = "right_bracket"
+	FieldRightParen   = "right_paren"
+	FieldSeparator    = "separator"
+	FieldValue        = "value"
+)
+
+// Field ids, as used by Node.ChildByFieldId. tree-sitter numbers fields
+// from one in name order.
+const (
+	FieldIDArg          uint16 = 1
+	FieldIDArgs         uint16 = 2
+	FieldIDBase         uint16 = 3
+	FieldIDBody         uint16 = 4
+	FieldIDExponent     uint16 = 5
+	FieldIDExpression   uint16 = 6
+	FieldIDFunction     uint16 = 7
+	FieldIDLeft         uint16 = 8
+	FieldIDLeftBrace    uint16 = 9
+	FieldIDLeftBracket  uint16 = 10
+	FieldIDLeftParen    uint16 = 11
+	FieldIDName         uint16 = 12
+	FieldIDOperand      uint16 = 13
+	FieldIDOperator     uint16 = 14
+	FieldIDParam        uint16 = 15
+	FieldIDParams       uint16 = 16
+	FieldIDRight        uint16 = 17
+	FieldIDRightBrace   uint16 = 18
+	FieldIDRightBracket uint16 = 19
+	FieldIDRightParen   uint16 = 20
+	FieldIDSeparator    uint16 = 21
+	FieldIDValue        uint16 = 22
+)
+
+var nodeKinds = []KindInfo{
+	{Kind: KindAdditive, Named: true, Fields: []FieldInfo{
+		{Name: FieldLeft, Types: []string{KindAdditive}},
+		{Name: FieldOperator, Types: []string{KindPlus, KindMinus}},
+		{Name: FieldRight, Types: []string{KindMultiplicative}},
+	}, Children: &FieldInfo{Types: []string{KindMultiplicative}}},
+	{Kind: KindArgumentList, Named: true, Fields: []FieldInfo{
+		{Name: FieldArg, Multiple: true, Required: true, Types: []string{KindExpression}},
+		{Name: FieldSeparator, Multiple: true, Types: []string{KindSemicolon}},
+	}},
+	{Kind: KindAssignment, Named: true, Fields: []FieldInfo{
+		{Name: FieldName, Required: true, Types: []string{KindIdentifier}},
+		{Name: FieldOperator, Required: true, Types: []string{KindColon}},
+		{Name: FieldValue, Required: true, Types: []string{KindExpression, KindFunctionBody}},
+	}},
+	{Kind: KindComment, Named: true, Extra: true},
+	{Kind: KindExpression, Named: true, Children: &FieldInfo{Required: true, Types: []string{KindAdditive}}},
+	{Kind: KindFunctionBody, Named: true, Fields: []FieldInfo{
+		{Name: FieldBody, Required: true, Types: []string{KindExpression}},
+		{Name: FieldLeftBrace, Required: true, Types: []string{KindLBrace}},
+		{Name: FieldParams, Types: []string{KindParameterList}},
+		{Name: FieldRightBrace, Required: true, Types: []string{KindRBrace}},
+	}},
+	{Kind: KindFunctionCall, Named: true, Fields: []FieldInfo{
+		{Name: FieldArgs, Types: []string{KindArgumentList}},
+		{Name: FieldFunction, Required: true, Types: []string{KindIdentifier}},
+		{Name: FieldLeftBracket, Required: true, Types: []string{KindLBracket}},
+		{Name: FieldRightBracket, Required: true, Types: []string{KindRBracket}},
+	}},
+	{Kind: KindIdentifier, Named: true},
+	{Kind: KindMultiplicative, Named: true, Fields: []FieldInfo{
+		{Name: FieldLeft, Types: []string{KindMultiplicative}},
+		{Name: FieldOperator, Types: []string{KindPercent, KindStar, KindSlash}},
+		{Name: FieldRight, Types: []string{KindUnary}},
+	}, Children: &FieldInfo{Types: []string{KindUnary}}},
+	{Kind: KindNumber, Named: true},
+	{Kind: KindParameterList, Named: true, Fields: []FieldInfo{
+		{Name: FieldLeftBracket, Required: true, Types: []string{KindLBracket}},
+		{Name: FieldParam, Multiple: true, Types: []string{KindIdentifier}},
+		{Name: FieldRightBracket, Required: true, Types: []string{KindRBracket}},
+		{Name: FieldSeparator, Multiple: true, Types: []string{KindSemicolon}},
+	}},
+	{Kind: KindPostfix, Named: true, Fields: []FieldInfo{
+		{Name: FieldOperand, Types: []string{KindPostfix}},
+		{Name: FieldOperator, Types: []string{KindBang}},
+	}, Children: &FieldInfo{Types: []string{KindPrimary}}},
+	{Kind: KindPower, Named: true, Fields: []FieldInfo{
+		{Name: FieldBase, Types: []string{KindPostfix}},
+		{Name: FieldExponent, Types: []string{KindUnary}},
+		{Name: FieldOperator, Types: []string{KindCaret}},
+	}, Children: &FieldInfo{Types: []string{KindPostfix}}},
+	{Kind: KindPrimary, Named: true, Fields: []FieldInfo{
+		{Name: FieldExpression, Types: []string{KindExpression}},
+		{Name: FieldLeftParen, Types: []string{KindLParen}},
+		{Name: FieldRightParen, Types: []string{KindRParen}},
+	}, Children: &FieldInfo{Types: []string{KindFunctionCall, KindIdentifier, KindNumber}}},
+	{Kind: KindSourceFile, Named: true, Root: true, Children: &FieldInfo{Required: true, Types: []string{KindStatement}}},
+	{Kind: KindStatement, Named: true, Children: &FieldInfo{Required: true, Types: []string{KindAssignment, KindExpression}}},
+	{Kind: KindUnary, Named: true, Fields: []FieldInfo{
+		{Name: FieldOperand, Types: []string{KindUnary}},
+		{Name: FieldOperator, Types: []string{KindMinus}},
+	}, Children: &FieldInfo{Types: []string{KindPower}}},
+	{Kind: KindBang},
+	{Kind: KindPercent},
+	{Kind: KindLParen},
+	{Kind: KindRParen},
+	{Kind: KindStar},
+	{Kind: KindPlus},
+	{Kind: KindMinus},
+	{Kind: KindSlash},
+	{Kind: KindColon},
+	{Kind: KindSemicolon},
+	{Kind: KindLBracket},
+	{Kind: KindRBracket},
+	{Kind: KindCaret},
+	{Kind: KindLBrace},
+	{Kind: KindRBrace},
+}
@@ -0,0 +1,66 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+// TestNodeKinds checks the generated tables against the compiled grammar,
+// catching a node-types.json that was regenerated without go generate.
+func TestNodeKinds(t *testing.T) {
+	lang := tree_sitter.NewLanguage(tree_sitter_wabznasm.Language())
+	listed := map[uint16]bool{}
+	for _, k := range tree_sitter_wabznasm.NodeKinds() {
+		id := lang.IdForNodeKind(k.Kind, k.Named)
+		if id == 0 && !k.Supertype {
+			t.Errorf("kind %q (named %v) is not in the language", k.Kind, k.Named)
+			continue
+		}
+		listed[id] = true
+		if lang.NodeKindIsSupertype(id) != k.Supertype {
+			t.Errorf("kind %q: supertype = %v, want %v", k.Kind, k.Supertype, lang.NodeKindIsSupertype(id))
+		}
+		for _, f := range k.Fields {
+			if lang.FieldIdForName(f.Name) == 0 {
+				t.Errorf("kind %q: field %q is not in the language", k.Kind, f.Name)
+			}
+		}
+	}
+	for id := uint16(1); id < uint16(lang.NodeKindCount()); id++ {
+		if lang.NodeKindIsVisible(id) && !listed[lang.IdForNodeKind(lang.NodeKindForId(id), lang.NodeKindIsNamed(id))] {
+			t.Errorf("language kind %q (named %v) is missing from NodeKinds", lang.NodeKindForId(id), lang.NodeKindIsNamed(id))
+		}
+	}
+
+	for name, id := range map[string]uint16{
+		tree_sitter_wabznasm.FieldArg:   tree_sitter_wabznasm.FieldIDArg,
+		tree_sitter_wabznasm.FieldLeft:  tree_sitter_wabznasm.FieldIDLeft,
+		tree_sitter_wabznasm.FieldValue: tree_sitter_wabznasm.FieldIDValue,
+	} {
+		if got := lang.FieldIdForName(name); got != id {
+			t.Errorf("field %q has id %d, want %d", name, got, id)
+		}
+	}
+	if n := lang.FieldCount(); n != uint32(tree_sitter_wabznasm.FieldIDValue) {
+		t.Errorf("language has %d fields, the last generated id is %d", n, tree_sitter_wabznasm.FieldIDValue)
+	}
+}
+
+func TestLookupKind(t *testing.T) {
+	k, ok := tree_sitter_wabznasm.LookupKind(tree_sitter_wabznasm.KindAssignment, true)
+	if !ok {
+		t.Fatal("assignment not found")
+	}
+	f, ok := k.Field(tree_sitter_wabznasm.FieldValue)
+	if !ok || !f.Required || len(f.Types) != 2 {
+		t.Errorf("assignment value field = %+v, %v", f, ok)
+	}
+	if k, ok := tree_sitter_wabznasm.LookupKind(tree_sitter_wabznasm.KindComment, true); !ok || !k.Extra {
+		t.Errorf("comment = %+v, %v; want an extra", k, ok)
+	}
+	if _, ok := tree_sitter_wabznasm.LookupKind("+", true); ok {
+		t.Error(`"+" found as a named kind`)
+	}
+}
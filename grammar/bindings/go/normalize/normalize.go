@@ -0,0 +1,237 @@
+// Package normalize handles the ways a source file may be encoded other
+// than as plain UTF-8 with '\n' line endings: a leading byte order mark,
+// "\r\n" line endings, as Windows editors write them, and bytes that are
+// not valid UTF-8.
+//
+// Detect reports what a source carries. Normalize strips the byte order
+// mark, turns "\r\n" into '\n' and optionally replaces invalid bytes, so
+// that what is parsed has one line ending and no hidden prefix; the Text
+// it returns maps offsets of the normalized source back to the original,
+// for reporting. Restore puts the byte order mark and line endings of the
+// original back on text produced from the normalized source, such as the
+// formatter's output:
+//
+//	t := normalize.Normalize(src, normalize.Options{})
+//	out, err := format.Source(t.Src)
+//	out = t.Restore(out)
+//
+// A lone '\r' is not a line ending, and is left as it is.
+package normalize
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+)
+
+// BOM is the UTF-8 encoding of the byte order mark.
+const BOM = "\xef\xbb\xbf"
+
+// LineEnding is the way lines of a source end.
+type LineEnding int
+
+const (
+	LF LineEnding = iota
+	CRLF
+)
+
+func (e LineEnding) String() string {
+	if e == CRLF {
+		return "crlf"
+	}
+	return "lf"
+}
+
+// Newline returns the bytes ending a line.
+func (e LineEnding) Newline() string {
+	if e == CRLF {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// Invalid is a run of bytes that are not valid UTF-8, from byte Start to
+// byte End of the original source.
+type Invalid struct {
+	Start, End uint
+}
+
+// Info describes how a source is encoded.
+type Info struct {
+	// BOM reports a leading byte order mark.
+	BOM bool
+	// LineEnding is the ending of most lines. A source with as many of
+	// each, or none, ends its lines with LF.
+	LineEnding LineEnding
+	// Mixed reports lines ending with each of LF and CRLF.
+	Mixed bool
+	// Invalid lists the runs of invalid UTF-8 in source order.
+	Invalid []Invalid
+}
+
+// Detect describes the encoding of src.
+func Detect(src []byte) Info {
+	info := Info{BOM: bytes.HasPrefix(src, []byte(BOM))}
+	info.LineEnding, info.Mixed = lineEndings(src)
+	for i := 0; i < len(src); {
+		if src[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, n := utf8.DecodeRune(src[i:])
+		if r != utf8.RuneError || n != 1 {
+			i += n
+			continue
+		}
+		if k := len(info.Invalid) - 1; k >= 0 && info.Invalid[k].End == uint(i) {
+			info.Invalid[k].End++
+		} else {
+			info.Invalid = append(info.Invalid, Invalid{uint(i), uint(i) + 1})
+		}
+		i++
+	}
+	return info
+}
+
+// DetectLineEnding returns the ending of most lines of src, as Detect
+// does, without checking its encoding.
+func DetectLineEnding(src []byte) LineEnding {
+	e, _ := lineEndings(src)
+	return e
+}
+
+func lineEndings(src []byte) (LineEnding, bool) {
+	lf := bytes.Count(src, []byte("\n"))
+	crlf := bytes.Count(src, []byte("\r\n"))
+	lf -= crlf
+	e := LF
+	if crlf > lf {
+		e = CRLF
+	}
+	return e, lf > 0 && crlf > 0
+}
+
+// Err returns an *InvalidError for the first run of invalid UTF-8, or nil
+// if there is none.
+func (info Info) Err() error {
+	if len(info.Invalid) == 0 {
+		return nil
+	}
+	return &InvalidError{info.Invalid[0]}
+}
+
+// InvalidError reports bytes that are not valid UTF-8.
+type InvalidError struct {
+	Invalid
+}
+
+func (e *InvalidError) Error() string {
+	return fmt.Sprintf("invalid UTF-8 at byte %d", e.Start)
+}
+
+// Options configure Normalize.
+type Options struct {
+	// ReplaceInvalid replaces each run of invalid UTF-8 with one U+FFFD
+	// replacement character. Otherwise invalid bytes are kept, and only
+	// reported.
+	ReplaceInvalid bool
+}
+
+// Text is a normalized source.
+type Text struct {
+	Info
+	// Src is the normalized source: the original itself if it needed no
+	// change.
+	Src []byte
+	// segments map offsets of Src to offsets of the original, in order.
+	segments []segment
+	length   uint
+}
+
+// segment maps the normalized bytes from norm on to the original bytes
+// from orig on. The first n bytes are copied, and map one to one; the rest,
+// up to the next segment, replaced original bytes and map to orig+n.
+type segment struct {
+	norm, orig, n uint
+}
+
+// Normalize returns the normalized form of src.
+func Normalize(src []byte, opts Options) *Text {
+	t := &Text{Info: Detect(src), length: uint(len(src))}
+	start := uint(0)
+	if t.BOM {
+		start = uint(len(BOM))
+	}
+	invalid := t.Invalid
+	if !opts.ReplaceInvalid {
+		invalid = nil
+	}
+	crlf := t.LineEnding == CRLF || t.Mixed
+	if start == 0 && len(invalid) == 0 && !crlf {
+		t.Src = src
+		t.segments = []segment{{0, 0, t.length}}
+		return t
+	}
+
+	out := make([]byte, 0, len(src))
+	seg := segment{0, start, 0}
+	// replace ends seg at orig, replacing the original bytes up to end with
+	// text.
+	replace := func(orig, end uint, text string) {
+		seg.n = orig - seg.orig
+		out = append(out, src[seg.orig:orig]...)
+		t.segments = append(t.segments, seg)
+		t.segments = append(t.segments, segment{uint(len(out)), orig, 0})
+		out = append(out, text...)
+		seg = segment{uint(len(out)), end, 0}
+	}
+	for i := start; i < uint(len(src)); i++ {
+		switch {
+		case len(invalid) > 0 && invalid[0].Start == i:
+			replace(i, invalid[0].End, "\uFFFD")
+			i = invalid[0].End - 1
+			invalid = invalid[1:]
+		case src[i] == '\r' && i+1 < uint(len(src)) && src[i+1] == '\n':
+			replace(i, i+2, "\n")
+			i++
+		}
+	}
+	seg.n = t.length - seg.orig
+	t.segments = append(t.segments, seg)
+	t.Src = append(out, src[seg.orig:]...)
+	return t
+}
+
+// Original returns the offset in the original source of offset in Src.
+// An offset inside a replacement maps to the start of what it replaced,
+// so the '\n' of a "\r\n" maps to its '\r'. Offsets past the end of Src
+// clamp to the end of the original.
+func (t *Text) Original(offset uint) uint {
+	i := sort.Search(len(t.segments), func(i int) bool { return t.segments[i].norm > offset }) - 1
+	s := t.segments[max(i, 0)]
+	return s.orig + min(offset-s.norm, s.n)
+}
+
+// Restore returns text, derived from the normalized source, with the byte
+// order mark and line ending of the original. Lines of a source with
+// mixed endings all get its prevailing one.
+func (info Info) Restore(text []byte) []byte {
+	if !info.BOM && info.LineEnding == LF {
+		return text
+	}
+	out := make([]byte, 0, len(text)+len(BOM))
+	if info.BOM && !bytes.HasPrefix(text, []byte(BOM)) {
+		out = append(out, BOM...)
+	}
+	if info.LineEnding == LF {
+		return append(out, text...)
+	}
+	for i, b := range text {
+		if b == '\n' && (i == 0 || text[i-1] != '\r') {
+			out = append(out, '\r')
+		}
+		out = append(out, b)
+	}
+	return out
+}
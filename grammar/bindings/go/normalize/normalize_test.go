@@ -0,0 +1,88 @@
+package normalize_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/normalize"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		src  string
+		want normalize.Info
+	}{
+		{"", normalize.Info{}},
+		{"x: 1\ny: 2\n", normalize.Info{}},
+		{"x: 1\r\ny: 2\r\n", normalize.Info{LineEnding: normalize.CRLF}},
+		{"x: 1\r\ny: 2\r\nz\n", normalize.Info{LineEnding: normalize.CRLF, Mixed: true}},
+		{"x: 1\r\ny: 2\n", normalize.Info{Mixed: true}},
+		{"x\ry", normalize.Info{}},
+		{"\xef\xbb\xbfx", normalize.Info{BOM: true}},
+		{"a\xffb\xc3\xa9\xe2\x82c\xc3", normalize.Info{Invalid: []normalize.Invalid{{1, 2}, {5, 7}, {8, 9}}}},
+		{"\xff\xfe\xfd", normalize.Info{Invalid: []normalize.Invalid{{0, 3}}}},
+	}
+	for _, tt := range tests {
+		if got := normalize.Detect([]byte(tt.src)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Detect(%q) = %+v, want %+v", tt.src, got, tt.want)
+		}
+	}
+
+	var ie *normalize.InvalidError
+	if err := normalize.Detect([]byte("ok\xff")).Err(); !errors.As(err, &ie) || ie.Start != 2 || err.Error() != "invalid UTF-8 at byte 2" {
+		t.Errorf("Err() = %v", err)
+	}
+	if err := normalize.Detect([]byte("ok")).Err(); err != nil {
+		t.Errorf("Err() = %v", err)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		src     string
+		opts    normalize.Options
+		want    string
+		offsets map[uint]uint
+	}{
+		{"x: 1\n", normalize.Options{}, "x: 1\n", map[uint]uint{0: 0, 5: 5, 9: 5}},
+		{"\xef\xbb\xbfx: 1", normalize.Options{}, "x: 1", map[uint]uint{0: 3, 1: 4, 4: 7}},
+		// The '\n' of "\r\n" maps to its '\r'.
+		{"ab\r\ncd\r\n\r\n", normalize.Options{}, "ab\ncd\n\n", map[uint]uint{1: 1, 2: 2, 3: 4, 5: 6, 6: 8, 7: 10, 8: 10}},
+		{"a\rb\r\n", normalize.Options{}, "a\rb\n", map[uint]uint{1: 1, 2: 2, 3: 3, 4: 5}},
+		{"a\xffb", normalize.Options{}, "a\xffb", map[uint]uint{1: 1, 2: 2}},
+		{"a\xff\xfeb\xc3", normalize.Options{ReplaceInvalid: true}, "a\uFFFDb\uFFFD", map[uint]uint{1: 1, 2: 1, 3: 1, 4: 3, 5: 4, 8: 5}},
+		{"\xef\xbb\xbf\xff\r\n", normalize.Options{ReplaceInvalid: true}, "\uFFFD\n", map[uint]uint{0: 3, 3: 4, 4: 6}},
+	}
+	for _, tt := range tests {
+		n := normalize.Normalize([]byte(tt.src), tt.opts)
+		if string(n.Src) != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.src, n.Src, tt.want)
+		}
+		for off, want := range tt.offsets {
+			if got := n.Original(off); got != want {
+				t.Errorf("Normalize(%q).Original(%d) = %d, want %d", tt.src, off, got, want)
+			}
+		}
+	}
+}
+
+func TestRestore(t *testing.T) {
+	for _, src := range []string{"", "x: 1\n", "\xef\xbb\xbfx: 1\r\ny: 2\r\n", "a\r\n\r\nb", "\xef\xbb\xbfa\nb"} {
+		n := normalize.Normalize([]byte(src), normalize.Options{})
+		if got := n.Restore(n.Src); string(got) != src {
+			t.Errorf("Restore(Normalize(%q)) = %q", src, got)
+		}
+	}
+
+	info := normalize.Detect([]byte("a\r\nb\r\nc\n"))
+	if got, want := info.Restore([]byte("a\nb\r\nc\n")), "a\r\nb\r\nc\r\n"; string(got) != want {
+		t.Errorf("Restore = %q, want %q", got, want)
+	}
+	if got, want := normalize.CRLF.Newline(), "\r\n"; got != want {
+		t.Errorf("Newline = %q", got)
+	}
+	if got := normalize.DetectLineEnding([]byte("a\r\nb")); got != normalize.CRLF || got.String() != "crlf" {
+		t.Errorf("DetectLineEnding = %v", got)
+	}
+}
@@ -0,0 +1,241 @@
+// Package optimize simplifies wabznasm sources: constant arithmetic is
+// folded into literals and operations that leave their operand unchanged
+// are removed.
+//
+// Folding follows the evaluator's integer arithmetic. An operation the
+// evaluator would reject, such as a division by zero or an overflow, is
+// left in place so that evaluating the simplified source reports the same
+// error. Identities assume numeric operands: x+0, 0+x, x-0, x*1, 1*x, x/1
+// and x^1 become x. Parentheses around an atom are dropped.
+//
+// Spacing and comments are kept outside the folded subexpressions; a
+// comment inside a subexpression that folds into a literal is dropped with
+// it.
+package optimize
+
+import (
+	"strconv"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// Kind classifies a simplification.
+type Kind int
+
+const (
+	// Fold replaces a constant subexpression by its value.
+	Fold Kind = iota
+	// Identity removes an operation that leaves its operand unchanged.
+	Identity
+	// Paren removes parentheses around an atom.
+	Paren
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Fold:
+		return "fold"
+	case Identity:
+		return "identity"
+	case Paren:
+		return "paren"
+	}
+	return "unknown"
+}
+
+// Change is one simplification step. Steps are listed innermost first, so
+// Old shows a node with the changes to its operands already made.
+type Change struct {
+	Kind Kind
+	// Span is the node's range in the original source.
+	Span ast.Span
+	Old  string
+	New  string
+}
+
+// Result is a simplified source and the steps that produced it.
+type Result struct {
+	Source  []byte
+	Changes []Change
+}
+
+// Source parses and simplifies source.
+func Source(source []byte) (Result, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return Result{}, err
+	}
+	defer parser.Close()
+	tree, err := parser.ParseBytes(source)
+	if err != nil {
+		return Result{}, err
+	}
+	defer tree.Close()
+	return File(ast.FromTree(tree.Tree, source), source)
+}
+
+// File simplifies f, the typed tree of source.
+func File(f *ast.File, source []byte) (Result, error) {
+	var e ast.Expr
+	switch s := f.Stmt.(type) {
+	case *ast.Assignment:
+		e = s.Value
+	case *ast.ExprStmt:
+		e = s.X
+	}
+	s := &simplifier{source: source}
+	if e == nil {
+		return Result{Source: source}, nil
+	}
+	r := s.expr(e, false)
+	if r.text == ast.Text(e, source) {
+		return Result{Source: source, Changes: s.changes}, nil
+	}
+	out, err := ast.Apply(source, []ast.Edit{{Start: e.Pos(), End: e.EndPos(), NewText: r.text}})
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Source: out, Changes: s.changes}, nil
+}
+
+type simplifier struct {
+	source  []byte
+	changes []Change
+}
+
+// result is a simplified expression: its text and, if it is a constant,
+// its value. Atom is set if the text needs no parentheses anywhere.
+type result struct {
+	text  string
+	value *int64
+	atom  bool
+}
+
+// expr simplifies e. Tight is set where the grammar needs a postfix
+// expression, as the base of ^ and the operand of !, so that a negative
+// constant has to stay in parentheses.
+func (s *simplifier) expr(e ast.Expr, tight bool) result {
+	switch e := e.(type) {
+	case nil:
+		return result{}
+	case *ast.Ident:
+		return result{text: e.Name, atom: true}
+	case *ast.NumberLiteral:
+		if e.Err != nil {
+			return result{text: e.Text, atom: true}
+		}
+		return result{text: e.Text, value: &e.Value, atom: true}
+	case *ast.UnaryExpr:
+		operand := s.expr(e.Operand, false)
+		r := result{text: s.splice(e, part{e.Operand, operand})}
+		if operand.value != nil {
+			return s.fold(e, r, tight, func() (eval.Value, error) { return eval.Negate(eval.Long(*operand.value)) })
+		}
+		return r
+	case *ast.PostfixExpr:
+		operand := s.expr(e.Operand, true)
+		r := result{text: s.splice(e, part{e.Operand, operand})}
+		if operand.value != nil {
+			return s.fold(e, r, tight, func() (eval.Value, error) { return eval.Factorial(eval.Long(*operand.value)) })
+		}
+		return r
+	case *ast.BinaryExpr:
+		left := s.expr(e.Left, e.Op == "^")
+		right := s.expr(e.Right, false)
+		r := result{text: s.splice(e, part{e.Left, left}, part{e.Right, right})}
+		if left.value != nil && right.value != nil {
+			return s.fold(e, r, tight, func() (eval.Value, error) {
+				return eval.Binary(e.Op, eval.Long(*left.value), eval.Long(*right.value))
+			})
+		}
+		if kept, ok := identity(e.Op, left, right); ok {
+			s.log(Identity, e, r.text, kept.text)
+			return kept
+		}
+		return r
+	case *ast.ParenExpr:
+		inner := s.expr(e.X, false)
+		r := result{text: s.splice(e, part{e.X, inner}), atom: true}
+		switch {
+		case inner.value != nil && (*inner.value >= 0 || !tight):
+			s.log(Paren, e, r.text, inner.text)
+			return inner
+		case inner.value != nil:
+			r.value = inner.value
+		case inner.atom:
+			s.log(Paren, e, r.text, inner.text)
+			return inner
+		}
+		return r
+	case *ast.Call:
+		parts := make([]part, len(e.Args))
+		for i, arg := range e.Args {
+			parts[i] = part{arg, s.expr(arg, false)}
+		}
+		return result{text: s.splice(e, parts...), atom: true}
+	case *ast.FunctionDef:
+		return result{text: s.splice(e, part{e.Body, s.expr(e.Body, false)})}
+	}
+	return result{text: ast.Text(e, s.source)}
+}
+
+// fold replaces the constant expression e, whose text is r, by its value
+// unless evaluating it fails.
+func (s *simplifier) fold(e ast.Expr, r result, tight bool, apply func() (eval.Value, error)) result {
+	v, err := apply()
+	long, ok := v.(eval.Long)
+	if err != nil || !ok {
+		return r
+	}
+	n := int64(long)
+	text := strconv.FormatInt(n, 10)
+	if n < 0 && tight {
+		text = "(" + text + ")"
+	}
+	if text != r.text {
+		s.log(Fold, e, r.text, text)
+	}
+	return result{text: text, value: &n, atom: n >= 0 || tight}
+}
+
+// identity returns the operand an operation with a neutral constant leaves
+// unchanged.
+func identity(op string, left, right result) (result, bool) {
+	is := func(r result, n int64) bool { return r.value != nil && *r.value == n }
+	switch {
+	case op == "+" && is(right, 0), op == "-" && is(right, 0),
+		op == "*" && is(right, 1), op == "/" && is(right, 1), op == "^" && is(right, 1):
+		return left, true
+	case op == "+" && is(left, 0), op == "*" && is(left, 1):
+		return right, true
+	}
+	return result{}, false
+}
+
+func (s *simplifier) log(kind Kind, n ast.Node, old, new string) {
+	s.changes = append(s.changes, Change{Kind: kind, Span: ast.Span{Start: n.Pos(), End: n.EndPos()}, Old: old, New: new})
+}
+
+// part is a child of a node and its simplified form.
+type part struct {
+	node ast.Node
+	r    result
+}
+
+// splice returns the text of n with its children replaced by their
+// simplified text. Parts are in source order; a nil child is skipped.
+func (s *simplifier) splice(n ast.Node, parts ...part) string {
+	var b []byte
+	last := n.Pos().Offset
+	for _, p := range parts {
+		if p.node == nil || p.node.Pos().Offset < last {
+			continue
+		}
+		b = append(b, s.source[last:p.node.Pos().Offset]...)
+		b = append(b, p.r.text...)
+		last = p.node.EndPos().Offset
+	}
+	return string(append(b, s.source[last:n.EndPos().Offset]...))
+}
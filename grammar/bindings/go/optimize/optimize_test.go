@@ -0,0 +1,94 @@
+package optimize_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/optimize"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func TestSource(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"1+2*3", "7"},
+		{"x: (1+2)*y", "x: 3*y"},
+		{"f: {[a] a*(2^3-8+1)}", "f: {[a] a}"},
+		{"x+0", "x"},
+		{"0 + x * 1", "x"},
+		{"a-(b-0)", "a-b"},
+		{"a-(b+c)*1", "a-(b+c)"},
+		{"((x))", "x"},
+		{"x*(2-5)", "x*-3"},
+		{"(2-5)^2", "9"},
+		{"3!+y", "6+y"},
+		{"y^(1-2)", "y^-1"},
+		{"f[1+1; g[2*2]]", "f[2; g[4]]"},
+		{"x: 1/0", "x: 1/0"},
+		{"9223372036854775807+1", "9223372036854775807+1"},
+		{"21!", "21!"},
+		{"x: 2 \\ two", "x: 2 \\ two"},
+		{"x:  1 + \t2", "x:  3"},
+		{"\\ only a comment", "\\ only a comment"},
+	}
+	for _, tt := range tests {
+		res, err := optimize.Source([]byte(tt.src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(res.Source); got != tt.want {
+			t.Errorf("Source(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestChanges(t *testing.T) {
+	res, err := optimize.Source([]byte("x*((1+2)*3+0)"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		kind     optimize.Kind
+		old, new string
+	}{
+		{optimize.Fold, "1+2", "3"},
+		{optimize.Paren, "(3)", "3"},
+		{optimize.Fold, "3*3", "9"},
+		{optimize.Fold, "9+0", "9"},
+		{optimize.Paren, "(9)", "9"},
+	}
+	if len(res.Changes) != len(want) {
+		t.Fatalf("changes = %+v", res.Changes)
+	}
+	for i, w := range want {
+		c := res.Changes[i]
+		if c.Kind != w.kind || c.Old != w.old || c.New != w.new {
+			t.Errorf("change %d = %v %q -> %q, want %v %q -> %q", i, c.Kind, c.Old, c.New, w.kind, w.old, w.new)
+		}
+	}
+	if c := res.Changes[0]; c.Span.Start.Offset != 4 || c.Span.End.Offset != 7 {
+		t.Errorf("span of first change = %+v", c.Span)
+	}
+}
+
+// Simplifying must not change what a source evaluates to.
+func TestPreservesValue(t *testing.T) {
+	for _, src := range []string{"2-3-4", "2^3^2", "-2^2", "(0-2)^3", "10%3*4", "7/2+1", "-(5-3)!", "2*-3!", "1-(-1)"} {
+		res, err := optimize.Source([]byte(src))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := eval.New().EvalString(src)
+		if err != nil {
+			t.Fatalf("%s: %v", src, err)
+		}
+		got, err := eval.New().EvalString(string(res.Source))
+		if err != nil || got.String() != want.String() {
+			t.Errorf("%s simplified to %s = %v, %v; want %v", src, res.Source, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Package outline lists the definitions of a wabznasm source as a tree
+// of symbols, for editor outlines and breadcrumbs and the LSP
+// textDocument/documentSymbol response.
+//
+// A source is a single statement, so its outline is the assignment that
+// statement makes, if any. A function's explicit parameters are its
+// children; the implicit x, y and z are not declared anywhere and are not
+// listed.
+package outline
+
+import (
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+// Kind classifies a symbol.
+type Kind int
+
+const (
+	// Variable is a name assigned a value other than a function.
+	Variable Kind = iota
+	// Function is a name assigned a function.
+	Function
+	// Parameter is an explicit parameter of a function.
+	Parameter
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Variable:
+		return "variable"
+	case Function:
+		return "function"
+	case Parameter:
+		return "parameter"
+	}
+	return "unknown"
+}
+
+// Symbol is a definition and the definitions nested in it.
+type Symbol struct {
+	Name string
+	Kind Kind
+	// Detail is the signature of a function, such as "[x;y]", and empty
+	// otherwise.
+	Detail string
+	// Span covers the whole definition, and Selection its name.
+	Span      ast.Span
+	Selection ast.Span
+	Children  []Symbol
+}
+
+// Of returns the outline of tree, whose text is source.
+func Of(tree *tree_sitter.Tree, source []byte) []Symbol {
+	return File(ast.FromTree(tree, source))
+}
+
+// File returns the outline of f.
+func File(f *ast.File) []Symbol {
+	a, ok := f.Stmt.(*ast.Assignment)
+	if !ok || a.Name == nil {
+		return nil
+	}
+	return []Symbol{assignment(a)}
+}
+
+func assignment(a *ast.Assignment) Symbol {
+	sym := Symbol{Name: a.Name.Name, Kind: Variable, Span: a.Span, Selection: a.Name.Span}
+	fn, ok := a.Value.(*ast.FunctionDef)
+	if !ok {
+		return sym
+	}
+	sym.Kind = Function
+	sym.Detail = "[" + strings.Join(fn.Signature(), ";") + "]"
+	if fn.Params != nil {
+		for _, p := range fn.Params.Names {
+			sym.Children = append(sym.Children, Symbol{Name: p.Name, Kind: Parameter, Span: p.Span, Selection: p.Span})
+		}
+	}
+	return sym
+}
+
+// Path returns the symbols enclosing the byte at offset, outermost first,
+// as an editor's breadcrumbs show them.
+func Path(symbols []Symbol, offset uint) []Symbol {
+	var path []Symbol
+	for len(symbols) > 0 {
+		found := false
+		for _, s := range symbols {
+			if s.Span.Start.Offset <= offset && offset < s.Span.End.Offset {
+				path = append(path, s)
+				symbols, found = s.Children, true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+	return path
+}
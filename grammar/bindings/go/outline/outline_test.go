@@ -0,0 +1,83 @@
+package outline_test
+
+import (
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/outline"
+)
+
+func parse(t *testing.T, src string) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(parser.Close)
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+// render writes symbols as name:kind:detail:span:selection, children in
+// braces.
+func render(src string, symbols []outline.Symbol) string {
+	var b strings.Builder
+	for i, s := range symbols {
+		if i > 0 {
+			b.WriteString(" ")
+		}
+		b.WriteString(s.Name + ":" + s.Kind.String() + ":" + s.Detail + ":")
+		b.WriteString(src[s.Span.Start.Offset:s.Span.End.Offset] + ":" + src[s.Selection.Start.Offset:s.Selection.End.Offset])
+		if len(s.Children) > 0 {
+			b.WriteString(" {" + render(src, s.Children) + "}")
+		}
+	}
+	return b.String()
+}
+
+func TestOf(t *testing.T) {
+	tests := []struct{ src, want string }{
+		{"", ""},
+		{"\\ only a comment", ""},
+		{"1+2", ""},
+		{"n: 42", "n:variable::n: 42:n"},
+		{"f: {[a;b] a+b} \\ add", "f:function:[a;b]:f: {[a;b] a+b}:f {a:parameter::a:a b:parameter::b:b}"},
+		{"g: {x*y}", "g:function:[x;y]:g: {x*y}:g"},
+	}
+	for _, tt := range tests {
+		if got := render(tt.src, outline.Of(parse(t, tt.src).Tree, []byte(tt.src))); got != tt.want {
+			t.Errorf("Of(%q) = %q, want %q", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestPath(t *testing.T) {
+	src := "f: {[a;b] a+b}"
+	symbols := outline.Of(parse(t, src).Tree, []byte(src))
+	tests := []struct {
+		at   string
+		want []string
+	}{
+		{"f:", []string{"f"}},
+		{"b]", []string{"f", "b"}},
+		{"a+b", []string{"f"}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, s := range outline.Path(symbols, uint(strings.Index(src, tt.at))) {
+			got = append(got, s.Name)
+		}
+		if strings.Join(got, ">") != strings.Join(tt.want, ">") {
+			t.Errorf("Path(%q) = %q, want %q", tt.at, got, tt.want)
+		}
+	}
+	if got := outline.Path(symbols, uint(len(src))); len(got) != 0 {
+		t.Errorf("Path(end) = %v", got)
+	}
+}
@@ -0,0 +1,97 @@
+package tree_sitter_wabznasm
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+	"unsafe"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+var (
+	// ErrParserClosed is returned when a Parser is used after Close.
+	ErrParserClosed = errors.New("wabznasm: parser is closed")
+	// ErrParseFailed is returned when tree-sitter produces no tree.
+	ErrParseFailed = errors.New("wabznasm: parse failed")
+)
+
+// Parser is a tree-sitter parser with the wabznasm language already set.
+// It and the trees it returns are accounted for by package memtrack.
+// A Parser is not safe for concurrent use.
+type Parser struct {
+	inner   *tree_sitter.Parser
+	timeout time.Duration
+	// lang is the language the parser was made with.
+	lang unsafe.Pointer
+	mem  *memtrack.Handle
+}
+
+// NewParser returns a parser ready to parse wabznasm source. The caller must
+// call Close to release the underlying C parser. It returns an *ABIError if
+// the linked tree-sitter runtime cannot load the grammar.
+func NewParser() (*Parser, error) {
+	ptr := Language()
+	lang := tree_sitter.NewLanguage(ptr)
+	if lang != nil {
+		if err := CheckABI(lang); err != nil {
+			return nil, err
+		}
+	}
+	inner := tree_sitter.NewParser()
+	if err := inner.SetLanguage(lang); err != nil {
+		inner.Close()
+		return nil, err
+	}
+	return &Parser{inner: inner, lang: ptr, mem: memtrack.Open(memtrack.Parser)}, nil
+}
+
+// ParseString parses src. The returned tree must be closed by the caller.
+func (p *Parser) ParseString(src string) (*memtrack.TrackedTree, error) {
+	return p.ParseBytes([]byte(src))
+}
+
+// ParseBytes parses src. The returned tree must be closed by the caller and
+// src must not be modified while the tree is in use.
+func (p *Parser) ParseBytes(src []byte) (*memtrack.TrackedTree, error) {
+	return p.Reparse(src, nil)
+}
+
+// Reparse parses src reusing old, a tree for the previous version of the
+// document that has already been adjusted with Tree.Edit. A nil old tree
+// parses from scratch.
+func (p *Parser) Reparse(src []byte, old *tree_sitter.Tree) (*memtrack.TrackedTree, error) {
+	return p.ReparseContext(context.Background(), src, old)
+}
+
+// ParseReader reads all of r and parses it, returning the tree together with
+// the source it was parsed from.
+func (p *Parser) ParseReader(r io.Reader) (*memtrack.TrackedTree, []byte, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	tree, err := p.ParseBytes(src)
+	if err != nil {
+		return nil, nil, err
+	}
+	return tree, src, nil
+}
+
+// Raw returns the underlying tree-sitter parser for options this type does
+// not wrap. It returns nil after Close.
+func (p *Parser) Raw() *tree_sitter.Parser {
+	return p.inner
+}
+
+// Close releases the parser. Calling Close more than once is a no-op.
+func (p *Parser) Close() {
+	if p.inner == nil {
+		return
+	}
+	p.inner.Close()
+	p.inner = nil
+	p.mem.Close()
+}
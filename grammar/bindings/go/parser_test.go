@@ -0,0 +1,93 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+func TestParserParseString(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+
+	tree, err := parser.ParseString("add: {[x;y] x+y}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	if tree.RootNode().HasError() {
+		t.Errorf("unexpected syntax error: %s", tree.RootNode().ToSexp())
+	}
+}
+
+func TestParserParseReader(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+
+	tree, src, err := parser.ParseReader(strings.NewReader("f[1;2]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	if string(src) != "f[1;2]" {
+		t.Errorf("source = %q", src)
+	}
+	if got := tree.RootNode().EndByte(); got != 6 {
+		t.Errorf("root end = %d, want 6", got)
+	}
+}
+
+func TestParserParseScript(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+
+	src := "\\ squares\nsq: {x*x}\n\nn: sq[3]\nsq[n\n"
+	s, err := parser.ParseScript([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	if len(s.Statements) != 3 {
+		t.Fatalf("ParseScript(%q) has %d statements, want 3", src, len(s.Statements))
+	}
+	for i, want := range []string{"sq: {x*x}", "n: sq[3]", "sq[n"} {
+		st := s.Statements[i]
+		if st.Text != want || src[st.Offset:st.Offset+len(st.Text)] != want {
+			t.Errorf("statement %d = %q at %d, want %q", i, st.Text, st.Offset, want)
+		}
+		if root := st.Tree.RootNode(); root.HasError() != (i == 2) {
+			t.Errorf("statement %d: HasError = %v: %s", i, root.HasError(), root.ToSexp())
+		}
+	}
+	// Positions in the trees of the statements are positions in src.
+	if got := s.At(uint(strings.Index(src, "sq[3]"))); got != &s.Statements[1] {
+		t.Errorf("At(sq[3]) = %q", got.Text)
+	}
+	d := s.Diagnostics()
+	if len(d) != 1 || d[0].Range.StartPoint.Row != 4 {
+		t.Errorf("Diagnostics = %+v, want one on line 5", d)
+	}
+}
+
+func TestParserClose(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser.Close()
+	parser.Close()
+	if _, err := parser.ParseString("1"); !errors.Is(err, tree_sitter_wabznasm.ErrParserClosed) {
+		t.Errorf("err = %v, want ErrParserClosed", err)
+	}
+}
@@ -0,0 +1,423 @@
+// Package pattern implements structural search and replace.
+//
+// A pattern is wabznasm source in which metavariables stand for any
+// subexpression or name:
+//
+//	$F[$X; $Y]      a call of two arguments
+//	$A + $A         an addition of something to itself
+//	{[$P] $P * $_}  a one-parameter function multiplying its parameter
+//
+// A metavariable is $ followed by upper-case letters, digits or
+// underscores. Repeating one requires the same code, ignoring spacing and
+// comments, at each place; $_ matches anything and binds nothing. Spacing
+// and comments in the pattern do not matter.
+//
+// Compile turns a pattern into a tree-sitter query that finds candidates,
+// and each candidate is then checked against the pattern exactly.
+package pattern
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+var (
+	// ErrPattern is returned, wrapped with details, for a pattern that is
+	// not valid wabznasm once metavariables are substituted.
+	ErrPattern = errors.New("pattern: invalid pattern")
+	// ErrUnboundMetavar is returned by Replace when the template uses a
+	// metavariable the pattern does not bind.
+	ErrUnboundMetavar = errors.New("pattern: template uses unbound metavariable")
+)
+
+var metavar = regexp.MustCompile(`\$([A-Z_][A-Z0-9_]*)`)
+
+// placeholder prefixes the identifiers metavariables are parsed as.
+const placeholder = "__wabznasm_meta_"
+
+// Wildcard is the metavariable that matches anything without binding.
+const Wildcard = "_"
+
+// Pattern is a compiled structural pattern.
+type Pattern struct {
+	source   string
+	root     *node
+	query    string
+	metavars []string
+}
+
+// node is a pattern tree node. A metavariable slot has meta set and no
+// children; it matches any node of its kind.
+type node struct {
+	kind     string
+	named    bool
+	field    string
+	text     string // identifier and number leaves
+	meta     string
+	capture  string
+	children []*node
+}
+
+// Compile parses a pattern.
+func Compile(pattern string) (*Pattern, error) {
+	text := metavar.ReplaceAllString(pattern, placeholder+"$1")
+	if strings.Contains(text, "$") {
+		return nil, fmt.Errorf("%w: stray $ in %q", ErrPattern, pattern)
+	}
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	src := []byte(text)
+	tree, err := parser.ParseBytes(src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	var top *tree_sitter.Node
+	if diags := tree_sitter_wabznasm.Diagnostics(tree.Tree, src); diags != nil {
+		// Function literals only parse as assigned values, so retry the
+		// pattern as the value of an assignment.
+		wrapped := []byte(placeholder + ": " + text)
+		wtree, werr := parser.ParseBytes(wrapped)
+		if werr != nil || wtree.RootNode().HasError() {
+			if wtree != nil {
+				wtree.Close()
+			}
+			return nil, fmt.Errorf("%w: %s", ErrPattern, diags[0].Message)
+		}
+		defer wtree.Close()
+		src = wrapped
+		top = statement(wtree.RootNode()).ChildByFieldName("value")
+	} else if stmt := statement(tree.RootNode()); stmt != nil {
+		top = stmt
+	}
+	if top == nil {
+		return nil, fmt.Errorf("%w: empty pattern", ErrPattern)
+	}
+	// Root the pattern at the innermost node of its wrapper chain, so that
+	// f[1] matches calls at any precedence level.
+	for slot(top, src) == "" {
+		c := onlyChild(top)
+		if c == nil {
+			break
+		}
+		top = c
+	}
+
+	p := &Pattern{source: pattern}
+	p.root = p.convert(top, "", src)
+	var preds []string
+	captures := 0
+	p.query = "(" + emit(p.root, &captures, &preds) + " @match" + strings.Join(preds, "") + ")"
+	return p, nil
+}
+
+// MustCompile is like Compile but panics on error.
+func MustCompile(pattern string) *Pattern {
+	p, err := Compile(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// String returns the pattern source.
+func (p *Pattern) String() string { return p.source }
+
+// Query returns the tree-sitter query that finds candidate matches.
+func (p *Pattern) Query() string { return p.query }
+
+// Metavars returns the names of the metavariables the pattern binds, in
+// order of first appearance.
+func (p *Pattern) Metavars() []string { return p.metavars }
+
+func (p *Pattern) convert(n *tree_sitter.Node, field string, src []byte) *node {
+	out := &node{kind: n.Kind(), named: n.IsNamed(), field: field}
+	if name := slot(n, src); name != "" {
+		out.meta = name
+		if name != Wildcard && !contains(p.metavars, name) {
+			p.metavars = append(p.metavars, name)
+		}
+		return out
+	}
+	if n.ChildCount() == 0 && out.named {
+		out.text = n.Utf8Text(src)
+	}
+	for i := uint(0); i < n.ChildCount(); i++ {
+		c := n.Child(i)
+		if c.IsExtra() {
+			continue
+		}
+		out.children = append(out.children, p.convert(c, n.FieldNameForChild(uint32(i)), src))
+	}
+	return out
+}
+
+// slot returns the metavariable n stands for: n must be a chain of single
+// child wrappers ending in a placeholder identifier.
+func slot(n *tree_sitter.Node, src []byte) string {
+	for {
+		c := onlyChild(n)
+		if c == nil {
+			break
+		}
+		n = c
+	}
+	if n.Kind() != "identifier" {
+		return ""
+	}
+	name, ok := strings.CutPrefix(n.Utf8Text(src), placeholder)
+	if !ok {
+		return ""
+	}
+	return name
+}
+
+// emit writes the query for n, appending predicates for literals to preds.
+func emit(n *node, captures *int, preds *[]string) string {
+	label := func(s string) string {
+		if n.field != "" {
+			return n.field + ": " + s
+		}
+		return s
+	}
+	if n.meta != "" {
+		n.capture = fmt.Sprintf("m%d", *captures)
+		*captures++
+		return label("(" + n.kind + ") @" + n.capture)
+	}
+	if !n.named {
+		return label(strconv.Quote(n.kind))
+	}
+	parts := []string{"(" + n.kind}
+	for _, c := range n.children {
+		if !c.named && c.field != "operator" {
+			continue
+		}
+		parts = append(parts, emit(c, captures, preds))
+	}
+	s := strings.Join(parts, " ") + ")"
+	if n.text != "" {
+		n.capture = fmt.Sprintf("l%d", *captures)
+		*captures++
+		s += " @" + n.capture
+		*preds = append(*preds, fmt.Sprintf(" (#eq? @%s %s)", n.capture, strconv.Quote(n.text)))
+	}
+	return label(s)
+}
+
+// Match is one occurrence of a pattern.
+type Match struct {
+	Node tree_sitter.Node
+	// Bindings maps each metavariable to the node it matched first.
+	Bindings map[string]tree_sitter.Node
+}
+
+// Text returns the source text bound to a metavariable, or "".
+func (m Match) Text(name string, source []byte) string {
+	if n, ok := m.Bindings[name]; ok {
+		return n.Utf8Text(source)
+	}
+	return ""
+}
+
+// Find returns the matches of p in tree, in source order. A match may
+// contain other matches.
+func (p *Pattern) Find(tree *tree_sitter.Tree, source []byte) ([]Match, error) {
+	return p.FindIn(tree.RootNode(), source)
+}
+
+// FindIn returns the matches of p in the subtree rooted at node.
+func (p *Pattern) FindIn(root *tree_sitter.Node, source []byte) ([]Match, error) {
+	q, err := tree_sitter_wabznasm.NewQuery(p.query)
+	if err != nil {
+		return nil, fmt.Errorf("pattern: compiling %q: %w", p.query, err)
+	}
+	defer q.Close()
+	cursor := memtrack.NewQueryCursor()
+	defer cursor.Close()
+	matchIndex, _ := q.CaptureIndexForName("match")
+
+	var out []Match
+	seen := map[uintptr]bool{}
+	matches := cursor.Matches(q.Query, root, source)
+	for m := matches.Next(); m != nil; m = matches.Next() {
+		for _, c := range m.Captures {
+			if c.Index != uint32(matchIndex) || seen[c.Node.Id()] {
+				continue
+			}
+			b := binder{source: source, bindings: map[string]tree_sitter.Node{}, canon: map[string]string{}}
+			if b.match(p.root, c.Node) {
+				seen[c.Node.Id()] = true
+				out = append(out, Match{Node: c.Node, Bindings: b.bindings})
+			}
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].Node, out[j].Node
+		if a.StartByte() != b.StartByte() {
+			return a.StartByte() < b.StartByte()
+		}
+		return a.EndByte() > b.EndByte()
+	})
+	return out, nil
+}
+
+type binder struct {
+	source   []byte
+	bindings map[string]tree_sitter.Node
+	canon    map[string]string
+}
+
+func (b *binder) match(p *node, t tree_sitter.Node) bool {
+	if p.kind != t.Kind() {
+		return false
+	}
+	if p.meta != "" {
+		if p.meta == Wildcard {
+			return true
+		}
+		text := canonical(t, b.source)
+		if prev, ok := b.canon[p.meta]; ok {
+			return prev == text
+		}
+		b.canon[p.meta] = text
+		b.bindings[p.meta] = t
+		return true
+	}
+	if p.text != "" && p.text != t.Utf8Text(b.source) {
+		return false
+	}
+	tc := children(&t)
+	if len(tc) != len(p.children) {
+		return false
+	}
+	for i, c := range p.children {
+		if !b.match(c, tc[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// canonical renders n as its tokens separated by single spaces.
+func canonical(n tree_sitter.Node, source []byte) string {
+	var toks []string
+	var walk func(n *tree_sitter.Node)
+	walk = func(n *tree_sitter.Node) {
+		if n.IsExtra() {
+			return
+		}
+		if n.ChildCount() == 0 {
+			toks = append(toks, n.Utf8Text(source))
+			return
+		}
+		for i := uint(0); i < n.ChildCount(); i++ {
+			walk(n.Child(i))
+		}
+	}
+	walk(&n)
+	return strings.Join(toks, " ")
+}
+
+// Expand substitutes the metavariables of m into template.
+func (p *Pattern) Expand(template string, m Match, source []byte) (string, error) {
+	var err error
+	out := metavar.ReplaceAllStringFunc(template, func(v string) string {
+		name := v[1:]
+		if _, ok := m.Bindings[name]; !ok && err == nil {
+			err = fmt.Errorf("%w: %s", ErrUnboundMetavar, v)
+		}
+		return m.Text(name, source)
+	})
+	return out, err
+}
+
+// Replace rewrites each outermost match of p in tree with template, in
+// which metavariables are replaced by the text they matched. It returns the
+// new source and the matches replaced; text outside them is unchanged.
+func (p *Pattern) Replace(tree *tree_sitter.Tree, source []byte, template string) ([]byte, []Match, error) {
+	for _, v := range metavar.FindAllStringSubmatch(template, -1) {
+		if !contains(p.metavars, v[1]) {
+			return nil, nil, fmt.Errorf("%w: %s", ErrUnboundMetavar, v[0])
+		}
+	}
+	matches, err := p.Find(tree, source)
+	if err != nil {
+		return nil, nil, err
+	}
+	var edits []ast.Edit
+	var replaced []Match
+	last := uint(0)
+	for _, m := range matches {
+		if m.Node.StartByte() < last {
+			continue
+		}
+		text, err := p.Expand(template, m, source)
+		if err != nil {
+			return nil, nil, err
+		}
+		edits = append(edits, ast.Edit{
+			Start:   ast.Pos{Offset: m.Node.StartByte()},
+			End:     ast.Pos{Offset: m.Node.EndByte()},
+			NewText: text,
+		})
+		replaced = append(replaced, m)
+		last = m.Node.EndByte()
+	}
+	out, err := ast.Apply(source, edits)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, replaced, nil
+}
+
+// statement returns the assignment or expression of a source_file, or nil.
+func statement(root *tree_sitter.Node) *tree_sitter.Node {
+	for _, c := range children(root) {
+		if c.Kind() == "statement" {
+			return onlyChild(&c)
+		}
+	}
+	return nil
+}
+
+// children returns the non-extra children of n.
+func children(n *tree_sitter.Node) []tree_sitter.Node {
+	var out []tree_sitter.Node
+	for i := uint(0); i < n.ChildCount(); i++ {
+		if c := n.Child(i); !c.IsExtra() {
+			out = append(out, *c)
+		}
+	}
+	return out
+}
+
+// onlyChild returns the single non-extra child of n, or nil.
+func onlyChild(n *tree_sitter.Node) *tree_sitter.Node {
+	cs := children(n)
+	if len(cs) != 1 {
+		return nil
+	}
+	return &cs[0]
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package pattern_test
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/pattern"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func parse(t *testing.T, src string) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(parser.Close)
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+func find(t *testing.T, pat, src string) []string {
+	t.Helper()
+	p, err := pattern.Compile(pat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	matches, err := p.Find(parse(t, src).Tree, []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, m := range matches {
+		out = append(out, m.Node.Utf8Text([]byte(src)))
+	}
+	return out
+}
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		pattern, src string
+		want         []string
+	}{
+		{"$F[$X; $Y]", "t: f[1;g[a;b]] + h[2]", []string{"f[1;g[a;b]]", "g[a;b]"}},
+		{"f[1]", "t: f[1]*f[2]+(f[1])", []string{"f[1]", "f[1]"}},
+		{"$A + $A", "t: (x*2)+(x * 2) + 3", []string{"(x*2)+(x * 2)"}},
+		{"$A + $A", "t: a+b", nil},
+		{"$X * 2", "t: 1 + (a+b)*2", []string{"(a+b)*2"}},
+		{"{[$P] $P * $_}", "sq: {[n] n*n}", []string{"{[n] n*n}"}},
+		{"{[$P] $P * $_}", "sq: {[n] m*n}", nil},
+		{"$A - $B", "t: a+b", nil},
+		{"x: $V", "x: 1+2", []string{"x: 1+2"}},
+	}
+	for _, tt := range tests {
+		got := find(t, tt.pattern, tt.src)
+		if len(got) != len(tt.want) {
+			t.Errorf("Find(%q in %q) = %q, want %q", tt.pattern, tt.src, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Find(%q in %q) = %q, want %q", tt.pattern, tt.src, got, tt.want)
+				break
+			}
+		}
+	}
+}
+
+func TestReplace(t *testing.T) {
+	p := pattern.MustCompile("$F[$X; $Y]")
+	src := "\\ swap\nt: f[1; g[a;b]] + 2"
+	out, replaced, err := p.Replace(parse(t, src).Tree, []byte(src), "$F[$Y;$X]")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The nested call lies inside the outer match, so only the outer one
+	// is rewritten.
+	if want := "\\ swap\nt: f[g[a;b];1] + 2"; string(out) != want || len(replaced) != 1 {
+		t.Errorf("Replace = %q (%d), want %q", out, len(replaced), want)
+	}
+	if _, _, err := p.Replace(parse(t, src).Tree, []byte(src), "$Z"); !errors.Is(err, pattern.ErrUnboundMetavar) {
+		t.Errorf("Replace with $Z = %v, want ErrUnboundMetavar", err)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	for _, pat := range []string{"$F[", "$f", "$", ""} {
+		if _, err := pattern.Compile(pat); !errors.Is(err, pattern.ErrPattern) {
+			t.Errorf("Compile(%q) = %v, want ErrPattern", pat, err)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+//go:build js && wasm
+
+package playground
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// Export publishes the playground as globalThis[name], an object with
+// these methods:
+//
+//	init(module, language)  register web-tree-sitter and the language
+//	                        loaded with Language.load; call it first
+//	parse(source)           a ParseResult
+//	format(source)          a FormatResult
+//	highlight(source)       an array of Tokens
+//	highlightHTML(source)   an HTML string
+//
+// Results are plain objects in the JSON shapes of the Go types. A failure
+// returns {error: message} instead: Go callbacks cannot throw.
+func Export(name string) {
+	api := map[string]any{
+		"init": js.FuncOf(func(_ js.Value, args []js.Value) any {
+			if len(args) < 2 {
+				return failure("init(module, language): missing arguments")
+			}
+			ts.Load(args[0], args[1])
+			return nil
+		}),
+		"parse":         export(func(src []byte) (any, error) { return Parse(src) }),
+		"format":        export(func(src []byte) (any, error) { return Format(src) }),
+		"highlight":     export(func(src []byte) (any, error) { return Highlight(src) }),
+		"highlightHTML": export(func(src []byte) (any, error) { return HighlightHTML(src) }),
+	}
+	js.Global().Set(name, js.ValueOf(api))
+}
+
+// export adapts fn to a JavaScript function of one string. Results cross
+// as JSON, which keeps syscall/js conversions out of the Go types.
+func export(fn func([]byte) (any, error)) js.Func {
+	return js.FuncOf(func(_ js.Value, args []js.Value) any {
+		if !ts.Loaded() {
+			return failure("wabznasm: call init(module, language) first")
+		}
+		if len(args) < 1 || args[0].Type() != js.TypeString {
+			return failure("wabznasm: expected a source string")
+		}
+		v, err := fn([]byte(args[0].String()))
+		if err != nil {
+			return failure(err.Error())
+		}
+		if s, ok := v.(string); ok {
+			return s
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return failure(err.Error())
+		}
+		return js.Global().Get("JSON").Call("parse", string(data))
+	})
+}
+
+func failure(msg string) any {
+	return map[string]any{"error": msg}
+}
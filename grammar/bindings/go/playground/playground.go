@@ -0,0 +1,177 @@
+// Package playground is the API of an in-browser wabznasm playground: the
+// parser, formatter and highlighter behind functions that take source and
+// return JSON-ready results. Sources are parsed a statement at a time,
+// as the other tools parse files.
+//
+// Built for js/wasm, where the grammar runs on web-tree-sitter instead of
+// cgo, Export publishes the functions to JavaScript; command wabznasm-wasm
+// is that build. The functions work natively too, which is how they are
+// tested.
+//
+// Offsets and columns in results count bytes of the UTF-8 source, as
+// everywhere in these bindings; HighlightHTML spares JavaScript callers
+// the conversion to string indices.
+package playground
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/highlight"
+)
+
+// A playground is single-threaded in the browser; the lock only matters
+// natively.
+var (
+	mu     sync.Mutex
+	parser *tree_sitter_wabznasm.Parser
+	hl     *highlight.Highlighter
+)
+
+// Diagnostic is a syntax error.
+type Diagnostic struct {
+	StartByte uint                           `json:"start_byte"`
+	EndByte   uint                           `json:"end_byte"`
+	Start     tree_sitter_wabznasm.PointJSON `json:"start"`
+	End       tree_sitter_wabznasm.PointJSON `json:"end"`
+	Message   string                         `json:"message"`
+}
+
+// ParseResult is the result of Parse. Trees are the trees of the
+// statements, in source order, and SExpr their S-expressions a line each.
+type ParseResult struct {
+	Trees       []tree_sitter_wabznasm.NodeJSON `json:"trees"`
+	SExpr       string                          `json:"sexpr"`
+	Diagnostics []Diagnostic                    `json:"diagnostics"`
+}
+
+// FormatResult is the result of Format. Source is empty when the source
+// has syntax errors; Diagnostics lists them.
+type FormatResult struct {
+	Source      string       `json:"source"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Token is a highlighted byte range [Start, End) of the source.
+type Token struct {
+	Start   uint   `json:"start"`
+	End     uint   `json:"end"`
+	Capture string `json:"capture"`
+	Class   string `json:"class,omitempty"`
+}
+
+// withScript parses src a statement at a time and calls fn with the
+// script.
+func withScript(src []byte, fn func(*tree_sitter_wabznasm.Script) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if parser == nil {
+		p, err := tree_sitter_wabznasm.NewParser()
+		if err != nil {
+			return err
+		}
+		parser = p
+	}
+	sc, err := parser.ParseScript(src)
+	if err != nil {
+		return err
+	}
+	defer sc.Close()
+	return fn(sc)
+}
+
+func diagnostics(sc *tree_sitter_wabznasm.Script) []Diagnostic {
+	out := []Diagnostic{}
+	for _, d := range sc.Diagnostics() {
+		out = append(out, Diagnostic{
+			StartByte: d.Range.StartByte,
+			EndByte:   d.Range.EndByte,
+			Start:     tree_sitter_wabznasm.PointJSON{Row: d.Range.StartPoint.Row, Column: d.Range.StartPoint.Column},
+			End:       tree_sitter_wabznasm.PointJSON{Row: d.Range.EndPoint.Row, Column: d.Range.EndPoint.Column},
+			Message:   d.Message,
+		})
+	}
+	return out
+}
+
+// Parse parses src.
+func Parse(src []byte) (*ParseResult, error) {
+	var res *ParseResult
+	err := withScript(src, func(sc *tree_sitter_wabznasm.Script) error {
+		res = &ParseResult{Trees: []tree_sitter_wabznasm.NodeJSON{}, Diagnostics: diagnostics(sc)}
+		var sexprs []string
+		for _, st := range sc.Statements {
+			root := st.Tree.RootNode()
+			res.Trees = append(res.Trees, tree_sitter_wabznasm.ToJSON(root, st.Source))
+			sexprs = append(sexprs, tree_sitter_wabznasm.DumpSExpr(root))
+		}
+		res.SExpr = strings.Join(sexprs, "\n")
+		return nil
+	})
+	return res, err
+}
+
+// Format formats src.
+func Format(src []byte) (*FormatResult, error) {
+	var res *FormatResult
+	err := withScript(src, func(sc *tree_sitter_wabznasm.Script) error {
+		res = &FormatResult{Diagnostics: diagnostics(sc)}
+		out, err := format.Config{}.Script(src)
+		if err != nil {
+			if errors.Is(err, format.ErrSyntax) {
+				return nil
+			}
+			return err
+		}
+		res.Source = string(out)
+		return nil
+	})
+	return res, err
+}
+
+// tokens returns the highlighted tokens of the statements of sc, in
+// source order.
+func tokens(sc *tree_sitter_wabznasm.Script) ([]highlight.Token, error) {
+	if hl == nil {
+		h, err := highlight.New(nil)
+		if err != nil {
+			return nil, err
+		}
+		hl = h
+	}
+	var out []highlight.Token
+	for _, st := range sc.Statements {
+		out = append(out, hl.Tokens(st.Tree.Tree, st.Source)...)
+	}
+	return out, nil
+}
+
+// Highlight returns the highlighted tokens of src.
+func Highlight(src []byte) ([]Token, error) {
+	out := []Token{}
+	err := withScript(src, func(sc *tree_sitter_wabznasm.Script) error {
+		toks, err := tokens(sc)
+		for _, t := range toks {
+			out = append(out, Token(t))
+		}
+		return err
+	})
+	return out, err
+}
+
+// HighlightHTML renders src as HTML with highlight.RenderHTML.
+func HighlightHTML(src []byte) (string, error) {
+	var buf bytes.Buffer
+	err := withScript(src, func(sc *tree_sitter_wabznasm.Script) error {
+		toks, err := tokens(sc)
+		if err != nil {
+			return err
+		}
+		return highlight.RenderHTML(&buf, src, toks)
+	})
+	return buf.String(), err
+}
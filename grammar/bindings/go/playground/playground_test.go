@@ -0,0 +1,81 @@
+package playground_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/playground"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func TestParse(t *testing.T) {
+	res, err := playground.Parse([]byte("x: 1+"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Trees) != 1 || res.Trees[0].Kind != "source_file" || !strings.HasPrefix(res.SExpr, "(source_file") {
+		t.Errorf("tree = %s", res.SExpr)
+	}
+	if len(res.Diagnostics) != 1 || res.Diagnostics[0].StartByte != 5 {
+		t.Errorf("diagnostics = %+v", res.Diagnostics)
+	}
+
+	res, err = playground.Parse([]byte("f: {x+1}\ny: f[2]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Trees) != 2 || strings.Count(res.SExpr, "(source_file") != 2 || len(res.Diagnostics) != 0 {
+		t.Errorf("Parse of two statements = %+v", res)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	res, err := playground.Format([]byte("f : {[x]  x * 2}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Source != "f: {[x] x*2}\n" || len(res.Diagnostics) != 0 {
+		t.Errorf("Format = %+v", res)
+	}
+	res, err = playground.Format([]byte("f: {x + 1}\ny:   f[2]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Source != "f: {x+1}\ny: f[2]\n" || len(res.Diagnostics) != 0 {
+		t.Errorf("Format of two statements = %+v", res)
+	}
+	res, err = playground.Format([]byte("f: {[x] x *"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Source != "" || len(res.Diagnostics) == 0 {
+		t.Errorf("Format of invalid source = %+v", res)
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	src := "sq: {[x] x*x}"
+	tokens, err := playground.Highlight([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) == 0 || src[tokens[0].Start:tokens[0].End] != "sq" {
+		t.Errorf("tokens = %+v", tokens)
+	}
+	tokens, err = playground.Highlight([]byte("x: 1\ny: 2\n"))
+	if err != nil || len(tokens) == 0 || tokens[len(tokens)-1].Start != 8 {
+		t.Errorf("tokens of two statements = %+v, %v", tokens, err)
+	}
+	html, err := playground.HighlightHTML([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html, `class="wz-`) {
+		t.Errorf("html = %s", html)
+	}
+}
@@ -0,0 +1,100 @@
+package tree_sitter_wabznasm
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+// ErrPoolClosed is returned by ParserPool.Get after Close.
+var ErrPoolClosed = errors.New("wabznasm: parser pool is closed")
+
+// ParserPool caches parsers for reuse across goroutines, so that callers
+// parsing many small sources avoid creating a C parser for each one.
+//
+// Idle parsers live in a sync.Pool and may be dropped by the garbage
+// collector; a dropped parser is closed by a finalizer. Close releases the
+// idle parsers immediately. A ParserPool is safe for concurrent use.
+type ParserPool struct {
+	pool   sync.Pool
+	mu     sync.RWMutex
+	closed bool
+}
+
+// NewParserPool returns an empty pool. Parsers are created on demand.
+func NewParserPool() *ParserPool {
+	return &ParserPool{}
+}
+
+// Get returns an idle parser or a new one. The parser must be returned with
+// Put, or closed by the caller, once it is no longer needed.
+func (pp *ParserPool) Get() (*Parser, error) {
+	pp.mu.RLock()
+	defer pp.mu.RUnlock()
+	if pp.closed {
+		return nil, ErrPoolClosed
+	}
+	for {
+		p, ok := pp.pool.Get().(*Parser)
+		if !ok {
+			break
+		}
+		// Parsers made before UseLanguage parse with the old language.
+		if p.lang == Language() {
+			return p, nil
+		}
+		p.Close()
+	}
+	p, err := NewParser()
+	if err != nil {
+		return nil, err
+	}
+	runtime.SetFinalizer(p, (*Parser).Close)
+	return p, nil
+}
+
+// Put resets p, including any timeout, and makes it available to later calls to Get. Closed
+// parsers are discarded, and after Close the parser is closed instead.
+func (pp *ParserPool) Put(p *Parser) {
+	if p == nil || p.inner == nil {
+		return
+	}
+	pp.mu.RLock()
+	defer pp.mu.RUnlock()
+	if pp.closed {
+		p.Close()
+		return
+	}
+	p.inner.Reset()
+	p.timeout = 0
+	pp.pool.Put(p)
+}
+
+// Parse parses src with a pooled parser. The returned tree must be closed
+// by the caller.
+func (pp *ParserPool) Parse(src []byte) (*memtrack.TrackedTree, error) {
+	p, err := pp.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer pp.Put(p)
+	return p.ParseBytes(src)
+}
+
+// Close releases the idle parsers and makes later calls to Get fail.
+// Parsers checked out at the time are closed when they are Put back.
+// Calling Close more than once is a no-op.
+func (pp *ParserPool) Close() {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	pp.closed = true
+	for {
+		p, ok := pp.pool.Get().(*Parser)
+		if !ok {
+			return
+		}
+		p.Close()
+	}
+}
@@ -0,0 +1,82 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+var snippet = []byte("add: {[x;y] x+y*2}")
+
+func TestParserPool(t *testing.T) {
+	pool := tree_sitter_wabznasm.NewParserPool()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				tree, err := pool.Parse(snippet)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if tree.RootNode().HasError() {
+					t.Errorf("unexpected syntax error: %s", tree.RootNode().ToSexp())
+				}
+				tree.Close()
+			}
+		}()
+	}
+	wg.Wait()
+
+	held, err := pool.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool.Close()
+	pool.Close()
+	if _, err := pool.Get(); !errors.Is(err, tree_sitter_wabznasm.ErrPoolClosed) {
+		t.Errorf("Get after Close = %v, want ErrPoolClosed", err)
+	}
+	pool.Put(held)
+	if _, err := held.ParseBytes(snippet); !errors.Is(err, tree_sitter_wabznasm.ErrParserClosed) {
+		t.Errorf("parser Put after Close still usable: %v", err)
+	}
+}
+
+func BenchmarkParseNewParser(b *testing.B) {
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			parser, err := tree_sitter_wabznasm.NewParser()
+			if err != nil {
+				b.Fatal(err)
+			}
+			tree, err := parser.ParseBytes(snippet)
+			if err != nil {
+				b.Fatal(err)
+			}
+			tree.Close()
+			parser.Close()
+		}
+	})
+}
+
+func BenchmarkParseParserPool(b *testing.B) {
+	pool := tree_sitter_wabznasm.NewParserPool()
+	defer pool.Close()
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			tree, err := pool.Parse(snippet)
+			if err != nil {
+				b.Fatal(err)
+			}
+			tree.Close()
+		}
+	})
+}
@@ -0,0 +1,39 @@
+package tree_sitter_wabznasm
+
+import tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+
+// PointForOffset returns the row and byte column of a byte offset in src.
+// Offsets past the end of src clamp to its end.
+func PointForOffset(src []byte, offset uint) tree_sitter.Point {
+	offset = min(offset, uint(len(src)))
+	var p tree_sitter.Point
+	for _, b := range src[:offset] {
+		if b == '\n' {
+			p.Row++
+			p.Column = 0
+		} else {
+			p.Column++
+		}
+	}
+	return p
+}
+
+// OffsetForPoint returns the byte offset of a row and byte column in src.
+// Columns past the end of a line clamp to the line end, and rows past the
+// last line clamp to the end of src.
+func OffsetForPoint(src []byte, p tree_sitter.Point) uint {
+	var off uint
+	for row := uint(0); row < p.Row; row++ {
+		for off < uint(len(src)) && src[off] != '\n' {
+			off++
+		}
+		if off == uint(len(src)) {
+			return off
+		}
+		off++
+	}
+	for col := uint(0); col < p.Column && off < uint(len(src)) && src[off] != '\n'; col++ {
+		off++
+	}
+	return off
+}
@@ -0,0 +1,178 @@
+// Package position converts between byte offsets into UTF-8 source, the
+// positions tree-sitter reports, and the lines and columns other tools
+// count in code units of other encodings: UTF-16 for the Language Server
+// Protocol, SARIF and most editors, UTF-32, which counts characters, for
+// some LSP clients.
+//
+// Lines end at '\n' only, as tree-sitter rows do; a '\r' before it is
+// part of the line. Bytes that are not valid UTF-8 count as one code unit
+// each in every encoding, as editors decoding them to U+FFFD would show
+// them. UTF-8 columns are byte counts, exact at any offset; in the other
+// encodings an offset or column falling inside a character rounds down to
+// the start of the character.
+package position
+
+import "unicode/utf8"
+
+// Encoding is an encoding columns are counted in.
+type Encoding int
+
+// The encodings. UTF8 counts bytes, UTF16 code units and UTF32 characters.
+const (
+	UTF8 Encoding = iota
+	UTF16
+	UTF32
+)
+
+// String returns the name of e as the LSP PositionEncodingKind names it.
+func (e Encoding) String() string {
+	switch e {
+	case UTF8:
+		return "utf-8"
+	case UTF16:
+		return "utf-16"
+	case UTF32:
+		return "utf-32"
+	}
+	return "unknown"
+}
+
+// ParseEncoding returns the encoding named name, an LSP
+// PositionEncodingKind, and whether there is one.
+func ParseEncoding(name string) (Encoding, bool) {
+	switch name {
+	case "utf-8":
+		return UTF8, true
+	case "utf-16":
+		return UTF16, true
+	case "utf-32":
+		return UTF32, true
+	}
+	return 0, false
+}
+
+// Position is a zero-based line and column, the column counted in code
+// units of some encoding.
+type Position struct {
+	Line   uint
+	Column uint
+}
+
+// next returns the size in bytes and in code units of e of the character
+// starting text, which must not be empty, for e other than UTF8. An
+// incomplete character at the end of text has size 0.
+func next(text []byte, e Encoding) (size, units uint) {
+	if text[0] < utf8.RuneSelf {
+		return 1, 1
+	}
+	if !utf8.FullRune(text) {
+		return 0, 0
+	}
+	r, n := utf8.DecodeRune(text)
+	switch {
+	case r == utf8.RuneError && n == 1:
+		return 1, 1
+	case e == UTF16 && r >= 0x10000:
+		return uint(n), 2
+	}
+	return uint(n), 1
+}
+
+// Units returns the length of text in code units of e. Unless e is UTF8,
+// an incomplete character at the end of text is not counted.
+func Units(text []byte, e Encoding) uint {
+	if e == UTF8 {
+		return uint(len(text))
+	}
+	var units uint
+	for i := 0; i < len(text); {
+		size, u := next(text[i:], e)
+		if size == 0 {
+			break
+		}
+		i += int(size)
+		units += u
+	}
+	return units
+}
+
+// ByteOffset returns the byte offset in text of the end of its first
+// units code units of e, clamped to the length of text.
+func ByteOffset(text []byte, units uint, e Encoding) uint {
+	if e == UTF8 {
+		return min(units, uint(len(text)))
+	}
+	var off, n uint
+	for off < uint(len(text)) && n < units {
+		size, u := next(text[off:], e)
+		if size == 0 || n+u > units {
+			break
+		}
+		off += size
+		n += u
+	}
+	return off
+}
+
+// Convert converts col, a column of line counted in units of from, to a
+// column counted in units of to.
+func Convert(line []byte, col uint, from, to Encoding) uint {
+	if from == to {
+		return col
+	}
+	return Units(line[:ByteOffset(line, col, from)], to)
+}
+
+// LineStart returns the offset of the start of the line containing offset
+// in src.
+func LineStart(src []byte, offset uint) uint {
+	offset = min(offset, uint(len(src)))
+	for offset > 0 && src[offset-1] != '\n' {
+		offset--
+	}
+	return offset
+}
+
+// lineEnd returns the offset of the '\n' ending the line starting at
+// start, or the length of src.
+func lineEnd(src []byte, start uint) uint {
+	end := start
+	for end < uint(len(src)) && src[end] != '\n' {
+		end++
+	}
+	return end
+}
+
+// Column returns the column in units of e of offset in src.
+func Column(src []byte, offset uint, e Encoding) uint {
+	offset = min(offset, uint(len(src)))
+	return Units(src[LineStart(src, offset):offset], e)
+}
+
+// Of returns the position in units of e of offset in src. Offsets past the
+// end of src clamp to it.
+func Of(src []byte, offset uint, e Encoding) Position {
+	offset = min(offset, uint(len(src)))
+	var line uint
+	for _, b := range src[:offset] {
+		if b == '\n' {
+			line++
+		}
+	}
+	return Position{Line: line, Column: Column(src, offset, e)}
+}
+
+// Offset returns the byte offset in src of p, a position in units of e.
+// Columns past the end of a line clamp to the line end, and lines past the
+// last clamp to the end of src.
+func Offset(src []byte, p Position, e Encoding) uint {
+	var start uint
+	for line := uint(0); line < p.Line; line++ {
+		start = lineEnd(src, start)
+		if start == uint(len(src)) {
+			return start
+		}
+		start++
+	}
+	return start + ByteOffset(src[start:lineEnd(src, start)], p.Column, e)
+}
@@ -0,0 +1,104 @@
+package position_test
+
+import (
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/position"
+)
+
+// src has a two-byte, a three-byte and a four-byte character, which is
+// two UTF-16 code units, on its second line.
+const src = "x: 1\n\\ é€😀 y\nz"
+
+func TestOf(t *testing.T) {
+	y := uint(len("x: 1\n\\ é€😀 "))
+	tests := []struct {
+		offset uint
+		enc    position.Encoding
+		want   position.Position
+	}{
+		{0, position.UTF16, position.Position{}},
+		{5, position.UTF16, position.Position{Line: 1}},
+		{y, position.UTF8, position.Position{Line: 1, Column: 12}},
+		{y, position.UTF16, position.Position{Line: 1, Column: 7}},
+		{y, position.UTF32, position.Position{Line: 1, Column: 6}},
+		// Inside 😀, rounded down to its start.
+		{y - 2, position.UTF16, position.Position{Line: 1, Column: 4}},
+		{y - 2, position.UTF8, position.Position{Line: 1, Column: 10}},
+		{1000, position.UTF16, position.Position{Line: 2, Column: 1}},
+	}
+	for _, tt := range tests {
+		if got := position.Of([]byte(src), tt.offset, tt.enc); got != tt.want {
+			t.Errorf("Of(%d, %v) = %+v, want %+v", tt.offset, tt.enc, got, tt.want)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	y := uint(len("x: 1\n\\ é€😀 "))
+	tests := []struct {
+		p    position.Position
+		enc  position.Encoding
+		want uint
+	}{
+		{position.Position{Line: 1, Column: 7}, position.UTF16, y},
+		{position.Position{Line: 1, Column: 6}, position.UTF32, y},
+		{position.Position{Line: 1, Column: 12}, position.UTF8, y},
+		// Between the surrogates of 😀, rounded down to its start.
+		{position.Position{Line: 1, Column: 5}, position.UTF16, y - 5},
+		// Past the end of the line, and of the source.
+		{position.Position{Line: 0, Column: 99}, position.UTF16, 4},
+		{position.Position{Line: 7}, position.UTF16, uint(len(src))},
+	}
+	for _, tt := range tests {
+		if got := position.Offset([]byte(src), tt.p, tt.enc); got != tt.want {
+			t.Errorf("Offset(%+v, %v) = %d, want %d", tt.p, tt.enc, got, tt.want)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	text := []byte(src)
+	for _, enc := range []position.Encoding{position.UTF8, position.UTF16, position.UTF32} {
+		for off := uint(0); off <= uint(len(text)); off++ {
+			p := position.Of(text, off, enc)
+			back := position.Offset(text, p, enc)
+			if back > off || enc == position.UTF8 && back != off {
+				t.Errorf("%v: offset %d -> %+v -> %d", enc, off, p, back)
+			}
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	// Invalid bytes count one unit each, and a truncated character at
+	// the end of a line is not a character.
+	line := []byte("a\xff\xfeb")
+	if got := position.Units(line, position.UTF16); got != 4 {
+		t.Errorf("Units = %d, want 4", got)
+	}
+	if got := position.ByteOffset(line, 3, position.UTF32); got != 3 {
+		t.Errorf("ByteOffset = %d, want 3", got)
+	}
+	if got := position.Units([]byte("a\xf0\x9f"), position.UTF16); got != 1 {
+		t.Errorf("Units of a truncated character = %d, want 1", got)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	line := []byte("é😀x")
+	if got := position.Convert(line, 3, position.UTF16, position.UTF8); got != 6 {
+		t.Errorf("UTF-16 3 = UTF-8 %d, want 6", got)
+	}
+	if got := position.Convert(line, 6, position.UTF8, position.UTF32); got != 2 {
+		t.Errorf("UTF-8 6 = UTF-32 %d, want 2", got)
+	}
+	for _, name := range []string{"utf-8", "utf-16", "utf-32"} {
+		if e, ok := position.ParseEncoding(name); !ok || e.String() != name {
+			t.Errorf("ParseEncoding(%q) = %v, %v", name, e, ok)
+		}
+	}
+	if _, ok := position.ParseEncoding("latin1"); ok {
+		t.Error("ParseEncoding accepted latin1")
+	}
+}
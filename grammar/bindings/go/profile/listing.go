@@ -0,0 +1,73 @@
+package profile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteListing writes src, the script called name, with each line
+// annotated by the time spent in the expressions beginning on it and the
+// number of their evaluations, followed by the top expressions by time
+// spent in them on their own, and the functions by total time.
+func (p *Profiler) WriteListing(w io.Writer, name string, src []byte, top int) error {
+	bw := bufio.NewWriter(w)
+	lines := strings.Split(strings.TrimSuffix(string(src), "\n"), "\n")
+	self := make([]time.Duration, len(lines))
+	count := make([]int64, len(lines))
+	var total time.Duration
+	var evals int64
+	nodes := p.Nodes()
+	for _, n := range nodes {
+		if row := int(n.Span.Start.Row); row < len(lines) {
+			self[row] += n.Self
+			count[row] += n.Count
+		}
+		total += n.Self
+		evals += n.Count
+	}
+
+	fmt.Fprintf(bw, "Total: %s in %d evaluations\n\n", ms(total), evals)
+	fmt.Fprintf(bw, "%10s %10s %5s\n", "self", "count", "line")
+	for i, line := range lines {
+		if count[i] == 0 {
+			fmt.Fprintf(bw, "%10s %10s %5d  %s\n", ".", ".", i+1, line)
+			continue
+		}
+		fmt.Fprintf(bw, "%10s %10d %5d  %s\n", ms(self[i]), count[i], i+1, line)
+	}
+
+	if len(nodes) > top {
+		nodes = nodes[:top]
+	}
+	if len(nodes) > 0 {
+		fmt.Fprintf(bw, "\nHot expressions:\n%10s %10s %10s  %s\n", "self", "total", "count", "location")
+	}
+	for _, n := range nodes {
+		pos := n.Span.Start
+		text := string(src[pos.Offset:n.Span.End.Offset])
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[:i] + " …"
+		}
+		fmt.Fprintf(bw, "%10s %10s %10d  %s:%d:%d %s  %s\n", ms(n.Self), ms(n.Total), n.Count, name, pos.Row+1, pos.Column+1, n.Function, text)
+	}
+
+	if funcs := p.Functions(); len(funcs) > 0 {
+		fmt.Fprintf(bw, "\nFunctions:\n%10s %10s %10s  %s\n", "self", "total", "calls", "function")
+		for _, f := range funcs {
+			where := "builtin"
+			if f.Line >= 0 {
+				where = fmt.Sprintf("%s:%d", name, f.Line+1)
+			}
+			fmt.Fprintf(bw, "%10s %10s %10d  %s (%s)\n", ms(f.Self), ms(f.Total), f.Calls, f.Name, where)
+		}
+	}
+	return bw.Flush()
+}
+
+// ms formats d in milliseconds.
+func ms(d time.Duration) string {
+	return fmt.Sprintf("%.3fms", float64(d)/float64(time.Millisecond))
+}
@@ -0,0 +1,172 @@
+package profile
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"sort"
+	"time"
+)
+
+// WritePprof writes the profile in the gzipped protocol buffer format of
+// pprof, with filename as the source of its locations. Each sample is a
+// stack of expressions, innermost first, with two values: the number of
+// evaluations and the time spent in the innermost expression itself.
+func (p *Profiler) WritePprof(w io.Writer, filename string) error {
+	strs := &stringTable{index: map[string]int64{}}
+	strs.id("")
+
+	var prof encoder
+	valueType := func(field int, typ, unit string) {
+		prof.message(field, func(m *encoder) {
+			m.int(1, strs.id(typ))
+			m.int(2, strs.id(unit))
+		})
+	}
+	valueType(1, "evaluations", "count")
+	valueType(1, "time", "nanoseconds")
+
+	// Functions and locations are numbered from 1 in order of first use,
+	// with the samples in a deterministic order.
+	keys := make([]string, 0, len(p.samples))
+	for k := range p.samples {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	funcIDs := map[funcKey]uint64{}
+	locIDs := map[loc]uint64{}
+	var funcs []funcKey
+	var locs []loc
+	for _, k := range keys {
+		s := p.samples[k]
+		ids := make([]uint64, len(s.locs))
+		for i, l := range s.locs {
+			if _, ok := funcIDs[l.fn]; !ok {
+				funcs = append(funcs, l.fn)
+				funcIDs[l.fn] = uint64(len(funcs))
+			}
+			if _, ok := locIDs[l]; !ok {
+				locs = append(locs, l)
+				locIDs[l] = uint64(len(locs))
+			}
+			ids[i] = locIDs[l]
+		}
+		prof.message(2, func(m *encoder) {
+			m.packed(1, ids)
+			m.packed(2, []uint64{uint64(s.count), uint64(s.self.Nanoseconds())})
+		})
+	}
+
+	prof.message(3, func(m *encoder) {
+		m.uint(1, 1)
+		m.int(5, strs.id(filename))
+		m.bool(7, true)
+		m.bool(8, true)
+		m.bool(9, true)
+	})
+	for i, l := range locs {
+		prof.message(4, func(m *encoder) {
+			m.uint(1, uint64(i+1))
+			m.uint(2, 1)
+			m.message(4, func(line *encoder) {
+				line.uint(1, funcIDs[l.fn])
+				line.int(2, int64(l.row)+1)
+				line.int(3, int64(l.column)+1)
+			})
+		})
+	}
+	for i, f := range funcs {
+		prof.message(5, func(m *encoder) {
+			m.uint(1, uint64(i+1))
+			m.int(2, strs.id(f.name))
+			m.int(3, strs.id(f.name))
+			m.int(4, strs.id(filename))
+			m.int(5, int64(f.line)+1)
+		})
+	}
+	// The string table goes last, once every string is in it.
+	for _, s := range strs.list {
+		prof.string(6, s)
+	}
+	elapsed := p.elapsed
+	if elapsed == 0 && !p.start.IsZero() {
+		elapsed = time.Since(p.start)
+	}
+	prof.int(9, p.start.UnixNano())
+	prof.int(10, elapsed.Nanoseconds())
+
+	zw := gzip.NewWriter(w)
+	if _, err := zw.Write(prof.buf); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// stringTable is the string table of a profile.
+type stringTable struct {
+	list  []string
+	index map[string]int64
+}
+
+func (t *stringTable) id(s string) int64 {
+	if i, ok := t.index[s]; ok {
+		return i
+	}
+	t.index[s] = int64(len(t.list))
+	t.list = append(t.list, s)
+	return t.index[s]
+}
+
+// encoder writes the protocol buffer wire format: each field a varint
+// key of its number and wire type, then the value.
+type encoder struct {
+	buf []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (e *encoder) key(field, wire int) {
+	e.buf = binary.AppendUvarint(e.buf, uint64(field)<<3|uint64(wire))
+}
+
+// uint writes a varint field, leaving out zero as proto3 does.
+func (e *encoder) uint(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	e.key(field, wireVarint)
+	e.buf = binary.AppendUvarint(e.buf, x)
+}
+
+func (e *encoder) int(field int, x int64) { e.uint(field, uint64(x)) }
+
+func (e *encoder) bool(field int, b bool) {
+	if b {
+		e.uint(field, 1)
+	}
+}
+
+// string writes a string field, even if it is empty, since the entries
+// of a repeated field cannot be left out.
+func (e *encoder) string(field int, s string) {
+	e.key(field, wireBytes)
+	e.buf = binary.AppendUvarint(e.buf, uint64(len(s)))
+	e.buf = append(e.buf, s...)
+}
+
+func (e *encoder) packed(field int, xs []uint64) {
+	var body []byte
+	for _, x := range xs {
+		body = binary.AppendUvarint(body, x)
+	}
+	e.string(field, string(body))
+}
+
+func (e *encoder) message(field int, f func(*encoder)) {
+	var m encoder
+	f(&m)
+	e.string(field, string(m.buf))
+}
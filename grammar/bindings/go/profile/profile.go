@@ -0,0 +1,264 @@
+// Package profile measures where the evaluation of a script spends its
+// time. A Profiler attached to an interpreter counts the evaluations of
+// each expression and times them, both on their own and with the
+// expressions within, and accumulates the calls of each function. The
+// result can be written as a pprof profile, for go tool pprof and the
+// tools that read its format, or as a listing of the script annotated
+// with the time spent on each line.
+package profile
+
+import (
+	"sort"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// TopLevel names the code of a script outside any function.
+const TopLevel = "(top level)"
+
+// Node is the profile of an expression of the script.
+type Node struct {
+	Span ast.Span
+	// Expr is the expression; Function names the function it is in, or
+	// is TopLevel.
+	Expr     ast.Expr
+	Function string
+	// Count is how many times the expression was evaluated.
+	Count int64
+	// Self is the time spent evaluating the expression itself, and Total
+	// the time including the expressions within it.
+	Self, Total time.Duration
+}
+
+// Function is the profile of a function, called by Name, whose body
+// begins on Line, counting from zero. Builtins have a Line of -1.
+type Function struct {
+	Name  string
+	Line  int
+	Calls int64
+	// Self is the time spent in the function outside the calls it made,
+	// and Total the time including them.
+	Self, Total time.Duration
+}
+
+// funcKey identifies a function of the profile.
+type funcKey struct {
+	name string
+	line int
+}
+
+// loc is a location of a sample: an expression position in a function.
+type loc struct {
+	fn          funcKey
+	row, column uint
+}
+
+// sample accumulates the evaluations of a stack of locations, innermost
+// first.
+type sample struct {
+	locs  []loc
+	count int64
+	self  time.Duration
+}
+
+// active is an expression being evaluated.
+type active struct {
+	e     ast.Expr
+	start time.Time
+	child time.Duration
+	// depth is the number of calls in progress around e.
+	depth int
+}
+
+// call is a function call being evaluated.
+type call struct {
+	fn    funcKey
+	start time.Time
+	child time.Duration
+}
+
+// Profiler accumulates the profile of the evaluations of interpreters it
+// is attached to. It is not safe for concurrent use.
+type Profiler struct {
+	start   time.Time
+	elapsed time.Duration
+	nodes   map[ast.Span]*Node
+	funcs   map[funcKey]*Function
+	samples map[string]*sample
+	active  []active
+	calls   []call
+}
+
+// New returns an empty profiler.
+func New() *Profiler {
+	return &Profiler{
+		nodes:   map[ast.Span]*Node{},
+		funcs:   map[funcKey]*Function{},
+		samples: map[string]*sample{},
+	}
+}
+
+// Attach installs p as the Hook, Done and Tracer of in, replacing any
+// set there, and starts the clock of the profile if it is not running.
+func (p *Profiler) Attach(in *eval.Interpreter) {
+	in.Hook = p.hook
+	in.Done = p.done
+	in.Tracer = p
+	if p.start.IsZero() {
+		p.start = time.Now()
+	}
+}
+
+// Stop stops the clock of the profile, which measures the time from the
+// first Attach.
+func (p *Profiler) Stop() {
+	if !p.start.IsZero() && p.elapsed == 0 {
+		p.elapsed = time.Since(p.start)
+	}
+}
+
+func (p *Profiler) hook(e ast.Expr, env *eval.Env) error {
+	p.active = append(p.active, active{e: e, start: time.Now(), depth: len(p.calls)})
+	return nil
+}
+
+func (p *Profiler) done(e ast.Expr, v eval.Value, err error) {
+	n := len(p.active) - 1
+	a := p.active[n]
+	p.active = p.active[:n]
+	total := time.Since(a.start)
+	self := total - a.child
+	if n > 0 {
+		p.active[n-1].child += total
+	}
+
+	span := ast.Span{Start: e.Pos(), End: e.EndPos()}
+	node := p.nodes[span]
+	if node == nil {
+		node = &Node{Span: span, Expr: e, Function: p.function(a.depth).name}
+		p.nodes[span] = node
+	}
+	node.Count++
+	node.Self += self
+	node.Total += total
+
+	// The stack of the sample is e, and the expression each caller was
+	// evaluating when it made the call in progress.
+	locs := []loc{p.loc(a)}
+	depth := a.depth
+	for i := n - 1; i >= 0; i-- {
+		if p.active[i].depth < depth {
+			locs = append(locs, p.loc(p.active[i]))
+			depth = p.active[i].depth
+		}
+	}
+	key := stackKey(locs)
+	s := p.samples[key]
+	if s == nil {
+		s = &sample{locs: locs}
+		p.samples[key] = s
+	}
+	s.count++
+	s.self += self
+}
+
+func (p *Profiler) function(depth int) funcKey {
+	if depth == 0 {
+		return funcKey{name: TopLevel}
+	}
+	return p.calls[depth-1].fn
+}
+
+func (p *Profiler) loc(a active) loc {
+	pos := a.e.Pos()
+	return loc{fn: p.function(a.depth), row: pos.Row, column: pos.Column}
+}
+
+func stackKey(locs []loc) string {
+	var b []byte
+	for _, l := range locs {
+		b = append(b, l.fn.name...)
+		b = append(b, 0)
+		b = appendInt(b, l.fn.line)
+		b = appendInt(b, int(l.row))
+		b = appendInt(b, int(l.column))
+	}
+	return string(b)
+}
+
+func appendInt(b []byte, n int) []byte {
+	return append(b, byte(n>>24), byte(n>>16), byte(n>>8), byte(n))
+}
+
+// Enter implements eval.Tracer.
+func (p *Profiler) Enter(c *ast.Call, fn eval.Value, args []eval.Value) {
+	key := funcKey{name: "<function>", line: -1}
+	if c != nil {
+		key.name = c.Func.Name
+	} else if b, ok := fn.(*eval.Builtin); ok {
+		key.name = b.Name
+	}
+	if f, ok := fn.(*eval.Function); ok {
+		key.line = int(f.Body.Pos().Row)
+	}
+	p.calls = append(p.calls, call{fn: key, start: time.Now()})
+}
+
+// Exit implements eval.Tracer.
+func (p *Profiler) Exit(c *ast.Call, fn eval.Value, result eval.Value, err error) {
+	n := len(p.calls) - 1
+	cl := p.calls[n]
+	p.calls = p.calls[:n]
+	total := time.Since(cl.start)
+	if n > 0 {
+		p.calls[n-1].child += total
+	}
+	f := p.funcs[cl.fn]
+	if f == nil {
+		f = &Function{Name: cl.fn.name, Line: cl.fn.line}
+		p.funcs[cl.fn] = f
+	}
+	f.Calls++
+	f.Self += total - cl.child
+	f.Total += total
+}
+
+// Operator implements eval.Tracer.
+func (p *Profiler) Operator(ast.Expr, string, []eval.Value, eval.Value, error) {}
+
+// Assign implements eval.Tracer.
+func (p *Profiler) Assign(string, eval.Value) {}
+
+// Nodes returns the profiles of the expressions evaluated, the most
+// time-consuming on their own first.
+func (p *Profiler) Nodes() []Node {
+	out := make([]Node, 0, len(p.nodes))
+	for _, n := range p.nodes {
+		out = append(out, *n)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Self != out[j].Self {
+			return out[i].Self > out[j].Self
+		}
+		return out[i].Span.Start.Offset < out[j].Span.Start.Offset
+	})
+	return out
+}
+
+// Functions returns the profiles of the functions called, the most
+// time-consuming in total first.
+func (p *Profiler) Functions() []Function {
+	out := make([]Function, 0, len(p.funcs))
+	for _, f := range p.funcs {
+		out = append(out, *f)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Total != out[j].Total {
+			return out[i].Total > out[j].Total
+		}
+		return out[i].Name < out[j].Name
+	})
+	return out
+}
@@ -0,0 +1,173 @@
+package profile_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/profile"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+)
+
+const script = `double: {[a] a*2}
+quad: {[a]
+  double[a]+double[a]
+}
+quad[3]+quad[4]
+`
+
+func run(t *testing.T) *profile.Profiler {
+	t.Helper()
+	p := profile.New()
+	in := eval.New()
+	p.Attach(in)
+	err := repl.RunScript(context.Background(), in, []byte(script), func(st *repl.Statement) bool {
+		if st.Err != nil {
+			t.Fatal(st.Err)
+		}
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Stop()
+	return p
+}
+
+func TestProfiler(t *testing.T) {
+	p := run(t)
+	counts := map[string]int64{}
+	for _, n := range p.Nodes() {
+		text := script[n.Span.Start.Offset:n.Span.End.Offset]
+		counts[n.Function+" "+text] += n.Count
+		if n.Self < 0 || n.Self > n.Total {
+			t.Errorf("%s: self %v, total %v", text, n.Self, n.Total)
+		}
+	}
+	for key, want := range map[string]int64{
+		"double a*2":                  4,
+		"quad double[a]":              4,
+		"quad double[a]+double[a]":    2,
+		"(top level) quad[3]+quad[4]": 1,
+		"(top level) {[a] a*2}":       1,
+		"double a":                    4,
+		"quad a":                      2 * 2,
+		"(top level) quad[3]":         1,
+		"(top level) quad":            2,
+		"(top level) 4":               1,
+		"double 2":                    4,
+		"quad double":                 4,
+	} {
+		if counts[key] != want {
+			t.Errorf("count of %q = %d, want %d", key, counts[key], want)
+		}
+	}
+
+	funcs := p.Functions()
+	var names []string
+	for _, f := range funcs {
+		names = append(names, f.Name)
+		if f.Self > f.Total {
+			t.Errorf("%s: self %v > total %v", f.Name, f.Self, f.Total)
+		}
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"double", "quad"}) || funcs[0].Name != "quad" || funcs[0].Calls != 2 || funcs[0].Line != 2 {
+		t.Errorf("functions = %+v", funcs)
+	}
+}
+
+func TestWriteListing(t *testing.T) {
+	var out strings.Builder
+	if err := run(t).WriteListing(&out, "main.wz", []byte(script), 2); err != nil {
+		t.Fatal(err)
+	}
+	got := regexp.MustCompile(`-?\d+\.\d{3}ms`).ReplaceAllString(out.String(), "T")
+	got = regexp.MustCompile(` +`).ReplaceAllString(got, " ")
+	for _, want := range []string{
+		"Total: T in 35 evaluations\n",
+		" T 13 1 double: {[a] a*2}\n",
+		" T 14 3 double[a]+double[a]\n",
+		" . . 4 }\n",
+		"Hot expressions:\n",
+		"Functions:\n",
+		" T T 2 quad (main.wz:3)\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("listing lacks %q:\n%s", want, got)
+		}
+	}
+	if n := strings.Count(got, " main.wz:"); n != 2 {
+		t.Errorf("listing has %d hot expressions, want 2:\n%s", n, got)
+	}
+}
+
+func TestWritePprof(t *testing.T) {
+	var buf bytes.Buffer
+	if err := run(t).WritePprof(&buf, "main.wz"); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := decode(t, data)
+	strs := fields[6]
+	if len(strs) == 0 || len(strs[0]) != 0 {
+		t.Fatalf("string table = %q", strs)
+	}
+	for _, s := range []string{"evaluations", "count", "time", "nanoseconds", "main.wz", "double", "quad", "(top level)"} {
+		if !slices.ContainsFunc(strs, func(b []byte) bool { return string(b) == s }) {
+			t.Errorf("string table lacks %q", s)
+		}
+	}
+	if len(fields[1]) != 2 || len(fields[2]) == 0 || len(fields[4]) == 0 || len(fields[5]) != 3 {
+		t.Errorf("profile has %d sample types, %d samples, %d locations, %d functions",
+			len(fields[1]), len(fields[2]), len(fields[4]), len(fields[5]))
+	}
+	// The evaluations of the samples add up to those of the profile.
+	var evals uint64
+	for _, s := range fields[2] {
+		values := decode(t, s)[2][0]
+		n, _ := binary.Uvarint(values)
+		evals += n
+	}
+	if evals != 35 {
+		t.Errorf("samples count %d evaluations, want 35", evals)
+	}
+}
+
+// decode splits a protocol buffer message into the payloads of its
+// length-delimited fields, skipping varints.
+func decode(t *testing.T, data []byte) map[int][][]byte {
+	t.Helper()
+	out := map[int][][]byte{}
+	for len(data) > 0 {
+		key, n := binary.Uvarint(data)
+		data = data[n:]
+		switch key & 7 {
+		case 0:
+			_, n = binary.Uvarint(data)
+			data = data[n:]
+		case 2:
+			size, n := binary.Uvarint(data)
+			data = data[n:]
+			out[int(key>>3)] = append(out[int(key>>3)], data[:size])
+			data = data[size:]
+		default:
+			t.Fatalf("unexpected wire type %d", key&7)
+		}
+	}
+	return out
+}
@@ -0,0 +1,452 @@
+// Package project indexes the wabznasm sources of a directory tree.
+//
+// A Project parses every source under its root concurrently, as a script
+// of statements, and keeps, for each file, its symbol table and syntax
+// diagnostics together with an index from global names to the files that
+// define or use them. The index lives as long as the Project and is
+// updated file by file: Update and Remove apply known changes, and Refresh
+// rescans the tree and reparses only the files whose size or modification
+// time changed.
+//
+// Files are analysed on a bounded pool of goroutines; Options set its size,
+// a time limit for each file and a callback that reports progress.
+//
+// Modules a project requires, vendored by the mod package, live in
+// VendorDir under the root and are indexed with the project's own sources,
+// so their globals resolve like any other; Module tells which module a
+// file belongs to.
+//
+// A Project implements definition.Index, so the definition and references
+// packages can resolve globals across the whole tree.
+package project
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/definition"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/group"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// Extensions are the file extensions recognized as wabznasm sources.
+var Extensions = []string{".wabznasm", ".wz"}
+
+// IsSource reports whether path has a wabznasm source extension.
+func IsSource(path string) bool {
+	ext := filepath.Ext(path)
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// VendorDir is the directory under a project root holding the modules it
+// requires, one directory for each named after the module.
+const VendorDir = "vendor"
+
+// File is the indexed state of one source file.
+type File struct {
+	Path        string
+	Source      []byte
+	ModTime     time.Time
+	Size        int64
+	Symbols     *scopes.Table
+	Diagnostics []tree_sitter_wabznasm.Diagnostic
+}
+
+// Options configure how a Project analyses files.
+type Options struct {
+	// Jobs is the number of files analysed at once; zero means one per
+	// CPU.
+	Jobs int
+	// Timeout limits the parse of each file; zero means no limit. A file
+	// that runs out of time is left out of the index and reported to
+	// Progress with an error wrapping tree_sitter_wabznasm.ErrParseTimeout.
+	Timeout time.Duration
+	// Progress, if set, is called after each file is analysed or fails.
+	// Calls are not concurrent.
+	Progress func(Progress)
+}
+
+// Progress reports a file analysed during a scan.
+type Progress struct {
+	Path string
+	// Done counts the files finished so far, this one included, out of
+	// Total.
+	Done, Total int
+	// Err is set if the file could not be analysed.
+	Err error
+}
+
+// Project is a symbol index over a directory tree. It is safe for
+// concurrent use.
+type Project struct {
+	Root string
+
+	opts  Options
+	mu    sync.RWMutex
+	files map[string]*File
+	// names maps each global name to the files mentioning it.
+	names map[string]map[string]bool
+}
+
+// Open scans root and indexes every source beneath it.
+func Open(root string) (*Project, error) {
+	return OpenOptions(context.Background(), root, Options{})
+}
+
+// OpenOptions is like Open, but analyses files as opts say and stops early,
+// returning ctx.Err(), if ctx is done first. Later scans use opts too.
+func OpenOptions(ctx context.Context, root string, opts Options) (*Project, error) {
+	p := &Project{Root: root, opts: opts, files: map[string]*File{}, names: map[string]map[string]bool{}}
+	if _, err := p.RefreshContext(ctx); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Refresh rescans the tree, indexing new and modified files and dropping
+// deleted ones. It returns the paths whose index entries changed.
+func (p *Project) Refresh() ([]string, error) {
+	return p.RefreshContext(context.Background())
+}
+
+// RefreshContext is like Refresh, but stops early if ctx is done. Files
+// indexed by then stay indexed.
+func (p *Project) RefreshContext(ctx context.Context) ([]string, error) {
+	type candidate struct {
+		path string
+		info fs.FileInfo
+	}
+	var stale []candidate
+	seen := map[string]bool{}
+	err := filepath.WalkDir(p.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != p.Root && len(d.Name()) > 1 && d.Name()[0] == '.' {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !IsSource(path) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		seen[path] = true
+		p.mu.RLock()
+		old := p.files[path]
+		p.mu.RUnlock()
+		if old == nil || !old.ModTime.Equal(info.ModTime()) || old.Size != info.Size() {
+			stale = append(stale, candidate{path, info})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []string
+	p.mu.RLock()
+	for path := range p.files {
+		if !seen[path] {
+			changed = append(changed, path)
+		}
+	}
+	p.mu.RUnlock()
+	for _, path := range changed {
+		p.Remove(path)
+	}
+
+	paths := make([]string, len(stale))
+	for i, c := range stale {
+		paths[i] = c.path
+	}
+	files, err := p.parseAll(ctx, paths)
+	for i, f := range files {
+		if f == nil {
+			continue
+		}
+		f.ModTime, f.Size = stale[i].info.ModTime(), stale[i].info.Size()
+		p.put(f)
+		changed = append(changed, f.Path)
+	}
+	sort.Strings(changed)
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// parseAll reads and analyses paths on the worker pool. It returns the
+// files analysed, by index of paths, with nil for those that failed; the
+// first failure other than a timeout stops the rest and is returned.
+func (p *Project) parseAll(ctx context.Context, paths []string) ([]*File, error) {
+	files := make([]*File, len(paths))
+	jobs := p.opts.Jobs
+	if jobs <= 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	parsers := tree_sitter_wabznasm.NewParserPool()
+	defer parsers.Close()
+	var mu sync.Mutex
+	done := 0
+	report := func(path string, err error) {
+		if p.opts.Progress == nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		p.opts.Progress(Progress{Path: path, Done: done, Total: len(paths), Err: err})
+	}
+
+	g, gctx := group.WithContext(ctx, jobs)
+	for i, path := range paths {
+		if gctx.Err() != nil {
+			break
+		}
+		g.Go(func() error {
+			parser, err := parsers.Get()
+			if err != nil {
+				return err
+			}
+			defer parsers.Put(parser)
+			parser.SetTimeoutMicros(uint64(p.opts.Timeout.Microseconds()))
+			f, err := parse(gctx, parser, path)
+			report(path, err)
+			if errors.Is(err, tree_sitter_wabznasm.ErrParseTimeout) {
+				return nil
+			}
+			files[i] = f
+			return err
+		})
+	}
+	err := g.Wait()
+	if err == nil {
+		err = ctx.Err()
+	}
+	return files, err
+}
+
+func parse(ctx context.Context, parser *tree_sitter_wabznasm.Parser, path string) (*File, error) {
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return analyse(ctx, parser, path, src)
+}
+
+func analyse(ctx context.Context, parser *tree_sitter_wabznasm.Parser, path string, src []byte) (*File, error) {
+	s, err := parser.ParseScriptContext(ctx, src)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	defer s.Close()
+	return &File{
+		Path:        path,
+		Source:      src,
+		Size:        int64(len(src)),
+		Symbols:     scopes.FromScript(s),
+		Diagnostics: s.Diagnostics(),
+	}, nil
+}
+
+// Update reindexes path from disk, or removes it if it no longer exists.
+func (p *Project) Update(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		p.Remove(path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	files, err := p.parseAll(context.Background(), []string{path})
+	if err != nil {
+		return err
+	}
+	if files[0] == nil {
+		return fmt.Errorf("%s: %w", path, tree_sitter_wabznasm.ErrParseTimeout)
+	}
+	files[0].ModTime, files[0].Size = info.ModTime(), info.Size()
+	p.put(files[0])
+	return nil
+}
+
+// Set indexes src as the contents of path without reading the disk, as for
+// an unsaved editor buffer. A later Refresh reindexes the file from disk.
+func (p *Project) Set(path string, src []byte) error {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return err
+	}
+	defer parser.Close()
+	f, err := analyse(context.Background(), parser, path, src)
+	if err != nil {
+		return err
+	}
+	f.Size = -1 // never matches the file on disk
+	p.put(f)
+	return nil
+}
+
+// Remove drops path from the index.
+func (p *Project) Remove(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unindex(path)
+	delete(p.files, path)
+}
+
+func (p *Project) put(f *File) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.unindex(f.Path)
+	p.files[f.Path] = f
+	for _, sym := range f.Symbols.Globals() {
+		if p.names[sym.Name] == nil {
+			p.names[sym.Name] = map[string]bool{}
+		}
+		p.names[sym.Name][f.Path] = true
+	}
+}
+
+// unindex removes path's names; p.mu must be held.
+func (p *Project) unindex(path string) {
+	old := p.files[path]
+	if old == nil {
+		return
+	}
+	for _, sym := range old.Symbols.Globals() {
+		delete(p.names[sym.Name], path)
+		if len(p.names[sym.Name]) == 0 {
+			delete(p.names, sym.Name)
+		}
+	}
+}
+
+// Files returns the indexed paths in sorted order.
+func (p *Project) Files() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	paths := make([]string, 0, len(p.files))
+	for path := range p.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// File returns the indexed state of path, or nil.
+func (p *Project) File(path string) *File {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.files[path]
+}
+
+// Module returns the name of the vendored module the file at path belongs
+// to, or "" for the project's own sources.
+func (p *Project) Module(path string) string {
+	rel, err := filepath.Rel(p.Root, path)
+	if err != nil {
+		return ""
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	if len(parts) < 3 || parts[0] != VendorDir {
+		return ""
+	}
+	return parts[1]
+}
+
+// Definitions returns the assignments to the global name across the
+// project, ordered by path.
+func (p *Project) Definitions(name string) []definition.Location {
+	var out []definition.Location
+	for _, f := range p.mentioning(name) {
+		if sym := f.Symbols.Global.Symbols[name]; sym != nil && sym.Kind == scopes.Global {
+			out = append(out, location(f.Path, sym.Decl))
+			for _, id := range sym.Redefs {
+				out = append(out, location(f.Path, id))
+			}
+		}
+	}
+	return out
+}
+
+// References returns the uses of the global name across the project,
+// ordered by path and position.
+func (p *Project) References(name string) []definition.Location {
+	var out []definition.Location
+	for _, f := range p.mentioning(name) {
+		if sym := f.Symbols.Global.Symbols[name]; sym != nil {
+			for _, id := range sym.Refs {
+				out = append(out, location(f.Path, id))
+			}
+		}
+	}
+	return out
+}
+
+// Globals returns every global name defined somewhere in the project.
+func (p *Project) Globals() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var names []string
+	for name, paths := range p.names {
+		for path := range paths {
+			if sym := p.files[path].Symbols.Global.Symbols[name]; sym.Kind == scopes.Global {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Signature returns the parameters of the global function name, as
+// defined by the first file in path order that assigns it a function.
+func (p *Project) Signature(name string) ([]string, bool) {
+	for _, f := range p.mentioning(name) {
+		if sym := f.Symbols.Global.Symbols[name]; sym != nil && sym.Func != nil {
+			return sym.Func.Signature(), true
+		}
+	}
+	return nil, false
+}
+
+func (p *Project) mentioning(name string) []*File {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var files []*File
+	for path := range p.names[name] {
+		files = append(files, p.files[path])
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+func location(path string, id *ast.Ident) definition.Location {
+	return definition.Location{File: path, Span: ast.Span{Start: id.Pos(), End: id.EndPos()}}
+}
+
+var _ definition.Index = (*Project)(nil)
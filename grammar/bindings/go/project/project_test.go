@@ -0,0 +1,197 @@
+package project_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/definition"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func write(t *testing.T, path, src string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestProject(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.wz")
+	main := filepath.Join(dir, "app", "main.wabznasm")
+	write(t, lib, "add: {[x;y] x+y}")
+	write(t, main, "add[1;2]")
+	write(t, filepath.Join(dir, "notes.txt"), "add: 1")
+	write(t, filepath.Join(dir, ".hidden", "skip.wz"), "add: 2")
+
+	p, err := project.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := p.Files(); !slices.Equal(got, []string{main, lib}) {
+		t.Fatalf("Files() = %v", got)
+	}
+	if defs := p.Definitions("add"); len(defs) != 1 || defs[0].File != lib {
+		t.Errorf("Definitions(add) = %+v", defs)
+	}
+	if refs := p.References("add"); len(refs) != 1 || refs[0].File != main {
+		t.Errorf("References(add) = %+v", refs)
+	}
+	if got := p.Globals(); !slices.Equal(got, []string{"add"}) {
+		t.Errorf("Globals() = %v", got)
+	}
+	if params, ok := p.Signature("add"); !ok || !slices.Equal(params, []string{"x", "y"}) {
+		t.Errorf("Signature(add) = %v, %v", params, ok)
+	}
+
+	// Go to definition from main resolves through the project.
+	f := p.File(main)
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseBytes(f.Source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	locs := definition.AtIn(p, main, tree.Tree, f.Source, tree_sitter.Point{})
+	if len(locs) != 1 || locs[0].File != lib {
+		t.Errorf("definition from main = %+v", locs)
+	}
+}
+
+func TestModule(t *testing.T) {
+	dir := t.TempDir()
+	own := filepath.Join(dir, "main.wz")
+	dep := filepath.Join(dir, project.VendorDir, "stats", "lib", "mean.wz")
+	write(t, own, "mean[1;2]")
+	write(t, dep, "mean: {[x;y] (x+y)%2}")
+
+	p, err := project.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if defs := p.Definitions("mean"); len(defs) != 1 || defs[0].File != dep {
+		t.Errorf("Definitions(mean) = %+v", defs)
+	}
+	if got := p.Module(dep); got != "stats" {
+		t.Errorf("Module(%s) = %q, want stats", dep, got)
+	}
+	if got := p.Module(own); got != "" {
+		t.Errorf("Module(%s) = %q, want none", own, got)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.wz")
+	b := filepath.Join(dir, "b.wz")
+	write(t, a, "f: {x}")
+	write(t, b, "g: 1")
+	p, err := project.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if changed, err := p.Refresh(); err != nil || len(changed) != 0 {
+		t.Fatalf("Refresh() with no changes = %v, %v", changed, err)
+	}
+
+	write(t, a, "h: {x*2}")
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(a, future, future); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(b)
+	changed, err := p.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changed, []string{a, b}) {
+		t.Errorf("Refresh() changed = %v", changed)
+	}
+	if p.Definitions("f") != nil || p.Definitions("g") != nil || len(p.Definitions("h")) != 1 {
+		t.Errorf("index not updated: globals %v", p.Globals())
+	}
+
+	if err := p.Set(a, []byte("k: 1+")); err != nil {
+		t.Fatal(err)
+	}
+	if f := p.File(a); len(f.Diagnostics) == 0 || len(p.Definitions("k")) != 1 {
+		t.Errorf("Set did not reindex: %+v", f)
+	}
+	p.Remove(a)
+	if len(p.Files()) != 0 || p.Globals() != nil {
+		t.Errorf("Remove left %v %v", p.Files(), p.Globals())
+	}
+}
+
+func TestOpenOptions(t *testing.T) {
+	dir := t.TempDir()
+	for i := range 20 {
+		write(t, filepath.Join(dir, "f"+strconv.Itoa(i)+".wz"), "g"+strconv.Itoa(i)+": {x}")
+	}
+	// A source large enough that parsing it outlasts a microsecond.
+	slow := filepath.Join(dir, "slow.wz")
+	write(t, slow, "big: 1"+strings.Repeat("+1", 200000))
+
+	var events []project.Progress
+	p, err := project.OpenOptions(context.Background(), dir, project.Options{
+		Jobs:     3,
+		Timeout:  time.Microsecond,
+		Progress: func(pr project.Progress) { events = append(events, pr) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 21 {
+		t.Fatalf("got %d progress events, want 21", len(events))
+	}
+	timedOut := 0
+	for i, e := range events {
+		if e.Done != i+1 || e.Total != 21 {
+			t.Errorf("event %d = %+v", i, e)
+		}
+		if e.Err != nil {
+			if e.Path != slow || !errors.Is(e.Err, tree_sitter_wabznasm.ErrParseTimeout) {
+				t.Errorf("event %d error = %v", i, e.Err)
+			}
+			timedOut++
+		}
+	}
+	if timedOut != 1 {
+		t.Errorf("%d files timed out, want 1", timedOut)
+	}
+	if len(p.Files()) != 20 || p.File(slow) != nil || len(p.Definitions("g7")) != 1 {
+		t.Errorf("indexed %d files", len(p.Files()))
+	}
+}
+
+func TestOpenOptionsCancelled(t *testing.T) {
+	dir := t.TempDir()
+	write(t, filepath.Join(dir, "a.wz"), "a: 1")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := project.OpenOptions(ctx, dir, project.Options{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("OpenOptions with a cancelled context = %v", err)
+	}
+}
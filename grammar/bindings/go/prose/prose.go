@@ -0,0 +1,61 @@
+// Package prose extracts the text of a wabznasm source written for people
+// rather than the parser, so that spell checkers and secret scanners can
+// read it without tripping over identifiers and operators.
+//
+// Comments are the only such text: the grammar has no string or symbol
+// literals, and numbers and identifiers are code. Should literals be
+// added, their contents belong here too, under a Kind of their own.
+package prose
+
+import (
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+// Kind classifies a run of prose.
+type Kind string
+
+// Comment is the text of a comment after its backslash.
+const Comment Kind = "comment"
+
+// Text is a run of prose and where it lies in the source.
+type Text struct {
+	Kind Kind
+	Text string
+	// Range covers Text exactly, so that a finding at byte i of Text is at
+	// byte Range.StartByte+i of the source, on the same row.
+	Range tree_sitter.Range
+}
+
+// Of returns the prose of tree, whose text is source, in source order.
+// The backslash opening a comment and the blanks around its text are left
+// out, and comments with no text are skipped.
+func Of(tree *tree_sitter.Tree, source []byte) []Text {
+	var out []Text
+	for _, tok := range tree_sitter_wabznasm.Tokens(tree, source) {
+		if !tok.IsComment() {
+			continue
+		}
+		start, end := 1, len(tok.Text)
+		for start < end && isBlank(tok.Text[start]) {
+			start++
+		}
+		for end > start && isBlank(tok.Text[end-1]) {
+			end--
+		}
+		if start == end {
+			continue
+		}
+		// Comments run to the end of their line, so the text is on the row
+		// the comment starts on.
+		r := tok.Range
+		r.StartByte = tok.Range.StartByte + uint(start)
+		r.EndByte = tok.Range.StartByte + uint(end)
+		r.StartPoint.Column = tok.Range.StartPoint.Column + uint(start)
+		r.EndPoint = tree_sitter.Point{Row: r.StartPoint.Row, Column: tok.Range.StartPoint.Column + uint(end)}
+		out = append(out, Text{Kind: Comment, Text: tok.Text[start:end], Range: r})
+	}
+	return out
+}
+
+func isBlank(b byte) bool { return b == ' ' || b == '\t' || b == '\r' }
@@ -0,0 +1,48 @@
+package prose_test
+
+import (
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/prose"
+)
+
+func TestOf(t *testing.T) {
+	src := "\\ Teh sum of squares\nf: {[api_key] \\\tkey: sk-123  \n  api_key*2 \\\n}\n\\"
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+
+	got := prose.Of(tree.Tree, []byte(src))
+	want := []struct {
+		text      string
+		row, col  uint
+		startByte uint
+	}{
+		{"Teh sum of squares", 0, 2, 2},
+		{"key: sk-123", 1, 16, 37},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i, w := range want {
+		g := got[i]
+		if g.Kind != prose.Comment || g.Text != w.text || g.Range.StartPoint != (tree_sitter.Point{Row: w.row, Column: w.col}) || g.Range.StartByte != w.startByte {
+			t.Errorf("%d: %+v, want %q at %d:%d", i, g, w.text, w.row, w.col)
+		}
+		if s := src[g.Range.StartByte:g.Range.EndByte]; s != g.Text {
+			t.Errorf("%d: range covers %q", i, s)
+		}
+		if g.Range.EndPoint.Column-g.Range.StartPoint.Column != uint(len(g.Text)) {
+			t.Errorf("%d: points %v-%v", i, g.Range.StartPoint, g.Range.EndPoint)
+		}
+	}
+}
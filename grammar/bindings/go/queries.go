@@ -0,0 +1,52 @@
+package tree_sitter_wabznasm
+
+import (
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/queries"
+)
+
+// HighlightQuery compiles the bundled highlights.scm. The caller must close
+// the returned query.
+func HighlightQuery() (*memtrack.TrackedQuery, error) {
+	return compileQuery(queries.Highlights)
+}
+
+// LocalsQuery compiles the bundled locals.scm. The caller must close the
+// returned query.
+func LocalsQuery() (*memtrack.TrackedQuery, error) {
+	return compileQuery(queries.Locals)
+}
+
+// InjectionsQuery compiles the bundled injections.scm. The caller must close
+// the returned query.
+func InjectionsQuery() (*memtrack.TrackedQuery, error) {
+	return compileQuery(queries.Injections)
+}
+
+// IndentsQuery compiles the bundled indents.scm. The caller must close the
+// returned query.
+func IndentsQuery() (*memtrack.TrackedQuery, error) {
+	return compileQuery(queries.Indents)
+}
+
+// TextobjectsQuery compiles the bundled textobjects.scm. The caller must
+// close the returned query.
+func TextobjectsQuery() (*memtrack.TrackedQuery, error) {
+	return compileQuery(queries.Textobjects)
+}
+
+// NewQuery compiles source against the wabznasm language. The caller must
+// close the returned query.
+func NewQuery(source string) (*memtrack.TrackedQuery, error) {
+	return compileQuery(source)
+}
+
+func compileQuery(source string) (*memtrack.TrackedQuery, error) {
+	q, err := tree_sitter.NewQuery(tree_sitter.NewLanguage(Language()), source)
+	if err != nil {
+		// Return a nil interface rather than a typed nil *QueryError.
+		return nil, err
+	}
+	return memtrack.NewQuery(q), nil
+}
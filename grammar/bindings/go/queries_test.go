@@ -0,0 +1,63 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+func TestBundledQueriesCompile(t *testing.T) {
+	for name, compile := range map[string]func() (*memtrack.TrackedQuery, error){
+		"highlights":  tree_sitter_wabznasm.HighlightQuery,
+		"locals":      tree_sitter_wabznasm.LocalsQuery,
+		"injections":  tree_sitter_wabznasm.InjectionsQuery,
+		"indents":     tree_sitter_wabznasm.IndentsQuery,
+		"textobjects": tree_sitter_wabznasm.TextobjectsQuery,
+	} {
+		q, err := compile()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if q.PatternCount() == 0 {
+			t.Errorf("%s: no patterns", name)
+		}
+		q.Close()
+	}
+}
+
+func TestHighlightQueryCaptures(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	src := []byte("add: {[x;y] x+y}")
+	tree, err := parser.ParseBytes(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+
+	q, err := tree_sitter_wabznasm.HighlightQuery()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Close()
+	cursor := tree_sitter.NewQueryCursor()
+	defer cursor.Close()
+
+	seen := map[string]bool{}
+	captures := cursor.Captures(q.Query, tree.RootNode(), src)
+	for match, index := captures.Next(); match != nil; match, index = captures.Next() {
+		c := match.Captures[index]
+		seen[q.CaptureNames()[c.Index]+"="+c.Node.Utf8Text(src)] = true
+	}
+	for _, want := range []string{"function=add", "variable.parameter=x", "operator=+"} {
+		if !seen[want] {
+			t.Errorf("missing capture %s; got %v", want, seen)
+		}
+	}
+}
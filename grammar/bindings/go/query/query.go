@@ -0,0 +1,210 @@
+// Package query finds common wabznasm constructs without hand-written
+// tree-sitter queries.
+//
+// Each constructor returns a Query that knows both its query source, for
+// inspection, and how to turn the raw captures of a match into a typed
+// result:
+//
+//	calls, err := query.CallsTo("add").Find(tree, source)
+//	for _, c := range calls {
+//		fmt.Println(c.Name, len(c.Args))
+//	}
+package query
+
+import (
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+// Query is a compiled-on-demand tree-sitter query whose matches convert to M.
+type Query[M any] struct {
+	source string
+	build  func(m match) M
+}
+
+// String returns the tree-sitter query source.
+func (q Query[M]) String() string { return q.source }
+
+// Find runs the query over tree and returns the matches in source order.
+func (q Query[M]) Find(tree *tree_sitter.Tree, source []byte) ([]M, error) {
+	return q.FindIn(tree.RootNode(), source)
+}
+
+// FindIn runs the query over the subtree rooted at node.
+func (q Query[M]) FindIn(node *tree_sitter.Node, source []byte) ([]M, error) {
+	compiled, err := tree_sitter_wabznasm.NewQuery(q.source)
+	if err != nil {
+		return nil, err
+	}
+	defer compiled.Close()
+	cursor := memtrack.NewQueryCursor()
+	defer cursor.Close()
+
+	names := compiled.CaptureNames()
+	var out []M
+	matches := cursor.Matches(compiled.Query, node, source)
+	for m := matches.Next(); m != nil; m = matches.Next() {
+		mm := match{source: source, captures: map[string]tree_sitter.Node{}}
+		for _, c := range m.Captures {
+			mm.captures[names[c.Index]] = c.Node
+		}
+		out = append(out, q.build(mm))
+	}
+	return out, nil
+}
+
+type match struct {
+	source   []byte
+	captures map[string]tree_sitter.Node
+}
+
+func (m match) node(name string) (tree_sitter.Node, bool) {
+	n, ok := m.captures[name]
+	return n, ok
+}
+
+func (m match) text(name string) string {
+	if n, ok := m.captures[name]; ok {
+		return n.Utf8Text(m.source)
+	}
+	return ""
+}
+
+// Assignment is a match of Assignments or AssignmentsTo.
+type Assignment struct {
+	Node  tree_sitter.Node
+	Name  string
+	Ident tree_sitter.Node
+	// Value is the function_body or expression assigned.
+	Value tree_sitter.Node
+}
+
+// Function is a match of Functions or FunctionsNamed: an assignment whose
+// value is a function literal.
+type Function struct {
+	Node  tree_sitter.Node
+	Name  string
+	Ident tree_sitter.Node
+	// Params holds the explicit parameter names; it is nil for a function
+	// using the implicit x, y and z.
+	Params []string
+	Body   tree_sitter.Node
+}
+
+// Call is a match of Calls or CallsTo.
+type Call struct {
+	Node  tree_sitter.Node
+	Name  string
+	Ident tree_sitter.Node
+	Args  []tree_sitter.Node
+}
+
+// Identifier is a match of Identifiers or IdentifiersNamed.
+type Identifier struct {
+	Node tree_sitter.Node
+	Name string
+}
+
+// Assignments matches every assignment.
+func Assignments() Query[Assignment] { return assignments("") }
+
+// AssignmentsTo matches assignments to name.
+func AssignmentsTo(name string) Query[Assignment] { return assignments(name) }
+
+func assignments(name string) Query[Assignment] {
+	return Query[Assignment]{
+		source: `(assignment name: (identifier) @name value: (_) @value` + eq("name", name) + `) @assignment`,
+		build: func(m match) Assignment {
+			a := Assignment{Name: m.text("name")}
+			a.Node, _ = m.node("assignment")
+			a.Ident, _ = m.node("name")
+			a.Value, _ = m.node("value")
+			return a
+		},
+	}
+}
+
+// Functions matches every function definition.
+func Functions() Query[Function] { return functions("") }
+
+// FunctionsNamed matches definitions of the function name.
+func FunctionsNamed(name string) Query[Function] { return functions(name) }
+
+func functions(name string) Query[Function] {
+	return Query[Function]{
+		source: `(assignment name: (identifier) @name value: (function_body params: (parameter_list)? @params body: (_) @body)` +
+			eq("name", name) + `) @function`,
+		build: func(m match) Function {
+			f := Function{Name: m.text("name")}
+			f.Node, _ = m.node("function")
+			f.Ident, _ = m.node("name")
+			f.Body, _ = m.node("body")
+			if params, ok := m.node("params"); ok {
+				f.Params = []string{}
+				cursor := memtrack.Walk(&params)
+				for _, p := range params.ChildrenByFieldName("param", cursor.TreeCursor) {
+					f.Params = append(f.Params, p.Utf8Text(m.source))
+				}
+				cursor.Close()
+			}
+			return f
+		},
+	}
+}
+
+// Calls matches every function call.
+func Calls() Query[Call] { return calls("") }
+
+// CallsTo matches calls of the function name.
+func CallsTo(name string) Query[Call] { return calls(name) }
+
+func calls(name string) Query[Call] {
+	return Query[Call]{
+		source: `(function_call function: (identifier) @name args: (argument_list)? @args` + eq("name", name) + `) @call`,
+		build: func(m match) Call {
+			c := Call{Name: m.text("name")}
+			c.Node, _ = m.node("call")
+			c.Ident, _ = m.node("name")
+			if args, ok := m.node("args"); ok {
+				cursor := memtrack.Walk(&args)
+				c.Args = args.ChildrenByFieldName("arg", cursor.TreeCursor)
+				cursor.Close()
+			}
+			return c
+		},
+	}
+}
+
+// Identifiers matches every identifier, whether defined, read or called.
+func Identifiers() Query[Identifier] { return identifiers("") }
+
+// IdentifiersNamed matches identifiers spelled name.
+func IdentifiersNamed(name string) Query[Identifier] { return identifiers(name) }
+
+func identifiers(name string) Query[Identifier] {
+	return Query[Identifier]{
+		source: `((identifier) @identifier` + eq("identifier", name) + `)`,
+		build: func(m match) Identifier {
+			id := Identifier{Name: m.text("identifier")}
+			id.Node, _ = m.node("identifier")
+			return id
+		},
+	}
+}
+
+// eq returns an #eq? predicate comparing capture with name, or nothing when
+// name is empty.
+func eq(capture, name string) string {
+	if name == "" {
+		return ""
+	}
+	return ` (#eq? @` + capture + ` ` + quote(name) + `)`
+}
+
+// quote writes s as a query string literal.
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
@@ -0,0 +1,116 @@
+package query_test
+
+import (
+	"os"
+	"slices"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/query"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func parse(t *testing.T, src string) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+func TestFunctions(t *testing.T) {
+	src := "add: {[x;y] x+y}"
+	tree := parse(t, src)
+	fns, err := query.FunctionsNamed("add").Find(tree.Tree, []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fns) != 1 || fns[0].Name != "add" || !slices.Equal(fns[0].Params, []string{"x", "y"}) {
+		t.Fatalf("FunctionsNamed(add) = %+v", fns)
+	}
+	if got := fns[0].Body.Utf8Text([]byte(src)); got != "x+y" {
+		t.Errorf("body = %q", got)
+	}
+	if fns, _ := query.FunctionsNamed("sub").Find(tree.Tree, []byte(src)); len(fns) != 0 {
+		t.Errorf("FunctionsNamed(sub) = %+v", fns)
+	}
+
+	src = "inc: {x+1}"
+	tree = parse(t, src)
+	fns, err = query.Functions().Find(tree.Tree, []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fns) != 1 || fns[0].Params != nil {
+		t.Errorf("Functions() = %+v, want one implicit function", fns)
+	}
+}
+
+func TestCalls(t *testing.T) {
+	src := "add[f[1];add[2;3]]"
+	tree := parse(t, src)
+	calls, err := query.CallsTo("add").Find(tree.Tree, []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || len(calls[0].Args) != 2 || calls[1].Node.StartByte() != 9 {
+		t.Fatalf("CallsTo(add) = %+v", calls)
+	}
+	all, err := query.Calls().Find(tree.Tree, []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, c := range all {
+		names = append(names, c.Name)
+	}
+	if !slices.Equal(names, []string{"add", "f", "add"}) {
+		t.Errorf("Calls() names = %v", names)
+	}
+
+	src = "f[]"
+	tree = parse(t, src)
+	if calls, _ := query.Calls().Find(tree.Tree, []byte(src)); len(calls) != 1 || calls[0].Args != nil {
+		t.Errorf("Calls() on f[] = %+v", calls)
+	}
+}
+
+func TestAssignmentsAndIdentifiers(t *testing.T) {
+	src := "total: a+a*b"
+	tree := parse(t, src)
+	as, err := query.Assignments().Find(tree.Tree, []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(as) != 1 || as[0].Name != "total" || as[0].Value.Utf8Text([]byte(src)) != "a+a*b" {
+		t.Errorf("Assignments() = %+v", as)
+	}
+	if as, _ := query.AssignmentsTo("other").Find(tree.Tree, []byte(src)); len(as) != 0 {
+		t.Errorf("AssignmentsTo(other) = %+v", as)
+	}
+	ids, err := query.IdentifiersNamed("a").Find(tree.Tree, []byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 2 {
+		t.Errorf("IdentifiersNamed(a) = %+v", ids)
+	}
+}
+
+func TestString(t *testing.T) {
+	want := `(function_call function: (identifier) @name args: (argument_list)? @args (#eq? @name "a\"b")) @call`
+	if got := query.CallsTo(`a"b`).String(); got != want {
+		t.Errorf("String() = %s, want %s", got, want)
+	}
+}
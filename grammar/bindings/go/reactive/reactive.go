@@ -0,0 +1,373 @@
+// Package reactive recomputes named wabznasm expressions when the values
+// they depend on change, the way a spreadsheet recalculates its cells.
+//
+// A Sheet holds named cells. An input cell holds a value the host sets; a
+// formula cell holds an expression over other cells, which the Sheet
+// evaluates in a Session of its Engine. Setting a cell re-evaluates only
+// the formulas downstream of it, each after the formulas it uses, and a
+// formula whose value comes out unchanged stops the recalculation there.
+// The host learns of every value that changed through the functions passed
+// to Watch:
+//
+//	s := reactive.New(e)
+//	defer s.Close()
+//	s.Watch(func(c reactive.Change) { log.Println(c.Name, c.Value, c.Err) })
+//	s.SetFormula(ctx, "tax", "net*rate")
+//	s.SetInput("rate", eval.Long(2))
+//	s.SetInput("net", eval.Long(100)) // tax changes to 200
+//
+// A formula failing to evaluate holds its error instead of a value, and so
+// does every formula using it. A Sheet is safe for concurrent use.
+package reactive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/depgraph"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/engine"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// ErrName is returned for a cell name that is not an identifier.
+var ErrName = errors.New("reactive: cell name is not an identifier")
+
+// ErrFormula is returned for a formula that is not a single expression.
+var ErrFormula = errors.New("reactive: formula is not an expression")
+
+// ErrNoCell is returned by Value for a name the Sheet has no cell for.
+var ErrNoCell = errors.New("reactive: no such cell")
+
+// Change reports a cell whose value changed.
+type Change struct {
+	Name string
+	// Value is the new value, or nil if the cell failed to evaluate or
+	// was deleted.
+	Value eval.Value
+	// Err is the error of a formula failing to evaluate, or of a formula
+	// it uses.
+	Err error
+}
+
+// cell is an input, or a formula if prog is set.
+type cell struct {
+	name    string
+	formula string
+	prog    *engine.Program
+	table   *scopes.Table
+	// index orders the formulas for depgraph, in order of first
+	// definition.
+	index int
+	value eval.Value
+	err   error
+}
+
+// Sheet is a set of cells. The caller must call Close.
+type Sheet struct {
+	engine *engine.Engine
+	pool   *tree_sitter_wabznasm.ParserPool
+
+	mu      sync.Mutex
+	session *engine.Session
+	cells   map[string]*cell
+	next    int
+
+	// pending are the changes not yet passed to the watchers, in order.
+	pending  []Change
+	watchers map[int]func(Change)
+	watchID  int
+
+	// notify is held while the watchers run, so that they see the changes
+	// of one recalculation after those of the one before.
+	notify sync.Mutex
+}
+
+// New returns an empty Sheet evaluating its formulas with e.
+func New(e *engine.Engine) *Sheet {
+	return &Sheet{
+		engine:   e,
+		pool:     tree_sitter_wabznasm.NewParserPool(),
+		session:  e.NewSession(),
+		cells:    map[string]*cell{},
+		watchers: map[int]func(Change){},
+	}
+}
+
+// Close releases the parsers of s. Later calls of SetFormula fail.
+func (s *Sheet) Close() { s.pool.Close() }
+
+// Watch calls fn with every change of a value, in the goroutine setting
+// the cell, once the recalculation is done, and in the order the cells
+// were recomputed. fn may read the Sheet and stop watching, but not set
+// its cells. The returned function stops the calls.
+func (s *Sheet) Watch(fn func(Change)) (stop func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.watchID
+	s.watchID++
+	s.watchers[id] = fn
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.watchers, id)
+	}
+}
+
+// SetInput sets the cell name to the value v, replacing any formula, and
+// recomputes the formulas using it.
+func (s *Sheet) SetInput(name string, v eval.Value) error {
+	if !isIdent(name) {
+		return fmt.Errorf("%w: %q", ErrName, name)
+	}
+	s.mu.Lock()
+	c := s.cells[name]
+	if c == nil {
+		c = &cell{name: name}
+		s.cells[name] = c
+	}
+	if c.prog == nil && c.err == nil && c.value != nil && eval.Equal(c.value, v) {
+		s.mu.Unlock()
+		return nil
+	}
+	*c = cell{name: name, value: v}
+	s.session.Define(name, v)
+	s.recalc(context.Background(), []Change{{Name: name, Value: v}}, name)
+	return nil
+}
+
+// SetFormula sets the cell name to the expression src, replacing any
+// value, and recomputes it and the formulas using it. It fails with
+// ErrFormula or a syntax error if src is not one expression, and with a
+// *depgraph.CycleError if the formula would use its own value, directly
+// or not; the Sheet is left as it was. Errors evaluating the formulas
+// are those of their cells, not of SetFormula.
+func (s *Sheet) SetFormula(ctx context.Context, name, src string) error {
+	if !isIdent(name) {
+		return fmt.Errorf("%w: %q", ErrName, name)
+	}
+	text := name + ": " + src
+	prog, err := s.engine.Compile(ctx, []byte(text))
+	if err != nil {
+		return err
+	}
+	table, err := s.analyse(text)
+	if err != nil {
+		return err
+	}
+	if a, ok := table.Files[0].Stmt.(*ast.Assignment); !ok || a.Name == nil || a.Name.Name != name {
+		return fmt.Errorf("%w: %q", ErrFormula, src)
+	}
+
+	s.mu.Lock()
+	old := s.cells[name]
+	c := &cell{name: name, formula: src, prog: prog, table: table, index: s.next}
+	if old != nil && old.prog != nil {
+		c.index = old.index
+	}
+	s.cells[name] = c
+	if _, err := s.graph().Affected(name); err != nil {
+		if old != nil {
+			s.cells[name] = old
+		} else {
+			delete(s.cells, name)
+		}
+		s.mu.Unlock()
+		return err
+	}
+	if c.index == s.next {
+		s.next++
+	}
+	if old != nil {
+		c.value, c.err = old.value, old.err
+	}
+	s.recalc(ctx, nil, name)
+	return nil
+}
+
+// Delete removes the cell name, if any, and recomputes the formulas that
+// used it, which then fail to find it.
+func (s *Sheet) Delete(name string) {
+	s.mu.Lock()
+	if s.cells[name] == nil {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.cells, name)
+	// A Session cannot forget a global, so the cells move to a new one.
+	s.session = s.engine.NewSession()
+	for _, c := range s.cells {
+		if c.err == nil && c.value != nil {
+			s.session.Define(c.name, c.value)
+		}
+	}
+	s.recalc(context.Background(), []Change{{Name: name}}, name)
+}
+
+// Value returns the value of the cell name, or the error of its formula.
+func (s *Sheet) Value(name string) (eval.Value, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.cells[name]
+	if c == nil {
+		return nil, fmt.Errorf("%w: %s", ErrNoCell, name)
+	}
+	return c.value, c.err
+}
+
+// Formula returns the expression of the cell name, and false if it is not
+// a formula.
+func (s *Sheet) Formula(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.cells[name]
+	if c == nil || c.prog == nil {
+		return "", false
+	}
+	return c.formula, true
+}
+
+// Names returns the names of the cells, sorted.
+func (s *Sheet) Names() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := make([]string, 0, len(s.cells))
+	for name := range s.cells {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// recalc recomputes the formulas affected by the cell changed, whose own
+// change, if any, is in changes, and passes the changes to the watchers. A
+// formula is recomputed if it is changed itself or if the value of one it
+// uses changed. It must be called with s.mu held, and releases it.
+func (s *Sheet) recalc(ctx context.Context, changes []Change, changed string) {
+	g := s.graph()
+	order, _ := g.Affected(changed)
+	dirty := map[string]bool{changed: len(changes) > 0}
+	for _, name := range order {
+		c, n := s.cells[name], g.Node(name)
+		if name != changed && !anyOf(dirty, n.Deps) {
+			continue
+		}
+		value, err := s.eval(ctx, c, n.Deps)
+		if errorText(err) == errorText(c.err) && (err != nil || eval.Equal(value, c.value)) {
+			continue
+		}
+		c.value, c.err = value, err
+		dirty[name] = true
+		changes = append(changes, Change{Name: name, Value: value, Err: err})
+	}
+	s.pending = append(s.pending, changes...)
+	s.mu.Unlock()
+	s.deliver()
+}
+
+// eval evaluates the formula of c, which uses deps, unless one of them
+// failed.
+func (s *Sheet) eval(ctx context.Context, c *cell, deps []string) (eval.Value, error) {
+	for _, dep := range deps {
+		if d := s.cells[dep]; d != nil && d.err != nil {
+			return nil, fmt.Errorf("%s: %w", dep, d.err)
+		}
+	}
+	return s.session.Run(ctx, c.prog)
+}
+
+// deliver passes the pending changes to the watchers. The watchers run
+// without s.mu held, so that they may read the Sheet.
+func (s *Sheet) deliver() {
+	s.notify.Lock()
+	defer s.notify.Unlock()
+	for {
+		s.mu.Lock()
+		pending := s.pending
+		s.pending = nil
+		ids := make([]int, 0, len(s.watchers))
+		for id := range s.watchers {
+			ids = append(ids, id)
+		}
+		sort.Ints(ids)
+		watchers := make([]func(Change), len(ids))
+		for i, id := range ids {
+			watchers[i] = s.watchers[id]
+		}
+		s.mu.Unlock()
+		if len(pending) == 0 {
+			return
+		}
+		for _, ch := range pending {
+			for _, fn := range watchers {
+				fn(ch)
+			}
+		}
+	}
+}
+
+// graph returns the dependency graph of the formulas.
+func (s *Sheet) graph() *depgraph.Graph {
+	var formulas []*cell
+	for _, c := range s.cells {
+		if c.prog != nil {
+			formulas = append(formulas, c)
+		}
+	}
+	sort.Slice(formulas, func(i, j int) bool { return formulas[i].index < formulas[j].index })
+	tables := make([]*scopes.Table, len(formulas))
+	for i, c := range formulas {
+		tables[i] = c.table
+	}
+	return depgraph.Build(tables)
+}
+
+// analyse resolves the names of src, which has no syntax errors.
+func (s *Sheet) analyse(src string) (*scopes.Table, error) {
+	p, err := s.pool.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer s.pool.Put(p)
+	tree, err := p.ParseString(src)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	return scopes.FromTree(tree.Tree, []byte(src)), nil
+}
+
+// isIdent reports whether name is an identifier: a letter or underscore
+// followed by letters, digits and underscores.
+func isIdent(name string) bool {
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+		case i > 0 && r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+func anyOf(set map[string]bool, names []string) bool {
+	for _, name := range names {
+		if set[name] {
+			return true
+		}
+	}
+	return false
+}
+
+func errorText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
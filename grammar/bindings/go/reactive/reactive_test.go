@@ -0,0 +1,167 @@
+package reactive_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/depgraph"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/engine"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/reactive"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+// newSheet returns a Sheet and the changes it reports, as name=value or
+// name!.
+func newSheet(t *testing.T) (*reactive.Sheet, *[]string) {
+	t.Helper()
+	e := engine.New(engine.Options{})
+	t.Cleanup(e.Close)
+	s := reactive.New(e)
+	t.Cleanup(s.Close)
+	var changes []string
+	s.Watch(func(c reactive.Change) {
+		if c.Err != nil {
+			changes = append(changes, c.Name+"!")
+			return
+		}
+		changes = append(changes, fmt.Sprintf("%s=%v", c.Name, c.Value))
+	})
+	return s, &changes
+}
+
+func TestRecalc(t *testing.T) {
+	s, changes := newSheet(t)
+	ctx := context.Background()
+	for _, f := range [][2]string{
+		{"sq", "{x*x}"},
+		{"net", "gross-costs"},
+		{"tax", "net*rate"},
+		{"total", "net+tax"},
+		{"big", "sq[net]"},
+		{"other", "costs*2"},
+	} {
+		if err := s.SetFormula(ctx, f[0], f[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for name, v := range map[string]eval.Value{"gross": eval.Long(150), "costs": eval.Long(50), "rate": eval.Long(2)} {
+		if err := s.SetInput(name, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v, err := s.Value("total"); err != nil || v.String() != "300" {
+		t.Fatalf("total = %v, %v", v, err)
+	}
+
+	*changes = nil
+	s.SetInput("rate", eval.Long(3))
+	if want := []string{"rate=3", "tax=300", "total=400"}; !slices.Equal(*changes, want) {
+		t.Errorf("changing rate reported %v, want %v", *changes, want)
+	}
+
+	// An unchanged value stops the recalculation.
+	*changes = nil
+	s.SetInput("rate", eval.Long(3))
+	s.SetFormula(ctx, "net", "gross-(costs*1)")
+	if len(*changes) != 0 {
+		t.Errorf("unchanged values reported %v", *changes)
+	}
+
+	*changes = nil
+	s.SetInput("gross", eval.Long(0))
+	if want := []string{"gross=0", "net=-50", "tax=-150", "total=-200", "big=2500"}; !slices.Equal(*changes, want) {
+		t.Errorf("changing gross reported %v, want %v", *changes, want)
+	}
+
+	if got, ok := s.Formula("tax"); !ok || got != "net*rate" {
+		t.Errorf("Formula(tax) = %q, %v", got, ok)
+	}
+	if _, ok := s.Formula("rate"); ok {
+		t.Error("Formula(rate) is set for an input")
+	}
+	if got := s.Names(); !slices.Equal(got, []string{"big", "costs", "gross", "net", "other", "rate", "sq", "tax", "total"}) {
+		t.Errorf("Names() = %v", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	s, changes := newSheet(t)
+	ctx := context.Background()
+	s.SetInput("a", eval.Long(1))
+	s.SetFormula(ctx, "b", "a+1")
+	s.SetFormula(ctx, "c", "b*2")
+
+	// A failing formula fails the formulas using it.
+	*changes = nil
+	s.SetFormula(ctx, "b", "a+missing")
+	if want := []string{"b!", "c!"}; !slices.Equal(*changes, want) {
+		t.Errorf("failing b reported %v, want %v", *changes, want)
+	}
+	if _, err := s.Value("c"); err == nil {
+		t.Error("c has no error")
+	}
+	*changes = nil
+	s.SetInput("missing", eval.Long(0))
+	if want := []string{"missing=0", "b=1", "c=2"}; !slices.Equal(*changes, want) {
+		t.Errorf("fixing b reported %v, want %v", *changes, want)
+	}
+
+	// Deleting a cell fails the formulas using it.
+	*changes = nil
+	s.Delete("missing")
+	if want := []string{"missing=<nil>", "b!", "c!"}; !slices.Equal(*changes, want) {
+		t.Errorf("deleting missing reported %v, want %v", *changes, want)
+	}
+	if _, err := s.Value("missing"); !errors.Is(err, reactive.ErrNoCell) {
+		t.Errorf("Value(missing) = %v", err)
+	}
+	if v, err := s.Value("a"); err != nil || v.String() != "1" {
+		t.Errorf("a after Delete = %v, %v", v, err)
+	}
+
+	var cycle *depgraph.CycleError
+	if err := s.SetFormula(ctx, "a", "c+1"); !errors.As(err, &cycle) {
+		t.Errorf("SetFormula(a, c+1) = %v, want a cycle", err)
+	}
+	if v, err := s.Value("a"); err != nil || v.String() != "1" {
+		t.Errorf("a after the cycle = %v, %v", v, err)
+	}
+	if err := s.SetFormula(ctx, "n", "n+1"); !errors.As(err, &cycle) {
+		t.Errorf("SetFormula(n, n+1) = %v, want a cycle", err)
+	}
+	if err := s.SetFormula(ctx, "1x", "1"); !errors.Is(err, reactive.ErrName) {
+		t.Errorf("SetFormula(1x) = %v", err)
+	}
+	if err := s.SetInput("a b", eval.Long(1)); !errors.Is(err, reactive.ErrName) {
+		t.Errorf("SetInput(a b) = %v", err)
+	}
+	if err := s.SetFormula(ctx, "d", "1+"); err == nil {
+		t.Error("SetFormula(d, 1+) succeeded")
+	}
+}
+
+func TestWatch(t *testing.T) {
+	s, _ := newSheet(t)
+	var seen []string
+	var stop func()
+	stop = s.Watch(func(c reactive.Change) {
+		// Watchers may read the sheet and stop watching.
+		v, _ := s.Value(c.Name)
+		seen = append(seen, fmt.Sprintf("%s=%v", c.Name, v))
+		stop()
+	})
+	s.SetInput("a", eval.Long(1))
+	s.SetInput("a", eval.Long(2))
+	if want := []string{"a=1"}; !slices.Equal(seen, want) {
+		t.Errorf("watcher saw %v, want %v", seen, want)
+	}
+}
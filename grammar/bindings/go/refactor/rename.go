@@ -0,0 +1,125 @@
+// Package refactor implements source-to-source refactorings for wabznasm.
+package refactor
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+var (
+	// ErrNoSymbol is returned when there is no identifier at the position.
+	ErrNoSymbol = errors.New("refactor: no symbol at position")
+	// ErrInvalidName is returned when the new name is not an identifier.
+	ErrInvalidName = errors.New("refactor: invalid identifier")
+	// ErrConflict is returned, wrapped with details, when the rename would
+	// change what some name refers to.
+	ErrConflict = errors.New("refactor: rename conflicts")
+)
+
+var identifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Edit replaces the source bytes [Start, End) with NewText.
+type Edit = ast.Edit
+
+// Apply applies edits to src and returns the result. Edits that overlap
+// fail with an error wrapping ast.ErrOverlap.
+func Apply(src []byte, edits []Edit) ([]byte, error) {
+	return ast.Apply(src, edits)
+}
+
+// Rename renames the symbol at the byte offset to newName throughout the
+// statements of source. It returns one edit per occurrence, in source
+// order, and the rewritten source.
+//
+// Implicit parameters cannot be renamed, and a rename is refused if the new
+// name would capture or be captured by another symbol, or would change the
+// implicit parameters of a function.
+func Rename(source []byte, offset uint, newName string) ([]Edit, []byte, error) {
+	if !identifier.MatchString(newName) {
+		return nil, nil, fmt.Errorf("%w: %q", ErrInvalidName, newName)
+	}
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer parser.Close()
+	script, err := parser.ParseScript(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer script.Close()
+
+	tab := scopes.FromScript(script)
+	sym := tab.ResolveAt(offset)
+	if sym == nil {
+		return nil, nil, ErrNoSymbol
+	}
+	if sym.Kind == scopes.Implicit {
+		return nil, nil, fmt.Errorf("%w: %s is an implicit parameter", ErrConflict, sym.Name)
+	}
+	if newName == sym.Name {
+		return nil, source, nil
+	}
+	if err := checkConflicts(tab, sym, newName); err != nil {
+		return nil, nil, err
+	}
+
+	occ := tab.Occurrences(sym)
+	edits := make([]Edit, len(occ))
+	for i, id := range occ {
+		edits[i] = Edit{Start: id.Pos(), End: id.EndPos(), NewText: newName}
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Start.Offset < edits[j].Start.Offset })
+	out, err := Apply(source, edits)
+	if err != nil {
+		return nil, nil, err
+	}
+	return edits, out, nil
+}
+
+func checkConflicts(tab *scopes.Table, sym *scopes.Symbol, newName string) error {
+	// Every occurrence must still resolve to sym: nothing visible there may
+	// already be called newName.
+	for _, id := range tab.Occurrences(sym) {
+		scope := tab.ScopeAt(id.Pos().Offset)
+		if other := scope.Lookup(newName); other != nil && other != sym {
+			return fmt.Errorf("%w: %s %s is already visible at %d:%d", ErrConflict, other.Kind, newName, id.Pos().Row+1, id.Pos().Column+1)
+		}
+		if isImplicitName(newName) {
+			for s := scope; s != nil; s = s.Parent {
+				if s.Func != nil && s.Func.Implicit() {
+					return fmt.Errorf("%w: %s would become an implicit parameter at %d:%d", ErrConflict, newName, id.Pos().Row+1, id.Pos().Column+1)
+				}
+			}
+		}
+	}
+	// No other use of newName may fall inside sym's scope, where the renamed
+	// symbol would capture it.
+	if sym.Scope != tab.Global {
+		for _, other := range tab.Symbols {
+			if other == sym || other.Name != newName {
+				continue
+			}
+			for _, id := range tab.Occurrences(other) {
+				if within(id, sym.Scope.Span) {
+					return fmt.Errorf("%w: renaming would capture %s at %d:%d", ErrConflict, newName, id.Pos().Row+1, id.Pos().Column+1)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func within(id *ast.Ident, span ast.Span) bool {
+	return span.Start.Offset <= id.Pos().Offset && id.EndPos().Offset <= span.End.Offset
+}
+
+func isImplicitName(name string) bool {
+	return name == "x" || name == "y" || name == "z"
+}
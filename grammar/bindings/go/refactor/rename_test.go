@@ -0,0 +1,78 @@
+package refactor_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/refactor"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		src, at, to, want string
+		edits             int
+	}{
+		{"add: {[x;y] x+y}", "x+", "a", "add: {[a;y] a+y}", 2},
+		{"add: {[x;y] x+y}", "add", "plus", "plus: {[x;y] x+y}", 1},
+		{"f: {[n] n*f[n-1]}", "f[", "fact", "fact: {[n] n*fact[n-1]}", 2},
+		{"t: a+a*b", "a*", "c", "t: c+c*b", 2},
+		{"g: {[x;x] x}", "x]", "v", "g: {[v;v] v}", 3},
+		{"a: 1\nb: a+1", "a:", "c", "c: 1\nb: c+1", 2},
+		{"n: 1\nn: n+1\nn*2", "n*", "m", "m: 1\nm: m+1\nm*2", 4},
+	}
+	for _, tt := range tests {
+		edits, out, err := refactor.Rename([]byte(tt.src), uint(strings.Index(tt.src, tt.at)), tt.to)
+		if err != nil {
+			t.Errorf("Rename(%q at %q): %v", tt.src, tt.at, err)
+			continue
+		}
+		if string(out) != tt.want || len(edits) != tt.edits {
+			t.Errorf("Rename(%q at %q) = %q with %d edits, want %q with %d", tt.src, tt.at, out, len(edits), tt.want, tt.edits)
+		}
+	}
+}
+
+func TestRenameRefused(t *testing.T) {
+	tests := []struct {
+		src, at, to string
+		err         error
+	}{
+		{"add: {[x;y] x+y}", "x+", "y", refactor.ErrConflict}, // collides with y
+		{"f: {[a] a+g}", "a+", "g", refactor.ErrConflict},     // would capture global g
+		{"f: {[a] a+g}", "g}", "a", refactor.ErrConflict},     // would be captured by a
+		{"f: {x+g}", "x+", "w", refactor.ErrConflict},         // implicit parameter
+		{"f: {x+g}", "g}", "y", refactor.ErrConflict},         // would add an implicit parameter
+		{"f: {[a] a}", "a}", "9a", refactor.ErrInvalidName},   // not an identifier
+		{"f: {[a] a+1}", "1", "b", refactor.ErrNoSymbol},      // not on a name
+		{"t: a+b", "a+", "t", refactor.ErrConflict},           // merges two globals
+	}
+	for _, tt := range tests {
+		_, _, err := refactor.Rename([]byte(tt.src), uint(strings.Index(tt.src, tt.at)), tt.to)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Rename(%q at %q to %q) = %v, want %v", tt.src, tt.at, tt.to, err, tt.err)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	edits, _, err := refactor.Rename([]byte("t: a+a*b"), 3, "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := refactor.Apply([]byte("t: a+a*b"), edits)
+	if err != nil || string(out) != "t: count+count*b" {
+		t.Errorf("Apply = %q, %v", out, err)
+	}
+	overlap := append(edits, refactor.Edit{Start: edits[0].Start, End: edits[1].End, NewText: "n"})
+	if _, err := refactor.Apply([]byte("t: a+a*b"), overlap); !errors.Is(err, ast.ErrOverlap) {
+		t.Errorf("Apply of overlapping edits = %v", err)
+	}
+}
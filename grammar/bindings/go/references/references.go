@@ -0,0 +1,51 @@
+// Package references finds every use of the name under a cursor.
+//
+// It shares Location and Index with package definition: At searches one
+// buffer, AtIn also searches the files of an index for uses of globals.
+package references
+
+import (
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/definition"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// At returns the occurrences in a single buffer of the symbol named at
+// point, in source order. The declaration is included when includeDecl is
+// set.
+func At(tree *tree_sitter.Tree, source []byte, point tree_sitter.Point, includeDecl bool) []definition.Location {
+	return AtIn(nil, "", tree, source, point, includeDecl)
+}
+
+// AtIn is like At for a buffer called file, adding the uses and, with
+// includeDecl, the definitions of a global found in idx.
+func AtIn(idx definition.Index, file string, tree *tree_sitter.Tree, source []byte, point tree_sitter.Point, includeDecl bool) []definition.Location {
+	tab := scopes.FromTree(tree, source)
+	sym := tab.ResolveAt(tree_sitter_wabznasm.OffsetForPoint(source, point))
+	if sym == nil {
+		return nil
+	}
+	return Of(idx, file, tab, sym, includeDecl)
+}
+
+// Of returns the occurrences of a resolved symbol of file.
+func Of(idx definition.Index, file string, tab *scopes.Table, sym *scopes.Symbol, includeDecl bool) []definition.Location {
+	ids := tab.ReferencesOf(sym)
+	if includeDecl {
+		ids = tab.Occurrences(sym)
+	}
+	var out []definition.Location
+	for _, id := range ids {
+		out = append(out, definition.Location{File: file, Span: ast.Span{Start: id.Pos(), End: id.EndPos()}})
+	}
+	global := sym.Kind == scopes.Global || sym.Kind == scopes.Free
+	if idx != nil && global {
+		if includeDecl {
+			out = definition.Merge(out, idx.Definitions(sym.Name))
+		}
+		out = definition.Merge(out, idx.References(sym.Name))
+	}
+	return out
+}
@@ -0,0 +1,50 @@
+package references_test
+
+import (
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/definition"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/references"
+)
+
+type fakeIndex struct{}
+
+func (fakeIndex) Definitions(string) []definition.Location { return nil }
+func (fakeIndex) References(name string) []definition.Location {
+	return []definition.Location{{File: "other.wz", Span: ast.Span{End: ast.Pos{Offset: 1, Column: 1}}}}
+}
+
+func TestAt(t *testing.T) {
+	src := "f: {[a] a*a+f[a-1]}"
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+
+	refs := references.At(tree.Tree, []byte(src), tree_sitter.Point{Column: 8}, false)
+	if len(refs) != 3 {
+		t.Errorf("references of a = %+v", refs)
+	}
+	refs = references.At(tree.Tree, []byte(src), tree_sitter.Point{Column: 8}, true)
+	if len(refs) != 4 || refs[0].Span.Start.Offset != 5 {
+		t.Errorf("references of a with declaration = %+v", refs)
+	}
+
+	// Parameters are never looked up in the index; globals are.
+	if refs := references.AtIn(fakeIndex{}, "f.wz", tree.Tree, []byte(src), tree_sitter.Point{Column: 8}, false); len(refs) != 3 {
+		t.Errorf("references of a with index = %+v", refs)
+	}
+	refs = references.AtIn(fakeIndex{}, "f.wz", tree.Tree, []byte(src), tree_sitter.Point{Column: 0}, true)
+	if len(refs) != 3 || refs[0].File != "f.wz" || refs[2].File != "other.wz" {
+		t.Errorf("references of f with index = %+v", refs)
+	}
+}
@@ -0,0 +1,288 @@
+// Package regress compares how two versions of the grammar parse a
+// corpus, so that the effect of a grammar upgrade on the tools built on
+// its trees can be assessed before it lands.
+//
+// Either version may be the grammar compiled into these bindings or one
+// loaded from a shared object built by tree-sitter generate and a C
+// compiler. Compare parses each input with both and reports the inputs
+// whose trees differ in the kind, field or extent of any node, locating
+// the first difference.
+package regress
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/grammartest"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+)
+
+// Symbol is the function returning the language in shared objects built
+// from this grammar.
+const Symbol = tree_sitter_wabznasm.LanguageSymbol
+
+// ErrWasm reports a wasm grammar; see tree_sitter_wabznasm.ErrWasm.
+var ErrWasm = tree_sitter_wabznasm.ErrWasm
+
+// Builtin returns the grammar compiled into these bindings, even if
+// tree_sitter_wabznasm.UseLanguage replaced it.
+func Builtin() *tree_sitter.Language {
+	return tree_sitter.NewLanguage(tree_sitter_wabznasm.BuiltinLanguage())
+}
+
+// Load returns the grammar of the shared object at path, whose language
+// function is symbol, or Symbol if it is empty, as
+// tree_sitter_wabznasm.LoadLanguageSymbol loads it.
+func Load(path, symbol string) (*tree_sitter.Language, error) {
+	return tree_sitter_wabznasm.LoadLanguageSymbol(path, symbol)
+}
+
+// Input is a source to parse, named for reports.
+type Input struct {
+	Name   string
+	Source []byte
+}
+
+// Corpus returns the inputs among paths: wabznasm sources, and the cases
+// of tree-sitter corpus files, those ending in .txt. Directories are
+// searched recursively, skipping hidden ones; each case is named by its
+// file, line and name.
+func Corpus(paths []string) ([]Input, error) {
+	var files []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+		var found []string
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && len(d.Name()) > 1 && d.Name()[0] == '.' {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if project.IsSource(path) || filepath.Ext(path) == ".txt" {
+				found = append(found, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+
+	var inputs []Input
+	for _, path := range files {
+		if filepath.Ext(path) == ".txt" {
+			cases, err := grammartest.ReadCorpusFile(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, c := range cases {
+				inputs = append(inputs, Input{Name: fmt.Sprintf("%s:%d: %s", path, c.Line, c.Name), Source: []byte(c.Source)})
+			}
+			continue
+		}
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		inputs = append(inputs, Input{Name: path, Source: src})
+	}
+	return inputs, nil
+}
+
+// Node describes a node of a tree.
+type Node struct {
+	Kind string
+	// Field is the field of the node in its parent, if any.
+	Field      string
+	Missing    bool
+	Start, End tree_sitter.Point
+	StartByte  uint
+	EndByte    uint
+}
+
+func (n *Node) String() string {
+	if n == nil {
+		return "nothing"
+	}
+	kind := n.Kind
+	if n.Missing {
+		kind = "MISSING " + kind
+	}
+	if n.Field != "" {
+		kind = n.Field + ": " + kind
+	}
+	return fmt.Sprintf("%s at %d:%d-%d:%d", kind, n.Start.Row+1, n.Start.Column+1, n.End.Row+1, n.End.Column+1)
+}
+
+func describe(n *tree_sitter.Node, field string) *Node {
+	return &Node{
+		Kind:      n.Kind(),
+		Field:     field,
+		Missing:   n.IsMissing(),
+		Start:     n.StartPosition(),
+		End:       n.EndPosition(),
+		StartByte: n.StartByte(),
+		EndByte:   n.EndByte(),
+	}
+}
+
+// Divergence is the first difference between two trees: the deepest
+// pair of nodes in the same place that differ, or a node one tree has
+// and the other has not.
+type Divergence struct {
+	// Path is the kinds of the ancestors of the nodes, from the root.
+	Path []string
+	// Old and New are the nodes; one is nil if the other tree has no node
+	// in its place.
+	Old, New *Node
+	// Reason is kind, field, missing, range or children.
+	Reason string
+}
+
+func (d *Divergence) String() string {
+	where := strings.Join(d.Path, " > ")
+	if where == "" {
+		where = "root"
+	}
+	return fmt.Sprintf("%s differs in %s: %s, now %s", where, d.Reason, d.Old, d.New)
+}
+
+// Diverge returns the first difference between the trees rooted at old
+// and new, or nil if they are the same.
+func Diverge(old, new *tree_sitter.Node) *Divergence {
+	return diverge(nil, old, new, "", "")
+}
+
+func diverge(path []string, a, b *tree_sitter.Node, aField, bField string) *Divergence {
+	differ := func(reason string) *Divergence {
+		return &Divergence{Path: append([]string(nil), path...), Old: describe(a, aField), New: describe(b, bField), Reason: reason}
+	}
+	switch {
+	case a.Kind() != b.Kind() || a.IsNamed() != b.IsNamed():
+		return differ("kind")
+	case aField != bField:
+		return differ("field")
+	case a.IsMissing() != b.IsMissing():
+		return differ("missing")
+	}
+	inner := append(path[:len(path):len(path)], a.Kind())
+	na, nb := a.ChildCount(), b.ChildCount()
+	for i := range min(na, nb) {
+		d := diverge(inner, a.Child(i), b.Child(i), a.FieldNameForChild(uint32(i)), b.FieldNameForChild(uint32(i)))
+		if d != nil {
+			return d
+		}
+	}
+	if na != nb {
+		d := &Divergence{Path: inner, Reason: "children"}
+		if na > nb {
+			d.Old = describe(a.Child(nb), a.FieldNameForChild(uint32(nb)))
+		} else {
+			d.New = describe(b.Child(na), b.FieldNameForChild(uint32(na)))
+		}
+		return d
+	}
+	if a.StartByte() != b.StartByte() || a.EndByte() != b.EndByte() {
+		return differ("range")
+	}
+	return nil
+}
+
+// Result is the outcome of parsing an input with both grammars.
+type Result struct {
+	Input
+	// OldError and NewError report trees containing syntax errors.
+	OldError, NewError bool
+	// Divergence is where the trees first differ, or nil.
+	Divergence *Divergence
+	// OldTree and NewTree are the S-expressions of trees that differ.
+	OldTree, NewTree string
+}
+
+// Changed reports whether the two trees differ.
+func (r *Result) Changed() bool { return r.Divergence != nil }
+
+// Compare parses each of inputs with the old and the new grammar and
+// returns the outcome for each, stopping with ctx.Err() if ctx is done
+// first.
+func Compare(ctx context.Context, old, new *tree_sitter.Language, inputs []Input) ([]Result, error) {
+	parsers := make([]*tree_sitter.Parser, 2)
+	for i, lang := range []*tree_sitter.Language{old, new} {
+		p := tree_sitter.NewParser()
+		defer p.Close()
+		if err := p.SetLanguage(lang); err != nil {
+			return nil, err
+		}
+		parsers[i] = p
+	}
+	results := make([]Result, 0, len(inputs))
+	for _, in := range inputs {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+		a := parsers[0].Parse(in.Source, nil)
+		b := parsers[1].Parse(in.Source, nil)
+		if a == nil || b == nil {
+			return results, fmt.Errorf("%s: parse failed", in.Name)
+		}
+		ra, rb := a.RootNode(), b.RootNode()
+		r := Result{Input: in, OldError: ra.HasError(), NewError: rb.HasError(), Divergence: Diverge(ra, rb)}
+		if r.Divergence != nil {
+			r.OldTree, r.NewTree = ra.ToSexp(), rb.ToSexp()
+		}
+		a.Close()
+		b.Close()
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// Summary counts the outcomes of a comparison.
+type Summary struct {
+	Inputs, Changed int
+	// Broken counts the inputs that parsed cleanly with the old grammar
+	// and have errors with the new, and Fixed the reverse.
+	Broken, Fixed int
+}
+
+// Summarize counts results.
+func Summarize(results []Result) Summary {
+	s := Summary{Inputs: len(results)}
+	for _, r := range results {
+		if r.Changed() {
+			s.Changed++
+		}
+		switch {
+		case !r.OldError && r.NewError:
+			s.Broken++
+		case r.OldError && !r.NewError:
+			s.Fixed++
+		}
+	}
+	return s
+}
+
+// Diff returns a line diff of the pretty-printed trees of r.
+func (r *Result) Diff() string {
+	return grammartest.Diff(grammartest.Pretty(r.OldTree), grammartest.Pretty(r.NewTree))
+}
@@ -0,0 +1,141 @@
+package regress_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/regress"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func parse(t *testing.T, src string) *tree_sitter.Tree {
+	t.Helper()
+	p := tree_sitter.NewParser()
+	defer p.Close()
+	if err := p.SetLanguage(regress.Builtin()); err != nil {
+		t.Fatal(err)
+	}
+	tree := p.Parse([]byte(src), nil)
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+func TestDiverge(t *testing.T) {
+	tests := []struct {
+		old, new string
+		reason   string
+		path     string
+	}{
+		{"x+1", "x+1", "", ""},
+		{"x+1", "x+12", "range", "source_file > statement > expression > additive > multiplicative > unary > power > postfix > primary"},
+		{"x+1", "x*1", "kind", "source_file > statement > expression > additive"},
+		{"f[]", "f[1]", "kind", "source_file > statement > expression > additive > multiplicative > unary > power > postfix > primary > function_call"},
+	}
+	for _, tt := range tests {
+		d := regress.Diverge(parse(t, tt.old).RootNode(), parse(t, tt.new).RootNode())
+		if tt.reason == "" {
+			if d != nil {
+				t.Errorf("%q, %q: %v", tt.old, tt.new, d)
+			}
+			continue
+		}
+		if d == nil {
+			t.Errorf("%q, %q: no divergence", tt.old, tt.new)
+			continue
+		}
+		if d.Reason != tt.reason || strings.Join(d.Path, " > ") != tt.path {
+			t.Errorf("%q, %q: %v", tt.old, tt.new, d)
+		}
+	}
+}
+
+// build compiles the grammar's parser into a shared object.
+func build(t *testing.T) string {
+	t.Helper()
+	cc, err := exec.LookPath("cc")
+	if err != nil {
+		t.Skip("no C compiler")
+	}
+	lib := filepath.Join(t.TempDir(), "calc.so")
+	out, err := exec.Command(cc, "-shared", "-fPIC", "-O0", "-I../../../src", "-o", lib, "../../../src/parser.c").CombinedOutput()
+	if err != nil {
+		t.Skipf("cannot build the parser: %v\n%s", err, out)
+	}
+	return lib
+}
+
+func TestLoadAndCompare(t *testing.T) {
+	lib := build(t)
+	lang, err := regress.Load(lib, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs, err := regress.Corpus([]string{"../../../test/corpus"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) == 0 {
+		t.Fatal("empty corpus")
+	}
+	inputs = append(inputs, regress.Input{Name: "broken", Source: []byte("1+")})
+	results, err := regress.Compare(context.Background(), regress.Builtin(), lang, inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := regress.Summarize(results)
+	if s.Inputs != len(inputs) || s.Changed != 0 || s.Broken != 0 || s.Fixed != 0 {
+		t.Errorf("summary %+v, want no changes", s)
+	}
+	if last := results[len(results)-1]; !last.OldError || !last.NewError {
+		t.Errorf("broken input %+v", last)
+	}
+
+	if _, err := regress.Load(lib, "tree_sitter_nonesuch"); err == nil {
+		t.Error("no error for a missing symbol")
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	if _, err := regress.Load("grammar.wasm", ""); !errors.Is(err, regress.ErrWasm) {
+		t.Errorf("wasm: %v", err)
+	}
+	if _, err := regress.Load(filepath.Join(t.TempDir(), "none.so"), ""); err == nil {
+		t.Error("no error for a missing object")
+	}
+}
+
+func TestCorpus(t *testing.T) {
+	dir := t.TempDir()
+	corpus := "==========\nSum\n==========\n\n1+2\n\n---\n\n(source_file)\n"
+	for name, src := range map[string]string{"a.wz": "x: 1\n", "corpus/sums.txt": corpus, "notes.md": "# no\n"} {
+		path := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	inputs, err := regress.Corpus([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 2 || inputs[0].Name != filepath.Join(dir, "a.wz") || !strings.HasSuffix(inputs[1].Name, "sums.txt:1: Sum") || string(inputs[1].Source) != "1+2" {
+		t.Errorf("inputs %q", inputs)
+	}
+}
+
+func TestResultDiff(t *testing.T) {
+	r := regress.Result{OldTree: "(a (b))", NewTree: "(a (c))"}
+	if d := r.Diff(); !strings.Contains(d, "-") || !strings.Contains(d, "+") {
+		t.Errorf("diff %q", d)
+	}
+}
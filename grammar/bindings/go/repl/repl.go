@@ -0,0 +1,430 @@
+// Package repl implements the interactive read-eval-print loop used by the
+// wabznasm command.
+package repl
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/depgraph"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/script"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/replay"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/table"
+)
+
+// Prompts printed before each line of input.
+const (
+	Prompt             = "wabz> "
+	ContinuationPrompt = "  ... "
+)
+
+// Config controls a REPL session.
+type Config struct {
+	In  io.Reader
+	Out io.Writer
+	// HistoryFile, if set, is loaded at start and appended to as entries are
+	// read.
+	HistoryFile string
+	// SExpr prints the parse tree of each entry instead of evaluating it.
+	SExpr bool
+	// Quiet suppresses the banner and prompts, for piped input.
+	Quiet bool
+	// ParseTimeoutMicros, if non-zero, bounds the time spent parsing each
+	// entry; an entry that takes longer is reported as a parse error.
+	ParseTimeoutMicros uint64
+	// Recorder, if set, records the session: the entries evaluated, the
+	// tables loaded and the results of the registered builtins.
+	Recorder *replay.Recorder
+}
+
+// Session is a REPL session: an interpreter, its history and output mode.
+type Session struct {
+	cfg     Config
+	interp  *eval.Interpreter
+	parser  *tree_sitter_wabznasm.Parser
+	history []string
+	hist    *os.File
+}
+
+// NewSession prepares a session. The caller must call Close.
+func NewSession(cfg Config) (*Session, error) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return nil, err
+	}
+	parser.SetTimeoutMicros(cfg.ParseTimeoutMicros)
+	bs := builtins()
+	if cfg.Recorder != nil {
+		bs = append(Builtins(), cfg.Recorder.Wrap(eval.Builtins()...)...)
+	}
+	s := &Session{cfg: cfg, interp: eval.NewWith(bs...), parser: parser}
+	if cfg.HistoryFile != "" {
+		if data, err := os.ReadFile(cfg.HistoryFile); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				if line != "" {
+					s.history = append(s.history, unescapeHistory(line))
+				}
+			}
+		}
+		s.hist, err = os.OpenFile(cfg.HistoryFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			parser.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// builtins are those of a session: the table builtins and peach, and the
+// registered ones, which take precedence.
+func builtins() []*eval.Builtin {
+	return append(Builtins(), eval.Builtins()...)
+}
+
+// Builtins returns the builtins a session offers besides the registered
+// ones: the table builtins and peach. A replay of a recorded session
+// offers them with the replayed builtins.
+func Builtins() []*eval.Builtin {
+	return append(table.Builtins(), eval.Peach)
+}
+
+// Close releases the parser and history file.
+func (s *Session) Close() error {
+	s.parser.Close()
+	if s.hist != nil {
+		return s.hist.Close()
+	}
+	return nil
+}
+
+// History returns the entries read so far, including loaded history.
+func (s *Session) History() []string { return s.history }
+
+// Interpreter returns the session's interpreter.
+func (s *Session) Interpreter() *eval.Interpreter { return s.interp }
+
+// Run reads entries until end of input or an exit command.
+func (s *Session) Run() error {
+	out := s.cfg.Out
+	if !s.cfg.Quiet {
+		fmt.Fprintln(out, "wabznasm REPL: enter expressions, assignments, or function definitions. Type 'exit' to quit")
+		fmt.Fprintln(out, "Examples: 1+2, f: {x+1}, add: {[x;y] x+y}, f[5], add[2;3]")
+	}
+	in := bufio.NewScanner(s.cfg.In)
+	var pending []string
+	for {
+		if !s.cfg.Quiet {
+			if len(pending) == 0 {
+				fmt.Fprint(out, Prompt)
+			} else {
+				fmt.Fprint(out, ContinuationPrompt)
+			}
+		}
+		if !in.Scan() {
+			if !s.cfg.Quiet {
+				fmt.Fprintln(out)
+			}
+			return in.Err()
+		}
+		pending = append(pending, in.Text())
+		entry := strings.Join(pending, "\n")
+		if NeedsContinuation(entry) {
+			continue
+		}
+		pending = nil
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if err := s.record(entry); err != nil {
+			return err
+		}
+		if done := s.Eval(entry); done {
+			return nil
+		}
+	}
+}
+
+// Eval handles one complete entry, printing its result. It reports whether
+// the entry asked to end the session.
+func (s *Session) Eval(entry string) (exit bool) {
+	out := s.cfg.Out
+	if args, ok := strings.CutPrefix(entry, ":load"); ok && (args == "" || args[0] == ' ' || args[0] == '\t') {
+		s.load(strings.TrimSpace(args))
+		return false
+	}
+	switch strings.ToLower(entry) {
+	case "exit", "quit", `\\`:
+		return true
+	case ":history":
+		for i, h := range s.history {
+			fmt.Fprintf(out, "%4d  %s\n", i+1, strings.ReplaceAll(h, "\n", "\n      "))
+		}
+		return false
+	case ":sexp":
+		s.cfg.SExpr = !s.cfg.SExpr
+		fmt.Fprintf(out, "s-expression mode %s\n", onOff(s.cfg.SExpr))
+		return false
+	case ":env":
+		for _, name := range s.interp.Globals.Names() {
+			v, _ := s.interp.Globals.Lookup(name)
+			fmt.Fprintf(out, "%s: %s\n", name, v)
+		}
+		return false
+	}
+
+	tree, err := s.parser.ParseString(entry)
+	if err != nil {
+		fmt.Fprintf(out, "Parse error: %v\n", err)
+		return false
+	}
+	defer tree.Close()
+	if s.cfg.SExpr {
+		fmt.Fprintln(out, tree.RootNode().ToSexp())
+		return false
+	}
+	v, err := s.interp.EvalTree(tree.Tree, []byte(entry))
+	if s.cfg.Recorder != nil {
+		s.cfg.Recorder.Record(entry, v, err)
+	}
+	switch {
+	case err != nil:
+		printError(out, entry, err)
+	case v != nil:
+		fmt.Fprintf(out, "= %s\n", v)
+	}
+	return false
+}
+
+// load handles :load name file, binding name to the table in the CSV
+// file, or tab-separated file if it ends in .tsv.
+func (s *Session) load(args string) {
+	out := s.cfg.Out
+	name, path, _ := strings.Cut(args, " ")
+	path = strings.TrimSpace(path)
+	if name == "" || path == "" {
+		fmt.Fprintln(out, "usage: :load name file.csv")
+		return
+	}
+	var opts table.Options
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		opts.Comma = '\t'
+	}
+	t, err := table.LoadCSV(path, opts)
+	var bound []string
+	if err == nil {
+		bound, err = table.Bind(s.interp.Globals, name, t)
+	}
+	if rec := s.cfg.Recorder; err == nil && rec != nil {
+		for _, n := range append([]string{name}, bound...) {
+			v, _ := s.interp.Globals.Lookup(n)
+			if err = rec.Define(s.interp.Globals, n, v); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(out, "Error: %v\n", err)
+		return
+	}
+	fmt.Fprintf(out, "%s: %d rows of %s\n", name, t.Len(), strings.Join(t.Names(), ", "))
+}
+
+func (s *Session) record(entry string) error {
+	if n := len(s.history); n > 0 && s.history[n-1] == entry {
+		return nil
+	}
+	s.history = append(s.history, entry)
+	if s.hist == nil {
+		return nil
+	}
+	_, err := fmt.Fprintln(s.hist, escapeHistory(entry))
+	return err
+}
+
+// printError prints err, with a caret line under the offending source when
+// the error carries a location.
+func printError(w io.Writer, src string, err error) {
+	var e *eval.Error
+	if !errors.As(err, &e) || e.Span == (ast.Span{}) && e.Code != eval.CodeSyntax {
+		fmt.Fprintf(w, "Error: %v\n", err)
+		return
+	}
+	label := "Error"
+	if e.Code == eval.CodeSyntax {
+		label = "Parse error"
+	}
+	fmt.Fprintf(w, "%s: %v\n", label, e)
+
+	lines := strings.Split(src, "\n")
+	row := int(e.Span.Start.Row)
+	if row >= len(lines) {
+		return
+	}
+	line := lines[row]
+	col := min(int(e.Span.Start.Column), len(line))
+	width := 1
+	if e.Span.End.Row == e.Span.Start.Row && e.Span.End.Column > e.Span.Start.Column {
+		width = int(e.Span.End.Column - e.Span.Start.Column)
+	}
+	fmt.Fprintf(w, "  %s\n  %s%s\n", line, caretIndent(line[:col]), strings.Repeat("^", width))
+}
+
+// caretIndent reproduces tabs in prefix so carets line up under tabbed
+// source.
+func caretIndent(prefix string) string {
+	var b strings.Builder
+	for _, r := range prefix {
+		if r == '\t' {
+			b.WriteByte('\t')
+		} else {
+			b.WriteByte(' ')
+		}
+	}
+	return b.String()
+}
+
+// Entry is script.Entry.
+type Entry = script.Entry
+
+// Split is script.Split.
+func Split(code string) []Entry { return script.Split(code) }
+
+// Statement is a statement of a script and the outcome of evaluating it.
+type Statement struct {
+	Entry
+	// Stmt is the statement, or nil if the entry has a syntax error.
+	Stmt  ast.Stmt
+	Value eval.Value
+	Err   error
+}
+
+// RunScript evaluates the statements of script in turn, as Split divides
+// it, and passes the outcome of each to report until report returns
+// false. Positions in the statements and in errors are positions in
+// script. The error is that of a parse that could not be completed.
+func RunScript(ctx context.Context, in *eval.Interpreter, script []byte, report func(*Statement) bool) error {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return err
+	}
+	defer parser.Close()
+	for _, e := range Split(string(script)) {
+		text := e.Isolate(script)
+		tree, err := parser.ParseBytes(text)
+		if err != nil {
+			return err
+		}
+		st := &Statement{Entry: e}
+		if tree.RootNode().HasError() {
+			st.Err = eval.SyntaxError(tree.Tree, text)
+		} else {
+			f := ast.FromTree(tree.Tree, text)
+			st.Stmt = f.Stmt
+			st.Value, st.Err = in.EvalFileContext(ctx, f, text)
+		}
+		tree.Close()
+		if !report(st) {
+			break
+		}
+	}
+	return nil
+}
+
+// RunScriptParallel evaluates the statements of script as RunScript does,
+// but evaluates statements independent of each other at once, on up to
+// in.Workers goroutines: each stage of depgraph.Schedule is evaluated with
+// in.EvalParallel. Report is called in script order once every statement
+// has been evaluated, so statements after one for which it returns false
+// have still been evaluated.
+func RunScriptParallel(ctx context.Context, in *eval.Interpreter, script []byte, report func(*Statement) bool) error {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		return err
+	}
+	defer parser.Close()
+	entries := Split(string(script))
+	stmts := make([]*Statement, len(entries))
+	files := make([]*ast.File, len(entries))
+	sources := make([][]byte, len(entries))
+	tables := make([]*scopes.Table, len(entries))
+	for i, e := range entries {
+		sources[i] = e.Isolate(script)
+		tree, err := parser.ParseBytes(sources[i])
+		if err != nil {
+			return err
+		}
+		stmts[i] = &Statement{Entry: e}
+		files[i] = &ast.File{}
+		if tree.RootNode().HasError() {
+			stmts[i].Err = eval.SyntaxError(tree.Tree, sources[i])
+		} else {
+			files[i] = ast.FromTree(tree.Tree, sources[i])
+			stmts[i].Stmt = files[i].Stmt
+		}
+		tables[i] = scopes.Build(files[i])
+		tree.Close()
+	}
+	for _, stage := range depgraph.Schedule(tables) {
+		var fs []*ast.File
+		var srcs [][]byte
+		for _, i := range stage {
+			fs = append(fs, files[i])
+			srcs = append(srcs, sources[i])
+		}
+		values, errs := in.EvalParallel(ctx, fs, srcs)
+		for k, i := range stage {
+			if stmts[i].Err == nil {
+				stmts[i].Value, stmts[i].Err = values[k], errs[k]
+			}
+		}
+	}
+	for _, st := range stmts {
+		if !report(st) {
+			break
+		}
+	}
+	return nil
+}
+
+// NeedsContinuation is script.NeedsContinuation.
+func NeedsContinuation(src string) bool { return script.NeedsContinuation(src) }
+
+// History entries may span lines; store them one per line.
+func escapeHistory(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(s)
+}
+
+func unescapeHistory(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			if s[i] == 'n' {
+				b.WriteByte('\n')
+			} else {
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
@@ -0,0 +1,193 @@
+package repl_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/replay"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func run(t *testing.T, cfg repl.Config, input string) (string, *repl.Session) {
+	t.Helper()
+	var out strings.Builder
+	cfg.In = strings.NewReader(input)
+	cfg.Out = &out
+	cfg.Quiet = true
+	s, err := repl.NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	if err := s.Run(); err != nil {
+		t.Fatal(err)
+	}
+	return out.String(), s
+}
+
+func TestEvaluate(t *testing.T) {
+	out, _ := run(t, repl.Config{}, "1+2\nadd: {[x;y] x+y}\nadd[2;3]\n")
+	want := "= 3\n= {[x;y] x+y}\n= 5\n"
+	if out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestContinuation(t *testing.T) {
+	out, s := run(t, repl.Config{}, "f: {[x;y]\n  x*y\n}\nf[6;7]\n")
+	if !strings.HasSuffix(out, "= 42\n") {
+		t.Errorf("output = %q", out)
+	}
+	if h := s.History(); len(h) != 2 || h[0] != "f: {[x;y]\n  x*y\n}" {
+		t.Errorf("history = %q", h)
+	}
+}
+
+func TestNeedsContinuation(t *testing.T) {
+	tests := map[string]bool{
+		"1+2":         false,
+		"f: {x":       true,
+		"f[1;":        true,
+		"(1+2)":       false,
+		`f: { \ }`:    true,
+		"f: {x\n}":    false,
+		"1)":          false,
+		"{[x;y] (x+y": true,
+	}
+	for src, want := range tests {
+		if got := repl.NeedsContinuation(src); got != want {
+			t.Errorf("NeedsContinuation(%q) = %v, want %v", src, got, want)
+		}
+	}
+}
+
+func TestSplit(t *testing.T) {
+	got := repl.Split("a: 1\n\n\\ note\nf: {[x]\n  x*2\n}\r\nf[a]")
+	want := []repl.Entry{
+		{Text: "a: 1", Offset: 0},
+		{Text: "f: {[x]\n  x*2\n}", Offset: 13},
+		{Text: "f[a]", Offset: 30},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Split = %q, want %q", got, want)
+	}
+}
+
+func TestRunScript(t *testing.T) {
+	script := []byte("a: 2\nf: {[x]\n  x*a\n}\nf[3]\n  f[1/0]\nf[4]\n")
+	var got []string
+	err := repl.RunScript(context.Background(), eval.New(), script, func(st *repl.Statement) bool {
+		got = append(got, fmt.Sprintf("%d %v %v", st.Offset, st.Value, st.Err))
+		return st.Err == nil
+	})
+	want := []string{"0 2 <nil>", "5 {[x]\n  x*a\n} <nil>", "21 6 <nil>", "26 <nil> 6:5: division by zero"}
+	if err != nil || !slices.Equal(got, want) {
+		t.Errorf("RunScript = %q, %v; want %q", got, err, want)
+	}
+}
+
+func TestRunScriptParallel(t *testing.T) {
+	script := []byte("a: 2\nf: {[x]\n  x*a\n}\nsq: {x*x}\nf[3]\na: 10\nb: peach[sq; a]\nf[1/0]\nf[4]\n(\n")
+	run := func(runScript func(context.Context, *eval.Interpreter, []byte, func(*repl.Statement) bool) error, workers int) []string {
+		in := eval.NewWith(eval.Peach)
+		in.Workers = workers
+		var got []string
+		err := runScript(context.Background(), in, script, func(st *repl.Statement) bool {
+			got = append(got, fmt.Sprintf("%d %v %v", st.Offset, st.Value, st.Err))
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+	want := run(repl.RunScript, 1)
+	for _, workers := range []int{1, 4} {
+		if got := run(repl.RunScriptParallel, workers); !slices.Equal(got, want) {
+			t.Errorf("workers %d: RunScriptParallel = %q, want %q", workers, got, want)
+		}
+	}
+}
+
+func TestErrorCarets(t *testing.T) {
+	out, _ := run(t, repl.Config{}, "1+(4/0)\n")
+	want := "Error: 1:4: division by zero\n  1+(4/0)\n     ^^^\n"
+	if out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	out, _ = run(t, repl.Config{}, "1+*2\n")
+	if !strings.HasPrefix(out, "Parse error: ") || !strings.Contains(out, "  1+*2\n") || !strings.Contains(out, "^") {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestSExprMode(t *testing.T) {
+	out, _ := run(t, repl.Config{SExpr: true}, "1\n")
+	if !strings.HasPrefix(out, "(source_file") || !strings.Contains(out, "(number)") {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestExit(t *testing.T) {
+	out, _ := run(t, repl.Config{}, "1\nexit\n2\n")
+	if out != "= 1\n" {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	run(t, repl.Config{HistoryFile: path}, "a: 1\nf: {x+\n1}\nf: {x+\n1}\n")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a: 1\nf: {x+\\n1}\n"; string(data) != want {
+		t.Errorf("history file = %q, want %q", data, want)
+	}
+	_, s := run(t, repl.Config{HistoryFile: path}, "")
+	if h := s.History(); len(h) != 2 || h[1] != "f: {x+\n1}" {
+		t.Errorf("loaded history = %q", h)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.csv")
+	if err := os.WriteFile(path, []byte("sym,qty,price\na,10,1.5\nb,200,2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, _ := run(t, repl.Config{}, ":load t "+path+"\nsum[where[t[qty]; gt[t[price]; 1]]]\n:load t\n:load t missing.csv\n")
+	lines := strings.Split(out, "\n")
+	if len(lines) != 5 || lines[0] != "t: 2 rows of sym, qty, price" || lines[1] != "= 210" ||
+		lines[2] != "usage: :load name file.csv" || !strings.HasPrefix(lines[3], "Error: ") {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trades.csv")
+	if err := os.WriteFile(path, []byte("sym,qty,price\na,10,1.5\nb,200,2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rec := replay.NewRecorder(1)
+	run(t, repl.Config{Recorder: rec}, ":load t "+path+"\nn: sum[t[qty]]\nn%0\n:env\nn*2\n")
+	// The replay needs neither the file nor the session's history.
+	os.Remove(path)
+	rp := replay.NewReplayer(rec.Recording())
+	steps, err := rp.Run(context.Background(), eval.NewWith(append(repl.Builtins(), rp.Builtins()...)...))
+	if err != nil || len(steps) != 3 || steps[2].Value.String() != "420" {
+		t.Errorf("Run = %+v, %v", steps, err)
+	}
+}
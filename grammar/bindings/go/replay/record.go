@@ -0,0 +1,200 @@
+// Package replay records evaluation sessions and replays them
+// deterministically, so that a user reporting a bug in the evaluator can
+// attach a session that reproduces it wherever it is replayed.
+//
+// A Recorder captures what a session depends on beyond its sources: the
+// values the host defines, the results of the builtins it wraps, and the
+// seed of the random numbers it hands out. Hosts wrap the builtins whose
+// results vary between runs, such as those reading clocks, files or the
+// network. A Replayer evaluates the recorded statements again, answering
+// each call of a wrapped builtin with its recorded result, and reports
+// where the outcome departs from the recording.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"slices"
+	"sync"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// Version is the version of the recording format.
+const Version = 1
+
+// Recording is a recorded session, encoded as JSON by Write.
+type Recording struct {
+	Version int   `json:"version"`
+	Seed    int64 `json:"seed"`
+	// Builtins are the wrapped builtins, whose results were recorded.
+	Builtins []Builtin `json:"builtins,omitempty"`
+	Events   []Event   `json:"events"`
+}
+
+// Builtin describes a wrapped builtin.
+type Builtin struct {
+	Name   string   `json:"name"`
+	Params []string `json:"params"`
+}
+
+// Event is a step of a session; exactly one of Define, Eval and Call is
+// set.
+type Event struct {
+	// Define is a global the host bound to Value.
+	Define string `json:"define,omitempty"`
+	// Eval is the source of a statement evaluated, with Text holding the
+	// text of its value, or Error its error.
+	Eval string `json:"eval,omitempty"`
+	Text string `json:"text,omitempty"`
+	// Call is the name of a wrapped builtin applied to Args, returning
+	// Value or Error.
+	Call string            `json:"call,omitempty"`
+	Args []json.RawMessage `json:"args,omitempty"`
+
+	Value json.RawMessage `json:"value,omitempty"`
+	Error *Error          `json:"error,omitempty"`
+}
+
+// Error is a recorded error.
+type Error struct {
+	Code    string `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+func recordError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	var e *eval.Error
+	if errors.As(err, &e) {
+		return &Error{Code: e.Code, Message: err.Error()}
+	}
+	return &Error{Message: err.Error()}
+}
+
+// Read decodes a recording written by Write.
+func Read(r io.Reader) (*Recording, error) {
+	var rec Recording
+	if err := json.NewDecoder(r).Decode(&rec); err != nil {
+		return nil, fmt.Errorf("replay: %w", err)
+	}
+	if rec.Version != Version {
+		return nil, fmt.Errorf("replay: unsupported recording version %d", rec.Version)
+	}
+	return &rec, nil
+}
+
+// Write encodes rec as indented JSON.
+func (rec *Recording) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rec)
+}
+
+// Recorder records a session. Its methods are safe for concurrent use, so
+// that wrapped builtins may be called by peach.
+type Recorder struct {
+	mu   sync.Mutex
+	rec  Recording
+	rand *rand.Rand
+}
+
+// NewRecorder returns a recorder whose random numbers are drawn from
+// seed.
+func NewRecorder(seed int64) *Recorder {
+	return &Recorder{rec: Recording{Version: Version, Seed: seed}, rand: rand.New(rand.NewSource(seed))}
+}
+
+// Rand returns the random numbers of the session, for builtins that are
+// not wrapped. A replay draws the same numbers as long as they are drawn
+// in the same order, so builtins using it should not be called by peach;
+// like any *rand.Rand from rand.New, it is not safe for concurrent use.
+func (r *Recorder) Rand() *rand.Rand { return r.rand }
+
+// Recording returns a copy of the session recorded so far.
+func (r *Recorder) Recording() *Recording {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rec := r.rec
+	rec.Builtins = slices.Clone(rec.Builtins)
+	rec.Events = slices.Clone(rec.Events)
+	return &rec
+}
+
+func (r *Recorder) add(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.rec.Events = append(r.rec.Events, e)
+}
+
+// Wrap returns builtins behaving as bs, whose calls are recorded.
+func (r *Recorder) Wrap(bs ...*eval.Builtin) []*eval.Builtin {
+	out := make([]*eval.Builtin, len(bs))
+	for i, b := range bs {
+		r.mu.Lock()
+		r.rec.Builtins = append(r.rec.Builtins, Builtin{Name: b.Name, Params: b.Params})
+		r.mu.Unlock()
+		out[i] = &eval.Builtin{Name: b.Name, Params: b.Params, Fn: func(args []eval.Value) (eval.Value, error) {
+			v, callErr := b.Call(args)
+			e := Event{Call: b.Name, Error: recordError(callErr)}
+			var err error
+			if e.Args, err = encodeArgs(args); err != nil {
+				return nil, err
+			}
+			if callErr == nil {
+				if e.Value, err = json.Marshal(v); err != nil {
+					return nil, fmt.Errorf("recording result: %w", err)
+				}
+			}
+			r.add(e)
+			return v, callErr
+		}}
+	}
+	return out
+}
+
+func encodeArgs(args []eval.Value) ([]json.RawMessage, error) {
+	out := make([]json.RawMessage, len(args))
+	for i, a := range args {
+		data, err := json.Marshal(a)
+		if err != nil {
+			return nil, fmt.Errorf("recording argument: %w", err)
+		}
+		out[i] = data
+	}
+	return out, nil
+}
+
+// Define binds name to v in env and records it as an input of the
+// session.
+func (r *Recorder) Define(env *eval.Env, name string, v eval.Value) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("replay: recording %s: %w", name, err)
+	}
+	env.Define(name, v)
+	r.add(Event{Define: name, Value: data})
+	return nil
+}
+
+// Eval evaluates src with in and records it with its outcome.
+func (r *Recorder) Eval(ctx context.Context, in *eval.Interpreter, src string) (eval.Value, error) {
+	v, err := in.EvalStringContext(ctx, src)
+	r.Record(src, v, err)
+	return v, err
+}
+
+// Record records the evaluation of src, made by the host, and its
+// outcome.
+func (r *Recorder) Record(src string, v eval.Value, err error) {
+	e := Event{Eval: src, Error: recordError(err)}
+	if err == nil && v != nil {
+		e.Text = v.String()
+	}
+	r.add(e)
+}
@@ -0,0 +1,186 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// DivergenceError reports a replay departing from its recording.
+type DivergenceError struct {
+	// Event is the index of the recorded event at which the replay
+	// departed.
+	Event int
+	// Source is the statement being replayed.
+	Source string
+	// Want and Got describe the recorded and replayed outcomes.
+	Want, Got string
+}
+
+func (e *DivergenceError) Error() string {
+	return fmt.Sprintf("replay: event %d: %s: recorded %s, replayed %s", e.Event, e.Source, e.Want, e.Got)
+}
+
+// Replayer replays a recording.
+type Replayer struct {
+	rec  *Recording
+	rand *rand.Rand
+
+	// env is the environment of the replay in progress, in which recorded
+	// results are decoded.
+	env *eval.Env
+
+	mu sync.Mutex
+	// calls holds the recorded calls of each wrapped builtin not yet
+	// answered.
+	calls map[string][]int
+	// missed is the first builtin called without a recorded result.
+	missed string
+}
+
+// NewReplayer returns a replayer of rec.
+func NewReplayer(rec *Recording) *Replayer {
+	rp := &Replayer{rec: rec, rand: rand.New(rand.NewSource(rec.Seed)), calls: map[string][]int{}}
+	for i, e := range rec.Events {
+		if e.Call != "" {
+			rp.calls[e.Call] = append(rp.calls[e.Call], i)
+		}
+	}
+	return rp
+}
+
+// Rand returns the random numbers of the session, as Recorder.Rand.
+func (rp *Replayer) Rand() *rand.Rand { return rp.rand }
+
+// Builtins returns builtins standing in for the wrapped builtins of the
+// recording, answering each call with its recorded result. A call the
+// session did not record fails, and makes Run report a
+// *DivergenceError. Calls are matched by builtin and arguments rather
+// than by order, since peach applies builtins in no fixed order.
+func (rp *Replayer) Builtins() []*eval.Builtin {
+	var out []*eval.Builtin
+	for _, b := range rp.rec.Builtins {
+		out = append(out, &eval.Builtin{Name: b.Name, Params: b.Params, Fn: func(args []eval.Value) (eval.Value, error) {
+			return rp.answer(b.Name, args)
+		}})
+	}
+	return out
+}
+
+// Step is a replayed statement.
+type Step struct {
+	Source string
+	Value  eval.Value
+	Err    error
+}
+
+// Run binds the recorded inputs in the globals of in and evaluates the
+// recorded statements with it, in order, returning the steps replayed. In
+// should offer the builtins of Builtins, and the builtins of the recorded
+// session that were not wrapped. Run stops with a *DivergenceError at the
+// first statement whose outcome differs from the recording, or that calls
+// a wrapped builtin in a way the session did not.
+func (rp *Replayer) Run(ctx context.Context, in *eval.Interpreter) ([]Step, error) {
+	rp.env = in.Globals
+	defer func() { rp.env = nil }()
+	var steps []Step
+	for i, e := range rp.rec.Events {
+		switch {
+		case e.Define != "":
+			v, err := eval.DecodeJSON(e.Value, in.Globals)
+			if err != nil {
+				return steps, fmt.Errorf("replay: event %d: decoding %s: %w", i, e.Define, err)
+			}
+			in.Globals.Define(e.Define, v)
+		case e.Eval != "":
+			v, err := in.EvalStringContext(ctx, e.Eval)
+			steps = append(steps, Step{Source: e.Eval, Value: v, Err: err})
+			if call := rp.unrecorded(); call != "" {
+				return steps, &DivergenceError{Event: i, Source: e.Eval, Want: "no call", Got: "a call of " + call}
+			}
+			var text string
+			if err == nil && v != nil {
+				text = v.String()
+			}
+			if got, want := outcome(text, recordError(err)), outcome(e.Text, e.Error); got != want {
+				return steps, &DivergenceError{Event: i, Source: e.Eval, Want: want, Got: got}
+			}
+		}
+	}
+	return steps, nil
+}
+
+// unrecorded returns and clears the name of the first builtin called
+// without a recorded result since the last call.
+func (rp *Replayer) unrecorded() string {
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	name := rp.missed
+	rp.missed = ""
+	return name
+}
+
+// answer returns the recorded result of the call of the wrapped builtin
+// name with args, consuming it.
+func (rp *Replayer) answer(name string, args []eval.Value) (eval.Value, error) {
+	encoded, err := encodeArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	rp.mu.Lock()
+	defer rp.mu.Unlock()
+	pending := rp.calls[name]
+	for k, i := range pending {
+		e := rp.rec.Events[i]
+		if !sameArgs(e.Args, encoded) {
+			continue
+		}
+		rp.calls[name] = append(pending[:k:k], pending[k+1:]...)
+		if e.Error != nil {
+			return nil, &eval.Error{Code: e.Error.Code, Message: e.Error.Message}
+		}
+		v, err := eval.DecodeJSON(e.Value, rp.env)
+		if err != nil {
+			return nil, fmt.Errorf("decoding recorded result: %w", err)
+		}
+		return v, nil
+	}
+	if rp.missed == "" {
+		rp.missed = name
+	}
+	return nil, &eval.Error{Code: eval.CodeBuiltin, Message: name + ": call not in the recording"}
+}
+
+// sameArgs reports whether the encoded arguments a and b are the same,
+// ignoring the spacing Write indents recordings with.
+func sameArgs(a, b []json.RawMessage) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	var x, y bytes.Buffer
+	for i := range a {
+		x.Reset()
+		y.Reset()
+		if json.Compact(&x, a[i]) != nil || json.Compact(&y, b[i]) != nil || !bytes.Equal(x.Bytes(), y.Bytes()) {
+			return false
+		}
+	}
+	return true
+}
+
+// outcome describes the result of a statement: the text of its value, or
+// its error.
+func outcome(text string, err *Error) string {
+	switch {
+	case err != nil:
+		return "error " + err.Message
+	case text == "":
+		return "no value"
+	}
+	return text
+}
@@ -0,0 +1,107 @@
+package replay_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/replay"
+)
+
+// record records a session calling tick, whose results change from run
+// to run, and roll, drawing from the session's random numbers.
+func record(t *testing.T) *replay.Recording {
+	t.Helper()
+	var clock atomic.Int64
+	clock.Store(1000)
+	tick := &eval.Builtin{Name: "tick", Params: []string{"step"}, Fn: func(args []eval.Value) (eval.Value, error) {
+		if args[0].(eval.Long) < 0 {
+			return nil, errors.New("clock runs forwards")
+		}
+		return eval.Long(clock.Add(int64(args[0].(eval.Long)))), nil
+	}}
+	r := replay.NewRecorder(42)
+	roll := &eval.Builtin{Name: "roll", Params: []string{"n"}, Fn: func(args []eval.Value) (eval.Value, error) {
+		return eval.Long(r.Rand().Int63n(int64(args[0].(eval.Long)))), nil
+	}}
+	in := eval.NewWith(append(r.Wrap(tick), roll, eval.Peach)...)
+	if err := r.Define(in.Globals, "steps", eval.List{eval.Long(1), eval.Long(2), eval.Long(3)}); err != nil {
+		t.Fatal(err)
+	}
+	for _, src := range []string{"t: tick[5]", "t+roll[6]", "tick[0-1]", "peach[tick; steps]", "f: {[n] n*t}", "f[2]"} {
+		r.Eval(context.Background(), in, src)
+	}
+	return r.Recording()
+}
+
+func TestReplay(t *testing.T) {
+	rec := record(t)
+	var buf bytes.Buffer
+	if err := rec.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	rec, err := replay.Read(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.Builtins) != 1 || rec.Builtins[0].Name != "tick" {
+		t.Errorf("Builtins = %+v", rec.Builtins)
+	}
+
+	rp := replay.NewReplayer(rec)
+	roll := &eval.Builtin{Name: "roll", Params: []string{"n"}, Fn: func(args []eval.Value) (eval.Value, error) {
+		return eval.Long(rp.Rand().Int63n(int64(args[0].(eval.Long)))), nil
+	}}
+	in := eval.NewWith(append(rp.Builtins(), roll, eval.Peach)...)
+	steps, err := rp.Run(context.Background(), in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, st := range steps {
+		if st.Err != nil {
+			got = append(got, st.Err.Error())
+		} else {
+			got = append(got, st.Value.String())
+		}
+	}
+	if len(got) != 6 || got[0] != "1005" || !strings.Contains(got[2], "clock runs forwards") || got[5] != "2010" {
+		t.Errorf("steps = %q", got)
+	}
+}
+
+func TestDivergence(t *testing.T) {
+	rec := record(t)
+	// A recorded outcome the replay does not reproduce.
+	rec.Events[len(rec.Events)-1].Text = "2011"
+	rp := replay.NewReplayer(rec)
+	in := eval.NewWith(append(rp.Builtins(), eval.Peach, &eval.Builtin{Name: "roll", Params: []string{"n"}, Fn: func(args []eval.Value) (eval.Value, error) {
+		return eval.Long(rp.Rand().Int63n(int64(args[0].(eval.Long)))), nil
+	}})...)
+	_, err := rp.Run(context.Background(), in)
+	var de *replay.DivergenceError
+	if !errors.As(err, &de) || de.Source != "f[2]" || de.Want != "2011" || de.Got != "2010" {
+		t.Errorf("err = %v", err)
+	}
+
+	// A call the session did not make.
+	rec = record(t)
+	for i, e := range rec.Events {
+		if e.Eval == "t: tick[5]" {
+			rec.Events[i].Eval = "t: tick[6]"
+		}
+	}
+	rp = replay.NewReplayer(rec)
+	_, err = rp.Run(context.Background(), eval.NewWith(rp.Builtins()...))
+	if !errors.As(err, &de) || de.Source != "t: tick[6]" || de.Got != "a call of tick" {
+		t.Errorf("err = %v", err)
+	}
+
+	if _, err := replay.Read(strings.NewReader(`{"version": 99}`)); err == nil {
+		t.Error("Read accepted an unknown version")
+	}
+}
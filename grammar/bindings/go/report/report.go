@@ -0,0 +1,218 @@
+// Package report renders diagnostics for terminals.
+//
+// Each message is printed with its position, the source line it refers to
+// and a caret underline:
+//
+//	main.wz:1:6: error: unexpected "*"; expected "(", "-", identifier or number
+//	  1 | x: 1+*2
+//	    |      ^
+//
+// Control characters and invalid UTF-8 in the source are escaped so that a
+// hostile file cannot move the cursor or recolor the terminal, and the
+// underline accounts for the escapes. ANSI colors are optional.
+package report
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+)
+
+// Message is one diagnostic to report. Code, when set, is printed after the
+// text, as the rule name of a lint finding is.
+type Message struct {
+	Range    tree_sitter.Range
+	Severity tree_sitter_wabznasm.Severity
+	Code     string
+	Text     string
+}
+
+// FromDiagnostic converts a syntax diagnostic.
+func FromDiagnostic(d tree_sitter_wabznasm.Diagnostic) Message {
+	return Message{Range: d.Range, Severity: d.Severity, Text: d.Message}
+}
+
+// FromFinding converts a lint finding.
+func FromFinding(f lint.Finding) Message {
+	return Message{Range: f.Range, Severity: f.Severity, Code: f.Rule, Text: f.Message}
+}
+
+// TabWidth is the number of columns a tab in the source is expanded to.
+const TabWidth = 4
+
+const (
+	ansiReset = "\x1b[0m"
+	ansiBold  = "\x1b[1m"
+)
+
+var severityColor = map[tree_sitter_wabznasm.Severity]string{
+	tree_sitter_wabznasm.SeverityError:       "\x1b[1;31m",
+	tree_sitter_wabznasm.SeverityWarning:     "\x1b[1;33m",
+	tree_sitter_wabznasm.SeverityInformation: "\x1b[1;34m",
+	tree_sitter_wabznasm.SeverityHint:        "\x1b[1;36m",
+}
+
+// Printer writes messages to an output stream.
+type Printer struct {
+	w     io.Writer
+	color bool
+}
+
+// New returns a printer writing to w, using ANSI colors when color is set.
+func New(w io.Writer, color bool) *Printer {
+	return &Printer{w: w, color: color}
+}
+
+// ColorEnabled reports whether output to f should be colored: f must be a
+// terminal and the NO_COLOR environment variable unset.
+func ColorEnabled(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// Print reports m against source, the contents of file. An empty file name
+// omits the name from the position.
+func (p *Printer) Print(file string, source []byte, m Message) error {
+	var b strings.Builder
+	start := m.Range.StartPoint
+	pos := fmt.Sprintf("%d:%d:", start.Row+1, start.Column+1)
+	if file != "" {
+		pos = Escape(file) + ":" + pos
+	}
+	p.paint(&b, ansiBold, pos)
+	b.WriteByte(' ')
+	p.paint(&b, severityColor[m.Severity], m.Severity.String()+":")
+	b.WriteByte(' ')
+	p.paint(&b, ansiBold, Escape(m.Text))
+	if m.Code != "" {
+		fmt.Fprintf(&b, " (%s)", Escape(m.Code))
+	}
+	b.WriteByte('\n')
+
+	if line, ok := lineAt(source, m.Range.StartByte); ok {
+		col := int(start.Column)
+		width := 1
+		if m.Range.EndPoint.Row == start.Row && m.Range.EndByte > m.Range.StartByte {
+			width = int(m.Range.EndPoint.Column) - col
+		} else if m.Range.EndPoint.Row > start.Row {
+			width = len(line) - col
+		}
+		text, offset, span := excerpt(line, col, max(width, 1))
+		gutter := fmt.Sprintf("%d", start.Row+1)
+		blank := strings.Repeat(" ", len(gutter))
+		fmt.Fprintf(&b, "  %s | %s\n", gutter, text)
+		fmt.Fprintf(&b, "  %s | %s", blank, strings.Repeat(" ", offset))
+		p.paint(&b, severityColor[m.Severity], "^"+strings.Repeat("~", max(span-1, 0)))
+		b.WriteByte('\n')
+	}
+	_, err := io.WriteString(p.w, b.String())
+	return err
+}
+
+// PrintAll reports each message in turn.
+func (p *Printer) PrintAll(file string, source []byte, ms []Message) error {
+	for _, m := range ms {
+		if err := p.Print(file, source, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *Printer) paint(b *strings.Builder, color, s string) {
+	if p.color && color != "" {
+		b.WriteString(color + s + ansiReset)
+		return
+	}
+	b.WriteString(s)
+}
+
+// lineAt returns the line containing offset, without its terminator.
+func lineAt(source []byte, offset uint) ([]byte, bool) {
+	if int(offset) > len(source) {
+		return nil, false
+	}
+	start := offset
+	for start > 0 && source[start-1] != '\n' {
+		start--
+	}
+	end := offset
+	for int(end) < len(source) && source[end] != '\n' {
+		end++
+	}
+	line := source[start:end]
+	if n := len(line); n > 0 && line[n-1] == '\r' {
+		line = line[:n-1]
+	}
+	return line, true
+}
+
+// excerpt escapes line for display and maps the byte span [col, col+width)
+// to display columns, returned as an offset and a width in cells.
+func excerpt(line []byte, col, width int) (string, int, int) {
+	var b strings.Builder
+	offset, end, cells := -1, -1, 0
+	for i := 0; ; {
+		if i >= col && offset < 0 {
+			offset = cells
+		}
+		if i >= col+width && end < 0 {
+			end = cells
+		}
+		if i >= len(line) {
+			break
+		}
+		s, n := escapeRune(line[i:], cells)
+		b.WriteString(s)
+		cells += utf8.RuneCountInString(s)
+		i += n
+	}
+	if offset < 0 {
+		offset = cells
+	}
+	if end < 0 {
+		end = cells
+	}
+	return b.String(), offset, max(end-offset, 1)
+}
+
+// Escape makes s safe to print on a terminal, replacing control characters
+// and invalid UTF-8 with visible escapes. Tabs become spaces.
+func Escape(s string) string {
+	var b strings.Builder
+	cells := 0
+	for i := 0; i < len(s); {
+		e, n := escapeRune([]byte(s[i:]), cells)
+		b.WriteString(e)
+		cells += utf8.RuneCountInString(e)
+		i += n
+	}
+	return b.String()
+}
+
+// escapeRune renders the rune at the start of p, displayed at column cells,
+// and returns the rendering with the number of bytes consumed.
+func escapeRune(p []byte, cells int) (string, int) {
+	r, n := utf8.DecodeRune(p)
+	switch {
+	case r == '\t':
+		return strings.Repeat(" ", TabWidth-cells%TabWidth), n
+	case r == utf8.RuneError && n == 1:
+		return fmt.Sprintf(`\x%02x`, p[0]), n
+	case r < 0x20 || r == 0x7f || (r >= 0x80 && r < 0xa0):
+		return fmt.Sprintf(`\u%04x`, r), n
+	case r == 0x2028 || r == 0x2029 || (r >= 0x202a && r <= 0x202e) || (r >= 0x2066 && r <= 0x2069):
+		// Line separators and bidirectional overrides.
+		return fmt.Sprintf(`\u%04x`, r), n
+	}
+	return string(r), n
+}
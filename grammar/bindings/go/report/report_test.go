@@ -0,0 +1,80 @@
+package report_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/report"
+)
+
+func diagnostics(t *testing.T, src string) []report.Message {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(parser.Close)
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	var out []report.Message
+	for _, d := range tree_sitter_wabznasm.Diagnostics(tree.Tree, []byte(src)) {
+		out = append(out, report.FromDiagnostic(d))
+	}
+	return out
+}
+
+func TestPrint(t *testing.T) {
+	src := "\\ header\nx: 1+*2"
+	var b bytes.Buffer
+	if err := report.New(&b, false).PrintAll("main.wz", []byte(src), diagnostics(t, src)); err != nil {
+		t.Fatal(err)
+	}
+	want := `main.wz:2:6: error: unexpected "*"; expected "(", "-", identifier or number
+  2 | x: 1+*2
+    |      ^
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestPrintEscapes(t *testing.T) {
+	// The span covers "bad" after a tab and an escape sequence.
+	src := "\t\x1b[2Jbad \xff"
+	m := report.Message{
+		Range: tree_sitter.Range{
+			StartByte: 5, EndByte: 8,
+			StartPoint: tree_sitter.Point{Column: 5}, EndPoint: tree_sitter.Point{Column: 8},
+		},
+		Severity: tree_sitter_wabznasm.SeverityWarning,
+		Code:     "rule",
+		Text:     "odd\x07",
+	}
+	var b bytes.Buffer
+	report.New(&b, false).Print("", []byte(src), m)
+	want := "1:6: warning: odd\\u0007 (rule)\n" +
+		"  1 |     \\u001b[2Jbad \\xff\n" +
+		"    |              ^~~\n"
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+	if strings.ContainsRune(b.String(), 0x1b) {
+		t.Error("raw escape character in output")
+	}
+}
+
+func TestPrintColor(t *testing.T) {
+	src := "f: {[x] "
+	var b bytes.Buffer
+	report.New(&b, true).PrintAll("f.wz", []byte(src), diagnostics(t, src))
+	out := b.String()
+	if !strings.Contains(out, "\x1b[1;31merror:\x1b[0m") || !strings.Contains(out, "\x1b[1mf.wz:1:") {
+		t.Errorf("missing colors: %q", out)
+	}
+}
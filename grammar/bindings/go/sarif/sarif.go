@@ -0,0 +1,318 @@
+// Package sarif exports syntax diagnostics and lint findings as SARIF
+// 2.1.0 logs, the format GitHub code scanning and other dashboards
+// ingest.
+//
+// A log has one run, whose tool lists a rule for each lint rule and for
+// each kind of syntax diagnostic. Each result carries the rule that
+// produced it, its region in both line and column and byte terms, a
+// fingerprint that survives edits elsewhere in the file, and the fixes the
+// diagnostic or finding offers, as replacements:
+//
+//	b := sarif.New(lint.Rules())
+//	b.AddDiagnostics("src/main.wabznasm", src, diagnostics)
+//	b.AddFindings("src/main.wabznasm", src, findings)
+//	b.Log().Write(os.Stdout)
+//
+// Columns are counted in UTF-16 code units, the SARIF default.
+package sarif
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/position"
+)
+
+// Schema and Version identify the SARIF version written.
+const (
+	Schema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	Version = "2.1.0"
+)
+
+// SourceRoot is the uriBaseId of relative artifact locations.
+const SourceRoot = "%SRCROOT%"
+
+// FingerprintKey names the partial fingerprint of each result.
+const FingerprintKey = "wabznasm/v1"
+
+// Log is a SARIF log.
+type Log struct {
+	Schema  string `json:"$schema"`
+	Version string `json:"version"`
+	Runs    []Run  `json:"runs"`
+}
+
+// Write writes l to w as indented JSON.
+func (l *Log) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(l)
+}
+
+// Run is the output of one invocation of a tool.
+type Run struct {
+	Tool       Tool     `json:"tool"`
+	ColumnKind string   `json:"columnKind"`
+	Results    []Result `json:"results"`
+}
+
+// Tool describes the tool of a run.
+type Tool struct {
+	Driver Driver `json:"driver"`
+}
+
+// Driver is the component of a tool that produced the results.
+type Driver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri,omitempty"`
+	Rules          []Rule `json:"rules"`
+}
+
+// Rule is the metadata of a rule, a SARIF reportingDescriptor.
+type Rule struct {
+	ID                   string         `json:"id"`
+	ShortDescription     Message        `json:"shortDescription"`
+	DefaultConfiguration *Configuration `json:"defaultConfiguration,omitempty"`
+}
+
+// Configuration is the default configuration of a rule.
+type Configuration struct {
+	Level string `json:"level"`
+}
+
+// Message is a text message.
+type Message struct {
+	Text string `json:"text"`
+}
+
+// Result is a diagnostic or finding.
+type Result struct {
+	RuleID              string            `json:"ruleId"`
+	RuleIndex           int               `json:"ruleIndex"`
+	Level               string            `json:"level"`
+	Message             Message           `json:"message"`
+	Locations           []Location        `json:"locations"`
+	PartialFingerprints map[string]string `json:"partialFingerprints"`
+	Fixes               []Fix             `json:"fixes,omitempty"`
+}
+
+// Location is where a result was found.
+type Location struct {
+	PhysicalLocation PhysicalLocation `json:"physicalLocation"`
+}
+
+// PhysicalLocation is a region of a file.
+type PhysicalLocation struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Region           Region           `json:"region"`
+}
+
+// ArtifactLocation names a file.
+type ArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// Region is a span of a file. Lines and columns are one-based; the end
+// column is just past the last character.
+type Region struct {
+	StartLine   int  `json:"startLine"`
+	StartColumn int  `json:"startColumn"`
+	EndLine     int  `json:"endLine"`
+	EndColumn   int  `json:"endColumn"`
+	ByteOffset  uint `json:"byteOffset"`
+	ByteLength  uint `json:"byteLength"`
+}
+
+// Fix is a proposed correction.
+type Fix struct {
+	Description     Message          `json:"description"`
+	ArtifactChanges []ArtifactChange `json:"artifactChanges"`
+}
+
+// ArtifactChange is the part of a fix changing one file.
+type ArtifactChange struct {
+	ArtifactLocation ArtifactLocation `json:"artifactLocation"`
+	Replacements     []Replacement    `json:"replacements"`
+}
+
+// Replacement replaces a region by new text.
+type Replacement struct {
+	DeletedRegion   Region           `json:"deletedRegion"`
+	InsertedContent *ArtifactContent `json:"insertedContent,omitempty"`
+}
+
+// ArtifactContent is the text of a replacement.
+type ArtifactContent struct {
+	Text string `json:"text"`
+}
+
+// syntaxRules describe the codes of syntax diagnostics.
+var syntaxRules = []Rule{
+	{ID: tree_sitter_wabznasm.CodeSyntax, ShortDescription: Message{"reports source the grammar does not accept"}, DefaultConfiguration: &Configuration{"error"}},
+	{ID: tree_sitter_wabznasm.CodeMissing, ShortDescription: Message{"reports a token the parser inserted to recover"}, DefaultConfiguration: &Configuration{"error"}},
+}
+
+// Builder accumulates the results of a run.
+type Builder struct {
+	run Run
+	// index maps rule IDs to their index in the rules of the driver.
+	index map[string]int
+	// seen counts the results of each file with the same fingerprint
+	// basis, so that repeated findings get distinct fingerprints.
+	seen map[string]int
+}
+
+// New returns a builder whose tool describes rules, or every registered
+// lint rule if rules is nil, and the syntax diagnostics.
+func New(rules []lint.Rule) *Builder {
+	if rules == nil {
+		rules = lint.Rules()
+	}
+	b := &Builder{
+		run: Run{
+			Tool:       Tool{Driver: Driver{Name: "wabznasm", InformationURI: "https://github.com/tree-sitter/tree-sitter-wabznasm"}},
+			ColumnKind: "utf16CodeUnits",
+			Results:    []Result{},
+		},
+		index: map[string]int{},
+		seen:  map[string]int{},
+	}
+	for _, r := range syntaxRules {
+		b.addRule(r)
+	}
+	for _, r := range rules {
+		b.addRule(Rule{ID: r.Name(), ShortDescription: Message{r.Doc()}})
+	}
+	return b
+}
+
+func (b *Builder) addRule(r Rule) int {
+	if i, ok := b.index[r.ID]; ok {
+		return i
+	}
+	b.index[r.ID] = len(b.run.Tool.Driver.Rules)
+	b.run.Tool.Driver.Rules = append(b.run.Tool.Driver.Rules, r)
+	return b.index[r.ID]
+}
+
+// AddDiagnostics adds the syntax diagnostics of src, the contents of the
+// file at path.
+func (b *Builder) AddDiagnostics(path string, src []byte, ds []tree_sitter_wabznasm.Diagnostic) {
+	for _, d := range ds {
+		code := d.Code
+		if code == "" {
+			code = tree_sitter_wabznasm.CodeSyntax
+		}
+		b.add(path, src, code, d.Severity, d.Message, d.Range, d.Fixes)
+	}
+}
+
+// AddFindings adds the lint findings of src, the contents of the file at
+// path. Findings of rules the builder was not given are described by
+// their name alone.
+func (b *Builder) AddFindings(path string, src []byte, fs []lint.Finding) {
+	for _, f := range fs {
+		b.add(path, src, f.Rule, f.Severity, f.Message, f.Range, f.Fixes)
+	}
+}
+
+func (b *Builder) add(path string, src []byte, rule string, sev tree_sitter_wabznasm.Severity, text string, r tree_sitter.Range, fixes []tree_sitter_wabznasm.Fix) {
+	loc := artifact(path)
+	res := Result{
+		RuleID:    rule,
+		RuleIndex: b.addRule(Rule{ID: rule, ShortDescription: Message{rule}}),
+		Level:     level(sev),
+		Message:   Message{text},
+		Locations: []Location{{PhysicalLocation{ArtifactLocation: loc, Region: region(src, r)}}},
+		PartialFingerprints: map[string]string{
+			FingerprintKey: b.fingerprint(loc.URI, rule, src, r),
+		},
+	}
+	for _, fix := range fixes {
+		change := ArtifactChange{ArtifactLocation: loc}
+		for _, e := range fix.Edits {
+			rep := Replacement{DeletedRegion: region(src, e.Range)}
+			if e.NewText != "" {
+				rep.InsertedContent = &ArtifactContent{e.NewText}
+			}
+			change.Replacements = append(change.Replacements, rep)
+		}
+		res.Fixes = append(res.Fixes, Fix{Description: Message{fix.Title}, ArtifactChanges: []ArtifactChange{change}})
+	}
+	b.run.Results = append(b.run.Results, res)
+}
+
+// Log returns the log of the results added so far.
+func (b *Builder) Log() *Log {
+	return &Log{Schema: Schema, Version: Version, Runs: []Run{b.run}}
+}
+
+func level(s tree_sitter_wabznasm.Severity) string {
+	switch s {
+	case tree_sitter_wabznasm.SeverityError:
+		return "error"
+	case tree_sitter_wabznasm.SeverityWarning:
+		return "warning"
+	}
+	return "note"
+}
+
+// artifact returns the location of the file at path: relative to the
+// source root if path is relative, else a file URI.
+func artifact(path string) ArtifactLocation {
+	slashed := filepath.ToSlash(path)
+	if filepath.IsAbs(path) {
+		if !strings.HasPrefix(slashed, "/") {
+			slashed = "/" + slashed
+		}
+		return ArtifactLocation{URI: (&url.URL{Scheme: "file", Path: slashed}).String()}
+	}
+	return ArtifactLocation{URI: (&url.URL{Path: strings.TrimPrefix(slashed, "./")}).String(), URIBaseID: SourceRoot}
+}
+
+// region converts r, a range of src, to a SARIF region.
+func region(src []byte, r tree_sitter.Range) Region {
+	return Region{
+		StartLine:   int(r.StartPoint.Row) + 1,
+		StartColumn: column(src, r.StartByte),
+		EndLine:     int(r.EndPoint.Row) + 1,
+		EndColumn:   column(src, r.EndByte),
+		ByteOffset:  r.StartByte,
+		ByteLength:  r.EndByte - r.StartByte,
+	}
+}
+
+// column returns the one-based UTF-16 column of offset in src.
+func column(src []byte, offset uint) int {
+	return int(position.Column(src, offset, position.UTF16)) + 1
+}
+
+// fingerprint identifies a result by its file, rule and the text of the
+// lines it spans, with surrounding space trimmed, so that it stays the
+// same when lines are added or removed elsewhere in the file. Results with
+// the same basis are told apart by the order they occur in.
+func (b *Builder) fingerprint(uri, rule string, src []byte, r tree_sitter.Range) string {
+	start := min(r.StartByte, uint(len(src)))
+	for start > 0 && src[start-1] != '\n' {
+		start--
+	}
+	end := min(max(r.EndByte, start), uint(len(src)))
+	for end < uint(len(src)) && src[end] != '\n' {
+		end++
+	}
+	basis := uri + "\x00" + rule + "\x00" + strings.TrimSpace(string(src[start:end]))
+	n := b.seen[basis]
+	b.seen[basis]++
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", basis, n)))
+	return hex.EncodeToString(sum[:16])
+}
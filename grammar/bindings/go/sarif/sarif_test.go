@@ -0,0 +1,109 @@
+package sarif_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/sarif"
+)
+
+// analyze builds the log of one file's diagnostics and findings.
+func analyze(t *testing.T, path, src string) *sarif.Log {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	b := sarif.New(nil)
+	b.AddDiagnostics(path, []byte(src), tree_sitter_wabznasm.Diagnostics(tree.Tree, []byte(src)))
+	b.AddFindings(path, []byte(src), lint.Run(tree.Tree, []byte(src)))
+	return b.Log()
+}
+
+func TestFindings(t *testing.T) {
+	log := analyze(t, "./lib/f.wabznasm", "f: {[x;y] x*2}")
+	run := log.Runs[0]
+	if len(run.Results) != 1 {
+		t.Fatalf("results = %+v", run.Results)
+	}
+	res := run.Results[0]
+	if run.Tool.Driver.Rules[res.RuleIndex].ID != "unused-parameter" || res.RuleID != "unused-parameter" {
+		t.Errorf("rule %s at index %d", res.RuleID, res.RuleIndex)
+	}
+	loc := res.Locations[0].PhysicalLocation
+	if loc.ArtifactLocation.URI != "lib/f.wabznasm" || loc.ArtifactLocation.URIBaseID != sarif.SourceRoot {
+		t.Errorf("artifact = %+v", loc.ArtifactLocation)
+	}
+	if r := loc.Region; r.StartLine != 1 || r.StartColumn != 8 || r.EndColumn != 9 || r.ByteOffset != 7 || r.ByteLength != 1 {
+		t.Errorf("region = %+v", r)
+	}
+	if len(res.Fixes) != 1 || len(res.Fixes[0].ArtifactChanges[0].Replacements) == 0 {
+		t.Errorf("fixes = %+v", res.Fixes)
+	}
+	if res.PartialFingerprints[sarif.FingerprintKey] == "" {
+		t.Error("no fingerprint")
+	}
+
+	var buf bytes.Buffer
+	if err := log.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil || doc["version"] != "2.1.0" || doc["$schema"] != sarif.Schema {
+		t.Errorf("log = %s, %v", buf.Bytes(), err)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	log := analyze(t, "/src/g.wabznasm", "g: f[1")
+	var res *sarif.Result
+	for i, r := range log.Runs[0].Results {
+		if r.RuleID == tree_sitter_wabznasm.CodeMissing {
+			res = &log.Runs[0].Results[i]
+		}
+	}
+	if res == nil {
+		t.Fatalf("results = %+v", log.Runs[0].Results)
+	}
+	if res.Level != "error" || res.Locations[0].PhysicalLocation.ArtifactLocation.URI != "file:///src/g.wabznasm" {
+		t.Errorf("result = %+v", res)
+	}
+	if len(res.Fixes) == 0 || res.Fixes[0].ArtifactChanges[0].Replacements[0].InsertedContent.Text != "]" {
+		t.Errorf("fixes = %+v", res.Fixes)
+	}
+
+	// Columns count the two UTF-16 units of the emoji, and byte lengths
+	// its four bytes.
+	log = analyze(t, "e.wabznasm", "g: \U0001F600 1+1")
+	if r := log.Runs[0].Results[0].Locations[0].PhysicalLocation.Region; r.StartColumn != 4 || r.EndColumn != 6 || r.ByteLength != 4 {
+		t.Errorf("region = %+v", r)
+	}
+}
+
+func TestFingerprints(t *testing.T) {
+	fingerprints := func(src string) []string {
+		var out []string
+		for _, r := range analyze(t, "f.wabznasm", src).Runs[0].Results {
+			out = append(out, r.PartialFingerprints[sarif.FingerprintKey])
+		}
+		return out
+	}
+	before := fingerprints("f: {[x;y] x*2}")
+	after := fingerprints("\\ doubles x\n\nf: {[x;y] x*2}")
+	if len(before) != 1 || len(after) != 1 || before[0] != after[0] {
+		t.Errorf("fingerprints %v before and %v after adding lines", before, after)
+	}
+	// Two findings on one line differ by their order.
+	if fps := fingerprints("f: {[a;b] 1}"); len(fps) != 2 || fps[0] == fps[1] {
+		t.Errorf("fingerprints = %v", fps)
+	}
+}
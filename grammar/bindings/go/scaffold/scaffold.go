@@ -0,0 +1,143 @@
+// Package scaffold generates starter files for wabznasm projects, so that
+// a new user begins from sources that parse, are formatted, and show the
+// layout the tools expect, rather than from a blank file.
+//
+// Each template is given a name, which must be an identifier since it
+// names the function the files define:
+//
+//	library  a module: wabznasm.mod, name.wz defining name, and its tests
+//	script   a script of statements evaluated in turn, name.wz
+//	test     a test file, name_test.wz, and the library name.wz it tests
+//
+// Every source uses the .wz extension and passes wabznasm check.
+package scaffold
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/mod"
+)
+
+// File is a generated file.
+type File struct {
+	// Path is relative to the directory the files are written to, with
+	// slashes.
+	Path    string
+	Content []byte
+}
+
+// Template generates a set of files.
+type Template struct {
+	Name    string
+	Summary string
+	files   func(name string) []File
+}
+
+// Files returns the files t generates for name.
+func (t *Template) Files(name string) ([]File, error) {
+	if !identifier.MatchString(name) {
+		return nil, fmt.Errorf("scaffold: %q is not an identifier", name)
+	}
+	return t.files(name), nil
+}
+
+var identifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Templates returns the templates, in name order.
+func Templates() []*Template {
+	return []*Template{
+		{Name: "library", Summary: "a module defining a function, with its tests", files: library},
+		{Name: "script", Summary: "a script of statements evaluated in turn", files: script},
+		{Name: "test", Summary: "a library source and its test file, without a module", files: test},
+	}
+}
+
+// Lookup returns the template called name, or nil.
+func Lookup(name string) *Template {
+	for _, t := range Templates() {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+// expand replaces NAME in text by name.
+func expand(text, name string) []byte {
+	return []byte(strings.ReplaceAll(text, "NAME", name))
+}
+
+func library(name string) []File {
+	m := &mod.Manifest{Name: name, Version: "0.1.0"}
+	return []File{
+		{Path: mod.ManifestFile, Content: m.Bytes()},
+		{Path: name + ".wz", Content: expand(libraryText, name)},
+		{Path: name + "_test.wz", Content: expand(testText, name)},
+	}
+}
+
+func script(name string) []File {
+	return []File{{Path: name + ".wz", Content: expand(scriptText, name)}}
+}
+
+// test writes the library with its tests, as the tests call the function
+// it defines.
+func test(name string) []File {
+	return []File{
+		{Path: name + ".wz", Content: expand(libraryText, name)},
+		{Path: name + "_test.wz", Content: expand(testText, name)},
+	}
+}
+
+const libraryText = `\ NAME returns the sum of the squares of x and y.
+NAME: {[x;y] (x*x)+y*y}
+`
+
+const testText = `\ Tests of NAME, run with wabznasm test. The library beside this file,
+\ NAME.wz, is evaluated first; each line calling expect or assert is a
+\ test case.
+expect[NAME[3;4];25]
+expect[NAME[0;0];0]
+assert[NAME[1;1]]
+`
+
+const scriptText = `\ NAME: the statements of a script are evaluated in turn, one per line,
+\ as in the REPL. Run it with wabznasm profile NAME.wz, or paste it into
+\ wabznasm repl.
+rate: 3
+scale: {[x] x*rate}
+net: scale[100]-25
+\ Functions without a parameter list take x, y and z.
+double: {2*x}
+double[net]
+`
+
+// Write writes files under dir, creating directories as needed. It writes
+// nothing, and fails with an error wrapping fs.ErrExist, if any of the files
+// already exists.
+func Write(dir string, files []File) error {
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if _, err := os.Lstat(path); err == nil {
+			return fmt.Errorf("scaffold: %s: %w", path, fs.ErrExist)
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	for _, f := range files {
+		path := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, f.Content, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
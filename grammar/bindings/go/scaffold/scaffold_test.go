@@ -0,0 +1,114 @@
+package scaffold_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/mod"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scaffold"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/suite"
+)
+
+func TestTemplates(t *testing.T) {
+	for _, tmpl := range scaffold.Templates() {
+		files, err := tmpl.Files("norm")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range files {
+			if f.Path != mod.ManifestFile {
+				check(t, tmpl.Name+" "+f.Path, f.Content)
+			}
+		}
+	}
+	if scaffold.Lookup("script") == nil || scaffold.Lookup("nope") != nil {
+		t.Error("Lookup found the wrong templates")
+	}
+	if _, err := scaffold.Lookup("library").Files("no-dash"); err == nil {
+		t.Error("Files accepted a name that is not an identifier")
+	}
+}
+
+// check fails t unless src passes wabznasm check: it parses, passes every
+// lint rule and is formatted.
+func check(t *testing.T, name string, src []byte) {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	s, err := parser.ParseScript(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	for _, d := range s.Diagnostics() {
+		t.Errorf("%s:%v", name, d)
+	}
+	for _, f := range lint.RunScript(s) {
+		t.Errorf("%s: %s: %s", name, f.Rule, f.Message)
+	}
+	out, err := format.Config{}.Script(src)
+	if err != nil || !bytes.Equal(out, src) {
+		t.Errorf("%s is not formatted: %v\n%s", name, err, out)
+	}
+}
+
+func TestLibrary(t *testing.T) {
+	dir := t.TempDir()
+	files, _ := scaffold.Lookup("library").Files("norm")
+	if err := scaffold.Write(dir, files); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := mod.ReadManifest(dir); err != nil || m.Name != "norm" {
+		t.Errorf("ReadManifest = %+v, %v", m, err)
+	}
+	res := suite.Run(context.Background(), suite.Config{}, filepath.Join(dir, "norm_test.wz"))
+	if !res.Passed() || len(res.Cases) != 3 || res.Library != filepath.Join(dir, "norm.wz") {
+		t.Errorf("suite.Run = %+v", res)
+	}
+	if err := scaffold.Write(dir, files); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("second Write = %v", err)
+	}
+}
+
+func TestTest(t *testing.T) {
+	dir := t.TempDir()
+	files, _ := scaffold.Lookup("test").Files("t1")
+	if err := scaffold.Write(dir, files); err != nil {
+		t.Fatal(err)
+	}
+	res := suite.Run(context.Background(), suite.Config{}, filepath.Join(dir, "t1_test.wz"))
+	if !res.Passed() || len(res.Cases) != 3 {
+		t.Errorf("suite.Run = %+v", res)
+	}
+}
+
+func TestScript(t *testing.T) {
+	files, _ := scaffold.Lookup("script").Files("pay")
+	if len(files) != 1 || files[0].Path != "pay.wz" {
+		t.Fatalf("files = %+v", files)
+	}
+	var last eval.Value
+	err := repl.RunScript(context.Background(), eval.New(), files[0].Content, func(st *repl.Statement) bool {
+		if st.Err != nil {
+			t.Errorf("%s: %v", strings.TrimSpace(st.Text), st.Err)
+		}
+		last = st.Value
+		return true
+	})
+	if err != nil || last == nil || last.String() != "550" {
+		t.Errorf("RunScript = %v, last value %v", err, last)
+	}
+}
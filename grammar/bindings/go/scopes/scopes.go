@@ -0,0 +1,333 @@
+// Package scopes resolves names in a wabznasm source to the symbols they
+// refer to.
+//
+// A source has one global scope holding assigned names, shared by the
+// statements of a script, so that a statement uses the globals the others
+// assign wherever they are in the script. Each function
+// literal opens a scope for its parameters: the names of an explicit
+// parameter list, or the implicit x, y and z its body uses. A name that no
+// enclosing scope defines resolves to a free symbol in the global scope,
+// standing for a global defined by some other source.
+package scopes
+
+import (
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+// Kind classifies a symbol.
+type Kind int
+
+const (
+	// Global is a name assigned at the top level.
+	Global Kind = iota
+	// Param is an explicit function parameter.
+	Param
+	// Implicit is one of the implicit parameters x, y and z.
+	Implicit
+	// Free is a name used but not defined in the source.
+	Free
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Global:
+		return "global"
+	case Param:
+		return "parameter"
+	case Implicit:
+		return "implicit parameter"
+	case Free:
+		return "free"
+	}
+	return "unknown"
+}
+
+// Symbol is a named entity and every place the source mentions it.
+type Symbol struct {
+	Name  string
+	Kind  Kind
+	Scope *Scope
+	// Decl is the identifier defining the symbol. It is nil for implicit
+	// and free symbols, which have no declaration in the source.
+	Decl *ast.Ident
+	// Redefs are the names of the later assignments of a global a script
+	// assigns more than once, in source order.
+	Redefs []*ast.Ident
+	// Refs are the identifiers using the symbol, in source order. The
+	// declaration and redefinitions are not included.
+	Refs []*ast.Ident
+	// Func is the function last assigned to a global, if any.
+	Func *ast.FunctionDef
+}
+
+// Scope is a region of the source in which names are defined.
+type Scope struct {
+	Parent   *Scope
+	Children []*Scope
+	// Func is the function literal that opened the scope; nil for the
+	// global scope.
+	Func    *ast.FunctionDef
+	Span    ast.Span
+	Symbols map[string]*Symbol
+}
+
+// Lookup finds name in s or its ancestors.
+func (s *Scope) Lookup(name string) *Symbol {
+	for ; s != nil; s = s.Parent {
+		if sym, ok := s.Symbols[name]; ok {
+			return sym
+		}
+	}
+	return nil
+}
+
+// Table is the result of analysing a source.
+type Table struct {
+	// Files are the converted statements of the source, in source order:
+	// one for a single statement, one per statement for a script.
+	Files  []*ast.File
+	Global *Scope
+	// Symbols lists every symbol in order of first appearance.
+	Symbols []*Symbol
+	uses    map[*ast.Ident]*Symbol
+}
+
+// FromTree analyses a parse tree of source.
+func FromTree(tree *tree_sitter.Tree, source []byte) *Table {
+	return Build(ast.FromTree(tree, source))
+}
+
+// FromScript analyses the statements of a parsed script.
+func FromScript(s *tree_sitter_wabznasm.Script) *Table {
+	files := make([]*ast.File, len(s.Statements))
+	for i, st := range s.Statements {
+		files[i] = ast.FromTree(st.Tree.Tree, st.Source)
+	}
+	return Build(files...)
+}
+
+// Build analyses converted files, the statements of a source in order.
+// The globals every statement assigns are defined before any is resolved.
+func Build(files ...*ast.File) *Table {
+	t := &Table{
+		Files:  files,
+		Global: &Scope{Symbols: map[string]*Symbol{}},
+		uses:   map[*ast.Ident]*Symbol{},
+	}
+	if len(files) > 0 {
+		t.Global.Span = ast.Span{Start: files[0].Pos(), End: files[len(files)-1].EndPos()}
+	}
+	for _, f := range files {
+		a, ok := f.Stmt.(*ast.Assignment)
+		if !ok || a.Name == nil {
+			continue
+		}
+		sym := t.Global.Symbols[a.Name.Name]
+		if sym == nil {
+			sym = t.define(t.Global, a.Name.Name, Global, a.Name)
+		} else {
+			sym.Redefs = append(sym.Redefs, a.Name)
+			t.uses[a.Name] = sym
+		}
+		sym.Func, _ = a.Value.(*ast.FunctionDef)
+	}
+	for _, f := range files {
+		switch s := f.Stmt.(type) {
+		case *ast.Assignment:
+			t.expr(t.Global, s.Value)
+		case *ast.ExprStmt:
+			t.expr(t.Global, s.X)
+		}
+	}
+	return t
+}
+
+// Stmts returns the statements of the source in order, leaving out the
+// files holding none.
+func (t *Table) Stmts() []ast.Stmt {
+	var out []ast.Stmt
+	for _, f := range t.Files {
+		if f.Stmt != nil {
+			out = append(out, f.Stmt)
+		}
+	}
+	return out
+}
+
+// Mentions returns the global and free symbols that stmt, a statement of
+// the source, uses, in order of first use. The name a statement assigns
+// is not a use.
+func (t *Table) Mentions(stmt ast.Stmt) []*Symbol {
+	var value ast.Node
+	switch s := stmt.(type) {
+	case *ast.Assignment:
+		value = s.Value
+	case *ast.ExprStmt:
+		value = s.X
+	}
+	if value == nil {
+		return nil
+	}
+	var out []*Symbol
+	seen := map[*Symbol]bool{}
+	ast.Inspect(value, func(n ast.Node) bool {
+		if id, ok := n.(*ast.Ident); ok {
+			if sym := t.uses[id]; sym != nil && sym.Scope == t.Global && !seen[sym] {
+				seen[sym] = true
+				out = append(out, sym)
+			}
+		}
+		return true
+	})
+	return out
+}
+
+// FileAt returns the file of the statement whose span holds the byte
+// offset, or nil if there is none.
+func (t *Table) FileAt(offset uint) *ast.File {
+	for _, f := range t.Files {
+		if f.Stmt != nil && f.Stmt.Pos().Offset <= offset && offset <= f.Stmt.EndPos().Offset {
+			return f
+		}
+	}
+	return nil
+}
+
+func (t *Table) define(scope *Scope, name string, kind Kind, decl *ast.Ident) *Symbol {
+	sym := &Symbol{Name: name, Kind: kind, Scope: scope, Decl: decl}
+	scope.Symbols[name] = sym
+	t.Symbols = append(t.Symbols, sym)
+	if decl != nil {
+		t.uses[decl] = sym
+	}
+	return sym
+}
+
+func (t *Table) expr(scope *Scope, e ast.Expr) {
+	switch e := e.(type) {
+	case *ast.Ident:
+		t.use(scope, e)
+	case *ast.FunctionDef:
+		t.function(scope, e)
+	case *ast.BinaryExpr:
+		t.expr(scope, e.Left)
+		t.expr(scope, e.Right)
+	case *ast.UnaryExpr:
+		t.expr(scope, e.Operand)
+	case *ast.PostfixExpr:
+		t.expr(scope, e.Operand)
+	case *ast.ParenExpr:
+		t.expr(scope, e.X)
+	case *ast.Call:
+		if e.Func != nil {
+			t.use(scope, e.Func)
+		}
+		for _, arg := range e.Args {
+			t.expr(scope, arg)
+		}
+	}
+}
+
+func (t *Table) function(parent *Scope, fn *ast.FunctionDef) {
+	scope := &Scope{Parent: parent, Func: fn, Span: ast.Span{Start: fn.Pos(), End: fn.EndPos()}, Symbols: map[string]*Symbol{}}
+	parent.Children = append(parent.Children, scope)
+	if fn.Params != nil {
+		for _, id := range fn.Params.Names {
+			if first, dup := scope.Symbols[id.Name]; dup {
+				// A repeated parameter refers to the first.
+				first.Refs = append(first.Refs, id)
+				t.uses[id] = first
+				continue
+			}
+			t.define(scope, id.Name, Param, id)
+		}
+	} else {
+		for _, name := range fn.Signature() {
+			t.define(scope, name, Implicit, nil)
+		}
+	}
+	t.expr(scope, fn.Body)
+}
+
+func (t *Table) use(scope *Scope, id *ast.Ident) {
+	sym := scope.Lookup(id.Name)
+	if sym == nil {
+		sym = t.define(t.Global, id.Name, Free, nil)
+	}
+	sym.Refs = append(sym.Refs, id)
+	t.uses[id] = sym
+}
+
+// Resolve returns the symbol an identifier of the file declares or uses.
+func (t *Table) Resolve(id *ast.Ident) *Symbol { return t.uses[id] }
+
+// IdentAt returns the identifier covering the byte offset, if any. An
+// offset just past the end of an identifier counts, so a cursor placed
+// after a name finds it.
+func (t *Table) IdentAt(offset uint) *ast.Ident {
+	var found *ast.Ident
+	for id := range t.uses {
+		if id.Pos().Offset <= offset && offset <= id.EndPos().Offset {
+			// Prefer the identifier starting at offset over one ending there.
+			if found == nil || id.Pos().Offset > found.Pos().Offset {
+				found = id
+			}
+		}
+	}
+	return found
+}
+
+// ResolveAt returns the symbol named by the identifier at the byte offset,
+// or nil if there is none.
+func (t *Table) ResolveAt(offset uint) *Symbol {
+	if id := t.IdentAt(offset); id != nil {
+		return t.uses[id]
+	}
+	return nil
+}
+
+// ReferencesOf returns the identifiers using sym, without its declaration.
+func (t *Table) ReferencesOf(sym *Symbol) []*ast.Ident { return sym.Refs }
+
+// Occurrences returns the declaration of sym, if any, followed by its
+// redefinitions and its references.
+func (t *Table) Occurrences(sym *Symbol) []*ast.Ident {
+	var out []*ast.Ident
+	if sym.Decl != nil {
+		out = append(out, sym.Decl)
+	}
+	out = append(out, sym.Redefs...)
+	return append(out, sym.Refs...)
+}
+
+// ScopeAt returns the innermost scope containing the byte offset.
+func (t *Table) ScopeAt(offset uint) *Scope {
+	s := t.Global
+	for {
+		next := (*Scope)(nil)
+		for _, c := range s.Children {
+			if c.Span.Start.Offset <= offset && offset < c.Span.End.Offset {
+				next = c
+				break
+			}
+		}
+		if next == nil {
+			return s
+		}
+		s = next
+	}
+}
+
+// Globals returns the symbols defined in the global scope, including free
+// ones, in order of first appearance.
+func (t *Table) Globals() []*Symbol {
+	var out []*Symbol
+	for _, sym := range t.Symbols {
+		if sym.Scope == t.Global {
+			out = append(out, sym)
+		}
+	}
+	return out
+}
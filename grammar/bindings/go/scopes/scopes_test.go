@@ -0,0 +1,84 @@
+package scopes_test
+
+import (
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+func build(t *testing.T, src string) *scopes.Table {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+	return scopes.FromTree(tree.Tree, []byte(src))
+}
+
+func TestExplicitParameters(t *testing.T) {
+	src := "add: {[x;y] x+y*scale}"
+	tab := build(t, src)
+
+	add := tab.Global.Symbols["add"]
+	if add == nil || add.Kind != scopes.Global || add.Func == nil || add.Decl.Pos().Offset != 0 {
+		t.Fatalf("add = %+v", add)
+	}
+	fn := tab.ScopeAt(uint(strings.Index(src, "x+")))
+	if fn.Parent != tab.Global || fn.Func == nil {
+		t.Fatalf("scope at body = %+v", fn)
+	}
+	x := tab.ResolveAt(uint(strings.Index(src, "x+")))
+	if x == nil || x.Kind != scopes.Param || x.Scope != fn || x.Decl.Pos().Offset != 7 {
+		t.Fatalf("x = %+v", x)
+	}
+	if refs := tab.ReferencesOf(x); len(refs) != 1 || refs[0].Pos().Offset != 12 {
+		t.Errorf("refs of x = %v", refs)
+	}
+	scale := tab.ResolveAt(uint(strings.Index(src, "scale")))
+	if scale == nil || scale.Kind != scopes.Free || scale.Scope != tab.Global {
+		t.Errorf("scale = %+v", scale)
+	}
+	if got := tab.ResolveAt(5); got != nil {
+		t.Errorf("ResolveAt(5) = %+v, want nil", got)
+	}
+}
+
+func TestImplicitParameters(t *testing.T) {
+	src := "f: {y*2+f[y]}"
+	tab := build(t, src)
+	fn := tab.Global.Children[0]
+	if fn.Symbols["x"] == nil || fn.Symbols["y"] == nil || fn.Symbols["z"] != nil {
+		t.Fatalf("implicit symbols = %v", fn.Symbols)
+	}
+	y := fn.Symbols["y"]
+	if y.Kind != scopes.Implicit || y.Decl != nil || len(y.Refs) != 2 {
+		t.Errorf("y = %+v", y)
+	}
+	// The recursive call resolves to the global being defined.
+	f := tab.ResolveAt(uint(strings.Index(src, "f[")))
+	if f != tab.Global.Symbols["f"] || len(tab.Occurrences(f)) != 2 {
+		t.Errorf("f = %+v", f)
+	}
+}
+
+func TestGlobals(t *testing.T) {
+	tab := build(t, "total: a+b*a")
+	var names []string
+	for _, sym := range tab.Globals() {
+		names = append(names, sym.Name+":"+sym.Kind.String())
+	}
+	if got := strings.Join(names, " "); got != "total:global a:free b:free" {
+		t.Errorf("globals = %s", got)
+	}
+	if a := tab.Global.Lookup("a"); len(a.Refs) != 2 {
+		t.Errorf("refs of a = %v", a.Refs)
+	}
+}
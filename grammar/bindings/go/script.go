@@ -0,0 +1,212 @@
+package tree_sitter_wabznasm
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/script"
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+)
+
+// Script is a source parsed a statement at a time. The grammar parses one
+// statement per source, while files, like the scripts the REPL runs, hold
+// a statement per line; ParseScript divides a file into statements as the
+// REPL does and parses each on its own.
+type Script struct {
+	Source []byte
+	// Statements are in source order.
+	Statements []Statement
+}
+
+// Statement is a statement of a script and its parse tree.
+type Statement struct {
+	// Offset is the byte offset of the statement in the script and Text
+	// its text; Text is empty for a script without statements.
+	Offset int
+	Text   string
+	// Start and End are the byte offsets of the lines going with the
+	// statement, as script.Extent divides them: its own lines and the
+	// comments and blank lines before it.
+	Start, End int
+	// Source is the source of the script, and Tree the parse of the lines
+	// of the statement in place, so that positions in the tree are
+	// positions in the script.
+	Source []byte
+	Tree   *memtrack.TrackedTree
+}
+
+// ParseScript parses the statements of src. The returned script must be
+// closed by the caller and src must not be modified while it is in use. A
+// source without statements, such as one holding only comments, is parsed
+// whole as a single statement.
+func (p *Parser) ParseScript(src []byte) (*Script, error) {
+	return p.ParseScriptContext(context.Background(), src)
+}
+
+// ParseScriptContext is ParseScript with the cancellation of
+// ParseContext.
+func (p *Parser) ParseScriptContext(ctx context.Context, src []byte) (*Script, error) {
+	return p.parseScript(ctx, src, nil)
+}
+
+// reparseScript parses src, the source of old after edit, as ParseScript
+// does, but moves the trees of the statements of old whose lines the edit
+// left alone to the new script, rather than parsing them again. The
+// caller must still close old, which keeps its trees if the parse fails.
+func (p *Parser) reparseScript(old *Script, src []byte, edit tree_sitter.InputEdit) (*Script, error) {
+	byStart := make(map[int]*Statement, len(old.Statements))
+	for i := range old.Statements {
+		byStart[old.Statements[i].Start] = &old.Statements[i]
+	}
+	shift := int(edit.NewEndByte) - int(edit.OldEndByte)
+	moved := map[*memtrack.TrackedTree]bool{}
+	s, err := p.parseScript(context.Background(), src, func(st *Statement) *Statement {
+		by := 0
+		switch {
+		case st.End <= int(edit.StartByte):
+		case st.Start >= int(edit.NewEndByte):
+			by = shift
+		default:
+			return nil
+		}
+		prev := byStart[st.Start-by]
+		if prev == nil || prev.End != st.End-by || prev.Offset != st.Offset-by || prev.Text != st.Text {
+			return nil
+		}
+		if by != 0 {
+			moved[prev.Tree] = true
+		}
+		return prev
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, st := range s.Statements {
+		if moved[st.Tree] {
+			st.Tree.Edit(&edit)
+		}
+	}
+	return s, nil
+}
+
+// parseScript parses the statements of src. If reuse returns a statement
+// for one, its tree is taken for the statement instead, once every other
+// statement has parsed.
+func (p *Parser) parseScript(ctx context.Context, src []byte, reuse func(*Statement) *Statement) (*Script, error) {
+	code := string(src)
+	entries := script.Split(code)
+	if len(entries) == 0 {
+		entries = []script.Entry{{}}
+	}
+	s := &Script{Source: src, Statements: make([]Statement, 0, len(entries))}
+	pos := &points{src: src}
+	taken := map[int]*Statement{}
+	for i, e := range entries {
+		start, end := script.Extent(code, entries, i)
+		st := Statement{Offset: e.Offset, Text: e.Text, Start: start, End: end, Source: src}
+		if reuse != nil {
+			if prev := reuse(&st); prev != nil {
+				taken[i] = prev
+				s.Statements = append(s.Statements, st)
+				continue
+			}
+		}
+		tree, err := p.parseRange(ctx, src, tree_sitter.Range{
+			StartByte: uint(start), EndByte: uint(end),
+			StartPoint: pos.at(start), EndPoint: pos.at(end),
+		})
+		if err != nil {
+			s.Close()
+			return nil, err
+		}
+		st.Tree = tree
+		s.Statements = append(s.Statements, st)
+	}
+	for i, prev := range taken {
+		s.Statements[i].Tree, prev.Tree = prev.Tree, nil
+	}
+	return s, nil
+}
+
+// parseRange parses the bytes of r in src and no others, so that a script
+// is parsed in time linear in its length.
+func (p *Parser) parseRange(ctx context.Context, src []byte, r tree_sitter.Range) (*memtrack.TrackedTree, error) {
+	if p.inner == nil {
+		return nil, ErrParserClosed
+	}
+	if err := p.inner.SetIncludedRanges([]tree_sitter.Range{r}); err != nil {
+		return nil, err
+	}
+	defer p.inner.SetIncludedRanges(nil)
+	ctx, span := telemetry.Start(ctx, "parse", slog.Int("bytes", int(r.EndByte-r.StartByte)), slog.Bool("incremental", false))
+	tree, err := p.parse(ctx, func(i int, _ tree_sitter.Point) []byte {
+		if i < int(r.EndByte) {
+			return src[i:r.EndByte]
+		}
+		return nil
+	}, nil)
+	span.End(err)
+	return tree, err
+}
+
+// points finds the points of increasing offsets of src in a single pass.
+type points struct {
+	src    []byte
+	offset int
+	point  tree_sitter.Point
+}
+
+func (p *points) at(offset int) tree_sitter.Point {
+	for ; p.offset < offset; p.offset++ {
+		if p.src[p.offset] == '\n' {
+			p.point.Row++
+			p.point.Column = 0
+		} else {
+			p.point.Column++
+		}
+	}
+	return p.point
+}
+
+// At returns the statement whose lines hold the byte offset, or the last
+// statement for an offset past the end.
+func (s *Script) At(offset uint) *Statement {
+	for i := range s.Statements {
+		if offset < uint(s.Statements[i].End) {
+			return &s.Statements[i]
+		}
+	}
+	return &s.Statements[len(s.Statements)-1]
+}
+
+// Diagnostics returns the syntax errors of every statement, in source
+// order.
+func (s *Script) Diagnostics() []Diagnostic {
+	var out []Diagnostic
+	for _, st := range s.Statements {
+		out = append(out, Diagnostics(st.Tree.Tree, s.Source)...)
+	}
+	return out
+}
+
+// HasError reports whether some statement has a syntax error.
+func (s *Script) HasError() bool {
+	for _, st := range s.Statements {
+		if st.Tree.RootNode().HasError() {
+			return true
+		}
+	}
+	return false
+}
+
+// Close releases the trees of the statements. Calling Close more than
+// once is a no-op.
+func (s *Script) Close() {
+	for _, st := range s.Statements {
+		if st.Tree != nil {
+			st.Tree.Close()
+		}
+	}
+}
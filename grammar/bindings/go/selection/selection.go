@@ -0,0 +1,39 @@
+// Package selection computes the ranges an editor's expand selection
+// command steps through: from the token at the cursor out through each
+// enclosing expression, to the statement and the whole source.
+package selection
+
+import (
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+)
+
+// Ranges returns the ranges of the named nodes enclosing point, innermost
+// first, each strictly larger than the one before; the nodes of the
+// precedence ladder that cover the same text as their child are skipped.
+// A cursor just after an identifier or number, as when the caret sits at
+// the end of a word, starts from that token.
+func Ranges(tree *tree_sitter.Tree, point tree_sitter.Point) []tree_sitter.Range {
+	var out []tree_sitter.Range
+	for n := start(tree.RootNode(), point); n != nil; n = n.Parent() {
+		r := n.Range()
+		if last := len(out) - 1; last >= 0 && out[last].StartByte == r.StartByte && out[last].EndByte == r.EndByte {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// start returns the innermost named node at point, preferring a token
+// ending at point over an enclosing node that merely spans it.
+func start(root *tree_sitter.Node, point tree_sitter.Point) *tree_sitter.Node {
+	n := root.NamedDescendantForPointRange(point, point)
+	if point.Column == 0 || (n != nil && n.ChildCount() == 0 && n.StartPosition() == point) {
+		return n
+	}
+	before := tree_sitter.Point{Row: point.Row, Column: point.Column - 1}
+	if m := root.NamedDescendantForPointRange(before, before); m != nil && m.ChildCount() == 0 && m.EndPosition() == point {
+		return m
+	}
+	return n
+}
@@ -0,0 +1,54 @@
+package selection_test
+
+import (
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/selection"
+)
+
+func parse(t *testing.T, src string) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(parser.Close)
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+func TestRanges(t *testing.T) {
+	const src = "f: {[a;b]\n  g[a*2;b] + 1}"
+	tests := []struct {
+		name string
+		// at is the text the cursor is placed before, plus offset.
+		at     string
+		offset int
+		want   []string
+	}{
+		{"in identifier", "a*2", 0, []string{"a", "a*2", "a*2;b", "g[a*2;b]", "g[a*2;b] + 1", "{[a;b]\n  g[a*2;b] + 1}", src}},
+		{"after identifier", "a*2", 1, []string{"a", "a*2", "a*2;b", "g[a*2;b]", "g[a*2;b] + 1", "{[a;b]\n  g[a*2;b] + 1}", src}},
+		{"on operator", "+ 1", 0, []string{"g[a*2;b] + 1", "{[a;b]\n  g[a*2;b] + 1}", src}},
+		{"parameter", "b]", 0, []string{"b", "[a;b]", "{[a;b]\n  g[a*2;b] + 1}", src}},
+		{"name", "f:", 0, []string{"f", src}},
+	}
+	tree := parse(t, src)
+	for _, tt := range tests {
+		off := strings.Index(src, tt.at) + tt.offset
+		point := tree_sitter_wabznasm.PointForOffset([]byte(src), uint(off))
+		var got []string
+		for _, r := range selection.Ranges(tree.Tree, point) {
+			got = append(got, src[r.StartByte:r.EndByte])
+		}
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("%s: Ranges = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
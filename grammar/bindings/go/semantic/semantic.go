@@ -0,0 +1,147 @@
+// Package semantic encodes highlight captures as LSP semantic tokens.
+package semantic
+
+import (
+	"unicode/utf8"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/highlight"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/position"
+)
+
+// Legend lists the token types and modifiers a server advertises in its
+// semanticTokensProvider capability. Encoded tokens refer to entries by index.
+type Legend struct {
+	TokenTypes     []string `json:"tokenTypes"`
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// DefaultLegend is the legend used by SemanticTokens.
+var DefaultLegend = Legend{
+	TokenTypes:     []string{"comment", "number", "function", "variable", "parameter", "operator"},
+	TokenModifiers: []string{"declaration", "definition"},
+}
+
+const (
+	typeComment = iota
+	typeNumber
+	typeFunction
+	typeVariable
+	typeParameter
+	typeOperator
+)
+
+const (
+	modDeclaration = 1 << iota
+	modDefinition
+)
+
+type tokenKind struct {
+	typ       uint32
+	modifiers uint32
+}
+
+// captureKinds maps highlight captures to semantic token kinds. Captures not
+// listed here, such as punctuation, are left to the client's syntax colouring.
+var captureKinds = map[string]tokenKind{
+	"comment":            {typeComment, 0},
+	"number":             {typeNumber, 0},
+	"function":           {typeFunction, modDefinition},
+	"function.call":      {typeFunction, 0},
+	"variable":           {typeVariable, 0},
+	"variable.parameter": {typeParameter, modDeclaration},
+	"operator":           {typeOperator, 0},
+}
+
+// SemanticTokens highlights tree and returns the delta-encoded token array
+// for a textDocument/semanticTokens/full response, using DefaultLegend.
+// Columns and lengths are measured in UTF-16 code units.
+func SemanticTokens(tree *tree_sitter.Tree, source []byte) ([]uint32, error) {
+	return SemanticTokensIn(tree, source, position.UTF16)
+}
+
+// SemanticTokensIn is SemanticTokens measuring columns and lengths in code
+// units of enc, for clients that agreed on another position encoding.
+func SemanticTokensIn(tree *tree_sitter.Tree, source []byte, enc position.Encoding) ([]uint32, error) {
+	tokens, err := highlight.Tokens(tree, source)
+	if err != nil {
+		return nil, err
+	}
+	return EncodeIn(tokens, source, enc), nil
+}
+
+// Encode delta-encodes highlight tokens, which must be sorted and
+// non-overlapping. Tokens spanning a line break are split per line.
+func Encode(tokens []highlight.Token, source []byte) []uint32 {
+	return EncodeIn(tokens, source, position.UTF16)
+}
+
+// EncodeIn is Encode measuring in code units of enc.
+func EncodeIn(tokens []highlight.Token, source []byte, enc position.Encoding) []uint32 {
+	var (
+		out               []uint32
+		prevLine, prevCol uint32
+	)
+	emit := func(line, col, length uint32, kind tokenKind) {
+		deltaCol := col
+		if line == prevLine {
+			deltaCol = col - prevCol
+		}
+		out = append(out, line-prevLine, deltaCol, length, kind.typ, kind.modifiers)
+		prevLine, prevCol = line, col
+	}
+
+	var (
+		line, col uint32 // position of offset, in units of enc
+		offset    uint
+	)
+	advance := func(to uint) {
+		for offset < to && offset < uint(len(source)) {
+			if source[offset] == '\n' {
+				line++
+				col = 0
+				offset++
+				continue
+			}
+			size, units := char(source[offset:], enc)
+			col += units
+			offset += size
+		}
+	}
+
+	for _, tok := range tokens {
+		kind, ok := captureKinds[tok.Capture]
+		if !ok || tok.Start < offset {
+			continue
+		}
+		advance(tok.Start)
+		startLine, startCol := line, col
+		for offset < tok.End && offset < uint(len(source)) {
+			if source[offset] == '\n' {
+				if col > startCol {
+					emit(startLine, startCol, col-startCol, kind)
+				}
+				advance(offset + 1)
+				startLine, startCol = line, col
+				continue
+			}
+			size, units := char(source[offset:], enc)
+			col += units
+			offset += size
+		}
+		if col > startCol {
+			emit(startLine, startCol, col-startCol, kind)
+		}
+	}
+	return out
+}
+
+// char returns the size in bytes and in code units of enc of the
+// character starting text, which must not be empty.
+func char(text []byte, enc position.Encoding) (uint, uint32) {
+	r, size := utf8.DecodeRune(text)
+	if r == utf8.RuneError && size == 1 {
+		return 1, 1
+	}
+	return uint(size), uint32(position.Units(text[:size], enc))
+}
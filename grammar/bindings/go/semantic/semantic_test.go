@@ -0,0 +1,62 @@
+package semantic_test
+
+import (
+	"reflect"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/highlight"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/semantic"
+)
+
+func TestSemanticTokens(t *testing.T) {
+	src := []byte("f: {x+1}")
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseBytes(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tree.Close()
+
+	got, err := semantic.SemanticTokens(tree.Tree, src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []uint32{
+		0, 0, 1, 2, 2, // f: function, definition
+		0, 1, 1, 5, 0, // :
+		0, 3, 1, 3, 0, // x
+		0, 1, 1, 5, 0, // +
+		0, 1, 1, 1, 0, // 1
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens:\n got %v\nwant %v", got, want)
+	}
+}
+
+func TestEncodeMultilineAndUTF16(t *testing.T) {
+	// A comment containing a character outside the BMP, followed by a token
+	// on the next line.
+	src := []byte("\\ 😀x\n42")
+	tokens := []highlight.Token{
+		{Start: 0, End: 7, Capture: "comment"},
+		{Start: 8, End: 10, Capture: "number"},
+	}
+	got := semantic.Encode(tokens, src)
+	want := []uint32{
+		0, 0, 5, 0, 0, // "\ 😀x" is 5 UTF-16 units
+		1, 0, 2, 1, 0,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("tokens:\n got %v\nwant %v", got, want)
+	}
+
+	split := semantic.Encode([]highlight.Token{{Start: 0, End: 10, Capture: "comment"}}, src)
+	if len(split) != 10 {
+		t.Errorf("expected token split across lines, got %v", split)
+	}
+}
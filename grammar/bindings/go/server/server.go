@@ -0,0 +1,497 @@
+// Package server serves the wabznasm tooling over HTTP, so that web
+// playgrounds and CI bots can parse, format, lint and highlight source
+// without linking the C parser into their own process.
+//
+// Every endpoint takes a POST whose body is the source, either as is or,
+// with a Content-Type of application/json, as {"source": "..."}, and
+// answers with JSON:
+//
+//	/parse      {"trees": [...], "diagnostics": [...]}, a tree for each
+//	            statement
+//	/format     {"source": "..."}, or 422 and the diagnostics if the
+//	            source does not parse
+//	/lint       {"findings": [...], "diagnostics": [...]}; the rules query
+//	            parameter selects rules by name, comma-separated
+//	/highlight  {"tokens": [...]}
+//
+// GET /debug/memory answers with the memtrack.Stats of the process, the
+// tree-sitter objects and C memory it holds.
+//
+// Sources are parsed a statement at a time, as the other tools parse
+// files, and trees are in the shape of tree_sitter_wabznasm.NodeJSON. A
+// /parse request whose Accept header names treebin.ContentType is
+// answered with the tree, and its source, encoded by package treebin
+// instead, for a client that wants to keep the tree or hand it on; as an
+// encoded tree is that of one statement, a source of several is refused
+// with a 406. Failures are
+// reported as {"error": "..."} with a 4xx or 5xx status: 413 for a body
+// over the size limit and 503 for a request that ran out of time.
+//
+// Every response carries the request ID of package telemetry in an
+// X-Request-ID header, when telemetry is on, and every request is logged
+// at info level with its method, path, status and duration. The work of a
+// request is traced in spans: the parse, then the phase of the endpoint.
+//
+// A request that panics is answered with a 500 whose body holds the ID of
+// the diagnostic bundle written for it by package crash; the handler goes
+// on serving the other requests.
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/crash"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/format"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/highlight"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/treebin"
+)
+
+// Defaults for the zero Options.
+const (
+	DefaultMaxRequestBytes = 1 << 20
+	DefaultTimeout         = 5 * time.Second
+)
+
+// Options configure a Handler.
+type Options struct {
+	// MaxRequestBytes bounds the size of a request body.
+	MaxRequestBytes int64
+	// Timeout bounds the time spent on a request.
+	Timeout time.Duration
+	// AllowOrigin, if set, is sent as Access-Control-Allow-Origin so that
+	// pages on other origins can call the service, and preflight requests
+	// are answered.
+	AllowOrigin string
+	// Logger, if set, logs the requests instead of the default logger of
+	// package telemetry.
+	Logger *slog.Logger
+	// Crash writes the diagnostic bundles of requests that panic; nil
+	// writes them as the zero crash.Reporter does.
+	Crash *crash.Reporter
+}
+
+// Handler serves the endpoints. It is safe for concurrent use.
+type Handler struct {
+	opts   Options
+	mux    *http.ServeMux
+	pool   *tree_sitter_wabznasm.ParserPool
+	hmu    sync.Mutex
+	hl     *highlight.Highlighter
+	hlInit error
+}
+
+// New returns a Handler. The caller must call Close.
+func New(opts Options) *Handler {
+	if opts.MaxRequestBytes <= 0 {
+		opts.MaxRequestBytes = DefaultMaxRequestBytes
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	// /debug/memory reports the C memory of the process.
+	memtrack.EnableCAccounting()
+	h := &Handler{opts: opts, mux: http.NewServeMux(), pool: tree_sitter_wabznasm.NewParserPool()}
+	h.hl, h.hlInit = highlight.New(nil)
+	h.mux.HandleFunc("/parse", h.endpoint("encode", h.parse))
+	h.mux.HandleFunc("/format", h.endpoint("format", h.format))
+	h.mux.HandleFunc("/lint", h.endpoint("lint", h.lint))
+	h.mux.HandleFunc("/highlight", h.endpoint("highlight", h.highlight))
+	h.mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok\n")
+	})
+	h.mux.HandleFunc("/debug/memory", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, memtrack.Read())
+	})
+	return h
+}
+
+// Close releases the parsers and queries of the handler.
+func (h *Handler) Close() {
+	h.pool.Close()
+	if h.hl != nil {
+		h.hl.Close()
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if h.opts.Logger != nil {
+		ctx = telemetry.WithLogger(ctx, h.opts.Logger)
+	}
+	ctx, span := telemetry.Start(ctx, "request", slog.String("method", r.Method), slog.String("path", r.URL.Path))
+	if id := span.RequestID(); id != "" {
+		w.Header().Set("X-Request-ID", id)
+	}
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	start := time.Now()
+	defer func() {
+		var err error
+		if rec.status >= 500 {
+			err = errors.New(http.StatusText(rec.status))
+		}
+		span.SetAttrs(slog.Int("status", rec.status))
+		span.End(err)
+		telemetry.Logger(ctx).InfoContext(ctx, "request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Duration("duration", time.Since(start)))
+	}()
+	w, r = rec, r.WithContext(ctx)
+	defer h.recover(ctx, w, &crash.Scope{Operation: r.URL.Path, RequestID: span.RequestID()})
+	if h.opts.AllowOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", h.opts.AllowOrigin)
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "POST")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	h.mux.ServeHTTP(w, r)
+}
+
+// recover answers a request that panicked, if it did, with a 500 and the
+// ID of its diagnostic bundle. It must be deferred directly.
+func (h *Handler) recover(ctx context.Context, w http.ResponseWriter, scope *crash.Scope) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	b, path := h.opts.Crash.Report(v, scope)
+	telemetry.Logger(ctx).ErrorContext(ctx, "panic",
+		slog.String("path", scope.Operation),
+		slog.String("panic", b.Panic),
+		slog.String("bundle", path))
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "internal error", "crash_id": b.ID})
+}
+
+// statusRecorder records the status of a response for the request log.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter { return w.ResponseWriter }
+
+// statusError is an error with the HTTP status it is reported with.
+type statusError struct {
+	status int
+	err    error
+}
+
+func (e *statusError) Error() string { return e.err.Error() }
+func (e *statusError) Unwrap() error { return e.err }
+
+// responseError is an error answered with a body of its own.
+type responseError struct {
+	status int
+	body   any
+}
+
+func (e *responseError) Error() string { return http.StatusText(e.status) }
+
+// request is a parsed request body.
+type request struct {
+	ctx    context.Context
+	r      *http.Request
+	source []byte
+	// script is the source parsed a statement at a time, as the other
+	// tools parse files.
+	script *tree_sitter_wabznasm.Script
+}
+
+// endpoint wraps a handler with the method check, size limit, timeout,
+// parse and JSON encoding every endpoint shares. The handler runs in a span
+// called phase.
+func (h *Handler) endpoint(phase string, fn func(*request) (any, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, &statusError{http.StatusMethodNotAllowed, errors.New("use POST")})
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), h.opts.Timeout)
+		defer cancel()
+		req := &request{ctx: ctx, r: r}
+		var err error
+		if req.source, err = readSource(http.MaxBytesReader(w, r.Body, h.opts.MaxRequestBytes), r.Header.Get("Content-Type")); err != nil {
+			writeError(w, err)
+			return
+		}
+		if req.script, err = h.parseScript(ctx, req.source); err != nil {
+			writeError(w, err)
+			return
+		}
+		defer req.script.Close()
+		// Recover here, before the trees are closed, so that the bundle can
+		// describe the statement being worked on.
+		scope := &crash.Scope{Operation: r.URL.Path, RequestID: telemetry.RequestID(ctx), Source: req.source}
+		if len(req.script.Statements) == 1 {
+			scope.Tree = req.script.Statements[0].Tree.Tree
+		}
+		defer h.recover(ctx, w, scope)
+		var span *telemetry.Span
+		req.ctx, span = telemetry.Start(ctx, phase)
+		v, err := fn(req)
+		span.End(err)
+		if err == nil {
+			err = timedOut(ctx.Err())
+		}
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if b, ok := v.(binaryResponse); ok {
+			w.Header().Set("Content-Type", b.contentType)
+			w.Write(b.data)
+			return
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+// binaryResponse is a response sent as is instead of as JSON.
+type binaryResponse struct {
+	contentType string
+	data        []byte
+}
+
+// accepts reports whether r lists the media type mt in its Accept header.
+func accepts(r *http.Request, mt string) bool {
+	for _, v := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(v, ",") {
+			if t, _, err := mime.ParseMediaType(strings.TrimSpace(part)); err == nil && t == mt {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func readSource(r io.Reader, contentType string) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, &statusError{http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit)}
+		}
+		return nil, &statusError{http.StatusBadRequest, err}
+	}
+	if mt, _, _ := mime.ParseMediaType(contentType); mt != "application/json" {
+		return data, nil
+	}
+	var body struct {
+		Source *string `json:"source"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&body); err != nil {
+		return nil, &statusError{http.StatusBadRequest, fmt.Errorf("invalid request: %w", err)}
+	}
+	if body.Source == nil {
+		return nil, &statusError{http.StatusBadRequest, errors.New(`invalid request: missing "source"`)}
+	}
+	return []byte(*body.Source), nil
+}
+
+// parseScript parses src a statement at a time with a parser of the pool.
+func (h *Handler) parseScript(ctx context.Context, src []byte) (*tree_sitter_wabznasm.Script, error) {
+	p, err := h.pool.Get()
+	if err != nil {
+		return nil, err
+	}
+	defer h.pool.Put(p)
+	s, err := p.ParseScriptContext(ctx, src)
+	if err != nil {
+		return nil, timedOut(err)
+	}
+	return s, nil
+}
+
+// timedOut maps the end of a request's context to a 503.
+func timedOut(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return &statusError{http.StatusServiceUnavailable, errors.New("request timed out")}
+	}
+	return err
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var re *responseError
+	if errors.As(err, &re) {
+		writeJSON(w, re.status, re.body)
+		return
+	}
+	status := http.StatusInternalServerError
+	var se *statusError
+	if errors.As(err, &se) {
+		status = se.status
+	}
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// Range is the JSON form of a source range.
+type Range struct {
+	StartByte uint                           `json:"start_byte"`
+	EndByte   uint                           `json:"end_byte"`
+	Start     tree_sitter_wabznasm.PointJSON `json:"start"`
+	End       tree_sitter_wabznasm.PointJSON `json:"end"`
+}
+
+func toRange(r tree_sitter.Range) Range {
+	return Range{
+		StartByte: r.StartByte,
+		EndByte:   r.EndByte,
+		Start:     tree_sitter_wabznasm.PointJSON{Row: r.StartPoint.Row, Column: r.StartPoint.Column},
+		End:       tree_sitter_wabznasm.PointJSON{Row: r.EndPoint.Row, Column: r.EndPoint.Column},
+	}
+}
+
+// Diagnostic is the JSON form of a syntax error.
+type Diagnostic struct {
+	Range    Range    `json:"range"`
+	Severity string   `json:"severity"`
+	Code     string   `json:"code"`
+	Message  string   `json:"message"`
+	Expected []string `json:"expected,omitempty"`
+}
+
+func diagnostics(req *request) []Diagnostic {
+	out := []Diagnostic{}
+	for _, d := range req.script.Diagnostics() {
+		out = append(out, Diagnostic{toRange(d.Range), d.Severity.String(), d.Code, d.Message, d.Expected})
+	}
+	return out
+}
+
+// ParseResponse is the response of /parse.
+type ParseResponse struct {
+	// Trees are the trees of the statements, in source order.
+	Trees       []tree_sitter_wabznasm.NodeJSON `json:"trees"`
+	Diagnostics []Diagnostic                    `json:"diagnostics"`
+}
+
+func (h *Handler) parse(req *request) (any, error) {
+	if accepts(req.r, treebin.ContentType) {
+		// An encoded tree is the tree of a single statement.
+		if len(req.script.Statements) != 1 {
+			return nil, &statusError{http.StatusNotAcceptable, fmt.Errorf("source holds %d statements; %s encodes one", len(req.script.Statements), treebin.ContentType)}
+		}
+		return binaryResponse{treebin.ContentType, treebin.Encode(req.script.Statements[0].Tree.Tree, req.source, treebin.Options{Source: true})}, nil
+	}
+	resp := ParseResponse{Trees: []tree_sitter_wabznasm.NodeJSON{}, Diagnostics: diagnostics(req)}
+	for _, st := range req.script.Statements {
+		resp.Trees = append(resp.Trees, tree_sitter_wabznasm.ToJSON(st.Tree.RootNode(), st.Source))
+	}
+	return resp, nil
+}
+
+// FormatResponse is the response of /format.
+type FormatResponse struct {
+	Source string `json:"source"`
+}
+
+// syntaxErrorResponse is the response of /format for invalid source.
+type syntaxErrorResponse struct {
+	Error       string       `json:"error"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+func (h *Handler) format(req *request) (any, error) {
+	out, err := format.Config{}.Script(req.source)
+	if err != nil {
+		if errors.Is(err, format.ErrSyntax) {
+			return nil, &responseError{http.StatusUnprocessableEntity, syntaxErrorResponse{err.Error(), diagnostics(req)}}
+		}
+		return nil, err
+	}
+	return FormatResponse{string(out)}, nil
+}
+
+// Finding is the JSON form of a lint finding.
+type Finding struct {
+	Rule     string `json:"rule"`
+	Range    Range  `json:"range"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// LintResponse is the response of /lint.
+type LintResponse struct {
+	Findings    []Finding    `json:"findings"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+func (h *Handler) lint(req *request) (any, error) {
+	var rules []lint.Rule
+	if names := req.r.URL.Query().Get("rules"); names != "" {
+		for _, name := range strings.Split(names, ",") {
+			r, ok := lint.Lookup(strings.TrimSpace(name))
+			if !ok {
+				return nil, &statusError{http.StatusBadRequest, fmt.Errorf("unknown rule %q", name)}
+			}
+			rules = append(rules, r)
+		}
+	}
+	resp := LintResponse{Findings: []Finding{}, Diagnostics: diagnostics(req)}
+	for _, f := range lint.RunScript(req.script, rules...) {
+		resp.Findings = append(resp.Findings, Finding{f.Rule, toRange(f.Range), f.Severity.String(), f.Message})
+	}
+	return resp, nil
+}
+
+// Token is the JSON form of a highlighted token.
+type Token struct {
+	Start   uint   `json:"start"`
+	End     uint   `json:"end"`
+	Capture string `json:"capture"`
+	Class   string `json:"class,omitempty"`
+}
+
+// HighlightResponse is the response of /highlight.
+type HighlightResponse struct {
+	Tokens []Token `json:"tokens"`
+}
+
+func (h *Handler) highlight(req *request) (any, error) {
+	if h.hlInit != nil {
+		return nil, h.hlInit
+	}
+	resp := HighlightResponse{Tokens: []Token{}}
+	h.hmu.Lock()
+	defer h.hmu.Unlock()
+	for _, st := range req.script.Statements {
+		for _, t := range h.hl.Tokens(st.Tree.Tree, st.Source) {
+			resp.Tokens = append(resp.Tokens, Token(t))
+		}
+	}
+	return resp, nil
+}
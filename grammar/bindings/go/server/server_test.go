@@ -0,0 +1,241 @@
+package server_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/crash"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/lint"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/server"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/treebin"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+func post(t *testing.T, h http.Handler, path, contentType, body string) (int, map[string]any) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	if contentType != "" {
+		r.Header.Set("Content-Type", contentType)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	var out map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &out); err != nil {
+		t.Fatalf("%s: %v in %q", path, err, w.Body)
+	}
+	return w.Code, out
+}
+
+func TestEndpoints(t *testing.T) {
+	h := server.New(server.Options{})
+	defer h.Close()
+
+	code, out := post(t, h, "/parse", "", "x: 1+2")
+	if trees, _ := out["trees"].([]any); code != 200 || len(trees) != 1 || trees[0].(map[string]any)["kind"] != "source_file" {
+		t.Errorf("/parse = %d %v", code, out)
+	}
+	code, out = post(t, h, "/parse", "", "f: {x+1}\ny: f[2]\n")
+	if trees, _ := out["trees"].([]any); code != 200 || len(trees) != 2 || len(out["diagnostics"].([]any)) != 0 {
+		t.Errorf("/parse of two statements = %d %v", code, out)
+	}
+	code, out = post(t, h, "/parse", "application/json", `{"source":"1+"}`)
+	if d, _ := out["diagnostics"].([]any); code != 200 || len(d) != 1 {
+		t.Errorf("/parse of invalid source = %d %v", code, out)
+	}
+
+	code, out = post(t, h, "/format", "text/plain", "x : 1 + 2")
+	if code != 200 || out["source"] != "x: 1+2\n" {
+		t.Errorf("/format = %d %v", code, out)
+	}
+	code, out = post(t, h, "/format", "", "f: {x + 1}\ny:   f[2]\n")
+	if code != 200 || out["source"] != "f: {x+1}\ny: f[2]\n" {
+		t.Errorf("/format of two statements = %d %v", code, out)
+	}
+	code, out = post(t, h, "/format", "", "x: 1 +")
+	if d, _ := out["diagnostics"].([]any); code != http.StatusUnprocessableEntity || len(d) != 1 || out["error"] == "" {
+		t.Errorf("/format of invalid source = %d %v", code, out)
+	}
+
+	code, out = post(t, h, "/lint?rules=unused-parameter", "", "f: {[x;y] x}")
+	if f, _ := out["findings"].([]any); code != 200 || len(f) != 1 || f[0].(map[string]any)["rule"] != "unused-parameter" {
+		t.Errorf("/lint = %d %v", code, out)
+	}
+	if code, out = post(t, h, "/lint?rules=nope", "", "1"); code != http.StatusBadRequest {
+		t.Errorf("/lint with unknown rule = %d %v", code, out)
+	}
+	if code, out = post(t, h, "/lint", "application/json", `{"src":"1"}`); code != http.StatusBadRequest {
+		t.Errorf("unknown field = %d %v", code, out)
+	}
+
+	code, out = post(t, h, "/highlight", "", "f: {[x] x*2}")
+	if tokens, _ := out["tokens"].([]any); code != 200 || len(tokens) == 0 {
+		t.Errorf("/highlight = %d %v", code, out)
+	}
+	code, out = post(t, h, "/highlight", "", "x: 1\ny: 2\n")
+	tokens, _ := out["tokens"].([]any)
+	if code != 200 || len(tokens) == 0 || tokens[len(tokens)-1].(map[string]any)["start"] != 8.0 {
+		t.Errorf("/highlight of two statements = %d %v", code, out)
+	}
+}
+
+func TestParseBinary(t *testing.T) {
+	h := server.New(server.Options{})
+	defer h.Close()
+	r := httptest.NewRequest(http.MethodPost, "/parse", strings.NewReader("x: 1+2"))
+	r.Header.Set("Accept", "application/json;q=0.5, "+treebin.ContentType)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != 200 || w.Header().Get("Content-Type") != treebin.ContentType {
+		t.Fatalf("/parse = %d %s", w.Code, w.Header().Get("Content-Type"))
+	}
+	tree, err := treebin.Decode(w.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tree.Root.Kind != "source_file" || string(tree.Source) != "x: 1+2" {
+		t.Errorf("tree %+v", tree)
+	}
+
+	r = httptest.NewRequest(http.MethodPost, "/parse", strings.NewReader("x: 1\ny: 2\n"))
+	r.Header.Set("Accept", treebin.ContentType)
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusNotAcceptable {
+		t.Errorf("/parse of two statements = %d %q", w.Code, w.Body)
+	}
+}
+
+func TestDebugMemory(t *testing.T) {
+	h := server.New(server.Options{})
+	defer h.Close()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/debug/memory", nil))
+	var stats memtrack.Stats
+	if err := json.Unmarshal(w.Body.Bytes(), &stats); err != nil || w.Code != 200 {
+		t.Fatalf("/debug/memory = %d %q", w.Code, w.Body)
+	}
+	if _, ok := stats.Objects["parser"]; !ok {
+		t.Errorf("stats = %+v", stats)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	h := server.New(server.Options{MaxRequestBytes: 8})
+	defer h.Close()
+	if code, out := post(t, h, "/parse", "", "x: 1+2+3+4"); code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body = %d %v", code, out)
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/parse", nil))
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "POST" {
+		t.Errorf("GET = %d %v", w.Code, w.Header())
+	}
+
+	slow := server.New(server.Options{Timeout: time.Nanosecond})
+	defer slow.Close()
+	if code, out := post(t, slow, "/parse", "", "1"); code != http.StatusServiceUnavailable {
+		t.Errorf("timed out request = %d %v", code, out)
+	}
+}
+
+func TestCORS(t *testing.T) {
+	h := server.New(server.Options{AllowOrigin: "*"})
+	defer h.Close()
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodOptions, "/format", nil))
+	if w.Code != http.StatusNoContent || w.Header().Get("Access-Control-Allow-Origin") != "*" {
+		t.Errorf("preflight = %d %v", w.Code, w.Header())
+	}
+}
+
+func TestRequestLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := telemetry.NewLogger(&buf, "debug", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := server.New(server.Options{Logger: logger})
+	defer h.Close()
+	r := httptest.NewRequest(http.MethodPost, "/format", strings.NewReader("x : 1"))
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	id := w.Header().Get("X-Request-ID")
+	if w.Code != 200 || len(id) != 32 {
+		t.Fatalf("/format = %d, X-Request-ID %q", w.Code, id)
+	}
+
+	spans := map[string]bool{}
+	var request map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var rec map[string]any
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec["request_id"] != id {
+			t.Errorf("record %v lacks request_id %s", rec, id)
+		}
+		if rec["msg"] == "span" {
+			spans[rec["span"].(string)] = true
+		} else if rec["msg"] == "request" && rec["level"] == slog.LevelInfo.String() {
+			request = rec
+		}
+	}
+	if !spans["request"] || !spans["parse"] || !spans["format"] {
+		t.Errorf("spans = %v", spans)
+	}
+	if request["path"] != "/format" || request["status"] != 200.0 || request["method"] != "POST" {
+		t.Errorf("request record = %v", request)
+	}
+}
+
+// panicRule panics on sources mentioning kaboom.
+type panicRule struct{}
+
+func (panicRule) Name() string { return "test-panic" }
+func (panicRule) Doc() string  { return "panics on kaboom" }
+func (panicRule) Check(_ *tree_sitter.Tree, src []byte) []lint.Finding {
+	if strings.Contains(string(src), "kaboom") {
+		panic("rule exploded")
+	}
+	return nil
+}
+
+func TestPanic(t *testing.T) {
+	lint.Register(panicRule{})
+	dir := t.TempDir()
+	h := server.New(server.Options{Crash: &crash.Reporter{Dir: dir}})
+	defer h.Close()
+
+	code, out := post(t, h, "/lint?rules=test-panic", "", "kaboom: 1")
+	if code != http.StatusInternalServerError || out["error"] != "internal error" || out["crash_id"] == "" {
+		t.Fatalf("/lint = %d %v", code, out)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || !strings.Contains(entries[0].Name(), out["crash_id"].(string)) {
+		t.Fatalf("bundles = %v", entries)
+	}
+	data, _ := os.ReadFile(dir + "/" + entries[0].Name())
+	var b crash.Bundle
+	if err := json.Unmarshal(data, &b); err != nil || b.Operation != "/lint" || b.Panic != "rule exploded" || b.Tree == "" || b.Source.Text != nil {
+		t.Errorf("bundle = %+v, %v", b, err)
+	}
+
+	// The handler goes on serving.
+	if code, out := post(t, h, "/lint?rules=test-panic", "", "x: 1"); code != 200 {
+		t.Errorf("/lint after a panic = %d %v", code, out)
+	}
+}
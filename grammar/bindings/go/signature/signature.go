@@ -0,0 +1,127 @@
+// Package signature describes the function being called at a cursor, for
+// signature help in editors and hints in the REPL.
+//
+// The call is found from the tokens before the cursor rather than from the
+// tree, so a call whose arguments are still being typed, and which does not
+// parse yet, is found as well.
+package signature
+
+import (
+	"strings"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/scopes"
+)
+
+// Help describes a call enclosing the cursor.
+type Help struct {
+	// Name is the function called.
+	Name string
+	// Params are the parameters the function takes; for an implicit
+	// function, x, y and z up to the highest its body mentions.
+	Params []string
+	// Active is the zero-based index of the argument the cursor is in. It
+	// may exceed the last parameter when too many arguments are written.
+	Active int
+	// Open is the byte offset of the call's left bracket.
+	Open uint
+}
+
+// Label renders the signature as the call would be written, such as
+// "add[x;y]".
+func (h *Help) Label() string {
+	return h.Name + "[" + strings.Join(h.Params, ";") + "]"
+}
+
+// ParamOffsets returns the byte range of parameter i within Label.
+func (h *Help) ParamOffsets(i int) (start, end int) {
+	start = len(h.Name) + 1
+	for _, p := range h.Params[:i] {
+		start += len(p) + 1
+	}
+	return start, start + len(h.Params[i])
+}
+
+// Index resolves the signatures of functions defined in other sources.
+type Index interface {
+	// Signature returns the parameters of the global function name.
+	Signature(name string) (params []string, ok bool)
+}
+
+// At returns the innermost call whose brackets enclose point, or nil if
+// there is none or its function cannot be resolved in the buffer.
+func At(tree *tree_sitter.Tree, source []byte, point tree_sitter.Point) *Help {
+	return AtIn(nil, tree, source, point)
+}
+
+// AtIn is like At, looking up functions the buffer does not define in idx
+// when it is non-nil.
+func AtIn(idx Index, tree *tree_sitter.Tree, source []byte, point tree_sitter.Point) *Help {
+	offset := tree_sitter_wabznasm.OffsetForPoint(source, point)
+	callee, open, active, ok := enclosingCall(tree_sitter_wabznasm.Tokens(tree, source), offset)
+	if !ok {
+		return nil
+	}
+	h := &Help{Name: callee.Text, Active: active, Open: open}
+	tab := scopes.FromTree(tree, source)
+	sym := tab.ScopeAt(callee.Range.StartByte).Lookup(h.Name)
+	switch {
+	case sym != nil && (sym.Kind == scopes.Param || sym.Kind == scopes.Implicit):
+		// A function passed in as an argument; its signature is unknown.
+		return nil
+	case sym != nil && sym.Func != nil:
+		h.Params = sym.Func.Signature()
+	case idx != nil:
+		if h.Params, ok = idx.Signature(h.Name); !ok {
+			return nil
+		}
+	default:
+		return nil
+	}
+	return h
+}
+
+// enclosingCall scans the tokens before offset backwards for the innermost
+// call left open at offset, returning its function name, the offset of its
+// left bracket and the number of argument separators before offset.
+func enclosingCall(toks []tree_sitter_wabznasm.Token, offset uint) (callee tree_sitter_wabznasm.Token, open uint, active int, ok bool) {
+	var prev []tree_sitter_wabznasm.Token
+	for _, t := range toks {
+		if t.Range.StartByte >= offset {
+			break
+		}
+		if t.IsComment() && offset <= t.Range.EndByte {
+			return callee, 0, 0, false
+		}
+		if t.Range.EndByte > offset {
+			break
+		}
+		if !t.Extra && !t.IsWhitespace() {
+			prev = append(prev, t)
+		}
+	}
+	depth := 0
+	for i := len(prev) - 1; i >= 0; i-- {
+		switch prev[i].Kind {
+		case "]", ")", "}":
+			depth++
+		case ";":
+			if depth == 0 {
+				active++
+			}
+		case "[", "(", "{":
+			if depth > 0 {
+				depth--
+				continue
+			}
+			if prev[i].Kind == "[" && i > 0 && prev[i-1].Kind == tree_sitter_wabznasm.KindIdentifier {
+				return prev[i-1], prev[i].Range.StartByte, active, true
+			}
+			// The cursor is inside a parenthesized expression, a function
+			// body or a parameter list, which may itself be an argument.
+			active = 0
+		}
+	}
+	return callee, 0, 0, false
+}
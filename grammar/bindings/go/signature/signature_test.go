@@ -0,0 +1,85 @@
+package signature_test
+
+import (
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/signature"
+)
+
+func parse(t *testing.T, src string) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(parser.Close)
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+type index map[string][]string
+
+func (ix index) Signature(name string) ([]string, bool) {
+	params, ok := ix[name]
+	return params, ok
+}
+
+// at returns the help at the | in src.
+func at(t *testing.T, idx signature.Index, src string) *signature.Help {
+	t.Helper()
+	offset := strings.Index(src, "|")
+	src = src[:offset] + src[offset+1:]
+	point := tree_sitter_wabznasm.PointForOffset([]byte(src), uint(offset))
+	return signature.AtIn(idx, parse(t, src).Tree, []byte(src), point)
+}
+
+func TestAt(t *testing.T) {
+	idx := index{"add": {"x", "y"}, "neg": {"x"}}
+	tests := []struct {
+		src    string
+		label  string
+		active int
+	}{
+		{"add[|1;2]", "add[x;y]", 0},
+		{"add[1;|2]", "add[x;y]", 1},
+		{"add[1;2|]", "add[x;y]", 1},
+		{"add[1;|", "add[x;y]", 1},
+		{"add[neg[|3];4]", "neg[x]", 0},
+		{"add[neg[3];|", "add[x;y]", 1},
+		{"add[(1+|2);3]", "add[x;y]", 0},
+		{"add[1;2;|", "add[x;y]", 2},
+		{"fact: {[n] n*fact[|n-1]}", "fact[n]", 0},
+		{"twice: {x[|y]}", "", 0},
+		{"add[1;2]|", "", 0},
+		{"add[1;2] \\ neg[|", "", 0},
+		{"mul[|", "", 0},
+		{"f: {[a;|b] a}", "", 0},
+	}
+	for _, tt := range tests {
+		h := at(t, idx, tt.src)
+		var label string
+		if h != nil {
+			label = h.Label()
+		}
+		if label != tt.label || h != nil && h.Active != tt.active {
+			t.Errorf("AtIn(%q) = %+v, want %q active %d", tt.src, h, tt.label, tt.active)
+		}
+	}
+}
+
+func TestAtImplicit(t *testing.T) {
+	h := at(t, nil, "sq: {x*sq[|x-1]+y}")
+	if h == nil || h.Label() != "sq[x;y]" || h.Open != 9 {
+		t.Fatalf("At = %+v", h)
+	}
+	if start, end := h.ParamOffsets(1); h.Label()[start:end] != "y" {
+		t.Errorf("ParamOffsets(1) = %d, %d", start, end)
+	}
+}
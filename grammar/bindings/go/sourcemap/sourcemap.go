@@ -0,0 +1,175 @@
+// Package sourcemap relates positions in a source to positions in a
+// version of it produced by formatting or rewriting, so that a diagnostic
+// computed on one can be shown on the other.
+//
+// A Map is built from segments: pairs of byte ranges, one in the old text
+// and one in the new, that correspond. The segments of a map partition
+// both texts in order. A segment whose ranges have the same length, such
+// as a token the formatter kept, maps offsets inside it one to one; any
+// other segment, such as a replaced node or the whitespace between two
+// tokens, maps its interior to its edges.
+package sourcemap
+
+import (
+	"sort"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// Span is the byte range [Start, End).
+type Span struct {
+	Start, End uint
+}
+
+// Len returns the length of s.
+func (s Span) Len() uint { return s.End - s.Start }
+
+// Segment is a range of the old text and the range of the new text it
+// became.
+type Segment struct {
+	Old, New Span
+}
+
+func (s Segment) exact() bool { return s.Old.Len() == s.New.Len() }
+
+// Map maps offsets of an old text to offsets of a new one. A map made by
+// Then applies each of its steps in turn.
+type Map struct {
+	steps [][]Segment
+}
+
+// Identity returns the map of a text of n bytes to itself.
+func Identity(n uint) *Map {
+	return &Map{steps: [][]Segment{{{Old: Span{0, n}, New: Span{0, n}}}}}
+}
+
+// Builder collects the segments of a map. Segments are added in order of
+// both texts; the ranges between them are filled in by Map.
+type Builder struct {
+	segs []Segment
+}
+
+// Add records that the old range [oldStart, oldEnd) became the new range
+// [newStart, newEnd). Ranges must not start before those of the segment
+// added last end.
+func (b *Builder) Add(oldStart, oldEnd, newStart, newEnd uint) {
+	b.segs = append(b.segs, Segment{Old: Span{oldStart, oldEnd}, New: Span{newStart, newEnd}})
+}
+
+// Map returns the map of an old text of oldLen bytes to a new text of
+// newLen bytes. The ranges between the segments added correspond to each
+// other, and adjacent one-to-one segments are merged.
+func (b *Builder) Map(oldLen, newLen uint) *Map {
+	var segs []Segment
+	var at Segment
+	push := func(s Segment) {
+		if s.Old.Len() == 0 && s.New.Len() == 0 {
+			return
+		}
+		if n := len(segs); n > 0 && s.exact() && segs[n-1].exact() {
+			segs[n-1].Old.End, segs[n-1].New.End = s.Old.End, s.New.End
+			return
+		}
+		segs = append(segs, s)
+	}
+	for _, s := range append(b.segs, Segment{Old: Span{oldLen, oldLen}, New: Span{newLen, newLen}}) {
+		push(Segment{Old: Span{at.Old.End, s.Old.Start}, New: Span{at.New.End, s.New.Start}})
+		push(s)
+		at = s
+	}
+	return &Map{steps: [][]Segment{segs}}
+}
+
+// Offset returns the offset of the new text that old offset off became.
+// An offset inside a segment that was not kept as it was maps to the
+// segment's start, and an offset past the old text to the end of the new.
+func (m *Map) Offset(off uint) uint {
+	for _, segs := range m.steps {
+		off = startOf(segs, off)
+	}
+	return off
+}
+
+// Range returns the new range that the old range [start, end) became. It
+// covers the whole of any segment that the old range reaches into but
+// that was not kept as it was.
+func (m *Map) Range(start, end uint) (uint, uint) {
+	for _, segs := range m.steps {
+		start = startOf(segs, start)
+		end = max(start, endOf(segs, end))
+	}
+	return start, end
+}
+
+// Project returns the range of the new text, whose bytes are dst, that r,
+// a range of the old text, became.
+func (m *Map) Project(r tree_sitter.Range, dst []byte) tree_sitter.Range {
+	start, end := m.Range(r.StartByte, r.EndByte)
+	return tree_sitter.Range{
+		StartByte:  start,
+		EndByte:    end,
+		StartPoint: tree_sitter_wabznasm.PointForOffset(dst, start),
+		EndPoint:   tree_sitter_wabznasm.PointForOffset(dst, end),
+	}
+}
+
+// Invert returns the map of the new text back to the old.
+func (m *Map) Invert() *Map {
+	inv := &Map{steps: make([][]Segment, len(m.steps))}
+	for i, segs := range m.steps {
+		out := make([]Segment, len(segs))
+		for j, s := range segs {
+			out[j] = Segment{Old: s.New, New: s.Old}
+		}
+		inv.steps[len(m.steps)-1-i] = out
+	}
+	return inv
+}
+
+// Then returns the map that applies m and then next, whose old text is
+// m's new text.
+func (m *Map) Then(next *Map) *Map {
+	steps := append(append([][]Segment(nil), m.steps...), next.steps...)
+	return &Map{steps: steps}
+}
+
+// startOf maps off through segs, keeping it at the start of a segment
+// that was not kept as it was. It lands after text inserted at off.
+func startOf(segs []Segment, off uint) uint {
+	i := sort.Search(len(segs), func(i int) bool { return segs[i].Old.End > off })
+	if i == len(segs) {
+		return pastEnd(segs, off)
+	}
+	s := segs[i]
+	if s.exact() {
+		return s.New.Start + off - s.Old.Start
+	}
+	return s.New.Start
+}
+
+// endOf maps off, the end of a range, through segs, moving it to the end
+// of a segment that was not kept as it was. It lands before text inserted
+// at off.
+func endOf(segs []Segment, off uint) uint {
+	i := sort.Search(len(segs), func(i int) bool { return segs[i].Old.End >= off })
+	if i == len(segs) {
+		return pastEnd(segs, off)
+	}
+	s := segs[i]
+	if off <= s.Old.Start {
+		return s.New.Start
+	}
+	if s.exact() {
+		return s.New.Start + off - s.Old.Start
+	}
+	return s.New.End
+}
+
+// pastEnd maps an offset at or past the end of the old text of segs.
+func pastEnd(segs []Segment, off uint) uint {
+	if len(segs) == 0 {
+		return off
+	}
+	return segs[len(segs)-1].New.End
+}
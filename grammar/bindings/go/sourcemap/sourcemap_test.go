@@ -0,0 +1,91 @@
+package sourcemap_test
+
+import (
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/sourcemap"
+)
+
+// reformat maps "x :  42" to "x: 42": both tokens are kept and the
+// whitespace between them changes.
+func reformat() *sourcemap.Map {
+	var b sourcemap.Builder
+	b.Add(0, 1, 0, 1) // x
+	b.Add(2, 3, 1, 2) // :
+	b.Add(5, 7, 3, 5) // 42
+	return b.Map(7, 5)
+}
+
+func TestOffset(t *testing.T) {
+	m := reformat()
+	tests := []struct{ old, want uint }{
+		{0, 0}, {2, 1}, {5, 3}, {6, 4}, {7, 5}, {99, 5},
+		// Inside the whitespace before 42: its start.
+		{4, 2},
+	}
+	for _, tt := range tests {
+		if got := m.Offset(tt.old); got != tt.want {
+			t.Errorf("Offset(%d) = %d, want %d", tt.old, got, tt.want)
+		}
+	}
+	inv := m.Invert()
+	for _, tt := range []struct{ new, want uint }{{0, 0}, {1, 2}, {3, 5}, {4, 6}, {5, 7}} {
+		if got := inv.Offset(tt.new); got != tt.want {
+			t.Errorf("Invert().Offset(%d) = %d, want %d", tt.new, got, tt.want)
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	// "a+b" to "a+(b)" to "a+(c)": the second step replaces b.
+	var wrap sourcemap.Builder
+	wrap.Add(2, 2, 2, 3)
+	wrap.Add(3, 3, 4, 5)
+	var rename sourcemap.Builder
+	rename.Add(3, 4, 3, 4)
+	m := wrap.Map(3, 5).Then(rename.Map(5, 5))
+
+	tests := []struct{ start, end, wantStart, wantEnd uint }{
+		{0, 1, 0, 1},
+		// A range ending at an insertion stops before it; one starting
+		// there begins after it.
+		{0, 2, 0, 2},
+		{2, 3, 3, 4},
+		{0, 3, 0, 4},
+		{3, 3, 5, 5},
+	}
+	for _, tt := range tests {
+		start, end := m.Range(tt.start, tt.end)
+		if start != tt.wantStart || end != tt.wantEnd {
+			t.Errorf("Range(%d, %d) = %d, %d, want %d, %d", tt.start, tt.end, start, end, tt.wantStart, tt.wantEnd)
+		}
+	}
+	if start, end := m.Invert().Range(2, 5); start != 2 || end != 3 {
+		t.Errorf("Invert().Range(2, 5) = %d, %d, want 2, 3", start, end)
+	}
+}
+
+func TestReplacementCovered(t *testing.T) {
+	// "f[x]" to "f[(x*2)]": the argument is replaced as a whole.
+	var b sourcemap.Builder
+	b.Add(2, 3, 2, 7)
+	m := b.Map(4, 8)
+	if start, end := m.Range(2, 3); start != 2 || end != 7 {
+		t.Errorf("Range(2, 3) = %d, %d, want 2, 7", start, end)
+	}
+	if got := m.Invert().Offset(5); got != 2 {
+		t.Errorf("Invert().Offset(5) = %d, want 2", got)
+	}
+	if start, end := m.Invert().Range(3, 6); start != 2 || end != 3 {
+		t.Errorf("Invert().Range(3, 6) = %d, %d, want 2, 3", start, end)
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	m := sourcemap.Identity(4)
+	for off := uint(0); off <= 4; off++ {
+		if got := m.Offset(off); got != off {
+			t.Errorf("Offset(%d) = %d", off, got)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package tree_sitter_wabznasm
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+// ChunkSize is the number of bytes handed to tree-sitter per read when
+// parsing streamed input.
+const ChunkSize = 64 << 10
+
+// ErrStreamSeek is returned by ParseStream when tree-sitter asks for input
+// that has already been discarded from a reader that cannot seek.
+var ErrStreamSeek = errors.New("wabznasm: stream input read out of order")
+
+// ParseReaderAt parses the contents of r, reading it in ChunkSize pieces as
+// tree-sitter asks for them instead of loading it whole. Reparsing with old
+// reads only around the edited regions. The tree does not retain the source,
+// so callers need r to recover node text.
+//
+// The go-tree-sitter binding keeps a C copy of every chunk until the parse
+// returns, so peak memory is still about the input size during the call;
+// the Go heap, and the source after the call, stay small.
+func (p *Parser) ParseReaderAt(r io.ReaderAt, old *tree_sitter.Tree) (*memtrack.TrackedTree, error) {
+	var readErr error
+	buf := make([]byte, ChunkSize)
+	return p.parseInput(func(offset int, _ tree_sitter.Point) []byte {
+		n, err := r.ReadAt(buf, int64(offset))
+		if err != nil && err != io.EOF && readErr == nil {
+			readErr = err
+		}
+		return buf[:n]
+	}, old, &readErr)
+}
+
+// ParseStream parses the contents of r in ChunkSize pieces without loading
+// it whole. Readers that implement io.ReaderAt, such as *os.File, are read
+// with ParseReaderAt. Other readers are consumed once, front to back,
+// keeping only the current and previous chunk for the short look-backs the
+// lexer makes; a read further back fails with ErrStreamSeek.
+func (p *Parser) ParseStream(r io.Reader) (*memtrack.TrackedTree, error) {
+	if ra, ok := r.(io.ReaderAt); ok {
+		return p.ParseReaderAt(ra, nil)
+	}
+	s := &stream{r: r}
+	return p.parseInput(s.chunk, nil, &s.err)
+}
+
+func (p *Parser) parseInput(read func(int, tree_sitter.Point) []byte, old *tree_sitter.Tree, readErr *error) (*memtrack.TrackedTree, error) {
+	tree, err := p.parse(context.Background(), read, old)
+	if *readErr != nil {
+		if tree != nil {
+			tree.Close()
+		}
+		return nil, *readErr
+	}
+	return tree, err
+}
+
+// stream serves tree-sitter reads from a forward-only reader. window holds
+// the input from offset base onwards.
+type stream struct {
+	r      io.Reader
+	window []byte
+	base   int
+	eof    bool
+	err    error
+}
+
+func (s *stream) chunk(offset int, _ tree_sitter.Point) []byte {
+	if s.err != nil {
+		return nil
+	}
+	if offset < s.base {
+		s.err = ErrStreamSeek
+		return nil
+	}
+	for offset >= s.base+len(s.window) && !s.eof {
+		s.fill()
+	}
+	if s.err != nil || offset >= s.base+len(s.window) {
+		return nil
+	}
+	return s.window[offset-s.base:]
+}
+
+// fill reads the next chunk, dropping all but the last chunk of the window.
+func (s *stream) fill() {
+	if keep := len(s.window) - ChunkSize; keep > 0 {
+		s.base += keep
+		s.window = append(s.window[:0], s.window[keep:]...)
+	}
+	start := len(s.window)
+	s.window = append(s.window, make([]byte, ChunkSize)...)
+	n, err := io.ReadFull(s.r, s.window[start:])
+	s.window = s.window[:start+n]
+	switch err {
+	case nil:
+	case io.EOF, io.ErrUnexpectedEOF:
+		s.eof = true
+	default:
+		s.err = err
+	}
+}
@@ -0,0 +1,65 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+// largeSource spans several chunks.
+func largeSource() []byte {
+	return []byte("total: 0" + strings.Repeat("\n  \\ a comment spanning the rest of the line\n  +f[1;2]*3", 4000))
+}
+
+func TestParseStream(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+
+	src := largeSource()
+	if len(src) < 3*tree_sitter_wabznasm.ChunkSize {
+		t.Fatalf("source too small: %d bytes", len(src))
+	}
+	want, err := parser.ParseBytes(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer want.Close()
+
+	readers := map[string]io.Reader{
+		"ReaderAt": bytes.NewReader(src),
+		// HalfReader hides ReadAt and returns short reads.
+		"Reader": iotest.HalfReader(bytes.NewReader(src)),
+	}
+	for name, r := range readers {
+		tree, err := parser.ParseStream(r)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if tree.RootNode().ToSexp() != want.RootNode().ToSexp() {
+			t.Errorf("%s: streamed tree differs from ParseBytes", name)
+		}
+		tree.Close()
+	}
+}
+
+func TestParseStreamError(t *testing.T) {
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+
+	r := iotest.TimeoutReader(bytes.NewReader(largeSource()))
+	if _, err := parser.ParseStream(r); !errors.Is(err, iotest.ErrTimeout) {
+		t.Errorf("ParseStream = %v, want %v", err, iotest.ErrTimeout)
+	}
+}
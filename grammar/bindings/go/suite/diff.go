@@ -0,0 +1,99 @@
+package suite
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/trace"
+)
+
+// maxDiffLines bounds the differing elements a diff lists.
+const maxDiffLines = 10
+
+// equal is eval.Equal, taking numbers within tolerance of each other as
+// equal when either is a float.
+func equal(a, b eval.Value, tolerance float64) bool {
+	la, aList := a.(eval.List)
+	lb, bList := b.(eval.List)
+	if aList || bList {
+		if !aList || !bList || len(la) != len(lb) {
+			return false
+		}
+		for i := range la {
+			if !equal(la[i], lb[i], tolerance) {
+				return false
+			}
+		}
+		return true
+	}
+	_, aFloat := a.(eval.Float)
+	_, bFloat := b.(eval.Float)
+	if aFloat || bFloat {
+		x, okA := number(a)
+		y, okB := number(b)
+		return okA && okB && (x == y || math.Abs(x-y) <= tolerance)
+	}
+	return eval.Equal(a, b)
+}
+
+func number(v eval.Value) (float64, bool) {
+	switch v := v.(type) {
+	case eval.Long:
+		return float64(v), true
+	case eval.Float:
+		return float64(v), true
+	}
+	return 0, false
+}
+
+// Diff describes how actual differs from expected: the two values, and
+// for lists the elements that differ, by index path, such as [1][0].
+func Diff(actual, expected eval.Value, tolerance float64) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "got:  %s\nwant: %s\n", actual, expected)
+	var lines []string
+	diffList(&lines, "", actual, expected, tolerance)
+	if len(lines) == 1 && lines[0] == "" {
+		// The values differ as wholes, which the two lines show.
+		return b.String()
+	}
+	for i, l := range lines {
+		if i == maxDiffLines {
+			fmt.Fprintf(&b, "  … and %d more\n", len(lines)-i)
+			break
+		}
+		fmt.Fprintf(&b, "  %s\n", l)
+	}
+	return b.String()
+}
+
+// diffList appends the differences at path between a and b to lines,
+// an empty line if they differ as wholes at the top.
+func diffList(lines *[]string, path string, a, b eval.Value, tolerance float64) {
+	if equal(a, b, tolerance) {
+		return
+	}
+	la, aList := a.(eval.List)
+	lb, bList := b.(eval.List)
+	switch {
+	case aList && bList && len(la) == len(lb):
+		for i := range la {
+			diffList(lines, fmt.Sprintf("%s[%d]", path, i), la[i], lb[i], tolerance)
+		}
+	case aList && bList:
+		*lines = append(*lines, fmt.Sprintf("%slength: got %d, want %d", prefix(path), len(la), len(lb)))
+	case path == "":
+		*lines = append(*lines, "")
+	default:
+		*lines = append(*lines, fmt.Sprintf("%s: got %s, want %s", path, trace.Brief(a), trace.Brief(b)))
+	}
+}
+
+func prefix(path string) string {
+	if path == "" {
+		return ""
+	}
+	return path + " "
+}
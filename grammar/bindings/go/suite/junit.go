@@ -0,0 +1,107 @@
+package suite
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// The JUnit XML format, as CI services read it: a suite for each test
+// file and a test case for each of its cases. A file that could not be
+// run is a suite with one erroring case.
+type (
+	junitSuites struct {
+		XMLName  xml.Name     `xml:"testsuites"`
+		Tests    int          `xml:"tests,attr"`
+		Failures int          `xml:"failures,attr"`
+		Errors   int          `xml:"errors,attr"`
+		Time     string       `xml:"time,attr"`
+		Suites   []junitSuite `xml:"testsuite"`
+	}
+	junitSuite struct {
+		Name     string      `xml:"name,attr"`
+		Tests    int         `xml:"tests,attr"`
+		Failures int         `xml:"failures,attr"`
+		Errors   int         `xml:"errors,attr"`
+		Time     string      `xml:"time,attr"`
+		Cases    []junitCase `xml:"testcase"`
+	}
+	junitCase struct {
+		Name      string        `xml:"name,attr"`
+		Classname string        `xml:"classname,attr"`
+		File      string        `xml:"file,attr,omitempty"`
+		Line      int           `xml:"line,attr,omitempty"`
+		Time      string        `xml:"time,attr"`
+		Failure   *junitProblem `xml:"failure"`
+		Error     *junitProblem `xml:"error"`
+	}
+	junitProblem struct {
+		Message string `xml:"message,attr"`
+		Type    string `xml:"type,attr"`
+		Body    string `xml:",chardata"`
+	}
+)
+
+// WriteJUnit writes results as a JUnit XML report.
+func WriteJUnit(w io.Writer, results []*Result) error {
+	out := junitSuites{}
+	var total time.Duration
+	for _, r := range results {
+		s := junitSuite{Name: r.Path, Time: seconds(r.Duration)}
+		for _, c := range r.Cases {
+			jc := junitCase{
+				Name:      c.Name,
+				Classname: r.Path,
+				File:      r.Path,
+				Line:      int(c.Pos.Row) + 1,
+				Time:      seconds(c.Duration),
+			}
+			switch {
+			case c.Err != nil:
+				jc.Error = &junitProblem{Message: c.Err.Error(), Type: "error", Body: c.Err.Error()}
+				s.Errors++
+			case len(c.Failures) > 0:
+				var body strings.Builder
+				for _, f := range c.Failures {
+					fmt.Fprintf(&body, "%s: %s\n%s", f.Assertion, f.Message, f.Diff)
+				}
+				jc.Failure = &junitProblem{Message: c.Failures[0].Message, Type: c.Failures[0].Assertion, Body: body.String()}
+				s.Failures++
+			}
+			s.Cases = append(s.Cases, jc)
+		}
+		if r.Err != nil {
+			s.Cases = append(s.Cases, junitCase{
+				Name:      r.Path,
+				Classname: r.Path,
+				File:      r.Path,
+				Time:      "0.000",
+				Error:     &junitProblem{Message: r.Err.Error(), Type: "error", Body: r.Err.Error()},
+			})
+			s.Errors++
+		}
+		s.Tests = len(s.Cases)
+		out.Tests += s.Tests
+		out.Failures += s.Failures
+		out.Errors += s.Errors
+		total += r.Duration
+		out.Suites = append(out.Suites, s)
+	}
+	out.Time = seconds(total)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,320 @@
+// Package suite runs wabznasm test files.
+//
+// A test file is a script whose name ends in _test before its extension,
+// such as rates_test.wz. It is evaluated statement by statement in a
+// fresh interpreter, after the library it tests, rates.wz, if that is
+// beside it. Assertions are builtins the runner binds: assert[cond]
+// passes if cond is non-zero, every element of it if it is a list, and
+// expect[actual; expected] passes if the two are equal. Each statement
+// calling assertions, directly or through a function it calls, is a test
+// case, failing if any of its assertions fail; so is each statement that
+// fails to evaluate, such as a setup step of the file.
+package suite
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/project"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/repl"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/trace"
+)
+
+// Suffix ends the base name of a test file, before its extension.
+const Suffix = "_test"
+
+// IsTest reports whether path names a test file.
+func IsTest(path string) bool {
+	return project.IsSource(path) && strings.HasSuffix(strings.TrimSuffix(path, filepath.Ext(path)), Suffix)
+}
+
+// Discover returns the test files among paths, in order: files are taken
+// as they are, and directories are searched recursively, skipping hidden
+// ones.
+func Discover(paths []string) ([]string, error) {
+	var out []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			out = append(out, root)
+			continue
+		}
+		var found []string
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if path != root && len(d.Name()) > 1 && d.Name()[0] == '.' {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if IsTest(path) {
+				found = append(found, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		out = append(out, found...)
+	}
+	return out, nil
+}
+
+// Library returns the path of the library tested by the test file at
+// path, or "" if there is none beside it.
+func Library(path string) string {
+	base := strings.TrimSuffix(strings.TrimSuffix(path, filepath.Ext(path)), Suffix)
+	for _, ext := range project.Extensions {
+		if info, err := os.Stat(base + ext); err == nil && !info.IsDir() {
+			return base + ext
+		}
+	}
+	return ""
+}
+
+// Config configures the runner. The zero Config binds assert and expect,
+// comparing exactly, alongside the registered builtins.
+type Config struct {
+	// Assert names the builtins of one argument that pass if it is
+	// non-zero; nil means assert.
+	Assert []string
+	// Expect names the builtins of two arguments, the actual and the
+	// expected value, that pass if the two are equal; nil means expect.
+	Expect []string
+	// Tolerance is the largest difference between floats, or between a
+	// float and a long, that expect takes as equal.
+	Tolerance float64
+	// Builtins are bound in each interpreter besides the assertions; nil
+	// means those registered with eval.
+	Builtins []*eval.Builtin
+	// Limits caps the resources of each statement.
+	Limits eval.Limits
+	// Instrument, if set, is called with each interpreter before the
+	// files are evaluated, to attach a tracer or coverage profile.
+	Instrument func(in *eval.Interpreter)
+	// RunScript evaluates a file as repl.RunScript does; nil means
+	// repl.RunScript. Coverage profiles offer one attributing the
+	// evaluations to the file at path.
+	RunScript func(ctx context.Context, in *eval.Interpreter, path string, src []byte, report func(*repl.Statement) bool) error
+}
+
+// Result is the outcome of a test file.
+type Result struct {
+	Path string
+	// Library is the path of the library evaluated first, if any.
+	Library string
+	Cases   []Case
+	// Err reports a file that could not be read or parsed, or a statement
+	// of the library that failed, which stops the file before its cases.
+	Err      error
+	Duration time.Duration
+}
+
+// Failed counts the cases that failed, with an assertion or an error.
+func (r *Result) Failed() int {
+	n := 0
+	for _, c := range r.Cases {
+		if !c.Passed() {
+			n++
+		}
+	}
+	return n
+}
+
+// Passed reports whether the file ran and every case passed.
+func (r *Result) Passed() bool { return r.Err == nil && r.Failed() == 0 }
+
+// Case is a statement of a test file that called assertions or failed.
+type Case struct {
+	// Name is the text of the statement, on one line.
+	Name string
+	Pos  ast.Pos
+	// Assertions counts the assertions the statement made.
+	Assertions int
+	Failures   []Failure
+	// Err is the error the statement failed to evaluate with.
+	Err      error
+	Duration time.Duration
+}
+
+// Passed reports whether the case made no failing assertion and
+// evaluated without error.
+func (c *Case) Passed() bool { return c.Err == nil && len(c.Failures) == 0 }
+
+// Failure is a failed assertion.
+type Failure struct {
+	// Assertion names the builtin that failed.
+	Assertion string
+	// Message summarises the failure, and Diff, if there is one, shows
+	// the expected and the actual value and where they differ.
+	Message string
+	Diff    string
+}
+
+// Run evaluates the test file at path and returns its outcome.
+func Run(ctx context.Context, cfg Config, path string) *Result {
+	start := time.Now()
+	r := &Result{Path: path, Library: Library(path)}
+	defer func() { r.Duration = time.Since(start) }()
+
+	var failures []Failure
+	assertions := 0
+	record := func(f *Failure) {
+		assertions++
+		if f != nil {
+			failures = append(failures, *f)
+		}
+	}
+	builtins := cfg.Builtins
+	if builtins == nil {
+		builtins = eval.Builtins()
+	}
+	in := eval.NewWith(append(cfg.assertions(record), builtins...)...)
+	in.Limits = cfg.Limits
+	if cfg.Instrument != nil {
+		cfg.Instrument(in)
+	}
+	runScript := cfg.RunScript
+	if runScript == nil {
+		runScript = func(ctx context.Context, in *eval.Interpreter, _ string, src []byte, report func(*repl.Statement) bool) error {
+			return repl.RunScript(ctx, in, src, report)
+		}
+	}
+
+	if r.Library != "" {
+		var failed error
+		src, err := os.ReadFile(r.Library)
+		if err == nil {
+			err = runScript(ctx, in, r.Library, src, func(st *repl.Statement) bool {
+				if st.Err != nil {
+					failed = fmt.Errorf("%s:%v", r.Library, st.Err)
+				}
+				return st.Err == nil
+			})
+		}
+		if err == nil {
+			err = failed
+		}
+		if err != nil {
+			r.Err = err
+			return r
+		}
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+	caseStart := time.Now()
+	r.Err = runScript(ctx, in, path, src, func(st *repl.Statement) bool {
+		elapsed := time.Since(caseStart)
+		if assertions > 0 || st.Err != nil {
+			c := Case{Name: caseName(st.Text), Assertions: assertions, Failures: failures, Err: st.Err, Duration: elapsed}
+			if st.Stmt != nil {
+				c.Pos = st.Stmt.Pos()
+			} else {
+				c.Pos = position(src, st.Offset)
+			}
+			r.Cases = append(r.Cases, c)
+		}
+		failures, assertions = nil, 0
+		caseStart = time.Now()
+		return ctx.Err() == nil
+	})
+	return r
+}
+
+// caseName returns the text of a statement on one line.
+func caseName(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// position returns the position of offset in src, counting columns in
+// bytes.
+func position(src []byte, offset int) ast.Pos {
+	p := ast.Pos{Offset: uint(offset)}
+	for _, c := range src[:offset] {
+		if c == '\n' {
+			p.Row++
+			p.Column = 0
+		} else {
+			p.Column++
+		}
+	}
+	return p
+}
+
+// assertions returns the assertion builtins of cfg, which pass the
+// outcome of each assertion to record, nil for one that passed.
+func (cfg Config) assertions(record func(*Failure)) []*eval.Builtin {
+	var out []*eval.Builtin
+	asserts, expects := cfg.Assert, cfg.Expect
+	if asserts == nil {
+		asserts = []string{"assert"}
+	}
+	if expects == nil {
+		expects = []string{"expect"}
+	}
+	for _, name := range asserts {
+		out = append(out, &eval.Builtin{Name: name, Params: []string{"cond"}, Fn: func(args []eval.Value) (eval.Value, error) {
+			if truthy(args[0]) {
+				record(nil)
+				return eval.Long(1), nil
+			}
+			record(&Failure{Assertion: name, Message: "assertion failed: " + args[0].String()})
+			return eval.Long(0), nil
+		}})
+	}
+	for _, name := range expects {
+		out = append(out, &eval.Builtin{Name: name, Params: []string{"actual", "expected"}, Fn: func(args []eval.Value) (eval.Value, error) {
+			if equal(args[0], args[1], cfg.Tolerance) {
+				record(nil)
+				return eval.Long(1), nil
+			}
+			f := &Failure{Assertion: name, Message: fmt.Sprintf("got %s, want %s", trace.Brief(args[0]), trace.Brief(args[1]))}
+			_, aList := args[0].(eval.List)
+			_, bList := args[1].(eval.List)
+			if aList || bList {
+				// The message says all there is to say of atoms.
+				f.Diff = Diff(args[0], args[1], cfg.Tolerance)
+			}
+			record(f)
+			return eval.Long(0), nil
+		}})
+	}
+	return out
+}
+
+// truthy reports whether v is non-zero, or all its elements are.
+func truthy(v eval.Value) bool {
+	switch v := v.(type) {
+	case eval.Long:
+		return v != 0
+	case eval.Float:
+		return v != 0
+	case eval.List:
+		for _, e := range v {
+			if !truthy(e) {
+				return false
+			}
+		}
+		return true
+	}
+	return true
+}
@@ -0,0 +1,200 @@
+package suite_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/suite"
+)
+
+func write(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, map[string]string{
+		"a_test.wz":           "",
+		"a.wz":                "",
+		"sub/b_test.wabznasm": "",
+		".hidden/c_test.wz":   "",
+		"notatest.wz":         "",
+	})
+	got, err := suite.Discover([]string{dir, filepath.Join(dir, "notatest.wz")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		filepath.Join(dir, "a_test.wz"),
+		filepath.Join(dir, "sub/b_test.wabznasm"),
+		filepath.Join(dir, "notatest.wz"),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("Discover = %q, want %q", got, want)
+	}
+	if lib := suite.Library(filepath.Join(dir, "a_test.wz")); lib != filepath.Join(dir, "a.wz") {
+		t.Errorf("Library = %q", lib)
+	}
+	if lib := suite.Library(filepath.Join(dir, "sub/b_test.wabznasm")); lib != "" {
+		t.Errorf("Library = %q, want none", lib)
+	}
+}
+
+const lib = `sq: {[x] x*x}
+`
+
+const tests = `expect[sq[3]; 9]
+expect[sq[pair[2; 3]]; pair[4; 10]]
+check: {[n] assert[sq[n]]}
+check[0]+check[1]
+n: 1%0
+expect[sq[tenth[]]; hundredth[]]
+`
+
+// builtins make the lists and floats the grammar has no literals for.
+func builtins(t *testing.T) []*eval.Builtin {
+	t.Helper()
+	var out []*eval.Builtin
+	for name, fn := range map[string]any{
+		"pair":      func(a, b int64) []int64 { return []int64{a, b} },
+		"tenth":     func() float64 { return 0.1 },
+		"hundredth": func() float64 { return 0.01 },
+	} {
+		b, err := eval.NewBuiltin(name, fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func run(t *testing.T, cfg suite.Config) *suite.Result {
+	t.Helper()
+	cfg.Builtins = builtins(t)
+	dir := t.TempDir()
+	write(t, dir, map[string]string{"sq.wz": lib, "sq_test.wz": tests})
+	return suite.Run(context.Background(), cfg, filepath.Join(dir, "sq_test.wz"))
+}
+
+func TestRun(t *testing.T) {
+	r := run(t, suite.Config{})
+	if r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	if r.Library == "" {
+		t.Error("library not found")
+	}
+	var names []string
+	for _, c := range r.Cases {
+		names = append(names, c.Name)
+	}
+	want := []string{
+		"expect[sq[3]; 9]",
+		"expect[sq[pair[2; 3]]; pair[4; 10]]",
+		"check[0]+check[1]",
+		"n: 1%0",
+		"expect[sq[tenth[]]; hundredth[]]",
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("cases %q, want %q", names, want)
+	}
+	passed := []bool{true, false, false, false, false}
+	for i, c := range r.Cases {
+		if c.Passed() != passed[i] {
+			t.Errorf("%s: passed = %v, want %v", c.Name, c.Passed(), passed[i])
+		}
+	}
+	if r.Failed() != 4 || r.Passed() {
+		t.Errorf("failed %d", r.Failed())
+	}
+
+	list := r.Cases[1]
+	if list.Pos.Row != 1 || len(list.Failures) != 1 {
+		t.Fatalf("list case %+v", list)
+	}
+	if diff := list.Failures[0].Diff; !strings.Contains(diff, "want: 4 10\n") || !strings.Contains(diff, "  [1]: got 9, want 10\n") {
+		t.Errorf("diff:\n%s", diff)
+	}
+	if c := r.Cases[2]; c.Assertions != 2 || len(c.Failures) != 1 || c.Failures[0].Assertion != "assert" {
+		t.Errorf("check case %+v", c)
+	}
+	if c := r.Cases[3]; c.Err == nil || c.Assertions != 0 {
+		t.Errorf("error case %+v", c)
+	}
+}
+
+func TestTolerance(t *testing.T) {
+	r := run(t, suite.Config{Tolerance: 1e-9})
+	if c := r.Cases[4]; !c.Passed() {
+		t.Errorf("%s failed: %+v", c.Name, c.Failures)
+	}
+}
+
+func TestConfiguredAssertions(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, map[string]string{"x_test.wz": "equals[1+1; 2]\nexpect[1; 1]\n"})
+	cfg := suite.Config{Expect: []string{"equals"}, Builtins: []*eval.Builtin{}}
+	r := suite.Run(context.Background(), cfg, filepath.Join(dir, "x_test.wz"))
+	if len(r.Cases) != 2 || !r.Cases[0].Passed() || r.Cases[1].Err == nil {
+		t.Errorf("cases %+v, want equals to pass and expect to be unbound", r.Cases)
+	}
+}
+
+func TestLibraryError(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, map[string]string{"x.wz": "1%0\n", "x_test.wz": "expect[1; 1]\n"})
+	r := suite.Run(context.Background(), suite.Config{}, filepath.Join(dir, "x_test.wz"))
+	if r.Err == nil || len(r.Cases) != 0 || r.Passed() {
+		t.Errorf("result %+v, want the library error", r)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	r := run(t, suite.Config{})
+	var buf bytes.Buffer
+	if err := suite.WriteJUnit(&buf, []*suite.Result{r}); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Tests    int `xml:"tests,attr"`
+		Failures int `xml:"failures,attr"`
+		Errors   int `xml:"errors,attr"`
+		Suites   []struct {
+			Name  string `xml:"name,attr"`
+			Cases []struct {
+				Name    string `xml:"name,attr"`
+				Line    int    `xml:"line,attr"`
+				Failure *struct {
+					Message string `xml:"message,attr"`
+				} `xml:"failure"`
+			} `xml:"testcase"`
+		} `xml:"testsuite"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("%v\n%s", err, buf.String())
+	}
+	if doc.Tests != 5 || doc.Failures != 3 || doc.Errors != 1 || len(doc.Suites) != 1 {
+		t.Fatalf("report %+v", doc)
+	}
+	c := doc.Suites[0].Cases[1]
+	if c.Line != 2 || c.Failure == nil || c.Failure.Message != "got 4 9, want 4 10" {
+		t.Errorf("case %+v", c)
+	}
+}
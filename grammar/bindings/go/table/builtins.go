@@ -0,0 +1,266 @@
+package table
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// Builtins returns the builtins for querying tables and lists:
+//
+//	cols[t]       the column names of t, as symbols
+//	count[x]      the rows of a table, the items of a list, or 1
+//	select[t;c]   the table of the columns named by c, a symbol or list
+//	where[x;m]    the rows of a table, or items of a list, whose item of
+//	              the list m is not zero
+//	eq[x;y]       1 where x equals y, else 0, item by item as arithmetic
+//	lt[x;y]       1 where x is less than y
+//	gt[x;y]       1 where x is greater than y
+//	sum[l]        the sum of a list of numbers
+//	avg[l]        their mean, as a float
+//	min[l]        their least
+//	max[l]        their greatest
+//
+// Comparisons extend an atom across a list, as arithmetic operators do,
+// so gt[t[qty]; 100] compares every item with 100.
+func Builtins() []*eval.Builtin {
+	return []*eval.Builtin{
+		{Name: "cols", Params: []string{"t"}, Fn: cols},
+		{Name: "count", Params: []string{"x"}, Fn: count},
+		{Name: "select", Params: []string{"t", "c"}, Fn: project},
+		{Name: "where", Params: []string{"x", "m"}, Fn: where},
+		{Name: "eq", Params: []string{"x", "y"}, Fn: compare("eq", func(c int) bool { return c == 0 })},
+		{Name: "lt", Params: []string{"x", "y"}, Fn: compare("lt", func(c int) bool { return c < 0 })},
+		{Name: "gt", Params: []string{"x", "y"}, Fn: compare("gt", func(c int) bool { return c > 0 })},
+		{Name: "sum", Params: []string{"l"}, Fn: sum},
+		{Name: "avg", Params: []string{"l"}, Fn: avg},
+		{Name: "min", Params: []string{"l"}, Fn: extreme("min", -1)},
+		{Name: "max", Params: []string{"l"}, Fn: extreme("max", 1)},
+	}
+}
+
+func typeError(format string, args ...any) error {
+	return &eval.Error{Code: eval.CodeType, Message: fmt.Sprintf(format, args...)}
+}
+
+func tableArg(name string, v eval.Value) (*eval.Table, error) {
+	t, ok := v.(*eval.Table)
+	if !ok {
+		return nil, typeError("%s: want a table, got %s", name, v.Kind())
+	}
+	return t, nil
+}
+
+// listArg accepts a list, a table column read as one, or an atom as a
+// list of one item.
+func listArg(v eval.Value) eval.List {
+	if l, ok := v.(eval.List); ok {
+		return l
+	}
+	return eval.List{v}
+}
+
+func cols(args []eval.Value) (eval.Value, error) {
+	t, err := tableArg("cols", args[0])
+	if err != nil {
+		return nil, err
+	}
+	out := eval.List{}
+	for _, n := range t.Names() {
+		out = append(out, eval.Symbol(n))
+	}
+	return out, nil
+}
+
+func count(args []eval.Value) (eval.Value, error) {
+	switch x := args[0].(type) {
+	case *eval.Table:
+		return eval.Long(x.Len()), nil
+	case eval.List:
+		return eval.Long(len(x)), nil
+	}
+	return eval.Long(1), nil
+}
+
+func project(args []eval.Value) (eval.Value, error) {
+	t, err := tableArg("select", args[0])
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	var columns []eval.Column
+	for _, c := range listArg(args[1]) {
+		s, ok := c.(eval.Symbol)
+		if !ok {
+			return nil, typeError("select: want column names, got %s", c.Kind())
+		}
+		col, ok := t.Column(string(s))
+		if !ok {
+			return nil, &eval.Error{Code: eval.CodeUndefined, Message: "select: no column " + string(s)}
+		}
+		names = append(names, string(s))
+		columns = append(columns, col)
+	}
+	return eval.NewTable(names, columns)
+}
+
+func where(args []eval.Value) (eval.Value, error) {
+	mask := listArg(args[1])
+	n := len(mask)
+	if t, ok := args[0].(*eval.Table); ok {
+		n = t.Len()
+	} else if l, ok := args[0].(eval.List); ok {
+		n = len(l)
+	}
+	if len(mask) != n {
+		return nil, &eval.Error{Code: eval.CodeLength, Message: fmt.Sprintf("where: %d flags for %d items", len(mask), n)}
+	}
+	var keep []int
+	for i, m := range mask {
+		flag, ok := m.(eval.Long)
+		if !ok {
+			return nil, typeError("where: want flags, got %s", m.Kind())
+		}
+		if flag != 0 {
+			keep = append(keep, i)
+		}
+	}
+	switch x := args[0].(type) {
+	case *eval.Table:
+		columns := x.Columns()
+		for j, c := range columns {
+			columns[j] = take(c, keep)
+		}
+		return eval.NewTable(x.Names(), columns)
+	case eval.List:
+		out := make(eval.List, len(keep))
+		for i, k := range keep {
+			out[i] = x[k]
+		}
+		return out, nil
+	}
+	return nil, typeError("where: want a table or list, got %s", args[0].Kind())
+}
+
+// take returns the items of c at the indices keep.
+func take(c eval.Column, keep []int) eval.Column {
+	switch c := c.(type) {
+	case eval.LongColumn:
+		out := make(eval.LongColumn, len(keep))
+		for i, k := range keep {
+			out[i] = c[k]
+		}
+		return out
+	case eval.FloatColumn:
+		out := make(eval.FloatColumn, len(keep))
+		for i, k := range keep {
+			out[i] = c[k]
+		}
+		return out
+	case eval.SymbolColumn:
+		out := make(eval.SymbolColumn, len(keep))
+		for i, k := range keep {
+			out[i] = c[k]
+		}
+		return out
+	}
+	panic(fmt.Sprintf("table: unknown column type %T", c))
+}
+
+// compare returns a builtin comparing its arguments item by item.
+func compare(name string, holds func(int) bool) func([]eval.Value) (eval.Value, error) {
+	var cmp func(a, b eval.Value) (eval.Value, error)
+	cmp = func(a, b eval.Value) (eval.Value, error) {
+		la, aList := a.(eval.List)
+		lb, bList := b.(eval.List)
+		if !aList && !bList {
+			c, err := eval.Compare(a, b)
+			if err != nil {
+				return nil, err
+			}
+			if holds(c) {
+				return eval.Long(1), nil
+			}
+			return eval.Long(0), nil
+		}
+		n := len(la)
+		if !aList {
+			n = len(lb)
+		} else if bList && len(lb) != n {
+			return nil, &eval.Error{Code: eval.CodeLength, Message: "length mismatch in " + name}
+		}
+		out := make(eval.List, n)
+		for i := range out {
+			x, y := a, b
+			if aList {
+				x = la[i]
+			}
+			if bList {
+				y = lb[i]
+			}
+			v, err := cmp(x, y)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = v
+		}
+		return out, nil
+	}
+	return func(args []eval.Value) (eval.Value, error) { return cmp(args[0], args[1]) }
+}
+
+func sum(args []eval.Value) (eval.Value, error) {
+	var total eval.Value = eval.Long(0)
+	for _, v := range listArg(args[0]) {
+		if v.Kind() != eval.KindLong && v.Kind() != eval.KindFloat {
+			return nil, typeError("sum: want numbers, got %s", v.Kind())
+		}
+		var err error
+		if total, err = eval.Binary("+", total, v); err != nil {
+			return nil, err
+		}
+	}
+	return total, nil
+}
+
+func avg(args []eval.Value) (eval.Value, error) {
+	l := listArg(args[0])
+	if len(l) == 0 {
+		return eval.Float(math.NaN()), nil
+	}
+	total := 0.0
+	for _, v := range l {
+		switch v := v.(type) {
+		case eval.Long:
+			total += float64(v)
+		case eval.Float:
+			total += float64(v)
+		default:
+			return nil, typeError("avg: want numbers, got %s", v.Kind())
+		}
+	}
+	return eval.Float(total / float64(len(l))), nil
+}
+
+// extreme returns a builtin finding the item of a list that compares as
+// sign against all others.
+func extreme(name string, sign int) func([]eval.Value) (eval.Value, error) {
+	return func(args []eval.Value) (eval.Value, error) {
+		l := listArg(args[0])
+		if len(l) == 0 {
+			return nil, &eval.Error{Code: eval.CodeLength, Message: name + " of an empty list"}
+		}
+		best := l[0]
+		for _, v := range l[1:] {
+			c, err := eval.Compare(v, best)
+			if err != nil {
+				return nil, err
+			}
+			if c == sign {
+				best = v
+			}
+		}
+		return best, nil
+	}
+}
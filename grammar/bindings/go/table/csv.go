@@ -0,0 +1,147 @@
+// Package table loads CSV files into eval tables and offers the builtins
+// that query them from wabznasm: projecting columns, filtering rows and
+// aggregating lists.
+//
+// The grammar has neither string nor symbol literals, so a column is
+// named by a global bound to its symbol. Bind defines the table and, for
+// each column, such a global, after which an expression can read
+// trades[price], filter with where[trades; gt[trades[qty]; 100]] and sum
+// with sum[trades[price]].
+package table
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// Options configure ReadCSV. The zero Options read comma-separated
+// values with a header row.
+type Options struct {
+	// Comma separates fields; zero means ','.
+	Comma rune
+	// NoHeader reads the first row as data, naming the columns c0, c1
+	// and so on.
+	NoHeader bool
+}
+
+// ReadCSV reads a CSV table from r, inferring the kind of each column
+// from its cells: a column of integers becomes longs, one of numbers
+// floats, and any other symbols. Empty cells of a numeric column are read
+// as NaN, which makes the column floats; cells are trimmed of spaces.
+func ReadCSV(r io.Reader, opts Options) (*eval.Table, error) {
+	cr := csv.NewReader(r)
+	if opts.Comma != 0 {
+		cr.Comma = opts.Comma
+	}
+	cr.TrimLeadingSpace = true
+	records, err := cr.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	switch {
+	case len(records) == 0:
+		return eval.NewTable(nil, nil)
+	case opts.NoHeader:
+		for i := range records[0] {
+			names = append(names, "c"+strconv.Itoa(i))
+		}
+	default:
+		for _, n := range records[0] {
+			names = append(names, strings.TrimSpace(n))
+		}
+		records = records[1:]
+	}
+	cols := make([]eval.Column, len(names))
+	cells := make([]string, len(records))
+	for j := range names {
+		for i, rec := range records {
+			cells[i] = strings.TrimSpace(rec[j])
+		}
+		cols[j] = infer(cells)
+	}
+	return eval.NewTable(names, cols)
+}
+
+// LoadCSV reads the CSV file at path with ReadCSV.
+func LoadCSV(path string, opts Options) (*eval.Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	t, err := ReadCSV(f, opts)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+// infer converts the cells of a column to the narrowest column holding
+// them all.
+func infer(cells []string) eval.Column {
+	longs := make(eval.LongColumn, len(cells))
+	isLong := true
+	for i, c := range cells {
+		n, err := strconv.ParseInt(c, 10, 64)
+		if err != nil {
+			isLong = false
+			break
+		}
+		longs[i] = n
+	}
+	if isLong {
+		return longs
+	}
+	floats := make(eval.FloatColumn, len(cells))
+	numeric := false
+	for i, c := range cells {
+		if c == "" {
+			floats[i] = math.NaN()
+			continue
+		}
+		f, err := strconv.ParseFloat(c, 64)
+		if err != nil {
+			return eval.SymbolColumn(append([]string(nil), cells...))
+		}
+		floats[i], numeric = f, true
+	}
+	if !numeric {
+		return eval.SymbolColumn(append([]string(nil), cells...))
+	}
+	return floats
+}
+
+// identifier matches the names a wabznasm source can refer to.
+var identifier = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// Bind defines name in env as t and, for each column whose name is an
+// identifier not yet bound in env or its parents, a global of that name
+// holding its symbol, so that sources can write name[column]. It returns
+// the columns it bound, and an error if name is not an identifier.
+func Bind(env *eval.Env, name string, t *eval.Table) ([]string, error) {
+	if !identifier.MatchString(name) {
+		return nil, fmt.Errorf("table: %q is not an identifier", name)
+	}
+	env.Define(name, t)
+	var bound []string
+	for _, col := range t.Names() {
+		if !identifier.MatchString(col) {
+			continue
+		}
+		if _, ok := env.Lookup(col); ok {
+			continue
+		}
+		env.Define(col, eval.Symbol(col))
+		bound = append(bound, col)
+	}
+	return bound, nil
+}
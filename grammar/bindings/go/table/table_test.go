@@ -0,0 +1,119 @@
+package table_test
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/table"
+)
+
+const trades = `sym, qty, price, note
+a, 10, 1.5, x
+bb, 200, 2, 
+a, 3, , y
+`
+
+func TestReadCSV(t *testing.T) {
+	tab, err := table.ReadCSV(strings.NewReader(trades), table.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tab.Names(); !slices.Equal(got, []string{"sym", "qty", "price", "note"}) {
+		t.Errorf("Names() = %v", got)
+	}
+	want := []eval.Kind{eval.KindSymbol, eval.KindLong, eval.KindFloat, eval.KindSymbol}
+	for i, c := range tab.Columns() {
+		if c.Type() != want[i] {
+			t.Errorf("column %s is %s, want %s", tab.Names()[i], c.Type(), want[i])
+		}
+	}
+	if qty, _ := tab.Column("qty"); !slices.Equal(qty.(eval.LongColumn), eval.LongColumn{10, 200, 3}) {
+		t.Errorf("qty = %v", qty)
+	}
+	if price, _ := tab.Column("price"); !math.IsNaN(float64(price.(eval.FloatColumn)[2])) {
+		t.Errorf("empty price = %v", price.At(2))
+	}
+
+	tsv, err := table.ReadCSV(strings.NewReader("1\t2\n3\t4\n"), table.Options{Comma: '\t', NoHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := tsv.Names(); !slices.Equal(got, []string{"c0", "c1"}) || tsv.Len() != 2 {
+		t.Errorf("headerless = %v, %d rows", got, tsv.Len())
+	}
+	if _, err := table.ReadCSV(strings.NewReader("a,b\n1\n"), table.Options{}); err == nil {
+		t.Error("ReadCSV accepted a short row")
+	}
+
+	path := filepath.Join(t.TempDir(), "t.csv")
+	if err := os.WriteFile(path, []byte(trades), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if loaded, err := table.LoadCSV(path, table.Options{}); err != nil || loaded.String() != tab.String() {
+		t.Errorf("LoadCSV = %v, %v", loaded, err)
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	tab, err := table.ReadCSV(strings.NewReader(trades), table.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := eval.NewWith(table.Builtins()...)
+	// A column named like a global keeps the global.
+	in.Globals.Define("note", eval.Long(0))
+	bound, err := table.Bind(in.Globals, "t", tab)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(bound, []string{"sym", "qty", "price"}) {
+		t.Errorf("Bind bound %v", bound)
+	}
+	if _, err := in.EvalString("span: {[l] max[l]-min[l]}"); err != nil {
+		t.Fatal(err)
+	}
+
+	for src, want := range map[string]string{
+		"cols[t]":                              "`sym`qty`price`note",
+		"count[t]":                             "3",
+		"count[t[qty]]":                        "3",
+		"select[t; qty]":                       "qty\n---\n10\n200\n3",
+		"where[t; gt[t[qty]; 5]]":              "sym qty price note\n------------------\na   10  1.5   x\nbb  200 2f",
+		"where[t[sym]; eq[t[qty]; 3]]":         ",`a",
+		"lt[t[qty]; 100]":                      "1 0 1",
+		"gt[t[qty]; t[qty]]":                   "0 0 0",
+		"avg[t[qty]]":                          "71f",
+		"min[t[qty]]":                          "3",
+		"max[t[qty]]":                          "200",
+		"span[where[t[qty]; lt[t[qty]; 100]]]": "7",
+	} {
+		v, err := in.EvalString(src)
+		if err != nil {
+			t.Errorf("%s: %v", src, err)
+			continue
+		}
+		if v.String() != want {
+			t.Errorf("%s =\n%s\nwant\n%s", src, v, want)
+		}
+	}
+
+	for _, src := range []string{
+		"cols[1]",
+		"where[t; 1]",
+		"select[t; 1]",
+		"lt[t[qty]; t[sym]]",
+		"max[cols[1]]",
+	} {
+		if _, err := in.EvalString(src); err == nil {
+			t.Errorf("%s succeeded", src)
+		}
+	}
+	if _, err := table.Bind(in.Globals, "not a name", tab); err == nil {
+		t.Error("Bind accepted a bad name")
+	}
+}
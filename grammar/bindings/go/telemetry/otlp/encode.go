@@ -0,0 +1,154 @@
+package otlp
+
+import (
+	"encoding/hex"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// The types below are the OTLP/JSON encoding of an
+// ExportTraceServiceRequest, as a collector accepts at /v1/traces: IDs are
+// hexadecimal and 64-bit integers are strings.
+
+// ExportRequest is the body of an export request.
+type ExportRequest struct {
+	ResourceSpans []ResourceSpans `json:"resourceSpans"`
+}
+
+// ResourceSpans are the spans of one service.
+type ResourceSpans struct {
+	Resource   Resource     `json:"resource"`
+	ScopeSpans []ScopeSpans `json:"scopeSpans"`
+}
+
+// Resource describes the service.
+type Resource struct {
+	Attributes []KeyValue `json:"attributes"`
+}
+
+// ScopeSpans are the spans recorded by one instrumentation scope.
+type ScopeSpans struct {
+	Scope Scope  `json:"scope"`
+	Spans []Span `json:"spans"`
+}
+
+// Scope names the code that recorded the spans.
+type Scope struct {
+	Name string `json:"name"`
+}
+
+// Span is a timed operation.
+type Span struct {
+	TraceID            string     `json:"traceId"`
+	SpanID             string     `json:"spanId"`
+	ParentSpanID       string     `json:"parentSpanId,omitempty"`
+	Name               string     `json:"name"`
+	Kind               int        `json:"kind"`
+	StartTimeUnixNano  string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano    string     `json:"endTimeUnixNano"`
+	Attributes         []KeyValue `json:"attributes,omitempty"`
+	Events             []Event    `json:"events,omitempty"`
+	DroppedEventsCount int        `json:"droppedEventsCount,omitempty"`
+	// Status is nil for a span that succeeded.
+	Status *Status `json:"status,omitempty"`
+}
+
+// Event is a moment in a span.
+type Event struct {
+	TimeUnixNano string     `json:"timeUnixNano"`
+	Name         string     `json:"name"`
+	Attributes   []KeyValue `json:"attributes,omitempty"`
+}
+
+// Status is the outcome of a span that failed.
+type Status struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// KeyValue is an attribute.
+type KeyValue struct {
+	Key   string   `json:"key"`
+	Value AnyValue `json:"value"`
+}
+
+// AnyValue is the value of an attribute; one field is set.
+type AnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+// spanKindInternal and statusError are the OTLP enumeration values used.
+const (
+	spanKindInternal = 1
+	statusError      = 2
+)
+
+// NewRequest returns the request exporting spans recorded by scope on
+// behalf of service.
+func NewRequest(service, scope string, spans []Span) ExportRequest {
+	if spans == nil {
+		spans = []Span{}
+	}
+	return ExportRequest{ResourceSpans: []ResourceSpans{{
+		Resource:   Resource{Attributes: []KeyValue{Attribute(slog.String("service.name", service))}},
+		ScopeSpans: []ScopeSpans{{Scope: Scope{Name: scope}, Spans: spans}},
+	}}}
+}
+
+// NewSpan returns an internal span; a zero parent ID makes it a root span
+// and a non-nil err marks it failed.
+func NewSpan(traceID [16]byte, spanID, parentID [8]byte, name string, start, end time.Time, err error) Span {
+	s := Span{
+		TraceID:           hex.EncodeToString(traceID[:]),
+		SpanID:            hex.EncodeToString(spanID[:]),
+		Name:              name,
+		Kind:              spanKindInternal,
+		StartTimeUnixNano: unixNano(start),
+		EndTimeUnixNano:   unixNano(end),
+	}
+	if parentID != ([8]byte{}) {
+		s.ParentSpanID = hex.EncodeToString(parentID[:])
+	}
+	if err != nil {
+		s.Status = &Status{Code: statusError, Message: err.Error()}
+	}
+	return s
+}
+
+// NewEvent returns an event at t.
+func NewEvent(t time.Time, name string, attrs []KeyValue) Event {
+	return Event{TimeUnixNano: unixNano(t), Name: name, Attributes: attrs}
+}
+
+func unixNano(t time.Time) string { return strconv.FormatInt(t.UnixNano(), 10) }
+
+// Attribute encodes a.
+func Attribute(a slog.Attr) KeyValue {
+	v := a.Value.Resolve()
+	var out AnyValue
+	switch v.Kind() {
+	case slog.KindBool:
+		b := v.Bool()
+		out.BoolValue = &b
+	case slog.KindInt64:
+		s := strconv.FormatInt(v.Int64(), 10)
+		out.IntValue = &s
+	case slog.KindUint64:
+		s := strconv.FormatUint(v.Uint64(), 10)
+		out.IntValue = &s
+	case slog.KindFloat64:
+		f := v.Float64()
+		out.DoubleValue = &f
+	case slog.KindDuration:
+		s := strconv.FormatInt(v.Duration().Nanoseconds(), 10)
+		out.IntValue = &s
+	default:
+		s := v.String()
+		out.StringValue = &s
+	}
+	return KeyValue{Key: a.Key, Value: out}
+}
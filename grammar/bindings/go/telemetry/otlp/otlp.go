@@ -0,0 +1,185 @@
+// Package otlp exports the spans of package telemetry to an OpenTelemetry
+// collector, as OTLP/HTTP with JSON bodies. It needs no OpenTelemetry
+// libraries, so the tools stay free of dependencies:
+//
+//	exp := otlp.New("http://localhost:4318/v1/traces", "wabznasm-server")
+//	defer exp.Close()
+//	telemetry.SetExporter(exp)
+//
+// Spans are batched in the background and sent every second, or sooner
+// once a batch fills. Spans are dropped, and counted, when the collector
+// cannot keep up, rather than slow the work being traced.
+//
+// The types of the encoding, such as ExportRequest and Span, are exported
+// so that trace.Spans.WriteOTLP writes the same JSON.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+)
+
+const (
+	// BatchSize is the number of spans sent in one request at most.
+	BatchSize = 512
+	// Interval is the longest a span waits to be sent.
+	Interval = time.Second
+	// queueSize bounds the spans waiting to be batched.
+	queueSize = 4 * BatchSize
+)
+
+// Exporter sends spans to a collector. It is safe for concurrent use.
+type Exporter struct {
+	url     string
+	service string
+	client  *http.Client
+
+	queue   chan telemetry.SpanData
+	done    chan struct{}
+	closing sync.Once
+	wg      sync.WaitGroup
+
+	dropped atomic.Int64
+	// errMu guards err, the last error sending a batch.
+	errMu sync.Mutex
+	err   error
+}
+
+// New returns an exporter posting to url, the traces endpoint of a
+// collector, on behalf of the service named service. The caller must call
+// Close to send the spans still batched.
+func New(url, service string) *Exporter {
+	e := &Exporter{
+		url:     url,
+		service: service,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		queue:   make(chan telemetry.SpanData, queueSize),
+		done:    make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.loop()
+	return e
+}
+
+// FromEnv returns an exporter configured by the standard OpenTelemetry
+// environment variables, or nil if OTEL_EXPORTER_OTLP_TRACES_ENDPOINT and
+// OTEL_EXPORTER_OTLP_ENDPOINT are both unset. OTEL_SERVICE_NAME, if set,
+// overrides service.
+func FromEnv(service string) *Exporter {
+	url := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+	if url == "" {
+		base := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		if base == "" {
+			return nil
+		}
+		url = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if name := os.Getenv("OTEL_SERVICE_NAME"); name != "" {
+		service = name
+	}
+	return New(url, service)
+}
+
+// ExportSpan queues s to be sent.
+func (e *Exporter) ExportSpan(s telemetry.SpanData) {
+	select {
+	case e.queue <- s:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of spans dropped because the queue was full.
+func (e *Exporter) Dropped() int64 { return e.dropped.Load() }
+
+// Err returns the error of the last batch that could not be sent, or nil.
+func (e *Exporter) Err() error {
+	e.errMu.Lock()
+	defer e.errMu.Unlock()
+	return e.err
+}
+
+// Close sends the spans queued so far and stops the exporter. Spans
+// exported after Close are dropped.
+func (e *Exporter) Close() error {
+	e.closing.Do(func() { close(e.done) })
+	e.wg.Wait()
+	return e.Err()
+}
+
+func (e *Exporter) loop() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	var batch []telemetry.SpanData
+	flush := func() {
+		if len(batch) > 0 {
+			e.send(batch)
+			batch = nil
+		}
+	}
+	for {
+		select {
+		case s := <-e.queue:
+			batch = append(batch, s)
+			if len(batch) >= BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.done:
+			for {
+				select {
+				case s := <-e.queue:
+					batch = append(batch, s)
+					if len(batch) >= BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+func (e *Exporter) send(batch []telemetry.SpanData) {
+	body, err := json.Marshal(e.request(batch))
+	if err == nil {
+		var resp *http.Response
+		req, _ := http.NewRequestWithContext(context.Background(), http.MethodPost, e.url, bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if resp, err = e.client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode/100 != 2 {
+				err = fmt.Errorf("otlp: %s: %s", e.url, resp.Status)
+			}
+		}
+	}
+	e.errMu.Lock()
+	e.err = err
+	e.errMu.Unlock()
+}
+
+func (e *Exporter) request(batch []telemetry.SpanData) ExportRequest {
+	spans := make([]Span, 0, len(batch))
+	for _, s := range batch {
+		out := NewSpan(s.TraceID, s.SpanID, s.ParentID, s.Name, s.Start, s.End, s.Err)
+		for _, a := range s.Attrs {
+			out.Attributes = append(out.Attributes, Attribute(a))
+		}
+		spans = append(spans, out)
+	}
+	return NewRequest(e.service, "wabznasm", spans)
+}
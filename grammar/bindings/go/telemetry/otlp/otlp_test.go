@@ -0,0 +1,119 @@
+package otlp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry/otlp"
+)
+
+func TestExport(t *testing.T) {
+	var mu sync.Mutex
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/traces" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request %s %s %s", r.Method, r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		bodies = append(bodies, body)
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", srv.URL+"/")
+	t.Setenv("OTEL_SERVICE_NAME", "test-service")
+	exp := otlp.FromEnv("wabznasm")
+	telemetry.SetExporter(exp)
+	defer telemetry.SetExporter(nil)
+
+	ctx, root := telemetry.Start(context.Background(), "request", slog.String("path", "/lint"))
+	_, child := telemetry.Start(ctx, "lint", slog.Int("findings", 2), slog.Bool("cached", false))
+	child.End(errors.New("boom"))
+	root.End(nil)
+	if err := exp.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bodies) != 1 {
+		t.Fatalf("%d requests, want 1", len(bodies))
+	}
+	var req struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string
+					Value map[string]any
+				}
+			}
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID, SpanID, ParentSpanID, Name string
+					StartTimeUnixNano                   string
+					Attributes                          []struct {
+						Key   string
+						Value map[string]any
+					}
+					Status *struct {
+						Code    int
+						Message string
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(bodies[0], &req); err != nil {
+		t.Fatal(err)
+	}
+	rs := req.ResourceSpans[0]
+	if a := rs.Resource.Attributes[0]; a.Key != "service.name" || a.Value["stringValue"] != "test-service" {
+		t.Errorf("resource = %+v", rs.Resource)
+	}
+	spans := rs.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("spans = %+v", spans)
+	}
+	c, p := spans[0], spans[1]
+	if c.TraceID != root.RequestID() || c.ParentSpanID != p.SpanID || p.ParentSpanID != "" || len(c.SpanID) != 16 {
+		t.Errorf("child %+v, parent %+v", c, p)
+	}
+	if c.Status == nil || c.Status.Code != 2 || c.Status.Message != "boom" || p.Status != nil {
+		t.Errorf("statuses %+v, %+v", c.Status, p.Status)
+	}
+	if c.Attributes[0].Value["intValue"] != "2" || c.Attributes[1].Value["boolValue"] != false {
+		t.Errorf("attributes = %+v", c.Attributes)
+	}
+	if c.StartTimeUnixNano == "" {
+		t.Error("no start time")
+	}
+}
+
+func TestFromEnvUnset(t *testing.T) {
+	t.Setenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	t.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", "")
+	if exp := otlp.FromEnv("wabznasm"); exp != nil {
+		exp.Close()
+		t.Error("FromEnv returned an exporter with no endpoint set")
+	}
+}
+
+func TestSendError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+	exp := otlp.New(srv.URL, "wabznasm")
+	exp.ExportSpan(telemetry.SpanData{Name: "x"})
+	if err := exp.Close(); err == nil {
+		t.Error("Close = nil after the collector failed")
+	}
+}
@@ -0,0 +1,217 @@
+// Package telemetry is the logging and tracing shared by the wabznasm
+// tools: the parser wrapper, the evaluator, the language server, the
+// HTTP service and the commands.
+//
+// Logs go to a log/slog logger, by default one that discards everything,
+// so that the libraries stay quiet unless a program opts in with
+// SetDefault or a context carries a logger of its own (WithLogger).
+//
+// Work is timed in spans. Start opens one, within the span of its context
+// if there is one, and End closes it, logging its name, request ID and
+// duration at debug level and handing it to the exporter set with
+// SetExporter, such as the OTLP exporter of package otlp:
+//
+//	ctx, span := telemetry.Start(ctx, "format", slog.Int("bytes", len(src)))
+//	out, err := format.Source(src)
+//	span.End(err)
+//
+// The spans started from one root share a trace, whose ID is the request
+// ID logged with them and returned by RequestID. Spans cost nothing when
+// nothing would log or export them: Start returns a nil span when there is
+// no span to continue, no exporter and a logger that discards every level.
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	defaultLogger atomic.Pointer[slog.Logger]
+	exporter      atomic.Pointer[exporterBox]
+)
+
+// exporterBox lets a nil Exporter be stored.
+type exporterBox struct{ e Exporter }
+
+func init() {
+	defaultLogger.Store(slog.New(discard{}))
+}
+
+// SetDefault sets the logger used by contexts without one. A nil logger
+// discards everything again.
+func SetDefault(l *slog.Logger) {
+	if l == nil {
+		l = slog.New(discard{})
+	}
+	defaultLogger.Store(l)
+}
+
+// SetExporter sets the exporter ended spans are handed to; nil stops
+// exporting.
+func SetExporter(e Exporter) {
+	exporter.Store(&exporterBox{e})
+}
+
+func currentExporter() Exporter {
+	if b := exporter.Load(); b != nil {
+		return b.e
+	}
+	return nil
+}
+
+// NewLogger returns a logger writing the records at level or above to w,
+// as JSON lines if json is set and as text otherwise. The level is a slog
+// level name, such as debug or warn.
+func NewLogger(w io.Writer, level string, json bool) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("telemetry: unknown log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: l}
+	if json {
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+	return slog.New(slog.NewTextHandler(w, opts)), nil
+}
+
+type loggerKey struct{}
+
+// WithLogger returns a context whose logger is l.
+func WithLogger(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, l)
+}
+
+// Logger returns the logger of ctx, with the request ID of its span, if
+// any, as the attribute request_id.
+func Logger(ctx context.Context) *slog.Logger {
+	l, _ := ctx.Value(loggerKey{}).(*slog.Logger)
+	if l == nil {
+		l = defaultLogger.Load()
+	}
+	if s := FromContext(ctx); s != nil {
+		l = l.With(slog.String("request_id", s.RequestID()))
+	}
+	return l
+}
+
+// Exporter receives ended spans. ExportSpan must not block for long: it
+// is called by the goroutine ending the span.
+type Exporter interface {
+	ExportSpan(SpanData)
+}
+
+// SpanData is an ended span.
+type SpanData struct {
+	TraceID  [16]byte
+	SpanID   [8]byte
+	ParentID [8]byte // zero for the root of a trace
+	Name     string
+	Start    time.Time
+	End      time.Time
+	Attrs    []slog.Attr
+	// Err is the error the span ended with, if any.
+	Err error
+}
+
+// Span is an interval of work in progress. A nil *Span, as Start returns
+// when nothing would record it, ignores every call.
+type Span struct {
+	data   SpanData
+	logger *slog.Logger
+	ctx    context.Context
+}
+
+type spanKey struct{}
+
+// FromContext returns the span of ctx, or nil.
+func FromContext(ctx context.Context) *Span {
+	s, _ := ctx.Value(spanKey{}).(*Span)
+	return s
+}
+
+// RequestID returns the request ID of the span of ctx, or "" if it has
+// none.
+func RequestID(ctx context.Context) string {
+	return FromContext(ctx).RequestID()
+}
+
+// Start starts a span called name, a child of the span of ctx if it has
+// one and the root of a new trace otherwise, and returns a context
+// carrying it.
+func Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, *Span) {
+	parent := FromContext(ctx)
+	l, _ := ctx.Value(loggerKey{}).(*slog.Logger)
+	if l == nil {
+		l = defaultLogger.Load()
+	}
+	if parent == nil && currentExporter() == nil && !l.Enabled(ctx, slog.LevelError) {
+		return ctx, nil
+	}
+	s := &Span{logger: l, data: SpanData{Name: name, Start: time.Now(), Attrs: attrs}}
+	if parent != nil {
+		s.data.TraceID = parent.data.TraceID
+		s.data.ParentID = parent.data.SpanID
+	} else {
+		rand.Read(s.data.TraceID[:])
+	}
+	rand.Read(s.data.SpanID[:])
+	s.ctx = context.WithValue(ctx, spanKey{}, s)
+	return s.ctx, s
+}
+
+// RequestID returns the ID of the trace of s, in hexadecimal.
+func (s *Span) RequestID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.data.TraceID[:])
+}
+
+// SetAttrs adds attributes to s.
+func (s *Span) SetAttrs(attrs ...slog.Attr) {
+	if s != nil {
+		s.data.Attrs = append(s.data.Attrs, attrs...)
+	}
+}
+
+// End ends s, with the error err if the work failed, logging it and
+// handing it to the exporter.
+func (s *Span) End(err error) {
+	if s == nil {
+		return
+	}
+	s.data.End = time.Now()
+	s.data.Err = err
+	if s.logger.Enabled(s.ctx, slog.LevelDebug) {
+		args := []any{
+			slog.String("span", s.data.Name),
+			slog.String("request_id", s.RequestID()),
+			slog.Duration("duration", s.data.End.Sub(s.data.Start)),
+		}
+		for _, a := range s.data.Attrs {
+			args = append(args, a)
+		}
+		if err != nil {
+			args = append(args, slog.String("error", err.Error()))
+		}
+		s.logger.DebugContext(s.ctx, "span", args...)
+	}
+	if e := currentExporter(); e != nil {
+		e.ExportSpan(s.data)
+	}
+}
+
+// discard is a handler discarding every record.
+type discard struct{}
+
+func (discard) Enabled(context.Context, slog.Level) bool  { return false }
+func (discard) Handle(context.Context, slog.Record) error { return nil }
+func (d discard) WithAttrs([]slog.Attr) slog.Handler      { return d }
+func (d discard) WithGroup(string) slog.Handler           { return d }
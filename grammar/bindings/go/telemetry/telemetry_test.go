@@ -0,0 +1,123 @@
+package telemetry_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry"
+)
+
+type recorder struct {
+	mu    sync.Mutex
+	spans []telemetry.SpanData
+}
+
+func (r *recorder) ExportSpan(s telemetry.SpanData) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.spans = append(r.spans, s)
+}
+
+func export(t *testing.T) *recorder {
+	t.Helper()
+	r := &recorder{}
+	telemetry.SetExporter(r)
+	t.Cleanup(func() { telemetry.SetExporter(nil) })
+	return r
+}
+
+func TestDisabled(t *testing.T) {
+	ctx, span := telemetry.Start(context.Background(), "idle")
+	if span != nil || telemetry.FromContext(ctx) != nil {
+		t.Fatalf("Start with telemetry off = %v", span)
+	}
+	span.SetAttrs(slog.Int("n", 1))
+	span.End(nil)
+	if id := telemetry.RequestID(ctx); id != "" {
+		t.Errorf("RequestID = %q", id)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	r := export(t)
+	ctx, root := telemetry.Start(context.Background(), "request")
+	_, child := telemetry.Start(ctx, "lint", slog.Int("rules", 3))
+	child.End(errors.New("boom"))
+	root.End(nil)
+
+	if len(r.spans) != 2 {
+		t.Fatalf("spans = %+v", r.spans)
+	}
+	c, p := r.spans[0], r.spans[1]
+	if c.Name != "lint" || p.Name != "request" {
+		t.Errorf("names = %q, %q", c.Name, p.Name)
+	}
+	if c.TraceID != p.TraceID || c.ParentID != p.SpanID || p.ParentID != ([8]byte{}) {
+		t.Errorf("child %+v is not in the trace of %+v", c, p)
+	}
+	if c.Err == nil || c.Err.Error() != "boom" || len(c.Attrs) != 1 {
+		t.Errorf("child = %+v", c)
+	}
+	if id := root.RequestID(); len(id) != 32 || telemetry.RequestID(ctx) != id {
+		t.Errorf("RequestID = %q", id)
+	}
+	if c.End.Before(c.Start) {
+		t.Errorf("child ends before it starts: %v, %v", c.Start, c.End)
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := telemetry.NewLogger(&buf, "debug", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := telemetry.WithLogger(context.Background(), l)
+	ctx, span := telemetry.Start(ctx, "format", slog.Int("bytes", 4))
+	telemetry.Logger(ctx).Info("hello")
+	span.End(nil)
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("log = %s", buf.String())
+	}
+	var hello, end map[string]any
+	json.Unmarshal([]byte(lines[0]), &hello)
+	json.Unmarshal([]byte(lines[1]), &end)
+	if hello["request_id"] != span.RequestID() {
+		t.Errorf("hello = %v, want request_id %s", hello, span.RequestID())
+	}
+	if end["msg"] != "span" || end["span"] != "format" || end["bytes"] != 4.0 || end["duration"] == nil {
+		t.Errorf("span record = %v", end)
+	}
+
+	if _, err := telemetry.NewLogger(&buf, "loud", false); err == nil {
+		t.Error("NewLogger accepted an unknown level")
+	}
+}
+
+func TestParseSpan(t *testing.T) {
+	r := export(t)
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	ctx, root := telemetry.Start(context.Background(), "request")
+	tree, err := parser.ParseContext(ctx, []byte("x: 1+2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree.Close()
+	root.End(nil)
+	if len(r.spans) != 2 || r.spans[0].Name != "parse" || r.spans[0].ParentID != r.spans[1].SpanID {
+		t.Fatalf("spans = %+v", r.spans)
+	}
+}
@@ -0,0 +1,108 @@
+// Package textobjects resolves the structural text objects of the bundled
+// textobjects.scm at a position, for editors that select, delete or move
+// by function, call or parameter rather than by character.
+//
+// Objects are named as in nvim-treesitter-textobjects: function.outer is
+// a whole definition and function.inner the body between its braces,
+// call.outer a call and call.inner its arguments, parameter.inner one
+// parameter or argument, and comment.outer a comment.
+package textobjects
+
+import (
+	"sort"
+	"sync"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+// The objects the bundled query captures.
+const (
+	FunctionOuter  = "function.outer"
+	FunctionInner  = "function.inner"
+	CallOuter      = "call.outer"
+	CallInner      = "call.inner"
+	ParameterInner = "parameter.inner"
+	CommentOuter   = "comment.outer"
+)
+
+var textobjectsQuery = sync.OnceValues(tree_sitter_wabznasm.TextobjectsQuery)
+
+// Object is a text object of a tree.
+type Object struct {
+	// Name is the capture naming the object, such as call.outer.
+	Name  string
+	Range tree_sitter.Range
+}
+
+// All returns the objects of tree, whose text is source, ordered by
+// start and, among objects starting together, outermost first.
+func All(tree *tree_sitter.Tree, source []byte) []Object {
+	q, err := textobjectsQuery()
+	if err != nil {
+		// The bundled query compiles; see the package tests.
+		panic(err)
+	}
+	cursor := memtrack.NewQueryCursor()
+	defer cursor.Close()
+	names := q.CaptureNames()
+	var out []Object
+	captures := cursor.Captures(q.Query, tree.RootNode(), source)
+	for match, index := captures.Next(); match != nil; match, index = captures.Next() {
+		c := match.Captures[index]
+		out = append(out, Object{Name: names[c.Index], Range: c.Node.Range()})
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		a, b := out[i].Range, out[j].Range
+		if a.StartByte != b.StartByte {
+			return a.StartByte < b.StartByte
+		}
+		return a.EndByte > b.EndByte
+	})
+	return out
+}
+
+// At returns the innermost object called name that contains point. A
+// point at the end of an object counts, so a cursor just after a
+// parameter selects it.
+func At(tree *tree_sitter.Tree, source []byte, point tree_sitter.Point, name string) (tree_sitter.Range, bool) {
+	var best tree_sitter.Range
+	found := false
+	for _, o := range All(tree, source) {
+		if o.Name != name || before(point, o.Range.StartPoint) || before(o.Range.EndPoint, point) {
+			continue
+		}
+		if !found || o.Range.EndByte-o.Range.StartByte < best.EndByte-best.StartByte {
+			best, found = o.Range, true
+		}
+	}
+	return best, found
+}
+
+// Next returns the first object called name starting after point, for
+// moving to the next function, call or parameter.
+func Next(tree *tree_sitter.Tree, source []byte, point tree_sitter.Point, name string) (tree_sitter.Range, bool) {
+	for _, o := range All(tree, source) {
+		if o.Name == name && before(point, o.Range.StartPoint) {
+			return o.Range, true
+		}
+	}
+	return tree_sitter.Range{}, false
+}
+
+// Previous returns the last object called name starting before point.
+func Previous(tree *tree_sitter.Tree, source []byte, point tree_sitter.Point, name string) (tree_sitter.Range, bool) {
+	var last tree_sitter.Range
+	found := false
+	for _, o := range All(tree, source) {
+		if o.Name == name && before(o.Range.StartPoint, point) {
+			last, found = o.Range, true
+		}
+	}
+	return last, found
+}
+
+func before(a, b tree_sitter.Point) bool {
+	return a.Row < b.Row || a.Row == b.Row && a.Column < b.Column
+}
@@ -0,0 +1,89 @@
+package textobjects_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/textobjects"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(memtrack.Main(m.Run))
+}
+
+const src = "f: {[a;b] g[a*2;h[b]]} \\ note"
+
+func parse(t *testing.T) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(parser.Close)
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(tree.Close)
+	return tree
+}
+
+func point(at string, offset int) tree_sitter.Point {
+	return tree_sitter_wabznasm.PointForOffset([]byte(src), uint(strings.Index(src, at)+offset))
+}
+
+func text(r tree_sitter.Range) string { return src[r.StartByte:r.EndByte] }
+
+func TestAt(t *testing.T) {
+	tree := parse(t)
+	tests := []struct {
+		at     string
+		offset int
+		name   string
+		want   string
+	}{
+		{"b]]", 0, textobjects.FunctionOuter, "f: {[a;b] g[a*2;h[b]]}"},
+		{"b]]", 0, textobjects.FunctionInner, "g[a*2;h[b]]"},
+		{"b]]", 0, textobjects.CallOuter, "h[b]"},
+		{"b]]", 0, textobjects.CallInner, "b"},
+		{"b]]", 0, textobjects.ParameterInner, "b"},
+		{"*2", 0, textobjects.ParameterInner, "a*2"},
+		{"*2", 0, textobjects.CallOuter, "g[a*2;h[b]]"},
+		{"*2", 0, textobjects.CallInner, "a*2;h[b]"},
+		{"b] g", 1, textobjects.ParameterInner, "b"},
+		{"note", 0, textobjects.CommentOuter, "\\ note"},
+	}
+	for _, tt := range tests {
+		r, ok := textobjects.At(tree.Tree, []byte(src), point(tt.at, tt.offset), tt.name)
+		if !ok || text(r) != tt.want {
+			t.Errorf("At(%q+%d, %s) = %q, %v; want %q", tt.at, tt.offset, tt.name, text(r), ok, tt.want)
+		}
+	}
+	if r, ok := textobjects.At(tree.Tree, []byte(src), point("f:", 0), textobjects.CallOuter); ok {
+		t.Errorf("call at f = %q", text(r))
+	}
+}
+
+func TestNextPrevious(t *testing.T) {
+	tree := parse(t)
+	var got []string
+	p := point("f", 0)
+	for {
+		r, ok := textobjects.Next(tree.Tree, []byte(src), p, textobjects.ParameterInner)
+		if !ok {
+			break
+		}
+		got = append(got, text(r))
+		p = r.StartPoint
+	}
+	if want := "a b a*2 h[b] b"; strings.Join(got, " ") != want {
+		t.Errorf("parameters %q, want %q", got, want)
+	}
+	if r, ok := textobjects.Previous(tree.Tree, []byte(src), point("h[", 0), textobjects.CallOuter); !ok || text(r) != "g[a*2;h[b]]" {
+		t.Errorf("Previous call = %q, %v", text(r), ok)
+	}
+}
@@ -0,0 +1,129 @@
+package tree_sitter_wabznasm
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// Token kinds that do not come from a grammar leaf.
+const (
+	// KindWhitespace marks the layout between two leaves.
+	KindWhitespace = "whitespace"
+	// KindError marks bytes the parser skipped without producing a leaf.
+	KindError = "ERROR"
+)
+
+// Token is a leaf of the parse tree, or the text between two leaves.
+type Token struct {
+	// Kind is the node kind: "identifier", "comment", "+" and so on, or
+	// KindWhitespace or KindError for text outside any leaf.
+	Kind string
+	Text string
+	// Named reports whether Kind is a named grammar rule rather than a
+	// literal token.
+	Named bool
+	// Extra reports whether the token may appear anywhere in the grammar:
+	// comments and whitespace.
+	Extra bool
+	Range tree_sitter.Range
+}
+
+// IsWhitespace reports whether t is layout between leaves.
+func (t Token) IsWhitespace() bool { return t.Kind == KindWhitespace }
+
+// IsComment reports whether t is a comment.
+func (t Token) IsComment() bool { return t.Kind == KindComment }
+
+// Tokenize parses source and returns its tokens, as Tokens does.
+func Tokenize(source []byte) ([]Token, error) {
+	parser, err := NewParser()
+	if err != nil {
+		return nil, err
+	}
+	defer parser.Close()
+	tree, err := parser.ParseBytes(source)
+	if err != nil {
+		return nil, err
+	}
+	defer tree.Close()
+	return Tokens(tree.Tree, source), nil
+}
+
+// Tokens returns the leaves of tree in source order, with the text between
+// them filled in as whitespace or error tokens, so that the token texts
+// concatenate to source. Zero-width leaves, such as the tokens the parser
+// inserts to recover from an error, are left out.
+func Tokens(tree *tree_sitter.Tree, source []byte) []Token {
+	t := &tokenizer{source: source}
+	t.leaves(tree.RootNode())
+	t.gap(uint(len(source)))
+	return t.tokens
+}
+
+type tokenizer struct {
+	source []byte
+	tokens []Token
+	offset uint
+	point  tree_sitter.Point
+}
+
+func (t *tokenizer) leaves(n *tree_sitter.Node) {
+	if n.ChildCount() > 0 {
+		for i := uint(0); i < n.ChildCount(); i++ {
+			t.leaves(n.Child(i))
+		}
+		return
+	}
+	start, end := n.StartByte(), min(n.EndByte(), uint(len(t.source)))
+	if start >= end || start < t.offset {
+		return
+	}
+	t.gap(start)
+	t.tokens = append(t.tokens, Token{
+		Kind:  n.Kind(),
+		Text:  string(t.source[start:end]),
+		Named: n.IsNamed(),
+		Extra: n.IsExtra(),
+		Range: n.Range(),
+	})
+	t.offset, t.point = end, n.EndPosition()
+}
+
+// gap emits the text from the current offset to end, split into runs of
+// whitespace and of anything else.
+func (t *tokenizer) gap(end uint) {
+	for t.offset < end {
+		start, startPoint := t.offset, t.point
+		space := isSpaceAt(t.source, start)
+		for t.offset < end && isSpaceAt(t.source, t.offset) == space {
+			_, size := utf8.DecodeRune(t.source[t.offset:end])
+			for _, b := range t.source[t.offset:][:size] {
+				if b == '\n' {
+					t.point.Row++
+					t.point.Column = 0
+				} else {
+					t.point.Column++
+				}
+			}
+			t.offset += uint(size)
+		}
+		kind := KindWhitespace
+		if !space {
+			kind = KindError
+		}
+		t.tokens = append(t.tokens, Token{
+			Kind:  kind,
+			Text:  string(t.source[start:t.offset]),
+			Named: !space,
+			Extra: space,
+			Range: tree_sitter.Range{StartByte: start, EndByte: t.offset, StartPoint: startPoint, EndPoint: t.point},
+		})
+	}
+}
+
+func isSpaceAt(src []byte, i uint) bool {
+	r, _ := utf8.DecodeRune(src[i:])
+	return unicode.IsSpace(r)
+}
@@ -0,0 +1,53 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+func TestTokenize(t *testing.T) {
+	src := "\\ doc\nadd: {[x;y] x+y}  \\ sum\n"
+	toks, err := tree_sitter_wabznasm.Tokenize([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []string
+	var text strings.Builder
+	for _, tok := range toks {
+		kinds = append(kinds, tok.Kind)
+		text.WriteString(tok.Text)
+		if got := src[tok.Range.StartByte:tok.Range.EndByte]; got != tok.Text {
+			t.Errorf("%s token text %q, range holds %q", tok.Kind, tok.Text, got)
+		}
+		if want := tree_sitter_wabznasm.PointForOffset([]byte(src), tok.Range.StartByte); tok.Range.StartPoint != want {
+			t.Errorf("%s token %q starts at %v, want %v", tok.Kind, tok.Text, tok.Range.StartPoint, want)
+		}
+	}
+	if text.String() != src {
+		t.Errorf("token texts join to %q, want %q", text.String(), src)
+	}
+	want := "comment whitespace identifier : whitespace { [ identifier ; identifier ] whitespace identifier + identifier } whitespace comment whitespace"
+	if got := strings.Join(kinds, " "); got != want {
+		t.Errorf("kinds = %s\nwant    %s", got, want)
+	}
+	if !toks[0].IsComment() || !toks[0].Extra || !toks[1].IsWhitespace() || !toks[2].Named || toks[3].Named {
+		t.Errorf("classification wrong: %+v", toks[:4])
+	}
+}
+
+func TestTokenizeError(t *testing.T) {
+	src := "1 + # 2"
+	toks, err := tree_sitter_wabznasm.Tokenize([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	for _, tok := range toks {
+		text.WriteString(tok.Text)
+	}
+	if text.String() != src {
+		t.Errorf("token texts join to %q, want %q", text.String(), src)
+	}
+}
@@ -0,0 +1,133 @@
+// Package trace provides eval.Tracer implementations. Log writes a flat,
+// indented log of an evaluation; Spans records function calls as
+// OpenTelemetry spans and writes them in the OTLP JSON encoding, for a
+// collector or tracing backend to import.
+package trace
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+)
+
+// maxValue is the length beyond which values are cut short in traces.
+const maxValue = 60
+
+// Log is a tracer writing a line per event, indented by call depth:
+//
+//	-> f[3]
+//	  3*2 = 6
+//	<- f = 6 (1.2µs)
+//	x: 6
+//
+// The lines of operators are left out unless Operators is set, since
+// they are numerous.
+type Log struct {
+	// Operators includes the application of each operator.
+	Operators bool
+	w         io.Writer
+	err       error
+	starts    []time.Time
+}
+
+// NewLog returns a tracer writing to w.
+func NewLog(w io.Writer) *Log {
+	return &Log{w: w}
+}
+
+// Err returns the first error writing the log.
+func (l *Log) Err() error { return l.err }
+
+func (l *Log) printf(format string, args ...any) {
+	if l.err != nil {
+		return
+	}
+	indent := strings.Repeat("  ", len(l.starts))
+	_, l.err = fmt.Fprintf(l.w, indent+format+"\n", args...)
+}
+
+// Enter implements eval.Tracer.
+func (l *Log) Enter(call *ast.Call, fn eval.Value, args []eval.Value) {
+	l.printf("-> %s[%s]", Name(call, fn), join(args))
+	l.starts = append(l.starts, time.Now())
+}
+
+// Exit implements eval.Tracer.
+func (l *Log) Exit(call *ast.Call, fn eval.Value, result eval.Value, err error) {
+	took := time.Since(l.starts[len(l.starts)-1])
+	l.starts = l.starts[:len(l.starts)-1]
+	if err != nil {
+		l.printf("<- %s: error: %v (%v)", Name(call, fn), err, took)
+		return
+	}
+	l.printf("<- %s = %s (%v)", Name(call, fn), Brief(result), took)
+}
+
+// Operator implements eval.Tracer.
+func (l *Log) Operator(e ast.Expr, op string, operands []eval.Value, result eval.Value, err error) {
+	if !l.Operators {
+		return
+	}
+	expr := Applied(e, op, operands)
+	if err != nil {
+		l.printf("%s: error: %v", expr, err)
+		return
+	}
+	l.printf("%s = %s", expr, Brief(result))
+}
+
+// Assign implements eval.Tracer.
+func (l *Log) Assign(name string, v eval.Value) {
+	l.printf("%s: %s", name, Brief(v))
+}
+
+// Name returns the name a traced function was called by.
+func Name(call *ast.Call, fn eval.Value) string {
+	switch {
+	case call != nil:
+		return call.Func.Name
+	case fn != nil:
+		if b, ok := fn.(*eval.Builtin); ok {
+			return b.Name
+		}
+	}
+	return "<function>"
+}
+
+// Applied describes the application of op to operands, as in "3*2".
+func Applied(e ast.Expr, op string, operands []eval.Value) string {
+	switch {
+	case len(operands) == 2:
+		return Brief(operands[0]) + op + Brief(operands[1])
+	case len(operands) == 1:
+		if _, ok := e.(*ast.PostfixExpr); ok {
+			return Brief(operands[0]) + op
+		}
+		return op + Brief(operands[0])
+	}
+	return op
+}
+
+// Brief formats v for a trace, cut short if it is long.
+func Brief(v eval.Value) string {
+	if v == nil {
+		return "<nothing>"
+	}
+	s := v.String()
+	if r := []rune(s); len(r) > maxValue {
+		s = string(r[:maxValue-1]) + "…"
+	}
+	return s
+}
+
+func join(vs []eval.Value) string {
+	parts := make([]string, len(vs))
+	for i, v := range vs {
+		parts[i] = Brief(v)
+	}
+	return strings.Join(parts, ";")
+}
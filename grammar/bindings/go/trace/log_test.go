@@ -0,0 +1,52 @@
+package trace_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/trace"
+)
+
+func TestLog(t *testing.T) {
+	var out strings.Builder
+	log := trace.NewLog(&out)
+	log.Operators = true
+	in := eval.New()
+	in.Tracer = log
+	for _, src := range []string{"f: {[a] a*2}", "g: {[a] f[a]+1}", "x: g[3]", "f[1/0]"} {
+		in.EvalString(src)
+	}
+	got := regexp.MustCompile(`\([^()]*s\)`).ReplaceAllString(out.String(), "(t)")
+	want := `f: {[a] a*2}
+g: {[a] f[a]+1}
+-> g[3]
+  -> f[3]
+    3*2 = 6
+  <- f = 6 (t)
+  6+1 = 7
+<- g = 7 (t)
+x: 7
+1/0: error: division by zero
+`
+	if got != want {
+		t.Errorf("log:\n%s\nwant:\n%s", got, want)
+	}
+	if log.Err() != nil {
+		t.Error(log.Err())
+	}
+}
+
+func TestBrief(t *testing.T) {
+	long := make(eval.List, 100)
+	for i := range long {
+		long[i] = eval.Long(i)
+	}
+	if s := trace.Brief(long); len([]rune(s)) != 60 || !strings.HasSuffix(s, "…") {
+		t.Errorf("Brief = %q", s)
+	}
+	if s := trace.Brief(eval.Long(5)); s != "5" {
+		t.Errorf("Brief = %q", s)
+	}
+}
@@ -0,0 +1,196 @@
+package trace
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/telemetry/otlp"
+)
+
+// DefaultMaxEvents is the number of events recorded per span when
+// Spans.MaxEvents is zero.
+const DefaultMaxEvents = 128
+
+// Span is a timed operation of a trace: a function call, or an
+// evaluation bracketed by Spans.Begin and Spans.End.
+type Span struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	ParentID   [8]byte // zero for a root span
+	Name       string
+	Start, End time.Time
+	Attributes []Attribute
+	Events     []Event
+	// DroppedEvents counts the events beyond the limit of the span.
+	DroppedEvents int
+	// Err is the error the operation failed with.
+	Err error
+}
+
+// Event is a moment in a span: an operator applied or a name assigned.
+type Event struct {
+	Time       time.Time
+	Name       string
+	Attributes []Attribute
+}
+
+// Attribute is a key and a value, a string or an int64.
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// Spans is a tracer recording each function call as a span, a child of
+// the call it was made in, with the operators and assignments evaluated
+// directly within it as events. Operators and assignments outside any
+// call are recorded only within a span opened by Begin.
+type Spans struct {
+	// MaxEvents bounds the events recorded per span; zero means
+	// DefaultMaxEvents.
+	MaxEvents int
+	traceID   [16]byte
+	open      []*Span
+	done      []*Span
+}
+
+// NewSpans returns a tracer recording the spans of a new trace.
+func NewSpans() *Spans {
+	s := &Spans{}
+	rand.Read(s.traceID[:])
+	return s
+}
+
+// Spans returns the spans ended so far, in the order they ended.
+func (s *Spans) Spans() []Span {
+	out := make([]Span, len(s.done))
+	for i, sp := range s.done {
+		out[i] = *sp
+	}
+	return out
+}
+
+// Begin opens a span called name, such as the statement about to be
+// evaluated, enclosing the calls and events that follow until End.
+func (s *Spans) Begin(name string, attrs ...Attribute) {
+	s.start(name, attrs)
+}
+
+// End closes the span opened by the matching Begin, recording whether
+// the evaluation failed.
+func (s *Spans) End(err error) {
+	s.end(nil, err)
+}
+
+func (s *Spans) start(name string, attrs []Attribute) {
+	sp := &Span{TraceID: s.traceID, Name: name, Start: time.Now(), Attributes: attrs}
+	rand.Read(sp.SpanID[:])
+	if n := len(s.open); n > 0 {
+		sp.ParentID = s.open[n-1].SpanID
+	}
+	s.open = append(s.open, sp)
+}
+
+func (s *Spans) end(attrs []Attribute, err error) {
+	n := len(s.open)
+	if n == 0 {
+		return
+	}
+	sp := s.open[n-1]
+	s.open = s.open[:n-1]
+	sp.End = time.Now()
+	sp.Attributes = append(sp.Attributes, attrs...)
+	sp.Err = err
+	s.done = append(s.done, sp)
+}
+
+func (s *Spans) event(name string, attrs ...Attribute) {
+	n := len(s.open)
+	if n == 0 {
+		return
+	}
+	sp := s.open[n-1]
+	limit := s.MaxEvents
+	if limit == 0 {
+		limit = DefaultMaxEvents
+	}
+	if len(sp.Events) >= limit {
+		sp.DroppedEvents++
+		return
+	}
+	sp.Events = append(sp.Events, Event{Time: time.Now(), Name: name, Attributes: attrs})
+}
+
+// Enter implements eval.Tracer.
+func (s *Spans) Enter(call *ast.Call, fn eval.Value, args []eval.Value) {
+	attrs := []Attribute{
+		{"wabznasm.function", Name(call, fn)},
+		{"wabznasm.arguments", join(args)},
+	}
+	if call != nil {
+		p := call.Pos()
+		attrs = append(attrs, Attribute{"code.lineno", int64(p.Row) + 1}, Attribute{"code.column", int64(p.Column) + 1})
+	}
+	s.start(Name(call, fn), attrs)
+}
+
+// Exit implements eval.Tracer.
+func (s *Spans) Exit(call *ast.Call, fn eval.Value, result eval.Value, err error) {
+	var attrs []Attribute
+	if err == nil {
+		attrs = append(attrs, Attribute{"wabznasm.result", Brief(result)})
+	}
+	s.end(attrs, err)
+}
+
+// Operator implements eval.Tracer.
+func (s *Spans) Operator(e ast.Expr, op string, operands []eval.Value, result eval.Value, err error) {
+	attrs := []Attribute{{"wabznasm.expression", Applied(e, op, operands)}}
+	if err != nil {
+		attrs = append(attrs, Attribute{"exception.message", err.Error()})
+	} else {
+		attrs = append(attrs, Attribute{"wabznasm.result", Brief(result)})
+	}
+	s.event(op, attrs...)
+}
+
+// Assign implements eval.Tracer.
+func (s *Spans) Assign(name string, v eval.Value) {
+	s.event("assign", Attribute{"wabznasm.name", name}, Attribute{"wabznasm.value", Brief(v)})
+}
+
+// WriteOTLP writes the spans ended so far as an OTLP JSON export request
+// from service, for a collector or tracing backend to import.
+func (s *Spans) WriteOTLP(w io.Writer, service string) error {
+	spans := []otlp.Span{}
+	for _, sp := range s.done {
+		out := otlp.NewSpan(sp.TraceID, sp.SpanID, sp.ParentID, sp.Name, sp.Start, sp.End, sp.Err)
+		out.Attributes = otlpAttributes(sp.Attributes)
+		out.DroppedEventsCount = sp.DroppedEvents
+		for _, e := range sp.Events {
+			out.Events = append(out.Events, otlp.NewEvent(e.Time, e.Name, otlpAttributes(e.Attributes)))
+		}
+		spans = append(spans, out)
+	}
+	req := otlp.NewRequest(service, "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/trace", spans)
+	return json.NewEncoder(w).Encode(req)
+}
+
+// otlpAttributes encodes the attributes holding an int64 or a string, the
+// kinds of value spans record.
+func otlpAttributes(attrs []Attribute) []otlp.KeyValue {
+	var out []otlp.KeyValue
+	for _, a := range attrs {
+		switch x := a.Value.(type) {
+		case int64:
+			out = append(out, otlp.Attribute(slog.Int64(a.Key, x)))
+		case string:
+			out = append(out, otlp.Attribute(slog.String(a.Key, x)))
+		}
+	}
+	return out
+}
@@ -0,0 +1,98 @@
+package trace_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/trace"
+)
+
+func TestSpans(t *testing.T) {
+	spans := trace.NewSpans()
+	spans.MaxEvents = 1
+	in := eval.New()
+	in.Tracer = spans
+	in.EvalString("f: {[a] a*2+1}")
+	spans.Begin("x: 1+f[3]")
+	_, err := in.EvalString("x: 1+f[3]")
+	spans.End(err)
+	in.EvalString("h: {[a] a%0}")
+	in.EvalString("h[1]")
+
+	got := spans.Spans()
+	if len(got) != 3 {
+		t.Fatalf("got %d spans", len(got))
+	}
+	f, stmt, failed := got[0], got[1], got[2]
+	if f.Name != "f" || stmt.Name != "x: 1+f[3]" || f.ParentID != stmt.SpanID || f.TraceID != stmt.TraceID {
+		t.Errorf("spans = %+v", got)
+	}
+	if len(f.Events) != 1 || f.DroppedEvents != 1 {
+		t.Errorf("f has %d events, %d dropped", len(f.Events), f.DroppedEvents)
+	}
+	// The statement's span holds the operator and the assignment.
+	if len(stmt.Events) != 1 || stmt.Events[0].Name != "+" || stmt.DroppedEvents != 1 {
+		t.Errorf("statement events = %+v", stmt.Events)
+	}
+	if failed.Name != "h" || failed.Err == nil || failed.ParentID != ([8]byte{}) {
+		t.Errorf("h[1] span = %+v", failed)
+	}
+	if f.End.Before(f.Start) || stmt.End.Before(f.End) {
+		t.Errorf("times out of order")
+	}
+
+	spans.Begin("boom")
+	spans.End(errors.New("boom"))
+	var out strings.Builder
+	if err := spans.WriteOTLP(&out, "test"); err != nil {
+		t.Fatal(err)
+	}
+	var req struct {
+		ResourceSpans []struct {
+			Resource struct {
+				Attributes []struct {
+					Key   string
+					Value struct{ StringValue string }
+				}
+			}
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID, SpanID, ParentSpanID, Name string
+					StartTimeUnixNano                   string
+					Attributes                          []struct {
+						Key   string
+						Value struct{ StringValue, IntValue string }
+					}
+					Status struct {
+						Code    int
+						Message string
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal([]byte(out.String()), &req); err != nil {
+		t.Fatal(err)
+	}
+	rs := req.ResourceSpans[0]
+	if a := rs.Resource.Attributes[0]; a.Key != "service.name" || a.Value.StringValue != "test" {
+		t.Errorf("resource = %+v", rs.Resource)
+	}
+	exported := rs.ScopeSpans[0].Spans
+	if len(exported) != 4 || len(exported[0].TraceID) != 32 || len(exported[0].SpanID) != 16 || exported[0].ParentSpanID != exported[1].SpanID {
+		t.Fatalf("exported = %+v", exported)
+	}
+	attrs := map[string]string{}
+	for _, a := range exported[0].Attributes {
+		attrs[a.Key] = a.Value.StringValue + a.Value.IntValue
+	}
+	if attrs["wabznasm.function"] != "f" || attrs["wabznasm.arguments"] != "3" || attrs["wabznasm.result"] != "7" || attrs["code.column"] != "6" {
+		t.Errorf("attributes = %v", attrs)
+	}
+	if s := exported[3].Status; s.Code != 2 || s.Message != "boom" {
+		t.Errorf("status = %+v", s)
+	}
+}
@@ -0,0 +1,215 @@
+package transpile
+
+import (
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+type tokenKind int
+
+const (
+	tEOF tokenKind = iota
+	// tSep ends a statement: a semicolon or a line break outside brackets.
+	tSep
+	tNumber
+	tName
+	tSymbol
+	tString
+	tOp
+	tLParen
+	tRParen
+	tLBracket
+	tRBracket
+	tLBrace
+	tRBrace
+	// tSemi separates the items of a bracketed list.
+	tSemi
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	span ast.Span
+	// space is set if white space, or the start of a line, precedes the
+	// token.
+	space bool
+}
+
+type lexer struct {
+	src   []byte
+	pos   ast.Pos
+	depth int
+	toks  []token
+}
+
+// lex splits q source into tokens. Comments are dropped, and line breaks
+// inside brackets, or followed by an indented line, are white space as in
+// q scripts.
+func lex(src []byte) []token {
+	l := &lexer{src: src}
+	space := true
+	for l.pos.Offset < uint(len(src)) {
+		c := src[l.pos.Offset]
+		lineStart := l.pos.Column == 0
+		switch {
+		case c == '\n':
+			l.advance(1)
+			if l.depth == 0 && !l.continued() {
+				l.emit(tSep, l.pos, "\n", space)
+			}
+			space = true
+			continue
+		case c == ' ' || c == '\t' || c == '\r':
+			l.advance(1)
+			space = true
+			continue
+		case c == '/' && lineStart && l.restOfLineBlank(1):
+			l.blockComment()
+			continue
+		case c == '/' && space:
+			l.skipLine()
+			continue
+		}
+		start := l.pos
+		kind := tOp
+		switch {
+		case isDigit(c) || c == '-' && l.negativeNumber(space):
+			kind = tNumber
+			l.advance(1)
+			for l.pos.Offset < uint(len(src)) && (isAlnum(src[l.pos.Offset]) || src[l.pos.Offset] == '.') {
+				l.advance(1)
+			}
+		case isLetter(c) || c == '.' && l.pos.Offset+1 < uint(len(src)) && isLetter(src[l.pos.Offset+1]):
+			kind = tName
+			l.advance(1)
+			for l.pos.Offset < uint(len(src)) && (isAlnum(src[l.pos.Offset]) || src[l.pos.Offset] == '.' || src[l.pos.Offset] == '_') {
+				l.advance(1)
+			}
+		case c == '`':
+			kind = tSymbol
+			l.advance(1)
+			for l.pos.Offset < uint(len(src)) && (isAlnum(src[l.pos.Offset]) || src[l.pos.Offset] == '.' || src[l.pos.Offset] == '_') {
+				l.advance(1)
+			}
+		case c == '"':
+			kind = tString
+			l.advance(1)
+			for l.pos.Offset < uint(len(src)) && src[l.pos.Offset] != '"' && src[l.pos.Offset] != '\n' {
+				if src[l.pos.Offset] == '\\' {
+					l.advance(1)
+				}
+				l.advance(1)
+			}
+			if l.pos.Offset < uint(len(src)) && src[l.pos.Offset] == '"' {
+				l.advance(1)
+			}
+		default:
+			switch c {
+			case '(', '[', '{':
+				kind = map[byte]tokenKind{'(': tLParen, '[': tLBracket, '{': tLBrace}[c]
+				l.depth++
+			case ')', ']', '}':
+				kind = map[byte]tokenKind{')': tRParen, ']': tRBracket, '}': tRBrace}[c]
+				l.depth = max(l.depth-1, 0)
+			case ';':
+				kind = tSemi
+				if l.depth == 0 {
+					kind = tSep
+				}
+			}
+			l.advance(1)
+		}
+		l.emit(kind, start, string(src[start.Offset:l.pos.Offset]), space)
+		space = false
+	}
+	l.emit(tEOF, l.pos, "", true)
+	return l.toks
+}
+
+func (l *lexer) emit(kind tokenKind, start ast.Pos, text string, space bool) {
+	end := start
+	if kind != tSep || text != "\n" {
+		end = l.pos
+	}
+	l.toks = append(l.toks, token{kind: kind, text: text, span: ast.Span{Start: start, End: end}, space: space})
+}
+
+func (l *lexer) advance(n int) {
+	for ; n > 0 && l.pos.Offset < uint(len(l.src)); n-- {
+		if l.src[l.pos.Offset] == '\n' {
+			l.pos.Row++
+			l.pos.Column = 0
+		} else {
+			l.pos.Column++
+		}
+		l.pos.Offset++
+	}
+}
+
+// continued reports whether the line starting at the current position
+// continues the previous one: it is indented and not blank.
+func (l *lexer) continued() bool {
+	rest := l.src[l.pos.Offset:]
+	if len(rest) == 0 || rest[0] != ' ' && rest[0] != '\t' {
+		return false
+	}
+	for _, c := range rest {
+		switch c {
+		case ' ', '\t', '\r':
+			continue
+		case '\n':
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// negativeNumber reports whether the minus sign at the current position
+// starts a negative literal, as in q: it is followed by a digit and
+// either follows white space or does not follow an operand.
+func (l *lexer) negativeNumber(space bool) bool {
+	i := l.pos.Offset + 1
+	if i >= uint(len(l.src)) || !isDigit(l.src[i]) {
+		return false
+	}
+	if space || len(l.toks) == 0 {
+		return true
+	}
+	switch l.toks[len(l.toks)-1].kind {
+	case tNumber, tName, tSymbol, tString, tRParen, tRBracket, tRBrace:
+		return false
+	}
+	return true
+}
+
+func (l *lexer) restOfLineBlank(from uint) bool {
+	for i := l.pos.Offset + from; i < uint(len(l.src)) && l.src[i] != '\n'; i++ {
+		if l.src[i] != ' ' && l.src[i] != '\t' && l.src[i] != '\r' {
+			return false
+		}
+	}
+	return true
+}
+
+func (l *lexer) skipLine() {
+	for l.pos.Offset < uint(len(l.src)) && l.src[l.pos.Offset] != '\n' {
+		l.advance(1)
+	}
+}
+
+// blockComment skips from a line holding a lone slash to the next line
+// holding a lone backslash, or to the end of the source.
+func (l *lexer) blockComment() {
+	for l.pos.Offset < uint(len(l.src)) {
+		l.skipLine()
+		l.advance(1)
+		if l.pos.Offset < uint(len(l.src)) && l.src[l.pos.Offset] == '\\' && l.restOfLineBlank(1) {
+			l.skipLine()
+			return
+		}
+	}
+}
+
+func isDigit(c byte) bool  { return '0' <= c && c <= '9' }
+func isLetter(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
+func isAlnum(c byte) bool  { return isDigit(c) || isLetter(c) }
@@ -0,0 +1,436 @@
+package transpile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+// node is a parsed q expression.
+type node interface{ span() ast.Span }
+
+type (
+	number struct {
+		sp   ast.Span
+		text string
+		neg  bool
+	}
+	name struct {
+		sp   ast.Span
+		text string
+	}
+	unary struct {
+		sp ast.Span
+		x  node
+	}
+	binary struct {
+		sp   ast.Span
+		op   string
+		l, r node
+	}
+	call struct {
+		sp   ast.Span
+		fn   string
+		args []node
+	}
+	lambda struct {
+		sp       ast.Span
+		params   []string
+		explicit bool
+		body     node
+	}
+)
+
+func (n *number) span() ast.Span { return n.sp }
+func (n *name) span() ast.Span   { return n.sp }
+func (n *unary) span() ast.Span  { return n.sp }
+func (n *binary) span() ast.Span { return n.sp }
+func (n *call) span() ast.Span   { return n.sp }
+func (n *lambda) span() ast.Span { return n.sp }
+
+// dyads maps the supported q operators and keywords to wabznasm operators,
+// with the warning their translation needs.
+var dyads = map[string]struct{ op, warning string }{
+	"+":    {"+", ""},
+	"-":    {"-", ""},
+	"*":    {"*", ""},
+	"%":    {"/", "q % always returns a float; wabznasm / truncates longs"},
+	"div":  {"/", "q div rounds down; wabznasm / rounds towards zero"},
+	"mod":  {"%", "q mod takes the sign of the divisor; wabznasm % takes the sign of the dividend"},
+	"xexp": {"^", "q xexp returns a float; wabznasm ^ raises longs to longs"},
+}
+
+// keywords are q keywords used infix that have no translation.
+var keywords = map[string]bool{
+	"and": true, "or": true, "xlog": true, "xbar": true, "within": true, "like": true,
+	"except": true, "inter": true, "union": true, "in": true, "cross": true, "cut": true,
+	"each": true, "over": true, "scan": true, "prior": true, "peach": true, "sv": true, "vs": true,
+}
+
+// bail unwinds the parse of a statement containing an unsupported
+// construct.
+type bail struct{}
+
+type parser struct {
+	toks   []token
+	i      int
+	last   token
+	issues []Issue
+}
+
+func (p *parser) peek() token { return p.toks[p.i] }
+
+func (p *parser) next() token {
+	t := p.toks[p.i]
+	if t.kind != tEOF {
+		p.i++
+	}
+	p.last = t
+	return t
+}
+
+func (p *parser) fail(span ast.Span, format string, args ...any) {
+	p.issues = append(p.issues, Issue{Span: span, Severity: tree_sitter_wabznasm.SeverityError, Message: fmt.Sprintf(format, args...)})
+	panic(bail{})
+}
+
+func (p *parser) warn(span ast.Span, msg string) {
+	p.issues = append(p.issues, Issue{Span: span, Severity: tree_sitter_wabznasm.SeverityWarning, Message: msg})
+}
+
+func (p *parser) expect(kind tokenKind, what string) {
+	if t := p.peek(); t.kind != kind {
+		p.fail(t.span, "expected %s, found %s", what, describe(t))
+	}
+	p.next()
+}
+
+// statement parses a statement and returns its translation, or "" if it
+// contains an unsupported construct.
+func (p *parser) statement() (src string, span ast.Span) {
+	span.Start = p.peek().span.Start
+	mark := len(p.issues)
+	defer func() {
+		span.End = p.last.span.End
+		r := recover()
+		if r == nil {
+			return
+		}
+		if _, ok := r.(bail); !ok {
+			panic(r)
+		}
+		// Keep the error only: warnings about a statement that is not
+		// translated are noise.
+		p.issues = append(p.issues[:mark], p.issues[len(p.issues)-1])
+		for k := p.peek().kind; k != tSep && k != tEOF; k = p.peek().kind {
+			p.next()
+		}
+		span.End = p.last.span.End
+		src = ""
+	}()
+	var assign string
+	if t := p.peek(); t.kind == tName && p.i+2 < len(p.toks) && p.toks[p.i+1].text == ":" && p.toks[p.i+2].text != ":" {
+		p.checkName(t)
+		assign = t.text
+		p.next()
+		p.next()
+	}
+	value := p.expr()
+	if t := p.peek(); t.kind != tSep && t.kind != tEOF {
+		p.fail(t.span, "unexpected %s", describe(t))
+	}
+	p.checkLambdas(value, assign != "")
+	if assign != "" {
+		return assign + ": " + render(value, 0), span
+	}
+	return render(value, 0), span
+}
+
+func ends(t token) bool {
+	switch t.kind {
+	case tSep, tEOF, tRParen, tRBracket, tRBrace, tSemi:
+		return true
+	}
+	return false
+}
+
+func isAdverb(t token) bool {
+	return t.kind == tOp && (t.text == "/" || t.text == "\\" || t.text == "'")
+}
+
+// expr parses an expression. q has no precedence: an operator takes the
+// term on its left and everything on its right.
+func (p *parser) expr() node {
+	t := p.peek()
+	if ends(t) {
+		p.fail(t.span, "missing operand")
+	}
+	if t.kind == tOp {
+		if isAdverb(p.toks[p.i+1]) && !p.toks[p.i+1].space {
+			p.fail(span(t, p.toks[p.i+1]), "adverb %s is not supported", p.toks[p.i+1].text)
+		}
+		p.fail(t.span, "monadic %s is not supported", t.text)
+	}
+	left := p.term()
+	t = p.peek()
+	switch {
+	case ends(t):
+		return left
+	case t.kind == tOp && t.text == ":":
+		p.fail(t.span, "assignment inside an expression is not supported")
+	case isAdverb(t):
+		p.fail(t.span, "adverb %s is not supported", t.text)
+	case t.kind == tOp, t.kind == tName && (dyads[t.text].op != "" || keywords[t.text]):
+		d, ok := dyads[t.text]
+		if !ok {
+			p.fail(t.span, "q %s %s is not supported", map[bool]string{true: "keyword", false: "operator"}[t.kind == tName], t.text)
+		}
+		p.next()
+		if a := p.peek(); isAdverb(a) && !a.space {
+			p.fail(span(t, a), "adverb %s is not supported", a.text)
+		}
+		if d.warning != "" {
+			p.warn(t.span, d.warning)
+		}
+		right := p.expr()
+		return &binary{sp: spanOf(left, right), op: d.op, l: left, r: right}
+	}
+	// Juxtaposition applies the term to the rest of the expression.
+	right := p.expr()
+	sp := spanOf(left, right)
+	switch l := left.(type) {
+	case *name:
+		if l.text == "neg" {
+			return &unary{sp: sp, x: right}
+		}
+		return &call{sp: sp, fn: l.text, args: []node{right}}
+	case *number:
+		if _, ok := right.(*number); ok || t.kind == tNumber {
+			p.fail(sp, "vectors are not supported")
+		}
+		p.fail(sp, "a number cannot be applied")
+	}
+	p.fail(sp, "applying an expression by juxtaposition is not supported")
+	return nil
+}
+
+func (p *parser) term() node {
+	t := p.next()
+	var n node
+	switch t.kind {
+	case tNumber:
+		n = p.number(t)
+	case tName:
+		p.checkName(t)
+		n = &name{sp: t.span, text: t.text}
+	case tLParen:
+		if p.peek().kind == tRParen {
+			p.fail(span(t, p.peek()), "empty lists are not supported")
+		}
+		n = p.expr()
+		if p.peek().kind == tSemi {
+			p.fail(p.peek().span, "general lists are not supported")
+		}
+		p.expect(tRParen, ")")
+	case tLBrace:
+		n = p.lambda(t)
+	case tSymbol:
+		p.fail(t.span, "symbols are not supported")
+	case tString:
+		p.fail(t.span, "strings are not supported")
+	default:
+		p.fail(t.span, "unexpected %s", describe(t))
+	}
+	for p.peek().kind == tLBracket {
+		n = p.call(n)
+	}
+	return n
+}
+
+func (p *parser) number(t token) node {
+	digits := strings.TrimPrefix(strings.TrimSuffix(t.text, "j"), "-")
+	switch {
+	case strings.ContainsAny(t.text, ".ef") || strings.HasSuffix(t.text, "f"):
+		p.fail(t.span, "float literals are not supported")
+	case strings.ContainsAny(t.text, "NWnw"):
+		p.fail(t.span, "null and infinity literals are not supported")
+	case strings.Trim(digits, "0123456789") != "":
+		p.fail(t.span, "literal %s is not supported", t.text)
+	}
+	if _, err := strconv.ParseInt(digits, 10, 64); err != nil {
+		p.fail(t.span, "%s is out of range", t.text)
+	}
+	if next := p.peek(); next.kind == tNumber {
+		p.fail(span(t, next), "vectors are not supported")
+	}
+	return &number{sp: t.span, text: digits, neg: strings.HasPrefix(t.text, "-")}
+}
+
+func (p *parser) checkName(t token) {
+	if strings.Contains(t.text, ".") {
+		p.fail(t.span, "namespaced name %s is not supported", t.text)
+	}
+}
+
+// call parses the bracketed arguments applied to callee.
+func (p *parser) call(callee node) node {
+	open := p.next()
+	fn, ok := callee.(*name)
+	if !ok {
+		p.fail(span(open, open), "only names can be called")
+	}
+	var args []node
+	if p.peek().kind != tRBracket {
+		for {
+			if t := p.peek(); t.kind == tSemi || t.kind == tRBracket {
+				p.fail(t.span, "projections are not supported")
+			}
+			args = append(args, p.expr())
+			if p.peek().kind != tSemi {
+				break
+			}
+			p.next()
+		}
+	}
+	p.expect(tRBracket, "]")
+	sp := ast.Span{Start: callee.span().Start, End: p.last.span.End}
+	d, dyad := dyads[fn.text]
+	switch {
+	case fn.text == "neg" && len(args) == 1:
+		return &unary{sp: sp, x: args[0]}
+	case dyad && len(args) == 2:
+		if d.warning != "" {
+			p.warn(fn.sp, d.warning)
+		}
+		return &binary{sp: sp, op: d.op, l: args[0], r: args[1]}
+	}
+	return &call{sp: sp, fn: fn.text, args: args}
+}
+
+// lambda parses a function literal after its opening brace.
+func (p *parser) lambda(open token) node {
+	fn := &lambda{}
+	if p.peek().kind == tLBracket {
+		p.next()
+		fn.explicit = true
+		for p.peek().kind != tRBracket {
+			t := p.next()
+			if t.kind != tName {
+				p.fail(t.span, "expected a parameter name, found %s", describe(t))
+			}
+			p.checkName(t)
+			fn.params = append(fn.params, t.text)
+			if p.peek().kind == tSemi {
+				p.next()
+			}
+		}
+		p.next()
+	}
+	if t := p.peek(); t.kind == tRBrace {
+		p.fail(span(open, t), "empty function bodies are not supported")
+	}
+	fn.body = p.expr()
+	if t := p.peek(); t.kind == tSemi {
+		p.fail(t.span, "functions with several statements are not supported")
+	}
+	p.expect(tRBrace, "}")
+	fn.sp = ast.Span{Start: open.span.Start, End: p.last.span.End}
+	return fn
+}
+
+// checkLambdas rejects function literals anywhere but as the value of an
+// assignment, the only place wabznasm accepts them.
+func (p *parser) checkLambdas(n node, allowed bool) {
+	switch n := n.(type) {
+	case *lambda:
+		if !allowed {
+			p.fail(n.sp, "function literals are only supported as the value of an assignment")
+		}
+		p.checkLambdas(n.body, false)
+	case *unary:
+		p.checkLambdas(n.x, false)
+	case *binary:
+		p.checkLambdas(n.l, false)
+		p.checkLambdas(n.r, false)
+	case *call:
+		for _, arg := range n.args {
+			p.checkLambdas(arg, false)
+		}
+	}
+}
+
+func describe(t token) string {
+	if t.kind == tEOF {
+		return "end of input"
+	}
+	if t.kind == tSep && t.text == "\n" {
+		return "end of line"
+	}
+	return strconv.Quote(t.text)
+}
+
+func span(from, to token) ast.Span { return ast.Span{Start: from.span.Start, End: to.span.End} }
+
+func spanOf(from, to node) ast.Span {
+	return ast.Span{Start: from.span().Start, End: to.span().End}
+}
+
+// Precedence levels of wabznasm expressions.
+const (
+	precFunction = iota
+	precAdditive
+	precMultiplicative
+	precUnary
+	precPower
+	precPostfix
+	precPrimary
+)
+
+// render formats n as wabznasm, parenthesized if its precedence is below
+// min.
+func render(n node, min int) string {
+	s, prec := layout(n)
+	if prec < min {
+		return "(" + s + ")"
+	}
+	return s
+}
+
+func layout(n node) (string, int) {
+	switch n := n.(type) {
+	case *number:
+		if n.neg {
+			return "-" + n.text, precUnary
+		}
+		return n.text, precPrimary
+	case *name:
+		return n.text, precPrimary
+	case *unary:
+		return "-" + render(n.x, precUnary), precUnary
+	case *binary:
+		if n.op == "^" {
+			return render(n.l, precPostfix) + "^" + render(n.r, precUnary), precPower
+		}
+		level := precAdditive
+		if n.op != "+" && n.op != "-" {
+			level = precMultiplicative
+		}
+		return render(n.l, level) + n.op + render(n.r, level+1), level
+	case *call:
+		args := make([]string, len(n.args))
+		for i, arg := range n.args {
+			args[i] = render(arg, precAdditive)
+		}
+		return n.fn + "[" + strings.Join(args, ";") + "]", precPrimary
+	case *lambda:
+		body := render(n.body, precAdditive)
+		if n.explicit {
+			return "{[" + strings.Join(n.params, ";") + "] " + body + "}", precFunction
+		}
+		return "{" + body + "}", precFunction
+	}
+	panic(fmt.Sprintf("transpile: unexpected node %T", n))
+}
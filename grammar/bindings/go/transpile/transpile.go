@@ -0,0 +1,111 @@
+// Package transpile translates a subset of kdb+/q into wabznasm, for
+// migrating snippets from q.
+//
+// q evaluates right to left with no operator precedence, so 2*3+4 is 14.
+// The translation makes that order explicit with parentheses where
+// wabznasm's precedence would differ, printing 2*(3+4). Longs, names,
+// assignments, function literals with explicit or implicit parameters,
+// bracket calls and monadic application by juxtaposition are supported,
+// as are the operators + - * % and the keywords neg, mod, div and xexp.
+// Comments are dropped.
+//
+// Each q statement becomes one wabznasm source. A construct that has no
+// wabznasm equivalent, such as a symbol, a vector or an adverb, is
+// reported as an error Issue and its statement is not translated.
+// Operators whose wabznasm counterpart differs in some cases, such as %
+// returning a float in q, are translated with a warning Issue.
+package transpile
+
+import (
+	"fmt"
+	"strings"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/ast"
+)
+
+// Issue is a q construct that was not translated, or translated with
+// different semantics.
+type Issue struct {
+	Span ast.Span
+	// Severity is SeverityError for unsupported constructs and
+	// SeverityWarning for approximate translations.
+	Severity tree_sitter_wabznasm.Severity
+	Message  string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s", i.Span.Start.Row+1, i.Span.Start.Column+1, i.Severity, i.Message)
+}
+
+// Statement is a translated q statement.
+type Statement struct {
+	// Span is the statement's range in the q source.
+	Span ast.Span
+	// Source is the wabznasm source.
+	Source string
+}
+
+// Result is the translation of a q source.
+type Result struct {
+	Statements []Statement
+	// Issues are in source order.
+	Issues []Issue
+}
+
+// String returns the translated statements, one per line.
+func (r *Result) String() string {
+	lines := make([]string, len(r.Statements))
+	for i, s := range r.Statements {
+		lines[i] = s.Source
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Error reports the q statements that could not be translated.
+type Error struct {
+	Issues []Issue
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, len(e.Issues))
+	for i, issue := range e.Issues {
+		parts[i] = issue.String()
+	}
+	return "transpile: " + strings.Join(parts, "; ")
+}
+
+// Transpile translates q source. The Result holds every statement that
+// could be translated; if some could not, the error is an *Error listing
+// the unsupported constructs, which the Result's Issues also contain.
+func Transpile(src []byte) (*Result, error) {
+	p := &parser{toks: lex(src)}
+	r := &Result{}
+	for p.peek().kind != tEOF {
+		if p.peek().kind == tSep {
+			p.next()
+			continue
+		}
+		start := len(p.issues)
+		stmt, span := p.statement()
+		if stmt != "" {
+			r.Statements = append(r.Statements, Statement{Span: span, Source: stmt})
+		}
+		r.Issues = append(r.Issues, p.issues[start:]...)
+	}
+	var errs []Issue
+	for _, issue := range r.Issues {
+		if issue.Severity == tree_sitter_wabznasm.SeverityError {
+			errs = append(errs, issue)
+		}
+	}
+	if errs != nil {
+		return r, &Error{Issues: errs}
+	}
+	return r, nil
+}
+
+// TranspileString is Transpile for a string.
+func TranspileString(src string) (*Result, error) {
+	return Transpile([]byte(src))
+}
@@ -0,0 +1,167 @@
+package transpile_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/eval"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/transpile"
+)
+
+func TestTranslate(t *testing.T) {
+	for _, tc := range []struct{ q, want string }{
+		{"2*3+4", "2*(3+4)"},
+		{"a-b-c", "a-(b-c)"},
+		{"(2*3)+4", "2*3+4"},
+		{"x+y*z", "x+y*z"},
+		{"f:{[a;b] a+b*2}", "f: {[a;b] a+b*2}"},
+		{"g:{x*y}", "g: {x*y}"},
+		{"neg x+1", "-(x+1)"},
+		{"neg[x]*2", "-x*2"},
+		{"f g x", "f[g[x]]"},
+		{"f[1;2]+3", "f[1;2]+3"},
+		{"-5 xexp 2", "(-5)^2"},
+		{"2 xexp 3 xexp 2", "2^3^2"},
+		{"x-1", "x-1"},
+		{"x :42j", "x: 42"},
+		{"a*b-c / the rest is a comment", "a*(b-c)"},
+	} {
+		r, err := transpile.TranspileString(tc.q)
+		if err != nil {
+			t.Errorf("%s: %v", tc.q, err)
+			continue
+		}
+		if got := r.String(); got != tc.want {
+			t.Errorf("%s => %s, want %s", tc.q, got, tc.want)
+		}
+	}
+}
+
+// TestEquivalence evaluates translated scripts: each result is the value
+// q gives.
+func TestEquivalence(t *testing.T) {
+	src := `
+k:10
+sq:{x*x}
+f:{[a;b] a-b-1}
+/
+block comments are skipped
+\
+g:{sq x+k}; h:{[n] neg n*2+k}
+`
+	r, err := transpile.TranspileString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Statements) != 5 {
+		t.Fatalf("statements = %q", r.String())
+	}
+	in := eval.New()
+	for _, s := range r.Statements {
+		if _, err := in.EvalString(s.Source); err != nil {
+			t.Fatalf("%s: %v", s.Source, err)
+		}
+	}
+	for q, want := range map[string]string{
+		"2*3+4":         "14",
+		"f[10;4]":       "7",
+		"g[2]":          "144",
+		"h 1":           "-12",
+		"k-2-3":         "11",
+		"sq 2+sq 1+1":   "36",
+		"k+neg f[k;1]":  "0",
+		"2 xexp 3-1":    "4",
+		"f[k;k]*k":      "10",
+		"neg neg k * 2": "20",
+	} {
+		r, err := transpile.TranspileString(q)
+		if err != nil {
+			t.Errorf("%s: %v", q, err)
+			continue
+		}
+		v, err := in.EvalString(r.String())
+		if err != nil || v.String() != want {
+			t.Errorf("%s => %s = %v, %v; want %s", q, r, v, err, want)
+		}
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	for _, tc := range []struct{ q, want string }{
+		{"1 2 3", "1:1: error: vectors are not supported"},
+		{"`abc", "1:1: error: symbols are not supported"},
+		{`"text"`, "1:1: error: strings are not supported"},
+		{"1.5*2", "1:1: error: float literals are not supported"},
+		{"+/x", "1:1: error: adverb / is not supported"},
+		{"x+/y", "1:2: error: adverb / is not supported"},
+		{"x=y", "1:2: error: q operator = is not supported"},
+		{"x within y", "1:3: error: q keyword within is not supported"},
+		{"(1;2)", "1:3: error: general lists are not supported"},
+		{"f[;1]", "1:3: error: projections are not supported"},
+		{"{x+1}", "1:1: error: function literals are only supported as the value of an assignment"},
+		{"f:{g:{x}; g 1}", "1:5: error: assignment inside an expression is not supported"},
+		{"f:{[a] a+1; 2}", "1:11: error: functions with several statements are not supported"},
+		{".q.neg 1", "1:1: error: namespaced name .q.neg is not supported"},
+		{"a.b+1", "1:1: error: namespaced name a.b is not supported"},
+		{"9223372036854775808", "1:1: error: 9223372036854775808 is out of range"},
+	} {
+		r, err := transpile.TranspileString(tc.q)
+		var te *transpile.Error
+		if !errors.As(err, &te) {
+			t.Errorf("%s: err = %v, want a transpile.Error", tc.q, err)
+			continue
+		}
+		if len(r.Issues) != 1 || r.Issues[0].String() != tc.want || len(r.Statements) != 0 {
+			t.Errorf("%s: issues = %v, statements %q; want %s", tc.q, r.Issues, r.String(), tc.want)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	r, err := transpile.TranspileString("x:7 div 2\ny:x % 2\nz:`a\nw:x mod 2")
+	var te *transpile.Error
+	if !errors.As(err, &te) || len(te.Issues) != 1 || te.Issues[0].Span.Start.Row != 2 {
+		t.Fatalf("err = %v, want the symbol on line 3", err)
+	}
+	if got := r.String(); got != "x: 7/2\ny: x/2\nw: x%2" {
+		t.Errorf("translation = %q", got)
+	}
+	var warnings []string
+	for _, issue := range r.Issues {
+		if issue.Severity == tree_sitter_wabznasm.SeverityWarning {
+			warnings = append(warnings, issue.String())
+		}
+	}
+	want := []string{
+		"1:5: warning: q div rounds down; wabznasm / rounds towards zero",
+		"2:5: warning: q % always returns a float; wabznasm / truncates longs",
+		"4:5: warning: q mod takes the sign of the divisor; wabznasm % takes the sign of the dividend",
+	}
+	if strings.Join(warnings, "\n") != strings.Join(want, "\n") {
+		t.Errorf("warnings:\n%s\nwant:\n%s", strings.Join(warnings, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestOutputParses(t *testing.T) {
+	r, err := transpile.TranspileString("a:neg neg 3\nb:7*-2\nc:2 xexp neg 1\nd:-2-3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	for _, s := range r.Statements {
+		tree, err := parser.ParseString(s.Source)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tree.RootNode().HasError() {
+			t.Errorf("%s does not parse: %s", s.Source, tree.RootNode().ToSexp())
+		}
+		tree.Close()
+	}
+}
@@ -0,0 +1,342 @@
+// Package treebin encodes parse trees in a compact binary form, so that a
+// tree parsed in one process can be shipped to another, or kept in a
+// cache, and read back without parsing the source again.
+//
+// A tree_sitter.Tree holds native memory and cannot be rebuilt from
+// bytes, so Decode returns the tree in the shape of
+// tree_sitter_wabznasm.NodeJSON instead: kinds, fields, flags, ranges and
+// structure, and the text of the leaves when the source was encoded with
+// it. The Go value is the same ToJSON would build from the original tree.
+//
+// An encoded tree starts with a header,
+//
+//	magic    "WZTB"
+//	version  uint16, little endian; currently 1
+//	flags    uint16, little endian; bit 0 is set if the source follows
+//	grammar  32 bytes: the content hash of the grammar the tree was
+//	         parsed with, see tree_sitter_wabznasm.ContentHash
+//
+// followed by the kinds and field names the nodes refer to, the source,
+// if included, and the nodes in preorder, all as unsigned varints and
+// length-prefixed strings. A CRC-32C of everything before it ends the
+// data, so that a truncated or corrupted copy is rejected instead of
+// decoding to a wrong tree.
+package treebin
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"unsafe"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+)
+
+// ContentType is the media type of encoded trees.
+const ContentType = "application/vnd.wabznasm.tree"
+
+// Version is the version of the format Encode writes and Decode reads.
+const Version = 1
+
+const (
+	magic      = "WZTB"
+	headerSize = len(magic) + 2 + 2 + hashSize
+	hashSize   = 32
+	sumSize    = 4
+
+	flagSource = 1 << 0
+)
+
+// Node flags.
+const (
+	named = 1 << iota
+	isError
+	missing
+	extra
+)
+
+var (
+	// ErrFormat is returned for data that is not an encoded tree, or is
+	// malformed.
+	ErrFormat = errors.New("treebin: not an encoded tree")
+	// ErrChecksum is returned for data whose checksum does not match.
+	ErrChecksum = errors.New("treebin: checksum mismatch")
+)
+
+// VersionError reports data in a version of the format this package does
+// not read.
+type VersionError struct {
+	Version uint16
+}
+
+func (e *VersionError) Error() string {
+	return fmt.Sprintf("treebin: unsupported format version %d, want %d", e.Version, Version)
+}
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Options configure Encode.
+type Options struct {
+	// Source includes the source in the data, so that Decode can restore
+	// it and the text of the leaves.
+	Source bool
+}
+
+// Tree is a decoded tree.
+type Tree struct {
+	// Grammar is the content hash of the grammar the tree was parsed
+	// with, in hexadecimal. A tree from a different grammar may have
+	// kinds and fields the reader does not know.
+	Grammar string
+	// Source is the source of the tree, or nil if it was not encoded.
+	Source []byte
+	// Root is the root node.
+	Root tree_sitter_wabznasm.NodeJSON
+}
+
+// hashes caches the content hash of each language, by its C pointer, as
+// computing it walks the whole parse table.
+var hashes sync.Map
+
+func grammarHash(lang *tree_sitter.Language) []byte {
+	if lang == nil {
+		return make([]byte, hashSize)
+	}
+	key := unsafe.Pointer(lang.Inner)
+	if h, ok := hashes.Load(key); ok {
+		return h.([]byte)
+	}
+	h, err := hex.DecodeString(tree_sitter_wabznasm.InfoOf(lang).Hash)
+	if err != nil || len(h) != hashSize {
+		h = make([]byte, hashSize)
+	}
+	hashes.Store(key, h)
+	return h
+}
+
+// Encode encodes tree, parsed from src.
+func Encode(tree *tree_sitter.Tree, src []byte, opts Options) []byte {
+	e := &encoder{index: map[string]uint64{}}
+	c := memtrack.Walk(tree.RootNode())
+	defer c.Close()
+	e.node(c.TreeCursor, 0, 0)
+
+	var flags uint16
+	if opts.Source {
+		flags |= flagSource
+	}
+	out := make([]byte, 0, headerSize+len(e.nodes)+len(src)+64)
+	out = append(out, magic...)
+	out = binary.LittleEndian.AppendUint16(out, Version)
+	out = binary.LittleEndian.AppendUint16(out, flags)
+	out = append(out, grammarHash(tree.Language())...)
+	out = binary.AppendUvarint(out, uint64(len(e.strings)))
+	for _, s := range e.strings {
+		out = appendBytes(out, []byte(s))
+	}
+	if opts.Source {
+		out = appendBytes(out, src)
+	}
+	out = append(out, e.nodes...)
+	return binary.LittleEndian.AppendUint32(out, crc32.Checksum(out, castagnoli))
+}
+
+func appendBytes(out, b []byte) []byte {
+	return append(binary.AppendUvarint(out, uint64(len(b))), b...)
+}
+
+type encoder struct {
+	strings []string
+	index   map[string]uint64
+	nodes   []byte
+}
+
+// intern returns the index of s in the string table.
+func (e *encoder) intern(s string) uint64 {
+	i, ok := e.index[s]
+	if !ok {
+		i = uint64(len(e.strings))
+		e.strings = append(e.strings, s)
+		e.index[s] = i
+	}
+	return i
+}
+
+func (e *encoder) uint(v uint) { e.nodes = binary.AppendUvarint(e.nodes, uint64(v)) }
+
+// node writes the node at c and its descendants: kind, field (0 for none,
+// else one more than its index), flags, byte range, points and children.
+// Starts are written relative to the start of the parent, and ends
+// relative to the start of the node, which keeps the varints short.
+func (e *encoder) node(c *tree_sitter.TreeCursor, parentByte, parentRow uint) {
+	n := c.Node()
+	e.nodes = binary.AppendUvarint(e.nodes, e.intern(n.Kind()))
+	var field uint64
+	if name := c.FieldName(); name != "" {
+		field = e.intern(name) + 1
+	}
+	e.nodes = binary.AppendUvarint(e.nodes, field)
+	var flags byte
+	for _, f := range []struct {
+		set  bool
+		flag byte
+	}{{n.IsNamed(), named}, {n.IsError(), isError}, {n.IsMissing(), missing}, {n.IsExtra(), extra}} {
+		if f.set {
+			flags |= f.flag
+		}
+	}
+	e.nodes = append(e.nodes, flags)
+	start, end := n.StartPosition(), n.EndPosition()
+	e.uint(n.StartByte() - parentByte)
+	e.uint(n.EndByte() - n.StartByte())
+	e.uint(start.Row - parentRow)
+	e.uint(start.Column)
+	e.uint(end.Row - start.Row)
+	e.uint(end.Column)
+	e.uint(n.ChildCount())
+	if !c.GotoFirstChild() {
+		return
+	}
+	for {
+		e.node(c, n.StartByte(), start.Row)
+		if !c.GotoNextSibling() {
+			break
+		}
+	}
+	c.GotoParent()
+}
+
+// Decode decodes a tree written by Encode.
+func Decode(data []byte) (*Tree, error) {
+	if len(data) < headerSize+sumSize || !bytes.HasPrefix(data, []byte(magic)) {
+		return nil, ErrFormat
+	}
+	if v := binary.LittleEndian.Uint16(data[len(magic):]); v != Version {
+		return nil, &VersionError{v}
+	}
+	body, sum := data[:len(data)-sumSize], binary.LittleEndian.Uint32(data[len(data)-sumSize:])
+	if crc32.Checksum(body, castagnoli) != sum {
+		return nil, ErrChecksum
+	}
+	flags := binary.LittleEndian.Uint16(data[len(magic)+2:])
+	t := &Tree{Grammar: hex.EncodeToString(data[len(magic)+4 : headerSize])}
+	d := &decoder{data: body[headerSize:]}
+	d.strings = make([]string, d.count())
+	for i := range d.strings {
+		d.strings[i] = string(d.bytes())
+	}
+	if flags&flagSource != 0 {
+		t.Source = d.bytes()
+	}
+	t.Root = d.node(0, 0, 0, t.Source)
+	if d.err == nil && len(d.data) != 0 {
+		d.err = errors.New("trailing data")
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrFormat, d.err)
+	}
+	return t, nil
+}
+
+type decoder struct {
+	data    []byte
+	strings []string
+	err     error
+}
+
+func (d *decoder) uint() uint {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.err = errors.New("truncated")
+		return 0
+	}
+	d.data = d.data[n:]
+	return uint(v)
+}
+
+// count reads a number of items, each taking at least one byte, and
+// rejects counts the rest of the data cannot hold.
+func (d *decoder) count() uint {
+	n := d.uint()
+	if n > uint(len(d.data)) {
+		d.err = errors.New("truncated")
+		return 0
+	}
+	return n
+}
+
+func (d *decoder) bytes() []byte {
+	n := d.count()
+	if d.err != nil {
+		return nil
+	}
+	b := append([]byte(nil), d.data[:n]...)
+	d.data = d.data[n:]
+	return b
+}
+
+func (d *decoder) string(i uint) string {
+	if i >= uint(len(d.strings)) {
+		if d.err == nil {
+			d.err = fmt.Errorf("string %d out of range", i)
+		}
+		return ""
+	}
+	return d.strings[i]
+}
+
+func (d *decoder) node(parentByte, parentRow uint, depth int, src []byte) tree_sitter_wabznasm.NodeJSON {
+	var n tree_sitter_wabznasm.NodeJSON
+	if depth > maxDepth {
+		d.err = errors.New("tree too deep")
+		return n
+	}
+	n.Kind = d.string(d.uint())
+	if field := d.uint(); field != 0 {
+		n.Field = d.string(field - 1)
+	}
+	var flags byte
+	if len(d.data) > 0 {
+		flags, d.data = d.data[0], d.data[1:]
+	} else if d.err == nil {
+		d.err = errors.New("truncated")
+	}
+	n.Named = flags&named != 0
+	n.Error = flags&isError != 0
+	n.Missing = flags&missing != 0
+	n.Extra = flags&extra != 0
+	n.StartByte = parentByte + d.uint()
+	n.EndByte = n.StartByte + d.uint()
+	n.Start.Row = parentRow + d.uint()
+	n.Start.Column = d.uint()
+	n.End.Row = n.Start.Row + d.uint()
+	n.End.Column = d.uint()
+	children := d.count()
+	if d.err != nil {
+		return n
+	}
+	if children == 0 && src != nil && n.EndByte <= uint(len(src)) {
+		text := string(src[n.StartByte:n.EndByte])
+		n.Text = &text
+	}
+	if children > 0 {
+		n.Children = make([]tree_sitter_wabznasm.NodeJSON, 0, children)
+		for i := uint(0); i < children && d.err == nil; i++ {
+			n.Children = append(n.Children, d.node(n.StartByte, n.Start.Row, depth+1, src))
+		}
+	}
+	return n
+}
+
+// maxDepth bounds the nesting Decode accepts, so that crafted data cannot
+// exhaust the stack.
+const maxDepth = 10000
@@ -0,0 +1,81 @@
+package treebin_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/memtrack"
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/treebin"
+)
+
+func parse(t *testing.T, src string) *memtrack.TrackedTree {
+	t.Helper()
+	parser, err := tree_sitter_wabznasm.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer parser.Close()
+	tree, err := parser.ParseString(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, src := range []string{
+		"x: 1+2",
+		"f: {[x;y] x+y*2} / sum\n",
+		"g: {[a] a+}",
+		"h: 😀 1",
+	} {
+		tree := parse(t, src)
+		for _, opts := range []treebin.Options{{}, {Source: true}} {
+			data := treebin.Encode(tree.Tree, []byte(src), opts)
+			got, err := treebin.Decode(data)
+			if err != nil {
+				t.Fatalf("%q: %v", src, err)
+			}
+			var text []byte
+			if opts.Source {
+				text = []byte(src)
+			}
+			if want := tree_sitter_wabznasm.ToJSON(tree.RootNode(), text); !reflect.DeepEqual(got.Root, want) {
+				t.Errorf("%q, %+v: decoded\n%+v\nwant\n%+v", src, opts, got.Root, want)
+			}
+			if string(got.Source) != string(text) || (got.Source == nil) != !opts.Source {
+				t.Errorf("%q, %+v: source %q", src, opts, got.Source)
+			}
+			if got.Grammar != tree_sitter_wabznasm.ContentHash() {
+				t.Errorf("grammar %s", got.Grammar)
+			}
+		}
+		tree.Close()
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tree := parse(t, "f: {[x] x*2}")
+	defer tree.Close()
+	data := treebin.Encode(tree.Tree, []byte("f: {[x] x*2}"), treebin.Options{Source: true})
+
+	if _, err := treebin.Decode([]byte("not a tree at all, not at all, not at all")); !errors.Is(err, treebin.ErrFormat) {
+		t.Errorf("garbage: %v", err)
+	}
+	if _, err := treebin.Decode(data[:len(data)-1]); !errors.Is(err, treebin.ErrChecksum) {
+		t.Errorf("truncated: %v", err)
+	}
+	flipped := append([]byte(nil), data...)
+	flipped[len(flipped)/2] ^= 0x40
+	if _, err := treebin.Decode(flipped); !errors.Is(err, treebin.ErrChecksum) {
+		t.Errorf("corrupted: %v", err)
+	}
+	future := append([]byte(nil), data...)
+	future[4] = 9
+	var ve *treebin.VersionError
+	if _, err := treebin.Decode(future); !errors.As(err, &ve) || ve.Version != 9 {
+		t.Errorf("version: %v", err)
+	}
+}
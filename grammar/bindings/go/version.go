@@ -0,0 +1,119 @@
+package tree_sitter_wabznasm
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	tree_sitter "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/internal/ts"
+)
+
+// LanguageInfo identifies a build of a grammar, so that a parser generated
+// from one version of the grammar can be told from another when the
+// bindings, the grammar and the tree-sitter runtime come from different
+// releases.
+type LanguageInfo struct {
+	// ABIVersion is the tree-sitter ABI the parser was generated for.
+	ABIVersion uint32 `json:"abi_version"`
+	// Version is the version of the grammar, as its tree-sitter.json
+	// gives it, or "" for a parser generated without one.
+	Version string `json:"version,omitempty"`
+	// Hash is a hash of the grammar's node kinds, fields and parse states,
+	// which differs between builds of different grammars whatever their
+	// versions say.
+	Hash string `json:"hash"`
+}
+
+// InfoOf describes lang. It returns the zero LanguageInfo for a nil lang,
+// as on js/wasm before the grammar is loaded.
+func InfoOf(lang *tree_sitter.Language) LanguageInfo {
+	if lang == nil {
+		return LanguageInfo{}
+	}
+	info := LanguageInfo{ABIVersion: lang.AbiVersion(), Hash: contentHash(lang)}
+	if m := lang.Metadata(); m != nil {
+		info.Version = fmt.Sprintf("%d.%d.%d", m.MajorVersion, m.MinorVersion, m.PatchVersion)
+	}
+	return info
+}
+
+// Info describes the grammar in use: the one compiled into these bindings,
+// unless UseLanguage replaced it.
+func Info() LanguageInfo {
+	return InfoOf(tree_sitter.NewLanguage(Language()))
+}
+
+// ABIVersion returns the tree-sitter ABI the grammar was generated for.
+func ABIVersion() uint32 { return Info().ABIVersion }
+
+// LanguageVersion returns the version of the grammar.
+func LanguageVersion() string { return Info().Version }
+
+// ContentHash returns the hash of the grammar; see LanguageInfo.
+func ContentHash() string { return Info().Hash }
+
+// contentHash hashes the ABI version, node kinds, fields and the states
+// reached from each parse state on each symbol.
+func contentHash(lang *tree_sitter.Language) string {
+	h := sha256.New()
+	word := func(v uint32) { h.Write(binary.LittleEndian.AppendUint32(nil, v)) }
+	str := func(s string) { word(uint32(len(s))); h.Write([]byte(s)) }
+	flag := func(b bool) {
+		if b {
+			word(1)
+		} else {
+			word(0)
+		}
+	}
+
+	word(lang.AbiVersion())
+	kinds := lang.NodeKindCount()
+	word(kinds)
+	for id := uint16(0); uint32(id) < kinds; id++ {
+		str(lang.NodeKindForId(id))
+		flag(lang.NodeKindIsNamed(id))
+		flag(lang.NodeKindIsVisible(id))
+	}
+	fields := lang.FieldCount()
+	word(fields)
+	// Field IDs start at 1.
+	for id := uint16(1); uint32(id) <= fields; id++ {
+		str(lang.FieldNameForId(id))
+	}
+	states := lang.ParseStateCount()
+	word(states)
+	for state := uint16(0); uint32(state) < states; state++ {
+		for id := uint16(0); uint32(id) < kinds; id++ {
+			word(uint32(lang.NextState(state, id)))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ABIError reports a grammar generated for a tree-sitter ABI the linked
+// runtime does not support. Loading it anyway would make the runtime read
+// the grammar's tables with the wrong layout, which crashes.
+type ABIError struct {
+	// Version is the ABI of the grammar; Min and Max bound those the
+	// runtime supports.
+	Version, Min, Max uint32
+}
+
+func (e *ABIError) Error() string {
+	hint := "regenerate the parser with a newer tree-sitter CLI"
+	if e.Version > e.Max {
+		hint = "upgrade github.com/tree-sitter/go-tree-sitter, or regenerate the parser with an older tree-sitter CLI"
+	}
+	return fmt.Sprintf("wabznasm: grammar has tree-sitter ABI version %d, but the linked runtime supports versions %d to %d; %s", e.Version, e.Min, e.Max, hint)
+}
+
+// CheckABI returns an *ABIError if the linked tree-sitter runtime cannot
+// load lang.
+func CheckABI(lang *tree_sitter.Language) error {
+	v := lang.AbiVersion()
+	if v < tree_sitter.MIN_COMPATIBLE_LANGUAGE_VERSION || v > tree_sitter.LANGUAGE_VERSION {
+		return &ABIError{Version: v, Min: tree_sitter.MIN_COMPATIBLE_LANGUAGE_VERSION, Max: tree_sitter.LANGUAGE_VERSION}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package tree_sitter_wabznasm_test
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	tree_sitter "github.com/tree-sitter/go-tree-sitter"
+	tree_sitter_wabznasm "github.com/tree-sitter/tree-sitter-wabznasm/bindings/go"
+)
+
+func TestInfo(t *testing.T) {
+	data, err := os.ReadFile("../../tree-sitter.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg struct {
+		Metadata struct{ Version string }
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	info := tree_sitter_wabznasm.Info()
+	if info.Version != cfg.Metadata.Version || tree_sitter_wabznasm.LanguageVersion() != info.Version {
+		t.Errorf("version %q, want %q from tree-sitter.json", info.Version, cfg.Metadata.Version)
+	}
+	if v := tree_sitter_wabznasm.ABIVersion(); v < tree_sitter.MIN_COMPATIBLE_LANGUAGE_VERSION || v > tree_sitter.LANGUAGE_VERSION || v != info.ABIVersion {
+		t.Errorf("ABI version %d", v)
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{64}$`).MatchString(info.Hash) || tree_sitter_wabznasm.ContentHash() != info.Hash {
+		t.Errorf("hash %q", info.Hash)
+	}
+	if got := tree_sitter_wabznasm.InfoOf(nil); got != (tree_sitter_wabznasm.LanguageInfo{}) {
+		t.Errorf("InfoOf(nil) = %+v", got)
+	}
+}
+
+func TestCheckABI(t *testing.T) {
+	if err := tree_sitter_wabznasm.CheckABI(tree_sitter.NewLanguage(tree_sitter_wabznasm.Language())); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		err  tree_sitter_wabznasm.ABIError
+		hint string
+	}{
+		{tree_sitter_wabznasm.ABIError{Version: 16, Min: 13, Max: 15}, "upgrade github.com/tree-sitter/go-tree-sitter"},
+		{tree_sitter_wabznasm.ABIError{Version: 12, Min: 13, Max: 15}, "newer tree-sitter CLI"},
+	}
+	for _, tt := range tests {
+		var err error = &tt.err
+		if msg := err.Error(); !strings.Contains(msg, "ABI version") || !strings.Contains(msg, "13 to 15") || !strings.Contains(msg, tt.hint) {
+			t.Errorf("error %q", msg)
+		}
+		var abi *tree_sitter_wabznasm.ABIError
+		if !errors.As(err, &abi) || abi.Version != tt.err.Version {
+			t.Errorf("errors.As(%v) failed", err)
+		}
+	}
+}
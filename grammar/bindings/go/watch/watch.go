@@ -0,0 +1,159 @@
+// Package watch reports changes to files, for commands that recompute
+// their results whenever a source is saved.
+//
+// A Watcher polls: it periodically walks the paths it watches and compares
+// each file's size and modification time with the previous scan. Polling
+// needs no platform support or third-party dependency, and at the sizes of
+// source trees a scan every half second is cheap.
+package watch
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// DefaultInterval is the time between scans of a Watcher with no Interval.
+const DefaultInterval = 500 * time.Millisecond
+
+// Op is the kind of a change.
+type Op int
+
+const (
+	Create Op = iota + 1
+	Write
+	Remove
+)
+
+func (op Op) String() string {
+	switch op {
+	case Create:
+		return "create"
+	case Write:
+		return "write"
+	case Remove:
+		return "remove"
+	}
+	return "unknown"
+}
+
+// Event is a change to a file.
+type Event struct {
+	Path string
+	Op   Op
+}
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watcher watches files and the files beneath directories.
+type Watcher struct {
+	// Interval is the time between scans.
+	Interval time.Duration
+	// Match selects the files beneath watched directories; nil matches
+	// every file. Files watched by name always match.
+	Match func(path string) bool
+
+	paths []string
+	state map[string]fileState
+}
+
+// New returns a watcher of paths, which may name files or directories. A
+// path that does not exist yet is watched for its creation.
+func New(paths ...string) *Watcher {
+	return &Watcher{paths: paths}
+}
+
+// Scan walks the watched paths and returns the changes since the previous
+// scan, sorted by path. The first scan reports every file as created.
+func (w *Watcher) Scan() ([]Event, error) {
+	state := map[string]fileState{}
+	for _, root := range w.paths {
+		info, err := os.Stat(root)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			state[root] = fileState{info.Size(), info.ModTime()}
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// A directory removed during the walk is just gone.
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || w.Match != nil && !w.Match(path) {
+				return nil
+			}
+			info, err := d.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			state[path] = fileState{info.Size(), info.ModTime()}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var events []Event
+	for path, s := range state {
+		prev, ok := w.state[path]
+		switch {
+		case !ok:
+			events = append(events, Event{path, Create})
+		case prev != s:
+			events = append(events, Event{path, Write})
+		}
+	}
+	for path := range w.state {
+		if _, ok := state[path]; !ok {
+			events = append(events, Event{path, Remove})
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Path < events[j].Path })
+	w.state = state
+	return events, nil
+}
+
+// Run scans until ctx is done, calling fn with the changes of each scan
+// that found any, starting with the files present at the start. It returns
+// ctx.Err(), or the first error of a scan.
+func (w *Watcher) Run(ctx context.Context, fn func([]Event)) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		events, err := w.Scan()
+		if err != nil {
+			return err
+		}
+		if len(events) > 0 {
+			fn(events)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package watch_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tree-sitter/tree-sitter-wabznasm/bindings/go/watch"
+)
+
+func write(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// Set modification times explicitly: writes within a scan interval
+	// can share a timestamp on coarse filesystems.
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScan(t *testing.T) {
+	dir := t.TempDir()
+	a, b, other := filepath.Join(dir, "a.wabznasm"), filepath.Join(dir, "sub", "b.wabznasm"), filepath.Join(dir, "notes.txt")
+	named := filepath.Join(t.TempDir(), "later.wabznasm")
+	base := time.Now().Add(-time.Hour)
+	write(t, a, "1", base)
+	write(t, b, "2", base)
+	write(t, other, "x", base)
+
+	w := watch.New(dir, named)
+	w.Match = func(path string) bool { return strings.HasSuffix(path, ".wabznasm") }
+	scan := func(want ...watch.Event) {
+		t.Helper()
+		got, err := w.Scan()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("events = %v, want %v", got, want)
+		}
+	}
+
+	scan(watch.Event{Path: a, Op: watch.Create}, watch.Event{Path: b, Op: watch.Create})
+	scan()
+	write(t, a, "10", base)
+	write(t, other, "xy", base.Add(time.Second))
+	scan(watch.Event{Path: a, Op: watch.Write})
+	write(t, named, "3", base)
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	scan(watch.Event{Path: b, Op: watch.Remove}, watch.Event{Path: named, Op: watch.Create})
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.wabznasm")
+	write(t, path, "1", time.Now().Add(-time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	w := watch.New(dir)
+	w.Interval = 10 * time.Millisecond
+	var got []watch.Op
+	err := w.Run(ctx, func(events []watch.Event) {
+		got = append(got, events[0].Op)
+		if len(got) == 1 {
+			write(t, path, "12", time.Now())
+		} else {
+			cancel()
+		}
+	})
+	if err != context.Canceled {
+		t.Errorf("Run = %v", err)
+	}
+	if want := []watch.Op{watch.Create, watch.Write}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ops = %v, want %v", got, want)
+	}
+}
@@ -1,5 +1,7 @@
 module github.com/tree-sitter/tree-sitter-wabznasm
 
-go 1.22
+go 1.24
 
-require github.com/tree-sitter/go-tree-sitter v0.24.0
+require github.com/tree-sitter/go-tree-sitter v0.25.0
+
+require github.com/mattn/go-pointer v0.0.1 // indirect
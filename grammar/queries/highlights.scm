@@ -0,0 +1,46 @@
+; Comments and literals
+
+(comment) @comment
+
+(number) @number
+
+; Definitions take precedence over plain variables
+
+(assignment
+  name: (identifier) @function
+  value: (function_body))
+
+(assignment
+  name: (identifier) @variable)
+
+(parameter_list
+  param: (identifier) @variable.parameter)
+
+(function_call
+  function: (identifier) @function.call)
+
+(identifier) @variable
+
+; Operators and punctuation
+
+[
+  "+"
+  "-"
+  "*"
+  "/"
+  "%"
+  "^"
+  "!"
+  ":"
+] @operator
+
+[
+  "("
+  ")"
+  "["
+  "]"
+  "{"
+  "}"
+] @punctuation.bracket
+
+";" @punctuation.delimiter
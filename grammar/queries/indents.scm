@@ -0,0 +1,19 @@
+; Lines inside a bracketed construct are indented one level deeper than
+; the line it starts on
+
+(function_body) @indent.begin
+
+(parameter_list) @indent.begin
+
+(function_call) @indent.begin
+
+(primary
+  left_paren: "(") @indent.begin
+
+; A line starting with the closing bracket lines up with the opening line
+
+[
+  "}"
+  "]"
+  ")"
+] @indent.branch
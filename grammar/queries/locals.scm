@@ -0,0 +1,11 @@
+; Function bodies introduce a scope for their parameters
+
+(function_body) @local.scope
+
+(parameter_list
+  param: (identifier) @local.definition)
+
+(assignment
+  name: (identifier) @local.definition)
+
+(identifier) @local.reference
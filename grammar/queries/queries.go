@@ -0,0 +1,30 @@
+// Package queries embeds the grammar's tree-sitter query files so Go
+// consumers always get the queries matching the compiled grammar.
+package queries
+
+import _ "embed"
+
+// Highlights is the source of highlights.scm.
+//
+//go:embed highlights.scm
+var Highlights string
+
+// Locals is the source of locals.scm.
+//
+//go:embed locals.scm
+var Locals string
+
+// Injections is the source of injections.scm.
+//
+//go:embed injections.scm
+var Injections string
+
+// Indents is the source of indents.scm.
+//
+//go:embed indents.scm
+var Indents string
+
+// Textobjects is the source of textobjects.scm.
+//
+//go:embed textobjects.scm
+var Textobjects string
@@ -0,0 +1,28 @@
+; Structural text objects, following the nvim-treesitter-textobjects
+; capture names
+
+; A function is its whole definition; inside it is the body between the
+; braces
+
+(assignment
+  value: (function_body)) @function.outer
+
+(function_body
+  body: (expression) @function.inner)
+
+; A call and the arguments between its brackets
+
+(function_call) @call.outer
+
+(function_call
+  args: (argument_list) @call.inner)
+
+; Each parameter of a definition and each argument of a call
+
+(parameter_list
+  param: (identifier) @parameter.inner)
+
+(argument_list
+  arg: (expression) @parameter.inner)
+
+(comment) @comment.outer